@@ -0,0 +1,148 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/artifacts"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	"github.com/google/subcommands"
+	"go.uber.org/zap"
+)
+
+// CleanupArtifactsCmd struct with flags.
+type CleanupArtifactsCmd struct {
+	artifactsPath string
+	policyFile    string
+	project       string
+	auditLog      string
+	interval      time.Duration
+	dryRun        bool
+	logLevel      string
+	logFormat     string
+	logFile       string
+}
+
+// Name returns the name of operation.
+func (cmd *CleanupArtifactsCmd) Name() string {
+	return "cleanup-artifacts"
+}
+
+// Synopsis returns summary of operation.
+func (cmd *CleanupArtifactsCmd) Synopsis() string {
+	return "delete generated artifacts which have outlived their retention period"
+}
+
+// Usage returns usage info of the command.
+func (cmd *CleanupArtifactsCmd) Usage() string {
+	return fmt.Sprintf(`%v cleanup-artifacts -path=gs://bucket/prefix -retention-policy=policy.json...
+
+Delete the artifacts generated under a GCS path, i.e. staged session files,
+tuning configs, reports and dead letter queue samples, which are older than
+the retention period configured for their kind in the retention policy. Each
+deleted artifact is recorded in the audit log. With -interval the cleanup is
+repeated periodically. The cleanup-artifacts flags are:
+`, path.Base(os.Args[0]))
+}
+
+// SetFlags sets the flags.
+func (cmd *CleanupArtifactsCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.artifactsPath, "path", "", "GCS path under which the artifacts are stored e.g., gs://my-bucket/smt/")
+	f.StringVar(&cmd.policyFile, "retention-policy", "", "Json file with the retention period of each artifact kind, by default and per project")
+	f.StringVar(&cmd.project, "project", "", "Project whose retention periods apply, defaults to the project of the gcloud configuration")
+	f.StringVar(&cmd.auditLog, "audit-log", artifacts.AUDIT_LOG_FILE_NAME, "File the deleted artifacts are recorded in")
+	f.DurationVar(&cmd.interval, "interval", 0, "Repeat the cleanup at this interval e.g., 24h, runs once if not set")
+	f.BoolVar(&cmd.dryRun, "dry-run", false, "Flag for recording the expired artifacts in the audit log without deleting them")
+	f.StringVar(&cmd.logLevel, "log-level", "DEBUG", "Configure the logging level for the command (INFO, DEBUG), defaults to DEBUG")
+	f.StringVar(&cmd.logFormat, "log-format", logger.CONSOLE_ENCODING, "Encoding of the logs written to the console (console, json), defaults to console")
+	f.StringVar(&cmd.logFile, "log-file", logger.LOG_FILE_NAME, "File the logs are appended to, defaults to spanner-migration-tool.log")
+}
+
+func (cmd *CleanupArtifactsCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Fatal("FATAL error", zap.Error(err))
+		}
+	}()
+	err = logger.InitializeLoggerWithConfig(logger.Config{Level: cmd.logLevel, Encoding: cmd.logFormat, OutputPath: cmd.logFile})
+	if err != nil {
+		fmt.Println("Error initialising logger, did you specify a valid log-level? [DEBUG, INFO, WARN, ERROR, FATAL]", err)
+		return subcommands.ExitFailure
+	}
+	defer logger.Log.Sync()
+
+	if cmd.artifactsPath == "" || cmd.policyFile == "" {
+		err = fmt.Errorf("please specify both -path and -retention-policy")
+		return subcommands.ExitUsageError
+	}
+	policy, err := artifacts.ReadPolicy(cmd.policyFile)
+	if err != nil {
+		return subcommands.ExitUsageError
+	}
+	if cmd.project == "" {
+		cmd.project, err = utils.GetProject()
+		if err != nil {
+			err = fmt.Errorf("can't get project: %v", err)
+			return subcommands.ExitUsageError
+		}
+	}
+	auditLog, err := os.OpenFile(cmd.auditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		err = fmt.Errorf("can't open audit log %s: %v", cmd.auditLog, err)
+		return subcommands.ExitFailure
+	}
+	defer auditLog.Close()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		err = fmt.Errorf("can't create GCS client: %v", err)
+		return subcommands.ExitFailure
+	}
+	defer client.Close()
+
+	c := artifacts.Collector{
+		Storage:  artifacts.GcsStorageAccessor{Client: client},
+		Project:  cmd.project,
+		Periods:  policy.ForProject(cmd.project),
+		AuditLog: auditLog,
+		DryRun:   cmd.dryRun,
+	}
+	for {
+		res, gcErr := c.Collect(ctx, cmd.artifactsPath)
+		logger.Log.Info("Artifact cleanup finished", zap.String("path", cmd.artifactsPath), zap.Int("scanned", res.Scanned),
+			zap.Int("deleted", res.Deleted), zap.Int64("deletedBytes", res.DeletedBytes), zap.Int("failed", res.Failed), zap.Bool("dryRun", cmd.dryRun))
+		if cmd.interval == 0 {
+			err = gcErr
+			break
+		}
+		if gcErr != nil {
+			// Keep running, the failed deletes are retried in the next run.
+			logger.Log.Error("Artifact cleanup failed", zap.Error(gcErr))
+		}
+		time.Sleep(cmd.interval)
+	}
+	if err != nil {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
@@ -26,6 +26,7 @@ import (
 	sp "cloud.google.com/go/spanner"
 	database "cloud.google.com/go/spanner/admin/database/apiv1"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/tracing"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/conversion"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
@@ -39,17 +40,23 @@ import (
 
 // DataCmd struct with flags.
 type DataCmd struct {
-	source          string
-	sourceProfile   string
-	target          string
-	targetProfile   string
-	sessionJSON     string
-	filePrefix      string // TODO: move filePrefix to global flags
-	WriteLimit      int64
-	dryRun          bool
-	logLevel        string
-	SkipForeignKeys bool
-	validate        bool
+	source              string
+	sourceProfile       string
+	target              string
+	targetProfile       string
+	sessionJSON         string
+	filePrefix          string // TODO: move filePrefix to global flags
+	WriteLimit          int64
+	dryRun              bool
+	logLevel            string
+	logFormat           string
+	logFile             string
+	SkipForeignKeys     bool
+	validate            bool
+	schemaCheckInterval time.Duration
+	pauseOnSchemaChange bool
+	minProcessingUnits  int
+	reverseReplication  bool
 }
 
 // Name returns the name of operation.
@@ -84,8 +91,14 @@ func (cmd *DataCmd) SetFlags(f *flag.FlagSet) {
 	f.Int64Var(&cmd.WriteLimit, "write-limit", DefaultWritersLimit, "Write limit for writes to spanner")
 	f.BoolVar(&cmd.dryRun, "dry-run", false, "Flag for generating DDL and schema conversion report without creating a spanner database")
 	f.StringVar(&cmd.logLevel, "log-level", "DEBUG", "Configure the logging level for the command (INFO, DEBUG), defaults to DEBUG")
+	f.StringVar(&cmd.logFormat, "log-format", logger.CONSOLE_ENCODING, "Encoding of the logs written to the console (console, json), defaults to console")
+	f.StringVar(&cmd.logFile, "log-file", logger.LOG_FILE_NAME, "File the logs are appended to, defaults to spanner-migration-tool.log")
 	f.BoolVar(&cmd.SkipForeignKeys, "skip-foreign-keys", false, "Skip creating foreign keys after data migration is complete (ddl statements for foreign keys can still be found in the downloaded schema.ddl.txt file and the same can be applied separately)")
 	f.BoolVar(&cmd.validate, "validate", false, "Flag for validating if all the required input parameters are present")
+	f.DurationVar(&cmd.schemaCheckInterval, "schema-check-interval", 0, "Interval at which the source schema is checked for changes during data migration e.g., 5m, disabled if not set")
+	f.BoolVar(&cmd.pauseOnSchemaChange, "pause-on-schema-change", false, "Pause data migration while the source schema differs from the converted schema, used with -schema-check-interval")
+	f.IntVar(&cmd.minProcessingUnits, "min-processing-units", 0, "Raise the processing units of the Spanner instance to at least this value during data migration, and restore them afterwards e.g., 3000, disabled if not set")
+	f.BoolVar(&cmd.reverseReplication, "reverse-replication", false, "Once the migration completes, offer to create the reverse replication pipeline to the source, prefilled from the migration")
 }
 
 func (cmd *DataCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -97,12 +110,17 @@ func (cmd *DataCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface
 			logger.Log.Fatal("FATAL error", zap.Error(err))
 		}
 	}()
-	err = logger.InitializeLogger(cmd.logLevel)
+	err = logger.InitializeLoggerWithConfig(logger.Config{Level: cmd.logLevel, Encoding: cmd.logFormat, OutputPath: cmd.logFile})
 	if err != nil {
 		fmt.Println("Error initialising logger, did you specify a valid log-level? [DEBUG, INFO, WARN, ERROR, FATAL]", err)
 		return subcommands.ExitFailure
 	}
 	defer logger.Log.Sync()
+	flushTraces, err := tracing.Init(ctx)
+	if err != nil {
+		return subcommands.ExitFailure
+	}
+	defer flushTraces()
 
 	conv := internal.MakeConv()
 	// validate and parse source-profile, target-profile and source
@@ -117,8 +135,12 @@ func (cmd *DataCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface
 	)
 	// Populate migration request id and migration type in conv object.
 	conv.Audit.MigrationRequestId = "SMT-" + uuid.New().String()
+	ctx = logger.WithMigration(ctx, conv.Audit.MigrationRequestId, cmd.Name())
 	conv.Audit.MigrationType = migration.MigrationData_DATA_ONLY.Enum()
 	conv.Audit.SkipMetricsPopulation = os.Getenv("SKIP_METRICS_POPULATION") == "true"
+	conv.Audit.SchemaCheckInterval = cmd.schemaCheckInterval
+	conv.Audit.PauseOnSchemaChange = cmd.pauseOnSchemaChange
+	conv.Audit.MinProcessingUnits = int32(cmd.minProcessingUnits)
 	dataCoversionStartTime := time.Now()
 
 	if cmd.validate {
@@ -172,6 +194,9 @@ func (cmd *DataCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface
 	conversion.WriteBadData(bw, conv, banner, cmd.filePrefix+badDataFile, ioHelper.Out)
 	// Cleanup smt tmp data directory.
 	os.RemoveAll(filepath.Join(os.TempDir(), constants.SMT_TMP_DIR))
+	if cmd.reverseReplication && !cmd.dryRun {
+		offerReverseReplication(ctx, sourceProfile, targetProfile, dbName, cmd.sessionJSON, cmd.filePrefix, ioHelper.Out)
+	}
 	return subcommands.ExitSuccess
 }
 
@@ -0,0 +1,1113 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/profiles"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/reverse_replication/reverserepl"
+	"github.com/google/subcommands"
+	"go.uber.org/zap"
+)
+
+// Output formats of the reverse-replication subcommands.
+const (
+	TABLE_FORMAT = "table"
+	JSON_FORMAT  = "json"
+)
+
+// ReverseReplicationCmd groups the subcommands managing the lifecycle of a
+// reverse replication pipeline.
+type ReverseReplicationCmd struct {
+	output string
+}
+
+// Name returns the name of operation.
+func (cmd *ReverseReplicationCmd) Name() string {
+	return "reverse-replication"
+}
+
+// Synopsis returns summary of operation.
+func (cmd *ReverseReplicationCmd) Synopsis() string {
+	return "create and manage reverse replication pipelines"
+}
+
+// Usage returns usage info of the command.
+func (cmd *ReverseReplicationCmd) Usage() string {
+	return fmt.Sprintf(`%v reverse-replication [-output=table|json] <subcommand> [flags]
+
+Manage the pipelines replicating the changes made in Spanner back to the
+source shards. The subcommands are:
+
+  create    launch a pipeline
+  status    show the state of the Dataflow jobs of a pipeline
+  list      list the pipelines of a project and region
+  delete    cancel the jobs of a pipeline and delete its resources
+  export    write the configuration a pipeline was created with to a file
+  clone     create a pipeline with the configuration of another one
+  watch     restart the failed ordering and writer jobs of a pipeline
+  adopt     register resources created by hand as resources of a pipeline
+  pause     drain the writer jobs of a pipeline, buffering the changes
+  resume    relaunch the writer jobs of a paused pipeline
+  metrics   show the jobs of a pipeline and the changes waiting per shard
+  generate-session
+            generate a session file for a database not migrated with this tool
+  generate-shards
+            generate the source shards file from the source profile of the
+            forward migration
+
+With -output=json, the subcommands write their result, or their error, to
+stdout as json and their progress messages to stderr. Use
+"%v reverse-replication help <subcommand>" for the flags of a subcommand. The
+reverse-replication flags are:
+`, path.Base(os.Args[0]), path.Base(os.Args[0]))
+}
+
+// SetFlags sets the flags.
+func (cmd *ReverseReplicationCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.output, "output", TABLE_FORMAT, "Output format of the subcommands (table, json), defaults to table")
+}
+
+func (cmd *ReverseReplicationCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	topFlags := flag.NewFlagSet(cmd.Name(), flag.ContinueOnError)
+	if err := topFlags.Parse(f.Args()); err != nil {
+		return subcommands.ExitUsageError
+	}
+	cdr := subcommands.NewCommander(topFlags, path.Base(os.Args[0])+" "+cmd.Name())
+	cdr.Register(cdr.HelpCommand(), "")
+	cdr.Register(cdr.CommandsCommand(), "")
+	cdr.Register(&reverseReplicationCreateCmd{}, "")
+	cdr.Register(&reverseReplicationStatusCmd{}, "")
+	cdr.Register(&reverseReplicationListCmd{}, "")
+	cdr.Register(&reverseReplicationDeleteCmd{}, "")
+	cdr.Register(&reverseReplicationExportCmd{}, "")
+	cdr.Register(&reverseReplicationCloneCmd{}, "")
+	cdr.Register(&reverseReplicationWatchCmd{}, "")
+	cdr.Register(&reverseReplicationAdoptCmd{}, "")
+	cdr.Register(&reverseReplicationPauseCmd{}, "")
+	cdr.Register(&reverseReplicationResumeCmd{}, "")
+	cdr.Register(&reverseReplicationMetricsCmd{}, "")
+	cdr.Register(&reverseReplicationGenerateSessionCmd{}, "")
+	cdr.Register(&reverseReplicationGenerateShardsCmd{}, "")
+	return cdr.Execute(ctx, cmd.output)
+}
+
+// launcherFlags collects the repeated -launcher-flag=name=value flags.
+type launcherFlags map[string]string
+
+func (l launcherFlags) String() string {
+	var pairs []string
+	for name, value := range l {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l launcherFlags) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", s)
+	}
+	l[strings.TrimPrefix(name, "-")] = value
+	return nil
+}
+
+// reverseReplicationFlags are the flags shared by the reverse-replication
+// subcommands.
+type reverseReplicationFlags struct {
+	project              string
+	dataflowRegion       string
+	jobNamePrefix        string
+	changeStreamName     string
+	streamRetention      string
+	valueCaptureType     string
+	instance             string
+	database             string
+	metadataProject      string
+	metadataInstance     string
+	metadataDatabase     string
+	metadataSuffix       string
+	tenant               string
+	pubSubTopic          string
+	sourceShardsFile     string
+	sessionFile          string
+	sourceType           string
+	sourceTimezoneOffset string
+	transformationJar    string
+	transformationClass  string
+	shardingFunction     string
+	shardingColumn       string
+	shardingRanges       string
+	tags                 string
+	configFile           string
+	launcherFlags        launcherFlags
+	output               string
+	logLevel             string
+	logFormat            string
+	logFile              string
+	projectFlagsOnly     bool
+}
+
+// setFlags sets the flags. The pipeline flags are left out for the
+// subcommands which don't act on a single pipeline.
+func (rf *reverseReplicationFlags) setFlags(f *flag.FlagSet) {
+	f.StringVar(&rf.project, "project", "", "Project of the Dataflow jobs and of the Spanner instance")
+	f.StringVar(&rf.dataflowRegion, "dataflow-region", "", "Region of the Dataflow jobs")
+	if !rf.projectFlagsOnly {
+		f.StringVar(&rf.jobNamePrefix, "job-name-prefix", "", "Job name prefix of the Dataflow jobs of the pipeline, defaults to reverse-rep")
+		f.StringVar(&rf.changeStreamName, "change-stream", "", "Name of the change stream, defaults to reverseReplicationStream")
+		f.StringVar(&rf.streamRetention, "change-stream-retention", "", "Minimum retention period of the change stream, between 1d and 7d e.g., 36h, defaults to 1d")
+		f.StringVar(&rf.valueCaptureType, "change-stream-value-capture-type", "", "Value capture type of the change stream (NEW_ROW, NEW_ROW_AND_OLD_VALUES), defaults to NEW_ROW")
+		f.StringVar(&rf.instance, "instance", "", "Spanner instance id")
+		f.StringVar(&rf.database, "database", "", "Spanner database name, or a comma separated list of databases replicated by the same pipeline")
+		f.StringVar(&rf.metadataProject, "metadata-project", "", "Project of the Spanner instance of the change stream metadata, defaults to the project")
+		f.StringVar(&rf.metadataInstance, "metadata-instance", "", "Spanner instance of the change stream metadata, defaults to the Spanner instance")
+		f.StringVar(&rf.metadataDatabase, "metadata-database", "", "Spanner database of the change stream metadata, defaults to change-stream-metadata")
+		f.StringVar(&rf.metadataSuffix, "metadata-table-suffix", "", "Suffix of the change stream metadata tables")
+		f.StringVar(&rf.tenant, "tenant", "", "Team or owner the pipeline belongs to. Other tenants sharing the metadata database can't inspect or delete it. Defaults to the gcloud account")
+		f.StringVar(&rf.pubSubTopic, "pubsub-topic", "", "Pub/Sub topic id the changes are buffered in, defaults to reverse-replication")
+		f.StringVar(&rf.sourceShardsFile, "source-shards-file", "", "GCS or local path of the source shards file")
+		f.StringVar(&rf.sessionFile, "session-file", "", "GCS or local path of the session file")
+		f.StringVar(&rf.sourceType, "source-type", "", "Type of the source shards (mysql, sqlserver, cassandra, oracle), defaults to mysql")
+		f.StringVar(&rf.sourceTimezoneOffset, "source-timezone-offset", "", "Timezone offset of the source shards e.g., +05:30, defaults to +00:00")
+		f.StringVar(&rf.transformationJar, "writer-transformation-jar", "", "GCS path of a jar with a custom transformation applied by the writer jobs to the values written to the source")
+		f.StringVar(&rf.transformationClass, "writer-transformation-class", "", "Fully qualified name of the custom transformation class in the writer transformation jar")
+		f.StringVar(&rf.shardingFunction, "sharding-function", "", "Built-in function assigning the changes to the source shards (identity, modulo, rangeMap), defaults to identity")
+		f.StringVar(&rf.shardingColumn, "sharding-column", "", "Spanner column holding the integer sharding key of the modulo and rangeMap sharding functions")
+		f.StringVar(&rf.shardingRanges, "sharding-ranges", "", "Comma separated <start>:<logicalShardId> ranges of the rangeMap sharding function e.g., 0:shard1,1000000:shard2")
+		f.StringVar(&rf.tags, "tags", "", "Comma separated tags of the pipeline, each a key or key=value e.g., env=prod,app=billing, to filter reverse-replication list")
+		rf.launcherFlags = make(launcherFlags)
+		f.Var(rf.launcherFlags, "launcher-flag", "Any other flag of the reverse replication launcher as name=value e.g., writerFanOut=2, can be repeated")
+		f.StringVar(&rf.configFile, "config", "", "Json or yaml file with the pipeline configuration, as written by create -interactive or export. The other flags override it")
+	}
+	f.StringVar(&rf.output, "output", "", "Output format (table, json), defaults to the -output of reverse-replication")
+	f.StringVar(&rf.logLevel, "log-level", "DEBUG", "Configure the logging level for the command (INFO, DEBUG), defaults to DEBUG")
+	f.StringVar(&rf.logFormat, "log-format", logger.CONSOLE_ENCODING, "Encoding of the logs written to the console (console, json), defaults to console")
+	f.StringVar(&rf.logFile, "log-file", logger.LOG_FILE_NAME, "File the logs are appended to, defaults to spanner-migration-tool.log")
+}
+
+// jobData returns the pipeline configuration of the flags, on top of the one
+// read from the config file if any.
+func (rf *reverseReplicationFlags) jobData() (reverserepl.JobData, error) {
+	var j reverserepl.JobData
+	if rf.configFile != "" {
+		var err error
+		if j, err = reverserepl.ReadJobData(rf.configFile); err != nil {
+			return j, err
+		}
+	}
+	for _, f := range []struct {
+		field *string
+		value string
+	}{
+		{&j.ProjectId, rf.project},
+		{&j.DataflowRegion, rf.dataflowRegion},
+		{&j.JobNamePrefix, rf.jobNamePrefix},
+		{&j.ChangeStreamName, rf.changeStreamName},
+		{&j.ChangeStreamRetention, rf.streamRetention},
+		{&j.ChangeStreamValueCaptureType, rf.valueCaptureType},
+		{&j.InstanceId, rf.instance},
+		{&j.DbName, rf.database},
+		{&j.MetadataProject, rf.metadataProject},
+		{&j.MetadataInstance, rf.metadataInstance},
+		{&j.MetadataDatabase, rf.metadataDatabase},
+		{&j.MetadataTableSuffix, rf.metadataSuffix},
+		{&j.Tenant, rf.tenant},
+		{&j.PubSubDataTopicId, rf.pubSubTopic},
+		{&j.SourceShardsFilePath, rf.sourceShardsFile},
+		{&j.SessionFilePath, rf.sessionFile},
+		{&j.SourceType, rf.sourceType},
+		{&j.SourceDbTimezoneOffset, rf.sourceTimezoneOffset},
+		{&j.WriterTransformationJarPath, rf.transformationJar},
+		{&j.WriterTransformationClassName, rf.transformationClass},
+		{&j.ShardingFunction, rf.shardingFunction},
+		{&j.ShardingColumn, rf.shardingColumn},
+		{&j.ShardingRanges, rf.shardingRanges},
+	} {
+		if f.value != "" {
+			*f.field = f.value
+		}
+	}
+	if rf.tags != "" {
+		j.Tags = strings.Split(rf.tags, ",")
+	}
+	if len(rf.launcherFlags) > 0 && j.Flags == nil {
+		j.Flags = make(map[string]string)
+	}
+	for name, value := range rf.launcherFlags {
+		j.Flags[name] = value
+	}
+	return j, nil
+}
+
+// resultOutput is the json output of the subcommands which fail, or which
+// have no other output.
+type resultOutput struct {
+	Subcommand string `json:"subcommand"`
+	Succeeded  bool   `json:"succeeded"`
+	Error      string `json:"error,omitempty"`
+}
+
+// run initializes the logger, runs the subcommand and writes its output, if
+// any, in the requested format. args are the arguments the subcommand was
+// executed with, holding the -output of reverse-replication. writeTable writes
+// the output as a table.
+func (rf *reverseReplicationFlags) run(ctx context.Context, name string, args []interface{}, action func(ctx context.Context) (interface{}, error), writeTable func(w *tabwriter.Writer, out interface{})) subcommands.ExitStatus {
+	if rf.output == "" && len(args) > 0 {
+		rf.output, _ = args[0].(string)
+	}
+	if rf.output == "" {
+		rf.output = TABLE_FORMAT
+	}
+	if rf.output != TABLE_FORMAT && rf.output != JSON_FORMAT {
+		fmt.Printf("Invalid output %s, please specify one of %s and %s\n", rf.output, TABLE_FORMAT, JSON_FORMAT)
+		return subcommands.ExitUsageError
+	}
+	// With json output, stdout is kept for the output of the subcommand.
+	console := io.Writer(os.Stdout)
+	if rf.output == JSON_FORMAT {
+		console = os.Stderr
+	}
+	err := logger.InitializeLoggerWithConfig(logger.Config{Level: rf.logLevel, Encoding: rf.logFormat, OutputPath: rf.logFile, Console: console})
+	if err != nil {
+		fmt.Println("Error initialising logger, did you specify a valid log-level? [DEBUG, INFO, WARN, ERROR, FATAL]", err)
+		return subcommands.ExitFailure
+	}
+	defer logger.Log.Sync()
+	ctx = logger.WithMigration(ctx, rf.jobNamePrefix, "reverse_replication")
+
+	if rf.output == JSON_FORMAT {
+		// The progress messages of the pipeline steps go to stderr as well.
+		stdout := os.Stdout
+		os.Stdout = os.Stderr
+		out, err := action(ctx)
+		os.Stdout = stdout
+		if err != nil {
+			logger.Log.Error(fmt.Sprintf("reverse-replication %s failed", name), zap.Error(err))
+			out = resultOutput{Subcommand: name, Error: err.Error()}
+		} else if out == nil {
+			out = resultOutput{Subcommand: name, Succeeded: true}
+		}
+		b, jsonErr := json.MarshalIndent(out, "", "  ")
+		if jsonErr != nil {
+			fmt.Println("Error in writing the output:", jsonErr)
+			return subcommands.ExitFailure
+		}
+		fmt.Println(string(b))
+		if err != nil {
+			return subcommands.ExitFailure
+		}
+		return subcommands.ExitSuccess
+	}
+
+	out, err := action(ctx)
+	if err != nil {
+		logger.Log.Error(fmt.Sprintf("reverse-replication %s failed", name), zap.Error(err))
+		fmt.Printf("Error in reverse-replication %s: %v\n", name, err)
+		return subcommands.ExitFailure
+	}
+	if out == nil {
+		return subcommands.ExitSuccess
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	writeTable(w, out)
+	w.Flush()
+	return subcommands.ExitSuccess
+}
+
+// jobStatusOutput is a Dataflow job in the output of the subcommands.
+type jobStatusOutput struct {
+	Name  string `json:"name"`
+	JobId string `json:"jobId"`
+	State string `json:"state"`
+}
+
+func toJobStatusOutputs(statuses []reverserepl.JobStatus) []jobStatusOutput {
+	out := []jobStatusOutput{}
+	for _, s := range statuses {
+		out = append(out, jobStatusOutput{Name: s.Name, JobId: s.JobId, State: strings.TrimPrefix(s.State.String(), "JOB_STATE_")})
+	}
+	return out
+}
+
+func writeJobStatusTable(w *tabwriter.Writer, jobs []jobStatusOutput) {
+	fmt.Fprintln(w, "NAME\tJOB ID\tSTATE")
+	for _, j := range jobs {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", j.Name, j.JobId, j.State)
+	}
+}
+
+type reverseReplicationCreateCmd struct {
+	reverseReplicationFlags
+	interactive bool
+}
+
+func (cmd *reverseReplicationCreateCmd) Name() string { return "create" }
+func (cmd *reverseReplicationCreateCmd) Synopsis() string {
+	return "launch a reverse replication pipeline"
+}
+func (cmd *reverseReplicationCreateCmd) Usage() string {
+	return fmt.Sprintf(`%v reverse-replication create -project=PROJECT -dataflow-region=REGION -instance=INSTANCE -database=DATABASE -source-shards-file=PATH -session-file=PATH...
+
+Create the change stream, metadata database and Pub/Sub resources of a
+reverse replication pipeline, and launch its ordering and writer jobs. With
+-interactive, the required fields of the pipeline configuration are prompted
+for, starting from the flags, and checked as they are entered. The
+configuration is written to a file, and the pipeline is only created once
+confirmed. The create flags are:
+`, path.Base(os.Args[0]))
+}
+func (cmd *reverseReplicationCreateCmd) SetFlags(f *flag.FlagSet) {
+	cmd.setFlags(f)
+	f.BoolVar(&cmd.interactive, "interactive", false, "Prompt for the pipeline configuration, and write it to a file before creating the pipeline")
+}
+
+func (cmd *reverseReplicationCreateCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return cmd.run(ctx, cmd.Name(), args, func(ctx context.Context) (interface{}, error) {
+		j, err := cmd.jobData()
+		if err != nil {
+			return nil, err
+		}
+		if cmd.interactive {
+			w := &jobDataWizard{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+			var create bool
+			if j, create, err = w.run(ctx, j); err != nil || !create {
+				return nil, err
+			}
+		}
+		return nil, reverserepl.CreateWorkflow(ctx, j)
+	}, nil)
+}
+
+type reverseReplicationStatusCmd struct {
+	reverseReplicationFlags
+}
+
+// statusOutput is the output of the status subcommand.
+type statusOutput struct {
+	Jobs      []jobStatusOutput `json:"jobs"`
+	Resources []resourceOutput  `json:"resources"`
+	// Only set when the session file is given.
+	SchemaDrift []schemaDriftOutput `json:"schemaDrift,omitempty"`
+}
+
+// schemaDriftOutput is a difference between the schema of a replicated
+// database and the session file in the output of the status subcommand.
+type schemaDriftOutput struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+	Column   string `json:"column,omitempty"`
+	Kind     string `json:"kind"`
+	Detail   string `json:"detail"`
+}
+
+// resourceOutput is an existing resource of the pipeline in the output of the
+// status subcommand.
+type resourceOutput struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+func (cmd *reverseReplicationStatusCmd) Name() string { return "status" }
+func (cmd *reverseReplicationStatusCmd) Synopsis() string {
+	return "show the state of the Dataflow jobs and resources of a reverse replication pipeline"
+}
+func (cmd *reverseReplicationStatusCmd) Usage() string {
+	return fmt.Sprintf(`%v reverse-replication status -project=PROJECT -dataflow-region=REGION -instance=INSTANCE -database=DATABASE -source-shards-file=PATH...
+
+Show the ordering, writer and reprocess jobs launched for a reverse
+replication pipeline and their state, and the resources created for the
+pipeline which still exist. With -session-file, the differences between the
+schema of the replicated databases and the session file are shown as well.
+The status flags are:
+`, path.Base(os.Args[0]))
+}
+func (cmd *reverseReplicationStatusCmd) SetFlags(f *flag.FlagSet) { cmd.setFlags(f) }
+
+func (cmd *reverseReplicationStatusCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return cmd.run(ctx, cmd.Name(), args, func(ctx context.Context) (interface{}, error) {
+		j, err := cmd.jobData()
+		if err != nil {
+			return nil, err
+		}
+		statuses, err := reverserepl.GetJobStatus(ctx, j)
+		if err != nil {
+			return nil, err
+		}
+		resources, err := reverserepl.GetResources(ctx, j)
+		if err != nil {
+			return nil, err
+		}
+		out := statusOutput{Jobs: toJobStatusOutputs(statuses), Resources: []resourceOutput{}}
+		for _, r := range resources {
+			out.Resources = append(out.Resources, resourceOutput{Kind: r.Kind, Name: r.Name})
+		}
+		if j.SessionFilePath != "" {
+			drifts, err := reverserepl.DetectSchemaDrift(ctx, j)
+			if err != nil {
+				return nil, err
+			}
+			for _, d := range drifts {
+				out.SchemaDrift = append(out.SchemaDrift, schemaDriftOutput{Database: d.Database, Table: d.Table, Column: d.Column, Kind: d.Kind, Detail: d.Detail})
+			}
+		}
+		return out, nil
+	}, func(w *tabwriter.Writer, out interface{}) {
+		status := out.(statusOutput)
+		writeJobStatusTable(w, status.Jobs)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "RESOURCE\tNAME")
+		for _, r := range status.Resources {
+			fmt.Fprintf(w, "%s\t%s\n", r.Kind, r.Name)
+		}
+		if len(status.SchemaDrift) > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "SCHEMA DRIFT\tDATABASE\tTABLE\tCOLUMN\tDETAIL")
+			for _, d := range status.SchemaDrift {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.Kind, d.Database, d.Table, d.Column, d.Detail)
+			}
+		}
+	})
+}
+
+type reverseReplicationListCmd struct {
+	reverseReplicationFlags
+	filterTags string
+}
+
+// workflowOutput is a pipeline in the output of the list subcommand.
+type workflowOutput struct {
+	JobNamePrefix string            `json:"jobNamePrefix"`
+	Active        bool              `json:"active"`
+	Tags          []string          `json:"tags"`
+	Jobs          []jobStatusOutput `json:"jobs"`
+}
+
+func (cmd *reverseReplicationListCmd) Name() string { return "list" }
+func (cmd *reverseReplicationListCmd) Synopsis() string {
+	return "list the reverse replication pipelines of a project and region"
+}
+func (cmd *reverseReplicationListCmd) Usage() string {
+	return fmt.Sprintf(`%v reverse-replication list -project=PROJECT -dataflow-region=REGION [-tags=TAGS]
+
+List the reverse replication pipelines with Dataflow jobs in the project and
+region, by job name prefix, with their tags and whether any of their jobs is
+still running. The list flags are:
+`, path.Base(os.Args[0]))
+}
+func (cmd *reverseReplicationListCmd) SetFlags(f *flag.FlagSet) {
+	cmd.projectFlagsOnly = true
+	cmd.setFlags(f)
+	f.StringVar(&cmd.filterTags, "tags", "", "Only list the pipelines with all of these comma separated tags. A tag given as a key e.g., env, matches the tags with that key whatever their value")
+}
+
+func (cmd *reverseReplicationListCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return cmd.run(ctx, cmd.Name(), args, func(ctx context.Context) (interface{}, error) {
+		var filterTags []string
+		if cmd.filterTags != "" {
+			filterTags = strings.Split(cmd.filterTags, ",")
+		}
+		summaries, err := reverserepl.ListWorkflows(ctx, cmd.project, cmd.dataflowRegion, filterTags...)
+		if err != nil {
+			return nil, err
+		}
+		out := []workflowOutput{}
+		for _, s := range summaries {
+			out = append(out, workflowOutput{JobNamePrefix: s.JobNamePrefix, Active: s.Active, Tags: s.Tags, Jobs: toJobStatusOutputs(s.Jobs)})
+		}
+		return out, nil
+	}, func(w *tabwriter.Writer, out interface{}) {
+		fmt.Fprintln(w, "JOB NAME PREFIX\tACTIVE\tJOBS\tTAGS")
+		for _, wf := range out.([]workflowOutput) {
+			fmt.Fprintf(w, "%s\t%t\t%d\t%s\n", wf.JobNamePrefix, wf.Active, len(wf.Jobs), strings.Join(wf.Tags, ","))
+		}
+	})
+}
+
+type reverseReplicationDeleteCmd struct {
+	reverseReplicationFlags
+}
+
+func (cmd *reverseReplicationDeleteCmd) Name() string { return "delete" }
+func (cmd *reverseReplicationDeleteCmd) Synopsis() string {
+	return "cancel the jobs of a reverse replication pipeline and delete its resources"
+}
+func (cmd *reverseReplicationDeleteCmd) Usage() string {
+	return fmt.Sprintf(`%v reverse-replication delete -project=PROJECT -dataflow-region=REGION -instance=INSTANCE -database=DATABASE -source-shards-file=PATH...
+
+Cancel the running Dataflow jobs of a reverse replication pipeline, then
+delete its change stream, metadata and Pub/Sub resources. The metadata
+database, topic and subscriptions are kept while other pipelines use them.
+The delete flags are:
+`, path.Base(os.Args[0]))
+}
+func (cmd *reverseReplicationDeleteCmd) SetFlags(f *flag.FlagSet) { cmd.setFlags(f) }
+
+func (cmd *reverseReplicationDeleteCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return cmd.run(ctx, cmd.Name(), args, func(ctx context.Context) (interface{}, error) {
+		j, err := cmd.jobData()
+		if err != nil {
+			return nil, err
+		}
+		return nil, reverserepl.DeleteWorkflow(ctx, j)
+	}, nil)
+}
+
+type reverseReplicationExportCmd struct {
+	reverseReplicationFlags
+	outFile string
+}
+
+// exportOutput is the result of the export subcommand.
+type exportOutput struct {
+	ConfigFile string   `json:"configFile"`
+	EnvVars    []string `json:"envVars"`
+}
+
+func (cmd *reverseReplicationExportCmd) Name() string { return "export" }
+func (cmd *reverseReplicationExportCmd) Synopsis() string {
+	return "write the configuration a reverse replication pipeline was created with to a file"
+}
+func (cmd *reverseReplicationExportCmd) Usage() string {
+	return fmt.Sprintf(`%v reverse-replication export -project=PROJECT -dataflow-region=REGION -instance=INSTANCE -database=DATABASE -job-name-prefix=PREFIX [-out=FILE]
+
+Write the configuration the pipeline was last created with, as recorded in its
+metadata database, to a yaml file, or a json file if -out ends with .json.
+All the launcher flags are written, including the defaults resolved at
+creation, so that create -config=FILE recreates the same pipeline, e.g. in
+another project after editing the file. Values read from environment
+variables are written as the references to the variables, which must be set
+when the file is used. The export flags are:
+`, path.Base(os.Args[0]))
+}
+func (cmd *reverseReplicationExportCmd) SetFlags(f *flag.FlagSet) {
+	cmd.setFlags(f)
+	f.StringVar(&cmd.outFile, "out", "", "File the configuration is written to, defaults to <job-name-prefix>.yaml")
+}
+
+func (cmd *reverseReplicationExportCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return cmd.run(ctx, cmd.Name(), args, func(ctx context.Context) (interface{}, error) {
+		j, err := cmd.jobData()
+		if err != nil {
+			return nil, err
+		}
+		exported, err := reverserepl.ExportJob(ctx, j)
+		if err != nil {
+			return nil, err
+		}
+		outFile := cmd.outFile
+		if outFile == "" {
+			outFile = exported.JobNamePrefix + ".yaml"
+		}
+		refs, err := reverserepl.WriteJobData(outFile, exported)
+		if err != nil {
+			return nil, err
+		}
+		return exportOutput{ConfigFile: outFile, EnvVars: append([]string{}, refs...)}, nil
+	}, func(w *tabwriter.Writer, out interface{}) {
+		e := out.(exportOutput)
+		fmt.Fprintf(w, "Wrote the pipeline configuration to file '%s'.\n", e.ConfigFile)
+		if len(e.EnvVars) > 0 {
+			fmt.Fprintf(w, "Set %s before creating a pipeline from it.\n", strings.Join(e.EnvVars, ", "))
+		}
+	})
+}
+
+type reverseReplicationCloneCmd struct {
+	reverseReplicationFlags
+	overridesFile string
+	overrides     launcherFlags
+}
+
+// cloneOutput is the result of the clone subcommand.
+type cloneOutput struct {
+	JobNamePrefix string `json:"jobNamePrefix"`
+}
+
+func (cmd *reverseReplicationCloneCmd) Name() string { return "clone" }
+func (cmd *reverseReplicationCloneCmd) Synopsis() string {
+	return "create a reverse replication pipeline with the configuration of another one"
+}
+func (cmd *reverseReplicationCloneCmd) Usage() string {
+	return fmt.Sprintf(`%v reverse-replication clone -project=PROJECT -dataflow-region=REGION -instance=INSTANCE -database=DATABASE -job-name-prefix=PREFIX [-overrides=FILE] [-set=NAME=VALUE...]
+
+Create a pipeline with the configuration the pipeline identified by the flags
+was last created with, as written by export, changed by the -overrides file
+and the -set flags, e.g. -set=dbName=proddb -set=jobNamePrefix=prod to
+promote a pipeline tested in staging. Within the same project, the clone must
+have its own jobNamePrefix and pubSubDataTopicId. The environment variables
+holding the secrets of the configuration must be set. The clone flags are:
+`, path.Base(os.Args[0]))
+}
+func (cmd *reverseReplicationCloneCmd) SetFlags(f *flag.FlagSet) {
+	cmd.setFlags(f)
+	cmd.overrides = make(launcherFlags)
+	f.StringVar(&cmd.overridesFile, "overrides", "", "Json or yaml file with the configuration values of the clone which differ from the cloned pipeline")
+	f.Var(cmd.overrides, "set", "Launcher flag of the clone as name=value e.g., dbName=proddb, taking precedence over -overrides, can be repeated")
+}
+
+func (cmd *reverseReplicationCloneCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return cmd.run(ctx, cmd.Name(), args, func(ctx context.Context) (interface{}, error) {
+		j, err := cmd.jobData()
+		if err != nil {
+			return nil, err
+		}
+		var overrides reverserepl.JobData
+		if cmd.overridesFile != "" {
+			if overrides, err = reverserepl.ReadJobData(cmd.overridesFile); err != nil {
+				return nil, err
+			}
+		}
+		if overrides.Flags == nil {
+			overrides.Flags = make(map[string]string)
+		}
+		for name, value := range cmd.overrides {
+			overrides.Flags[name] = value
+		}
+		clone, err := reverserepl.CloneWorkflow(ctx, j, overrides)
+		if err != nil {
+			return nil, err
+		}
+		return cloneOutput{JobNamePrefix: clone.JobNamePrefix}, nil
+	}, func(w *tabwriter.Writer, out interface{}) {
+		fmt.Fprintf(w, "Created pipeline %s.\n", out.(cloneOutput).JobNamePrefix)
+	})
+}
+
+type reverseReplicationWatchCmd struct {
+	reverseReplicationFlags
+	interval    time.Duration
+	maxRestarts int
+}
+
+// restartOutput is a job restarted by the watch subcommand.
+type restartOutput struct {
+	JobName     string    `json:"jobName"`
+	FailedJobId string    `json:"failedJobId"`
+	RestartedAt time.Time `json:"restartedAt"`
+}
+
+func (cmd *reverseReplicationWatchCmd) Name() string { return "watch" }
+func (cmd *reverseReplicationWatchCmd) Synopsis() string {
+	return "restart the failed ordering and writer jobs of a reverse replication pipeline"
+}
+func (cmd *reverseReplicationWatchCmd) Usage() string {
+	return fmt.Sprintf(`%v reverse-replication watch -project=PROJECT -dataflow-region=REGION -instance=INSTANCE -database=DATABASE -source-shards-file=PATH -session-file=PATH [-interval=DURATION] [-max-restarts=N]
+
+Check the ordering and writer jobs of the pipeline every -interval until
+interrupted, and relaunch the jobs which failed. The ordering jobs are
+relaunched in orderingRunMode, as with the -relaunchOrdering launcher flag,
+so pass a resume mode, e.g. -launcher-flag=orderingRunMode=resumeFailed.
+Every restart is recorded in the ReverseReplicationRestarts table of the
+metadata database. The watch fails once more than -max-restarts jobs failed.
+The watch flags are:
+`, path.Base(os.Args[0]))
+}
+func (cmd *reverseReplicationWatchCmd) SetFlags(f *flag.FlagSet) {
+	cmd.setFlags(f)
+	f.DurationVar(&cmd.interval, "interval", 5*time.Minute, "Time between two checks of the jobs, defaults to 5m")
+	f.IntVar(&cmd.maxRestarts, "max-restarts", 3, "Number of failed jobs restarted before the watch fails, defaults to 3")
+}
+
+func (cmd *reverseReplicationWatchCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return cmd.run(ctx, cmd.Name(), args, func(ctx context.Context) (interface{}, error) {
+		j, err := cmd.jobData()
+		if err != nil {
+			return nil, err
+		}
+		if cmd.interval <= 0 || cmd.maxRestarts < 0 {
+			return nil, fmt.Errorf("please specify a positive interval and a non negative max-restarts")
+		}
+		restarts := []restartOutput{}
+		err = reverserepl.WatchWorkflow(ctx, j, reverserepl.WatchOptions{Interval: cmd.interval, MaxRestarts: cmd.maxRestarts}, func(r reverserepl.JobRestart) {
+			fmt.Printf("Restarted job %s, whose job %s failed\n", r.JobName, r.FailedJobId)
+			restarts = append(restarts, restartOutput{JobName: r.JobName, FailedJobId: r.FailedJobId, RestartedAt: r.RestartedAt})
+		})
+		if err != nil {
+			return nil, err
+		}
+		return restarts, nil
+	}, func(w *tabwriter.Writer, out interface{}) {
+		fmt.Fprintln(w, "JOB\tFAILED JOB ID\tRESTARTED AT")
+		for _, r := range out.([]restartOutput) {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", r.JobName, r.FailedJobId, r.RestartedAt.Format(time.RFC3339))
+		}
+	})
+}
+
+// manualResources collects the repeated flags naming resources of a kind
+// created by hand.
+type manualResources struct {
+	kind      string
+	resources *[]reverserepl.ManualResource
+}
+
+func (m manualResources) String() string {
+	if m.resources == nil {
+		return ""
+	}
+	var names []string
+	for _, r := range *m.resources {
+		if r.Kind == m.kind {
+			names = append(names, r.Name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func (m manualResources) Set(s string) error {
+	r := reverserepl.ManualResource{Kind: m.kind, Name: s}
+	if m.kind == reverserepl.ADOPTED_CHANGE_STREAM {
+		if db, name, ok := strings.Cut(s, "/"); ok {
+			r.Database, r.Name = db, name
+		}
+	}
+	if r.Name == "" {
+		return fmt.Errorf("expected a resource name, got %q", s)
+	}
+	*m.resources = append(*m.resources, r)
+	return nil
+}
+
+type reverseReplicationAdoptCmd struct {
+	reverseReplicationFlags
+	resources []reverserepl.ManualResource
+}
+
+// adoptOutput is a resource adopted by the adopt subcommand.
+type adoptOutput struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Database string `json:"database,omitempty"`
+}
+
+func (cmd *reverseReplicationAdoptCmd) Name() string { return "adopt" }
+func (cmd *reverseReplicationAdoptCmd) Synopsis() string {
+	return "register resources created by hand as resources of a reverse replication pipeline"
+}
+func (cmd *reverseReplicationAdoptCmd) Usage() string {
+	return fmt.Sprintf(`%v reverse-replication adopt -project=PROJECT -dataflow-region=REGION -instance=INSTANCE -database=DATABASE -source-shards-file=PATH [-adopt-change-stream=[DATABASE/]NAME...] [-adopt-dataflow-job=NAME...]
+
+Register change streams and Dataflow jobs created by hand, e.g. while building
+a pipeline manually, in the metadata database of the pipeline, creating it if
+needed. The status, delete and metrics subcommands then report, cancel and
+delete them along with the resources created by the launcher. Every resource
+must exist. The adopt flags are:
+`, path.Base(os.Args[0]))
+}
+func (cmd *reverseReplicationAdoptCmd) SetFlags(f *flag.FlagSet) {
+	cmd.setFlags(f)
+	f.Var(manualResources{kind: reverserepl.ADOPTED_CHANGE_STREAM, resources: &cmd.resources}, "adopt-change-stream", "Change stream created by hand, as [DATABASE/]NAME with DATABASE defaulting to -database, can be repeated")
+	f.Var(manualResources{kind: reverserepl.ADOPTED_DATAFLOW_JOB, resources: &cmd.resources}, "adopt-dataflow-job", "Name of a Dataflow job launched by hand in the project and region, can be repeated")
+}
+
+func (cmd *reverseReplicationAdoptCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return cmd.run(ctx, cmd.Name(), args, func(ctx context.Context) (interface{}, error) {
+		j, err := cmd.jobData()
+		if err != nil {
+			return nil, err
+		}
+		if len(cmd.resources) == 0 {
+			return nil, fmt.Errorf("please specify the resources to adopt with -adopt-change-stream or -adopt-dataflow-job")
+		}
+		if err := reverserepl.AdoptResources(ctx, j, cmd.resources); err != nil {
+			return nil, err
+		}
+		out := []adoptOutput{}
+		for _, r := range cmd.resources {
+			out = append(out, adoptOutput{Kind: r.Kind, Name: r.Name, Database: r.Database})
+		}
+		return out, nil
+	}, func(w *tabwriter.Writer, out interface{}) {
+		fmt.Fprintln(w, "ADOPTED\tNAME\tDATABASE")
+		for _, r := range out.([]adoptOutput) {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", r.Kind, r.Name, r.Database)
+		}
+	})
+}
+
+type reverseReplicationPauseCmd struct {
+	reverseReplicationFlags
+}
+
+func (cmd *reverseReplicationPauseCmd) Name() string { return "pause" }
+func (cmd *reverseReplicationPauseCmd) Synopsis() string {
+	return "drain the writer jobs of a reverse replication pipeline"
+}
+func (cmd *reverseReplicationPauseCmd) Usage() string {
+	return fmt.Sprintf(`%v reverse-replication pause -project=PROJECT -dataflow-region=REGION -instance=INSTANCE -database=DATABASE -source-shards-file=PATH...
+
+Drain the writer jobs of a reverse replication pipeline, so that no change is
+applied to the source shards. The ordering jobs keep running, and the changes
+wait in Pub/Sub until the pipeline is resumed. The pause flags are:
+`, path.Base(os.Args[0]))
+}
+func (cmd *reverseReplicationPauseCmd) SetFlags(f *flag.FlagSet) { cmd.setFlags(f) }
+
+func (cmd *reverseReplicationPauseCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return cmd.run(ctx, cmd.Name(), args, func(ctx context.Context) (interface{}, error) {
+		j, err := cmd.jobData()
+		if err != nil {
+			return nil, err
+		}
+		return nil, reverserepl.PauseWorkflow(ctx, j)
+	}, nil)
+}
+
+type reverseReplicationResumeCmd struct {
+	reverseReplicationFlags
+}
+
+func (cmd *reverseReplicationResumeCmd) Name() string { return "resume" }
+func (cmd *reverseReplicationResumeCmd) Synopsis() string {
+	return "relaunch the writer jobs of a paused reverse replication pipeline"
+}
+func (cmd *reverseReplicationResumeCmd) Usage() string {
+	return fmt.Sprintf(`%v reverse-replication resume -project=PROJECT -dataflow-region=REGION -instance=INSTANCE -database=DATABASE -source-shards-file=PATH -session-file=PATH...
+
+Relaunch the writer jobs of a paused reverse replication pipeline. They apply
+the changes which waited in Pub/Sub first. The resume flags are:
+`, path.Base(os.Args[0]))
+}
+func (cmd *reverseReplicationResumeCmd) SetFlags(f *flag.FlagSet) { cmd.setFlags(f) }
+
+func (cmd *reverseReplicationResumeCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return cmd.run(ctx, cmd.Name(), args, func(ctx context.Context) (interface{}, error) {
+		j, err := cmd.jobData()
+		if err != nil {
+			return nil, err
+		}
+		return nil, reverserepl.ResumeWorkflow(ctx, j)
+	}, nil)
+}
+
+type reverseReplicationMetricsCmd struct {
+	reverseReplicationFlags
+}
+
+// metricsOutput is the output of the metrics subcommand.
+type metricsOutput struct {
+	Jobs     []jobStatusOutput `json:"jobs"`
+	Backlogs []backlogOutput   `json:"backlogs"`
+}
+
+// backlogOutput is the number of changes of a shard waiting in Pub/Sub, or
+// nil if Cloud Monitoring reported no value recently.
+type backlogOutput struct {
+	ShardId             string `json:"shardId"`
+	UndeliveredMessages *int64 `json:"undeliveredMessages"`
+}
+
+func (cmd *reverseReplicationMetricsCmd) Name() string { return "metrics" }
+func (cmd *reverseReplicationMetricsCmd) Synopsis() string {
+	return "show the jobs of a reverse replication pipeline and the changes waiting per shard"
+}
+func (cmd *reverseReplicationMetricsCmd) Usage() string {
+	return fmt.Sprintf(`%v reverse-replication metrics -project=PROJECT -dataflow-region=REGION -instance=INSTANCE -database=DATABASE -source-shards-file=PATH...
+
+Show the state of the Dataflow jobs of a reverse replication pipeline, and
+the number of changes of every shard waiting in Pub/Sub to be applied, as
+last reported by Cloud Monitoring. The metrics flags are:
+`, path.Base(os.Args[0]))
+}
+func (cmd *reverseReplicationMetricsCmd) SetFlags(f *flag.FlagSet) { cmd.setFlags(f) }
+
+func (cmd *reverseReplicationMetricsCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return cmd.run(ctx, cmd.Name(), args, func(ctx context.Context) (interface{}, error) {
+		j, err := cmd.jobData()
+		if err != nil {
+			return nil, err
+		}
+		metrics, err := reverserepl.GetMetrics(ctx, j)
+		if err != nil {
+			return nil, err
+		}
+		out := metricsOutput{Jobs: toJobStatusOutputs(metrics.Jobs), Backlogs: []backlogOutput{}}
+		for _, b := range metrics.Backlogs {
+			backlog := backlogOutput{ShardId: b.ShardId}
+			if b.Reported {
+				n := b.UndeliveredMessages
+				backlog.UndeliveredMessages = &n
+			}
+			out.Backlogs = append(out.Backlogs, backlog)
+		}
+		return out, nil
+	}, func(w *tabwriter.Writer, out interface{}) {
+		m := out.(metricsOutput)
+		writeJobStatusTable(w, m.Jobs)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "SHARD\tUNDELIVERED MESSAGES")
+		for _, b := range m.Backlogs {
+			n := "-"
+			if b.UndeliveredMessages != nil {
+				n = fmt.Sprint(*b.UndeliveredMessages)
+			}
+			fmt.Fprintf(w, "%s\t%s\n", b.ShardId, n)
+		}
+	})
+}
+
+type reverseReplicationGenerateSessionCmd struct {
+	reverseReplicationFlags
+	source  string
+	outFile string
+}
+
+// generateSessionOutput is the result of the generate-session subcommand.
+type generateSessionOutput struct {
+	SessionFile string   `json:"sessionFile"`
+	Notes       []string `json:"notes"`
+}
+
+func (cmd *reverseReplicationGenerateSessionCmd) Name() string { return "generate-session" }
+func (cmd *reverseReplicationGenerateSessionCmd) Synopsis() string {
+	return "generate a session file from the schema of a Spanner database not migrated with Spanner migration tool"
+}
+func (cmd *reverseReplicationGenerateSessionCmd) Usage() string {
+	return fmt.Sprintf(`%v reverse-replication generate-session -project=PROJECT -instance=INSTANCE -database=DATABASE -out=FILE
+
+Generate a best effort session file mapping the tables and columns of a
+Spanner database back to source tables and columns of the same name, with the
+closest source types, for databases which were not migrated with Spanner
+migration tool. Review the file, and the listed notes on the mappings, before
+passing it to create as -session-file. The generate-session flags are:
+`, path.Base(os.Args[0]))
+}
+func (cmd *reverseReplicationGenerateSessionCmd) SetFlags(f *flag.FlagSet) {
+	cmd.projectFlagsOnly = true
+	cmd.setFlags(f)
+	f.StringVar(&cmd.instance, "instance", "", "Spanner instance id")
+	f.StringVar(&cmd.database, "database", "", "Spanner database name")
+	f.StringVar(&cmd.source, "source", constants.MYSQL, "Type of the source database (mysql), defaults to mysql")
+	f.StringVar(&cmd.outFile, "out", "", "File the session is written to, defaults to <database>.session.json")
+}
+
+func (cmd *reverseReplicationGenerateSessionCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return cmd.run(ctx, cmd.Name(), args, func(ctx context.Context) (interface{}, error) {
+		if cmd.project == "" || cmd.instance == "" || cmd.database == "" {
+			return nil, fmt.Errorf("please specify the project, instance and database of the Spanner database")
+		}
+		outFile := cmd.outFile
+		if outFile == "" {
+			outFile = cmd.database + ".session.json"
+		}
+		dbURI := fmt.Sprintf("projects/%s/instances/%s/databases/%s", cmd.project, cmd.instance, cmd.database)
+		session, notes, err := reverserepl.GenerateSessionFromSpanner(ctx, dbURI, cmd.source)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(outFile, session, 0644); err != nil {
+			return nil, fmt.Errorf("could not write %s: %v", outFile, err)
+		}
+		out := generateSessionOutput{SessionFile: outFile, Notes: []string{}}
+		out.Notes = append(out.Notes, notes...)
+		return out, nil
+	}, func(w *tabwriter.Writer, out interface{}) {
+		g := out.(generateSessionOutput)
+		fmt.Fprintf(w, "Wrote session to file '%s'. Please review it before using it for reverse replication.\n", g.SessionFile)
+		if len(g.Notes) > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "NOTES")
+			for _, n := range g.Notes {
+				fmt.Fprintln(w, n)
+			}
+		}
+	})
+}
+
+type reverseReplicationGenerateShardsCmd struct {
+	reverseReplicationFlags
+	source        string
+	sourceProfile string
+	outFile       string
+}
+
+// generateShardsOutput is the result of the generate-shards subcommand.
+type generateShardsOutput struct {
+	SourceShardsFile string   `json:"sourceShardsFile"`
+	Notes            []string `json:"notes"`
+}
+
+func (cmd *reverseReplicationGenerateShardsCmd) Name() string { return "generate-shards" }
+func (cmd *reverseReplicationGenerateShardsCmd) Synopsis() string {
+	return "generate the source shards file from the source profile of the forward migration"
+}
+func (cmd *reverseReplicationGenerateShardsCmd) Usage() string {
+	return fmt.Sprintf(`%v reverse-replication generate-shards -source=SOURCE -source-profile=PROFILE -out=FILE
+
+Generate the source shards file of the pipeline from the -source and
+-source-profile used for the forward migration, either a connection profile or
+a sharded config. The connection details of dataflow configs are read from
+their Datastream source connection profiles in -project, which don't return
+passwords. Review the file, and the listed notes, before passing it to create
+as -source-shards-file. The generate-shards flags are:
+`, path.Base(os.Args[0]))
+}
+func (cmd *reverseReplicationGenerateShardsCmd) SetFlags(f *flag.FlagSet) {
+	cmd.projectFlagsOnly = true
+	cmd.setFlags(f)
+	f.StringVar(&cmd.source, "source", constants.MYSQL, "Source of the forward migration (mysql, sqlserver, oracle), defaults to mysql")
+	f.StringVar(&cmd.sourceProfile, "source-profile", "", "Source profile of the forward migration")
+	f.StringVar(&cmd.outFile, "out", "source-shards.json", "File the source shards are written to, defaults to source-shards.json")
+}
+
+func (cmd *reverseReplicationGenerateShardsCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return cmd.run(ctx, cmd.Name(), args, func(ctx context.Context) (interface{}, error) {
+		if cmd.sourceProfile == "" {
+			return nil, fmt.Errorf("please specify the source profile of the forward migration")
+		}
+		sourceProfile, err := profiles.NewSourceProfile(cmd.sourceProfile, cmd.source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source profile: %v", err)
+		}
+		shards, notes, err := reverserepl.GenerateSourceShardsFromProfile(ctx, sourceProfile, cmd.project)
+		if err != nil {
+			return nil, err
+		}
+		// The passwords of the shards are references to environment
+		// variables, the rest of the connection details are still private.
+		if err := os.WriteFile(cmd.outFile, shards, 0600); err != nil {
+			return nil, fmt.Errorf("could not write %s: %v", cmd.outFile, err)
+		}
+		out := generateShardsOutput{SourceShardsFile: cmd.outFile, Notes: []string{}}
+		out.Notes = append(out.Notes, notes...)
+		return out, nil
+	}, func(w *tabwriter.Writer, out interface{}) {
+		g := out.(generateShardsOutput)
+		fmt.Fprintf(w, "Wrote source shards to file '%s'. Please review it before using it for reverse replication.\n", g.SourceShardsFile)
+		if len(g.Notes) > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "NOTES")
+			for _, n := range g.Notes {
+				fmt.Fprintln(w, n)
+			}
+		}
+	})
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/profiles"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/reverse_replication/reverserepl"
+)
+
+const sourceShardsFile = "source-shards.json"
+
+// ReverseReplicationJobData returns the job data of the reverse replication
+// pipeline of a completed forward migration, from its source and target
+// profiles and session file, along with the path of the source shards file
+// written to filePrefix and the notes on the shards to review. Streaming
+// migrations are not supported, as the pipeline should only be created at
+// cutover.
+func ReverseReplicationJobData(ctx context.Context, sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile, dbName, sessionFilePath, filePrefix string) (reverserepl.JobData, []string, error) {
+	if sourceProfile.Ty == profiles.SourceProfileTypeConnection && sourceProfile.Conn.Streaming ||
+		sourceProfile.Ty == profiles.SourceProfileTypeConfig && sourceProfile.Config.ConfigType == constants.DATAFLOW_MIGRATION {
+		return reverserepl.JobData{}, nil, fmt.Errorf("reverse replication is created at cutover of a streaming migration, please run reverse-replication create -interactive then")
+	}
+	shards, notes, err := reverserepl.GenerateSourceShardsFromProfile(ctx, sourceProfile, targetProfile.Conn.Sp.Project)
+	if err != nil {
+		return reverserepl.JobData{}, nil, err
+	}
+	shardsFilePath := filePrefix + sourceShardsFile
+	// The shards file holds the passwords of the source shards.
+	if err := ioutil.WriteFile(shardsFilePath, shards, 0600); err != nil {
+		return reverserepl.JobData{}, nil, fmt.Errorf("can't write %s: %v", shardsFilePath, err)
+	}
+	j := reverserepl.JobData{
+		ProjectId:            targetProfile.Conn.Sp.Project,
+		InstanceId:           targetProfile.Conn.Sp.Instance,
+		DbName:               targetProfile.Conn.Sp.Dbname,
+		SessionFilePath:      sessionFilePath,
+		SourceShardsFilePath: shardsFilePath,
+	}
+	if j.DbName == "" {
+		j.DbName = dbName
+	}
+	if sourceProfile.Ty == profiles.SourceProfileTypeConnection {
+		switch sourceProfile.Conn.Ty {
+		case profiles.SourceProfileConnectionTypeSqlServer:
+			j.SourceType = constants.SQLSERVER
+		case profiles.SourceProfileConnectionTypeOracle:
+			j.SourceType = constants.ORACLE
+			j.Flags = map[string]string{"allowExperimental": "true"}
+		}
+	}
+	return j, notes, nil
+}
+
+// offerReverseReplication offers, once a forward migration completed, to
+// create the reverse replication pipeline replicating the changes made to the
+// migrated database back to the source. The fields of the pipeline
+// configuration are prefilled from the migration and prompted for. Failures
+// are reported without failing the migration, which already completed.
+func offerReverseReplication(ctx context.Context, sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile, dbName, sessionFilePath, filePrefix string, out io.Writer) {
+	fmt.Fprintln(out, "\nSetting up reverse replication from the migrated database to the source.")
+	j, notes, err := ReverseReplicationJobData(ctx, sourceProfile, targetProfile, dbName, sessionFilePath, filePrefix)
+	if err != nil {
+		fmt.Fprintf(out, "Can't prefill the reverse replication configuration: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "Wrote the source shards to %s.\n", j.SourceShardsFilePath)
+	for _, n := range notes {
+		fmt.Fprintf(out, "Note: %s\n", n)
+	}
+	w := &jobDataWizard{in: bufio.NewReader(os.Stdin), out: out}
+	j, create, err := w.run(ctx, j)
+	if err != nil {
+		fmt.Fprintf(out, "Reverse replication not set up: %v\n", err)
+		return
+	}
+	if !create {
+		return
+	}
+	if err := reverserepl.CreateWorkflow(ctx, j); err != nil {
+		fmt.Fprintf(out, "Can't create the reverse replication pipeline: %v\n", err)
+		return
+	}
+	fmt.Fprintln(out, "Reverse replication pipeline created.")
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/reverse_replication/reverserepl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReverseReplicationJobData(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "pipeline.json")
+	assert.Nil(t, os.WriteFile(configFile, []byte(`{
+		"projectId": "my-project",
+		"dataflowRegion": "us-central1",
+		"jobNamePrefix": "orders",
+		"instanceId": "my-instance",
+		"dbName": "orders",
+		"sourceShardsFilePath": "gs://my-bucket/shards.json",
+		"flags": {"writerFanOut": "2"}
+	}`), 0644))
+
+	rf := &reverseReplicationFlags{}
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	rf.setFlags(fs)
+	assert.Nil(t, fs.Parse([]string{
+		"-config", configFile,
+		"-dataflow-region", "europe-west1",
+		"-session-file", "gs://my-bucket/session.json",
+		"-tags", "env=prod,team",
+		"-launcher-flag", "dryRun=true",
+		"-launcher-flag", "-writerFanOut=3",
+	}))
+	j, err := rf.jobData()
+	assert.Nil(t, err)
+	// The flags override the config file.
+	assert.Equal(t, reverserepl.JobData{
+		ProjectId:            "my-project",
+		DataflowRegion:       "europe-west1",
+		JobNamePrefix:        "orders",
+		InstanceId:           "my-instance",
+		DbName:               "orders",
+		SourceShardsFilePath: "gs://my-bucket/shards.json",
+		SessionFilePath:      "gs://my-bucket/session.json",
+		Tags:                 []string{"env=prod", "team"},
+		Flags:                map[string]string{"writerFanOut": "3", "dryRun": "true"},
+	}, j)
+}
+
+func TestLauncherFlags(t *testing.T) {
+	l := make(launcherFlags)
+	assert.Nil(t, l.Set("writerFanOut=2"))
+	assert.Nil(t, l.Set("-filtrationMode=none"))
+	assert.Nil(t, l.Set("startTimestamp="))
+	assert.Equal(t, "filtrationMode=none,startTimestamp=,writerFanOut=2", l.String())
+	assert.NotNil(t, l.Set("writerFanOut"))
+	assert.NotNil(t, l.Set("=2"))
+}
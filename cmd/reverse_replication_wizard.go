@@ -0,0 +1,167 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/reverse_replication/reverserepl"
+)
+
+const DEFAULT_JOB_DATA_FILE = "reverse-replication.json"
+
+// jobDataWizard walks the user through the fields of the job data required
+// to create a reverse replication pipeline, checking every answer against
+// Google Cloud as it is entered.
+type jobDataWizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask prompts for a value until check, if any, accepts the answer. An empty
+// answer takes the default value def.
+func (w *jobDataWizard) ask(question, def string, check func(answer string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(w.out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(w.out, "%s: ", question)
+		}
+		answer, err := w.in.ReadString('\n')
+		if err != nil && (err != io.EOF || answer == "") {
+			return "", fmt.Errorf("no answer to %q: %v", question, err)
+		}
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			answer = def
+		}
+		if answer == "" {
+			fmt.Fprintln(w.out, "  A value is required.")
+			continue
+		}
+		if check != nil {
+			if err := check(answer); err != nil {
+				fmt.Fprintf(w.out, "  %v\n", err)
+				continue
+			}
+		}
+		return answer, nil
+	}
+}
+
+// confirm asks a yes or no question, defaulting to no.
+func (w *jobDataWizard) confirm(question string) (bool, error) {
+	fmt.Fprintf(w.out, "%s (y/N): ", question)
+	answer, err := w.in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// run prompts for the required fields of j, using its values as defaults,
+// writes the resulting job data to a file and returns it, along with whether
+// the user wants the pipeline created now.
+func (w *jobDataWizard) run(ctx context.Context, j reverserepl.JobData) (reverserepl.JobData, bool, error) {
+	var err error
+	if j.ProjectId == "" {
+		// Best effort, the project can still be entered.
+		j.ProjectId, _ = utils.GetProject()
+	}
+	if j.ProjectId, err = w.ask("Google Cloud project", j.ProjectId, nil); err != nil {
+		return j, false, err
+	}
+	if j.DataflowRegion, err = w.ask("Region of the Dataflow jobs", j.DataflowRegion, nil); err != nil {
+		return j, false, err
+	}
+	j.InstanceId, err = w.ask("Spanner instance", j.InstanceId, func(instanceId string) error {
+		info, err := reverserepl.CheckInstance(ctx, j.ProjectId, instanceId)
+		if err == nil {
+			fmt.Fprintf(w.out, "  Instance with %d node(s) (%d processing units), leader region %s.\n", info.NodeCount, info.ProcessingUnits, info.LeaderLocation)
+		}
+		return err
+	})
+	if err != nil {
+		return j, false, err
+	}
+	j.DbName, err = w.ask("Spanner database, or comma separated databases", j.DbName, func(dbName string) error {
+		return reverserepl.CheckDatabases(ctx, j.ProjectId, j.InstanceId, dbName)
+	})
+	if err != nil {
+		return j, false, err
+	}
+	j.SessionFilePath, err = w.ask("Session file (gs:// or local path)", j.SessionFilePath, func(path string) error {
+		dialect, err := reverserepl.CheckSessionFile(ctx, path)
+		if err == nil {
+			fmt.Fprintf(w.out, "  Session file for a %s database.\n", dialect)
+		}
+		return err
+	})
+	if err != nil {
+		return j, false, err
+	}
+	j.SourceShardsFilePath, err = w.ask("Source shards file (gs:// or local path)", j.SourceShardsFilePath, func(path string) error {
+		ids, err := reverserepl.CheckSourceShardsFile(ctx, path)
+		if err == nil {
+			fmt.Fprintf(w.out, "  Found %d shard(s): %s\n", len(ids), strings.Join(ids, ", "))
+		}
+		return err
+	})
+	if err != nil {
+		return j, false, err
+	}
+	if !strings.HasPrefix(j.SessionFilePath, "gs://") || !strings.HasPrefix(j.SourceShardsFilePath, "gs://") {
+		if j.Flags == nil {
+			j.Flags = make(map[string]string)
+		}
+		j.Flags["artifactsPath"], err = w.ask("GCS path the local files are uploaded to", j.Flags["artifactsPath"], func(path string) error {
+			if !strings.HasPrefix(path, "gs://") {
+				return fmt.Errorf("the path must start with gs://")
+			}
+			return nil
+		})
+		if err != nil {
+			return j, false, err
+		}
+	}
+	if j.JobNamePrefix == "" {
+		j.JobNamePrefix = "reverse-rep"
+	}
+	if j.JobNamePrefix, err = w.ask("Job name prefix of the Dataflow jobs", j.JobNamePrefix, nil); err != nil {
+		return j, false, err
+	}
+
+	var secretVars []string
+	configFile, err := w.ask("File to write the configuration to", DEFAULT_JOB_DATA_FILE, func(path string) error {
+		vars, err := reverserepl.WriteJobData(path, j)
+		secretVars = vars
+		return err
+	})
+	if err != nil {
+		return j, false, err
+	}
+	fmt.Fprintf(w.out, "Wrote the configuration to %s. It can be used later with -config=%s.\n", configFile, configFile)
+	if len(secretVars) > 0 {
+		fmt.Fprintf(w.out, "The configuration reads %s from the environment, set them when using it.\n", strings.Join(secretVars, ", "))
+	}
+	create, err := w.confirm("Create the pipeline now?")
+	return j, create, err
+}
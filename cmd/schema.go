@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/tracing"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/conversion"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
@@ -42,6 +43,8 @@ type SchemaCmd struct {
 	targetProfile string
 	filePrefix    string // TODO: move filePrefix to global flags
 	logLevel      string
+	logFormat     string
+	logFile       string
 	dryRun        bool
 	validate      bool
 }
@@ -75,6 +78,8 @@ func (cmd *SchemaCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&cmd.targetProfile, "target-profile", "", "Flag for specifying connection profile for target database e.g., \"dialect=postgresql\"")
 	f.StringVar(&cmd.filePrefix, "prefix", "", "File prefix for generated files")
 	f.StringVar(&cmd.logLevel, "log-level", "DEBUG", "Configure the logging level for the command (INFO, DEBUG), defaults to DEBUG")
+	f.StringVar(&cmd.logFormat, "log-format", logger.CONSOLE_ENCODING, "Encoding of the logs written to the console (console, json), defaults to console")
+	f.StringVar(&cmd.logFile, "log-file", logger.LOG_FILE_NAME, "File the logs are appended to, defaults to spanner-migration-tool.log")
 	f.BoolVar(&cmd.dryRun, "dry-run", false, "Flag for generating DDL and schema conversion report without creating a spanner database")
 	f.BoolVar(&cmd.validate, "validate", false, "Flag for validating if all the required input parameters are present")
 }
@@ -88,12 +93,17 @@ func (cmd *SchemaCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interfa
 			logger.Log.Fatal("FATAL error", zap.Error(err))
 		}
 	}()
-	err = logger.InitializeLogger(cmd.logLevel)
+	err = logger.InitializeLoggerWithConfig(logger.Config{Level: cmd.logLevel, Encoding: cmd.logFormat, OutputPath: cmd.logFile})
 	if err != nil {
 		fmt.Println("Error initialising logger, did you specify a valid log-level? [DEBUG, INFO, WARN, ERROR, FATAL]", err)
 		return subcommands.ExitFailure
 	}
 	defer logger.Log.Sync()
+	flushTraces, err := tracing.Init(ctx)
+	if err != nil {
+		return subcommands.ExitFailure
+	}
+	defer flushTraces()
 	// validate and parse source-profile, target-profile and source
 	sourceProfile, targetProfile, ioHelper, dbName, err := PrepareMigrationPrerequisites(cmd.sourceProfile, cmd.targetProfile, cmd.source)
 	if err != nil {
@@ -122,6 +132,7 @@ func (cmd *SchemaCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interfa
 
 	// Populate migration request id and migration type in conv object.
 	conv.Audit.MigrationRequestId = "SMT-" + uuid.New().String()
+	ctx = logger.WithMigration(ctx, conv.Audit.MigrationRequestId, cmd.Name())
 	conv.Audit.MigrationType = migration.MigrationData_SCHEMA_ONLY.Enum()
 	conv.Audit.SkipMetricsPopulation = os.Getenv("SKIP_METRICS_POPULATION") == "true"
 	if !cmd.dryRun {
@@ -21,11 +21,15 @@ import (
 
 	sp "cloud.google.com/go/spanner"
 	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/metrics"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/tracing"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/conversion"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/profiles"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/writer"
+	"github.com/google/subcommands"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
@@ -66,7 +70,8 @@ func CreateDatabaseClient(ctx context.Context, targetProfile profiles.TargetProf
 	return adminClient, client, dbURI, nil
 }
 
-// PrepareMigrationPrerequisites creates source and target profiles, opens a new IOStream and generates the database name.
+// PrepareMigrationPrerequisites creates source and target profiles, checks that the
+// source supports the requested migration, opens a new IOStream and generates the database name.
 func PrepareMigrationPrerequisites(sourceProfileString, targetProfileString, source string) (profiles.SourceProfile, profiles.TargetProfile, utils.IOStreams, string, error) {
 	targetProfile, err := profiles.NewTargetProfile(targetProfileString)
 	if err != nil {
@@ -81,6 +86,9 @@ func PrepareMigrationPrerequisites(sourceProfileString, targetProfileString, sou
 	if err != nil {
 		return profiles.SourceProfile{}, targetProfile, utils.IOStreams{}, "", err
 	}
+	if err = conversion.ValidateSourceCapabilities(sourceProfile); err != nil {
+		return profiles.SourceProfile{}, targetProfile, utils.IOStreams{}, "", err
+	}
 
 	dumpFilePath := ""
 	if sourceProfile.Ty == profiles.SourceProfileTypeFile && (sourceProfile.File.Format == "" || sourceProfile.File.Format == "dump") {
@@ -105,10 +113,17 @@ func MigrateDatabase(ctx context.Context, targetProfile profiles.TargetProfile,
 		bw  *writer.BatchWriter
 		err error
 	)
+	ctx, span := tracing.StartSpan(ctx, "MigrateDatabase", attribute.String("migrationRequestId", conv.Audit.MigrationRequestId))
+	command := migrationCommand(cmd)
+	metrics.JobsCreated.WithLabelValues(command).Inc()
 	defer func() {
-		if err != nil && migrationError != nil {
-			*migrationError = err
+		if err != nil {
+			metrics.JobsFailed.WithLabelValues(command).Inc()
+			if migrationError != nil {
+				*migrationError = err
+			}
 		}
+		tracing.EndSpan(span, err)
 	}()
 	adminClient, client, dbURI, err := CreateDatabaseClient(ctx, targetProfile, sourceProfile.Driver, dbName, *ioHelper)
 	if err != nil {
@@ -117,6 +132,14 @@ func MigrateDatabase(ctx context.Context, targetProfile profiles.TargetProfile,
 	}
 	defer adminClient.Close()
 	defer client.Close()
+	if _, isSchemaCmd := cmd.(*SchemaCmd); !isSchemaCmd && conv.Audit.MinProcessingUnits > 0 {
+		var restore func()
+		restore, err = scaleInstanceForDataMigration(ctx, dbURI, conv)
+		if err != nil {
+			return nil, err
+		}
+		defer restore()
+	}
 	switch v := cmd.(type) {
 	case *SchemaCmd:
 		err = migrateSchema(ctx, targetProfile, sourceProfile, ioHelper, conv, dbURI, adminClient)
@@ -132,9 +155,42 @@ func MigrateDatabase(ctx context.Context, targetProfile profiles.TargetProfile,
 	return bw, nil
 }
 
+// migrationCommand returns the name of the subcommand cmd, used to label the
+// metrics of the migration.
+func migrationCommand(cmd interface{}) string {
+	if c, ok := cmd.(subcommands.Command); ok {
+		return c.Name()
+	}
+	return "unknown"
+}
+
+// scaleInstanceForDataMigration raises the processing units of the instance of
+// dbURI to conv.Audit.MinProcessingUnits and returns the function restoring
+// them once the migration is done.
+func scaleInstanceForDataMigration(ctx context.Context, dbURI string, conv *internal.Conv) (func(), error) {
+	instanceClient, err := utils.NewInstanceAdminClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't create instance admin client: %v", utils.AnalyzeError(err, dbURI))
+	}
+	scaler := &conversion.AdminInstanceScaler{Client: instanceClient}
+	if err := conversion.RaiseProcessingUnits(ctx, scaler, conversion.GetInstanceURI(dbURI), conv); err != nil {
+		instanceClient.Close()
+		return nil, err
+	}
+	return func() {
+		defer instanceClient.Close()
+		if err := conversion.RestoreProcessingUnits(context.Background(), scaler, conv); err != nil {
+			fmt.Printf("WARNING: %v\n", err)
+		}
+	}, nil
+}
+
 func migrateSchema(ctx context.Context, targetProfile profiles.TargetProfile, sourceProfile profiles.SourceProfile,
-	ioHelper *utils.IOStreams, conv *internal.Conv, dbURI string, adminClient *database.DatabaseAdminClient) error {
-	err := conversion.CreateOrUpdateDatabase(ctx, adminClient, dbURI, sourceProfile.Driver, conv, ioHelper.Out, sourceProfile.Config.ConfigType)
+	ioHelper *utils.IOStreams, conv *internal.Conv, dbURI string, adminClient *database.DatabaseAdminClient) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "migrateSchema")
+	defer func() { tracing.EndSpan(span, err) }()
+	defer metrics.ObserveStep("migrateSchema", time.Now())
+	err = conversion.CreateOrUpdateDatabase(ctx, adminClient, dbURI, sourceProfile.Driver, conv, ioHelper.Out, sourceProfile.Config.ConfigType)
 	if err != nil {
 		err = fmt.Errorf("can't create/update database: %v", err)
 		return err
@@ -144,11 +200,10 @@ func migrateSchema(ctx context.Context, targetProfile profiles.TargetProfile, so
 }
 
 func migrateData(ctx context.Context, targetProfile profiles.TargetProfile, sourceProfile profiles.SourceProfile,
-	ioHelper *utils.IOStreams, conv *internal.Conv, dbURI string, adminClient *database.DatabaseAdminClient, client *sp.Client, cmd *DataCmd) (*writer.BatchWriter, error) {
-	var (
-		bw  *writer.BatchWriter
-		err error
-	)
+	ioHelper *utils.IOStreams, conv *internal.Conv, dbURI string, adminClient *database.DatabaseAdminClient, client *sp.Client, cmd *DataCmd) (bw *writer.BatchWriter, err error) {
+	ctx, span := tracing.StartSpan(ctx, "migrateData")
+	defer func() { tracing.EndSpan(span, err) }()
+	defer metrics.ObserveStep("migrateData", time.Now())
 	if !sourceProfile.UseTargetSchema() {
 		err = validateExistingDb(ctx, conv.SpDialect, dbURI, adminClient, client, conv)
 		if err != nil {
@@ -172,14 +227,17 @@ func migrateData(ctx context.Context, targetProfile profiles.TargetProfile, sour
 }
 
 func migrateSchemaAndData(ctx context.Context, targetProfile profiles.TargetProfile, sourceProfile profiles.SourceProfile,
-	ioHelper *utils.IOStreams, conv *internal.Conv, dbURI string, adminClient *database.DatabaseAdminClient, client *sp.Client, cmd *SchemaAndDataCmd) (*writer.BatchWriter, error) {
-	err := conversion.CreateOrUpdateDatabase(ctx, adminClient, dbURI, sourceProfile.Driver, conv, ioHelper.Out, sourceProfile.Config.ConfigType)
+	ioHelper *utils.IOStreams, conv *internal.Conv, dbURI string, adminClient *database.DatabaseAdminClient, client *sp.Client, cmd *SchemaAndDataCmd) (bw *writer.BatchWriter, err error) {
+	ctx, span := tracing.StartSpan(ctx, "migrateSchemaAndData")
+	defer func() { tracing.EndSpan(span, err) }()
+	defer metrics.ObserveStep("migrateSchemaAndData", time.Now())
+	err = conversion.CreateOrUpdateDatabase(ctx, adminClient, dbURI, sourceProfile.Driver, conv, ioHelper.Out, sourceProfile.Config.ConfigType)
 	if err != nil {
 		err = fmt.Errorf("can't create/update database: %v", err)
 		return nil, err
 	}
 	conv.Audit.Progress.UpdateProgress("Schema migration complete.", completionPercentage, internal.SchemaMigrationComplete)
-	bw, err := conversion.DataConv(ctx, sourceProfile, targetProfile, ioHelper, client, conv, true, cmd.WriteLimit)
+	bw, err = conversion.DataConv(ctx, sourceProfile, targetProfile, ioHelper, client, conv, true, cmd.WriteLimit)
 	if err != nil {
 		err = fmt.Errorf("can't finish data conversion for db %s: %v", dbURI, err)
 		return nil, err
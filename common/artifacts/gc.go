@@ -0,0 +1,140 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifacts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+)
+
+// AUDIT_LOG_FILE_NAME is the default file the garbage collector appends its
+// audit records to.
+const AUDIT_LOG_FILE_NAME = "spanner-migration-tool-artifacts-audit.log"
+
+// AuditRecord is appended to the audit log, as a line of json, for every
+// expired artifact found by the garbage collector.
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	Project   string    `json:"project"`
+	Object    string    `json:"object"`
+	Kind      Kind      `json:"kind"`
+	Created   time.Time `json:"created"`
+	Retention string    `json:"retention"`
+	DryRun    bool      `json:"dryRun"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// GcResult summarizes a single garbage collection run.
+type GcResult struct {
+	Scanned      int
+	Deleted      int
+	DeletedBytes int64
+	Failed       int
+}
+
+// Collector deletes the artifacts under a Cloud Storage path which are older
+// than the retention period of their kind.
+type Collector struct {
+	Storage  StorageAccessor
+	Project  string
+	Periods  RetentionPeriods
+	AuditLog io.Writer
+	DryRun   bool
+	// Now returns the current time, and can be overridden in tests.
+	Now func() time.Time
+}
+
+// Collect runs one garbage collection over the artifacts under gcsPath. A
+// failure to delete one object does not stop the run; the failures are
+// recorded in the audit log and reported in the returned error.
+func (c Collector) Collect(ctx context.Context, gcsPath string) (GcResult, error) {
+	var res GcResult
+	// The path is parsed here rather than with utils.ParseGCSFilePath, as
+	// utils prepares the buckets through this package.
+	if !strings.HasSuffix(gcsPath, "/") {
+		gcsPath += "/"
+	}
+	u, err := url.Parse(gcsPath)
+	if err != nil || u.Scheme != constants.GCS_SCHEME {
+		return res, fmt.Errorf("not a valid GCS path: %s, should start with 'gs'", gcsPath)
+	}
+	bucket, prefix := u.Host, strings.TrimPrefix(u.Path, "/")
+	objects, err := c.Storage.ListObjects(ctx, bucket, prefix)
+	if err != nil {
+		return res, err
+	}
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	for _, o := range objects {
+		res.Scanned++
+		kind, ok := Classify(o.Name)
+		if !ok {
+			continue
+		}
+		retention := c.Periods[kind]
+		if retention == 0 || now().Sub(o.Created) < time.Duration(retention) {
+			continue
+		}
+		rec := AuditRecord{
+			Time:      now(),
+			Project:   c.Project,
+			Object:    fmt.Sprintf("gs://%s/%s", o.Bucket, o.Name),
+			Kind:      kind,
+			Created:   o.Created,
+			Retention: time.Duration(retention).String(),
+			DryRun:    c.DryRun,
+		}
+		if !c.DryRun {
+			if err := c.Storage.DeleteObject(ctx, o.Bucket, o.Name); err != nil {
+				rec.Error = err.Error()
+				res.Failed++
+			}
+		}
+		if rec.Error == "" {
+			res.Deleted++
+			res.DeletedBytes += o.Size
+		}
+		if err := c.audit(rec); err != nil {
+			return res, err
+		}
+	}
+	if res.Failed > 0 {
+		return res, fmt.Errorf("could not delete %d expired artifact(s) under %s, see the audit log for details", res.Failed, gcsPath)
+	}
+	return res, nil
+}
+
+func (c Collector) audit(rec AuditRecord) error {
+	if c.AuditLog == nil {
+		return nil
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := c.AuditLog.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("could not write to the audit log: %v", err)
+	}
+	return nil
+}
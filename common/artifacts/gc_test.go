@@ -0,0 +1,168 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStorage struct {
+	objects   []Object
+	deleted   []string
+	failOn    string
+	listedFor string
+}
+
+func (f *fakeStorage) ListObjects(ctx context.Context, bucket, prefix string) ([]Object, error) {
+	f.listedFor = bucket + "/" + prefix
+	return f.objects, nil
+}
+
+func (f *fakeStorage) DeleteObject(ctx context.Context, bucket, name string) error {
+	if name == f.failOn {
+		return fmt.Errorf("permission denied")
+	}
+	f.deleted = append(f.deleted, name)
+	return nil
+}
+
+func (f *fakeStorage) ReadObject(ctx context.Context, bucket, name string) ([]byte, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (f *fakeStorage) CopyObject(ctx context.Context, srcBucket, srcName, dstBucket, dstName string) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (f *fakeStorage) UploadObject(ctx context.Context, bucket, name string, r io.Reader) (int64, error) {
+	return 0, fmt.Errorf("not implemented")
+}
+
+func (f *fakeStorage) GenerateSignedURL(bucket, name string, ttl time.Duration) (string, error) {
+	return "", fmt.Errorf("not implemented")
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Kind
+		ok       bool
+	}{
+		{"tmp/session.json", Session, true},
+		{"reports/mydb.session.json", Session, true},
+		{"tmp/transformationContext.json", Config, true},
+		{"reports/mydb.report.txt", Report, true},
+		{"reports/mydb.structured_report.json", Report, true},
+		{"reports/mydb.dropped.txt", Report, true},
+		{"data/dlq/severe/2023/01/01/file.json", Dlq, true},
+		{"dlq/retry/file.json", Dlq, true},
+		{"data/2023/01/01/file.json", "", false},
+	}
+	for _, tc := range tests {
+		kind, ok := Classify(tc.name)
+		assert.Equal(t, tc.ok, ok, tc.name)
+		assert.Equal(t, tc.expected, kind, tc.name)
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	r, err := ParseRetention("30d")
+	assert.Nil(t, err)
+	assert.Equal(t, Retention(30*24*time.Hour), r)
+	r, err = ParseRetention("12h")
+	assert.Nil(t, err)
+	assert.Equal(t, Retention(12*time.Hour), r)
+	for _, s := range []string{"", "d", "-1d", "ten days", "-3h"} {
+		_, err = ParseRetention(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestReadPolicy(t *testing.T) {
+	f, err := ioutil.TempFile("", "policy")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	f.WriteString(`{"default": {"session": "30d", "dlq": "14d"}, "projects": {"p1": {"dlq": "7d", "report": "90d"}}}`)
+	f.Close()
+	p, err := ReadPolicy(f.Name())
+	assert.Nil(t, err)
+	day := Retention(24 * time.Hour)
+	assert.Equal(t, RetentionPeriods{Session: 30 * day, Dlq: 7 * day, Report: 90 * day}, p.ForProject("p1"))
+	assert.Equal(t, RetentionPeriods{Session: 30 * day, Dlq: 14 * day}, p.ForProject("p2"))
+
+	assert.Nil(t, ioutil.WriteFile(f.Name(), []byte(`{"default": {"logs": "1d"}}`), 0644))
+	_, err = ReadPolicy(f.Name())
+	assert.NotNil(t, err)
+}
+
+func TestCollect(t *testing.T) {
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	fs := &fakeStorage{objects: []Object{
+		{Bucket: "b", Name: "smt/session.json", Size: 10, Created: now.Add(-40 * day)},
+		{Bucket: "b", Name: "smt/transformationContext.json", Size: 20, Created: now.Add(-40 * day)},
+		{Bucket: "b", Name: "smt/mydb.report.txt", Size: 30, Created: now.Add(-10 * day)},
+		{Bucket: "b", Name: "smt/dlq/severe/file.json", Size: 40, Created: now.Add(-8 * day)},
+		{Bucket: "b", Name: "smt/data/file.json", Size: 50, Created: now.Add(-400 * day)},
+	}}
+	periods := RetentionPeriods{Session: Retention(30 * day), Report: Retention(90 * day), Dlq: Retention(7 * day)}
+	audit := &bytes.Buffer{}
+	c := Collector{Storage: fs, Project: "p1", Periods: periods, AuditLog: audit, Now: func() time.Time { return now }}
+
+	res, err := c.Collect(context.Background(), "gs://b/smt")
+	assert.Nil(t, err)
+	assert.Equal(t, "b/smt/", fs.listedFor)
+	// The config has no retention period and is kept forever.
+	assert.Equal(t, []string{"smt/session.json", "smt/dlq/severe/file.json"}, fs.deleted)
+	assert.Equal(t, GcResult{Scanned: 5, Deleted: 2, DeletedBytes: 50}, res)
+	lines := strings.Split(strings.TrimSpace(audit.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	var rec AuditRecord
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &rec))
+	assert.Equal(t, AuditRecord{Time: now, Project: "p1", Object: "gs://b/smt/session.json", Kind: Session, Created: now.Add(-40 * day), Retention: "720h0m0s"}, rec)
+
+	// A dry run only records what would be deleted.
+	fs.deleted = nil
+	audit.Reset()
+	c.DryRun = true
+	res, err = c.Collect(context.Background(), "gs://b/smt")
+	assert.Nil(t, err)
+	assert.Nil(t, fs.deleted)
+	assert.Equal(t, 2, res.Deleted)
+	assert.Contains(t, audit.String(), `"dryRun":true`)
+
+	// Failed deletes don't stop the run and are recorded.
+	audit.Reset()
+	c.DryRun = false
+	fs.failOn = "smt/session.json"
+	res, err = c.Collect(context.Background(), "gs://b/smt")
+	assert.NotNil(t, err)
+	assert.Equal(t, GcResult{Scanned: 5, Deleted: 1, DeletedBytes: 40, Failed: 1}, res)
+	assert.Contains(t, audit.String(), "permission denied")
+
+	_, err = c.Collect(context.Background(), "/local/dir")
+	assert.NotNil(t, err)
+}
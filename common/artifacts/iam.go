@@ -0,0 +1,100 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifacts
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/iam"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+)
+
+// Roles granted on the buckets holding the artifacts.
+const (
+	ROLE_OBJECT_VIEWER iam.RoleName = "roles/storage.objectViewer"
+	ROLE_OBJECT_ADMIN  iam.RoleName = "roles/storage.objectAdmin"
+)
+
+// Number of times the policy of a bucket is read and written again when it
+// changed concurrently.
+const maxPolicyUpdates = 3
+
+// BucketIamAccessor reads and replaces the IAM policies of buckets.
+type BucketIamAccessor interface {
+	GetBucketIamPolicy(ctx context.Context, bucket string) (*iam.Policy, error)
+	// SetBucketIamPolicy replaces the policy of bucket. It fails if the
+	// policy changed since it was read, as given by its etag.
+	SetBucketIamPolicy(ctx context.Context, bucket string, policy *iam.Policy) error
+}
+
+var _ BucketIamAccessor = GcsStorageAccessor{}
+
+// GetBucketIamPolicy returns the IAM policy of bucket.
+func (g GcsStorageAccessor) GetBucketIamPolicy(ctx context.Context, bucket string) (*iam.Policy, error) {
+	call := gcp.Call{Service: gcp.STORAGE, Method: "GetBucketIamPolicy", Resource: "gs://" + bucket, Idempotent: true}
+	policy, err := gcp.DoWithResult(ctx, call, func(ctx context.Context) (*iam.Policy, error) {
+		return g.Client.Bucket(bucket).IAM().Policy(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get the IAM policy of gs://%s: %v", bucket, err)
+	}
+	return policy, nil
+}
+
+// SetBucketIamPolicy replaces the IAM policy of bucket. The call is retried,
+// as the etag of the policy keeps a retry from overwriting a concurrent
+// change.
+func (g GcsStorageAccessor) SetBucketIamPolicy(ctx context.Context, bucket string, policy *iam.Policy) error {
+	call := gcp.Call{Service: gcp.STORAGE, Method: "SetBucketIamPolicy", Resource: "gs://" + bucket, Idempotent: true}
+	err := gcp.Do(ctx, call, func(ctx context.Context) error {
+		return g.Client.Bucket(bucket).IAM().SetPolicy(ctx, policy)
+	})
+	if err != nil {
+		return fmt.Errorf("could not set the IAM policy of gs://%s: %w", bucket, err)
+	}
+	return nil
+}
+
+// AddBucketMember grants role on bucket to member, e.g.
+// serviceAccount:worker@project.iam.gserviceaccount.com, keeping the other
+// bindings of the bucket. It returns whether the policy was changed, i.e.
+// false if member already had the role.
+func AddBucketMember(ctx context.Context, a BucketIamAccessor, bucket string, role iam.RoleName, member string) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		policy, err := a.GetBucketIamPolicy(ctx, bucket)
+		if err != nil {
+			return false, err
+		}
+		if policy.HasRole(member, role) {
+			return false, nil
+		}
+		policy.Add(member, role)
+		err = a.SetBucketIamPolicy(ctx, bucket, policy)
+		if err == nil {
+			return true, nil
+		}
+		// The policy was changed since it was read, read it again.
+		if attempt < maxPolicyUpdates && gcp.GetCategory(err) == gcp.CATEGORY_FAILED_PRECONDITION {
+			continue
+		}
+		return false, err
+	}
+}
+
+// AddBucketReader lets member read the objects of bucket.
+func AddBucketReader(ctx context.Context, a BucketIamAccessor, bucket, member string) (bool, error) {
+	return AddBucketMember(ctx, a, bucket, ROLE_OBJECT_VIEWER, member)
+}
@@ -0,0 +1,157 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifacts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the type of a generated artifact. Each kind has its own retention
+// period.
+type Kind string
+
+const (
+	Session Kind = "session"
+	Config  Kind = "config"
+	Report  Kind = "report"
+	Dlq     Kind = "dlq"
+)
+
+// Kinds lists every artifact kind covered by the retention policy.
+var Kinds = []Kind{Session, Config, Report, Dlq}
+
+// Classify returns the kind of artifact stored in the object with the given
+// name. Objects which were not generated by the tool are not classified and
+// are never deleted.
+func Classify(name string) (Kind, bool) {
+	base := path.Base(name)
+	switch {
+	case strings.Contains("/"+name, "/dlq/"):
+		return Dlq, true
+	case base == "session.json" || strings.HasSuffix(base, ".session.json"):
+		return Session, true
+	case base == "transformationContext.json":
+		return Config, true
+	case strings.HasSuffix(base, ".report.txt") || strings.HasSuffix(base, ".structured_report.json") ||
+		strings.HasSuffix(base, ".dropped.txt") || strings.HasSuffix(base, ".schema.txt"):
+		return Report, true
+	}
+	return "", false
+}
+
+// Retention is a duration which is read from and written to json as a
+// string like "30d" or "12h". A zero retention keeps artifacts forever.
+type Retention time.Duration
+
+// ParseRetention parses a retention period. In addition to the units
+// accepted by time.ParseDuration, whole days can be given with the d unit.
+func ParseRetention(s string) (Retention, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid retention period %q", s)
+		}
+		return Retention(time.Duration(n) * 24 * time.Hour), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid retention period %q", s)
+	}
+	return Retention(d), nil
+}
+
+// UnmarshalJSON parses a retention period string.
+func (r *Retention) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := ParseRetention(s)
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+// RetentionPeriods holds the retention period of each artifact kind.
+type RetentionPeriods map[Kind]Retention
+
+// Policy is the retention policy read from the policy file. Default applies
+// to every project and is overridden per kind by the entry for the project
+// in Projects.
+//
+// Example:
+//
+//	{
+//	  "default": {"session": "30d", "config": "30d", "report": "90d", "dlq": "14d"},
+//	  "projects": {"my-project": {"dlq": "7d"}}
+//	}
+type Policy struct {
+	Default  RetentionPeriods            `json:"default"`
+	Projects map[string]RetentionPeriods `json:"projects"`
+}
+
+// ReadPolicy reads a retention policy from a json file.
+func ReadPolicy(file string) (Policy, error) {
+	var p Policy
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return p, fmt.Errorf("could not read retention policy %s: %v", file, err)
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return p, fmt.Errorf("could not parse retention policy %s: %v", file, err)
+	}
+	for k := range p.Default {
+		if !isKind(k) {
+			return p, fmt.Errorf("unknown artifact kind %q in retention policy, expected one of %v", k, Kinds)
+		}
+	}
+	for project, periods := range p.Projects {
+		for k := range periods {
+			if !isKind(k) {
+				return p, fmt.Errorf("unknown artifact kind %q for project %s in retention policy, expected one of %v", k, project, Kinds)
+			}
+		}
+	}
+	return p, nil
+}
+
+// ForProject returns the retention periods which apply to project.
+func (p Policy) ForProject(project string) RetentionPeriods {
+	periods := make(RetentionPeriods)
+	for k, r := range p.Default {
+		periods[k] = r
+	}
+	for k, r := range p.Projects[project] {
+		periods[k] = r
+	}
+	return periods
+}
+
+func isKind(k Kind) bool {
+	for _, kind := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
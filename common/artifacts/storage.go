@@ -0,0 +1,217 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package artifacts implements the retention policy for the artifacts that
+// the tool generates in Cloud Storage, such as staged session files, tuning
+// configs, reports and dead letter queue samples. Its storage accessor also
+// copies and uploads artifacts, including multi-GB ones.
+package artifacts
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"google.golang.org/api/iterator"
+)
+
+// Object describes a stored artifact.
+type Object struct {
+	Bucket  string
+	Name    string
+	Size    int64
+	Created time.Time
+}
+
+const (
+	// UPLOAD_CHUNK_SIZE is the size of the chunks of the resumable uploads.
+	// A failed chunk is sent again, rather than the whole object.
+	UPLOAD_CHUNK_SIZE = 16 << 20
+	// How long a chunk is retried for before the upload fails.
+	chunkRetryDeadline = 2 * time.Minute
+	// Deadline of copying or uploading an object, long enough for objects of
+	// several GBs.
+	transferTimeout = 2 * time.Hour
+	// MAX_SIGNED_URL_TTL is the longest validity of a V4 signed URL accepted
+	// by Cloud Storage.
+	MAX_SIGNED_URL_TTL = 7 * 24 * time.Hour
+)
+
+// StorageAccessor lists, reads, copies, uploads, deletes and shares the
+// objects holding generated artifacts.
+type StorageAccessor interface {
+	ListObjects(ctx context.Context, bucket, prefix string) ([]Object, error)
+	DeleteObject(ctx context.Context, bucket, name string) error
+	// ReadObject returns the content of a single object. Reading an object
+	// which does not exist fails with an error wrapping
+	// storage.ErrObjectNotExist.
+	ReadObject(ctx context.Context, bucket, name string) ([]byte, error)
+	// CopyObject copies an object, possibly to another bucket, overwriting
+	// the destination object if it exists.
+	CopyObject(ctx context.Context, srcBucket, srcName, dstBucket, dstName string) error
+	// UploadObject streams the content of r to an object and returns the
+	// number of bytes written.
+	UploadObject(ctx context.Context, bucket, name string, r io.Reader) (int64, error)
+	// GenerateSignedURL returns a URL through which anyone holding it can
+	// download an object for ttl, without credentials.
+	GenerateSignedURL(bucket, name string, ttl time.Duration) (string, error)
+}
+
+// UploadFile uploads the local file at path to an object, without reading
+// it in memory.
+func UploadFile(ctx context.Context, s StorageAccessor, path, bucket, name string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("could not open %s: %v", path, err)
+	}
+	defer f.Close()
+	return s.UploadObject(ctx, bucket, name, f)
+}
+
+// GcsStorageAccessor implements StorageAccessor for Cloud Storage.
+type GcsStorageAccessor struct {
+	Client *storage.Client
+}
+
+// ListObjects returns all objects in bucket whose name starts with prefix.
+func (g GcsStorageAccessor) ListObjects(ctx context.Context, bucket, prefix string) ([]Object, error) {
+	var objects []Object
+	it := g.Client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not list objects in gs://%s/%s: %v", bucket, prefix, err)
+		}
+		objects = append(objects, Object{Bucket: bucket, Name: attrs.Name, Size: attrs.Size, Created: attrs.Created})
+	}
+	return objects, nil
+}
+
+// DeleteObject deletes a single object. Deleting an object which no longer
+// exists is not an error.
+func (g GcsStorageAccessor) DeleteObject(ctx context.Context, bucket, name string) error {
+	err := g.Client.Bucket(bucket).Object(name).Delete(ctx)
+	if err != nil && !gcp.IsNotFound(err) {
+		return fmt.Errorf("could not delete gs://%s/%s: %v", bucket, name, err)
+	}
+	return nil
+}
+
+// ReadObject reads a whole object in memory, so it is meant for small
+// objects such as configuration files.
+func (g GcsStorageAccessor) ReadObject(ctx context.Context, bucket, name string) ([]byte, error) {
+	obj := g.Client.Bucket(bucket).Object(name)
+	call := gcp.Call{Service: gcp.STORAGE, Method: "ReadObject", Resource: fmt.Sprintf("gs://%s/%s", bucket, name), Idempotent: true}
+	data, err := gcp.DoWithResult(ctx, call, func(ctx context.Context) ([]byte, error) {
+		r, err := obj.NewReader(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read gs://%s/%s: %w", bucket, name, err)
+	}
+	return data, nil
+}
+
+// CopyObject copies an object on the server side, so that the data doesn't go
+// through the tool. Large objects, or objects copied across locations, take
+// several rewrite calls, which the copier chains until the copy completes.
+// The checksum of the copy is checked against the one of the source object.
+func (g GcsStorageAccessor) CopyObject(ctx context.Context, srcBucket, srcName, dstBucket, dstName string) error {
+	src := g.Client.Bucket(srcBucket).Object(srcName)
+	dst := g.Client.Bucket(dstBucket).Object(dstName)
+	call := gcp.Call{Service: gcp.STORAGE, Method: "CopyObject", Resource: fmt.Sprintf("gs://%s/%s", srcBucket, srcName), Idempotent: true, Timeout: transferTimeout}
+	attrs, err := gcp.DoWithResult(ctx, call, func(ctx context.Context) (*storage.ObjectAttrs, error) {
+		return dst.CopierFrom(src).Run(ctx)
+	})
+	if err != nil {
+		return fmt.Errorf("could not copy gs://%s/%s to gs://%s/%s: %v", srcBucket, srcName, dstBucket, dstName, err)
+	}
+	srcAttrs, err := src.Attrs(ctx)
+	if err != nil {
+		return fmt.Errorf("could not check gs://%s/%s: %v", srcBucket, srcName, err)
+	}
+	if attrs.CRC32C != srcAttrs.CRC32C {
+		return fmt.Errorf("checksum of gs://%s/%s does not match the one of gs://%s/%s", dstBucket, dstName, srcBucket, srcName)
+	}
+	return nil
+}
+
+// UploadObject streams the content of r to an object with a resumable upload
+// sent in chunks of UPLOAD_CHUNK_SIZE bytes. The checksum of the object is
+// checked against the one of the data read, and the object is deleted if they
+// don't match.
+func (g GcsStorageAccessor) UploadObject(ctx context.Context, bucket, name string, r io.Reader) (int64, error) {
+	obj := g.Client.Bucket(bucket).Object(name)
+	// The reader can't be read again, so the upload is only retried chunk by
+	// chunk by the writer.
+	call := gcp.Call{Service: gcp.STORAGE, Method: "UploadObject", Resource: fmt.Sprintf("gs://%s/%s", bucket, name), Timeout: transferTimeout}
+	var n int64
+	var attrs *storage.ObjectAttrs
+	checksum := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	err := gcp.Do(ctx, call, func(ctx context.Context) error {
+		w := obj.NewWriter(ctx)
+		w.ChunkSize = UPLOAD_CHUNK_SIZE
+		w.ChunkRetryDeadline = chunkRetryDeadline
+		var err error
+		if n, err = io.Copy(w, io.TeeReader(r, checksum)); err != nil {
+			w.Close()
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		attrs = w.Attrs()
+		return nil
+	})
+	if err != nil {
+		return n, fmt.Errorf("could not upload to gs://%s/%s: %v", bucket, name, err)
+	}
+	if attrs == nil || attrs.CRC32C != checksum.Sum32() {
+		obj.Delete(ctx)
+		return n, fmt.Errorf("checksum of gs://%s/%s does not match the one of the uploaded data", bucket, name)
+	}
+	return n, nil
+}
+
+// GenerateSignedURL returns a V4 signed URL downloading an object, valid for
+// ttl. The URL is signed with the private key of the credentials of the
+// client if they have one, or else through the IAM credentials API on behalf
+// of its service account, which then needs the
+// iam.serviceAccounts.signBlob permission on itself.
+func (g GcsStorageAccessor) GenerateSignedURL(bucket, name string, ttl time.Duration) (string, error) {
+	if ttl <= 0 || ttl > MAX_SIGNED_URL_TTL {
+		return "", fmt.Errorf("the validity of a signed URL must be positive and at most %v, got %v", MAX_SIGNED_URL_TTL, ttl)
+	}
+	url, err := g.Client.Bucket(bucket).SignedURL(name, &storage.SignedURLOptions{
+		Method:  "GET",
+		Expires: time.Now().Add(ttl),
+		Scheme:  storage.SigningSchemeV4,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not sign a URL for gs://%s/%s: %v", bucket, name, err)
+	}
+	return url, nil
+}
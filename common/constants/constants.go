@@ -79,6 +79,8 @@ const (
 	DATAFLOW_MIGRATION = "dataflow"
 	//DMS migration type
 	DMS_MIGRATION = "dms"
+	// File format of an information_schema snapshot used for offline schema conversion.
+	INFOSCHEMA_SNAPSHOT_FORMAT = "infoschema"
 
 	SESSION_FILE = "sessionFile"
 )
@@ -0,0 +1,141 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dataflowjobs launches and manages Dataflow jobs through the
+// DataflowAccessor interface, so that the code orchestrating the jobs can be
+// tested against fakes such as testutil.FakeDataflowAccessor.
+package dataflowjobs
+
+import (
+	"context"
+	"fmt"
+
+	dataflow "cloud.google.com/go/dataflow/apiv1beta3"
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"google.golang.org/api/iterator"
+)
+
+// DataflowAccessor launches flex template jobs, and lists, reads and stops
+// the jobs of a project and region.
+type DataflowAccessor interface {
+	// LaunchFlexTemplate launches the job of req, or only validates it if
+	// req.ValidateOnly is set.
+	LaunchFlexTemplate(ctx context.Context, req *dataflowpb.LaunchFlexTemplateRequest) (*dataflowpb.LaunchFlexTemplateResponse, error)
+	// ListJobs returns all the jobs of the project and location, including
+	// the terminated ones. The jobs are listed without their labels and
+	// environment.
+	ListJobs(ctx context.Context, projectId, location string) ([]*dataflowpb.Job, error)
+	// GetJob returns a job, with its labels and environment.
+	GetJob(ctx context.Context, projectId, location, jobId string) (*dataflowpb.Job, error)
+	// UpdateJobState requests a job to move to state, e.g. cancelled or
+	// drained.
+	UpdateJobState(ctx context.Context, projectId, location, jobId string, state dataflowpb.JobState) error
+	Close() error
+}
+
+// GcpDataflowAccessor implements DataflowAccessor with the Dataflow API.
+type GcpDataflowAccessor struct {
+	Templates *dataflow.FlexTemplatesClient
+	Jobs      *dataflow.JobsV1Beta3Client
+}
+
+var _ DataflowAccessor = (*GcpDataflowAccessor)(nil)
+
+// NewGcpDataflowAccessor creates the Dataflow clients of the accessor with
+// the default credentials.
+func NewGcpDataflowAccessor(ctx context.Context) (*GcpDataflowAccessor, error) {
+	templates, err := dataflow.NewFlexTemplatesClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create flex template client: %v", err)
+	}
+	jobs, err := dataflow.NewJobsV1Beta3Client(ctx)
+	if err != nil {
+		templates.Close()
+		return nil, fmt.Errorf("could not create dataflow jobs client: %v", err)
+	}
+	return &GcpDataflowAccessor{Templates: templates, Jobs: jobs}, nil
+}
+
+// LaunchFlexTemplate only retries the launches rejected before being
+// applied, as launching a job again would run it twice. Validations are
+// always retried.
+func (a *GcpDataflowAccessor) LaunchFlexTemplate(ctx context.Context, req *dataflowpb.LaunchFlexTemplateRequest) (*dataflowpb.LaunchFlexTemplateResponse, error) {
+	call := gcp.Call{Service: gcp.DATAFLOW, Method: "LaunchFlexTemplate", Resource: req.GetLaunchParameter().GetJobName(), Idempotent: req.ValidateOnly}
+	return gcp.DoWithResult(ctx, call, func(ctx context.Context) (*dataflowpb.LaunchFlexTemplateResponse, error) {
+		return a.Templates.LaunchFlexTemplate(ctx, req)
+	})
+}
+
+func (a *GcpDataflowAccessor) ListJobs(ctx context.Context, projectId, location string) ([]*dataflowpb.Job, error) {
+	var jobs []*dataflowpb.Job
+	call := gcp.Call{Service: gcp.DATAFLOW, Method: "ListJobs", Resource: fmt.Sprintf("projects/%s/locations/%s", projectId, location), Idempotent: true}
+	err := gcp.Do(ctx, call, func(ctx context.Context) error {
+		// A failed listing is started over.
+		jobs = nil
+		it := a.Jobs.ListJobs(ctx, &dataflowpb.ListJobsRequest{
+			ProjectId: projectId,
+			Location:  location,
+			Filter:    dataflowpb.ListJobsRequest_ALL,
+		})
+		for {
+			job, err := it.Next()
+			if err == iterator.Done {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+		}
+	})
+	return jobs, err
+}
+
+func (a *GcpDataflowAccessor) GetJob(ctx context.Context, projectId, location, jobId string) (*dataflowpb.Job, error) {
+	call := gcp.Call{Service: gcp.DATAFLOW, Method: "GetJob", Resource: jobId, Idempotent: true}
+	return gcp.DoWithResult(ctx, call, func(ctx context.Context) (*dataflowpb.Job, error) {
+		return a.Jobs.GetJob(ctx, &dataflowpb.GetJobRequest{ProjectId: projectId, JobId: jobId, Location: location, View: dataflowpb.JobView_JOB_VIEW_DESCRIPTION})
+	})
+}
+
+// UpdateJobState retries the requests, as requesting the same state again is
+// harmless.
+func (a *GcpDataflowAccessor) UpdateJobState(ctx context.Context, projectId, location, jobId string, state dataflowpb.JobState) error {
+	call := gcp.Call{Service: gcp.DATAFLOW, Method: "UpdateJob", Resource: jobId, Idempotent: true}
+	return gcp.Do(ctx, call, func(ctx context.Context) error {
+		_, err := a.Jobs.UpdateJob(ctx, &dataflowpb.UpdateJobRequest{
+			ProjectId: projectId,
+			JobId:     jobId,
+			Location:  location,
+			Job:       &dataflowpb.Job{RequestedState: state},
+		})
+		return err
+	})
+}
+
+func (a *GcpDataflowAccessor) Close() error {
+	a.Templates.Close()
+	return a.Jobs.Close()
+}
+
+// IsTerminalJobState returns whether a job in state has stopped for good.
+func IsTerminalJobState(state dataflowpb.JobState) bool {
+	switch state {
+	case dataflowpb.JobState_JOB_STATE_DONE, dataflowpb.JobState_JOB_STATE_FAILED, dataflowpb.JobState_JOB_STATE_CANCELLED,
+		dataflowpb.JobState_JOB_STATE_DRAINED, dataflowpb.JobState_JOB_STATE_UPDATED, dataflowpb.JobState_JOB_STATE_STOPPED:
+		return true
+	}
+	return false
+}
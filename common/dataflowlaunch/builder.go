@@ -0,0 +1,188 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dataflowlaunch builds the requests launching the Dataflow flex
+// template jobs of Spanner migration tool, such as the forward migration job
+// and the reader and writer jobs of reverse replication. The requests are
+// checked against the limits of Dataflow before they are sent, so that an
+// invalid job name, label or parameter is reported with the field at fault
+// rather than as a launch failure.
+package dataflowlaunch
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+)
+
+const (
+	// MAX_JOB_NAME_LENGTH is the longest name of a Dataflow job.
+	MAX_JOB_NAME_LENGTH = 1024
+	// MAX_LABELS is the most labels a job can have.
+	MAX_LABELS = 64
+	// MAX_LABEL_LENGTH is the longest key or value of a label.
+	MAX_LABEL_LENGTH = 63
+	// MAX_PARAMETERS_BYTES is the largest total size of the names and values
+	// of the parameters of a job. The parameters are passed to the launcher
+	// VM of the template, which fails on larger parameters with errors
+	// unrelated to their size.
+	MAX_PARAMETERS_BYTES = 1 << 20
+)
+
+var (
+	jobNameRegex = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+	// Labels may have international characters, as long as they are
+	// lowercase.
+	labelKeyRegex        = regexp.MustCompile(`^\p{Ll}[-_\p{Ll}\p{Lo}\p{N}]*$`)
+	labelValueRegex      = regexp.MustCompile(`^[-_\p{Ll}\p{Lo}\p{N}]*$`)
+	gcsTemplatePathRegex = regexp.MustCompile(`^gs://[^/]+/.+`)
+)
+
+// LaunchRequestBuilder builds the request launching a flex template job.
+// The With methods return the builder so that they can be chained:
+//
+//	req, err := dataflowlaunch.NewLaunchRequestBuilder(project, region, jobName).
+//		WithTemplate(templatePath).
+//		WithParams(params).
+//		WithEnvironment(env).
+//		Build()
+type LaunchRequestBuilder struct {
+	projectId    string
+	location     string
+	jobName      string
+	templatePath string
+	params       map[string]string
+	env          *dataflowpb.FlexTemplateRuntimeEnvironment
+}
+
+// NewLaunchRequestBuilder returns a builder of the request launching the job
+// jobName in project and location, a Dataflow region.
+func NewLaunchRequestBuilder(projectId, location, jobName string) *LaunchRequestBuilder {
+	return &LaunchRequestBuilder{
+		projectId: projectId,
+		location:  location,
+		jobName:   jobName,
+		params:    make(map[string]string),
+	}
+}
+
+// WithTemplate sets the gcs path of the container spec of the flex template.
+func (b *LaunchRequestBuilder) WithTemplate(gcsPath string) *LaunchRequestBuilder {
+	b.templatePath = gcsPath
+	return b
+}
+
+// WithParams adds params to the template parameters, replacing the values of
+// the parameters already set.
+func (b *LaunchRequestBuilder) WithParams(params map[string]string) *LaunchRequestBuilder {
+	for k, v := range params {
+		b.params[k] = v
+	}
+	return b
+}
+
+// WithEnvironment sets the runtime environment of the job. Jobs launched
+// without one run with the defaults of Dataflow.
+func (b *LaunchRequestBuilder) WithEnvironment(env *dataflowpb.FlexTemplateRuntimeEnvironment) *LaunchRequestBuilder {
+	b.env = env
+	return b
+}
+
+// Validate returns an error listing every field of the request which
+// Dataflow would reject.
+func (b *LaunchRequestBuilder) Validate() error {
+	var problems []string
+	if b.projectId == "" {
+		problems = append(problems, "no project")
+	}
+	if b.location == "" {
+		problems = append(problems, "no location")
+	}
+	if len(b.jobName) > MAX_JOB_NAME_LENGTH || !jobNameRegex.MatchString(b.jobName) {
+		problems = append(problems, fmt.Sprintf("invalid job name %q, it must have at most %d characters, start with a lowercase letter, end with a lowercase letter or digit and only have lowercase letters, digits and hyphens", b.jobName, MAX_JOB_NAME_LENGTH))
+	}
+	if !gcsTemplatePathRegex.MatchString(b.templatePath) {
+		problems = append(problems, fmt.Sprintf("invalid template path %q, it must be the gcs path of the template spec", b.templatePath))
+	}
+	size := 0
+	for k, v := range b.params {
+		size += len(k) + len(v)
+	}
+	if size > MAX_PARAMETERS_BYTES {
+		problems = append(problems, fmt.Sprintf("the parameters have %d bytes, more than the %d bytes allowed", size, MAX_PARAMETERS_BYTES))
+	}
+	if b.env != nil {
+		problems = append(problems, validateLabels(b.env.AdditionalUserLabels)...)
+		if b.env.NumWorkers < 0 || b.env.MaxWorkers < 0 {
+			problems = append(problems, "the number of workers can't be negative")
+		} else if b.env.MaxWorkers != 0 && b.env.NumWorkers > b.env.MaxWorkers {
+			problems = append(problems, fmt.Sprintf("the initial number of workers %d is more than the maximum %d", b.env.NumWorkers, b.env.MaxWorkers))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid launch request for job %s: %s", b.jobName, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateLabels returns the problems of labels, in the order of their keys.
+func validateLabels(labels map[string]string) []string {
+	var problems []string
+	if len(labels) > MAX_LABELS {
+		problems = append(problems, fmt.Sprintf("%d labels, more than the %d allowed", len(labels), MAX_LABELS))
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if utf8.RuneCountInString(k) > MAX_LABEL_LENGTH || !labelKeyRegex.MatchString(k) {
+			problems = append(problems, fmt.Sprintf("invalid label key %q, it must have at most %d characters, start with a lowercase letter and only have lowercase letters, digits, underscores and hyphens", k, MAX_LABEL_LENGTH))
+		}
+		if v := labels[k]; utf8.RuneCountInString(v) > MAX_LABEL_LENGTH || !labelValueRegex.MatchString(v) {
+			problems = append(problems, fmt.Sprintf("invalid value %q of label %s, it must have at most %d characters and only have lowercase letters, digits, underscores and hyphens", v, k, MAX_LABEL_LENGTH))
+		}
+	}
+	return problems
+}
+
+// Build validates the request and returns it.
+func (b *LaunchRequestBuilder) Build() (*dataflowpb.LaunchFlexTemplateRequest, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	env := b.env
+	if env == nil {
+		env = &dataflowpb.FlexTemplateRuntimeEnvironment{}
+	}
+	params := make(map[string]string, len(b.params))
+	for k, v := range b.params {
+		params[k] = v
+	}
+	return &dataflowpb.LaunchFlexTemplateRequest{
+		ProjectId: b.projectId,
+		LaunchParameter: &dataflowpb.LaunchFlexTemplateParameter{
+			JobName:     b.jobName,
+			Template:    &dataflowpb.LaunchFlexTemplateParameter_ContainerSpecGcsPath{ContainerSpecGcsPath: b.templatePath},
+			Parameters:  params,
+			Environment: env,
+		},
+		Location: b.location,
+	}, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataflowlaunch
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTemplate = "gs://dataflow-templates/latest/flex/Spanner_to_SourceDb"
+
+func TestBuild(t *testing.T) {
+	env := &dataflowpb.FlexTemplateRuntimeEnvironment{NumWorkers: 1, MaxWorkers: 5, AdditionalUserLabels: map[string]string{"migration": "smt-1", "équipe": "données"}}
+	req, err := NewLaunchRequestBuilder("p1", "us-central1", "smt-writer").
+		WithTemplate(testTemplate).
+		WithParams(map[string]string{"sessionFilePath": "gs://b/session.json", "bufferType": "gcs"}).
+		WithParams(map[string]string{"bufferType": "pubsub"}).
+		WithEnvironment(env).
+		Build()
+	assert.Nil(t, err)
+	assert.Equal(t, "p1", req.ProjectId)
+	assert.Equal(t, "us-central1", req.Location)
+	assert.Equal(t, "smt-writer", req.LaunchParameter.JobName)
+	assert.Equal(t, testTemplate, req.LaunchParameter.GetContainerSpecGcsPath())
+	assert.Equal(t, map[string]string{"sessionFilePath": "gs://b/session.json", "bufferType": "pubsub"}, req.LaunchParameter.Parameters)
+	assert.Equal(t, env, req.LaunchParameter.Environment)
+
+	req, err = NewLaunchRequestBuilder("p1", "us-central1", "smt-writer").WithTemplate(testTemplate).Build()
+	assert.Nil(t, err)
+	assert.NotNil(t, req.LaunchParameter.Environment)
+	assert.Empty(t, req.LaunchParameter.Parameters)
+}
+
+func TestValidate(t *testing.T) {
+	manyLabels := map[string]string{}
+	for i := 0; i <= MAX_LABELS; i++ {
+		manyLabels[fmt.Sprintf("label%d", i)] = ""
+	}
+	tests := []struct {
+		name    string
+		b       *LaunchRequestBuilder
+		wantErr string
+	}{
+		{
+			name:    "missing project and location",
+			b:       NewLaunchRequestBuilder("", "", "smt").WithTemplate(testTemplate),
+			wantErr: "no project; no location",
+		},
+		{
+			name:    "uppercase job name",
+			b:       NewLaunchRequestBuilder("p1", "us-central1", "SMT-writer").WithTemplate(testTemplate),
+			wantErr: `invalid job name "SMT-writer"`,
+		},
+		{
+			name:    "job name ending with a hyphen",
+			b:       NewLaunchRequestBuilder("p1", "us-central1", "smt-").WithTemplate(testTemplate),
+			wantErr: `invalid job name "smt-"`,
+		},
+		{
+			name:    "job name too long",
+			b:       NewLaunchRequestBuilder("p1", "us-central1", strings.Repeat("a", MAX_JOB_NAME_LENGTH+1)).WithTemplate(testTemplate),
+			wantErr: "invalid job name",
+		},
+		{
+			name:    "local template",
+			b:       NewLaunchRequestBuilder("p1", "us-central1", "smt").WithTemplate("/tmp/template.json"),
+			wantErr: `invalid template path "/tmp/template.json"`,
+		},
+		{
+			name:    "parameters too large",
+			b:       NewLaunchRequestBuilder("p1", "us-central1", "smt").WithTemplate(testTemplate).WithParams(map[string]string{"filter": strings.Repeat("a", MAX_PARAMETERS_BYTES)}),
+			wantErr: "the parameters have 1048582 bytes",
+		},
+		{
+			name:    "invalid label",
+			b:       NewLaunchRequestBuilder("p1", "us-central1", "smt").WithTemplate(testTemplate).WithEnvironment(&dataflowpb.FlexTemplateRuntimeEnvironment{AdditionalUserLabels: map[string]string{"Team": "db", "owner": "Jane"}}),
+			wantErr: `invalid label key "Team"`,
+		},
+		{
+			name:    "label value too long",
+			b:       NewLaunchRequestBuilder("p1", "us-central1", "smt").WithTemplate(testTemplate).WithEnvironment(&dataflowpb.FlexTemplateRuntimeEnvironment{AdditionalUserLabels: map[string]string{"owner": strings.Repeat("a", MAX_LABEL_LENGTH+1)}}),
+			wantErr: "invalid value",
+		},
+		{
+			name:    "too many labels",
+			b:       NewLaunchRequestBuilder("p1", "us-central1", "smt").WithTemplate(testTemplate).WithEnvironment(&dataflowpb.FlexTemplateRuntimeEnvironment{AdditionalUserLabels: manyLabels}),
+			wantErr: "65 labels, more than the 64 allowed",
+		},
+		{
+			name:    "more initial workers than the maximum",
+			b:       NewLaunchRequestBuilder("p1", "us-central1", "smt").WithTemplate(testTemplate).WithEnvironment(&dataflowpb.FlexTemplateRuntimeEnvironment{NumWorkers: 10, MaxWorkers: 5}),
+			wantErr: "the initial number of workers 10 is more than the maximum 5",
+		},
+	}
+	for _, tc := range tests {
+		err := tc.b.Validate()
+		if assert.NotNil(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.wantErr, tc.name)
+		}
+		_, err = tc.b.Build()
+		assert.NotNil(t, err, tc.name)
+	}
+
+	err := NewLaunchRequestBuilder("p1", "us-central1", "smt").WithTemplate(testTemplate).WithEnvironment(&dataflowpb.FlexTemplateRuntimeEnvironment{AdditionalUserLabels: map[string]string{"Team": "db", "owner": "Jane"}}).Validate()
+	assert.Contains(t, err.Error(), `invalid label key "Team"`)
+	assert.Contains(t, err.Error(), `invalid value "Jane" of label owner`)
+}
@@ -0,0 +1,365 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcp wraps the calls made by Spanner migration tool to the Google
+// Cloud APIs, such as Dataflow, Cloud Storage, Spanner and Datastream, so that
+// they share the same retry policy, default deadline, error categories and
+// logs. Failed calls return an *Error, whose category tells the callers what
+// went wrong regardless of the API and transport, gRPC or HTTP, of the call.
+//
+// A call is only retried if making it again can't apply it twice: idempotent
+// calls are retried after any transient error, while the calls creating or
+// changing resources are only retried when the error shows that the attempt
+// was rejected before being applied, e.g. because of a quota.
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Services the calls are made to.
+const (
+	DATAFLOW         = "dataflow"
+	DATASTREAM       = "datastream"
+	MONITORING       = "monitoring"
+	PUBSUB           = "pubsub"
+	RESOURCE_MANAGER = "cloudresourcemanager"
+	SPANNER          = "spanner"
+	STORAGE          = "storage"
+)
+
+// Category is the kind of failure of a call, independent of the API.
+type Category string
+
+const (
+	CATEGORY_NOT_FOUND           Category = "NOT_FOUND"
+	CATEGORY_ALREADY_EXISTS      Category = "ALREADY_EXISTS"
+	CATEGORY_PERMISSION_DENIED   Category = "PERMISSION_DENIED"
+	CATEGORY_UNAUTHENTICATED     Category = "UNAUTHENTICATED"
+	CATEGORY_INVALID_ARGUMENT    Category = "INVALID_ARGUMENT"
+	CATEGORY_FAILED_PRECONDITION Category = "FAILED_PRECONDITION"
+	CATEGORY_QUOTA_EXCEEDED      Category = "QUOTA_EXCEEDED"
+	CATEGORY_UNAVAILABLE         Category = "UNAVAILABLE"
+	CATEGORY_DEADLINE_EXCEEDED   Category = "DEADLINE_EXCEEDED"
+	CATEGORY_CANCELLED           Category = "CANCELLED"
+	CATEGORY_INTERNAL            Category = "INTERNAL"
+	CATEGORY_UNKNOWN             Category = "UNKNOWN"
+)
+
+const (
+	// DEFAULT_TIMEOUT is the deadline of an attempt of a call which doesn't
+	// set one, unless the context of the call has an earlier deadline.
+	DEFAULT_TIMEOUT = 2 * time.Minute
+	maxAttempts     = 5
+	initialBackoff  = time.Second
+	maxBackoff      = 30 * time.Second
+	// Minimum wait before retrying a call rejected because of a quota. Most
+	// quotas are enforced per minute, so retrying sooner is likely to be
+	// rejected again.
+	quotaBackoff = 10 * time.Second
+)
+
+// Call describes a call to a Google Cloud API.
+type Call struct {
+	Service string
+	// Name of the method called, e.g. GetConnectionProfile.
+	Method string
+	// Resource the call is made on, if any, e.g. the name of a connection
+	// profile.
+	Resource string
+	// Idempotent calls are retried when they fail with a transient error.
+	// The other calls, e.g. creating or changing resources, are only retried
+	// when IsRejected, as a failed attempt may have been applied.
+	Idempotent bool
+	// Deadline of each attempt, DEFAULT_TIMEOUT if zero.
+	Timeout time.Duration
+	// Limiter, if set, spaces the attempts of the calls sharing it, e.g. to
+	// stay within a quota shared by the process.
+	Limiter *Limiter
+}
+
+func (c Call) String() string {
+	if c.Resource == "" {
+		return fmt.Sprintf("%s %s", c.Service, c.Method)
+	}
+	return fmt.Sprintf("%s %s of %s", c.Service, c.Method, c.Resource)
+}
+
+// Error is the error of a failed call.
+type Error struct {
+	Call     Call
+	Category Category
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s failed (%s): %v", e.Call, e.Category, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// GetCategory returns the category of the error err returned by a Google
+// Cloud client, or by a call made through this package.
+func GetCategory(err error) Category {
+	var callErr *Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &callErr):
+		return callErr.Category
+	case errors.Is(err, context.DeadlineExceeded):
+		return CATEGORY_DEADLINE_EXCEEDED
+	case errors.Is(err, context.Canceled):
+		return CATEGORY_CANCELLED
+	case errors.Is(err, storage.ErrObjectNotExist), errors.Is(err, storage.ErrBucketNotExist):
+		return CATEGORY_NOT_FOUND
+	}
+	var httpErr *googleapi.Error
+	if errors.As(err, &httpErr) {
+		return getHTTPCategory(httpErr.Code)
+	}
+	return getGRPCCategory(status.Code(err))
+}
+
+func getGRPCCategory(code codes.Code) Category {
+	switch code {
+	case codes.NotFound:
+		return CATEGORY_NOT_FOUND
+	case codes.AlreadyExists:
+		return CATEGORY_ALREADY_EXISTS
+	case codes.PermissionDenied:
+		return CATEGORY_PERMISSION_DENIED
+	case codes.Unauthenticated:
+		return CATEGORY_UNAUTHENTICATED
+	case codes.InvalidArgument, codes.OutOfRange:
+		return CATEGORY_INVALID_ARGUMENT
+	case codes.FailedPrecondition:
+		return CATEGORY_FAILED_PRECONDITION
+	case codes.ResourceExhausted:
+		return CATEGORY_QUOTA_EXCEEDED
+	case codes.Unavailable, codes.Aborted:
+		return CATEGORY_UNAVAILABLE
+	case codes.DeadlineExceeded:
+		return CATEGORY_DEADLINE_EXCEEDED
+	case codes.Canceled:
+		return CATEGORY_CANCELLED
+	case codes.Internal, codes.DataLoss:
+		return CATEGORY_INTERNAL
+	default:
+		return CATEGORY_UNKNOWN
+	}
+}
+
+func getHTTPCategory(code int) Category {
+	switch code {
+	case http.StatusNotFound:
+		return CATEGORY_NOT_FOUND
+	case http.StatusConflict:
+		return CATEGORY_ALREADY_EXISTS
+	case http.StatusForbidden:
+		return CATEGORY_PERMISSION_DENIED
+	case http.StatusUnauthorized:
+		return CATEGORY_UNAUTHENTICATED
+	case http.StatusBadRequest:
+		return CATEGORY_INVALID_ARGUMENT
+	case http.StatusPreconditionFailed:
+		return CATEGORY_FAILED_PRECONDITION
+	case http.StatusTooManyRequests:
+		return CATEGORY_QUOTA_EXCEEDED
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return CATEGORY_UNAVAILABLE
+	case http.StatusGatewayTimeout:
+		return CATEGORY_DEADLINE_EXCEEDED
+	case http.StatusInternalServerError:
+		return CATEGORY_INTERNAL
+	default:
+		return CATEGORY_UNKNOWN
+	}
+}
+
+// IsNotFound returns whether err is the error of a call on a resource which
+// doesn't exist.
+func IsNotFound(err error) bool {
+	return GetCategory(err) == CATEGORY_NOT_FOUND
+}
+
+// IsAlreadyExists returns whether err is the error of a call creating a
+// resource which already exists.
+func IsAlreadyExists(err error) bool {
+	return GetCategory(err) == CATEGORY_ALREADY_EXISTS
+}
+
+// IsRetryable returns whether the call which failed with err may succeed if
+// made again.
+func IsRetryable(err error) bool {
+	switch GetCategory(err) {
+	case CATEGORY_QUOTA_EXCEEDED, CATEGORY_UNAVAILABLE:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsRejected returns whether the call which failed with err was rejected
+// before being applied, so that it may be made again even if it is not
+// idempotent: calls over a quota, and Spanner schema changes rejected because
+// another one is running on the database.
+func IsRejected(err error) bool {
+	if GetCategory(err) == CATEGORY_QUOTA_EXCEEDED {
+		return true
+	}
+	return status.Code(err) == codes.FailedPrecondition && strings.Contains(err.Error(), "concurrent schema change")
+}
+
+// canRetry returns whether the call c which failed with err may be made
+// again.
+func canRetry(c Call, err error) bool {
+	if IsRejected(err) {
+		return true
+	}
+	return c.Idempotent && IsRetryable(err)
+}
+
+// RetryDelay returns the delay to wait before retrying given by the server in
+// err, if any.
+func RetryDelay(err error) time.Duration {
+	s, ok := status.FromError(err)
+	if !ok {
+		return 0
+	}
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.RetryDelay != nil {
+			return info.RetryDelay.AsDuration()
+		}
+	}
+	return 0
+}
+
+// Retrier retries the calls which failed with an error they can be retried
+// after, with exponential backoff and jitter.
+type Retrier struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	QuotaBackoff   time.Duration
+	// sleep is replaced in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+var defaultRetrier = &Retrier{
+	MaxAttempts:    maxAttempts,
+	InitialBackoff: initialBackoff,
+	MaxBackoff:     maxBackoff,
+	QuotaBackoff:   quotaBackoff,
+}
+
+// Do makes the call c with f through the default retrier. f must not keep
+// the context it is given once it returns, as the context expires with the
+// attempt.
+func Do(ctx context.Context, c Call, f func(ctx context.Context) error) error {
+	return defaultRetrier.Do(ctx, c, f)
+}
+
+// DoWithResult is Do for calls returning a result.
+func DoWithResult[T any](ctx context.Context, c Call, f func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := Do(ctx, c, func(ctx context.Context) error {
+		var err error
+		result, err = f(ctx)
+		return err
+	})
+	return result, err
+}
+
+// Do makes the call c with f, retrying it if it failed with an error it can
+// be retried after, see Call.Idempotent. The error of the last attempt is
+// returned as an *Error.
+func (r *Retrier) Do(ctx context.Context, c Call, f func(ctx context.Context) error) error {
+	sleep := r.sleep
+	if sleep == nil {
+		sleep = sleepCtx
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DEFAULT_TIMEOUT
+	}
+	log := logger.FromContext(ctx).With(zap.String("service", c.Service), zap.String("method", c.Method), zap.String("resource", c.Resource))
+	backoff := r.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		if c.Limiter != nil {
+			if err := sleep(ctx, c.Limiter.reserve()); err != nil {
+				return &Error{Call: c, Category: GetCategory(err), Err: err}
+			}
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := f(attemptCtx)
+		cancel()
+		if err == nil {
+			log.Debug("Google Cloud call succeeded", zap.Int("attempt", attempt))
+			return nil
+		}
+		callErr := &Error{Call: c, Category: GetCategory(err), Err: err}
+		if attempt >= r.MaxAttempts || ctx.Err() != nil || !canRetry(c, err) {
+			log.Warn("Google Cloud call failed", zap.Int("attempt", attempt), zap.String("category", string(callErr.Category)), zap.Error(err))
+			return callErr
+		}
+		// Wait between half and all of the backoff, so that concurrent
+		// calls rejected together are retried apart.
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		if d := RetryDelay(err); d > delay {
+			delay = d
+		} else if callErr.Category == CATEGORY_QUOTA_EXCEEDED && r.QuotaBackoff > delay {
+			delay = r.QuotaBackoff
+		}
+		log.Info("Retrying Google Cloud call", zap.Int("attempt", attempt), zap.String("category", string(callErr.Category)), zap.Duration("delay", delay), zap.Error(err))
+		if err := sleep(ctx, delay); err != nil {
+			return callErr
+		}
+		backoff *= 2
+		if backoff > r.MaxBackoff {
+			backoff = r.MaxBackoff
+		}
+	}
+}
+
+// sleepCtx waits for d, or until ctx is done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestGetCategory(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Category
+	}{
+		{"nil", nil, ""},
+		{"grpc not found", status.Error(codes.NotFound, "no such stream"), CATEGORY_NOT_FOUND},
+		{"wrapped grpc already exists", fmt.Errorf("can't create: %w", status.Error(codes.AlreadyExists, "exists")), CATEGORY_ALREADY_EXISTS},
+		{"grpc aborted", status.Error(codes.Aborted, "aborted"), CATEGORY_UNAVAILABLE},
+		{"grpc quota", status.Error(codes.ResourceExhausted, "quota"), CATEGORY_QUOTA_EXCEEDED},
+		{"http conflict", &googleapi.Error{Code: 409}, CATEGORY_ALREADY_EXISTS},
+		{"http too many requests", &googleapi.Error{Code: 429}, CATEGORY_QUOTA_EXCEEDED},
+		{"http forbidden", &googleapi.Error{Code: 403}, CATEGORY_PERMISSION_DENIED},
+		{"storage object not found", storage.ErrObjectNotExist, CATEGORY_NOT_FOUND},
+		{"context deadline", fmt.Errorf("call: %w", context.DeadlineExceeded), CATEGORY_DEADLINE_EXCEEDED},
+		{"call error", &Error{Category: CATEGORY_INTERNAL, Err: errors.New("internal")}, CATEGORY_INTERNAL},
+		{"other", errors.New("other"), CATEGORY_UNKNOWN},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, GetCategory(tc.err), tc.name)
+	}
+}
+
+func newTestRetrier(delays *[]time.Duration) *Retrier {
+	return &Retrier{
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		QuotaBackoff:   10 * time.Second,
+		sleep: func(ctx context.Context, d time.Duration) error {
+			*delays = append(*delays, d)
+			return nil
+		},
+	}
+}
+
+func TestRetrierDo(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	st, err := status.New(codes.ResourceExhausted, "quota exceeded").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(20 * time.Second)})
+	assert.Nil(t, err)
+	quotaErrWithDelay := st.Err()
+	quotaErr := status.Error(codes.ResourceExhausted, "quota exceeded")
+	concurrentErr := status.Error(codes.FailedPrecondition, "Schema change operation rejected because a concurrent schema change operation or read-write transaction is already in progress.")
+	call := Call{Service: DATASTREAM, Method: "GetStream", Resource: "stream", Idempotent: true}
+
+	tests := []struct {
+		name         string
+		call         Call
+		errs         []error
+		wantCategory Category
+		wantAttempts int
+		wantDelays   []time.Duration
+	}{
+		{
+			name:         "success",
+			call:         call,
+			errs:         []error{nil},
+			wantAttempts: 1,
+		},
+		{
+			name:         "non retryable error",
+			call:         call,
+			errs:         []error{status.Error(codes.NotFound, "no such stream")},
+			wantCategory: CATEGORY_NOT_FOUND,
+			wantAttempts: 1,
+		},
+		{
+			name:         "retry delay from the server",
+			call:         call,
+			errs:         []error{quotaErrWithDelay, nil},
+			wantAttempts: 2,
+			wantDelays:   []time.Duration{20 * time.Second},
+		},
+		{
+			name:         "gives up after max attempts",
+			call:         call,
+			errs:         []error{unavailable, unavailable, unavailable},
+			wantCategory: CATEGORY_UNAVAILABLE,
+			wantAttempts: 3,
+		},
+		{
+			name:         "quota errors wait longer",
+			call:         call,
+			errs:         []error{quotaErr, nil},
+			wantAttempts: 2,
+			wantDelays:   []time.Duration{10 * time.Second},
+		},
+		{
+			name:         "non idempotent calls are not retried",
+			call:         Call{Service: DATASTREAM, Method: "CreateStream", Resource: "stream"},
+			errs:         []error{unavailable},
+			wantCategory: CATEGORY_UNAVAILABLE,
+			wantAttempts: 1,
+		},
+		{
+			name:         "rejected non idempotent calls are retried",
+			call:         Call{Service: SPANNER, Method: "UpdateDatabaseDdl", Resource: "db"},
+			errs:         []error{quotaErr, concurrentErr, nil},
+			wantAttempts: 3,
+		},
+	}
+	for _, tc := range tests {
+		var delays []time.Duration
+		r := newTestRetrier(&delays)
+		attempts := 0
+		err := r.Do(context.Background(), tc.call, func(ctx context.Context) error {
+			_, hasDeadline := ctx.Deadline()
+			assert.True(t, hasDeadline, tc.name)
+			err := tc.errs[attempts]
+			attempts++
+			return err
+		})
+		assert.Equal(t, tc.wantAttempts, attempts, tc.name)
+		if tc.wantCategory == "" {
+			assert.Nil(t, err, tc.name)
+		} else {
+			var callErr *Error
+			assert.True(t, errors.As(err, &callErr), tc.name)
+			assert.Equal(t, tc.wantCategory, callErr.Category, tc.name)
+			assert.Equal(t, tc.call, callErr.Call, tc.name)
+			assert.Equal(t, tc.errs[attempts-1], errors.Unwrap(err), tc.name)
+		}
+		if tc.wantDelays != nil {
+			assert.Equal(t, tc.wantDelays, delays, tc.name)
+		}
+	}
+}
+
+func TestDoWithResult(t *testing.T) {
+	result, err := DoWithResult(context.Background(), Call{Service: DATAFLOW, Method: "GetJob"}, func(ctx context.Context) (string, error) {
+		return "done", nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "done", result)
+
+	_, err = DoWithResult(context.Background(), Call{Service: DATAFLOW, Method: "GetJob", Resource: "job"}, func(ctx context.Context) (string, error) {
+		return "", status.Error(codes.PermissionDenied, "denied")
+	})
+	assert.False(t, IsNotFound(err))
+	assert.Equal(t, "dataflow GetJob of job failed (PERMISSION_DENIED): rpc error: code = PermissionDenied desc = denied", err.Error())
+}
+
+func TestRetrierDoLimiter(t *testing.T) {
+	var delays []time.Duration
+	r := newTestRetrier(&delays)
+	now := time.Unix(0, 0)
+	l := NewLimiter(1, 1)
+	l.now = func() time.Time { return now }
+	call := Call{Service: SPANNER, Method: "GetDatabase", Idempotent: true, Limiter: l}
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, r.Do(context.Background(), call, func(ctx context.Context) error { return nil }))
+	}
+	assert.Equal(t, []time.Duration{0, time.Second, 2 * time.Second}, delays)
+}
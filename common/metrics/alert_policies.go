@@ -0,0 +1,120 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Defines the alert policies created for the dataflow job of a shard
+const (
+	// Default watermark lag, in minutes, beyond which an alert is raised
+	DefaultWatermarkLagMinutes int64 = 15
+	// Window over which the system lag must keep growing to raise an alert
+	systemLagGrowthWindow = 30 * time.Minute
+
+	dataflowWatermarkLagAlertQuery = "fetch dataflow_job | metric 'dataflow.googleapis.com/job/data_watermark_age' | " +
+		"filter (metric.job_id == '%s') | group_by 1m, [value_data_watermark_age_max: max(value.data_watermark_age)] | " +
+		"every 1m | condition val() > %d 's'"
+	dataflowJobFailedAlertQuery = "fetch dataflow_job | metric 'dataflow.googleapis.com/job/is_failed' | " +
+		"filter (metric.job_id == '%s') | group_by 5m, [value_is_failed_max: max(value.is_failed)] | " +
+		"every 5m | condition val() > 0"
+	dataflowSystemLagGrowthAlertQuery = "fetch dataflow_job | metric 'dataflow.googleapis.com/job/system_lag' | " +
+		"filter (metric.job_id == '%s') | align delta_gauge(10m) | every 10m | condition val() > 0 's'"
+)
+
+// getNotificationChannelName returns the full resource name of a notification
+// channel given either by name or by id.
+func getNotificationChannelName(projectId, channel string) string {
+	if strings.HasPrefix(channel, "projects/") {
+		return channel
+	}
+	return fmt.Sprintf("projects/%s/notificationChannels/%s", projectId, channel)
+}
+
+// getDataflowAlertPolicies returns the alert policies watching the dataflow
+// job of the shard: the watermark lag exceeding watermarkLagMinutes, the job
+// failing and the system lag growing for systemLagGrowthWindow.
+func getDataflowAlertPolicies(resourceIds MonitoringMetricsResources, notificationChannel string, watermarkLagMinutes int64) []*monitoringpb.AlertPolicy {
+	if watermarkLagMinutes <= 0 {
+		watermarkLagMinutes = DefaultWatermarkLagMinutes
+	}
+	displayNamePrefix := fmt.Sprintf("Migration %s", resourceIds.MigrationRequestId)
+	if resourceIds.ShardId != "" {
+		displayNamePrefix = fmt.Sprintf("%s shard %s", displayNamePrefix, resourceIds.ShardId)
+	}
+	newPolicy := func(displayName, query string, duration time.Duration, documentation string) *monitoringpb.AlertPolicy {
+		return &monitoringpb.AlertPolicy{
+			DisplayName: fmt.Sprintf("%s: %s", displayNamePrefix, displayName),
+			Documentation: &monitoringpb.AlertPolicy_Documentation{
+				Content:  fmt.Sprintf("%s Dataflow job: %s.", documentation, resourceIds.DataflowJobId),
+				MimeType: "text/markdown",
+			},
+			Combiner: monitoringpb.AlertPolicy_OR,
+			Conditions: []*monitoringpb.AlertPolicy_Condition{{
+				DisplayName: displayName,
+				Condition: &monitoringpb.AlertPolicy_Condition_ConditionMonitoringQueryLanguage{
+					ConditionMonitoringQueryLanguage: &monitoringpb.AlertPolicy_Condition_MonitoringQueryLanguageCondition{
+						Query:    query,
+						Duration: durationpb.New(duration),
+					},
+				},
+			}},
+			NotificationChannels: []string{getNotificationChannelName(resourceIds.ProjectId, notificationChannel)},
+		}
+	}
+	return []*monitoringpb.AlertPolicy{
+		newPolicy(fmt.Sprintf("Dataflow watermark lag above %d minutes", watermarkLagMinutes),
+			fmt.Sprintf(dataflowWatermarkLagAlertQuery, resourceIds.DataflowJobId, watermarkLagMinutes*60), 5*time.Minute,
+			"The data watermark of the Dataflow job is lagging, changes from the source are reaching Spanner late."),
+		newPolicy("Dataflow job failed",
+			fmt.Sprintf(dataflowJobFailedAlertQuery, resourceIds.DataflowJobId), 0,
+			"The Dataflow job failed and no longer migrates changes from the source."),
+		newPolicy("Dataflow system lag growing",
+			fmt.Sprintf(dataflowSystemLagGrowthAlertQuery, resourceIds.DataflowJobId), systemLagGrowthWindow,
+			"The system lag of the Dataflow job keeps growing, the job can't keep up with the changes from the source."),
+	}
+}
+
+// CreateDataflowAlertPolicies creates the alert policies watching the dataflow
+// job of the shard, notifying notificationChannel, and returns their names.
+// Policies created before a failure are returned along with the error so
+// that they can be cleaned up.
+func (resourceIds MonitoringMetricsResources) CreateDataflowAlertPolicies(ctx context.Context, notificationChannel string, watermarkLagMinutes int64) ([]string, error) {
+	client, err := monitoring.NewAlertPolicyClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("alert policy client can not be created: %v", err)
+	}
+	defer client.Close()
+	var names []string
+	for _, policy := range getDataflowAlertPolicies(resourceIds, notificationChannel, watermarkLagMinutes) {
+		resp, err := client.CreateAlertPolicy(ctx, &monitoringpb.CreateAlertPolicyRequest{
+			Name:        "projects/" + resourceIds.ProjectId,
+			AlertPolicy: policy,
+		})
+		if err != nil {
+			return names, fmt.Errorf("could not create alert policy %s: %v", policy.DisplayName, err)
+		}
+		names = append(names, resp.Name)
+	}
+	return names, nil
+}
@@ -0,0 +1,38 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDataflowAlertPolicies(t *testing.T) {
+	resourceIds := MonitoringMetricsResources{ProjectId: "my-project", DataflowJobId: "job-id", ShardId: "shard1", MigrationRequestId: "req"}
+
+	policies := getDataflowAlertPolicies(resourceIds, "123", 0)
+	assert.Equal(t, 3, len(policies))
+	for _, p := range policies {
+		assert.Equal(t, []string{"projects/my-project/notificationChannels/123"}, p.NotificationChannels)
+		assert.Contains(t, p.DisplayName, "Migration req shard shard1: ")
+		assert.Contains(t, p.Conditions[0].GetConditionMonitoringQueryLanguage().Query, "metric.job_id == 'job-id'")
+	}
+	assert.Contains(t, policies[0].Conditions[0].GetConditionMonitoringQueryLanguage().Query, "val() > 900 's'")
+
+	policies = getDataflowAlertPolicies(resourceIds, "projects/other/notificationChannels/456", 5)
+	assert.Equal(t, []string{"projects/other/notificationChannels/456"}, policies[0].NotificationChannels)
+	assert.Contains(t, policies[0].Conditions[0].GetConditionMonitoringQueryLanguage().Query, "val() > 300 's'")
+}
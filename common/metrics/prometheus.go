@@ -0,0 +1,86 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const prometheusNamespace = "spanner_migration_tool"
+
+// Prometheus metrics of migrations, which the web UI server exposes on
+// /metrics so that teams running Spanner migration tool as a service can
+// alert on failures.
+var (
+	// JobsCreated counts the migrations started, by command.
+	JobsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Name:      "jobs_created_total",
+		Help:      "Number of migrations started.",
+	}, []string{"command"})
+	// JobsFailed counts the migrations which failed, by command.
+	JobsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Name:      "jobs_failed_total",
+		Help:      "Number of migrations which failed.",
+	}, []string{"command"})
+	// StepLatency is the duration of the steps of migrations, such as the
+	// creation of the database or the data conversion.
+	StepLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: prometheusNamespace,
+		Name:      "step_duration_seconds",
+		Help:      "Duration of the steps of migrations.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 4, 10),
+	}, []string{"step"})
+	// MetadataDbLatency is the duration of the operations on the metadata
+	// database.
+	MetadataDbLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: prometheusNamespace,
+		Name:      "metadata_db_operation_duration_seconds",
+		Help:      "Duration of the operations on the metadata database.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+	// ConversionErrors counts the schema and data conversions which failed, by
+	// source driver and conversion ("schema" or "data").
+	ConversionErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Name:      "conversion_errors_total",
+		Help:      "Number of schema and data conversions which failed.",
+	}, []string{"driver", "conversion"})
+)
+
+// ObserveStep records the duration of the migration step started at start.
+// It is meant to be deferred:
+//
+//	defer metrics.ObserveStep("DataConv", time.Now())
+func ObserveStep(step string, start time.Time) {
+	StepLatency.WithLabelValues(step).Observe(time.Since(start).Seconds())
+}
+
+// ObserveMetadataDbOperation records the duration of the operation on the
+// metadata database started at start.
+func ObserveMetadataDbOperation(operation string, start time.Time) {
+	MetadataDbLatency.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// PrometheusHandler serves the metrics in the Prometheus exposition format.
+func PrometheusHandler() http.Handler {
+	return promhttp.Handler()
+}
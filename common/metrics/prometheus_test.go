@@ -0,0 +1,41 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	JobsCreated.WithLabelValues("schema").Inc()
+	JobsFailed.WithLabelValues("schema").Inc()
+	ObserveStep("CreateOrUpdateDatabase", time.Now().Add(-time.Second))
+	ObserveMetadataDbOperation("SaveTask", time.Now())
+	ConversionErrors.WithLabelValues("mysql", "schema").Inc()
+
+	rr := httptest.NewRecorder()
+	PrometheusHandler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, 200, rr.Code)
+	body := rr.Body.String()
+	assert.Contains(t, body, `spanner_migration_tool_jobs_created_total{command="schema"} 1`)
+	assert.Contains(t, body, `spanner_migration_tool_jobs_failed_total{command="schema"} 1`)
+	assert.Contains(t, body, `spanner_migration_tool_step_duration_seconds_count{step="CreateOrUpdateDatabase"} 1`)
+	assert.Contains(t, body, `spanner_migration_tool_metadata_db_operation_duration_seconds_count{operation="SaveTask"} 1`)
+	assert.Contains(t, body, `spanner_migration_tool_conversion_errors_total{conversion="schema",driver="mysql"} 1`)
+}
@@ -0,0 +1,194 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package settings holds the deployment settings of Spanner migration tool,
+// such as the Dataflow templates and the default machine type of the jobs.
+// Every setting has a built-in default, which can be overridden at runtime by
+// a JSON config file named by the SMT_CONFIG_FILE environment variable and
+// by an environment variable of its own, which takes precedence. This lets
+// private and air-gapped deployments use mirrored templates without
+// rebuilding the tool.
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CONFIG_FILE_ENV is the environment variable naming the config file. The
+// file is a JSON object from the names of the settings to their values, e.g.
+//
+//	{"datastreamToSpannerTemplate": "gs://mirror/flex/Cloud_Datastream_to_Spanner"}
+const CONFIG_FILE_ENV = "SMT_CONFIG_FILE"
+
+// Setting is a named configuration value.
+type Setting struct {
+	// Name is the key of the setting in the config file.
+	Name string
+	// Env is the environment variable overriding the setting.
+	Env     string
+	Default string
+	// validate, if set, checks the values given in the config file or the
+	// environment.
+	validate func(string) error
+}
+
+var (
+	gcsPathRegex      = regexp.MustCompile(`^gs://[^/]+/.+`)
+	bucketPrefixRegex = regexp.MustCompile(`^[a-z0-9][-a-z0-9]{0,19}$`)
+)
+
+func validateGcsPath(v string) error {
+	if !gcsPathRegex.MatchString(v) {
+		return fmt.Errorf("%q is not a gcs path", v)
+	}
+	return nil
+}
+
+// Generated bucket names are the prefix followed by the migration id, which
+// has up to 40 characters, and must fit in the 63 characters allowed by
+// Cloud Storage.
+func validateBucketPrefix(v string) error {
+	if v != "" && !bucketPrefixRegex.MatchString(v) {
+		return fmt.Errorf("%q must have at most 20 characters, start with a lowercase letter or digit and only have lowercase letters, digits and hyphens", v)
+	}
+	return nil
+}
+
+// The settings of the tool.
+var (
+	// DATASTREAM_TO_SPANNER_TEMPLATE is the gcs path of the flex template of
+	// the forward migration job.
+	DATASTREAM_TO_SPANNER_TEMPLATE = register(Setting{
+		Name:     "datastreamToSpannerTemplate",
+		Env:      "SMT_DATASTREAM_TO_SPANNER_TEMPLATE",
+		Default:  "gs://dataflow-templates-southamerica-west1/2023-09-12-00_RC00/flex/Cloud_Datastream_to_Spanner",
+		validate: validateGcsPath,
+	})
+	// ORDERING_TEMPLATE is the gcs path of the flex template of the ordering
+	// job of reverse replication.
+	ORDERING_TEMPLATE = register(Setting{
+		Name:     "orderingTemplate",
+		Env:      "SMT_ORDERING_TEMPLATE",
+		Default:  "gs://dataflow-templates/2023-10-12-00_RC00/flex/Spanner_Change_Streams_to_Sink",
+		validate: validateGcsPath,
+	})
+	// WRITER_TEMPLATE is the gcs path of the flex template of the writer job
+	// of reverse replication.
+	WRITER_TEMPLATE = register(Setting{
+		Name:     "writerTemplate",
+		Env:      "SMT_WRITER_TEMPLATE",
+		Default:  "gs://dataflow-templates/2023-10-12-00_RC00/flex/Ordered_Changestream_Buffer_to_Sourcedb",
+		validate: validateGcsPath,
+	})
+	// REVERSE_REPLICATION_MACHINE_TYPE is the default machine type of the
+	// workers of the reverse replication jobs.
+	REVERSE_REPLICATION_MACHINE_TYPE = register(Setting{
+		Name:    "reverseReplicationMachineType",
+		Env:     "SMT_REVERSE_REPLICATION_MACHINE_TYPE",
+		Default: "n2-standard-4",
+	})
+	// BUCKET_NAME_PREFIX is prepended to the names of the buckets created for
+	// the migrations, e.g. to match the naming policy of an organization.
+	BUCKET_NAME_PREFIX = register(Setting{
+		Name:     "bucketNamePrefix",
+		Env:      "SMT_BUCKET_NAME_PREFIX",
+		Default:  "",
+		validate: validateBucketPrefix,
+	})
+)
+
+var (
+	registry = map[string]Setting{}
+
+	loadOnce sync.Once
+	loadErr  error
+	// values holds the overridden settings, by name.
+	values map[string]string
+)
+
+func register(s Setting) Setting {
+	if _, ok := registry[s.Name]; ok {
+		panic(fmt.Sprintf("setting %s registered twice", s.Name))
+	}
+	registry[s.Name] = s
+	return s
+}
+
+// Load reads the overrides of the settings from the config file and the
+// environment. It is called on the first Get, callers only need to call it to
+// report an invalid config file or override at startup. The settings keep
+// their defaults if it fails.
+func Load() error {
+	loadOnce.Do(func() {
+		values, loadErr = load(os.Getenv(CONFIG_FILE_ENV), os.Getenv)
+	})
+	return loadErr
+}
+
+// Get returns the value of s.
+func Get(s Setting) string {
+	Load()
+	if v, ok := values[s.Name]; ok {
+		return v
+	}
+	return s.Default
+}
+
+// load returns the overridden settings, from the config file at path if not
+// empty and from the environment variables given by getenv.
+func load(path string, getenv func(string) string) (map[string]string, error) {
+	overrides := map[string]string{}
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("can't read the config file %s: %v", path, err)
+		}
+		if err := json.Unmarshal(content, &overrides); err != nil {
+			return nil, fmt.Errorf("can't parse the config file %s, it must be a JSON object of string settings: %v", path, err)
+		}
+		for name := range overrides {
+			if _, ok := registry[name]; !ok {
+				return nil, fmt.Errorf("unknown setting %s in the config file %s, the settings are: %s", name, path, strings.Join(names(), ", "))
+			}
+		}
+	}
+	for _, s := range registry {
+		if v := getenv(s.Env); v != "" {
+			overrides[s.Name] = v
+		}
+	}
+	for name, v := range overrides {
+		if s := registry[name]; s.validate != nil {
+			if err := s.validate(v); err != nil {
+				return nil, fmt.Errorf("invalid setting %s: %v", name, err)
+			}
+		}
+	}
+	return overrides, nil
+}
+
+func names() []string {
+	var n []string
+	for name := range registry {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return n
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	env := map[string]string{}
+	getenv := func(k string) string { return env[k] }
+
+	overrides, err := load("", getenv)
+	assert.Nil(t, err)
+	assert.Empty(t, overrides)
+
+	path := writeConfigFile(t, `{"orderingTemplate": "gs://mirror/flex/ordering", "writerTemplate": "gs://mirror/flex/writer"}`)
+	env["SMT_WRITER_TEMPLATE"] = "gs://other-mirror/flex/writer"
+	env["SMT_BUCKET_NAME_PREFIX"] = "acme"
+	overrides, err = load(path, getenv)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"orderingTemplate": "gs://mirror/flex/ordering",
+		"writerTemplate":   "gs://other-mirror/flex/writer",
+		"bucketNamePrefix": "acme",
+	}, overrides)
+}
+
+func TestLoadErrors(t *testing.T) {
+	noEnv := func(string) string { return "" }
+	tests := []struct {
+		name    string
+		path    string
+		getenv  func(string) string
+		wantErr string
+	}{
+		{
+			name:    "missing file",
+			path:    filepath.Join(t.TempDir(), "missing.json"),
+			getenv:  noEnv,
+			wantErr: "can't read the config file",
+		},
+		{
+			name:    "not an object of strings",
+			path:    writeConfigFile(t, `{"orderingTemplate": 1}`),
+			getenv:  noEnv,
+			wantErr: "can't parse the config file",
+		},
+		{
+			name:    "unknown setting",
+			path:    writeConfigFile(t, `{"orderingTemplates": "gs://mirror/flex/ordering"}`),
+			getenv:  noEnv,
+			wantErr: "unknown setting orderingTemplates",
+		},
+		{
+			name:    "local template",
+			path:    writeConfigFile(t, `{"orderingTemplate": "/templates/ordering"}`),
+			getenv:  noEnv,
+			wantErr: `invalid setting orderingTemplate: "/templates/ordering" is not a gcs path`,
+		},
+		{
+			name: "invalid bucket prefix in the environment",
+			getenv: func(k string) string {
+				if k == "SMT_BUCKET_NAME_PREFIX" {
+					return "Acme_"
+				}
+				return ""
+			},
+			wantErr: "invalid setting bucketNamePrefix",
+		},
+	}
+	for _, tc := range tests {
+		_, err := load(tc.path, tc.getenv)
+		if assert.NotNil(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.wantErr, tc.name)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	assert.Nil(t, Load())
+	values = map[string]string{"writerTemplate": "gs://mirror/flex/writer"}
+	defer func() { values = nil }()
+	assert.Equal(t, "gs://mirror/flex/writer", Get(WRITER_TEMPLATE))
+	assert.Equal(t, ORDERING_TEMPLATE.Default, Get(ORDERING_TEMPLATE))
+}
@@ -0,0 +1,308 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/dataflowjobs"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SimulatedClock is a clock which only moves when advanced, and by its step on
+// every reading, so that code polling the simulated jobs sees them progress.
+type SimulatedClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+// NewSimulatedClock returns a clock reading start, moving by step on every
+// reading.
+func NewSimulatedClock(start time.Time, step time.Duration) *SimulatedClock {
+	return &SimulatedClock{now: start, step: step}
+}
+
+// Now returns the time of the clock, then moves it by its step.
+func (c *SimulatedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+// Advance moves the clock by d.
+func (c *SimulatedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// SimulatedDataflowAccessor is a set of Dataflow jobs implementing
+// dataflowjobs.DataflowAccessor whose jobs go through the lifecycle of
+// Dataflow jobs as its clock moves. A launched job is QUEUED for
+// QueueDuration, then RUNNING until it is stopped or, if RunDuration is set,
+// DONE after RunDuration. A job listed in JobFailures is FAILED instead, after
+// running for the given duration. Cancelling or draining a job moves it to
+// CANCELLING or DRAINING for StopDuration, then to CANCELLED or DRAINED.
+//
+// The durations are those set when the job is launched. Together with the
+// errors and latency injected per call, they make the status, pause and
+// resume, and retry logic built on the accessor testable deterministically.
+type SimulatedDataflowAccessor struct {
+	mu    sync.Mutex
+	Clock *SimulatedClock
+
+	QueueDuration time.Duration
+	RunDuration   time.Duration
+	StopDuration  time.Duration
+	// JobFailures holds the time the jobs of a name run before they fail,
+	// keyed by job name.
+	JobFailures map[string]time.Duration
+	// Latency is waited, in real time, before every call returns. A call
+	// whose context is done first returns the error of the context.
+	Latency time.Duration
+	// Errors returned by the calls, keyed as the Errors of
+	// FakeDataflowAccessor. The errors of a key are returned in order, one per
+	// call, after which the calls succeed, e.g. to check that a call failing
+	// with a transient error is retried.
+	Errors map[string][]error
+	// Launches holds every launch request, including the validations and the
+	// failed launches, in order.
+	Launches []*dataflowpb.LaunchFlexTemplateRequest
+
+	jobs map[string]*simulatedJob
+	// Ids of the jobs, in the order they were launched.
+	order []string
+}
+
+var _ dataflowjobs.DataflowAccessor = (*SimulatedDataflowAccessor)(nil)
+
+// simulatedJob is a launched job, with the times of the steps of its
+// lifecycle.
+type simulatedJob struct {
+	job      *dataflowpb.Job
+	launched time.Time
+	running  time.Time
+	// Time the job finishes by itself in endState, if endState is set.
+	end      time.Time
+	endState dataflowpb.JobState
+	// Time the job was requested to stop in stopState, if stopState is set.
+	stopRequested time.Time
+	stopState     dataflowpb.JobState
+	stopDuration  time.Duration
+}
+
+// stateAt returns the state of the job at t, and the time it entered it.
+func (j *simulatedJob) stateAt(t time.Time) (dataflowpb.JobState, time.Time) {
+	stopping := j.stopState != dataflowpb.JobState_JOB_STATE_UNKNOWN
+	switch {
+	case stopping && !t.Before(j.stopRequested.Add(j.stopDuration)):
+		return j.stopState, j.stopRequested.Add(j.stopDuration)
+	case stopping && j.stopState == dataflowpb.JobState_JOB_STATE_DRAINED:
+		return dataflowpb.JobState_JOB_STATE_DRAINING, j.stopRequested
+	case stopping:
+		return dataflowpb.JobState_JOB_STATE_CANCELLING, j.stopRequested
+	case j.endState != dataflowpb.JobState_JOB_STATE_UNKNOWN && !t.Before(j.end):
+		return j.endState, j.end
+	case t.Before(j.running):
+		return dataflowpb.JobState_JOB_STATE_QUEUED, j.launched
+	}
+	return dataflowpb.JobState_JOB_STATE_RUNNING, j.running
+}
+
+// snapshot returns a copy of the job in its state at t.
+func (j *simulatedJob) snapshot(t time.Time) *dataflowpb.Job {
+	job := proto.Clone(j.job).(*dataflowpb.Job)
+	state, since := j.stateAt(t)
+	job.CurrentState = state
+	job.CurrentStateTime = timestamppb.New(since)
+	if !t.Before(j.running) {
+		job.StartTime = timestamppb.New(j.running)
+	}
+	return job
+}
+
+// NewSimulatedDataflowAccessor returns an accessor without any job, whose jobs
+// move with clock.
+func NewSimulatedDataflowAccessor(clock *SimulatedClock) *SimulatedDataflowAccessor {
+	return &SimulatedDataflowAccessor{
+		Clock:       clock,
+		JobFailures: make(map[string]time.Duration),
+		Errors:      make(map[string][]error),
+		jobs:        make(map[string]*simulatedJob),
+	}
+}
+
+// call waits for the latency of the calls, then returns the next error
+// injected for key, if any.
+func (f *SimulatedDataflowAccessor) call(ctx context.Context, key string) error {
+	if f.Latency > 0 {
+		select {
+		case <-time.After(f.Latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	errs := f.Errors[key]
+	if len(errs) == 0 {
+		return nil
+	}
+	if len(errs) == 1 {
+		delete(f.Errors, key)
+	} else {
+		f.Errors[key] = errs[1:]
+	}
+	return errs[0]
+}
+
+// GetJobState returns the current state of a job, and false if it does not
+// exist.
+func (f *SimulatedDataflowAccessor) GetJobState(jobId string) (dataflowpb.JobState, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	j, ok := f.jobs[jobId]
+	if !ok {
+		return dataflowpb.JobState_JOB_STATE_UNKNOWN, false
+	}
+	state, _ := j.stateAt(f.Clock.Now())
+	return state, true
+}
+
+// LaunchFlexTemplate records req and, unless it only validates the launch,
+// adds a QUEUED job. As with Dataflow, launching a job with the name of an
+// active job fails.
+func (f *SimulatedDataflowAccessor) LaunchFlexTemplate(ctx context.Context, req *dataflowpb.LaunchFlexTemplateRequest) (*dataflowpb.LaunchFlexTemplateResponse, error) {
+	f.mu.Lock()
+	f.Launches = append(f.Launches, proto.Clone(req).(*dataflowpb.LaunchFlexTemplateRequest))
+	f.mu.Unlock()
+	name := req.GetLaunchParameter().GetJobName()
+	if err := f.call(ctx, "LaunchFlexTemplate "+name); err != nil {
+		return nil, err
+	}
+	if req.ValidateOnly {
+		return &dataflowpb.LaunchFlexTemplateResponse{}, nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.Clock.Now()
+	for _, j := range f.jobs {
+		if state, _ := j.stateAt(now); j.job.Name == name && !dataflowjobs.IsTerminalJobState(state) {
+			return nil, status.Errorf(codes.AlreadyExists, "dataflow job %s is already running", name)
+		}
+	}
+	j := &simulatedJob{
+		job: &dataflowpb.Job{
+			Id:         fmt.Sprintf("simulated-job-%d", len(f.order)+1),
+			ProjectId:  req.ProjectId,
+			Location:   req.Location,
+			Name:       name,
+			CreateTime: timestamppb.New(now),
+			Labels:     req.GetLaunchParameter().GetEnvironment().GetAdditionalUserLabels(),
+		},
+		launched:     now,
+		running:      now.Add(f.QueueDuration),
+		stopDuration: f.StopDuration,
+	}
+	if d, ok := f.JobFailures[name]; ok {
+		j.end, j.endState = j.running.Add(d), dataflowpb.JobState_JOB_STATE_FAILED
+	} else if f.RunDuration > 0 {
+		j.end, j.endState = j.running.Add(f.RunDuration), dataflowpb.JobState_JOB_STATE_DONE
+	}
+	f.jobs[j.job.Id] = j
+	f.order = append(f.order, j.job.Id)
+	return &dataflowpb.LaunchFlexTemplateResponse{Job: j.snapshot(now)}, nil
+}
+
+// ListJobs returns the jobs of the project and location in the order they were
+// launched, without their labels as Dataflow does.
+func (f *SimulatedDataflowAccessor) ListJobs(ctx context.Context, projectId, location string) ([]*dataflowpb.Job, error) {
+	if err := f.call(ctx, fmt.Sprintf("ListJobs %s/%s", projectId, location)); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.Clock.Now()
+	var jobs []*dataflowpb.Job
+	for _, id := range f.order {
+		j := f.jobs[id]
+		if j.job.ProjectId != projectId || j.job.Location != location {
+			continue
+		}
+		listed := j.snapshot(now)
+		listed.Labels = nil
+		jobs = append(jobs, listed)
+	}
+	return jobs, nil
+}
+
+// GetJob returns a job with its labels.
+func (f *SimulatedDataflowAccessor) GetJob(ctx context.Context, projectId, location, jobId string) (*dataflowpb.Job, error) {
+	if err := f.call(ctx, "GetJob "+jobId); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	j, ok := f.jobs[jobId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "dataflow job %s not found", jobId)
+	}
+	return j.snapshot(f.Clock.Now()), nil
+}
+
+// UpdateJobState requests a job to be cancelled or drained. As with Dataflow,
+// a job which already stopped can't be updated, and a draining job can still
+// be cancelled.
+func (f *SimulatedDataflowAccessor) UpdateJobState(ctx context.Context, projectId, location, jobId string, state dataflowpb.JobState) error {
+	if err := f.call(ctx, "UpdateJobState "+jobId); err != nil {
+		return err
+	}
+	if state != dataflowpb.JobState_JOB_STATE_CANCELLED && state != dataflowpb.JobState_JOB_STATE_DRAINED {
+		return status.Errorf(codes.InvalidArgument, "dataflow jobs can only be cancelled or drained, not moved to %s", state)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	j, ok := f.jobs[jobId]
+	if !ok {
+		return status.Errorf(codes.NotFound, "dataflow job %s not found", jobId)
+	}
+	now := f.Clock.Now()
+	current, _ := j.stateAt(now)
+	switch {
+	case dataflowjobs.IsTerminalJobState(current):
+		return status.Errorf(codes.FailedPrecondition, "dataflow job %s is in terminal state %s", jobId, current)
+	case current == dataflowpb.JobState_JOB_STATE_CANCELLING:
+		return nil
+	case current == dataflowpb.JobState_JOB_STATE_DRAINING && state == dataflowpb.JobState_JOB_STATE_DRAINED:
+		return nil
+	}
+	j.stopRequested, j.stopState = now, state
+	return nil
+}
+
+// Close does nothing, the jobs are kept.
+func (f *SimulatedDataflowAccessor) Close() error {
+	return nil
+}
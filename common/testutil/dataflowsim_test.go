@@ -0,0 +1,150 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func getSimulatedLaunchRequest(name string) *dataflowpb.LaunchFlexTemplateRequest {
+	return &dataflowpb.LaunchFlexTemplateRequest{
+		ProjectId: "p",
+		Location:  "us-central1",
+		LaunchParameter: &dataflowpb.LaunchFlexTemplateParameter{
+			JobName:     name,
+			Environment: &dataflowpb.FlexTemplateRuntimeEnvironment{AdditionalUserLabels: map[string]string{"team": "db"}},
+		},
+	}
+}
+
+func TestSimulatedDataflowAccessorLifecycle(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start, 0)
+	df := NewSimulatedDataflowAccessor(clock)
+	df.QueueDuration = time.Minute
+	df.RunDuration = time.Hour
+	df.StopDuration = 5 * time.Minute
+	df.JobFailures["smt-writer"] = 10 * time.Minute
+
+	ordering, err := df.LaunchFlexTemplate(ctx, getSimulatedLaunchRequest("smt-ordering"))
+	assert.Nil(t, err)
+	assert.Equal(t, dataflowpb.JobState_JOB_STATE_QUEUED, ordering.Job.CurrentState)
+	writer, err := df.LaunchFlexTemplate(ctx, getSimulatedLaunchRequest("smt-writer"))
+	assert.Nil(t, err)
+	batch, err := df.LaunchFlexTemplate(ctx, getSimulatedLaunchRequest("smt-reprocess"))
+	assert.Nil(t, err)
+	_, err = df.LaunchFlexTemplate(ctx, getSimulatedLaunchRequest("smt-ordering"))
+	assert.True(t, gcp.IsAlreadyExists(err))
+	assert.Equal(t, 4, len(df.Launches))
+
+	clock.Advance(time.Minute)
+	job, err := df.GetJob(ctx, "p", "us-central1", ordering.Job.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, dataflowpb.JobState_JOB_STATE_RUNNING, job.CurrentState)
+	assert.Equal(t, start.Add(time.Minute), job.StartTime.AsTime())
+	assert.Equal(t, map[string]string{"team": "db"}, job.Labels)
+
+	// The writer job fails after running for 10 minutes.
+	clock.Advance(10 * time.Minute)
+	state, _ := df.GetJobState(writer.Job.Id)
+	assert.Equal(t, dataflowpb.JobState_JOB_STATE_FAILED, state)
+	assert.NotNil(t, df.UpdateJobState(ctx, "p", "us-central1", writer.Job.Id, dataflowpb.JobState_JOB_STATE_CANCELLED))
+	// A failed job can be relaunched.
+	_, err = df.LaunchFlexTemplate(ctx, getSimulatedLaunchRequest("smt-writer"))
+	assert.Nil(t, err)
+
+	// Draining takes StopDuration, and can be turned into a cancellation.
+	assert.Nil(t, df.UpdateJobState(ctx, "p", "us-central1", ordering.Job.Id, dataflowpb.JobState_JOB_STATE_DRAINED))
+	state, _ = df.GetJobState(ordering.Job.Id)
+	assert.Equal(t, dataflowpb.JobState_JOB_STATE_DRAINING, state)
+	clock.Advance(time.Minute)
+	assert.Nil(t, df.UpdateJobState(ctx, "p", "us-central1", ordering.Job.Id, dataflowpb.JobState_JOB_STATE_CANCELLED))
+	state, _ = df.GetJobState(ordering.Job.Id)
+	assert.Equal(t, dataflowpb.JobState_JOB_STATE_CANCELLING, state)
+	clock.Advance(5 * time.Minute)
+	state, _ = df.GetJobState(ordering.Job.Id)
+	assert.Equal(t, dataflowpb.JobState_JOB_STATE_CANCELLED, state)
+
+	// Without a failure, a job is done after RunDuration.
+	clock.Advance(time.Hour)
+	jobs, err := df.ListJobs(ctx, "p", "us-central1")
+	assert.Nil(t, err)
+	var states []dataflowpb.JobState
+	for _, job := range jobs {
+		assert.Nil(t, job.Labels)
+		if job.Id == batch.Job.Id {
+			assert.Equal(t, start.Add(61*time.Minute), job.CurrentStateTime.AsTime())
+		}
+		states = append(states, job.CurrentState)
+	}
+	assert.Equal(t, []dataflowpb.JobState{
+		dataflowpb.JobState_JOB_STATE_CANCELLED,
+		dataflowpb.JobState_JOB_STATE_FAILED,
+		dataflowpb.JobState_JOB_STATE_DONE,
+		dataflowpb.JobState_JOB_STATE_FAILED,
+	}, states)
+	jobs, err = df.ListJobs(ctx, "p", "europe-west1")
+	assert.Nil(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestSimulatedDataflowAccessorInjection(t *testing.T) {
+	ctx := context.Background()
+	// Every reading moves the clock, as polling code would see it.
+	clock := NewSimulatedClock(time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), time.Minute)
+	df := NewSimulatedDataflowAccessor(clock)
+	df.QueueDuration = 2 * time.Minute
+
+	// The injected errors are returned once each, in order.
+	unavailable := status.Error(codes.Unavailable, "try again")
+	df.Errors["LaunchFlexTemplate smt-writer"] = []error{unavailable, unavailable}
+	var resp *dataflowpb.LaunchFlexTemplateResponse
+	var err error
+	attempts := 0
+	for attempts = 1; attempts <= 3; attempts++ {
+		if resp, err = df.LaunchFlexTemplate(ctx, getSimulatedLaunchRequest("smt-writer")); err == nil {
+			break
+		}
+		assert.True(t, gcp.IsRetryable(err))
+	}
+	assert.Nil(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 3, len(df.Launches))
+	assert.Empty(t, df.Errors)
+
+	state, _ := df.GetJobState(resp.Job.Id)
+	assert.Equal(t, dataflowpb.JobState_JOB_STATE_QUEUED, state)
+	state, _ = df.GetJobState(resp.Job.Id)
+	assert.Equal(t, dataflowpb.JobState_JOB_STATE_RUNNING, state)
+	assert.NotNil(t, df.UpdateJobState(ctx, "p", "us-central1", resp.Job.Id, dataflowpb.JobState_JOB_STATE_RUNNING))
+	_, err = df.GetJob(ctx, "p", "us-central1", "missing")
+	assert.True(t, gcp.IsNotFound(err))
+
+	// The calls outlasting their context fail.
+	df.Latency = time.Minute
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = df.ListJobs(timeoutCtx, "p", "us-central1")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,476 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testutil provides in-memory fakes of the accessors through which
+// the tool reaches Google Cloud, so that code built on the tool can be unit
+// tested without credentials:
+//
+//   - FakeStorageClient implements artifacts.StorageAccessor and
+//     artifacts.BucketIamAccessor.
+//   - FakeSpannerAdmin implements conversion.InstanceScaler.
+//   - FakeDataflowAccessor implements dataflowjobs.DataflowAccessor, and
+//     exposes its jobs as streaming.Resource.
+//   - SimulatedDataflowAccessor implements dataflowjobs.DataflowAccessor with
+//     jobs going through the Dataflow lifecycle as a SimulatedClock moves.
+//
+// The fakes are safe for concurrent use. Errors can be injected per call
+// through their Errors maps.
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"cloud.google.com/go/iam"
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/artifacts"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/dataflowjobs"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/streaming"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// FakeStorageClient is an in-memory StorageAccessor holding buckets of
+// objects.
+type FakeStorageClient struct {
+	mu       sync.Mutex
+	buckets  map[string]map[string]fakeObject
+	policies map[string]*iampb.Policy
+	// Number of policies set, from which their etags are made.
+	policyUpdates int
+	// Errors returned by the calls, keyed by "<method> <bucket>/<name>", e.g.
+	// "DeleteObject my-bucket/reports/a.txt" or "ListObjects my-bucket/reports/",
+	// or by "<method> <bucket>" for the calls on buckets, e.g.
+	// "SetBucketIamPolicy my-bucket".
+	Errors map[string]error
+}
+
+type fakeObject struct {
+	data    []byte
+	created time.Time
+}
+
+var _ artifacts.StorageAccessor = (*FakeStorageClient)(nil)
+var _ artifacts.BucketIamAccessor = (*FakeStorageClient)(nil)
+
+// NewFakeStorageClient returns a storage client without any bucket.
+func NewFakeStorageClient() *FakeStorageClient {
+	return &FakeStorageClient{buckets: make(map[string]map[string]fakeObject), policies: make(map[string]*iampb.Policy), Errors: make(map[string]error)}
+}
+
+// PutObject stores an object, creating its bucket if needed.
+func (f *FakeStorageClient) PutObject(bucket, name string, data []byte, created time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.buckets[bucket] == nil {
+		f.buckets[bucket] = make(map[string]fakeObject)
+	}
+	f.buckets[bucket][name] = fakeObject{data: data, created: created}
+}
+
+// GetObject returns the content of an object, and false if it does not exist.
+func (f *FakeStorageClient) GetObject(bucket, name string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o, ok := f.buckets[bucket][name]
+	return o.data, ok
+}
+
+// ListObjects returns the objects in bucket whose name starts with prefix,
+// ordered by name.
+func (f *FakeStorageClient) ListObjects(ctx context.Context, bucket, prefix string) ([]artifacts.Object, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors[fmt.Sprintf("ListObjects %s/%s", bucket, prefix)]; err != nil {
+		return nil, err
+	}
+	var objects []artifacts.Object
+	for name, o := range f.buckets[bucket] {
+		if strings.HasPrefix(name, prefix) {
+			objects = append(objects, artifacts.Object{Bucket: bucket, Name: name, Size: int64(len(o.data)), Created: o.created})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, nil
+}
+
+// DeleteObject deletes a single object. As with Cloud Storage, deleting an
+// object which does not exist is not an error.
+func (f *FakeStorageClient) DeleteObject(ctx context.Context, bucket, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors[fmt.Sprintf("DeleteObject %s/%s", bucket, name)]; err != nil {
+		return err
+	}
+	delete(f.buckets[bucket], name)
+	return nil
+}
+
+// ReadObject returns the content of an object.
+func (f *FakeStorageClient) ReadObject(ctx context.Context, bucket, name string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors[fmt.Sprintf("ReadObject %s/%s", bucket, name)]; err != nil {
+		return nil, err
+	}
+	o, ok := f.buckets[bucket][name]
+	if !ok {
+		return nil, fmt.Errorf("gs://%s/%s: %w", bucket, name, storage.ErrObjectNotExist)
+	}
+	return append([]byte(nil), o.data...), nil
+}
+
+// CopyObject copies an object, possibly to another bucket.
+func (f *FakeStorageClient) CopyObject(ctx context.Context, srcBucket, srcName, dstBucket, dstName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors[fmt.Sprintf("CopyObject %s/%s", srcBucket, srcName)]; err != nil {
+		return err
+	}
+	o, ok := f.buckets[srcBucket][srcName]
+	if !ok {
+		return fmt.Errorf("gs://%s/%s: %w", srcBucket, srcName, storage.ErrObjectNotExist)
+	}
+	if f.buckets[dstBucket] == nil {
+		f.buckets[dstBucket] = make(map[string]fakeObject)
+	}
+	f.buckets[dstBucket][dstName] = fakeObject{data: append([]byte(nil), o.data...), created: time.Now()}
+	return nil
+}
+
+// UploadObject stores the content of r in an object.
+func (f *FakeStorageClient) UploadObject(ctx context.Context, bucket, name string, r io.Reader) (int64, error) {
+	if err := f.getError(fmt.Sprintf("UploadObject %s/%s", bucket, name)); err != nil {
+		return 0, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return int64(len(data)), err
+	}
+	f.PutObject(bucket, name, data, time.Now())
+	return int64(len(data)), nil
+}
+
+// GenerateSignedURL returns a fake URL of an object, which needs not exist,
+// carrying its validity as X-Goog-Expires.
+func (f *FakeStorageClient) GenerateSignedURL(bucket, name string, ttl time.Duration) (string, error) {
+	if err := f.getError(fmt.Sprintf("GenerateSignedURL %s/%s", bucket, name)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s?X-Goog-Expires=%d", bucket, name, int64(ttl.Seconds())), nil
+}
+
+// GetBucketIamPolicy returns the IAM policy of a bucket, which has no
+// bindings until a policy is set.
+func (f *FakeStorageClient) GetBucketIamPolicy(ctx context.Context, bucket string) (*iam.Policy, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["GetBucketIamPolicy "+bucket]; err != nil {
+		return nil, err
+	}
+	p, ok := f.policies[bucket]
+	if !ok {
+		return &iam.Policy{InternalProto: &iampb.Policy{}}, nil
+	}
+	return &iam.Policy{InternalProto: proto.Clone(p).(*iampb.Policy)}, nil
+}
+
+// SetBucketIamPolicy replaces the IAM policy of a bucket. As with Cloud
+// Storage, it fails with 412 Precondition Failed if the policy was replaced
+// since it was read.
+func (f *FakeStorageClient) SetBucketIamPolicy(ctx context.Context, bucket string, policy *iam.Policy) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["SetBucketIamPolicy "+bucket]; err != nil {
+		return err
+	}
+	current := f.policies[bucket]
+	if !bytes.Equal(policy.InternalProto.GetEtag(), current.GetEtag()) {
+		return &googleapi.Error{Code: http.StatusPreconditionFailed, Message: "the policy of gs://" + bucket + " was changed"}
+	}
+	p := &iampb.Policy{}
+	if policy.InternalProto != nil {
+		p = proto.Clone(policy.InternalProto).(*iampb.Policy)
+	}
+	f.policyUpdates++
+	p.Etag = []byte(fmt.Sprintf("etag-%d", f.policyUpdates))
+	f.policies[bucket] = p
+	return nil
+}
+
+// Close does nothing, the objects are kept.
+func (f *FakeStorageClient) Close() error {
+	return nil
+}
+
+func (f *FakeStorageClient) getError(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Errors[key]
+}
+
+// FakeSpannerAdmin is an in-memory instance admin, implementing
+// conversion.InstanceScaler.
+type FakeSpannerAdmin struct {
+	mu        sync.Mutex
+	instances map[string]int32
+	// Updates holds the processing units set on every instance, in order.
+	Updates map[string][]int32
+	// Errors returned by the calls, keyed by "<method> <instanceURI>", e.g.
+	// "SetProcessingUnits projects/p/instances/i".
+	Errors map[string]error
+}
+
+// NewFakeSpannerAdmin returns an instance admin holding the given instances,
+// keyed by instance URI, with their processing units.
+func NewFakeSpannerAdmin(instances map[string]int32) *FakeSpannerAdmin {
+	f := &FakeSpannerAdmin{instances: make(map[string]int32), Updates: make(map[string][]int32), Errors: make(map[string]error)}
+	for uri, pu := range instances {
+		f.instances[uri] = pu
+	}
+	return f
+}
+
+// GetProcessingUnits returns the processing units of an instance.
+func (f *FakeSpannerAdmin) GetProcessingUnits(ctx context.Context, instanceURI string) (int32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["GetProcessingUnits "+instanceURI]; err != nil {
+		return 0, err
+	}
+	pu, ok := f.instances[instanceURI]
+	if !ok {
+		return 0, fmt.Errorf("instance %s not found", instanceURI)
+	}
+	return pu, nil
+}
+
+// SetProcessingUnits updates the processing units of an instance.
+func (f *FakeSpannerAdmin) SetProcessingUnits(ctx context.Context, instanceURI string, processingUnits int32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["SetProcessingUnits "+instanceURI]; err != nil {
+		return err
+	}
+	if _, ok := f.instances[instanceURI]; !ok {
+		return fmt.Errorf("instance %s not found", instanceURI)
+	}
+	f.instances[instanceURI] = processingUnits
+	f.Updates[instanceURI] = append(f.Updates[instanceURI], processingUnits)
+	return nil
+}
+
+// FakeDataflowAccessor is an in-memory set of Dataflow jobs, keyed by job id,
+// implementing dataflowjobs.DataflowAccessor. Launched jobs start RUNNING, and
+// stop as soon as they are cancelled or drained.
+type FakeDataflowAccessor struct {
+	mu   sync.Mutex
+	jobs map[string]*dataflowpb.Job
+	// Ids of the jobs, in the order they were added.
+	order []string
+	// Launches holds every launch request, including the validations, in
+	// order.
+	Launches []*dataflowpb.LaunchFlexTemplateRequest
+	// Errors returned by the calls, keyed by "<method> <jobId>", e.g.
+	// "Exists 2023-01-01_00_00_00-123" or "Delete 2023-01-01_00_00_00-123",
+	// or by "LaunchFlexTemplate <jobName>" for the launches.
+	Errors map[string]error
+}
+
+var _ dataflowjobs.DataflowAccessor = (*FakeDataflowAccessor)(nil)
+
+// NewFakeDataflowAccessor returns an accessor without any job.
+func NewFakeDataflowAccessor() *FakeDataflowAccessor {
+	return &FakeDataflowAccessor{jobs: make(map[string]*dataflowpb.Job), Errors: make(map[string]error)}
+}
+
+// SetJobState adds a job named after its id, or updates the state of an
+// existing one.
+func (f *FakeDataflowAccessor) SetJobState(jobId string, state dataflowpb.JobState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if job, ok := f.jobs[jobId]; ok {
+		job.CurrentState = state
+		return
+	}
+	f.addJobLocked(&dataflowpb.Job{Id: jobId, Name: jobId, CurrentState: state, CreateTime: timestamppb.Now()})
+}
+
+// GetJobState returns the state of a job, and false if it does not exist.
+func (f *FakeDataflowAccessor) GetJobState(jobId string) (dataflowpb.JobState, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job, ok := f.jobs[jobId]
+	if !ok {
+		return dataflowpb.JobState_JOB_STATE_UNKNOWN, false
+	}
+	return job.CurrentState, true
+}
+
+func (f *FakeDataflowAccessor) addJobLocked(job *dataflowpb.Job) {
+	f.jobs[job.Id] = job
+	f.order = append(f.order, job.Id)
+}
+
+// LaunchFlexTemplate records req and, unless it only validates the launch,
+// adds a RUNNING job. As with Dataflow, launching a job with the name of an
+// active job fails.
+func (f *FakeDataflowAccessor) LaunchFlexTemplate(ctx context.Context, req *dataflowpb.LaunchFlexTemplateRequest) (*dataflowpb.LaunchFlexTemplateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Launches = append(f.Launches, proto.Clone(req).(*dataflowpb.LaunchFlexTemplateRequest))
+	name := req.GetLaunchParameter().GetJobName()
+	if err := f.Errors["LaunchFlexTemplate "+name]; err != nil {
+		return nil, err
+	}
+	if req.ValidateOnly {
+		return &dataflowpb.LaunchFlexTemplateResponse{}, nil
+	}
+	for _, job := range f.jobs {
+		if job.Name == name && !dataflowjobs.IsTerminalJobState(job.CurrentState) {
+			return nil, status.Errorf(codes.AlreadyExists, "dataflow job %s is already running", name)
+		}
+	}
+	job := &dataflowpb.Job{
+		Id:           fmt.Sprintf("fake-job-%d", len(f.order)+1),
+		ProjectId:    req.ProjectId,
+		Location:     req.Location,
+		Name:         name,
+		CurrentState: dataflowpb.JobState_JOB_STATE_RUNNING,
+		CreateTime:   timestamppb.Now(),
+		Labels:       req.GetLaunchParameter().GetEnvironment().GetAdditionalUserLabels(),
+	}
+	f.addJobLocked(job)
+	return &dataflowpb.LaunchFlexTemplateResponse{Job: proto.Clone(job).(*dataflowpb.Job)}, nil
+}
+
+// ListJobs returns the jobs in the order they were added, without their
+// labels as Dataflow does. Jobs added by SetJobState are in every project
+// and location.
+func (f *FakeDataflowAccessor) ListJobs(ctx context.Context, projectId, location string) ([]*dataflowpb.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors[fmt.Sprintf("ListJobs %s/%s", projectId, location)]; err != nil {
+		return nil, err
+	}
+	var jobs []*dataflowpb.Job
+	for _, id := range f.order {
+		job := f.jobs[id]
+		if (job.ProjectId != "" && job.ProjectId != projectId) || (job.Location != "" && job.Location != location) {
+			continue
+		}
+		listed := proto.Clone(job).(*dataflowpb.Job)
+		listed.Labels = nil
+		jobs = append(jobs, listed)
+	}
+	return jobs, nil
+}
+
+// GetJob returns a job with its labels.
+func (f *FakeDataflowAccessor) GetJob(ctx context.Context, projectId, location, jobId string) (*dataflowpb.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["GetJob "+jobId]; err != nil {
+		return nil, err
+	}
+	job, ok := f.jobs[jobId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "dataflow job %s not found", jobId)
+	}
+	return proto.Clone(job).(*dataflowpb.Job), nil
+}
+
+// UpdateJobState moves a job to state right away.
+func (f *FakeDataflowAccessor) UpdateJobState(ctx context.Context, projectId, location, jobId string, state dataflowpb.JobState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["UpdateJobState "+jobId]; err != nil {
+		return err
+	}
+	job, ok := f.jobs[jobId]
+	if !ok {
+		return status.Errorf(codes.NotFound, "dataflow job %s not found", jobId)
+	}
+	job.CurrentState = state
+	return nil
+}
+
+// Close does nothing, the jobs are kept.
+func (f *FakeDataflowAccessor) Close() error {
+	return nil
+}
+
+// JobResource returns the job as a streaming.Resource. Deleting it cancels
+// the job, as the cleanup of streaming migrations does.
+func (f *FakeDataflowAccessor) JobResource(jobId string) streaming.Resource {
+	return &fakeJobResource{accessor: f, jobId: jobId}
+}
+
+type fakeJobResource struct {
+	accessor *FakeDataflowAccessor
+	jobId    string
+}
+
+func (r *fakeJobResource) Kind() string     { return streaming.DATAFLOW_JOB_RESOURCE }
+func (r *fakeJobResource) Name() string     { return r.jobId }
+func (r *fakeJobResource) Describe() string { return "Fake Dataflow job " + r.jobId }
+
+func (r *fakeJobResource) Create(ctx context.Context) error {
+	exists, err := r.Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%s %s no longer exists", streaming.DATAFLOW_JOB_RESOURCE, r.jobId)
+	}
+	return nil
+}
+
+func (r *fakeJobResource) Exists(ctx context.Context) (bool, error) {
+	f := r.accessor
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["Exists "+r.jobId]; err != nil {
+		return false, err
+	}
+	job, ok := f.jobs[r.jobId]
+	return ok && !dataflowjobs.IsTerminalJobState(job.CurrentState), nil
+}
+
+func (r *fakeJobResource) Delete(ctx context.Context) error {
+	f := r.accessor
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["Delete "+r.jobId]; err != nil {
+		return err
+	}
+	job, ok := f.jobs[r.jobId]
+	if !ok {
+		return fmt.Errorf("dataflow job %s not found", r.jobId)
+	}
+	job.CurrentState = dataflowpb.JobState_JOB_STATE_CANCELLED
+	return nil
+}
@@ -0,0 +1,243 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"cloud.google.com/go/iam"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/artifacts"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/conversion"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeStorageClient(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	fs := NewFakeStorageClient()
+	fs.PutObject("b", "smt/session.json", []byte("{}"), now.Add(-40*24*time.Hour))
+	fs.PutObject("b", "smt/mydb.report.txt", []byte("report"), now)
+	fs.PutObject("b", "other/session.json", []byte("{}"), now.Add(-40*24*time.Hour))
+
+	objects, err := fs.ListObjects(ctx, "b", "smt/")
+	assert.Nil(t, err)
+	assert.Equal(t, []artifacts.Object{
+		{Bucket: "b", Name: "smt/mydb.report.txt", Size: 6, Created: now},
+		{Bucket: "b", Name: "smt/session.json", Size: 2, Created: now.Add(-40 * 24 * time.Hour)},
+	}, objects)
+
+	c := artifacts.Collector{Storage: fs, Periods: artifacts.RetentionPeriods{artifacts.Session: artifacts.Retention(30 * 24 * time.Hour)}, AuditLog: &bytes.Buffer{}, Now: func() time.Time { return now }}
+	res, err := c.Collect(ctx, "gs://b/smt")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, res.Deleted)
+	_, ok := fs.GetObject("b", "smt/session.json")
+	assert.False(t, ok)
+	_, ok = fs.GetObject("b", "other/session.json")
+	assert.True(t, ok)
+
+	fs.Errors["DeleteObject b/smt/mydb.report.txt"] = fmt.Errorf("permission denied")
+	assert.NotNil(t, fs.DeleteObject(ctx, "b", "smt/mydb.report.txt"))
+	assert.Nil(t, fs.DeleteObject(ctx, "b", "missing"))
+}
+
+func TestFakeSpannerAdmin(t *testing.T) {
+	ctx := context.Background()
+	instanceURI := "projects/p/instances/i"
+	admin := NewFakeSpannerAdmin(map[string]int32{instanceURI: 1000})
+	conv := internal.MakeConv()
+	conv.Audit.MinProcessingUnits = 3000
+
+	assert.Nil(t, conversion.RaiseProcessingUnits(ctx, admin, instanceURI, conv))
+	assert.Nil(t, conversion.RestoreProcessingUnits(ctx, admin, conv))
+	assert.Equal(t, []int32{3000, 1000}, admin.Updates[instanceURI])
+
+	_, err := admin.GetProcessingUnits(ctx, "projects/p/instances/missing")
+	assert.NotNil(t, err)
+	admin.Errors["SetProcessingUnits "+instanceURI] = fmt.Errorf("quota exceeded")
+	assert.NotNil(t, conversion.RaiseProcessingUnits(ctx, admin, instanceURI, conv))
+}
+
+func TestFakeDataflowAccessor(t *testing.T) {
+	ctx := context.Background()
+	df := NewFakeDataflowAccessor()
+	df.SetJobState("job1", dataflowpb.JobState_JOB_STATE_RUNNING)
+	r := df.JobResource("job1")
+
+	exists, err := r.Exists(ctx)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Nil(t, r.Create(ctx))
+	assert.Nil(t, r.Delete(ctx))
+	state, _ := df.GetJobState("job1")
+	assert.Equal(t, dataflowpb.JobState_JOB_STATE_CANCELLED, state)
+	exists, err = r.Exists(ctx)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	assert.NotNil(t, r.Create(ctx))
+
+	assert.NotNil(t, df.JobResource("missing").Delete(ctx))
+	df.Errors["Exists job1"] = fmt.Errorf("permission denied")
+	_, err = r.Exists(ctx)
+	assert.NotNil(t, err)
+}
+
+func TestFakeDataflowAccessorJobs(t *testing.T) {
+	ctx := context.Background()
+	df := NewFakeDataflowAccessor()
+	req := &dataflowpb.LaunchFlexTemplateRequest{
+		ProjectId: "p",
+		Location:  "us-central1",
+		LaunchParameter: &dataflowpb.LaunchFlexTemplateParameter{
+			JobName:     "smt-ordering",
+			Environment: &dataflowpb.FlexTemplateRuntimeEnvironment{AdditionalUserLabels: map[string]string{"team": "db"}},
+		},
+		ValidateOnly: true,
+	}
+	_, err := df.LaunchFlexTemplate(ctx, req)
+	assert.Nil(t, err)
+	jobs, err := df.ListJobs(ctx, "p", "us-central1")
+	assert.Nil(t, err)
+	assert.Empty(t, jobs)
+
+	req.ValidateOnly = false
+	resp, err := df.LaunchFlexTemplate(ctx, req)
+	assert.Nil(t, err)
+	assert.Equal(t, "smt-ordering", resp.Job.Name)
+	assert.Equal(t, 2, len(df.Launches))
+	// A job name can't be reused while the job is running.
+	_, err = df.LaunchFlexTemplate(ctx, req)
+	assert.True(t, gcp.IsAlreadyExists(err))
+
+	jobs, err = df.ListJobs(ctx, "p", "us-central1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(jobs))
+	assert.Equal(t, dataflowpb.JobState_JOB_STATE_RUNNING, jobs[0].CurrentState)
+	assert.Nil(t, jobs[0].Labels)
+	jobs, err = df.ListJobs(ctx, "p", "europe-west1")
+	assert.Nil(t, err)
+	assert.Empty(t, jobs)
+	job, err := df.GetJob(ctx, "p", "us-central1", resp.Job.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"team": "db"}, job.Labels)
+
+	assert.Nil(t, df.UpdateJobState(ctx, "p", "us-central1", resp.Job.Id, dataflowpb.JobState_JOB_STATE_CANCELLED))
+	state, _ := df.GetJobState(resp.Job.Id)
+	assert.Equal(t, dataflowpb.JobState_JOB_STATE_CANCELLED, state)
+	_, err = df.LaunchFlexTemplate(ctx, req)
+	assert.Nil(t, err)
+	assert.True(t, gcp.IsNotFound(df.UpdateJobState(ctx, "p", "us-central1", "missing", dataflowpb.JobState_JOB_STATE_CANCELLED)))
+	df.Errors["LaunchFlexTemplate smt-writer"] = fmt.Errorf("quota exceeded")
+	req.LaunchParameter.JobName = "smt-writer"
+	_, err = df.LaunchFlexTemplate(ctx, req)
+	assert.NotNil(t, err)
+}
+
+func TestFakeStorageClientTransfers(t *testing.T) {
+	ctx := context.Background()
+	fs := NewFakeStorageClient()
+	path := filepath.Join(t.TempDir(), "session.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`{"a": 1}`), 0644))
+
+	n, err := artifacts.UploadFile(ctx, fs, path, "src", "smt/session.json")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(8), n)
+	assert.Nil(t, fs.CopyObject(ctx, "src", "smt/session.json", "backup", "smt/session.json"))
+	data, ok := fs.GetObject("backup", "smt/session.json")
+	assert.True(t, ok)
+	assert.Equal(t, `{"a": 1}`, string(data))
+	data, err = fs.ReadObject(ctx, "backup", "smt/session.json")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"a": 1}`, string(data))
+	_, err = fs.ReadObject(ctx, "backup", "missing.json")
+	assert.True(t, gcp.IsNotFound(err))
+
+	assert.True(t, gcp.IsNotFound(fs.CopyObject(ctx, "src", "missing", "backup", "missing")))
+	fs.Errors["UploadObject src/smt/report.txt"] = fmt.Errorf("permission denied")
+	_, err = fs.UploadObject(ctx, "src", "smt/report.txt", strings.NewReader("report"))
+	assert.NotNil(t, err)
+	_, err = artifacts.UploadFile(ctx, fs, filepath.Join(t.TempDir(), "missing.json"), "src", "missing.json")
+	assert.NotNil(t, err)
+
+	url, err := fs.GenerateSignedURL("src", "smt/session.json", 15*time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://storage.googleapis.com/src/smt/session.json?X-Goog-Expires=900", url)
+	fs.Errors["GenerateSignedURL src/smt/session.json"] = fmt.Errorf("permission denied")
+	_, err = fs.GenerateSignedURL("src", "smt/session.json", 15*time.Minute)
+	assert.NotNil(t, err)
+}
+
+// racingIamAccessor changes the policy of the bucket between the first read
+// and write of a policy, as a concurrent writer would.
+type racingIamAccessor struct {
+	*FakeStorageClient
+	raced bool
+}
+
+func (r *racingIamAccessor) SetBucketIamPolicy(ctx context.Context, bucket string, policy *iam.Policy) error {
+	if !r.raced {
+		r.raced = true
+		if _, err := artifacts.AddBucketReader(ctx, r.FakeStorageClient, bucket, "user:other@example.com"); err != nil {
+			return err
+		}
+	}
+	return r.FakeStorageClient.SetBucketIamPolicy(ctx, bucket, policy)
+}
+
+func TestAddBucketMember(t *testing.T) {
+	ctx := context.Background()
+	fs := NewFakeStorageClient()
+	worker := "serviceAccount:123-compute@developer.gserviceaccount.com"
+
+	granted, err := artifacts.AddBucketMember(ctx, fs, "smt", artifacts.ROLE_OBJECT_ADMIN, worker)
+	assert.Nil(t, err)
+	assert.True(t, granted)
+	granted, err = artifacts.AddBucketMember(ctx, fs, "smt", artifacts.ROLE_OBJECT_ADMIN, worker)
+	assert.Nil(t, err)
+	assert.False(t, granted)
+	granted, err = artifacts.AddBucketReader(ctx, fs, "smt", "user:me@example.com")
+	assert.Nil(t, err)
+	assert.True(t, granted)
+	policy, err := fs.GetBucketIamPolicy(ctx, "smt")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{worker}, policy.Members(artifacts.ROLE_OBJECT_ADMIN))
+	assert.Equal(t, []string{"user:me@example.com"}, policy.Members(artifacts.ROLE_OBJECT_VIEWER))
+
+	// A policy changed since it was read is not overwritten.
+	stale, err := fs.GetBucketIamPolicy(ctx, "racy")
+	assert.Nil(t, err)
+	r := &racingIamAccessor{FakeStorageClient: fs}
+	granted, err = artifacts.AddBucketMember(ctx, r, "racy", artifacts.ROLE_OBJECT_ADMIN, worker)
+	assert.Nil(t, err)
+	assert.True(t, granted)
+	policy, err = fs.GetBucketIamPolicy(ctx, "racy")
+	assert.Nil(t, err)
+	assert.True(t, policy.HasRole(worker, artifacts.ROLE_OBJECT_ADMIN))
+	assert.True(t, policy.HasRole("user:other@example.com", artifacts.ROLE_OBJECT_VIEWER))
+	assert.Equal(t, gcp.CATEGORY_FAILED_PRECONDITION, gcp.GetCategory(fs.SetBucketIamPolicy(ctx, "racy", stale)))
+
+	fs.Errors["SetBucketIamPolicy smt"] = fmt.Errorf("permission denied")
+	_, err = artifacts.AddBucketMember(ctx, fs, "smt", artifacts.ROLE_OBJECT_ADMIN, "user:new@example.com")
+	assert.NotNil(t, err)
+}
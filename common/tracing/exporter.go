@@ -0,0 +1,129 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/cloudtrace/v2"
+)
+
+// Maximum length of span names and attribute values accepted by Cloud Trace.
+const (
+	maxDisplayNameBytes    = 128
+	maxAttributeValueBytes = 256
+)
+
+// spanWriter writes spans to Cloud Trace.
+type spanWriter interface {
+	WriteSpans(ctx context.Context, project string, spans []*cloudtrace.Span) error
+}
+
+type apiSpanWriter struct {
+	service *cloudtrace.Service
+}
+
+func (w *apiSpanWriter) WriteSpans(ctx context.Context, project string, spans []*cloudtrace.Span) error {
+	_, err := w.service.Projects.Traces.BatchWrite("projects/"+project, &cloudtrace.BatchWriteSpansRequest{Spans: spans}).Context(ctx).Do()
+	return err
+}
+
+// cloudTraceExporter is an OpenTelemetry exporter writing spans to Cloud
+// Trace. The spans are batched by the span processor of the tracer provider.
+type cloudTraceExporter struct {
+	project string
+	writer  spanWriter
+}
+
+var _ sdktrace.SpanExporter = (*cloudTraceExporter)(nil)
+
+func newCloudTraceExporter(project string, writer spanWriter) *cloudTraceExporter {
+	return &cloudTraceExporter{project: project, writer: writer}
+}
+
+func (e *cloudTraceExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	ctSpans := make([]*cloudtrace.Span, 0, len(spans))
+	for _, s := range spans {
+		ctSpans = append(ctSpans, toCloudTraceSpan(e.project, s))
+	}
+	if err := e.writer.WriteSpans(ctx, e.project, ctSpans); err != nil {
+		return fmt.Errorf("can't export %d spans to Cloud Trace: %v", len(spans), err)
+	}
+	return nil
+}
+
+func (e *cloudTraceExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func truncatableString(s string, maxBytes int) *cloudtrace.TruncatableString {
+	if len(s) <= maxBytes {
+		return &cloudtrace.TruncatableString{Value: s}
+	}
+	return &cloudtrace.TruncatableString{Value: s[:maxBytes], TruncatedByteCount: int64(len(s) - maxBytes)}
+}
+
+func toCloudTraceSpan(project string, s sdktrace.ReadOnlySpan) *cloudtrace.Span {
+	sc := s.SpanContext()
+	span := &cloudtrace.Span{
+		Name:                    fmt.Sprintf("projects/%s/traces/%s/spans/%s", project, sc.TraceID(), sc.SpanID()),
+		SpanId:                  sc.SpanID().String(),
+		DisplayName:             truncatableString(s.Name(), maxDisplayNameBytes),
+		StartTime:               s.StartTime().UTC().Format(time.RFC3339Nano),
+		EndTime:                 s.EndTime().UTC().Format(time.RFC3339Nano),
+		ChildSpanCount:          int64(s.ChildSpanCount()),
+		SameProcessAsParentSpan: !s.Parent().IsRemote(),
+	}
+	if s.Parent().HasSpanID() {
+		span.ParentSpanId = s.Parent().SpanID().String()
+	}
+	switch s.SpanKind() {
+	case trace.SpanKindClient:
+		span.SpanKind = "CLIENT"
+	case trace.SpanKindServer:
+		span.SpanKind = "SERVER"
+	}
+	// Cloud Trace uses the google.rpc.Code values, where 2 is UNKNOWN.
+	if st := s.Status(); st.Code == codes.Error {
+		span.Status = &cloudtrace.Status{Code: 2, Message: st.Description}
+	}
+	if len(s.Attributes()) > 0 {
+		attributes := &cloudtrace.Attributes{AttributeMap: make(map[string]cloudtrace.AttributeValue), DroppedAttributesCount: int64(s.DroppedAttributes())}
+		for _, kv := range s.Attributes() {
+			k := string(kv.Key)
+			switch kv.Value.Type() {
+			case attribute.STRING:
+				attributes.AttributeMap[k] = cloudtrace.AttributeValue{StringValue: truncatableString(kv.Value.AsString(), maxAttributeValueBytes)}
+			case attribute.BOOL:
+				attributes.AttributeMap[k] = cloudtrace.AttributeValue{BoolValue: kv.Value.AsBool(), ForceSendFields: []string{"BoolValue"}}
+			case attribute.INT64:
+				attributes.AttributeMap[k] = cloudtrace.AttributeValue{IntValue: kv.Value.AsInt64(), ForceSendFields: []string{"IntValue"}}
+			default:
+				attributes.AttributeMap[k] = cloudtrace.AttributeValue{StringValue: truncatableString(kv.Value.Emit(), maxAttributeValueBytes)}
+			}
+		}
+		span.Attributes = attributes
+	}
+	return span
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/api/cloudtrace/v2"
+)
+
+type fakeSpanWriter struct {
+	mu    sync.Mutex
+	spans []*cloudtrace.Span
+}
+
+func (w *fakeSpanWriter) WriteSpans(ctx context.Context, project string, spans []*cloudtrace.Span) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.spans = append(w.spans, spans...)
+	return nil
+}
+
+func TestCloudTraceExporter(t *testing.T) {
+	w := &fakeSpanWriter{}
+	shutdown := initProvider(newCloudTraceExporter("my-project", w))
+
+	ctx, parent := StartSpan(context.Background(), "MigrateDatabase", attribute.String("migrationRequestId", "SMT-1234"))
+	_, child := StartSpan(ctx, "CreateOrUpdateDatabase", attribute.Int64("statements", 3), attribute.Bool("dryRun", false))
+	EndSpan(child, fmt.Errorf("ddl failed"))
+	EndSpan(parent, nil)
+	// Spans are exported in batches, at the latest on shutdown.
+	shutdown(context.Background())
+
+	assert.Equal(t, 2, len(w.spans))
+	c, p := w.spans[0], w.spans[1]
+	assert.Equal(t, "CreateOrUpdateDatabase", c.DisplayName.Value)
+	assert.Equal(t, p.SpanId, c.ParentSpanId)
+	assert.Equal(t, fmt.Sprintf("projects/my-project/traces/%s/spans/%s", child.SpanContext().TraceID(), c.SpanId), c.Name)
+	assert.Equal(t, &cloudtrace.Status{Code: 2, Message: "ddl failed"}, c.Status)
+	assert.Equal(t, int64(3), c.Attributes.AttributeMap["statements"].IntValue)
+	assert.Equal(t, []string{"BoolValue"}, c.Attributes.AttributeMap["dryRun"].ForceSendFields)
+	assert.True(t, c.SameProcessAsParentSpan)
+	assert.Equal(t, "MigrateDatabase", p.DisplayName.Value)
+	assert.Equal(t, "", p.ParentSpanId)
+	assert.Nil(t, p.Status)
+	assert.Equal(t, "SMT-1234", p.Attributes.AttributeMap["migrationRequestId"].StringValue.Value)
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing records the steps of a migration as OpenTelemetry spans,
+// and exports them to Cloud Trace.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/api/cloudtrace/v2"
+)
+
+// TRACE_PROJECT_ENV names the project whose Cloud Trace the spans are
+// exported to. No spans are recorded if it is not set.
+const TRACE_PROJECT_ENV = "SPANNER_MIGRATION_TOOL_TRACE_PROJECT"
+
+const tracerName = "github.com/GoogleCloudPlatform/spanner-migration-tool"
+
+// Init starts exporting spans to Cloud Trace if TRACE_PROJECT_ENV is set. The
+// returned function exports the remaining spans and must be called before
+// exiting.
+func Init(ctx context.Context) (func(), error) {
+	project := os.Getenv(TRACE_PROJECT_ENV)
+	if project == "" {
+		return func() {}, nil
+	}
+	service, err := cloudtrace.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't create Cloud Trace client: %v", err)
+	}
+	shutdown := initProvider(newCloudTraceExporter(project, &apiSpanWriter{service: service}))
+	return func() { shutdown(ctx) }, nil
+}
+
+// initProvider sets the global tracer provider to one sampling every span
+// and exporting them in batches with exporter. The returned function exports
+// the remaining spans.
+func initProvider(exporter sdktrace.SpanExporter) func(ctx context.Context) {
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.FromContext(context.Background()).Warn("Tracing failed", zap.Error(err))
+	}))
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithBatcher(exporter),
+	)
+	otel.SetTracerProvider(tp)
+	return func(ctx context.Context) {
+		if err := tp.Shutdown(ctx); err != nil {
+			logger.FromContext(ctx).Warn("Could not export the remaining spans", zap.Error(err))
+		}
+	}
+}
+
+// StartSpan starts a span named name as a child of the span in ctx, if any.
+func StartSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// EndSpan ends span, marking it as failed if err is not nil.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -17,13 +17,19 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/url"
 	"sort"
 	"strings"
 
 	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
 	"golang.org/x/exp/maps"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 // Generate the equivalent gCloud CLI command to launch a dataflow job with the same parameters and environment flags
@@ -109,3 +115,95 @@ func getEnvironmentFlags(environment *dataflowpb.FlexTemplateRuntimeEnvironment)
 	}
 	return strings.Trim(flag, " ")
 }
+
+// ReadFlexTemplateSpec downloads the container spec of the flex template at
+// the gcs path templatePath.
+func ReadFlexTemplateSpec(ctx context.Context, templatePath string) (*dataflowpb.ContainerSpec, error) {
+	u, err := url.Parse(templatePath)
+	if err != nil || u.Scheme != "gs" || u.Path == "" {
+		return nil, fmt.Errorf("invalid template path %s", templatePath)
+	}
+	gcsclient, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create gcs client: %v", err)
+	}
+	defer gcsclient.Close()
+	obj := gcsclient.Bucket(u.Host).Object(u.Path[1:])
+	bArr, err := gcp.DoWithResult(ctx, gcp.Call{Service: gcp.STORAGE, Method: "ReadObject", Resource: templatePath, Idempotent: true}, func(ctx context.Context) ([]byte, error) {
+		rc, err := obj.NewReader(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return ioutil.ReadAll(rc)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", templatePath, err)
+	}
+	return ParseFlexTemplateSpec(templatePath, bArr)
+}
+
+// ParseFlexTemplateSpec parses bArr, the content of the container spec of the
+// flex template at templatePath.
+func ParseFlexTemplateSpec(templatePath string, bArr []byte) (*dataflowpb.ContainerSpec, error) {
+	spec := &dataflowpb.ContainerSpec{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(bArr, spec); err != nil {
+		return nil, fmt.Errorf("could not parse template spec %s: %v", templatePath, err)
+	}
+	if spec.Image == "" {
+		return nil, fmt.Errorf("template spec %s has no container image", templatePath)
+	}
+	return spec, nil
+}
+
+// ValidateFlexTemplateParameters checks parameters against the parameters
+// declared in the metadata of spec, the container spec of the template at
+// templatePath. It returns an error listing the parameters the template does
+// not recognize and the required parameters that are missing. Templates
+// without metadata declare no parameters and are not checked.
+func ValidateFlexTemplateParameters(templatePath string, spec *dataflowpb.ContainerSpec, parameters map[string]string) error {
+	if spec.GetMetadata() == nil {
+		return nil
+	}
+	declared := map[string]bool{}
+	var missing []string
+	for _, p := range spec.GetMetadata().GetParameters() {
+		declared[p.Name] = true
+		if _, ok := parameters[p.Name]; !ok && !p.IsOptional {
+			missing = append(missing, p.Name)
+		}
+	}
+	var unknown []string
+	for name := range parameters {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) == 0 && len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	sort.Strings(missing)
+	var problems []string
+	if len(unknown) > 0 {
+		problems = append(problems, fmt.Sprintf("unrecognized parameters %s", strings.Join(unknown, ", ")))
+	}
+	if len(missing) > 0 {
+		problems = append(problems, fmt.Sprintf("missing required parameters %s", strings.Join(missing, ", ")))
+	}
+	return fmt.Errorf("template %s is incompatible with this version of Spanner migration tool: %s", templatePath, strings.Join(problems, "; "))
+}
+
+// ValidateFlexTemplateRequest fetches the container spec of the template
+// launched by req from gcs and validates the parameters of req against it.
+func ValidateFlexTemplateRequest(ctx context.Context, req *dataflowpb.LaunchFlexTemplateRequest) error {
+	templatePath := req.GetLaunchParameter().GetContainerSpecGcsPath()
+	if templatePath == "" {
+		return fmt.Errorf("launch request for job %s has no gcs template path", req.GetLaunchParameter().GetJobName())
+	}
+	spec, err := ReadFlexTemplateSpec(ctx, templatePath)
+	if err != nil {
+		return err
+	}
+	return ValidateFlexTemplateParameters(templatePath, spec, req.GetLaunchParameter().GetParameters())
+}
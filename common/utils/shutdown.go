@@ -0,0 +1,66 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// WithShutdownSignals returns a context cancelled on the first SIGINT or
+// SIGTERM, so that long-running commands can stop and record their outcome
+// rather than being killed midway. A second signal terminates the process
+// right away. The returned function cancels the context and stops listening
+// to the signals.
+func WithShutdownSignals(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			// Stopping the notifications restores the default behaviour of
+			// the signals, which exits.
+			signal.Stop(signals)
+			fmt.Fprintf(os.Stderr, "\nReceived %v, stopping. Interrupt again to exit immediately.\n", sig)
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(signals)
+		}
+	}()
+	return ctx, cancel
+}
+
+// detachedContext keeps the values of its parent, e.g. the logging fields,
+// but not its cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// WithCleanupTimeout returns a context for the work done after ctx was
+// cancelled, such as releasing locks and recording that a command was
+// interrupted. It has the values of ctx and expires after timeout.
+func WithCleanupTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(detachedContext{parent: ctx}, timeout)
+}
@@ -38,13 +38,15 @@ import (
 	database "cloud.google.com/go/spanner/admin/database/apiv1"
 	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
 	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/artifacts"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/common"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/spanner"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/ddl"
 	"golang.org/x/crypto/ssh/terminal"
-	"google.golang.org/api/googleapi"
+	"google.golang.org/api/cloudresourcemanager/v1"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
@@ -207,15 +209,20 @@ func WriteToGCS(filePath, fileName, data string) error {
 	bucket := client.Bucket(bucketName)
 	obj := bucket.Object(u.Path[1:] + fileName)
 
-	w := obj.NewWriter(ctx)
-	if _, err := fmt.Fprint(w, data); err != nil {
+	// Writing the whole content again is harmless, so the write is retried.
+	call := gcp.Call{Service: gcp.STORAGE, Method: "WriteObject", Resource: filePath + fileName, Idempotent: true}
+	err = gcp.Do(ctx, call, func(ctx context.Context) error {
+		w := obj.NewWriter(ctx)
+		if _, err := fmt.Fprint(w, data); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	})
+	if err != nil {
 		fmt.Printf("Failed to write to Cloud Storage: %s", filePath)
 		return err
 	}
-	if err := w.Close(); err != nil {
-		fmt.Printf("Failed to close GCS file: %s", filePath)
-		return err
-	}
 	return nil
 }
 
@@ -231,17 +238,15 @@ func CreateGCSBucket(bucketName, projectID, location string) error {
 	attrs := storage.BucketAttrs{
 		Location: location,
 	}
-	if err := bucket.Create(ctx, projectID, &attrs); err != nil {
-		if e, ok := err.(*googleapi.Error); ok {
-			// Ignoring the bucket already exists error.
-			if e.Code != 409 {
-				return fmt.Errorf("failed to create bucket: %v", err)
-			} else {
-				fmt.Printf("Using the existing bucket: %v \n", bucketName)
-			}
-		} else {
+	create := func(ctx context.Context) error {
+		return bucket.Create(ctx, projectID, &attrs)
+	}
+	if err := gcp.Do(ctx, gcp.Call{Service: gcp.STORAGE, Method: "CreateBucket", Resource: "gs://" + bucketName}, create); err != nil {
+		// Ignoring the bucket already exists error.
+		if !gcp.IsAlreadyExists(err) {
 			return fmt.Errorf("failed to create bucket: %v", err)
 		}
+		fmt.Printf("Using the existing bucket: %v \n", bucketName)
 
 	} else {
 		fmt.Printf("Created new GCS bucket: %v\n", bucketName)
@@ -249,6 +254,115 @@ func CreateGCSBucket(bucketName, projectID, location string) error {
 	return nil
 }
 
+// ParseUserManagedBucket splits the gs:// path of an existing, user managed
+// bucket into the bucket name and the root path of the files written under
+// it. The root path always starts and ends with a '/'.
+func ParseUserManagedBucket(path string) (string, string, error) {
+	u, err := ParseGCSFilePath(path)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Host == "" {
+		return "", "", fmt.Errorf("no bucket specified in GCS path: %s", path)
+	}
+	return u.Host, u.Path, nil
+}
+
+// PrepareGCSBucket makes the bucket ready for the files of a migration to be
+// written under rootPath. A user managed bucket must already exist, and is
+// only checked for write access by writing and deleting a probe object under
+// rootPath. Otherwise the bucket is created if it does not exist.
+// The default Dataflow worker service account of the project is then granted
+// objectAdmin on the bucket, so that the Dataflow jobs of the migration can
+// read and write its files. Failing to grant it only prints a warning, as
+// the user may not be allowed to change the policy of the bucket, or run the
+// jobs as another service account.
+func PrepareGCSBucket(bucketName, rootPath, projectID, location string, userManaged bool) error {
+	var err error
+	if userManaged {
+		err = checkGCSBucketWriteAccess(bucketName, rootPath)
+	} else {
+		err = CreateGCSBucket(bucketName, projectID, location)
+	}
+	if err != nil {
+		return err
+	}
+	if err := grantDataflowWorkerAccess(bucketName, projectID); err != nil {
+		fmt.Printf("Warning: %v. Please grant %s on gs://%s to the service account of the Dataflow workers if the Dataflow jobs can't access it.\n", err, artifacts.ROLE_OBJECT_ADMIN, bucketName)
+	}
+	return nil
+}
+
+// checkGCSBucketWriteAccess checks that the files of a migration can be
+// written under rootPath of a user managed bucket.
+func checkGCSBucketWriteAccess(bucketName, rootPath string) error {
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create GCS client: %v", err)
+	}
+	defer client.Close()
+	obj := client.Bucket(bucketName).Object(strings.TrimPrefix(rootPath, "/") + ".smt-write-check")
+	resource := fmt.Sprintf("gs://%s/%s", bucketName, obj.ObjectName())
+	err = gcp.Do(ctx, gcp.Call{Service: gcp.STORAGE, Method: "WriteObject", Resource: resource, Idempotent: true}, func(ctx context.Context) error {
+		w := obj.NewWriter(ctx)
+		if _, err := fmt.Fprint(w, "Spanner migration tool write access check"); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("can't write to bucket gs://%s%s: %v", bucketName, rootPath, err)
+	}
+	if err := gcp.Do(ctx, gcp.Call{Service: gcp.STORAGE, Method: "DeleteObject", Resource: resource}, obj.Delete); err != nil {
+		return fmt.Errorf("can't delete from bucket gs://%s%s: %v", bucketName, rootPath, err)
+	}
+	fmt.Printf("Using the user managed bucket: gs://%s%s\n", bucketName, rootPath)
+	return nil
+}
+
+// GetDataflowWorkerServiceAccount returns the service account the Dataflow
+// workers of a project run as by default, the Compute Engine default service
+// account.
+func GetDataflowWorkerServiceAccount(ctx context.Context, projectID string) (string, error) {
+	crmService, err := cloudresourcemanager.NewService(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create resource manager client: %v", err)
+	}
+	call := gcp.Call{Service: gcp.RESOURCE_MANAGER, Method: "GetProject", Resource: projectID, Idempotent: true}
+	project, err := gcp.DoWithResult(ctx, call, func(ctx context.Context) (*cloudresourcemanager.Project, error) {
+		return crmService.Projects.Get(projectID).Context(ctx).Do()
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-compute@developer.gserviceaccount.com", project.ProjectNumber), nil
+}
+
+// grantDataflowWorkerAccess grants objectAdmin on a bucket to the default
+// Dataflow worker service account of the project.
+func grantDataflowWorkerAccess(bucketName, projectID string) error {
+	ctx := context.Background()
+	serviceAccount, err := GetDataflowWorkerServiceAccount(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("can't get the Dataflow worker service account: %v", err)
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create GCS client: %v", err)
+	}
+	defer client.Close()
+	granted, err := artifacts.AddBucketMember(ctx, artifacts.GcsStorageAccessor{Client: client}, bucketName, artifacts.ROLE_OBJECT_ADMIN, "serviceAccount:"+serviceAccount)
+	if err != nil {
+		return fmt.Errorf("can't grant %s access to gs://%s: %v", serviceAccount, bucketName, err)
+	}
+	if granted {
+		fmt.Printf("Granted %s on gs://%s to the Dataflow worker service account %s\n", artifacts.ROLE_OBJECT_ADMIN, bucketName, serviceAccount)
+	}
+	return nil
+}
+
 // GetProject returns the cloud project we should use for accessing Spanner.
 // Use environment variable GCLOUD_PROJECT if it is set.
 // Otherwise, use the default project returned from gcloud.
@@ -0,0 +1,87 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/profiles"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/common"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/csv"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/dynamodb"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/mysql"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/oracle"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/postgres"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/sqlserver"
+)
+
+// sourceCapabilities maps each driver to the capabilities declared by its
+// source adapter.
+var sourceCapabilities = map[string]common.Capabilities{
+	constants.MYSQL:     mysql.Capabilities,
+	constants.MYSQLDUMP: mysql.Capabilities,
+	constants.POSTGRES:  postgres.Capabilities,
+	constants.PGDUMP:    postgres.Capabilities,
+	constants.SQLSERVER: sqlserver.Capabilities,
+	constants.ORACLE:    oracle.Capabilities,
+	constants.DYNAMODB:  dynamodb.Capabilities,
+	constants.CSV:       csv.Capabilities,
+}
+
+// GetSourceCapabilities returns the capabilities of the source adapter used
+// for driver.
+func GetSourceCapabilities(driver string) (common.Capabilities, error) {
+	c, ok := sourceCapabilities[driver]
+	if !ok {
+		return common.Capabilities{}, fmt.Errorf("driver %s not supported", driver)
+	}
+	return c, nil
+}
+
+// ValidateSourceCapabilities checks that the source adapter for the source
+// profile supports every feature the requested migration will need. It is
+// run while preparing the migration so that unsupported options are rejected
+// before any resources are created.
+func ValidateSourceCapabilities(sourceProfile profiles.SourceProfile) error {
+	c, err := GetSourceCapabilities(sourceProfile.Driver)
+	if err != nil {
+		return err
+	}
+	var required []common.Feature
+	switch sourceProfile.Ty {
+	case profiles.SourceProfileTypeFile:
+		if sourceProfile.File.IsInfoSchemaSnapshot() {
+			required = append(required, common.SchemaFromSnapshot)
+		} else if sourceProfile.File.Format == "" || sourceProfile.File.Format == "dump" {
+			required = append(required, common.SchemaFromDump)
+		}
+	case profiles.SourceProfileTypeConnection:
+		required = append(required, common.SchemaFromDatabase, common.SnapshotExport)
+		if sourceProfile.Conn.Streaming {
+			required = append(required, common.ChangeDataCapture)
+		}
+	case profiles.SourceProfileTypeConfig:
+		required = append(required, common.SchemaFromDatabase, common.Sharding)
+		if sourceProfile.Config.ConfigType == constants.DATAFLOW_MIGRATION {
+			required = append(required, common.ChangeDataCapture)
+		} else {
+			required = append(required, common.SnapshotExport)
+		}
+	case profiles.SourceProfileTypeCsv:
+		required = append(required, common.SnapshotExport)
+	}
+	return c.Require(sourceProfile.Driver, required...)
+}
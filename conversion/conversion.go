@@ -43,7 +43,9 @@ import (
 	sp "cloud.google.com/go/spanner"
 	database "cloud.google.com/go/spanner/admin/database/apiv1"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/metrics"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/tracing"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal/reports"
@@ -55,8 +57,10 @@ import (
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/mysql"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/oracle"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/postgres"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/snapshot"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/spanner"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/sqlserver"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/ddl"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/writer"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/streaming"
@@ -92,7 +96,12 @@ func getDatastreamClient(ctx context.Context) *datastream.Client {
 
 // SchemaConv performs the schema conversion
 // The SourceProfile param provides the connection details to use the go SQL library.
-func SchemaConv(sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile, ioHelper *utils.IOStreams) (*internal.Conv, error) {
+func SchemaConv(sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile, ioHelper *utils.IOStreams) (conv *internal.Conv, err error) {
+	defer func() {
+		if err != nil {
+			metrics.ConversionErrors.WithLabelValues(sourceProfile.Driver, "schema").Inc()
+		}
+	}()
 	switch sourceProfile.Driver {
 	case constants.POSTGRES, constants.MYSQL, constants.DYNAMODB, constants.SQLSERVER, constants.ORACLE:
 		return schemaFromDatabase(sourceProfile, targetProfile)
@@ -105,13 +114,24 @@ func SchemaConv(sourceProfile profiles.SourceProfile, targetProfile profiles.Tar
 
 // DataConv performs the data conversion
 // The SourceProfile param provides the connection details to use the go SQL library.
-func DataConv(ctx context.Context, sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile, ioHelper *utils.IOStreams, client *sp.Client, conv *internal.Conv, dataOnly bool, writeLimit int64) (*writer.BatchWriter, error) {
+func DataConv(ctx context.Context, sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile, ioHelper *utils.IOStreams, client *sp.Client, conv *internal.Conv, dataOnly bool, writeLimit int64) (bw *writer.BatchWriter, err error) {
+	ctx, span := tracing.StartSpan(ctx, "DataConv")
+	defer span.End()
+	defer metrics.ObserveStep("DataConv", time.Now())
+	defer func() {
+		if err != nil {
+			metrics.ConversionErrors.WithLabelValues(sourceProfile.Driver, "data").Inc()
+		}
+	}()
 	config := writer.BatchWriterConfig{
 		BytesLimit: 100 * 1000 * 1000,
 		WriteLimit: writeLimit,
 		RetryLimit: 1000,
 		Verbose:    internal.Verbose(),
 	}
+	if sourceProfile.Ty == profiles.SourceProfileTypeFile && sourceProfile.File.IsInfoSchemaSnapshot() {
+		return nil, fmt.Errorf("data conversion is not supported from an information_schema snapshot, please connect to the source database instead")
+	}
 	switch sourceProfile.Driver {
 	case constants.POSTGRES, constants.MYSQL, constants.DYNAMODB, constants.SQLSERVER, constants.ORACLE:
 		return dataFromDatabase(ctx, sourceProfile, targetProfile, config, conv, client)
@@ -209,6 +229,7 @@ func getInfoSchemaForShard(shardConnInfo profiles.DirectConnectionConfig, driver
 func schemaFromDatabase(sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile) (*internal.Conv, error) {
 	conv := internal.MakeConv()
 	conv.SpDialect = targetProfile.Conn.Sp.Dialect
+	conv.Audit.StreamingStats.Streaming = sourceProfile.Conn.Streaming
 	//handle fetching schema differently for sharded migrations, we only connect to the primary shard to
 	//fetch the schema. We reuse the SourceProfileConnection object for this purpose.
 	var infoSchema common.InfoSchema
@@ -255,11 +276,46 @@ func performSnapshotMigration(config writer.BatchWriterConfig, conv *internal.Co
 		conv.Audit.Progress = *internal.NewProgress(totalRows, "Writing data to Spanner", internal.Verbose(), false, int(internal.DataWriteInProgress))
 	}
 	batchWriter := populateDataConv(conv, config, client)
+	if conv.Audit.SchemaCheckInterval > 0 {
+		monitor := common.NewSchemaChangeMonitor(conv, infoSchema, conv.Audit.SchemaCheckInterval, conv.Audit.PauseOnSchemaChange)
+		if !conv.Audit.DryRun {
+			conv.SetDataSink(
+				func(table string, cols []string, vals []interface{}) {
+					monitor.WaitIfPaused()
+					batchWriter.AddRow(table, cols, vals)
+				})
+		}
+		monitor.Start()
+		defer recordSchemaChanges(conv, monitor)
+	}
 	common.ProcessData(conv, infoSchema, additionalAttributes)
 	batchWriter.Flush()
 	return batchWriter
 }
 
+// recordSchemaChanges stops the monitor and reports any source schema change
+// it detected as an unexpected condition of the migration.
+func recordSchemaChanges(conv *internal.Conv, monitor *common.SchemaChangeMonitor) {
+	monitor.Stop()
+	for _, change := range monitor.Changes() {
+		conv.Unexpected(fmt.Sprintf("Source schema changed during data migration: %s", change))
+	}
+}
+
+// checkSchemaUnchanged verifies before starting a streaming migration that the
+// source schema still matches the converted schema, since the Dataflow job
+// converts changes using the converted schema for its whole lifetime.
+func checkSchemaUnchanged(conv *internal.Conv, infoSchema common.InfoSchema) error {
+	diffs, err := common.NewSchemaChangeMonitor(conv, infoSchema, conv.Audit.SchemaCheckInterval, conv.Audit.PauseOnSchemaChange).Check()
+	if err != nil {
+		return err
+	}
+	if len(diffs) > 0 && conv.Audit.PauseOnSchemaChange {
+		return fmt.Errorf("the source schema changed since it was converted: %s. Please convert the schema again", strings.Join(diffs, ", "))
+	}
+	return nil
+}
+
 func snapshotMigrationHandler(sourceProfile profiles.SourceProfile, config writer.BatchWriterConfig, conv *internal.Conv, client *sp.Client, infoSchema common.InfoSchema) (*writer.BatchWriter, error) {
 	switch sourceProfile.Driver {
 	// Skip snapshot migration via Spanner migration tool for mysql and oracle since dataflow job will job will handle this from backfilled data.
@@ -309,6 +365,11 @@ func dataFromDatabase(ctx context.Context, sourceProfile profiles.SourceProfile,
 		}
 		var streamInfo map[string]interface{}
 		if sourceProfile.Conn.Streaming {
+			if conv.Audit.SchemaCheckInterval > 0 {
+				if err := checkSchemaUnchanged(conv, infoSchema); err != nil {
+					return nil, err
+				}
+			}
 			streamInfo, err = infoSchema.StartChangeDataCapture(ctx, conv)
 			if err != nil {
 				return nil, err
@@ -329,8 +390,8 @@ func dataFromDatabase(ctx context.Context, sourceProfile profiles.SourceProfile,
 			dsClient := getDatastreamClient(ctx)
 			gcsBucket, _, fetchGcsErr := streaming.FetchTargetBucketAndPath(ctx, dsClient, targetProfile.Conn.Sp.Project, streamingCfg.DatastreamCfg.DestinationConnectionConfig)
 			if fetchGcsErr != nil {
-				logger.Log.Info("Could not fetch GCS Bucket, hence Monitoring Dashboard will not contain Metrics for the gcs bucket\n")
-				logger.Log.Debug("Error", zap.Error(fetchGcsErr))
+				logger.FromContext(ctx).Info("Could not fetch GCS Bucket, hence Monitoring Dashboard will not contain Metrics for the gcs bucket\n")
+				logger.FromContext(ctx).Debug("Error", zap.Error(fetchGcsErr))
 			}
 
 			monitoringResources := metrics.MonitoringMetricsResources{
@@ -348,20 +409,39 @@ func dataFromDatabase(ctx context.Context, sourceProfile profiles.SourceProfile,
 			var dashboardName string
 			if dashboardErr != nil {
 				dashboardName = ""
-				logger.Log.Info("Creation of the monitoring dashboard failed, please create the dashboard manually")
-				logger.Log.Debug("Error", zap.Error(dashboardErr))
+				logger.FromContext(ctx).Info("Creation of the monitoring dashboard failed, please create the dashboard manually")
+				logger.FromContext(ctx).Debug("Error", zap.Error(dashboardErr))
 			} else {
 				dashboardName = strings.Split(respDash.Name, "/")[3]
 				fmt.Printf("Monitoring Dashboard: %+v\n", dashboardName)
 			}
+			alertPolicies := prepareAlerting(ctx, monitoringResources, streamingCfg.AlertingCfg)
 
-			streaming.StoreGeneratedResources(conv, streamingCfg, dfJobId, gcloudCmd, targetProfile.Conn.Sp.Project, "", internal.GcsResources{BucketName: gcsBucket}, dashboardName)
+			streaming.StoreGeneratedResources(conv, streamingCfg, dfJobId, gcloudCmd, targetProfile.Conn.Sp.Project, "", internal.GcsResources{BucketName: gcsBucket}, internal.MonitoringResources{DashboardName: dashboardName, AlertPolicies: alertPolicies})
 			return bw, nil
 		}
 		return performSnapshotMigration(config, conv, client, infoSchema, internal.AdditionalDataAttributes{ShardId: ""}), nil
 	}
 }
 
+// prepareAlerting creates the alert policies watching the dataflow job if a
+// notification channel is configured, and returns their names. Alerting is
+// optional, so failures are logged and the migration carries on.
+func prepareAlerting(ctx context.Context, monitoringResources metrics.MonitoringMetricsResources, alertingCfg streaming.AlertingCfg) []string {
+	if alertingCfg.NotificationChannel == "" {
+		return nil
+	}
+	alertPolicies, err := monitoringResources.CreateDataflowAlertPolicies(ctx, alertingCfg.NotificationChannel, alertingCfg.WatermarkLagMinutes)
+	if err != nil {
+		logger.FromContext(ctx).Info(fmt.Sprintf("Creation of the alert policies for the dataflow job %s failed, please create them manually\n", monitoringResources.DataflowJobId))
+		logger.FromContext(ctx).Debug("Error", zap.Error(err))
+	}
+	for _, name := range alertPolicies {
+		fmt.Printf("Alert Policy: %s\n", name)
+	}
+	return alertPolicies
+}
+
 // TODO: Define the data processing logic for DMS migrations here.
 func dataFromDatabaseForDMSMigration() (*writer.BatchWriter, error) {
 	return nil, fmt.Errorf("dms configType is not implemented yet, please use one of 'bulk' or 'dataflow'")
@@ -400,6 +480,8 @@ func dataFromDatabaseForDataflowMigration(targetProfile profiles.TargetProfile,
 			fmt.Printf("Data shard id generated: %v\n", p.DataShardId)
 		}
 		streamingCfg := streaming.CreateStreamingConfig(*p)
+		alertingConfig := sourceProfile.Config.ShardConfigurationDataflow.AlertingConfig
+		streamingCfg.AlertingCfg = streaming.AlertingCfg{NotificationChannel: alertingConfig.NotificationChannel, WatermarkLagMinutes: alertingConfig.WatermarkLagMinutes}
 		err := streaming.VerifyAndUpdateCfg(&streamingCfg, targetProfile.Conn.Sp.Dbname, tableList)
 		if err != nil {
 			err = fmt.Errorf("failed to process shard: %s, there seems to be an error in the sharding configuration, error: %v", p.DataShardId, err)
@@ -425,8 +507,8 @@ func dataFromDatabaseForDataflowMigration(targetProfile profiles.TargetProfile,
 		dsClient := getDatastreamClient(ctx)
 		gcsBucket, _, fetchGcsErr := streaming.FetchTargetBucketAndPath(ctx, dsClient, targetProfile.Conn.Sp.Project, streamingCfg.DatastreamCfg.DestinationConnectionConfig)
 		if fetchGcsErr != nil {
-			logger.Log.Info(fmt.Sprintf("Could not fetch GCS Bucket for Shard %s hence Monitoring Dashboard will not contain Metrics for the gcs bucket\n", p.DataShardId))
-			logger.Log.Debug("Error", zap.Error(fetchGcsErr))
+			logger.FromContext(ctx).Info(fmt.Sprintf("Could not fetch GCS Bucket for Shard %s hence Monitoring Dashboard will not contain Metrics for the gcs bucket\n", p.DataShardId))
+			logger.FromContext(ctx).Debug("Error", zap.Error(fetchGcsErr))
 		}
 
 		// create monitoring dashboard for a single shard
@@ -445,13 +527,14 @@ func dataFromDatabaseForDataflowMigration(targetProfile profiles.TargetProfile,
 		var dashboardName string
 		if dashboardErr != nil {
 			dashboardName = ""
-			logger.Log.Info(fmt.Sprintf("Creation of the monitoring dashboard for shard %s failed, please create the dashboard manually\n", p.DataShardId))
-			logger.Log.Debug("Error", zap.Error(dashboardErr))
+			logger.FromContext(ctx).Info(fmt.Sprintf("Creation of the monitoring dashboard for shard %s failed, please create the dashboard manually\n", p.DataShardId))
+			logger.FromContext(ctx).Debug("Error", zap.Error(dashboardErr))
 		} else {
 			dashboardName = strings.Split(respDash.Name, "/")[3]
 			fmt.Printf("Monitoring Dashboard for shard %v: %+v\n", p.DataShardId, dashboardName)
 		}
-		streaming.StoreGeneratedResources(conv, streamingCfg, dfOutput.JobID, dfOutput.GCloudCmd, targetProfile.Conn.Sp.Project, p.DataShardId, internal.GcsResources{BucketName: gcsBucket}, dashboardName)
+		alertPolicies := prepareAlerting(ctx, monitoringResources, streamingCfg.AlertingCfg)
+		streaming.StoreGeneratedResources(conv, streamingCfg, dfOutput.JobID, dfOutput.GCloudCmd, targetProfile.Conn.Sp.Project, p.DataShardId, internal.GcsResources{BucketName: gcsBucket}, internal.MonitoringResources{DashboardName: dashboardName, AlertPolicies: alertPolicies})
 		return common.TaskResult[*profiles.DataShard]{Result: p, Err: err}
 	}
 	_, err = common.RunParallelTasks(sourceProfile.Config.ShardConfigurationDataflow.DataShards, 20, asyncProcessShards, true)
@@ -473,7 +556,7 @@ func dataFromDatabaseForDataflowMigration(targetProfile profiles.TargetProfile,
 	}
 	aggRespDash, dashboardErr := aggMonitoringResources.CreateDataflowAggMonitoringDashboard(ctx)
 	if dashboardErr != nil {
-		logger.Log.Error(fmt.Sprintf("Creation of the aggregated monitoring dashboard failed, please create the dashboard manually\n error=%v\n", dashboardErr))
+		logger.FromContext(ctx).Error(fmt.Sprintf("Creation of the aggregated monitoring dashboard failed, please create the dashboard manually\n error=%v\n", dashboardErr))
 	} else {
 		fmt.Printf("Aggregated Monitoring Dashboard: %+v\n", strings.Split(aggRespDash.Name, "/")[3])
 		conv.Audit.StreamingStats.AggMonitoringResources = internal.MonitoringResources{DashboardName:strings.Split(aggRespDash.Name, "/")[3]}
@@ -754,7 +837,10 @@ func CheckExistingDb(ctx context.Context, adminClient *database.DatabaseAdminCli
 	gotResponse := make(chan bool)
 	var err error
 	go func() {
-		_, err = adminClient.GetDatabase(ctx, &adminpb.GetDatabaseRequest{Name: dbURI})
+		err = spanneradmin.Do(ctx, gcp.Call{Method: "GetDatabase", Resource: dbURI, Idempotent: true}, func(ctx context.Context) error {
+			_, err := adminClient.GetDatabase(ctx, &adminpb.GetDatabaseRequest{Name: dbURI})
+			return err
+		})
 		gotResponse <- true
 	}()
 	for {
@@ -796,7 +882,9 @@ func ValidateTables(ctx context.Context, client *sp.Client, spDialect string) (s
 // ValidateDDL verifies if an existing DB's ddl follows what is supported by Spanner migration tool. Currently,
 // we only support empty schema when db already exists.
 func ValidateDDL(ctx context.Context, adminClient *database.DatabaseAdminClient, dbURI string) error {
-	dbDdl, err := adminClient.GetDatabaseDdl(ctx, &adminpb.GetDatabaseDdlRequest{Database: dbURI})
+	dbDdl, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "GetDatabaseDdl", Resource: dbURI, Idempotent: true}, func(ctx context.Context) (*adminpb.GetDatabaseDdlResponse, error) {
+		return adminClient.GetDatabaseDdl(ctx, &adminpb.GetDatabaseDdlRequest{Database: dbURI})
+	})
 	if err != nil {
 		return fmt.Errorf("can't fetch database ddl: %v", err)
 	}
@@ -808,6 +896,9 @@ func ValidateDDL(ctx context.Context, adminClient *database.DatabaseAdminClient,
 
 // CreatesOrUpdatesDatabase updates an existing Spanner database or creates a new one if one does not exist.
 func CreateOrUpdateDatabase(ctx context.Context, adminClient *database.DatabaseAdminClient, dbURI, driver string, conv *internal.Conv, out *os.File, migrationType string) error {
+	ctx, span := tracing.StartSpan(ctx, "CreateOrUpdateDatabase")
+	defer span.End()
+	defer metrics.ObserveStep("CreateOrUpdateDatabase", time.Now())
 	dbExists, err := VerifyDb(ctx, adminClient, dbURI)
 	if err != nil {
 		return err
@@ -867,7 +958,9 @@ func CreateDatabase(ctx context.Context, adminClient *database.DatabaseAdminClie
 
 	}
 
-	op, err := adminClient.CreateDatabase(ctx, req)
+	op, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "CreateDatabase", Resource: dbURI}, func(ctx context.Context) (*database.CreateDatabaseOperation, error) {
+		return adminClient.CreateDatabase(ctx, req)
+	})
 	if err != nil {
 		return fmt.Errorf("can't build CreateDatabaseRequest: %w", utils.AnalyzeError(err, dbURI))
 	}
@@ -899,7 +992,9 @@ func UpdateDatabase(ctx context.Context, adminClient *database.DatabaseAdminClie
 	// than 1 min for large schemas, therefore, timeout is specified as 5 minutes
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
-	op, err := adminClient.UpdateDatabaseDdl(ctx, req)
+	op, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "UpdateDatabaseDdl", Resource: dbURI}, func(ctx context.Context) (*database.UpdateDatabaseDdlOperation, error) {
+		return adminClient.UpdateDatabaseDdl(ctx, req)
+	})
 	if err != nil {
 		return fmt.Errorf("can't build UpdateDatabaseDdlRequest: %w", utils.AnalyzeError(err, dbURI))
 	}
@@ -913,6 +1008,9 @@ func UpdateDatabase(ctx context.Context, adminClient *database.DatabaseAdminClie
 // UpdateDDLForeignKeys updates the Spanner database with foreign key
 // constraints using ALTER TABLE statements.
 func UpdateDDLForeignKeys(ctx context.Context, adminClient *database.DatabaseAdminClient, dbURI string, conv *internal.Conv, out *os.File, driver string, migrationType string) error {
+	ctx, span := tracing.StartSpan(ctx, "UpdateDDLForeignKeys")
+	defer span.End()
+	defer metrics.ObserveStep("UpdateDDLForeignKeys", time.Now())
 
 	if conv.SpDialect != constants.DIALECT_POSTGRESQL && migrationType == constants.DATAFLOW_MIGRATION {
 		//foreign keys were applied as part of CreateDatabase
@@ -962,11 +1060,13 @@ admin quota limit by spreading the FK creation requests over time.`)
 				workers <- workerID
 			}()
 			internal.VerbosePrintf("Submitting new FK create request: %s\n", fkStmt)
-			logger.Log.Debug("Submitting new FK create request", zap.String("fkStmt", fkStmt))
+			logger.FromContext(ctx).Debug("Submitting new FK create request", zap.String("fkStmt", fkStmt))
 
-			op, err := adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
-				Database:   dbURI,
-				Statements: []string{fkStmt},
+			op, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "UpdateDatabaseDdl", Resource: dbURI}, func(ctx context.Context) (*database.UpdateDatabaseDdlOperation, error) {
+				return adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+					Database:   dbURI,
+					Statements: []string{fkStmt},
+				})
 			})
 			if err != nil {
 				fmt.Printf("Cannot submit request for create foreign key with statement: %s\n due to error: %s. Skipping this foreign key...\n", fkStmt, err)
@@ -979,7 +1079,7 @@ admin quota limit by spreading the FK creation requests over time.`)
 				return
 			}
 			internal.VerbosePrintln("Updated schema with statement: " + fkStmt)
-			logger.Log.Debug("Updated schema with statement", zap.String("fkStmt", fkStmt))
+			logger.FromContext(ctx).Debug("Updated schema with statement", zap.String("fkStmt", fkStmt))
 		}(fkStmt, workerID)
 		// Send out an FK creation request every second, with total of maxWorkers request being present in a batch.
 		time.Sleep(time.Second)
@@ -1241,6 +1341,9 @@ func ProcessDump(driver string, conv *internal.Conv, r *internal.Reader) error {
 }
 
 func GetInfoSchema(sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile) (common.InfoSchema, error) {
+	if sourceProfile.Ty == profiles.SourceProfileTypeFile && sourceProfile.File.IsInfoSchemaSnapshot() {
+		return getInfoSchemaFromSnapshot(sourceProfile)
+	}
 	connectionConfig, err := connectionConfig(sourceProfile)
 	if err != nil {
 		return nil, err
@@ -1302,3 +1405,23 @@ func GetInfoSchema(sourceProfile profiles.SourceProfile, targetProfile profiles.
 		return nil, fmt.Errorf("driver %s not supported", driver)
 	}
 }
+
+// getInfoSchemaFromSnapshot returns an InfoSchema reading the schema from the
+// information_schema snapshot given in the source profile instead of
+// connecting to the source database.
+func getInfoSchemaFromSnapshot(sourceProfile profiles.SourceProfile) (common.InfoSchema, error) {
+	s, err := snapshot.Load(sourceProfile.File.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch sourceProfile.Driver {
+	case constants.MYSQL:
+		return mysql.NewSnapshotInfoSchema(s), nil
+	case constants.POSTGRES:
+		return postgres.NewSnapshotInfoSchema(s), nil
+	case constants.SQLSERVER:
+		return sqlserver.NewSnapshotInfoSchema(s), nil
+	default:
+		return nil, fmt.Errorf("information_schema snapshots are not supported for driver %s", sourceProfile.Driver)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// InstanceScaler reads and updates the compute capacity of Spanner instances.
+type InstanceScaler interface {
+	GetProcessingUnits(ctx context.Context, instanceURI string) (int32, error)
+	SetProcessingUnits(ctx context.Context, instanceURI string, processingUnits int32) error
+}
+
+// AdminInstanceScaler scales instances with the instance admin API.
+type AdminInstanceScaler struct {
+	Client *instance.InstanceAdminClient
+}
+
+func (s *AdminInstanceScaler) GetProcessingUnits(ctx context.Context, instanceURI string) (int32, error) {
+	inst, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "GetInstance", Resource: instanceURI, Idempotent: true}, func(ctx context.Context) (*instancepb.Instance, error) {
+		return s.Client.GetInstance(ctx, &instancepb.GetInstanceRequest{Name: instanceURI})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return inst.ProcessingUnits, nil
+}
+
+func (s *AdminInstanceScaler) SetProcessingUnits(ctx context.Context, instanceURI string, processingUnits int32) error {
+	op, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "UpdateInstance", Resource: instanceURI}, func(ctx context.Context) (*instance.UpdateInstanceOperation, error) {
+		return s.Client.UpdateInstance(ctx, &instancepb.UpdateInstanceRequest{
+			Instance:  &instancepb.Instance{Name: instanceURI, ProcessingUnits: processingUnits},
+			FieldMask: &fieldmaskpb.FieldMask{Paths: []string{"processing_units"}},
+		})
+	})
+	if err != nil {
+		return err
+	}
+	_, err = op.Wait(ctx)
+	return err
+}
+
+// GetInstanceURI returns the uri of the instance of the database dbURI.
+func GetInstanceURI(dbURI string) string {
+	return strings.Split(dbURI, "/databases/")[0]
+}
+
+// RaiseProcessingUnits raises the processing units of the instance to
+// conv.Audit.MinProcessingUnits for the data migration, if the instance has
+// less. The change is recorded in conv.Audit.InstanceScaling so that it can be
+// reverted by RestoreProcessingUnits.
+func RaiseProcessingUnits(ctx context.Context, scaler InstanceScaler, instanceURI string, conv *internal.Conv) error {
+	minProcessingUnits := conv.Audit.MinProcessingUnits
+	if minProcessingUnits <= 0 {
+		return nil
+	}
+	current, err := scaler.GetProcessingUnits(ctx, instanceURI)
+	if err != nil {
+		return fmt.Errorf("can't read processing units of instance %s: %v", instanceURI, err)
+	}
+	if current >= minProcessingUnits {
+		fmt.Printf("Instance %s has %d processing units, no scaling needed for the data migration\n", instanceURI, current)
+		return nil
+	}
+	fmt.Printf("Raising processing units of instance %s from %d to %d for the data migration\n", instanceURI, current, minProcessingUnits)
+	if err := scaler.SetProcessingUnits(ctx, instanceURI, minProcessingUnits); err != nil {
+		return fmt.Errorf("can't raise processing units of instance %s: %v", instanceURI, err)
+	}
+	conv.Audit.InstanceScaling = &internal.InstanceScalingResources{
+		InstanceURI:             instanceURI,
+		OriginalProcessingUnits: current,
+		ProcessingUnits:         minProcessingUnits,
+	}
+	return nil
+}
+
+// RestoreProcessingUnits reverts the processing units change recorded in
+// conv.Audit.InstanceScaling. If the processing units were changed since, e.g.
+// by an autoscaler managing the instance, they are left as they are.
+func RestoreProcessingUnits(ctx context.Context, scaler InstanceScaler, conv *internal.Conv) error {
+	scaling := conv.Audit.InstanceScaling
+	if scaling == nil || scaling.Restored {
+		return nil
+	}
+	current, err := scaler.GetProcessingUnits(ctx, scaling.InstanceURI)
+	if err != nil {
+		return fmt.Errorf("can't read processing units of instance %s: %v, restore them manually with: %s", scaling.InstanceURI, err, getRestoreProcessingUnitsCmd(scaling))
+	}
+	if current != scaling.ProcessingUnits {
+		fmt.Printf("Processing units of instance %s were changed to %d during the data migration, leaving them unchanged\n", scaling.InstanceURI, current)
+		scaling.Restored = true
+		return nil
+	}
+	fmt.Printf("Restoring processing units of instance %s to %d\n", scaling.InstanceURI, scaling.OriginalProcessingUnits)
+	if err := scaler.SetProcessingUnits(ctx, scaling.InstanceURI, scaling.OriginalProcessingUnits); err != nil {
+		return fmt.Errorf("can't restore processing units of instance %s: %v, restore them manually with: %s", scaling.InstanceURI, err, getRestoreProcessingUnitsCmd(scaling))
+	}
+	scaling.Restored = true
+	return nil
+}
+
+func getRestoreProcessingUnitsCmd(scaling *internal.InstanceScalingResources) string {
+	parts := strings.Split(scaling.InstanceURI, "/")
+	return fmt.Sprintf("gcloud spanner instances update %s --project=%s --processing-units=%d", parts[len(parts)-1], parts[1], scaling.OriginalProcessingUnits)
+}
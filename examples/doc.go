@@ -0,0 +1,31 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package examples holds runnable examples of embedding Spanner migration
+// tool, which run as part of go test and use the dump files in this directory
+// as source databases:
+//
+//   - schema conversion of a dump file to Spanner DDL
+//   - validation of a converted schema against an existing schema
+//   - scaling the target instance for a data migration
+//   - running a migration as a background task
+//
+// TestEmulator_SchemaAndDataFromDump additionally migrates schema and data of
+// a dump file when SPANNER_EMULATOR_HOST,
+// SPANNER_MIGRATION_TOOL_TESTS_GCLOUD_PROJECT_ID and
+// SPANNER_MIGRATION_TOOL_TESTS_GCLOUD_INSTANCE_ID are set.
+//
+// The reverse replication launcher is a standalone main package, see
+// docs/reverse-replication for running it.
+package examples
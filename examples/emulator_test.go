@@ -0,0 +1,81 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package examples_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/testing/common"
+	"github.com/stretchr/testify/assert"
+	databasepb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+// TestEmulator_SchemaAndDataFromDump migrates the bundled cart dump to the
+// Spanner emulator, in the same way as:
+//
+//	spanner-migration-tool schema-and-data -source=mysql < cart.mysqldump
+func TestEmulator_SchemaAndDataFromDump(t *testing.T) {
+	projectID := os.Getenv("SPANNER_MIGRATION_TOOL_TESTS_GCLOUD_PROJECT_ID")
+	instanceID := os.Getenv("SPANNER_MIGRATION_TOOL_TESTS_GCLOUD_INSTANCE_ID")
+	if testing.Short() || os.Getenv("SPANNER_EMULATOR_HOST") == "" || projectID == "" || instanceID == "" {
+		t.Skip("Skipping example which only runs against the emulator.")
+	}
+	ctx := context.Background()
+	tmpdir, err := ioutil.TempDir("", "examples-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	dbName := "examples-cart"
+	dbURI := fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectID, instanceID, dbName)
+	args := fmt.Sprintf("schema-and-data -source=mysql -prefix=%s -target-profile='instance=%s,dbName=%s' < cart.mysqldump", filepath.Join(tmpdir, dbName), instanceID, dbName)
+	if err := common.RunCommand(args, projectID); err != nil {
+		t.Fatal(err)
+	}
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adminClient.Close()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		if err := adminClient.DropDatabase(ctx, &databasepb.DropDatabaseRequest{Database: dbURI}); err != nil {
+			t.Fatalf("failed to drop database %v: %v", dbURI, err)
+		}
+	}()
+
+	client, err := spanner.NewClient(ctx, dbURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for table, expected := range map[string]int64{"cart": 3, "products": 3} {
+		var count int64
+		row, err := client.Single().Query(ctx, spanner.Statement{SQL: fmt.Sprintf("SELECT COUNT(*) FROM %s", table)}).Next()
+		assert.Nil(t, err)
+		assert.Nil(t, row.Columns(&count))
+		assert.Equal(t, expected, count, table)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package examples_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/testutil"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/conversion"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/ddl"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/task"
+	"go.uber.org/zap"
+)
+
+func init() {
+	logger.Log = zap.NewNop()
+}
+
+// convertDump converts the schema of a bundled dump file to a Spanner schema.
+func convertDump(driver, dumpFile string) (*internal.Conv, error) {
+	f, err := os.Open(dumpFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	conv := internal.MakeConv()
+	conv.SpDialect = constants.DIALECT_GOOGLESQL
+	conv.SetSchemaMode()
+	if err := conversion.ProcessDump(driver, conv, internal.NewReader(bufio.NewReader(f), nil)); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// Converts the schema of a MySQL dump and prints the Spanner DDL.
+func Example_schemaConversion() {
+	conv, err := convertDump(constants.MYSQLDUMP, "cart.mysqldump")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	stmts := conv.SpSchema.GetDDL(ddl.Config{Tables: true, ForeignKeys: true, SpDialect: conv.SpDialect, Source: constants.MYSQL})
+	fmt.Println(strings.Join(stmts, ";\n"))
+	// Output:
+	// CREATE TABLE cart (
+	// 	user_id STRING(20) NOT NULL,
+	// 	product_id STRING(20) NOT NULL,
+	// 	quantity INT64,
+	// 	last_modified TIMESTAMP NOT NULL,
+	// ) PRIMARY KEY (user_id, product_id);
+	// CREATE TABLE products (
+	// 	product_id STRING(20) NOT NULL,
+	// 	description STRING(1000),
+	// 	price NUMERIC,
+	// 	date_added DATE,
+	// ) PRIMARY KEY (product_id)
+}
+
+// Validates a converted schema against the schema of an existing database,
+// as done before a data only migration. Here the existing schema is converted
+// from the same dump and then altered.
+func Example_validation() {
+	conv, err := convertDump(constants.MYSQLDUMP, "cart.mysqldump")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	existing, err := convertDump(constants.MYSQLDUMP, "cart.mysqldump")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("unchanged schema:", utils.CompareSchema(conv, existing))
+
+	tableId, _ := internal.GetTableIdFromSpName(existing.SpSchema, "products")
+	table := existing.SpSchema[tableId]
+	colId, _ := internal.GetColIdFromSpName(table.ColDefs, "description")
+	col := table.ColDefs[colId]
+	col.T.Len = 100
+	table.ColDefs[colId] = col
+	fmt.Println("altered schema:", utils.CompareSchema(conv, existing))
+	// Output:
+	// unchanged schema: <nil>
+	// altered schema: column detail for table products don't match
+}
+
+// Raises the processing units of the target instance for a data migration and
+// restores them afterwards.
+func Example_instanceScaling() {
+	ctx := context.Background()
+	instanceURI := conversion.GetInstanceURI("projects/my-project/instances/my-instance/databases/cart")
+	scaler := testutil.NewFakeSpannerAdmin(map[string]int32{instanceURI: 1000})
+	conv := internal.MakeConv()
+	conv.Audit.MinProcessingUnits = 3000
+
+	if err := conversion.RaiseProcessingUnits(ctx, scaler, instanceURI, conv); err != nil {
+		fmt.Println(err)
+		return
+	}
+	// The data migration runs here.
+	if err := conversion.RestoreProcessingUnits(ctx, scaler, conv); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// Raising processing units of instance projects/my-project/instances/my-instance from 1000 to 3000 for the data migration
+	// Restoring processing units of instance projects/my-project/instances/my-instance to 1000
+}
+
+// Runs a migration as a background task, the way the web UI does, and waits
+// for it to finish.
+func Example_backgroundTask() {
+	ctx := context.Background()
+	q := task.NewQueue(task.NewLocalTaskStore(), 1, 1)
+	if err := q.Start(ctx); err != nil {
+		fmt.Println(err)
+		return
+	}
+	t, err := q.Submit(ctx, "migrate", map[string]string{"dumpFile": "cart.mysqldump"}, func(ctx context.Context) error {
+		_, err := convertDump(constants.MYSQLDUMP, "cart.mysqldump")
+		return err
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("submitted:", t.Status)
+	// Shutdown waits for the queued tasks to finish.
+	if err := q.Shutdown(ctx); err != nil {
+		fmt.Println(err)
+		return
+	}
+	t, _ = q.Get(ctx, t.TaskId)
+	fmt.Println("finished:", t.Status)
+	// Output:
+	// submitted: QUEUED
+	// finished: SUCCEEDED
+}
@@ -118,6 +118,10 @@ const (
 	ShardIdColumnAdded
 	ShardIdColumnPrimaryKey
 	ArrayTypeNotSupported
+	GeneratedColumn
+	ComputedColumn
+	CommitTimestamp
+	TimestampOffset
 )
 
 const (
@@ -184,6 +188,19 @@ type Audit struct {
 	StreamingStats           streamingStats                         `json:"-"` // Stores information related to streaming migration process.
 	Progress                 Progress                               `json:"-"` // Stores information related to progress of the migration progress
 	SkipMetricsPopulation    bool                                   `json:"-"` // Flag to identify if outgoing metrics metadata needs to skipped
+	SchemaCheckInterval      time.Duration                          `json:"-"` // Interval at which the source schema is checked for changes during data migration, 0 disables the checks.
+	PauseOnSchemaChange      bool                                   `json:"-"` // Flag to pause data migration while the source schema differs from the converted schema.
+	MinProcessingUnits       int32                                  `json:"-"` // Processing units the Spanner instance is raised to for the data migration, 0 leaves the instance unchanged.
+	InstanceScaling          *InstanceScalingResources              `json:"-"` // Stores the processing units change made to the Spanner instance for the data migration.
+}
+
+// Stores information related to the processing units of the Spanner instance
+// raised for the duration of the data migration.
+type InstanceScalingResources struct {
+	InstanceURI             string `json:"InstanceURI"`
+	OriginalProcessingUnits int32  `json:"OriginalProcessingUnits"`
+	ProcessingUnits         int32  `json:"ProcessingUnits"`
+	Restored                bool   `json:"Restored"`
 }
 
 // Stores information related to resources.
@@ -196,9 +213,18 @@ type GcsResources struct {
 	BucketName string `json:"BucketName"`
 }
 
+// Stores the generation and MD5 hash of a GCS file read by the dataflow job,
+// recorded when the job is launched.
+type GcsArtifact struct {
+	Path       string `json:"Path"`
+	Generation int64  `json:"Generation"`
+	MD5        []byte `json:"MD5"`
+}
+
 // Stores information related to Monitoring resources
 type MonitoringResources struct {
-	DashboardName string `json:"DashboardName"`
+	DashboardName string   `json:"DashboardName"`
+	AlertPolicies []string `json:"AlertPolicies,omitempty"` // Names of the alert policies watching the dataflow job.
 }
 
 // Stores information related to the streaming migration process.
@@ -222,6 +248,8 @@ type streamingStats struct {
 	MonitoringResources           MonitoringResources
 	ShardToMonitoringResourcesMap map[string]MonitoringResources
 	AggMonitoringResources        MonitoringResources
+	GcsArtifacts                  []GcsArtifact     // GCS files read by the dataflow jobs, as they were at launch time.
+	DlqDirectories                map[string]string // Dead letter queue directory of the dataflow job of every data shard, under the empty shard id for unsharded migrations.
 }
 
 type PubsubCfg struct {
@@ -419,6 +447,39 @@ func (conv *Conv) AddShardIdColumn() {
 	}
 }
 
+// AddTimezoneOffsetColumns adds a column storing the source timezone offset
+// next to every column with the TimestampOffset issue, since Spanner
+// normalizes TIMESTAMP values to UTC.
+func (conv *Conv) AddTimezoneOffsetColumns() {
+	for t, ct := range conv.SpSchema {
+		var colIds []string
+		for _, colId := range ct.ColIds {
+			colIds = append(colIds, colId)
+			colDef := ct.ColDefs[colId]
+			if colDef.TimezoneOffsetColId != "" || !containsSchemaIssue(conv.SchemaIssues[t].ColumnLevelIssues[colId], TimestampOffset) {
+				continue
+			}
+			colName := conv.buildColumnNameWithBase(t, colDef.Name+"_offset")
+			columnId := GenerateColumnId()
+			colIds = append(colIds, columnId)
+			ct.ColDefs[columnId] = ddl.ColumnDef{Name: colName, Id: columnId, T: ddl.Type{Name: ddl.String, Len: 6}, Comment: "Timezone offset of " + colDef.Name}
+			colDef.TimezoneOffsetColId = columnId
+			ct.ColDefs[colId] = colDef
+		}
+		ct.ColIds = colIds
+		conv.SpSchema[t] = ct
+	}
+}
+
+func containsSchemaIssue(issues []SchemaIssue, issue SchemaIssue) bool {
+	for _, i := range issues {
+		if i == issue {
+			return true
+		}
+	}
+	return false
+}
+
 // AddPrimaryKeys analyzes all tables in conv.schema and adds synthetic primary
 // keys for any tables that don't have primary key.
 func (conv *Conv) AddPrimaryKeys() {
@@ -542,6 +542,11 @@ var IssueDB = map[internal.SchemaIssue]struct {
 	internal.UniqueIndexPrimaryKey: {Category: "UNIQUE_INDEX_PRIMARY_KEY",
 		CategoryDescription: "Primary Key is missing, unique column(s) used as primary key"},
 	internal.ArrayTypeNotSupported: {Brief: "Array datatype migration is not fully supported. Please validate data after data migration", severity: warning, Category: "ARRAY_TYPE_NOT_SUPPORTED"},
+	internal.GeneratedColumn:       {Brief: "Computed column is converted to a stored generated column. Please verify the generated expression", severity: warning, Category: "GENERATED_COLUMN"},
+	internal.ComputedColumn:        {Brief: "Expression of the computed column could not be converted. The column is migrated as a regular column holding the computed values", severity: warning, Category: "COMPUTED_COLUMN_NOT_CONVERTED"},
+	internal.CommitTimestamp: {Brief: "Column is a row version which the source updates on every write. It is mapped to a commit timestamp column, so source values are not migrated. Drop the column, or map it to INT64 to keep the source values instead", severity: warning, Category: "ROW_VERSION_COMMIT_TIMESTAMP",
+		CategoryDescription: "Row version column mapped to a commit timestamp"},
+	internal.TimestampOffset: {Brief: "Spanner normalizes timestamps to UTC, the source timezone offsets are stored in a separate column", severity: note, Category: "TIMESTAMP_OFFSET_COLUMN_ADDED"},
 }
 
 type severity int
@@ -43,6 +43,10 @@ func assertSpColDef(conv *Conv, t *testing.T, tableId string, expectedColDef, ac
 		actualCol := actualColDef[colId]
 		actualCol.Id = ""
 		actualCol.Comment = ""
+		// Expected offset columns are referenced by name.
+		if actualCol.TimezoneOffsetColId != "" {
+			actualCol.TimezoneOffsetColId = actualColDef[actualCol.TimezoneOffsetColId].Name
+		}
 		assert.Equal(t, col, actualCol)
 	}
 }
@@ -0,0 +1,185 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/api/logging/v2"
+)
+
+const (
+	// CLOUD_LOGGING_PROJECT_ENV names the project whose Cloud Logging the logs
+	// are additionally written to. Logs are only written to stdout and the log
+	// file if it is not set.
+	CLOUD_LOGGING_PROJECT_ENV = "SPANNER_MIGRATION_TOOL_CLOUD_LOGGING_PROJECT"
+	CLOUD_LOG_NAME            = "spanner-migration-tool"
+	// Number of entries buffered before they are written to Cloud Logging.
+	cloudLoggingBatchSize = 100
+)
+
+// Labels of Cloud Logging entries, set from the matching structured fields.
+var cloudLoggingLabels = map[string]string{
+	MIGRATION_REQUEST_ID_FIELD: "job_id",
+	COMMAND_FIELD:              "module",
+}
+
+// cloudLoggingSeverity maps zap levels to Cloud Logging severities.
+func cloudLoggingSeverity(level zapcore.Level) string {
+	switch level {
+	case zapcore.DebugLevel:
+		return "DEBUG"
+	case zapcore.InfoLevel:
+		return "INFO"
+	case zapcore.WarnLevel:
+		return "WARNING"
+	case zapcore.ErrorLevel:
+		return "ERROR"
+	case zapcore.DPanicLevel:
+		return "CRITICAL"
+	case zapcore.PanicLevel:
+		return "ALERT"
+	case zapcore.FatalLevel:
+		return "EMERGENCY"
+	default:
+		return "DEFAULT"
+	}
+}
+
+// cloudLogWriter writes log entries to Cloud Logging.
+type cloudLogWriter interface {
+	WriteEntries(entries []*logging.LogEntry) error
+}
+
+type apiLogWriter struct {
+	service *logging.Service
+}
+
+func (w *apiLogWriter) WriteEntries(entries []*logging.LogEntry) error {
+	_, err := w.service.Entries.Write(&logging.WriteLogEntriesRequest{Entries: entries}).Do()
+	return err
+}
+
+// cloudSink buffers the entries of all the loggers derived from a cloudCore.
+type cloudSink struct {
+	mu       sync.Mutex
+	writer   cloudLogWriter
+	logName  string
+	resource *logging.MonitoredResource
+	entries  []*logging.LogEntry
+}
+
+func (s *cloudSink) add(entry *logging.LogEntry, flush bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	if flush || len(s.entries) >= cloudLoggingBatchSize {
+		return s.flushLocked()
+	}
+	return nil
+}
+
+func (s *cloudSink) flushLocked() error {
+	if len(s.entries) == 0 {
+		return nil
+	}
+	entries := s.entries
+	s.entries = nil
+	if err := s.writer.WriteEntries(entries); err != nil {
+		return fmt.Errorf("can't write %d entries to Cloud Logging: %v", len(entries), err)
+	}
+	return nil
+}
+
+// cloudCore is a zapcore.Core writing entries to Cloud Logging. The entries
+// are written in batches, and right away from the error level on.
+type cloudCore struct {
+	zapcore.LevelEnabler
+	fields []zapcore.Field
+	sink   *cloudSink
+}
+
+func newCloudCore(enabler zapcore.LevelEnabler, project string, writer cloudLogWriter) *cloudCore {
+	return &cloudCore{
+		LevelEnabler: enabler,
+		sink: &cloudSink{
+			writer:   writer,
+			logName:  fmt.Sprintf("projects/%s/logs/%s", project, CLOUD_LOG_NAME),
+			resource: &logging.MonitoredResource{Type: "global", Labels: map[string]string{"project_id": project}},
+		},
+	}
+}
+
+func (c *cloudCore) With(fields []zapcore.Field) zapcore.Core {
+	return &cloudCore{
+		LevelEnabler: c.LevelEnabler,
+		fields:       append(append([]zapcore.Field{}, c.fields...), fields...),
+		sink:         c.sink,
+	}
+}
+
+func (c *cloudCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *cloudCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	labels := map[string]string{}
+	for field, label := range cloudLoggingLabels {
+		if v, ok := enc.Fields[field].(string); ok {
+			labels[label] = v
+		}
+	}
+	enc.Fields["message"] = ent.Message
+	if ent.Caller.Defined {
+		enc.Fields["caller"] = ent.Caller.TrimmedPath()
+	}
+	if ent.Stack != "" {
+		enc.Fields["stacktrace"] = ent.Stack
+	}
+	payload, err := json.Marshal(enc.Fields)
+	if err != nil {
+		return fmt.Errorf("can't encode log entry for Cloud Logging: %v", err)
+	}
+	return c.sink.add(&logging.LogEntry{
+		LogName:     c.sink.logName,
+		Resource:    c.sink.resource,
+		Severity:    cloudLoggingSeverity(ent.Level),
+		Timestamp:   ent.Time.UTC().Format(time.RFC3339Nano),
+		Labels:      labels,
+		JsonPayload: payload,
+	}, ent.Level >= zapcore.ErrorLevel)
+}
+
+func (c *cloudCore) Sync() error {
+	c.sink.mu.Lock()
+	defer c.sink.mu.Unlock()
+	return c.sink.flushLocked()
+}
+
+// getCloudCore returns the core writing to Cloud Logging if
+// CLOUD_LOGGING_PROJECT_ENV is set, and nil otherwise.
+func getCloudCore(enabler zapcore.LevelEnabler) (zapcore.Core, error) {
+	project := os.Getenv(CLOUD_LOGGING_PROJECT_ENV)
+	if project == "" {
+		return nil, nil
+	}
+	service, err := logging.NewService(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("can't create Cloud Logging client: %v", err)
+	}
+	return newCloudCore(enabler, project, &apiLogWriter{service: service}), nil
+}
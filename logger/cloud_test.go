@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/api/logging/v2"
+)
+
+type fakeLogWriter struct {
+	writes [][]*logging.LogEntry
+}
+
+func (w *fakeLogWriter) WriteEntries(entries []*logging.LogEntry) error {
+	w.writes = append(w.writes, entries)
+	return nil
+}
+
+func TestCloudCore(t *testing.T) {
+	w := &fakeLogWriter{}
+	l := zap.New(newCloudCore(zapcore.InfoLevel, "my-project", w)).With(zap.String(MIGRATION_REQUEST_ID_FIELD, "SMT-1234"), zap.String(COMMAND_FIELD, "data"))
+
+	l.Debug("not enabled")
+	l.Info("writing rows", zap.String("table", "cart"))
+	// Entries are buffered until the batch is full or the logger is synced.
+	assert.Empty(t, w.writes)
+	l.Warn("slow write")
+	l.Error("write failed")
+	assert.Equal(t, 1, len(w.writes))
+	assert.Nil(t, l.Sync())
+	assert.Equal(t, 1, len(w.writes))
+
+	entries := w.writes[0]
+	assert.Equal(t, 3, len(entries))
+	var severities []string
+	for _, e := range entries {
+		severities = append(severities, e.Severity)
+		assert.Equal(t, "projects/my-project/logs/spanner-migration-tool", e.LogName)
+		assert.Equal(t, "global", e.Resource.Type)
+		assert.Equal(t, map[string]string{"job_id": "SMT-1234", "module": "data"}, e.Labels)
+	}
+	assert.Equal(t, []string{"INFO", "WARNING", "ERROR"}, severities)
+	var payload map[string]interface{}
+	assert.Nil(t, json.Unmarshal(entries[0].JsonPayload, &payload))
+	assert.Equal(t, map[string]interface{}{"message": "writing rows", "table": "cart", MIGRATION_REQUEST_ID_FIELD: "SMT-1234", COMMAND_FIELD: "data"}, payload)
+
+	l.Info("done")
+	assert.Nil(t, l.Sync())
+	assert.Equal(t, 2, len(w.writes))
+}
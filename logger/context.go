@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// Names of the structured fields identifying the migration a log entry
+// belongs to, which can be used to filter the logs of a single migration.
+const (
+	MIGRATION_REQUEST_ID_FIELD = "migrationRequestId"
+	COMMAND_FIELD              = "command"
+)
+
+type contextKey struct{}
+
+// FromContext returns the logger carried by ctx, or Log if ctx carries none.
+func FromContext(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*zap.Logger); ok {
+		return l
+	}
+	if Log == nil {
+		return zap.NewNop()
+	}
+	return Log
+}
+
+// WithFields returns a context carrying a logger which adds fields to every
+// entry logged through FromContext.
+func WithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	return context.WithValue(ctx, contextKey{}, FromContext(ctx).With(fields...))
+}
+
+// WithMigration returns a context whose logger tags entries with the id of the
+// migration and the command or handler running it.
+func WithMigration(ctx context.Context, migrationRequestId, command string) context.Context {
+	return WithFields(ctx, zap.String(MIGRATION_REQUEST_ID_FIELD, migrationRequestId), zap.String(COMMAND_FIELD, command))
+}
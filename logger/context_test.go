@@ -0,0 +1,28 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFromContext(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	Log = zap.New(core)
+	defer func() { Log = nil }()
+
+	ctx := context.Background()
+	assert.Equal(t, Log, FromContext(ctx))
+
+	ctx = WithMigration(ctx, "SMT-1234", "data")
+	FromContext(WithFields(ctx, zap.String("table", "cart"))).Info("writing rows")
+	FromContext(ctx).Info("done")
+
+	entries := logs.All()
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, map[string]interface{}{MIGRATION_REQUEST_ID_FIELD: "SMT-1234", COMMAND_FIELD: "data", "table": "cart"}, entries[0].ContextMap())
+	assert.Equal(t, map[string]interface{}{MIGRATION_REQUEST_ID_FIELD: "SMT-1234", COMMAND_FIELD: "data"}, entries[1].ContextMap())
+}
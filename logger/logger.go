@@ -1,6 +1,8 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"os"
 
 	"go.uber.org/zap"
@@ -9,9 +11,48 @@ import (
 
 const LOG_FILE_NAME = "spanner-migration-tool.log"
 
+// Encodings of the logs written to the console.
+const (
+	CONSOLE_ENCODING = "console"
+	JSON_ENCODING    = "json"
+)
+
 var Log *zap.Logger
 
+// Config configures the logger set up by InitializeLoggerWithConfig. Empty
+// fields take their default value.
+type Config struct {
+	// Level is the lowest level logged, e.g. DEBUG or INFO. Defaults to DEBUG.
+	Level string
+	// Encoding of the logs written to Console, CONSOLE_ENCODING or
+	// JSON_ENCODING. Defaults to CONSOLE_ENCODING. The log file is always
+	// written as JSON.
+	Encoding string
+	// OutputPath is the file the logs are appended to. Defaults to
+	// LOG_FILE_NAME in the working directory.
+	OutputPath string
+	// Console is where the logs are written besides the log file. Defaults
+	// to stdout.
+	Console io.Writer
+}
+
+// InitializeLogger sets up Log at the given level, with the default encoding
+// and log file.
 func InitializeLogger(inputLogLevel string) error {
+	return InitializeLoggerWithConfig(Config{Level: inputLogLevel})
+}
+
+// InitializeLoggerWithConfig sets up Log as configured by cfg.
+func InitializeLoggerWithConfig(cfg Config) error {
+	if cfg.Level == "" {
+		cfg.Level = "DEBUG"
+	}
+	if cfg.OutputPath == "" {
+		cfg.OutputPath = LOG_FILE_NAME
+	}
+	if cfg.Console == nil {
+		cfg.Console = os.Stdout
+	}
 	// create zapper encoding config object
 	config := zap.NewProductionEncoderConfig()
 	// set logging timestamp format
@@ -21,22 +62,42 @@ func InitializeLogger(inputLogLevel string) error {
 	// create encoder for logs that are written to console
 	// we create two encoders because we want to write human readable logs to console and
 	// JSON parsable logs to the file
-	consoleEncoder := zapcore.NewConsoleEncoder(config)
-	// specify log file.
-	logFile, _ := os.OpenFile(LOG_FILE_NAME, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	writer := zapcore.AddSync(logFile)
+	var consoleEncoder zapcore.Encoder
+	switch cfg.Encoding {
+	case "", CONSOLE_ENCODING:
+		consoleEncoder = zapcore.NewConsoleEncoder(config)
+	case JSON_ENCODING:
+		consoleEncoder = zapcore.NewJSONEncoder(config)
+	default:
+		return fmt.Errorf("unknown log encoding %s, expected %s or %s", cfg.Encoding, CONSOLE_ENCODING, JSON_ENCODING)
+	}
 	// create and set the log level from the user input
 	zapLogLevel := new(zapcore.Level)
-	err := zapLogLevel.Set(inputLogLevel)
+	err := zapLogLevel.Set(cfg.Level)
 	if err != nil {
 		return err
 	}
+	// specify log file.
+	logFile, err := os.OpenFile(cfg.OutputPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("can't open log file %s: %v", cfg.OutputPath, err)
+	}
+	writer := zapcore.AddSync(logFile)
 	logLevel := zap.NewAtomicLevelAt(*zapLogLevel)
 	// create the logger
-	core := zapcore.NewTee(
+	cores := []zapcore.Core{
 		zapcore.NewCore(fileEncoder, writer, logLevel),
-		zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), logLevel),
-	)
+		zapcore.NewCore(consoleEncoder, zapcore.AddSync(cfg.Console), logLevel),
+	}
+	// optionally write the logs to Cloud Logging as well
+	cloudCore, err := getCloudCore(logLevel)
+	if err != nil {
+		return err
+	}
+	if cloudCore != nil {
+		cores = append(cores, cloudCore)
+	}
+	core := zapcore.NewTee(cores...)
 	Log = zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
 	return nil
 }
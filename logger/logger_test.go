@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitializeLoggerWithConfig(t *testing.T) {
+	defer func() { Log = nil }()
+	logFile := filepath.Join(t.TempDir(), "smt.log")
+
+	var console bytes.Buffer
+	assert.Nil(t, InitializeLoggerWithConfig(Config{Level: "INFO", Encoding: JSON_ENCODING, OutputPath: logFile, Console: &console}))
+	Log.Debug("not logged")
+	Log.Info("logged")
+	Log.Sync()
+	assert.Contains(t, console.String(), `"msg":"logged"`)
+	assert.NotContains(t, console.String(), "not logged")
+
+	content, err := os.ReadFile(logFile)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Equal(t, 1, len(lines))
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "logged", entry["msg"])
+	assert.Equal(t, "info", entry["level"])
+
+	assert.NotNil(t, InitializeLoggerWithConfig(Config{Encoding: "xml", OutputPath: logFile}))
+	assert.NotNil(t, InitializeLoggerWithConfig(Config{Level: "VERBOSE", OutputPath: logFile}))
+	assert.NotNil(t, InitializeLoggerWithConfig(Config{OutputPath: filepath.Join(t.TempDir(), "missing", "smt.log")}))
+}
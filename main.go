@@ -30,6 +30,7 @@ import (
 	_ "github.com/sijms/go-ora/v2"
 
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/cmd"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/settings"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2"
 	"github.com/google/subcommands"
@@ -39,19 +40,27 @@ import (
 var distDir embed.FS
 
 func main() {
-	ctx := context.Background()
+	// Long-running commands stop on SIGINT and SIGTERM through ctx.
+	ctx, stop := utils.WithShutdownSignals(context.Background())
+	defer stop()
 	lf, err := utils.SetupLogFile()
 	if err != nil {
 		fmt.Printf("\nCan't set up log file: %v\n", err)
 		panic(fmt.Errorf("can't set up log file"))
 	}
 	defer utils.Close(lf)
+	if err := settings.Load(); err != nil {
+		fmt.Printf("\nCan't load the settings: %v\n", err)
+		os.Exit(1)
+	}
 	// Using SMT CLI in subcommand mode.
 	subcommands.Register(subcommands.HelpCommand(), "")
 	subcommands.Register(subcommands.CommandsCommand(), "")
 	subcommands.Register(&cmd.SchemaCmd{}, "")
 	subcommands.Register(&cmd.DataCmd{}, "")
 	subcommands.Register(&cmd.SchemaAndDataCmd{}, "")
+	subcommands.Register(&cmd.CleanupArtifactsCmd{}, "")
+	subcommands.Register(&cmd.ReverseReplicationCmd{}, "")
 	subcommands.Register(&webv2.WebCmd{DistDir: distDir}, "")
 	flag.Parse()
 	os.Exit(int(subcommands.Execute(ctx)))
@@ -56,6 +56,12 @@ func NewSourceProfileFile(params map[string]string) SourceProfileFile {
 	return profile
 }
 
+// IsInfoSchemaSnapshot returns true if the file is an information_schema
+// snapshot of the source database rather than a dump.
+func (f SourceProfileFile) IsInfoSchemaSnapshot() bool {
+	return f.Format == constants.INFOSCHEMA_SNAPSHOT_FORMAT
+}
+
 type SourceProfileConnectionType int
 
 const (
@@ -464,6 +470,7 @@ type DataflowConfig struct {
 	AdditionalUserLabels string `json:"additionalUserLabels"`
 	KmsKeyName           string `json:"kmsKeyName"`
 	GcsTemplatePath      string `json:"gcsTemplatePath"`
+	StreamingEngine      string `json:"streamingEngine"` // "false" to run without Streaming Engine, enabled by default
 }
 
 type DataShard struct {
@@ -483,11 +490,19 @@ type LogicalShard struct {
 	RefDataShardId string `json:"refDataShardId"`
 }
 
+// AlertingConfig enables the creation of Cloud Monitoring alert policies for
+// the dataflow jobs of the migration, notifying NotificationChannel.
+type AlertingConfig struct {
+	NotificationChannel string `json:"notificationChannel"`
+	WatermarkLagMinutes int64  `json:"watermarkLagMinutes"`
+}
+
 type ShardConfigurationDataflow struct {
 	SchemaSource     DirectConnectionConfig `json:"schemaSource"`
 	DataShards       []*DataShard           `json:"dataShards"`
 	DatastreamConfig DatastreamConfig       `json:"datastreamConfig"`
 	DataflowConfig   DataflowConfig         `json:"dataflowConfig"`
+	AlertingConfig   AlertingConfig         `json:"alertingConfig"`
 }
 
 type ShardConfigurationBulk struct {
@@ -567,6 +582,19 @@ func (src SourceProfile) ToLegacyDriver(source string) (string, error) {
 	switch src.Ty {
 	case SourceProfileTypeFile:
 		{
+			if src.File.IsInfoSchemaSnapshot() {
+				// Snapshots are converted like a live database of the same source.
+				switch strings.ToLower(source) {
+				case "mysql":
+					return constants.MYSQL, nil
+				case "postgresql", "postgres", "pg":
+					return constants.POSTGRES, nil
+				case "sqlserver", "mssql":
+					return constants.SQLSERVER, nil
+				default:
+					return "", fmt.Errorf("information_schema snapshots are not supported for source = %v", source)
+				}
+			}
 			switch strings.ToLower(source) {
 			case "mysql":
 				return constants.MYSQLDUMP, nil
@@ -22,8 +22,8 @@ import (
 
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
 	"golang.org/x/net/context"
-	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
 )
 
 type TargetProfileType int
@@ -66,11 +66,11 @@ func (trg TargetProfile) FetchTargetDialect(ctx context.Context) (string, error)
 	adminClient, _ := utils.NewDatabaseAdminClient(ctx)
 	// The parameters are irrelevant because the results are already cached when called the first time.
 	project, instance, dbName, _ := trg.GetResourceIds(ctx, time.Now(), "", nil)
-	result, err := adminClient.GetDatabase(ctx, &adminpb.GetDatabaseRequest{Name: fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, dbName)})
+	dialect, err := spanneradmin.GetDatabaseDialect(ctx, adminClient, fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, dbName))
 	if err != nil {
 		return "", fmt.Errorf("cannot connect to target: %v", err)
 	}
-	return strings.ToLower(result.DatabaseDialect.String()), nil
+	return dialect, nil
 }
 
 func (targetProfile *TargetProfile) GetResourceIds(ctx context.Context, now time.Time, driverName string, out *os.File) (string, string, string, error) {
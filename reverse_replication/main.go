@@ -0,0 +1,7 @@
+package main
+
+import "github.com/GoogleCloudPlatform/spanner-migration-tool/reverse_replication/reverserepl"
+
+func main() {
+	reverserepl.Main()
+}
@@ -0,0 +1,189 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+)
+
+// Table in the metadata database recording the resources created by hand
+// which were adopted by a pipeline.
+const ADOPTED_RESOURCES_TABLE = "ReverseReplicationAdoptedResources"
+
+// Kinds of the resources which can be adopted.
+const (
+	ADOPTED_CHANGE_STREAM = "changeStream"
+	ADOPTED_DATAFLOW_JOB  = "dataflowJob"
+)
+
+// ManualResource is a resource of a pipeline created by hand, e.g. a change
+// stream or Dataflow job created while building the pipeline manually.
+type ManualResource struct {
+	// ADOPTED_CHANGE_STREAM or ADOPTED_DATAFLOW_JOB.
+	Kind string
+	// Name of the change stream, or of the Dataflow job.
+	Name string
+	// Database of the change stream, one of the replicated databases.
+	// Defaults to the first of them.
+	Database string
+}
+
+// getAdoptedResourcesTableDdl returns the statement creating the adopted
+// resources table in a metadata database of the given dialect.
+func getAdoptedResourcesTableDdl(dialect string) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+	"JobNamePrefix" VARCHAR NOT NULL,
+	"Kind" VARCHAR NOT NULL,
+	"Name" VARCHAR NOT NULL,
+	"DatabaseId" VARCHAR NOT NULL,
+	"Tenant" VARCHAR,
+	"AdoptedAt" SPANNER.COMMIT_TIMESTAMP NOT NULL,
+	PRIMARY KEY ("JobNamePrefix", "Kind", "Name", "DatabaseId")
+)`, ADOPTED_RESOURCES_TABLE)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	JobNamePrefix STRING(MAX) NOT NULL,
+	Kind STRING(MAX) NOT NULL,
+	Name STRING(MAX) NOT NULL,
+	DatabaseId STRING(MAX) NOT NULL,
+	Tenant STRING(MAX),
+	AdoptedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+) PRIMARY KEY (JobNamePrefix, Kind, Name, DatabaseId)`, ADOPTED_RESOURCES_TABLE)
+}
+
+// checkManualResource checks that the resource exists and belongs to the
+// pipeline, and returns it with its defaults resolved.
+func (cfg *config) checkManualResource(ctx context.Context, r ManualResource) (ManualResource, error) {
+	switch r.Kind {
+	case ADOPTED_CHANGE_STREAM:
+		dbs := cfg.getDatabaseIds()
+		if r.Database == "" {
+			r.Database = dbs[0]
+		}
+		replicated := false
+		for _, db := range dbs {
+			replicated = replicated || db == r.Database
+		}
+		if !replicated {
+			return r, fmt.Errorf("database %s of change stream %s is not replicated by pipeline %s", r.Database, r.Name, cfg.jobNamePrefix)
+		}
+		adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+		if err != nil {
+			return r, fmt.Errorf("could not create database admin client: %v", err)
+		}
+		defer adminClient.Close()
+		cs, err := findOrphanChangeStream(ctx, adminClient, cfg.getDbUri(r.Database), r.Name)
+		if err != nil {
+			return r, err
+		}
+		if cs == nil {
+			return r, fmt.Errorf("change stream %s not found in %s", r.Name, cfg.getDbUri(r.Database))
+		}
+	case ADOPTED_DATAFLOW_JOB:
+		r.Database = ""
+		jobs, err := cfg.listPipelineJobs(ctx, []string{r.Name})
+		if err != nil {
+			return r, err
+		}
+		if len(jobs[r.Name]) == 0 {
+			return r, fmt.Errorf("dataflow job %s not found in project %s and region %s", r.Name, cfg.projectId, cfg.dataflowRegion)
+		}
+	default:
+		return r, fmt.Errorf("can't adopt %s %s. Supported kinds are %s and %s", r.Kind, r.Name, ADOPTED_CHANGE_STREAM, ADOPTED_DATAFLOW_JOB)
+	}
+	return r, nil
+}
+
+// getAdoptedResources returns the resources adopted by the pipeline, or none
+// if its metadata database doesn't exist.
+func (cfg *config) getAdoptedResources(ctx context.Context) ([]ManualResource, error) {
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	if _, err := spanneradmin.GetDatabaseDialect(ctx, adminClient, cfg.getMetadataDbUri()); err != nil {
+		if gcp.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not check metadata database %s: %v", cfg.getMetadataDbUri(), err)
+	}
+	store, err := getClients(ctx).openMetadataStore(ctx, cfg, adminClient)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the metadata db: %v", err)
+	}
+	defer store.Close()
+	return store.ReadAdoptedResources(ctx)
+}
+
+// getAdoptedJobNames returns the names of the Dataflow jobs adopted by the
+// pipeline.
+func getAdoptedJobNames(adopted []ManualResource) []string {
+	var names []string
+	for _, r := range adopted {
+		if r.Kind == ADOPTED_DATAFLOW_JOB {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+// AdoptResources registers resources created by hand, e.g. an existing change
+// stream or Dataflow job, in the metadata database of the pipeline described
+// by j, creating it if needed, so that the status and cleanup of the pipeline
+// take them into account as if the launcher had created them. Every resource
+// is checked to exist before any is registered.
+func AdoptResources(ctx context.Context, j JobData, resources []ManualResource) error {
+	cfg, err := newConfigWithoutSession(j)
+	if err != nil {
+		return err
+	}
+	ctx = cfg.getWorkflowContext(ctx)
+	if err := cfg.checkTenantAccess(ctx); err != nil {
+		return err
+	}
+	var checked []ManualResource
+	for _, r := range resources {
+		r, err := cfg.checkManualResource(ctx, r)
+		if err != nil {
+			return err
+		}
+		checked = append(checked, r)
+	}
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	dialect, err := spanneradmin.GetDatabaseDialect(ctx, adminClient, cfg.getDbUri(cfg.getDatabaseIds()[0]))
+	if err != nil {
+		return err
+	}
+	metadataDbCreated, err := cfg.createMetadataDatabase(ctx, adminClient, dialect)
+	if err != nil {
+		return err
+	}
+	store, err := getClients(ctx).openMetadataStore(ctx, cfg, adminClient)
+	if err != nil {
+		return fmt.Errorf("could not open the metadata db: %v", err)
+	}
+	defer store.Close()
+	if metadataDbCreated {
+		if err := store.RecordCreatedResource(ctx, createdResource{kind: CREATED_METADATA_DATABASE, name: cfg.metadataDatabase}); err != nil {
+			return err
+		}
+	}
+	if err := cfg.checkTenant(ctx, store); err != nil {
+		return err
+	}
+	for _, r := range checked {
+		if err := store.RecordAdoptedResource(ctx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
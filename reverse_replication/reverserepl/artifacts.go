@@ -0,0 +1,129 @@
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/artifacts"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+)
+
+// isGcsPath returns whether path points to a gcs object rather than to a
+// local file.
+func isGcsPath(path string) bool {
+	return strings.HasPrefix(path, "gs://")
+}
+
+// getArtifactGcsPath returns the gcs path the local file at localPath is
+// uploaded to. The files of a pipeline are kept under a directory named after
+// it, so that pipelines sharing artifactsPath don't overwrite each other's.
+func (cfg *config) getArtifactGcsPath(localPath string) string {
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(cfg.artifactsPath, "/"), cfg.jobNamePrefix, filepath.Base(localPath))
+}
+
+// getDefaultDataflowDir returns the directory of the pipeline under
+// artifactsPath holding the staging and temporary files of its dataflow jobs
+// when stagingLocation is not set, or "" if they are not kept there. The
+// directory belongs to the pipeline, and is deleted with it.
+func (cfg *config) getDefaultDataflowDir() string {
+	if cfg.stagingLocation != "" || cfg.artifactsPath == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/dataflow", strings.TrimSuffix(cfg.artifactsPath, "/"), cfg.jobNamePrefix)
+}
+
+// getDataflowLocations returns the gcs paths of the staging and temporary
+// files of the dataflow jobs. Reusing the same staging location avoids staging
+// the job files again on every launch. Without stagingLocation, tempLocation
+// and artifactsPath, both are "" and Dataflow picks them, creating its own
+// buckets in the project.
+func (cfg *config) getDataflowLocations() (string, string) {
+	var stagingDir, tempDir string
+	base := cfg.stagingLocation
+	if base == "" {
+		base = cfg.getDefaultDataflowDir()
+	}
+	if base != "" {
+		stagingDir = strings.TrimSuffix(base, "/") + "/staging"
+		tempDir = strings.TrimSuffix(base, "/") + "/temp"
+	}
+	if cfg.tempLocation != "" {
+		tempDir = strings.TrimSuffix(cfg.tempLocation, "/")
+	}
+	return stagingDir, tempDir
+}
+
+// uploadArtifact copies the local file at localPath to gcsPath. The upload
+// fails if the checksum of the uploaded object does not match the one of the
+// local file.
+func uploadArtifact(ctx context.Context, gcs StorageAccessor, localPath, gcsPath string) error {
+	bucket, name, err := parseGcsObjectPath(gcsPath)
+	if err != nil {
+		return err
+	}
+	if _, err := artifacts.UploadFile(ctx, gcs, localPath, bucket, name); err != nil {
+		return fmt.Errorf("could not upload %s to %s: %v", localPath, gcsPath, err)
+	}
+	return nil
+}
+
+// uploadSourceShardsFile copies the local source shards file at localPath to
+// gcsPath, with the references to environment variables of its shards, such
+// as the passwords written by GenerateSourceShardsFromProfile, replaced by the
+// values of the variables, as the dataflow jobs read the shards from it.
+func uploadSourceShardsFile(ctx context.Context, gcs StorageAccessor, localPath, gcsPath string) error {
+	b, err := ioutil.ReadFile(localPath)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %v", localPath, err)
+	}
+	var shards []interface{}
+	if err := json.Unmarshal(b, &shards); err != nil {
+		return fmt.Errorf("could not parse %s: %v", localPath, err)
+	}
+	if err := resolveShardSecrets(shards); err != nil {
+		return fmt.Errorf("invalid %s: %v", localPath, err)
+	}
+	if err := writeShardsFile(ctx, gcs, gcsPath, shards); err != nil {
+		return fmt.Errorf("could not upload %s to %s: %v", localPath, gcsPath, err)
+	}
+	return nil
+}
+
+// uploadLocalArtifacts uploads the session files and the source shards file to
+// artifactsPath when they are local files, and points sessionFilePath,
+// sourceShardsFilePath and newSessionFilePath to the uploaded copies read by
+// the dataflow jobs.
+func (cfg *config) uploadLocalArtifacts(ctx context.Context) error {
+	paths := []*string{&cfg.sessionFilePath, &cfg.sourceShardsFilePath, &cfg.newSessionFilePath}
+	var local []*string
+	for _, p := range paths {
+		if *p != "" && !isGcsPath(*p) {
+			local = append(local, p)
+		}
+	}
+	if len(local) == 0 {
+		return nil
+	}
+	gcs, err := getClients(ctx).NewStorageAccessor(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create gcs client: %v", err)
+	}
+	defer gcs.Close()
+	for _, p := range local {
+		gcsPath := cfg.getArtifactGcsPath(*p)
+		upload := uploadArtifact
+		if p == &cfg.sourceShardsFilePath {
+			upload = uploadSourceShardsFile
+		}
+		if err := upload(ctx, gcs, *p, gcsPath); err != nil {
+			return err
+		}
+		logger.FromContext(ctx).Info(fmt.Sprintf("Uploaded %s to %s", *p, gcsPath))
+		*p = gcsPath
+	}
+	return nil
+}
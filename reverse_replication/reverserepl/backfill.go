@@ -0,0 +1,249 @@
+package reverserepl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+const (
+	// Layout of the timestamps written to the source shards by the backfill.
+	BACKFILL_TIMESTAMP_LAYOUT = "2006-01-02 15:04:05.999999"
+	// Precision of the Spanner commit timestamps. The snapshot of the
+	// backfill is read at this precision, so that the changes committed
+	// after it start one tick later.
+	COMMIT_TIMESTAMP_PRECISION = time.Microsecond
+)
+
+// getSnapshotColumnsDdl returns the statements adding the snapshot timestamp
+// columns to the jobs table of a metadata database created before they
+// existed.
+func getSnapshotColumnsDdl(dialect string) []string {
+	var stmts []string
+	for _, col := range []string{"SnapshotTimestamp", "StreamStartTimestamp"} {
+		if dialect == constants.DIALECT_POSTGRESQL {
+			stmts = append(stmts, fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "%s" TIMESTAMPTZ`, JOBS_TABLE, col))
+		} else {
+			stmts = append(stmts, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s TIMESTAMP`, JOBS_TABLE, col))
+		}
+	}
+	return stmts
+}
+
+// getStreamStart returns the timestamp the change streams are read from
+// after a backfill from the snapshot. The snapshot holds the changes
+// committed up to it included, and the change streams return the ones
+// committed from their start timestamp included.
+func getStreamStart(snapshot time.Time) time.Time {
+	return snapshot.Add(COMMIT_TIMESTAMP_PRECISION)
+}
+
+// checkSnapshotWindow fails if the change streams read from start would miss
+// changes committed after the snapshot, or replay changes already in it.
+func checkSnapshotWindow(snapshot, start time.Time) error {
+	if !start.After(snapshot) {
+		return fmt.Errorf("the changes committed from %s to %s would be replicated again after the backfill", start.UTC().Format(time.RFC3339Nano), snapshot.UTC().Format(time.RFC3339Nano))
+	}
+	if start.After(getStreamStart(snapshot.Truncate(COMMIT_TIMESTAMP_PRECISION))) {
+		return fmt.Errorf("the changes committed from %s to %s would not be replicated, as they are neither in the backfill nor in the changestreams", snapshot.UTC().Format(time.RFC3339Nano), start.UTC().Format(time.RFC3339Nano))
+	}
+	return nil
+}
+
+// coordinateBackfill records the timestamps of the backfill snapshot and of
+// the start of the change streams in the jobs table of store, checks from
+// the recorded values that there is no gap or overlap between them, and sets
+// startTimestamp so that the ordering jobs read from the recorded start.
+func (cfg *config) coordinateBackfill(ctx context.Context, store metadataStore, suffixes []string, snapshot time.Time) error {
+	if err := store.RecordSnapshotWindow(ctx, suffixes, snapshot, getStreamStart(snapshot)); err != nil {
+		return err
+	}
+	recordedSnapshot, recordedStart, found, err := store.ReadSnapshotWindow(ctx)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("the snapshot timestamps of pipeline %s were not recorded", cfg.jobNamePrefix)
+	}
+	if err := checkSnapshotWindow(recordedSnapshot, recordedStart); err != nil {
+		return err
+	}
+	start := recordedStart.UTC().Format(time.RFC3339Nano)
+	if cfg.endTimestamp != "" {
+		if end, err := time.Parse(time.RFC3339Nano, cfg.endTimestamp); err == nil && !recordedStart.Before(end) {
+			return fmt.Errorf("endTimestamp %s must be after the start of the changestreams %s", cfg.endTimestamp, start)
+		}
+	}
+	cfg.startTimestamp = start
+	logger.FromContext(ctx).Info(fmt.Sprintf("Backfilled from the snapshot at %s, reading the changestreams from %s", recordedSnapshot.UTC().Format(time.RFC3339Nano), cfg.startTimestamp))
+	return nil
+}
+
+// getSourceLocation returns the location of the timezone offset of the source
+// shards, which validateSource checked.
+func (cfg *config) getSourceLocation() *time.Location {
+	t, err := time.Parse("-07:00", cfg.getSourceDbTimezoneOffset())
+	if err != nil {
+		return time.UTC
+	}
+	return t.Location()
+}
+
+// getBackfillValue returns the value written to the source for a Spanner
+// value, converted as the writer jobs do: timestamps are converted to the
+// timezone of the source shards. NULLs are returned as nil.
+func getBackfillValue(v spanner.GenericColumnValue, loc *time.Location) (interface{}, error) {
+	s, code, err := normalizeSpannerValue(v)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	switch code {
+	case sppb.TypeCode_BYTES:
+		return []byte(*s), nil
+	case sppb.TypeCode_TIMESTAMP:
+		t, err := time.Parse(VALIDATE_TIMESTAMP_LAYOUT, *s)
+		if err != nil {
+			return nil, err
+		}
+		return t.In(loc).Format(BACKFILL_TIMESTAMP_LAYOUT), nil
+	}
+	return *s, nil
+}
+
+// backfillTable copies the rows the table has in the shard in Spanner at the
+// snapshot ts to the source shard, committing every backfillBatchSize rows.
+// It returns the number of rows copied.
+func (cfg *config) backfillTable(ctx context.Context, spClient *spanner.Client, db *sql.DB, t validatedTable, dialect, shardId string, ts time.Time) (int64, error) {
+	var spCols, srcCols, params []string
+	for i := range t.spCols {
+		spCols = append(spCols, quoteSpannerIdentifier(dialect, t.spCols[i]))
+		srcCols = append(srcCols, cfg.quoteSourceIdentifier(t.srcCols[i]))
+		params = append(params, cfg.getSourceQueryParam(i+1))
+	}
+	filter, queryParams := getSpannerShardFilter(t, dialect, shardId)
+	stmt := spanner.Statement{
+		SQL:    fmt.Sprintf("SELECT %s FROM %s%s", strings.Join(spCols, ", "), quoteSpannerIdentifier(dialect, t.spName), filter),
+		Params: queryParams,
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", cfg.quoteSourceIdentifier(t.srcName), strings.Join(srcCols, ", "), strings.Join(params, ", "))
+	loc := cfg.getSourceLocation()
+	var tx *sql.Tx
+	var insertStmt *sql.Stmt
+	var copied, pending int64
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+	err := spClient.Single().WithTimestampBound(spanner.ReadTimestamp(ts)).Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		values := make([]interface{}, row.Size())
+		for i := range values {
+			var gcv spanner.GenericColumnValue
+			if err := row.Column(i, &gcv); err != nil {
+				return err
+			}
+			v, err := getBackfillValue(gcv, loc)
+			if err != nil {
+				return fmt.Errorf("can't decode column %s: %v", t.spCols[i], err)
+			}
+			values[i] = v
+		}
+		if tx == nil {
+			var err error
+			if tx, err = db.BeginTx(ctx, nil); err != nil {
+				return fmt.Errorf("couldn't write the rows of %s to the source: %v", t.srcName, err)
+			}
+			if insertStmt, err = tx.PrepareContext(ctx, insert); err != nil {
+				return fmt.Errorf("couldn't write the rows of %s to the source: %v", t.srcName, err)
+			}
+		}
+		if _, err := insertStmt.ExecContext(ctx, values...); err != nil {
+			return fmt.Errorf("couldn't write a row of %s to the source: %v", t.srcName, err)
+		}
+		if pending++; pending == int64(cfg.backfillBatchSize) {
+			err := tx.Commit()
+			tx = nil
+			if err != nil {
+				return fmt.Errorf("couldn't write the rows of %s to the source: %v", t.srcName, err)
+			}
+			copied += pending
+			pending = 0
+		}
+		return nil
+	})
+	if err != nil {
+		return copied, fmt.Errorf("couldn't copy the rows of %s: %w", t.spName, err)
+	}
+	if tx != nil {
+		err := tx.Commit()
+		tx = nil
+		if err != nil {
+			return copied, fmt.Errorf("couldn't write the rows of %s to the source: %v", t.srcName, err)
+		}
+		copied += pending
+	}
+	return copied, nil
+}
+
+// runBackfill copies the rows the tables of the session file, restricted to
+// backfillTables if set, have in the replicated databases to every source
+// shard, all read at the same snapshot. It returns the timestamp of the
+// snapshot, right after which the change streams are read so that every
+// change is replicated exactly once. The source tables are expected to be
+// empty, as the rows are inserted. The snapshot must be readable during the
+// whole copy, i.e. the copy must complete within the version retention period
+// of the databases.
+func (cfg *config) runBackfill(ctx context.Context, spClients map[string]*spanner.Client, dialect string, shards []interface{}) (time.Time, error) {
+	ts := time.Now().Truncate(COMMIT_TIMESTAMP_PRECISION)
+	sessionJSON, err := readGcsFile(ctx, cfg.sessionFilePath)
+	if err != nil {
+		return ts, err
+	}
+	tables, err := getValidatedTables(sessionJSON, cfg.backfillTables)
+	if err != nil {
+		return ts, err
+	}
+	for _, dbId := range cfg.getDatabaseIds() {
+		spClient := spClients[dbId]
+		dbTables, err := getSpannerTables(ctx, spClient, dialect)
+		if err != nil {
+			return ts, err
+		}
+		for _, s := range shards {
+			shard, ok := s.(map[string]interface{})
+			if !ok {
+				return ts, fmt.Errorf("shard %v is not a json object", s)
+			}
+			shardId, _ := shard["logicalShardId"].(string)
+			connStr, err := cfg.getShardConnectionString(shard)
+			if err != nil {
+				return ts, err
+			}
+			db, err := sql.Open(cfg.getShardDriverName(), connStr)
+			if err != nil {
+				return ts, fmt.Errorf("could not connect to shard %s: %v", shardId, err)
+			}
+			for _, t := range tables {
+				if !dbTables[t.spName] {
+					continue
+				}
+				logger.FromContext(ctx).Info(fmt.Sprintf("Backfilling table %s of shard %s...", t.spName, shardId))
+				copied, err := cfg.backfillTable(ctx, spClient, db, t, dialect, shardId, ts)
+				if err != nil {
+					db.Close()
+					return ts, fmt.Errorf("could not backfill shard %s after copying %d rows of %s: %v", shardId, copied, t.spName, err)
+				}
+				logger.FromContext(ctx).Info(fmt.Sprintf("Copied %d rows of table %s to shard %s", copied, t.spName, shardId))
+			}
+			db.Close()
+		}
+	}
+	return ts, nil
+}
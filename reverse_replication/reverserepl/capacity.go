@@ -0,0 +1,94 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// CPU utilization of the instance above which creating a change stream
+	// needs -forceChangeStream. Change streams add load to the instance, and
+	// Spanner recommends keeping the high priority CPU utilization of
+	// regional instances below 65%.
+	CHANGE_STREAM_MAX_CPU_UTILIZATION = 0.65
+	// Window of the CPU utilization samples checked before creating the
+	// change stream.
+	CAPACITY_METRICS_WINDOW = 30 * time.Minute
+)
+
+// getInstanceCpuUtilization returns the highest CPU utilization, as a
+// fraction, of the Spanner instance over the last CAPACITY_METRICS_WINDOW, and
+// false if Cloud Monitoring reported no value.
+func (cfg *config) getInstanceCpuUtilization(ctx context.Context, client MetricReader) (float64, bool, error) {
+	now := time.Now()
+	series, err := client.ListTimeSeries(ctx, &monitoringpb.ListTimeSeriesRequest{
+		Name:   fmt.Sprintf("projects/%s", cfg.projectId),
+		Filter: fmt.Sprintf(`metric.type = "spanner.googleapis.com/instance/cpu/utilization" AND resource.labels.instance_id = "%s"`, cfg.instanceId),
+		Interval: &monitoringpb.TimeInterval{
+			StartTime: timestamppb.New(now.Add(-CAPACITY_METRICS_WINDOW)),
+			EndTime:   timestamppb.New(now),
+		},
+		// The utilization is reported per database and priority, which are
+		// summed up to the utilization of the instance.
+		Aggregation: &monitoringpb.Aggregation{
+			AlignmentPeriod:    durationpb.New(time.Minute),
+			PerSeriesAligner:   monitoringpb.Aggregation_ALIGN_MEAN,
+			CrossSeriesReducer: monitoringpb.Aggregation_REDUCE_SUM,
+		},
+		View: monitoringpb.ListTimeSeriesRequest_FULL,
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("could not read the cpu utilization of instance %s: %v", cfg.instanceId, err)
+	}
+	utilization, reported := 0.0, false
+	for _, ts := range series {
+		for _, p := range ts.Points {
+			if v := p.GetValue().GetDoubleValue(); !reported || v > utilization {
+				utilization, reported = v, true
+			}
+		}
+	}
+	return utilization, reported, nil
+}
+
+// checkChangeStreamCapacity checks that the Spanner instance has spare CPU
+// for the change stream about to be created. It fails if the instance was
+// recently above CHANGE_STREAM_MAX_CPU_UTILIZATION, or only warns with
+// -forceChangeStream. The processing units of the instance are looked up in
+// instances.
+func (cfg *config) checkChangeStreamCapacity(ctx context.Context, instances *spanneradmin.InstanceInfoCache) error {
+	info, err := instances.GetInstanceInfo(ctx, fmt.Sprintf("projects/%s/instances/%s", cfg.projectId, cfg.instanceId))
+	if err != nil {
+		return err
+	}
+	metricClient, err := getClients(ctx).NewMetricReader(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create monitoring client: %v", err)
+	}
+	defer metricClient.Close()
+	utilization, reported, err := cfg.getInstanceCpuUtilization(ctx, metricClient)
+	if err != nil {
+		return err
+	}
+	if !reported {
+		logger.FromContext(ctx).Info(fmt.Sprintf("No recent cpu utilization reported for instance %s, skipping the capacity check", cfg.instanceId))
+		return nil
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Instance %s has %d processing units, peak cpu utilization over the last %s: %.0f%%", cfg.instanceId, info.ProcessingUnits, CAPACITY_METRICS_WINDOW, utilization*100))
+	if utilization <= CHANGE_STREAM_MAX_CPU_UTILIZATION {
+		return nil
+	}
+	msg := fmt.Sprintf("the cpu utilization of instance %s reached %.0f%%, above the recommended %.0f%%. The change stream adds load to the instance, consider adding processing units first", cfg.instanceId, utilization*100, CHANGE_STREAM_MAX_CPU_UTILIZATION*100)
+	if !cfg.forceChangeStream {
+		return fmt.Errorf("%s, or rerun with -forceChangeStream", msg)
+	}
+	logger.FromContext(ctx).Warn(fmt.Sprintf("%s.", msg))
+	return nil
+}
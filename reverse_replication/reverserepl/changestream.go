@@ -0,0 +1,221 @@
+package reverserepl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/tracing"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/api/iterator"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+const (
+	// Value capture type and retention period used by Spanner when the
+	// options are not set.
+	DEFAULT_VALUE_CAPTURE_TYPE = "OLD_AND_NEW_VALUES"
+	DEFAULT_RETENTION_PERIOD   = "1d"
+	// Bounds of the changeStreamRetention flag.
+	MIN_CHANGE_STREAM_RETENTION = 24 * time.Hour
+	MAX_CHANGE_STREAM_RETENTION = 7 * 24 * time.Hour
+)
+
+// Value capture types of the change stream supported by reverse replication,
+// which need the full new row of every change.
+var supportedValueCaptureTypes = []string{"NEW_ROW", "NEW_ROW_AND_OLD_VALUES"}
+
+var retentionPeriodRegex = regexp.MustCompile(`^(\d+)([dhms])$`)
+
+// parseRetentionPeriod parses a change stream retention_period option value,
+// e.g. 36h or 7d.
+func parseRetentionPeriod(period string) (time.Duration, error) {
+	m := retentionPeriodRegex.FindStringSubmatch(period)
+	if m == nil {
+		return 0, fmt.Errorf("invalid retention period '%s', expected a number followed by one of d, h, m or s", period)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid retention period '%s': %v", period, err)
+	}
+	unit := map[string]time.Duration{"d": 24 * time.Hour, "h": time.Hour, "m": time.Minute, "s": time.Second}[m[2]]
+	return time.Duration(n) * unit, nil
+}
+
+// checkChangeStreamFlags validates the retention period and the value capture
+// type of the change stream.
+func (cfg *config) checkChangeStreamFlags() error {
+	retention, err := parseRetentionPeriod(cfg.changeStreamRetention)
+	if err != nil {
+		return fmt.Errorf("please specify a valid changeStreamRetention: %v", err)
+	}
+	if retention < MIN_CHANGE_STREAM_RETENTION || retention > MAX_CHANGE_STREAM_RETENTION {
+		return fmt.Errorf("please specify a changeStreamRetention between 1d and 7d, got %s", cfg.changeStreamRetention)
+	}
+	for _, t := range supportedValueCaptureTypes {
+		if cfg.changeStreamValueCaptureType == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("please specify a valid changeStreamValueCaptureType, one of %s", strings.Join(supportedValueCaptureTypes, ", "))
+}
+
+// getQueryParam returns the placeholder of the n-th query parameter, named
+// pn in the statement params, in the dialect of the database.
+func getQueryParam(dialect string, n int) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf("$%d", n)
+	}
+	return fmt.Sprintf("@p%d", n)
+}
+
+// getCreateChangeStreamStmt returns the statement creating the change stream
+// with the watch clause, e.g. FOR ALL, and the requested value capture type
+// and retention period.
+func (cfg *config) getCreateChangeStreamStmt(dialect, watch string) string {
+	options := fmt.Sprintf("value_capture_type = '%s', retention_period = '%s'", cfg.changeStreamValueCaptureType, cfg.changeStreamRetention)
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf("CREATE CHANGE STREAM %s %s WITH (%s)", quoteIdentifier(dialect, cfg.changeStreamName), watch, options)
+	}
+	return fmt.Sprintf("CREATE CHANGE STREAM %s %s OPTIONS (%s)", cfg.changeStreamName, watch, options)
+}
+
+// getAlterChangeStreamStmt returns the statement setting the given option
+// assignments on the change stream.
+func (cfg *config) getAlterChangeStreamStmt(dialect string, assignments []string) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf("ALTER CHANGE STREAM %s SET (%s)", quoteIdentifier(dialect, cfg.changeStreamName), strings.Join(assignments, ", "))
+	}
+	return fmt.Sprintf("ALTER CHANGE STREAM %s SET OPTIONS (%s)", cfg.changeStreamName, strings.Join(assignments, ", "))
+}
+
+// getChangeStreamOptions returns the options explicitly set on the change
+// stream, keyed by option name.
+func (cfg *config) getChangeStreamOptions(ctx context.Context, spClient *spanner.Client, dialect string) (map[string]string, error) {
+	stmt := spanner.Statement{
+		SQL: `SELECT option_name, option_value FROM information_schema.change_stream_options WHERE change_stream_name = ` + getQueryParam(dialect, 1),
+		Params: map[string]interface{}{
+			"p1": cfg.changeStreamName,
+		},
+	}
+	iter := spClient.Single().Query(ctx, stmt)
+	defer iter.Stop()
+	options := make(map[string]string)
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read row from change_stream_options table: %w", err)
+		}
+		var name, value string
+		if err := row.Columns(&name, &value); err != nil {
+			return nil, fmt.Errorf("can't scan row from change_stream_options table: %v", err)
+		}
+		options[strings.ToLower(name)] = value
+	}
+	return options, nil
+}
+
+// getChangeStreamOptionFixes compares the options of an existing change
+// stream with the requested value capture type and minimum retention period,
+// and returns the option
+// assignments needed to fix it. An empty result means the change stream can
+// be used as is.
+func (cfg *config) getChangeStreamOptionFixes(options map[string]string) ([]string, error) {
+	var fixes []string
+	valueCaptureType, ok := options["value_capture_type"]
+	if !ok {
+		valueCaptureType = DEFAULT_VALUE_CAPTURE_TYPE
+	}
+	if valueCaptureType != cfg.changeStreamValueCaptureType {
+		fixes = append(fixes, fmt.Sprintf("value_capture_type = '%s'", cfg.changeStreamValueCaptureType))
+	}
+	retention, ok := options["retention_period"]
+	if !ok {
+		retention = DEFAULT_RETENTION_PERIOD
+	}
+	current, err := parseRetentionPeriod(retention)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse retention_period of changestream %s: %v", cfg.changeStreamName, err)
+	}
+	required, err := parseRetentionPeriod(cfg.changeStreamRetention)
+	if err != nil {
+		return nil, err
+	}
+	if current < required {
+		fixes = append(fixes, fmt.Sprintf("retention_period = '%s'", cfg.changeStreamRetention))
+	}
+	return fixes, nil
+}
+
+// getChangeStreamRollback returns the statement restoring the original options
+// of the change stream, undoing fixChangeStreamOptions. Options which were not
+// set are reset to null in GoogleSQL, and set to the Spanner defaults in
+// PostgreSQL which doesn't accept null option values.
+func (cfg *config) getChangeStreamRollback(options map[string]string, dialect string) string {
+	valueCaptureType, retention := "null", "null"
+	if dialect == constants.DIALECT_POSTGRESQL {
+		valueCaptureType = fmt.Sprintf("'%s'", DEFAULT_VALUE_CAPTURE_TYPE)
+		retention = fmt.Sprintf("'%s'", DEFAULT_RETENTION_PERIOD)
+	}
+	if v, ok := options["value_capture_type"]; ok {
+		valueCaptureType = fmt.Sprintf("'%s'", v)
+	}
+	if v, ok := options["retention_period"]; ok {
+		retention = fmt.Sprintf("'%s'", v)
+	}
+	return cfg.getAlterChangeStreamStmt(dialect, []string{"value_capture_type = " + valueCaptureType, "retention_period = " + retention})
+}
+
+// fixChangeStreamOptions alters the change stream to the required options
+// once the user confirms. The statement restoring the original options is
+// written to a file in the current directory before the change stream is
+// altered, so that the change can be rolled back.
+func (cfg *config) fixChangeStreamOptions(ctx context.Context, adminClient *database.DatabaseAdminClient, dbUri, dialect string, options map[string]string, fixes []string) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "AlterChangeStream", attribute.String("database", dbUri), attribute.String("changeStream", cfg.changeStreamName))
+	defer func() { tracing.EndSpan(span, err) }()
+	stmt := cfg.getAlterChangeStreamStmt(dialect, fixes)
+	fmt.Printf("\nchangestream %s does not have the options required for reverse replication. The following statement will be run:\n%s\n", cfg.changeStreamName, stmt)
+	fmt.Print("Proceed? (y/N): ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return fmt.Errorf("altering changestream %s was not confirmed", cfg.changeStreamName)
+	}
+
+	rollback := cfg.getChangeStreamRollback(options, dialect)
+	rollbackFile := fmt.Sprintf("%s-%s-rollback.sql", cfg.jobNamePrefix, cfg.changeStreamName)
+	if err := ioutil.WriteFile(rollbackFile, []byte(rollback+";\n"), 0644); err != nil {
+		return fmt.Errorf("could not record the original changestream options: %v", err)
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Original changestream options recorded in %s", rollbackFile))
+
+	op, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "UpdateDatabaseDdl", Resource: dbUri}, func(ctx context.Context) (*database.UpdateDatabaseDdlOperation, error) {
+		return adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+			Database:   dbUri,
+			Statements: []string{stmt},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("cannot submit alter change stream request: %v", err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("could not alter changestream %s: %v", cfg.changeStreamName, err)
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Successfully updated options of changestream %s", cfg.changeStreamName))
+	return nil
+}
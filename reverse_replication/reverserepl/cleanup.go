@@ -0,0 +1,582 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/dataflowjobs"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/tracing"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/api/iterator"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+// orphanResource is a resource created by the launcher which still exists even
+// though none of the Dataflow jobs using it are running.
+type orphanResource struct {
+	kind   string
+	name   string
+	delete func(ctx context.Context) error
+}
+
+// isTerminalJobState returns true if a Dataflow job in the given state will
+// never process any more data.
+func isTerminalJobState(state dataflowpb.JobState) bool {
+	return dataflowjobs.IsTerminalJobState(state)
+}
+
+// listPipelineJobs returns all the Dataflow jobs launched for this pipeline,
+// keyed by job name. Job names which were never launched (or which Dataflow
+// no longer reports) are absent from the map.
+func (cfg *config) listPipelineJobs(ctx context.Context, jobNames []string) (map[string][]*dataflowpb.Job, error) {
+	wanted := make(map[string]bool)
+	for _, name := range jobNames {
+		wanted[name] = true
+	}
+	return cfg.listJobs(ctx, func(name string) bool { return wanted[name] })
+}
+
+// listJobs returns the Dataflow jobs of the project and region whose name is
+// accepted by keep, keyed by job name.
+func (cfg *config) listJobs(ctx context.Context, keep func(name string) bool) (map[string][]*dataflowpb.Job, error) {
+	c, err := getClients(ctx).NewDataflowAccessor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create dataflow client: %v", err)
+	}
+	defer c.Close()
+	all, err := c.ListJobs(ctx, cfg.projectId, cfg.dataflowRegion)
+	if err != nil {
+		return nil, fmt.Errorf("could not list dataflow jobs: %v", err)
+	}
+	jobs := make(map[string][]*dataflowpb.Job)
+	for _, job := range all {
+		if keep(job.Name) {
+			jobs[job.Name] = append(jobs[job.Name], job)
+		}
+	}
+	return jobs, nil
+}
+
+// getPipelineJobStates returns the states of all the Dataflow jobs launched
+// for this pipeline, keyed by job name. Job names which were never launched
+// (or which Dataflow no longer reports) are absent from the map.
+func (cfg *config) getPipelineJobStates(ctx context.Context, jobNames []string) (map[string][]dataflowpb.JobState, error) {
+	jobs, err := cfg.listPipelineJobs(ctx, jobNames)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string][]dataflowpb.JobState)
+	for name, nameJobs := range jobs {
+		for _, job := range nameJobs {
+			states[name] = append(states[name], job.CurrentState)
+		}
+	}
+	return states, nil
+}
+
+// cleanupOrphans cross-references the resources created by the launcher
+// against the state of the ordering and writer Dataflow jobs. If none of the
+// jobs are running any more (all of them FAILED, CANCELLED, DRAINED etc. or
+// never launched), the change stream, metadata and Pub/Sub resources they were
+// using are reported as orphaned and, unless dryRun is set, deleted. The
+// metadata database and Pub/Sub resources shared with other pipelines are
+// kept, see findOrphanResources.
+func (cfg *config) cleanupOrphans(ctx context.Context) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "CleanupOrphans", attribute.String("jobNamePrefix", cfg.jobNamePrefix))
+	defer func() { tracing.EndSpan(span, err) }()
+	shards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return err
+	}
+	shardIds, err := getLogicalShardIds(shards)
+	if err != nil {
+		return err
+	}
+	numWriterGroups := len(partitionShards(shards, cfg.writerFanOut))
+	jobNames := append(cfg.getOrderingJobNames(cfg.getDatabaseIds()), cfg.getWriterJobNames(numWriterGroups)...)
+	jobNames = append(jobNames, cfg.getReprocessJobName())
+	adopted, err := cfg.getAdoptedResources(ctx)
+	if err != nil {
+		return err
+	}
+	jobNames = append(jobNames, getAdoptedJobNames(adopted)...)
+	states, err := cfg.getPipelineJobStates(ctx, jobNames)
+	if err != nil {
+		return err
+	}
+	for _, name := range jobNames {
+		if len(states[name]) == 0 {
+			logger.FromContext(ctx).Info(fmt.Sprintf("dataflow job %s not found", name))
+			continue
+		}
+		for _, state := range states[name] {
+			if !isTerminalJobState(state) {
+				return fmt.Errorf("dataflow job %s is in state %s, the pipeline is still active. Please cancel or drain the job before cleaning up its resources", name, state)
+			}
+		}
+		logger.FromContext(ctx).Info(fmt.Sprintf("dataflow job %s is in terminal state %s", name, states[name][len(states[name])-1]))
+	}
+
+	orphans, closeClients, err := cfg.findOrphanResources(ctx, shardIds, numWriterGroups)
+	if err != nil {
+		return err
+	}
+	defer closeClients()
+	if len(orphans) == 0 {
+		logger.FromContext(ctx).Info("No orphaned resources found")
+		return nil
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Found %d orphaned resource(s):", len(orphans)))
+	for _, o := range orphans {
+		logger.FromContext(ctx).Info(fmt.Sprintf("%s: %s", o.kind, o.name))
+	}
+	if cfg.dryRun {
+		logger.FromContext(ctx).Info("dryRun is set, skipping deletion")
+		return nil
+	}
+	failed := false
+	for _, o := range orphans {
+		if err := o.delete(ctx); err != nil {
+			logger.FromContext(ctx).Warn(fmt.Sprintf("could not delete %s %s: %v", o.kind, o.name, err))
+			failed = true
+			continue
+		}
+		logger.FromContext(ctx).Info(fmt.Sprintf("Deleted %s %s", o.kind, o.name))
+	}
+	if failed {
+		return fmt.Errorf("some orphaned resources could not be deleted, please clean them up manually")
+	}
+	return nil
+}
+
+// findOrphanResources returns the launcher created resources which still
+// exist. The change streams and the metadata database which already existed
+// when the pipeline was launched are reported as kept, as only the ones
+// recorded in the created resources table were created by the launcher. The
+// resources shared with the other pipelines using the metadata
+// database, such as the Pub/Sub topic and the subscriptions named after the
+// shards, are only returned once the suffix registry shows no other pipeline.
+// The resources are deleted through the clients closed by closeClients, which
+// must be called once they are deleted, or right away if they are only listed.
+func (cfg *config) findOrphanResources(ctx context.Context, shardIds []string, numWriterGroups int) (orphans []orphanResource, closeClients func(), err error) {
+	var closers []func() error
+	closeAll := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+	defer func() {
+		if err != nil {
+			closeAll()
+		}
+	}()
+
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create database admin client: %v", err)
+	}
+	closers = append(closers, adminClient.Close)
+	gcs, err := getClients(ctx).NewStorageAccessor(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create gcs client: %v", err)
+	}
+	closers = append(closers, gcs.Close)
+	created, err := cfg.getCreatedResources(ctx, adminClient)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadataOrphans, shared, err := cfg.findOrphanMetadata(ctx, adminClient, created)
+	if err != nil {
+		return nil, nil, err
+	}
+	if shared {
+		logger.FromContext(ctx).Info(fmt.Sprintf("pubsub topic %s and the subscriptions of the shards are kept, the metadata database %s is used by other pipelines", cfg.pubSubDataTopicId, cfg.getMetadataDbUri()))
+	} else {
+		pubsubClient, err := getClients(ctx).NewPubsubClient(ctx, cfg.projectId)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not create pubsub client: %v", err)
+		}
+		closers = append(closers, pubsubClient.Close)
+		pubsubOrphans, err := cfg.findOrphanPubsubResources(ctx, pubsubClient, shardIds)
+		if err != nil {
+			return nil, nil, err
+		}
+		orphans = append(orphans, pubsubOrphans...)
+	}
+
+	// The change streams created by hand and adopted by the pipeline are
+	// deleted along with the created ones.
+	adopted, err := cfg.getAdoptedResources(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, db := range cfg.getDatabaseIds() {
+		dbUri := cfg.getDbUri(db)
+		csOrphan, err := findOrphanChangeStream(ctx, adminClient, dbUri, cfg.changeStreamName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if csOrphan == nil {
+			continue
+		}
+		isAdopted := false
+		for _, r := range adopted {
+			isAdopted = isAdopted || (r == ManualResource{Kind: ADOPTED_CHANGE_STREAM, Name: cfg.changeStreamName, Database: db})
+		}
+		if !isAdopted && !isCreatedResource(created, CREATED_CHANGE_STREAM, cfg.changeStreamName, db) {
+			logger.FromContext(ctx).Info(fmt.Sprintf("change stream %s is kept, it existed before the pipeline was launched", csOrphan.name))
+			continue
+		}
+		orphans = append(orphans, *csOrphan)
+	}
+	for _, r := range adopted {
+		if r.Kind != ADOPTED_CHANGE_STREAM || r.Name == cfg.changeStreamName {
+			continue
+		}
+		csOrphan, err := findOrphanChangeStream(ctx, adminClient, cfg.getDbUri(r.Database), r.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if csOrphan != nil {
+			orphans = append(orphans, *csOrphan)
+		}
+	}
+	orphans = append(orphans, metadataOrphans...)
+
+	var shardsFilePaths []string
+	if numWriterGroups > 1 {
+		for i := 0; i < numWriterGroups; i++ {
+			shardsFilePaths = append(shardsFilePaths, cfg.getShardGroupFilePath(i))
+		}
+	}
+	shardsFilePaths = append(shardsFilePaths, cfg.getReprocessShardsFilePath())
+	gcsOrphans, err := findGcsFiles(ctx, gcs, "writer shards file", shardsFilePaths)
+	if err != nil {
+		return nil, nil, err
+	}
+	orphans = append(orphans, gcsOrphans...)
+	if cfg.shardingFunction != SHARDING_FUNCTION_IDENTITY {
+		gcsOrphans, err := findGcsFiles(ctx, gcs, "sharding config file", []string{cfg.getShardingConfigFilePath()})
+		if err != nil {
+			return nil, nil, err
+		}
+		orphans = append(orphans, gcsOrphans...)
+	}
+	// The staging and temp files are only deleted when they are in the
+	// directory of the pipeline, stagingLocation and tempLocation may be
+	// shared with other pipelines.
+	if dir := cfg.getDefaultDataflowDir(); dir != "" {
+		dirOrphan, err := findGcsDirectory(ctx, gcs, "dataflow staging and temp files", dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		if dirOrphan != nil {
+			orphans = append(orphans, *dirOrphan)
+		}
+	}
+	return orphans, closeAll, nil
+}
+
+// findOrphanPubsubResources returns the subscriptions of the shards reading
+// the topic of the pipeline, and the topic unless other subscriptions read it.
+func (cfg *config) findOrphanPubsubResources(ctx context.Context, client *pubsub.Client, shardIds []string) ([]orphanResource, error) {
+	topic := client.Topic(cfg.pubSubDataTopicId)
+	var orphans []orphanResource
+	subs := make(map[string]bool)
+	for _, shardId := range shardIds {
+		sub := client.Subscription(shardId)
+		cfgSub, err := gcp.DoWithResult(ctx, gcp.Call{Service: gcp.PUBSUB, Method: "GetSubscription", Resource: sub.String(), Idempotent: true}, sub.Config)
+		if gcp.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not check subscription %s: %v", shardId, err)
+		}
+		// A subscription of the same name may read the topic of another
+		// pipeline.
+		if cfgSub.Topic == nil || cfgSub.Topic.String() != topic.String() {
+			continue
+		}
+		subs[sub.String()] = true
+		orphans = append(orphans, orphanResource{kind: "pubsub subscription", name: sub.String(), delete: func(ctx context.Context) error {
+			return gcp.Do(ctx, gcp.Call{Service: gcp.PUBSUB, Method: "DeleteSubscription", Resource: sub.String()}, sub.Delete)
+		}})
+	}
+	exists, err := gcp.DoWithResult(ctx, gcp.Call{Service: gcp.PUBSUB, Method: "GetTopic", Resource: topic.String(), Idempotent: true}, topic.Exists)
+	if err != nil {
+		return nil, fmt.Errorf("could not check topic %s: %v", cfg.pubSubDataTopicId, err)
+	}
+	if !exists {
+		return orphans, nil
+	}
+	var others []string
+	err = gcp.Do(ctx, gcp.Call{Service: gcp.PUBSUB, Method: "ListTopicSubscriptions", Resource: topic.String(), Idempotent: true}, func(ctx context.Context) error {
+		others = nil
+		it := topic.Subscriptions(ctx)
+		for {
+			sub, err := it.Next()
+			if err == iterator.Done {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !subs[sub.String()] {
+				others = append(others, sub.String())
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list the subscriptions of topic %s: %v", cfg.pubSubDataTopicId, err)
+	}
+	if len(others) > 0 {
+		logger.FromContext(ctx).Info(fmt.Sprintf("pubsub topic %s is kept, it is read by %d other subscription(s)", topic.String(), len(others)))
+		return orphans, nil
+	}
+	return append(orphans, orphanResource{kind: "pubsub topic", name: topic.String(), delete: func(ctx context.Context) error {
+		return gcp.Do(ctx, gcp.Call{Service: gcp.PUBSUB, Method: "DeleteTopic", Resource: topic.String()}, topic.Delete)
+	}}), nil
+}
+
+// findOrphanMetadata returns the metadata of the pipeline. The metadata
+// database, along with the metadata instance created for it, is returned when
+// it is among the created resources and its suffix registry shows no other
+// pipeline. Otherwise only the change stream metadata tables of the suffixes
+// registered by the pipeline and its rows in the tables of the launcher are
+// returned, and shared is true if other pipelines use the database.
+func (cfg *config) findOrphanMetadata(ctx context.Context, adminClient *database.DatabaseAdminClient, created []createdResource) (orphans []orphanResource, shared bool, err error) {
+	metadataDbUri := cfg.getMetadataDbUri()
+	_, err = adminClient.GetDatabase(ctx, &adminpb.GetDatabaseRequest{Name: metadataDbUri})
+	if err != nil && !gcp.IsNotFound(err) {
+		return nil, false, fmt.Errorf("could not check metadata database %s: %v", metadataDbUri, err)
+	}
+	if err == nil {
+		store, err := getClients(ctx).openMetadataStore(ctx, cfg, adminClient)
+		if err != nil {
+			return nil, false, fmt.Errorf("could not open the metadata db: %v", err)
+		}
+		defer store.Close()
+		owners, err := store.ReadSuffixOwners(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		users := getOtherSuffixUsers(owners, cfg.jobNamePrefix)
+		metadataDbCreated := isCreatedResource(created, CREATED_METADATA_DATABASE, cfg.metadataDatabase, "")
+		if len(users) > 0 || !metadataDbCreated {
+			tables, err := store.ListChangeStreamMetadataTables(ctx)
+			if err != nil {
+				return nil, false, err
+			}
+			_, _, hasDefinition, err := store.ReadJobDefinition(ctx)
+			if err != nil {
+				return nil, false, err
+			}
+			hasRecords := hasDefinition
+			for _, owner := range owners {
+				hasRecords = hasRecords || owner.jobNamePrefix == cfg.jobNamePrefix
+			}
+			if len(users) == 0 {
+				logger.FromContext(ctx).Info(fmt.Sprintf("metadata database %s is kept, it existed before the pipeline was launched. Only the metadata of pipeline %s is deleted", metadataDbUri, cfg.jobNamePrefix))
+				return cfg.getOrphanPipelineMetadata(adminClient, getSuffixTables(tables, owners, cfg.jobNamePrefix), hasRecords), false, nil
+			}
+			logger.FromContext(ctx).Info(fmt.Sprintf("metadata database %s is used by %d other pipeline(s), only the metadata of pipeline %s is deleted", metadataDbUri, len(users), cfg.jobNamePrefix))
+			return cfg.getOrphanPipelineMetadata(adminClient, getSuffixTables(tables, owners, cfg.jobNamePrefix), hasRecords), true, nil
+		}
+		orphans = append(orphans, orphanResource{kind: "metadata database", name: metadataDbUri, delete: func(ctx context.Context) error {
+			return adminClient.DropDatabase(ctx, &adminpb.DropDatabaseRequest{Database: metadataDbUri})
+		}})
+	}
+	// Deleted after its metadata database.
+	if cfg.createMetadataInstance {
+		instanceOrphan, err := cfg.findOrphanMetadataInstance(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		if instanceOrphan != nil {
+			orphans = append(orphans, *instanceOrphan)
+		}
+	}
+	return orphans, false, nil
+}
+
+// getOrphanPipelineMetadata returns the change stream metadata tables of the
+// pipeline, and its rows in the tables of the launcher if hasRecords is set,
+// deleted through the metadata store.
+func (cfg *config) getOrphanPipelineMetadata(adminClient *database.DatabaseAdminClient, tables []string, hasRecords bool) []orphanResource {
+	withStore := func(ctx context.Context, f func(store metadataStore) error) error {
+		store, err := getClients(ctx).openMetadataStore(ctx, cfg, adminClient)
+		if err != nil {
+			return fmt.Errorf("could not open the metadata db: %v", err)
+		}
+		defer store.Close()
+		return f(store)
+	}
+	var orphans []orphanResource
+	if len(tables) > 0 {
+		orphans = append(orphans, orphanResource{kind: "change stream metadata tables", name: fmt.Sprintf("%s in %s", strings.Join(tables, ", "), cfg.getMetadataDbUri()), delete: func(ctx context.Context) error {
+			return withStore(ctx, func(store metadataStore) error { return store.DropTables(ctx, tables) })
+		}})
+	}
+	// Deleted after the tables, which a rerun finds through the suffixes
+	// registered by the pipeline.
+	if hasRecords {
+		orphans = append(orphans, orphanResource{kind: "metadata records", name: fmt.Sprintf("rows of pipeline %s in %s", cfg.jobNamePrefix, cfg.getMetadataDbUri()), delete: func(ctx context.Context) error {
+			return withStore(ctx, func(store metadataStore) error { return store.DeletePipelineRecords(ctx) })
+		}})
+	}
+	return orphans
+}
+
+// getOtherSuffixUsers returns the pipelines other than jobNamePrefix which
+// registered a metadata table suffix, sorted.
+func getOtherSuffixUsers(owners map[string]suffixOwner, jobNamePrefix string) []string {
+	seen := make(map[string]bool)
+	var users []string
+	for _, owner := range owners {
+		if owner.jobNamePrefix != jobNamePrefix && !seen[owner.jobNamePrefix] {
+			seen[owner.jobNamePrefix] = true
+			users = append(users, owner.jobNamePrefix)
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// getSuffixTables returns the change stream metadata tables among tables which
+// belong to the suffixes registered by jobNamePrefix. A table belongs to the
+// longest registered suffix its name ends with, so that e.g. the tables of the
+// suffix orders_2 are not taken for the ones of the suffix 2, and the tables
+// of the empty suffix are the ones no other suffix claims.
+func getSuffixTables(tables []string, owners map[string]suffixOwner, jobNamePrefix string) []string {
+	var owned []string
+	for _, table := range tables {
+		suffix, found := "", false
+		for s := range owners {
+			if strings.HasSuffix(table, s) && (!found || len(s) > len(suffix)) {
+				suffix, found = s, true
+			}
+		}
+		if found && owners[suffix].jobNamePrefix == jobNamePrefix {
+			owned = append(owned, table)
+		}
+	}
+	return owned
+}
+
+// findGcsDirectory returns the objects under the gcs directory dir as a
+// single resource, or nil if there are none. kind names the objects in the
+// output.
+func findGcsDirectory(ctx context.Context, s StorageAccessor, kind, dir string) (*orphanResource, error) {
+	bucket, prefix, err := parseGcsObjectPath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s path %s", kind, dir)
+	}
+	objects, err := s.ListObjects(ctx, bucket, strings.TrimSuffix(prefix, "/")+"/")
+	if err != nil {
+		return nil, fmt.Errorf("could not check %s %s: %v", kind, dir, err)
+	}
+	if len(objects) == 0 {
+		return nil, nil
+	}
+	return &orphanResource{kind: kind, name: fmt.Sprintf("%s (%d objects)", dir, len(objects)), delete: func(ctx context.Context) error {
+		for _, o := range objects {
+			if err := s.DeleteObject(ctx, o.Bucket, o.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}, nil
+}
+
+// findOrphanChangeStream returns the change stream named name in the database
+// at dbUri, or nil if it does not exist.
+func findOrphanChangeStream(ctx context.Context, adminClient *database.DatabaseAdminClient, dbUri, name string) (*orphanResource, error) {
+	spClient, err := getClients(ctx).NewSpannerClient(ctx, dbUri)
+	if err != nil {
+		return nil, fmt.Errorf("could not create spanner client: %v", err)
+	}
+	defer spClient.Close()
+	dialect, err := spanneradmin.GetDatabaseDialect(ctx, adminClient, dbUri)
+	if err != nil {
+		return nil, err
+	}
+	csExists, err := changeStreamExists(ctx, spClient, dialect, name)
+	if err != nil {
+		return nil, err
+	}
+	if !csExists {
+		return nil, nil
+	}
+	return &orphanResource{kind: "change stream", name: fmt.Sprintf("%s in %s", name, dbUri), delete: func(ctx context.Context) error {
+		return dropChangeStream(ctx, adminClient, dbUri, dialect, name)
+	}}, nil
+}
+
+// findGcsFiles returns the files uploaded for the jobs among paths, e.g. the
+// shards files of the writer jobs when the writers were fanned out or changes
+// were reprocessed. kind names the files in the output.
+func findGcsFiles(ctx context.Context, s StorageAccessor, kind string, paths []string) ([]orphanResource, error) {
+	var orphans []orphanResource
+	for _, path := range paths {
+		bucket, name, err := parseGcsObjectPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s path %s: %v", kind, path, err)
+		}
+		// Listing by the name as prefix also returns the objects whose name
+		// starts with it, so only an exact match counts.
+		objects, err := s.ListObjects(ctx, bucket, name)
+		if err != nil {
+			return nil, fmt.Errorf("could not check %s %s: %v", kind, path, err)
+		}
+		for _, o := range objects {
+			if o.Name != name {
+				continue
+			}
+			orphans = append(orphans, orphanResource{kind: kind, name: path, delete: func(ctx context.Context) error {
+				return s.DeleteObject(ctx, bucket, name)
+			}})
+		}
+	}
+	return orphans, nil
+}
+
+func changeStreamExists(ctx context.Context, spClient *spanner.Client, dialect, name string) (bool, error) {
+	stmt := spanner.Statement{
+		SQL: `SELECT COUNT(*) FROM information_schema.change_streams WHERE change_stream_name = ` + getQueryParam(dialect, 1),
+		Params: map[string]interface{}{
+			"p1": name,
+		},
+	}
+	var count int64
+	err := spClient.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		return row.Columns(&count)
+	})
+	if err != nil {
+		return false, fmt.Errorf("couldn't read from change_streams table: %w", err)
+	}
+	return count > 0, nil
+}
+
+func dropChangeStream(ctx context.Context, adminClient *database.DatabaseAdminClient, dbUri, dialect, name string) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "DropChangeStream", attribute.String("database", dbUri), attribute.String("changeStream", name))
+	defer func() { tracing.EndSpan(span, err) }()
+	op, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "UpdateDatabaseDdl", Resource: dbUri}, func(ctx context.Context) (*database.UpdateDatabaseDdlOperation, error) {
+		return adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+			Database:   dbUri,
+			Statements: []string{fmt.Sprintf("DROP CHANGE STREAM %s", quoteIdentifier(dialect, name))},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("cannot submit drop change stream request: %v", err)
+	}
+	return op.Wait(ctx)
+}
@@ -0,0 +1,201 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestFindGcsFiles(t *testing.T) {
+	gcs := testutil.NewFakeStorageClient()
+	ctx := withFakeClients(context.Background(), testutil.NewFakeDataflowAccessor(), gcs)
+	gcs.PutObject("my-bucket", "orders/shards-0.json", []byte("[]"), time.Now())
+	// Only an exact match is a shards file of the pipeline.
+	gcs.PutObject("my-bucket", "orders/shards-1.json.bak", []byte("[]"), time.Now())
+
+	orphans, err := findGcsFiles(ctx, gcs, "writer shards file", []string{"gs://my-bucket/orders/shards-0.json", "gs://my-bucket/orders/shards-1.json"})
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(orphans)) {
+		assert.Equal(t, "gs://my-bucket/orders/shards-0.json", orphans[0].name)
+		assert.Nil(t, orphans[0].delete(ctx))
+	}
+	_, ok := gcs.GetObject("my-bucket", "orders/shards-0.json")
+	assert.False(t, ok)
+	_, ok = gcs.GetObject("my-bucket", "orders/shards-1.json.bak")
+	assert.True(t, ok)
+
+	_, err = findGcsFiles(ctx, gcs, "writer shards file", []string{"/tmp/shards.json"})
+	assert.NotNil(t, err)
+}
+
+func TestFindGcsDirectory(t *testing.T) {
+	gcs := testutil.NewFakeStorageClient()
+	ctx := withFakeClients(context.Background(), testutil.NewFakeDataflowAccessor(), gcs)
+	gcs.PutObject("my-bucket", "orders/staging/a.jar", []byte("a"), time.Now())
+	gcs.PutObject("my-bucket", "orders/temp/b", []byte("b"), time.Now())
+	gcs.PutObject("my-bucket", "orders-2/temp/c", []byte("c"), time.Now())
+
+	orphan, err := findGcsDirectory(ctx, gcs, "dataflow staging and temp files", "gs://my-bucket/orders")
+	assert.Nil(t, err)
+	if assert.NotNil(t, orphan) {
+		assert.Equal(t, "gs://my-bucket/orders (2 objects)", orphan.name)
+		assert.Nil(t, orphan.delete(ctx))
+	}
+	objects, err := gcs.ListObjects(ctx, "my-bucket", "")
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(objects)) {
+		assert.Equal(t, "orders-2/temp/c", objects[0].Name)
+	}
+
+	orphan, err = findGcsDirectory(ctx, gcs, "dataflow staging and temp files", "gs://my-bucket/orders")
+	assert.Nil(t, err)
+	assert.Nil(t, orphan)
+}
+
+func TestGetSuffixTables(t *testing.T) {
+	owners := map[string]suffixOwner{
+		"":         {jobNamePrefix: "payments"},
+		"2":        {jobNamePrefix: "orders"},
+		"orders_2": {jobNamePrefix: "inventory"},
+		"orders":   {jobNamePrefix: "orders"},
+	}
+	tables := []string{"Metadata", "Metadata_2", "Metadata_orders", "Metadata_orders_2", "Metadata_refunds"}
+	// A table belongs to the longest suffix its name ends with.
+	assert.Equal(t, []string{"Metadata_2", "Metadata_orders"}, getSuffixTables(tables, owners, "orders"))
+	assert.Equal(t, []string{"Metadata_orders_2"}, getSuffixTables(tables, owners, "inventory"))
+	assert.Equal(t, []string{"Metadata", "Metadata_refunds"}, getSuffixTables(tables, owners, "payments"))
+	assert.Equal(t, []string{"inventory", "payments"}, getOtherSuffixUsers(owners, "orders"))
+	assert.Nil(t, getOtherSuffixUsers(map[string]suffixOwner{"": {jobNamePrefix: "orders"}}, "orders"))
+}
+
+func TestDeletePipelineRecords(t *testing.T) {
+	ctx := context.Background()
+	orders, err := newConfig(getTestJobData())
+	assert.Nil(t, err)
+	j := getTestJobData()
+	j.JobNamePrefix = "payments"
+	payments, err := newConfig(j)
+	assert.Nil(t, err)
+	store := newLocalMetadataStore(orders)
+	// The stores of both pipelines share the tables.
+	other := &localMetadataStore{cfg: payments, owners: store.owners, jobs: store.jobs, creations: store.creations, definitions: store.definitions}
+	assert.Nil(t, store.RegisterSuffix(ctx, "orders", suffixOwner{jobNamePrefix: "orders"}))
+	assert.Nil(t, other.RegisterSuffix(ctx, "payments", suffixOwner{jobNamePrefix: "payments"}))
+	assert.Nil(t, store.RecordJobDefinition(ctx, "{}"))
+	assert.Nil(t, other.RecordJobDefinition(ctx, "{}"))
+	assert.Nil(t, store.RecordJobRestart(ctx, "orders-writer", "1"))
+	assert.Nil(t, store.RecordCreationStatus(ctx, "holder", CREATION_STATUS_CREATED, ""))
+
+	// Only the records of the pipeline are deleted.
+	assert.Nil(t, store.DeletePipelineRecords(ctx))
+	owners, err := store.ReadSuffixOwners(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]suffixOwner{"payments": {jobNamePrefix: "payments"}}, owners)
+	_, _, found, err := store.ReadJobDefinition(ctx)
+	assert.Nil(t, err)
+	assert.False(t, found)
+	_, _, found, err = other.ReadJobDefinition(ctx)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 0, len(store.restarts))
+	assert.Equal(t, 0, len(store.creations))
+}
+
+func TestCreatedResources(t *testing.T) {
+	ctx := context.Background()
+	orders, err := newConfig(getTestJobData())
+	assert.Nil(t, err)
+	j := getTestJobData()
+	j.JobNamePrefix = "payments"
+	payments, err := newConfig(j)
+	assert.Nil(t, err)
+	store := newLocalMetadataStore(orders)
+	other := newLocalMetadataStore(payments)
+	changeStream := createdResource{kind: CREATED_CHANGE_STREAM, name: "reverseReplicationStream", database: "orders"}
+	metadataDb := createdResource{kind: CREATED_METADATA_DATABASE, name: "rev_repl_metadata"}
+	assert.Nil(t, store.RecordCreatedResource(ctx, changeStream))
+	assert.Nil(t, store.RecordCreatedResource(ctx, metadataDb))
+	assert.Nil(t, store.RecordCreatedResource(ctx, changeStream))
+	created, err := store.ReadCreatedResources(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []createdResource{changeStream, metadataDb}, created)
+	assert.True(t, isCreatedResource(created, CREATED_CHANGE_STREAM, "reverseReplicationStream", "orders"))
+	assert.False(t, isCreatedResource(created, CREATED_CHANGE_STREAM, "reverseReplicationStream", "payments"))
+
+	// The metadata database is recorded for every pipeline using it, and
+	// outlives the records of the pipeline which created it.
+	other.created = store.created
+	created, err = other.ReadCreatedResources(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []createdResource{metadataDb}, created)
+	assert.Nil(t, store.DeletePipelineRecords(ctx))
+	created, err = store.ReadCreatedResources(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []createdResource{metadataDb}, created)
+}
+
+func TestFindOrphanPubsubResources(t *testing.T) {
+	ctx := context.Background()
+	srv := pstest.NewServer()
+	defer srv.Close()
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	defer conn.Close()
+	client, err := pubsub.NewClient(ctx, "my-project", option.WithGRPCConn(conn))
+	assert.Nil(t, err)
+	defer client.Close()
+	topic, err := client.CreateTopic(ctx, "reverse-replication")
+	assert.Nil(t, err)
+	otherTopic, err := client.CreateTopic(ctx, "other-topic")
+	assert.Nil(t, err)
+	_, err = client.CreateSubscription(ctx, "shard1", pubsub.SubscriptionConfig{Topic: topic})
+	assert.Nil(t, err)
+	// A subscription of the same name reading another topic is kept.
+	_, err = client.CreateSubscription(ctx, "shard2", pubsub.SubscriptionConfig{Topic: otherTopic})
+	assert.Nil(t, err)
+
+	cfg, err := newConfig(getTestJobData())
+	assert.Nil(t, err)
+	orphans, err := cfg.findOrphanPubsubResources(ctx, client, []string{"shard1", "shard2", "shard3"})
+	assert.Nil(t, err)
+	var names []string
+	for _, o := range orphans {
+		names = append(names, o.name)
+	}
+	assert.Equal(t, []string{"projects/my-project/subscriptions/shard1", "projects/my-project/topics/reverse-replication"}, names)
+
+	// The topic is kept while other subscriptions read it.
+	_, err = client.CreateSubscription(ctx, "other-pipeline", pubsub.SubscriptionConfig{Topic: topic})
+	assert.Nil(t, err)
+	orphans, err = cfg.findOrphanPubsubResources(ctx, client, []string{"shard1", "shard2", "shard3"})
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(orphans)) {
+		assert.Equal(t, "projects/my-project/subscriptions/shard1", orphans[0].name)
+		assert.Nil(t, orphans[0].delete(ctx))
+	}
+	exists, err := client.Subscription("shard1").Exists(ctx)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,198 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	datastream "cloud.google.com/go/datastream/apiv1"
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/artifacts"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/dataflowjobs"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	"google.golang.org/api/iterator"
+)
+
+// StorageAccessor reads and writes the Cloud Storage objects of a pipeline,
+// such as its shards files, and is closed once done with.
+type StorageAccessor interface {
+	artifacts.StorageAccessor
+	Close() error
+}
+
+// MetricReader reads the time series of Cloud Monitoring, such as the
+// backlogs of the Pub/Sub subscriptions.
+type MetricReader interface {
+	ListTimeSeries(ctx context.Context, req *monitoringpb.ListTimeSeriesRequest) ([]*monitoringpb.TimeSeries, error)
+	Close() error
+}
+
+// ClientProvider creates the clients through which the pipelines reach Google
+// Cloud. Every step gets its clients from the provider of its context, see
+// WithClientProvider, so that they can be replaced in tests: Dataflow, Cloud
+// Storage and Cloud Monitoring are reached through interfaces implemented by
+// in-memory fakes such as testutil.FakeDataflowAccessor and
+// testutil.FakeStorageClient, while the Spanner and Pub/Sub clients can be
+// pointed at the Spanner emulator and at pstest, as the testharness package
+// does.
+type ClientProvider struct {
+	NewDataflowAccessor    func(ctx context.Context) (dataflowjobs.DataflowAccessor, error)
+	NewStorageAccessor     func(ctx context.Context) (StorageAccessor, error)
+	NewMetricReader        func(ctx context.Context) (MetricReader, error)
+	NewPubsubClient        func(ctx context.Context, projectId string) (*pubsub.Client, error)
+	NewDatabaseAdminClient func(ctx context.Context) (*database.DatabaseAdminClient, error)
+	NewInstanceAdminClient func(ctx context.Context) (*instance.InstanceAdminClient, error)
+	NewSpannerClient       func(ctx context.Context, dbUri string) (*spanner.Client, error)
+	NewDatastreamClient    func(ctx context.Context) (*datastream.Client, error)
+	// newMetadataStore opens the metadata store of the pipeline of cfg,
+	// using adminClient to manage its tables. Defaults to the store in the
+	// metadata database.
+	newMetadataStore func(ctx context.Context, cfg *config, adminClient *database.DatabaseAdminClient) (metadataStore, error)
+	// newCreationLockStore opens the store of the creation locks of the
+	// pipeline of cfg. Defaults to the store in the creation locks database.
+	newCreationLockStore func(ctx context.Context, cfg *config, adminClient *database.DatabaseAdminClient) (creationLockStore, error)
+
+	instanceInfoMu sync.Mutex
+	// instanceInfo caches the instance lookups made with the clients of the
+	// provider, see getInstanceInfoCache.
+	instanceInfo *spanneradmin.InstanceInfoCache
+}
+
+// DefaultClientProvider returns the provider creating clients with the
+// default credentials and endpoints.
+func DefaultClientProvider() *ClientProvider {
+	return &ClientProvider{
+		NewDataflowAccessor: func(ctx context.Context) (dataflowjobs.DataflowAccessor, error) {
+			return dataflowjobs.NewGcpDataflowAccessor(ctx)
+		},
+		NewStorageAccessor: func(ctx context.Context) (StorageAccessor, error) {
+			client, err := storage.NewClient(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return gcsStorageAccessor{artifacts.GcsStorageAccessor{Client: client}}, nil
+		},
+		NewMetricReader: func(ctx context.Context) (MetricReader, error) {
+			client, err := monitoring.NewMetricClient(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return &gcpMetricReader{client: client}, nil
+		},
+		NewPubsubClient: func(ctx context.Context, projectId string) (*pubsub.Client, error) {
+			return pubsub.NewClient(ctx, projectId)
+		},
+		NewDatabaseAdminClient: func(ctx context.Context) (*database.DatabaseAdminClient, error) {
+			return database.NewDatabaseAdminClient(ctx)
+		},
+		NewInstanceAdminClient: func(ctx context.Context) (*instance.InstanceAdminClient, error) {
+			return instance.NewInstanceAdminClient(ctx)
+		},
+		NewSpannerClient: func(ctx context.Context, dbUri string) (*spanner.Client, error) {
+			return spanner.NewClient(ctx, dbUri)
+		},
+		NewDatastreamClient: func(ctx context.Context) (*datastream.Client, error) {
+			return datastream.NewClient(ctx)
+		},
+	}
+}
+
+// openMetadataStore opens the metadata store of the pipeline of cfg.
+func (p *ClientProvider) openMetadataStore(ctx context.Context, cfg *config, adminClient *database.DatabaseAdminClient) (metadataStore, error) {
+	if p.newMetadataStore == nil {
+		return newSpannerMetadataStore(ctx, cfg, adminClient)
+	}
+	return p.newMetadataStore(ctx, cfg, adminClient)
+}
+
+// openCreationLockStore opens the store of the creation locks of the pipeline
+// of cfg.
+func (p *ClientProvider) openCreationLockStore(ctx context.Context, cfg *config, adminClient *database.DatabaseAdminClient) (creationLockStore, error) {
+	if p.newCreationLockStore == nil {
+		return newSpannerCreationLockStore(ctx, cfg, adminClient)
+	}
+	return p.newCreationLockStore(ctx, cfg, adminClient)
+}
+
+// getInstanceInfoCache returns the cache of the instance lookups made with
+// the provider, creating its instance admin client on first use. The cache,
+// and its client, are kept for as long as the provider, so that the
+// pipelines sharing a provider look up an instance once.
+func (p *ClientProvider) getInstanceInfoCache(ctx context.Context) (*spanneradmin.InstanceInfoCache, error) {
+	p.instanceInfoMu.Lock()
+	defer p.instanceInfoMu.Unlock()
+	if p.instanceInfo == nil {
+		client, err := p.NewInstanceAdminClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not create instance admin client: %v", err)
+		}
+		p.instanceInfo = spanneradmin.NewInstanceInfoCache(client, spanneradmin.DEFAULT_INSTANCE_INFO_TTL)
+	}
+	return p.instanceInfo, nil
+}
+
+// gcsStorageAccessor closes the Cloud Storage client it was created with.
+type gcsStorageAccessor struct {
+	artifacts.GcsStorageAccessor
+}
+
+func (g gcsStorageAccessor) Close() error {
+	return g.Client.Close()
+}
+
+type gcpMetricReader struct {
+	client *monitoring.MetricClient
+}
+
+func (r *gcpMetricReader) ListTimeSeries(ctx context.Context, req *monitoringpb.ListTimeSeriesRequest) ([]*monitoringpb.TimeSeries, error) {
+	var series []*monitoringpb.TimeSeries
+	call := gcp.Call{Service: gcp.MONITORING, Method: "ListTimeSeries", Resource: req.Name, Idempotent: true}
+	err := gcp.Do(ctx, call, func(ctx context.Context) error {
+		// A failed listing is started over.
+		series = nil
+		it := r.client.ListTimeSeries(ctx, req)
+		for {
+			ts, err := it.Next()
+			if err == iterator.Done {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			series = append(series, ts)
+		}
+	})
+	return series, err
+}
+
+func (r *gcpMetricReader) Close() error {
+	return r.client.Close()
+}
+
+type clientProviderKey struct{}
+
+// defaultClients is the provider of the contexts without one. It is shared by
+// the whole process, as is its instance info cache.
+var defaultClients = DefaultClientProvider()
+
+// WithClientProvider returns a copy of ctx whose steps get their clients from
+// p.
+func WithClientProvider(ctx context.Context, p *ClientProvider) context.Context {
+	return context.WithValue(ctx, clientProviderKey{}, p)
+}
+
+// getClients returns the client provider of ctx, or the default provider if
+// ctx has none.
+func getClients(ctx context.Context) *ClientProvider {
+	if p, ok := ctx.Value(clientProviderKey{}).(*ClientProvider); ok {
+		return p
+	}
+	return defaultClients
+}
@@ -0,0 +1,54 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetInstanceInfoCache(t *testing.T) {
+	// The default provider, and so its cache, is shared by the process.
+	assert.Same(t, getClients(context.Background()), getClients(context.Background()))
+
+	p := DefaultClientProvider()
+	clients := 0
+	p.NewInstanceAdminClient = func(ctx context.Context) (*instance.InstanceAdminClient, error) {
+		clients++
+		return &instance.InstanceAdminClient{}, nil
+	}
+	ctx := WithClientProvider(context.Background(), p)
+	var wg sync.WaitGroup
+	caches := make([]*spanneradmin.InstanceInfoCache, 4)
+	for i := range caches {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			caches[i], err = getClients(ctx).getInstanceInfoCache(ctx)
+			assert.Nil(t, err)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, clients)
+	for _, c := range caches {
+		assert.Same(t, caches[0], c)
+	}
+}
@@ -0,0 +1,82 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// mergeJobData returns the job data base with the non empty values of
+// overrides. The tags of overrides, if any, replace the ones of base. Launcher
+// flags of overrides naming a field of the job data, e.g. dbName, or tags, set
+// the field. Overriding artifactsPath also drops the stagingLocation of base,
+// which was resolved from the artifactsPath of base.
+func mergeJobData(base, overrides JobData) JobData {
+	j := base
+	j.Flags = make(map[string]string)
+	for name, value := range base.Flags {
+		j.Flags[name] = value
+	}
+	if _, ok := overrides.Flags["artifactsPath"]; ok {
+		delete(j.Flags, "stagingLocation")
+	}
+	named := make(map[string]*string)
+	for _, f := range j.fields() {
+		named[f.flag] = f.value
+	}
+	for _, f := range overrides.fields() {
+		if *f.value != "" {
+			*named[f.flag] = *f.value
+		}
+	}
+	if len(overrides.Tags) > 0 {
+		j.Tags = overrides.Tags
+	}
+	for name, value := range overrides.Flags {
+		if field, ok := named[name]; ok {
+			*field = value
+		} else if name == "tags" {
+			j.Tags = strings.Split(value, ",")
+		} else {
+			j.Flags[name] = value
+		}
+	}
+	return j
+}
+
+// checkClone fails if the clone would share the Dataflow jobs or the Pub/Sub
+// topic of the source pipeline.
+func checkClone(source, clone JobData) error {
+	if clone.ProjectId != source.ProjectId {
+		return nil
+	}
+	if clone.DataflowRegion == source.DataflowRegion && clone.JobNamePrefix == source.JobNamePrefix {
+		return fmt.Errorf("the clone would have the same jobs as pipeline %s, please override the jobNamePrefix", source.JobNamePrefix)
+	}
+	if clone.PubSubDataTopicId == source.PubSubDataTopicId {
+		return fmt.Errorf("the clone would share the pub/sub topic %s of pipeline %s, please override the pubSubDataTopicId", source.PubSubDataTopicId, source.JobNamePrefix)
+	}
+	return nil
+}
+
+// CloneWorkflow creates a new pipeline with the configuration the pipeline
+// identified by source was last created with, as returned by ExportJob, and
+// the non empty values of overrides, e.g. another database and source shards
+// file. It is meant to promote a configuration tested in staging to
+// production. The secrets of the exported configuration are read from the
+// environment variables it references, unless given by overrides. The job
+// data of the clone is returned, with the references kept.
+func CloneWorkflow(ctx context.Context, source, overrides JobData) (JobData, error) {
+	exported, err := ExportJob(ctx, source)
+	if err != nil {
+		return JobData{}, fmt.Errorf("could not export pipeline %s: %v", source.JobNamePrefix, err)
+	}
+	clone := mergeJobData(exported, overrides)
+	if err := checkClone(exported, clone); err != nil {
+		return JobData{}, err
+	}
+	if err := CreateWorkflow(ctx, clone); err != nil {
+		return JobData{}, err
+	}
+	return clone, nil
+}
@@ -0,0 +1,138 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Approximate list prices in USD, for us-central1. Prices differ per region
+// and change over time, so the estimate is only meant for sizing.
+const (
+	HOURS_PER_MONTH = 730
+	// Streaming Dataflow worker resources, per hour.
+	DATAFLOW_VCPU_HOUR      = 0.069
+	DATAFLOW_MEMORY_GB_HOUR = 0.003557
+	DATAFLOW_PD_GB_HOUR     = 0.000054
+	// Streaming Engine, per GB of data processed.
+	DATAFLOW_STREAMING_ENGINE_GB = 0.018
+	// Persistent disk attached to each streaming worker, in GB.
+	WORKER_DISK_GB                  = 400
+	STREAMING_ENGINE_WORKER_DISK_GB = 30
+	// Pub/Sub throughput, per TiB published or delivered.
+	PUBSUB_TIB = 40.0
+	// GCS standard storage, per GB per month.
+	GCS_STORAGE_GB_MONTH = 0.020
+	// Spanner regional storage, per GB per month.
+	SPANNER_STORAGE_GB_MONTH = 0.30
+	// Storage assumed for the session, shards and Dataflow staging files.
+	GCS_STAGING_GB = 1.0
+	// Storage assumed for the changestream metadata tables.
+	METADATA_STORAGE_GB = 1.0
+)
+
+// Memory in GB per vCPU for the supported machine families and classes.
+var machineMemoryPerVcpu = map[string]map[string]float64{
+	"n1":  {"standard": 3.75, "highmem": 6.5, "highcpu": 0.9},
+	"n2":  {"standard": 4, "highmem": 8, "highcpu": 1},
+	"n2d": {"standard": 4, "highmem": 8, "highcpu": 1},
+	"e2":  {"standard": 4, "highmem": 8, "highcpu": 1},
+	"c2":  {"standard": 4},
+}
+
+// CostEstimateRequest describes the pipeline whose cost is estimated.
+type CostEstimateRequest struct {
+	MachineType     string
+	OrderingWorkers int
+	WriterWorkers   int
+	WriterJobs      int
+	StreamingEngine bool
+	// Expected volume of changes replicated per month, in GB.
+	MonthlyChangeVolumeGB float64
+}
+
+// CostItem is the estimated monthly cost of one component of the pipeline.
+type CostItem struct {
+	Component   string
+	Description string
+	MonthlyCost float64
+}
+
+// CostEstimate is the approximate monthly cost breakdown of the pipeline.
+type CostEstimate struct {
+	Items []CostItem
+	Total float64
+}
+
+func (e *CostEstimate) add(component, description string, cost float64) {
+	e.Items = append(e.Items, CostItem{Component: component, Description: description, MonthlyCost: cost})
+	e.Total += cost
+}
+
+// getMachineResources returns the vCPUs and memory in GB of a predefined
+// machine type such as n2-standard-4.
+func getMachineResources(machineType string) (int, float64, error) {
+	parts := strings.Split(machineType, "-")
+	if len(parts) != 3 {
+		return 0, 0, fmt.Errorf("unsupported machine type %s, expected a predefined type such as n2-standard-4", machineType)
+	}
+	memPerVcpu, ok := machineMemoryPerVcpu[parts[0]][parts[1]]
+	if !ok {
+		return 0, 0, fmt.Errorf("no pricing for machine type %s", machineType)
+	}
+	vcpus, err := strconv.Atoi(parts[2])
+	if err != nil || vcpus < 1 {
+		return 0, 0, fmt.Errorf("invalid number of vCPUs in machine type %s", machineType)
+	}
+	return vcpus, float64(vcpus) * memPerVcpu, nil
+}
+
+// EstimateCost returns the approximate monthly cost of running the reverse
+// replication pipeline described by req, split into Dataflow, Pub/Sub, GCS and
+// the metadata Spanner database. The compute capacity of the metadata
+// database is shared with its instance and not included.
+func EstimateCost(ctx context.Context, req CostEstimateRequest) (*CostEstimate, error) {
+	if req.OrderingWorkers < 1 || req.WriterWorkers < 1 || req.WriterJobs < 1 {
+		return nil, fmt.Errorf("the number of workers and writer jobs must be at least 1")
+	}
+	if req.MonthlyChangeVolumeGB < 0 {
+		return nil, fmt.Errorf("the monthly change volume can't be negative")
+	}
+	vcpus, memGB, err := getMachineResources(req.MachineType)
+	if err != nil {
+		return nil, err
+	}
+	diskGB := WORKER_DISK_GB
+	if req.StreamingEngine {
+		diskGB = STREAMING_ENGINE_WORKER_DISK_GB
+	}
+	workerCost := HOURS_PER_MONTH * (float64(vcpus)*DATAFLOW_VCPU_HOUR + memGB*DATAFLOW_MEMORY_GB_HOUR + float64(diskGB)*DATAFLOW_PD_GB_HOUR)
+
+	estimate := &CostEstimate{}
+	estimate.add("Dataflow", fmt.Sprintf("ordering job, %d x %s", req.OrderingWorkers, req.MachineType), float64(req.OrderingWorkers)*workerCost)
+	estimate.add("Dataflow", fmt.Sprintf("%d writer job(s), %d x %s each", req.WriterJobs, req.WriterWorkers, req.MachineType), float64(req.WriterJobs*req.WriterWorkers)*workerCost)
+	if req.StreamingEngine {
+		// Both the ordering and the writer jobs process every change.
+		estimate.add("Dataflow", fmt.Sprintf("streaming engine, %.1f GB processed", 2*req.MonthlyChangeVolumeGB), 2*req.MonthlyChangeVolumeGB*DATAFLOW_STREAMING_ENGINE_GB)
+	}
+	// Every change is published by the ordering job and delivered to a writer job.
+	estimate.add("Pub/Sub", fmt.Sprintf("%.1f GB published and delivered", req.MonthlyChangeVolumeGB), 2*req.MonthlyChangeVolumeGB/1024*PUBSUB_TIB)
+	estimate.add("GCS", fmt.Sprintf("%.1f GB of session, shards and staging files", GCS_STAGING_GB), GCS_STAGING_GB*GCS_STORAGE_GB_MONTH)
+	estimate.add("Spanner", fmt.Sprintf("%.1f GB of changestream metadata storage", METADATA_STORAGE_GB), METADATA_STORAGE_GB*SPANNER_STORAGE_GB_MONTH)
+	return estimate, nil
+}
+
+// printCostEstimate prints the cost breakdown as a table.
+func printCostEstimate(estimate *CostEstimate) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tDESCRIPTION\tUSD/MONTH")
+	for _, item := range estimate.Items {
+		fmt.Fprintf(w, "%s\t%s\t%.2f\n", item.Component, item.Description, item.MonthlyCost)
+	}
+	fmt.Fprintf(w, "Total\t\t%.2f\n", estimate.Total)
+	w.Flush()
+	fmt.Println("\nThe estimate uses approximate us-central1 list prices, actual costs vary by region, discounts and load.")
+}
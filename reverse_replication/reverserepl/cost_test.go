@@ -0,0 +1,110 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMachineResources(t *testing.T) {
+	tests := []struct {
+		machineType string
+		vcpus       int
+		memGB       float64
+		errContains string
+	}{
+		{machineType: "n2-standard-4", vcpus: 4, memGB: 16},
+		{machineType: "n1-highmem-8", vcpus: 8, memGB: 52},
+		{machineType: "e2-highcpu-2", vcpus: 2, memGB: 2},
+		{machineType: "n2-custom", errContains: "unsupported machine type n2-custom"},
+		{machineType: "c2-highmem-4", errContains: "no pricing for machine type c2-highmem-4"},
+		{machineType: "n2-standard-0", errContains: "invalid number of vCPUs in machine type n2-standard-0"},
+		{machineType: "n2-standard-x", errContains: "invalid number of vCPUs in machine type n2-standard-x"},
+	}
+	for _, tc := range tests {
+		vcpus, memGB, err := getMachineResources(tc.machineType)
+		if tc.errContains == "" {
+			assert.Nil(t, err, tc.machineType)
+			assert.Equal(t, tc.vcpus, vcpus, tc.machineType)
+			assert.InDelta(t, tc.memGB, memGB, 1e-9, tc.machineType)
+		} else if assert.NotNil(t, err, tc.machineType) {
+			assert.Contains(t, err.Error(), tc.errContains, tc.machineType)
+		}
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         CostEstimateRequest
+		components  []string
+		total       float64
+		errContains string
+	}{
+		{
+			name:       "single writer job",
+			req:        CostEstimateRequest{MachineType: "n2-standard-4", OrderingWorkers: 1, WriterWorkers: 1, WriterJobs: 1},
+			components: []string{"Dataflow", "Dataflow", "Pub/Sub", "GCS", "Spanner"},
+			// Two workers with a 400 GB disk, and the staging and metadata
+			// storage.
+			total: 517.58752 + 0.02 + 0.3,
+		},
+		{
+			name:       "streaming engine with writer fan out",
+			req:        CostEstimateRequest{MachineType: "n2-standard-4", OrderingWorkers: 1, WriterWorkers: 2, WriterJobs: 3, StreamingEngine: true, MonthlyChangeVolumeGB: 100},
+			components: []string{"Dataflow", "Dataflow", "Dataflow", "Pub/Sub", "GCS", "Spanner"},
+			total:      1721.19102,
+		},
+		{
+			name:        "no writer jobs",
+			req:         CostEstimateRequest{MachineType: "n2-standard-4", OrderingWorkers: 1, WriterWorkers: 1},
+			errContains: "the number of workers and writer jobs must be at least 1",
+		},
+		{
+			name:        "negative change volume",
+			req:         CostEstimateRequest{MachineType: "n2-standard-4", OrderingWorkers: 1, WriterWorkers: 1, WriterJobs: 1, MonthlyChangeVolumeGB: -1},
+			errContains: "the monthly change volume can't be negative",
+		},
+		{
+			name:        "unsupported machine type",
+			req:         CostEstimateRequest{MachineType: "custom-4-16384", OrderingWorkers: 1, WriterWorkers: 1, WriterJobs: 1},
+			errContains: "no pricing for machine type custom-4-16384",
+		},
+	}
+	for _, tc := range tests {
+		estimate, err := EstimateCost(context.Background(), tc.req)
+		if tc.errContains != "" {
+			if assert.NotNil(t, err, tc.name) {
+				assert.Contains(t, err.Error(), tc.errContains, tc.name)
+			}
+			continue
+		}
+		if !assert.Nil(t, err, tc.name) {
+			continue
+		}
+		var components []string
+		sum := 0.0
+		for _, item := range estimate.Items {
+			components = append(components, item.Component)
+			sum += item.MonthlyCost
+		}
+		assert.Equal(t, tc.components, components, tc.name)
+		assert.InDelta(t, tc.total, estimate.Total, 1e-6, tc.name)
+		assert.InDelta(t, sum, estimate.Total, 1e-9, tc.name)
+	}
+}
@@ -0,0 +1,95 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+// Table in the metadata database recording the resources the launcher created,
+// as opposed to the ones which already existed when the pipeline was launched.
+// Only the created ones are deleted when the pipeline is cleaned up.
+const CREATED_RESOURCES_TABLE = "ReverseReplicationCreatedResources"
+
+// Kinds of the resources recorded as created by the launcher.
+const (
+	CREATED_CHANGE_STREAM     = "changeStream"
+	CREATED_METADATA_DATABASE = "metadataDatabase"
+)
+
+// createdResource is a resource created by the launcher. The metadata
+// database is recorded for no pipeline in particular, as it outlives the
+// pipeline which created it while other pipelines use it.
+type createdResource struct {
+	// CREATED_CHANGE_STREAM or CREATED_METADATA_DATABASE.
+	kind string
+	name string
+	// Database of the change stream, empty for the metadata database.
+	database string
+}
+
+// getOwner returns the pipeline the resource is recorded for, jobNamePrefix
+// or none for the metadata database, whose record is kept along with it while
+// other pipelines use it.
+func (r createdResource) getOwner(jobNamePrefix string) string {
+	if r.kind == CREATED_METADATA_DATABASE {
+		return ""
+	}
+	return jobNamePrefix
+}
+
+// getCreatedResourcesTableDdl returns the statement creating the created
+// resources table in a metadata database of the given dialect.
+func getCreatedResourcesTableDdl(dialect string) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+	"JobNamePrefix" VARCHAR NOT NULL,
+	"Kind" VARCHAR NOT NULL,
+	"Name" VARCHAR NOT NULL,
+	"DatabaseId" VARCHAR NOT NULL,
+	"CreatedAt" SPANNER.COMMIT_TIMESTAMP NOT NULL,
+	PRIMARY KEY ("JobNamePrefix", "Kind", "Name", "DatabaseId")
+)`, CREATED_RESOURCES_TABLE)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	JobNamePrefix STRING(MAX) NOT NULL,
+	Kind STRING(MAX) NOT NULL,
+	Name STRING(MAX) NOT NULL,
+	DatabaseId STRING(MAX) NOT NULL,
+	CreatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+) PRIMARY KEY (JobNamePrefix, Kind, Name, DatabaseId)`, CREATED_RESOURCES_TABLE)
+}
+
+// getCreatedResources returns the resources the launcher created for the
+// pipeline, and the metadata database if the launcher created it. Nothing was
+// created if the metadata database does not exist.
+func (cfg *config) getCreatedResources(ctx context.Context, adminClient *database.DatabaseAdminClient) ([]createdResource, error) {
+	_, err := adminClient.GetDatabase(ctx, &adminpb.GetDatabaseRequest{Name: cfg.getMetadataDbUri()})
+	if gcp.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not check metadata database %s: %v", cfg.getMetadataDbUri(), err)
+	}
+	store, err := getClients(ctx).openMetadataStore(ctx, cfg, adminClient)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the metadata db: %v", err)
+	}
+	defer store.Close()
+	return store.ReadCreatedResources(ctx)
+}
+
+// isCreatedResource returns true if the resource of the given kind, name and
+// database is among the created resources.
+func isCreatedResource(created []createdResource, kind, name, database string) bool {
+	for _, r := range created {
+		if r == (createdResource{kind: kind, name: name, database: database}) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,284 @@
+package reverserepl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// Database on the Spanner instance of the replicated databases holding
+	// the creation locks. Every creation replicating a database of the
+	// instance uses it, whatever its metadata database.
+	CREATION_LOCKS_DATABASE = "reverse-replication-locks"
+	// Table holding the advisory lock taken on every resource a pipeline
+	// creates or sets up while it is being created.
+	CREATION_LOCKS_TABLE = "ReverseReplicationCreationLocks"
+	// Time after which a lock which was not released, e.g. because the
+	// launcher crashed, can be taken over.
+	CREATION_LOCK_TTL = time.Hour
+)
+
+// creationLock is an advisory lock on a resource of a pipeline, held while
+// the pipeline is being created.
+type creationLock struct {
+	holder        string
+	jobNamePrefix string
+	tenant        string
+	expiresAt     time.Time
+}
+
+// errCreationInProgress is returned when the lock on a resource is held by
+// another creation.
+type errCreationInProgress struct {
+	resource string
+	lock     creationLock
+	// sameTenant is true if the lock is held by a pipeline of the tenant of
+	// the failed creation.
+	sameTenant bool
+}
+
+func (e errCreationInProgress) Error() string {
+	// The pipelines of other tenants are not disclosed.
+	owner := "another pipeline"
+	if e.sameTenant {
+		owner = fmt.Sprintf("pipeline %s", e.lock.jobNamePrefix)
+	}
+	return fmt.Sprintf("job already in progress: %s is being created for %s. Please retry once it is done, or after %s if it was interrupted", owner, e.resource, e.lock.expiresAt.Format(time.RFC3339))
+}
+
+// creationLockStore takes and releases the creation locks. Its locks are
+// shared by every creation of a pipeline on the Spanner instance of cfg.
+type creationLockStore interface {
+	// AcquireCreationLock takes the creation lock of resource for holder
+	// until ttl elapses. It fails with errCreationInProgress if another
+	// holder has the lock.
+	AcquireCreationLock(ctx context.Context, resource, holder string, ttl time.Duration) error
+	// ReleaseCreationLock releases the creation lock of resource if holder
+	// has it.
+	ReleaseCreationLock(ctx context.Context, resource, holder string) error
+	Close()
+}
+
+// getCreationLocksDbUri returns the uri of the database holding the creation
+// locks of the pipelines replicating databases of instanceId.
+func (cfg *config) getCreationLocksDbUri() string {
+	return fmt.Sprintf("projects/%s/instances/%s/databases/%s", cfg.projectId, cfg.instanceId, CREATION_LOCKS_DATABASE)
+}
+
+// getCreationLockResources returns the resources locked while the pipeline is
+// being created: every replicated database, whose change stream and metadata
+// tables it sets up, and its job name prefix, after which its Dataflow jobs
+// and Pub/Sub resources are named.
+func (cfg *config) getCreationLockResources(dbs []string) []string {
+	resources := []string{fmt.Sprintf("projects/%s/locations/%s/jobNamePrefixes/%s", cfg.projectId, cfg.dataflowRegion, cfg.jobNamePrefix)}
+	for _, db := range dbs {
+		resources = append(resources, cfg.getDbUri(db))
+	}
+	return resources
+}
+
+// getCreationLocksTableDdl returns the statement creating the creation locks
+// table.
+func getCreationLocksTableDdl() string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	Resource STRING(MAX) NOT NULL,
+	Holder STRING(MAX) NOT NULL,
+	JobNamePrefix STRING(MAX) NOT NULL,
+	Tenant STRING(MAX),
+	ExpiresAt TIMESTAMP NOT NULL,
+	LockedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+) PRIMARY KEY (Resource)`, CREATION_LOCKS_TABLE)
+}
+
+// acquireCreationLocks takes the creation lock of every resource of the
+// pipeline for holder, see getCreationLockResources, so that concurrent
+// creations of pipelines replicating the same database, or named after the
+// same job name prefix, don't create duplicate change streams and conflicting
+// metadata. The locks already taken are released if one of them is held by
+// another creation. The returned function releases the locks.
+func (cfg *config) acquireCreationLocks(ctx context.Context, locks creationLockStore, dbs []string, holder string) (func(), error) {
+	var locked []string
+	release := func() {
+		// The locks are released even if the creation was interrupted.
+		ctx, cancel := utils.WithCleanupTimeout(ctx, CREATION_CLEANUP_TIMEOUT)
+		defer cancel()
+		for _, resource := range locked {
+			if err := locks.ReleaseCreationLock(ctx, resource, holder); err != nil {
+				logger.FromContext(ctx).Warn(fmt.Sprintf("could not release the creation lock of %s: %v", resource, err))
+			}
+		}
+	}
+	for _, resource := range cfg.getCreationLockResources(dbs) {
+		if err := locks.AcquireCreationLock(ctx, resource, holder, CREATION_LOCK_TTL); err != nil {
+			release()
+			return nil, err
+		}
+		locked = append(locked, resource)
+	}
+	return release, nil
+}
+
+// spannerCreationLockStore implements creationLockStore on the creation locks
+// database of the Spanner instance.
+type spannerCreationLockStore struct {
+	cfg    *config
+	client *spanner.Client
+}
+
+var _ creationLockStore = (*spannerCreationLockStore)(nil)
+
+// newSpannerCreationLockStore returns a store on the creation locks database,
+// creating the database and its table unless they exist.
+func newSpannerCreationLockStore(ctx context.Context, cfg *config, adminClient *database.DatabaseAdminClient) (creationLockStore, error) {
+	dbUri := cfg.getCreationLocksDbUri()
+	createOp, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "CreateDatabase", Resource: dbUri}, func(ctx context.Context) (*database.CreateDatabaseOperation, error) {
+		return adminClient.CreateDatabase(ctx, &adminpb.CreateDatabaseRequest{
+			Parent:          fmt.Sprintf("projects/%s/instances/%s", cfg.projectId, cfg.instanceId),
+			CreateStatement: fmt.Sprintf("CREATE DATABASE `%s`", CREATION_LOCKS_DATABASE),
+		})
+	})
+	if err == nil {
+		_, err = createOp.Wait(ctx)
+	}
+	if err != nil && !gcp.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("could not create the creation locks db %s: %v", dbUri, err)
+	}
+	ddlOp, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "UpdateDatabaseDdl", Resource: dbUri}, func(ctx context.Context) (*database.UpdateDatabaseDdlOperation, error) {
+		return adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+			Database:   dbUri,
+			Statements: []string{getCreationLocksTableDdl()},
+		})
+	})
+	if err == nil {
+		err = ddlOp.Wait(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not create the creation locks table: %v", err)
+	}
+	client, err := getClients(ctx).NewSpannerClient(ctx, dbUri)
+	if err != nil {
+		return nil, fmt.Errorf("could not create spanner client for the creation locks db: %v", err)
+	}
+	return &spannerCreationLockStore{cfg: cfg, client: client}, nil
+}
+
+func (st *spannerCreationLockStore) Close() {
+	st.client.Close()
+}
+
+func (st *spannerCreationLockStore) AcquireCreationLock(ctx context.Context, resource, holder string, ttl time.Duration) error {
+	_, err := st.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		lock, found, err := readCreationLock(ctx, txn, resource)
+		if err != nil {
+			return err
+		}
+		if found && lock.holder != holder && time.Now().Before(lock.expiresAt) {
+			return errCreationInProgress{resource: resource, lock: lock, sameTenant: st.cfg.isSameTenant(lock.tenant)}
+		}
+		return txn.BufferWrite([]*spanner.Mutation{
+			spanner.InsertOrUpdate(CREATION_LOCKS_TABLE,
+				[]string{"Resource", "Holder", "JobNamePrefix", TENANT_COLUMN, "ExpiresAt", "LockedAt"},
+				[]interface{}{resource, holder, st.cfg.jobNamePrefix, st.cfg.getTenant(), time.Now().Add(ttl), spanner.CommitTimestamp}),
+		})
+	})
+	var inProgress errCreationInProgress
+	if errors.As(err, &inProgress) {
+		return inProgress
+	}
+	if err != nil {
+		return fmt.Errorf("could not take the creation lock of %s: %v", resource, err)
+	}
+	return nil
+}
+
+func (st *spannerCreationLockStore) ReleaseCreationLock(ctx context.Context, resource, holder string) error {
+	_, err := st.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		lock, found, err := readCreationLock(ctx, txn, resource)
+		if err != nil || !found || lock.holder != holder {
+			return err
+		}
+		return txn.BufferWrite([]*spanner.Mutation{spanner.Delete(CREATION_LOCKS_TABLE, spanner.Key{resource})})
+	})
+	if err != nil {
+		return fmt.Errorf("could not release the creation lock of %s: %v", resource, err)
+	}
+	return nil
+}
+
+// readCreationLock reads the creation lock of resource in txn, and returns
+// false if it is not taken.
+func readCreationLock(ctx context.Context, txn *spanner.ReadWriteTransaction, resource string) (creationLock, bool, error) {
+	var lock creationLock
+	row, err := txn.ReadRow(ctx, CREATION_LOCKS_TABLE, spanner.Key{resource}, []string{"Holder", "JobNamePrefix", TENANT_COLUMN, "ExpiresAt"})
+	if spanner.ErrCode(err) == codes.NotFound {
+		return lock, false, nil
+	}
+	if err != nil {
+		return lock, false, fmt.Errorf("couldn't read %s from %s table: %w", resource, CREATION_LOCKS_TABLE, err)
+	}
+	var tenant spanner.NullString
+	if err := row.Columns(&lock.holder, &lock.jobNamePrefix, &tenant, &lock.expiresAt); err != nil {
+		return lock, false, fmt.Errorf("can't scan row from %s table: %v", CREATION_LOCKS_TABLE, err)
+	}
+	lock.tenant = tenant.StringVal
+	return lock, true, nil
+}
+
+// localCreationLocks holds the creation locks in memory, for the creations of
+// a single process, e.g. in tests. Its stores are handed to the creations with
+// forConfig.
+type localCreationLocks struct {
+	mu    sync.Mutex
+	locks map[string]creationLock
+}
+
+// newLocalCreationLocks returns an empty set of in-memory locks.
+func newLocalCreationLocks() *localCreationLocks {
+	return &localCreationLocks{locks: make(map[string]creationLock)}
+}
+
+// forConfig returns the store taking the locks for the pipeline of cfg.
+func (l *localCreationLocks) forConfig(cfg *config) creationLockStore {
+	return &localCreationLockStore{cfg: cfg, locks: l}
+}
+
+// localCreationLockStore implements creationLockStore on localCreationLocks.
+type localCreationLockStore struct {
+	cfg   *config
+	locks *localCreationLocks
+}
+
+var _ creationLockStore = (*localCreationLockStore)(nil)
+
+func (st *localCreationLockStore) Close() {}
+
+func (st *localCreationLockStore) AcquireCreationLock(ctx context.Context, resource, holder string, ttl time.Duration) error {
+	st.locks.mu.Lock()
+	defer st.locks.mu.Unlock()
+	if lock, ok := st.locks.locks[resource]; ok && lock.holder != holder && time.Now().Before(lock.expiresAt) {
+		return errCreationInProgress{resource: resource, lock: lock, sameTenant: st.cfg.isSameTenant(lock.tenant)}
+	}
+	st.locks.locks[resource] = creationLock{holder: holder, jobNamePrefix: st.cfg.jobNamePrefix, tenant: st.cfg.getTenant(), expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (st *localCreationLockStore) ReleaseCreationLock(ctx context.Context, resource, holder string) error {
+	st.locks.mu.Lock()
+	defer st.locks.mu.Unlock()
+	if st.locks.locks[resource].holder == holder {
+		delete(st.locks.locks, resource)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireCreationLocks(t *testing.T) {
+	ctx := context.Background()
+	locks := newLocalCreationLocks()
+	newTestConfig := func(jobNamePrefix, dbName, metadataDatabase, tenant string) *config {
+		j := getTestJobData()
+		j.JobNamePrefix, j.DbName, j.MetadataDatabase, j.Tenant = jobNamePrefix, dbName, metadataDatabase, tenant
+		cfg, err := newConfig(j)
+		assert.Nil(t, err)
+		return cfg
+	}
+
+	orders := newTestConfig("orders", "orders,payments", "metadata-a", "team-a")
+	assert.Equal(t, []string{
+		"projects/my-project/locations/us-central1/jobNamePrefixes/orders",
+		"projects/my-project/instances/my-instance/databases/orders",
+		"projects/my-project/instances/my-instance/databases/payments",
+	}, orders.getCreationLockResources(orders.getDatabaseIds()))
+	release, err := orders.acquireCreationLocks(ctx, locks.forConfig(orders), orders.getDatabaseIds(), "holder-1")
+	assert.Nil(t, err)
+
+	// The locks are shared by the creations using other metadata databases.
+	other := newTestConfig("payments", "payments", "metadata-b", "team-a")
+	assert.Equal(t, orders.getCreationLocksDbUri(), other.getCreationLocksDbUri())
+	_, err = other.acquireCreationLocks(ctx, locks.forConfig(other), other.getDatabaseIds(), "holder-2")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "job already in progress: pipeline orders is being created for projects/my-project/instances/my-instance/databases/payments")
+	}
+	// The job name prefix is locked as well, and the pipelines of other
+	// tenants are not disclosed.
+	other = newTestConfig("orders", "inventory", "metadata-b", "team-b")
+	_, err = other.acquireCreationLocks(ctx, locks.forConfig(other), other.getDatabaseIds(), "holder-2")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "job already in progress: another pipeline is being created for projects/my-project/locations/us-central1/jobNamePrefixes/orders")
+	}
+
+	// The locks taken by a failed acquisition are released.
+	inventory := newTestConfig("inventory", "inventory", "", "team-a")
+	_, err = inventory.acquireCreationLocks(ctx, locks.forConfig(inventory), []string{"inventory", "orders"}, "holder-3")
+	assert.NotNil(t, err)
+	release()
+	release, err = inventory.acquireCreationLocks(ctx, locks.forConfig(inventory), inventory.getDatabaseIds(), "holder-4")
+	assert.Nil(t, err)
+	release()
+	assert.Equal(t, 0, len(locks.locks))
+}
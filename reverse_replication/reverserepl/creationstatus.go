@@ -0,0 +1,94 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+)
+
+const (
+	// Table in the metadata database recording the outcome of every creation
+	// of a pipeline.
+	CREATIONS_TABLE = "ReverseReplicationCreations"
+	// Time given to record the outcome of an interrupted creation, release
+	// its locks and roll it back.
+	CREATION_CLEANUP_TIMEOUT = 5 * time.Minute
+)
+
+// Statuses of a creation of a pipeline.
+const (
+	CREATION_STATUS_CREATING = "CREATING"
+	CREATION_STATUS_CREATED  = "CREATED"
+	CREATION_STATUS_FAILED   = "FAILED"
+	// The creation was interrupted, e.g. by SIGINT or SIGTERM.
+	CREATION_STATUS_ABORTED = "ABORTED"
+)
+
+// getCreationsTableDdl returns the statement creating the creations table in
+// a metadata database of the given dialect.
+func getCreationsTableDdl(dialect string) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+	"JobNamePrefix" VARCHAR NOT NULL,
+	"Holder" VARCHAR NOT NULL,
+	"Status" VARCHAR NOT NULL,
+	"Reason" VARCHAR,
+	"Tenant" VARCHAR,
+	"UpdatedAt" SPANNER.COMMIT_TIMESTAMP NOT NULL,
+	PRIMARY KEY ("JobNamePrefix", "Holder")
+)`, CREATIONS_TABLE)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	JobNamePrefix STRING(MAX) NOT NULL,
+	Holder STRING(MAX) NOT NULL,
+	Status STRING(MAX) NOT NULL,
+	Reason STRING(MAX),
+	Tenant STRING(MAX),
+	UpdatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+) PRIMARY KEY (JobNamePrefix, Holder)`, CREATIONS_TABLE)
+}
+
+// getCreationStatus returns the status of a creation which ended with err,
+// given ctx, the context it ran with.
+func getCreationStatus(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return CREATION_STATUS_CREATED
+	case ctx.Err() != nil:
+		return CREATION_STATUS_ABORTED
+	default:
+		return CREATION_STATUS_FAILED
+	}
+}
+
+// finishCreation records the outcome of the creation of holder, which ended
+// with err, and rolls the creation back if it did not succeed and
+// rollbackOnFailure is set. It runs even if ctx was cancelled, so that an
+// interrupted creation is not left in the CREATING status.
+func (cfg *config) finishCreation(ctx context.Context, store metadataStore, holder string, jobsLaunched bool, err error) {
+	status := getCreationStatus(ctx, err)
+	ctx, cancel := utils.WithCleanupTimeout(ctx, CREATION_CLEANUP_TIMEOUT)
+	defer cancel()
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	}
+	if err := store.RecordCreationStatus(ctx, holder, status, reason); err != nil {
+		logger.FromContext(ctx).Warn(fmt.Sprintf("could not record the %s status of the pipeline: %v", status, err))
+	}
+	if status == CREATION_STATUS_CREATED || !jobsLaunched {
+		return
+	}
+	if !cfg.rollbackOnFailure {
+		logger.FromContext(ctx).Info("The dataflow jobs already launched are left running. Delete the pipeline or relaunch it with the same jobNamePrefix to complete it")
+		return
+	}
+	logger.FromContext(ctx).Info("Rolling back the pipeline, cancelling the dataflow jobs already launched...")
+	if err := cfg.cancelPipelineJobs(ctx); err != nil {
+		logger.FromContext(ctx).Warn(fmt.Sprintf("could not roll back the pipeline: %v", err))
+	}
+}
@@ -0,0 +1,325 @@
+package reverserepl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"cloud.google.com/go/spanner"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// Interval between two checks of the jobs and the subscription backlogs
+	// during cutback.
+	CUTBACK_POLL_INTERVAL = 30 * time.Second
+	// Window of the backlog metrics read during cutback. Pub/Sub reports the
+	// backlog of a subscription every minute.
+	CUTBACK_METRICS_WINDOW = 5 * time.Minute
+)
+
+// getRunningJobs returns the running Dataflow job of every name in jobNames.
+// All of the jobs must be running.
+func (cfg *config) getRunningJobs(ctx context.Context, jobNames []string) ([]*dataflowpb.Job, error) {
+	jobs, err := cfg.listPipelineJobs(ctx, jobNames)
+	if err != nil {
+		return nil, err
+	}
+	var running []*dataflowpb.Job
+	for _, name := range jobNames {
+		var job *dataflowpb.Job
+		for _, j := range jobs[name] {
+			if !isTerminalJobState(j.CurrentState) {
+				job = j
+			}
+		}
+		if job == nil {
+			return nil, fmt.Errorf("dataflow job %s is not running", name)
+		}
+		running = append(running, job)
+	}
+	return running, nil
+}
+
+// drainJobs requests the jobs to drain, and waits until all of them reach a
+// terminal state or the deadline passes.
+func (cfg *config) drainJobs(ctx context.Context, jobs []*dataflowpb.Job, deadline time.Time) error {
+	return cfg.stopJobs(ctx, jobs, dataflowpb.JobState_JOB_STATE_DRAINED, "drain", deadline)
+}
+
+// cancelJobs requests the jobs to cancel, and waits until all of them reach a
+// terminal state or the deadline passes.
+func (cfg *config) cancelJobs(ctx context.Context, jobs []*dataflowpb.Job, deadline time.Time) error {
+	return cfg.stopJobs(ctx, jobs, dataflowpb.JobState_JOB_STATE_CANCELLED, "cancel", deadline)
+}
+
+// stopJobs requests the jobs to move to state, either drained or cancelled,
+// and waits until all of them reach a terminal state or the deadline passes.
+// action names the request in the messages.
+func (cfg *config) stopJobs(ctx context.Context, jobs []*dataflowpb.Job, state dataflowpb.JobState, action string, deadline time.Time) error {
+	c, err := getClients(ctx).NewDataflowAccessor(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create dataflow client: %v", err)
+	}
+	defer c.Close()
+	var names []string
+	for _, job := range jobs {
+		if err := c.UpdateJobState(ctx, cfg.projectId, cfg.dataflowRegion, job.Id, state); err != nil {
+			return fmt.Errorf("could not %s dataflow job %s: %v", action, job.Name, err)
+		}
+		logger.FromContext(ctx).Info(fmt.Sprintf("Requested %s of dataflow job %s", action, job.Name))
+		names = append(names, job.Name)
+	}
+	for {
+		states, err := cfg.getPipelineJobStates(ctx, names)
+		if err != nil {
+			return err
+		}
+		pending := 0
+		for _, name := range names {
+			for _, jobState := range states[name] {
+				if !isTerminalJobState(jobState) {
+					pending++
+				}
+			}
+		}
+		if pending == 0 {
+			return nil
+		}
+		if time.Now().Add(CUTBACK_POLL_INTERVAL).After(deadline) {
+			return fmt.Errorf("%d dataflow job(s) did not stop after the %s request by %s", pending, action, deadline.Format(time.RFC3339))
+		}
+		time.Sleep(CUTBACK_POLL_INTERVAL)
+	}
+}
+
+// getSubscriptionBacklog returns the latest number of undelivered messages of
+// the subscription reported by Cloud Monitoring, and false if no value was
+// reported recently.
+func (cfg *config) getSubscriptionBacklog(ctx context.Context, client MetricReader, subscriptionId string) (int64, bool, error) {
+	now := time.Now()
+	series, err := client.ListTimeSeries(ctx, &monitoringpb.ListTimeSeriesRequest{
+		Name:   fmt.Sprintf("projects/%s", cfg.projectId),
+		Filter: fmt.Sprintf(`metric.type = "pubsub.googleapis.com/subscription/num_undelivered_messages" AND resource.labels.subscription_id = "%s"`, subscriptionId),
+		Interval: &monitoringpb.TimeInterval{
+			StartTime: timestamppb.New(now.Add(-CUTBACK_METRICS_WINDOW)),
+			EndTime:   timestamppb.New(now),
+		},
+		View: monitoringpb.ListTimeSeriesRequest_FULL,
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("could not read the backlog of subscription %s: %v", subscriptionId, err)
+	}
+	for _, ts := range series {
+		// Points are returned newest first.
+		if len(ts.Points) > 0 {
+			return ts.Points[0].GetValue().GetInt64Value(), true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// waitForEmptyBacklogs waits until Cloud Monitoring reports no undelivered
+// messages on the subscription of every shard, read after drainedAt, or the
+// deadline passes.
+func (cfg *config) waitForEmptyBacklogs(ctx context.Context, shardIds []string, drainedAt, deadline time.Time) error {
+	client, err := getClients(ctx).NewMetricReader(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create monitoring client: %v", err)
+	}
+	defer client.Close()
+	pending := make(map[string]bool)
+	for _, id := range shardIds {
+		pending[id] = true
+	}
+	for {
+		// Values sampled before the ordering jobs drained may not include
+		// their last messages.
+		if time.Since(drainedAt) > time.Minute {
+			for _, id := range shardIds {
+				if !pending[id] {
+					continue
+				}
+				backlog, reported, err := cfg.getSubscriptionBacklog(ctx, client, id)
+				if err != nil {
+					return err
+				}
+				if reported && backlog == 0 {
+					logger.FromContext(ctx).Info(fmt.Sprintf("shard %s: no pending changes", id))
+					delete(pending, id)
+				}
+			}
+			if len(pending) == 0 {
+				return nil
+			}
+		}
+		if time.Now().Add(CUTBACK_POLL_INTERVAL).After(deadline) {
+			return fmt.Errorf("the subscriptions of %d shard(s) still had pending changes by %s", len(pending), deadline.Format(time.RFC3339))
+		}
+		time.Sleep(CUTBACK_POLL_INTERVAL)
+	}
+}
+
+// writeCutbackMarker writes a marker row for the shard into verifyTable and
+// deletes it, waiting for both changes to reach the shard. Once the deletion
+// is replicated, all the changes committed to Spanner for the shard before it
+// have been applied to the shard. Returns the commit timestamp of the
+// deletion.
+func (cfg *config) writeCutbackMarker(ctx context.Context, spClient *spanner.Client, shard map[string]interface{}, sharded bool, deadline time.Time) (time.Time, error) {
+	shardId, _ := shard["logicalShardId"].(string)
+	connStr, err := cfg.getShardConnectionString(shard)
+	if err != nil {
+		return time.Time{}, err
+	}
+	db, err := sql.Open(cfg.getShardDriverName(), connStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not connect to shard: %v", err)
+	}
+	defer db.Close()
+
+	markerId := fmt.Sprintf("%s-cutback-%s-%d", cfg.jobNamePrefix, shardId, time.Now().UnixNano())
+	cols := []string{VERIFY_ID_COLUMN}
+	vals := []interface{}{markerId}
+	if sharded {
+		cols = append(cols, SHARD_ID_COLUMN)
+		vals = append(vals, shardId)
+	}
+	if _, err := spClient.Apply(ctx, []*spanner.Mutation{spanner.Insert(cfg.verifyTable, cols, vals)}); err != nil {
+		return time.Time{}, fmt.Errorf("could not write marker row to %s: %v", cfg.verifyTable, err)
+	}
+	if err := cfg.waitForMarker(ctx, db, markerId, true, deadline); err != nil {
+		return time.Time{}, err
+	}
+	commitTs, err := spClient.Apply(ctx, []*spanner.Mutation{spanner.Delete(cfg.verifyTable, spanner.Key{markerId})})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not delete marker row %s from %s: %v", markerId, cfg.verifyTable, err)
+	}
+	if err := cfg.waitForMarker(ctx, db, markerId, false, deadline); err != nil {
+		return time.Time{}, err
+	}
+	return commitTs, nil
+}
+
+// writeCutbackMarkers writes a marker for every shard concurrently into the
+// database db, and returns the latest commit timestamp of the markers.
+func (cfg *config) writeCutbackMarkers(ctx context.Context, db string, shards []interface{}, deadline time.Time) (time.Time, error) {
+	dbUri := cfg.getDbUri(db)
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	dialect, err := spanneradmin.GetDatabaseDialect(ctx, adminClient, dbUri)
+	if err != nil {
+		return time.Time{}, err
+	}
+	spClient, err := getClients(ctx).NewSpannerClient(ctx, dbUri)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not create spanner client for %s: %v", dbUri, err)
+	}
+	defer spClient.Close()
+	sharded, err := cfg.hasShardIdColumn(ctx, spClient, dialect)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !sharded && len(shards) > 1 {
+		return time.Time{}, fmt.Errorf("table %s has no %s column, which is needed to route the marker rows to each of the %d shards", cfg.verifyTable, SHARD_ID_COLUMN, len(shards))
+	}
+	results := make([]verifyResult, len(shards))
+	markerTs := make([]time.Time, len(shards))
+	wg := &sync.WaitGroup{}
+	for i, s := range shards {
+		shard, ok := s.(map[string]interface{})
+		if !ok {
+			return time.Time{}, fmt.Errorf("shard at index %d is not a json object", i)
+		}
+		wg.Add(1)
+		go func(i int, shard map[string]interface{}) {
+			defer wg.Done()
+			results[i].shardId, _ = shard["logicalShardId"].(string)
+			markerTs[i], results[i].err = cfg.writeCutbackMarker(ctx, spClient, shard, sharded, deadline)
+		}(i, shard)
+	}
+	wg.Wait()
+	var latest time.Time
+	failed := 0
+	for i, res := range results {
+		if res.err != nil {
+			logger.FromContext(ctx).Warn(fmt.Sprintf("shard %s: FAILED: %v", res.shardId, res.err))
+			failed++
+			continue
+		}
+		logger.FromContext(ctx).Info(fmt.Sprintf("shard %s: caught up with Spanner as of %s", res.shardId, markerTs[i].UTC().Format(time.RFC3339Nano)))
+		if markerTs[i].After(latest) {
+			latest = markerTs[i]
+		}
+	}
+	if failed > 0 {
+		return time.Time{}, fmt.Errorf("%d of %d shard(s) did not catch up with Spanner", failed, len(shards))
+	}
+	return latest, nil
+}
+
+// runCutback moves the source of truth back from Spanner to the source shards,
+// once the application stopped writing to Spanner. It waits for a marker
+// written after the last application write to be replicated to every shard,
+// drains the ordering jobs so that they stop reading the change stream,
+// waits for the writer jobs to apply every pending change, checked through
+// the Pub/Sub backlog metrics, and drains the writer jobs. Any failure stops
+// the cutback before the next step, and the pipeline can be inspected.
+func (cfg *config) runCutback(ctx context.Context) error {
+	deadline := time.Now().Add(cfg.cutbackTimeout)
+	shards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return err
+	}
+	shardIds, err := getLogicalShardIds(shards)
+	if err != nil {
+		return err
+	}
+	dbs := cfg.getDatabaseIds()
+	orderingJobs, err := cfg.getRunningJobs(ctx, cfg.getOrderingJobNames(dbs))
+	if err != nil {
+		return err
+	}
+	writerJobs, err := cfg.getRunningJobs(ctx, cfg.getWriterJobNames(len(partitionShards(shards, cfg.writerFanOut))))
+	if err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx).Info(fmt.Sprintf("Step 1/4: writing a marker row per shard to %s, after the last application write...", cfg.verifyTable))
+	var consistentAt time.Time
+	for _, db := range dbs {
+		if len(dbs) > 1 {
+			logger.FromContext(ctx).Info(fmt.Sprintf("Writing the markers from database %s", db))
+		}
+		markerTs, err := cfg.writeCutbackMarkers(ctx, db, shards, deadline)
+		if err != nil {
+			return err
+		}
+		if markerTs.After(consistentAt) {
+			consistentAt = markerTs
+		}
+	}
+	logger.FromContext(ctx).Info("Step 2/4: draining the ordering jobs, so that they stop reading the change stream...")
+	if err := cfg.drainJobs(ctx, orderingJobs, deadline); err != nil {
+		return err
+	}
+	drainedAt := time.Now()
+	logger.FromContext(ctx).Info("Step 3/4: waiting for the writer jobs to apply the pending changes...")
+	if err := cfg.waitForEmptyBacklogs(ctx, shardIds, drainedAt, deadline); err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info("Step 4/4: draining the writer jobs...")
+	if err := cfg.drainJobs(ctx, writerJobs, deadline); err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Cutback complete: the %d source shard(s) are consistent with Spanner as of %s. The application can now use the source shards.", len(shards), consistentAt.UTC().Format(time.RFC3339Nano)))
+	return nil
+}
@@ -0,0 +1,82 @@
+package reverserepl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Characters of database ids not allowed in Dataflow job names or metadata
+// table suffixes.
+var (
+	invalidJobNameCharsRegex = regexp.MustCompile(`[^a-z0-9-]`)
+	invalidSuffixCharsRegex  = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// getDatabaseIds returns the ids of the replicated databases. dbName holds a
+// single database id, or a comma separated list of databases on instanceId
+// which are replicated by the same pipeline.
+func (cfg *config) getDatabaseIds() []string {
+	var ids []string
+	for _, id := range strings.Split(cfg.dbName, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// validateDatabaseIds checks that dbName lists at least one database, and
+// no database twice.
+func validateDatabaseIds(ids []string) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("please specify a valid dbName")
+	}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			return fmt.Errorf("database %s is listed more than once in dbName", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+func (cfg *config) getDbUri(db string) string {
+	return fmt.Sprintf("projects/%s/instances/%s/databases/%s", cfg.projectId, cfg.instanceId, db)
+}
+
+// getOrderingJobName returns the name of the ordering job reading the change
+// stream of db. With a single database, the job is named after the pipeline
+// only, as before multiple databases were supported.
+func getOrderingJobName(prefix, db string, multiDb bool) string {
+	if !multiDb {
+		return fmt.Sprintf("%s-ordering", prefix)
+	}
+	return fmt.Sprintf("%s-ordering-%s", prefix, invalidJobNameCharsRegex.ReplaceAllString(strings.ToLower(db), "-"))
+}
+
+// getOrderingJobNames returns the names of the ordering jobs of the pipeline,
+// one per database.
+func (cfg *config) getOrderingJobNames(dbs []string) []string {
+	var names []string
+	for _, db := range dbs {
+		names = append(names, getOrderingJobName(cfg.jobNamePrefix, db, len(dbs) > 1))
+	}
+	return names
+}
+
+// getDatabaseTableSuffix returns the metadata table suffix requested for the
+// ordering job of db. The ordering jobs of several databases can't share the
+// metadata tables, so each of them gets the database id appended to
+// metadataTableSuffix.
+func (cfg *config) getDatabaseTableSuffix(db string, multiDb bool) string {
+	if !multiDb {
+		return cfg.metadataTableSuffix
+	}
+	dbSuffix := invalidSuffixCharsRegex.ReplaceAllString(db, "_")
+	if cfg.metadataTableSuffix == "" {
+		return dbSuffix
+	}
+	return cfg.metadataTableSuffix + "_" + dbSuffix
+}
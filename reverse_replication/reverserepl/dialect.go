@@ -0,0 +1,67 @@
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+// quoteIdentifier quotes name in PostgreSQL, where unquoted identifiers are
+// folded to lower case. GoogleSQL identifiers are left as is.
+func quoteIdentifier(dialect, name string) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf(`"%s"`, name)
+	}
+	return name
+}
+
+// getMetadataDbCreateRequest returns the request creating the metadata
+// database with the given dialect.
+func (cfg *config) getMetadataDbCreateRequest(dialect string) *adminpb.CreateDatabaseRequest {
+	req := &adminpb.CreateDatabaseRequest{
+		Parent:          fmt.Sprintf("projects/%s/instances/%s", cfg.metadataProject, cfg.metadataInstance),
+		CreateStatement: fmt.Sprintf("CREATE DATABASE `%s`", cfg.metadataDatabase),
+	}
+	if dialect == constants.DIALECT_POSTGRESQL {
+		req.CreateStatement = fmt.Sprintf(`CREATE DATABASE "%s"`, cfg.metadataDatabase)
+		req.DatabaseDialect = adminpb.DatabaseDialect_POSTGRESQL
+	}
+	return req
+}
+
+// getSessionDialect returns the Spanner dialect the session file was
+// generated for. Session files without a dialect are GoogleSQL ones.
+func getSessionDialect(sessionJSON []byte) (string, error) {
+	var session struct {
+		SpDialect string
+	}
+	if err := json.Unmarshal(sessionJSON, &session); err != nil {
+		return "", fmt.Errorf("could not parse session file: %v", err)
+	}
+	if session.SpDialect == "" {
+		return constants.DIALECT_GOOGLESQL, nil
+	}
+	return session.SpDialect, nil
+}
+
+// validateSessionDialect checks that the session file was generated for a
+// Spanner database of the dialect of the replicated database db, since the jobs
+// map the change records to the source schema using the Spanner schema of the
+// session.
+func (cfg *config) validateSessionDialect(ctx context.Context, db, dialect string) error {
+	sessionJSON, err := readGcsFile(ctx, cfg.sessionFilePath)
+	if err != nil {
+		return err
+	}
+	sessionDialect, err := getSessionDialect(sessionJSON)
+	if err != nil {
+		return err
+	}
+	if sessionDialect != dialect {
+		return fmt.Errorf("session file %s was generated for a %s Spanner database, but database %s uses the %s dialect. Please use the session file of the migration to this database", cfg.sessionFilePath, sessionDialect, db, dialect)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+)
+
+// Table in the metadata database holding the job data every pipeline was
+// last created with.
+const JOB_DEFINITIONS_TABLE = "ReverseReplicationJobDefinitions"
+
+// Launcher flags which select an action other than creating the pipeline, or
+// only configure the launcher itself. They are left out of the job
+// definitions.
+var nonDefinitionFlags = map[string]bool{
+	"cutback":            true,
+	"updateShards":       true,
+	"rotateCredentials":  true,
+	"estimateCost":       true,
+	"relaunchOrdering":   true,
+	"reprocessSkipped":   true,
+	"validate":           true,
+	"verifySample":       true,
+	"detectSchemaDrift":  true,
+	"applySessionUpdate": true,
+	"cleanup":            true,
+	"dryRun":             true,
+	"logLevel":           true,
+	"logFormat":          true,
+	"logFile":            true,
+}
+
+// getJobDefinitionsTableDdl returns the statement creating the job
+// definitions table in a metadata database of the given dialect.
+func getJobDefinitionsTableDdl(dialect string) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+	"JobNamePrefix" VARCHAR NOT NULL,
+	"Definition" VARCHAR NOT NULL,
+	"Tenant" VARCHAR,
+	"UpdatedAt" SPANNER.COMMIT_TIMESTAMP NOT NULL,
+	PRIMARY KEY ("JobNamePrefix")
+)`, JOB_DEFINITIONS_TABLE)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	JobNamePrefix STRING(MAX) NOT NULL,
+	Definition STRING(MAX) NOT NULL,
+	Tenant STRING(MAX),
+	UpdatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+) PRIMARY KEY (JobNamePrefix)`, JOB_DEFINITIONS_TABLE)
+}
+
+// getJobDefinition returns the job data of the configured pipeline, with the
+// value of every launcher flag, including the defaults resolved by prechecks,
+// so that creating a pipeline from it gives the same pipeline even if the
+// defaults of the launcher change. The values read from environment
+// variables are replaced by the references to the variables.
+func (cfg *config) getJobDefinition() JobData {
+	j := JobData{Flags: make(map[string]string)}
+	named := make(map[string]*string)
+	for _, f := range j.fields() {
+		named[f.flag] = f.value
+	}
+	cfg.definitionFlags.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if ref, ok := cfg.secretReferences[f.Name]; ok {
+			value = ref
+		}
+		switch {
+		case nonDefinitionFlags[f.Name] || value == "":
+		case f.Name == "tags":
+			j.Tags = strings.Split(value, ",")
+		case named[f.Name] != nil:
+			*named[f.Name] = value
+		default:
+			j.Flags[f.Name] = value
+		}
+	})
+	// The dataflow directories default to a directory of artifactsPath, under
+	// which tempLocation defaults to the temp directory.
+	if cfg.stagingLocation == "" && cfg.getDefaultDataflowDir() != "" {
+		j.Flags["stagingLocation"] = cfg.getDefaultDataflowDir()
+	}
+	return j
+}
+
+// recordJobDefinition records the job definition of the configured pipeline
+// in store, for ExportJob.
+func (cfg *config) recordJobDefinition(ctx context.Context, store metadataStore) error {
+	b, err := json.Marshal(cfg.getJobDefinition())
+	if err != nil {
+		return fmt.Errorf("could not serialize the definition of pipeline %s: %v", cfg.jobNamePrefix, err)
+	}
+	return store.RecordJobDefinition(ctx, string(b))
+}
+
+// ExportJob returns the job data the pipeline identified by j, e.g. by its
+// project, instance, database and job name prefix, was last created with, as
+// recorded in its metadata database. Creating a pipeline from the job data,
+// e.g. after writing it with WriteJobData, recreates the same pipeline. The
+// values the pipeline read from environment variables are exported as the
+// references to the variables.
+func ExportJob(ctx context.Context, j JobData) (JobData, error) {
+	j, _, err := resolveJobData(j)
+	if err != nil {
+		return JobData{}, fmt.Errorf("invalid job data: %v", err)
+	}
+	cfg, err := parseConfig(j.args())
+	if err != nil {
+		return JobData{}, err
+	}
+	if err := cfg.checkPipelineFlags(); err != nil {
+		return JobData{}, fmt.Errorf("invalid job data: %v", err)
+	}
+	ctx = cfg.getWorkflowContext(ctx)
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return JobData{}, fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	if _, err := spanneradmin.GetDatabaseDialect(ctx, adminClient, cfg.getMetadataDbUri()); err != nil {
+		if gcp.IsNotFound(err) {
+			return JobData{}, fmt.Errorf("metadata database %s not found, please specify the metadata database of pipeline %s", cfg.getMetadataDbUri(), cfg.jobNamePrefix)
+		}
+		return JobData{}, fmt.Errorf("could not check metadata database %s: %v", cfg.getMetadataDbUri(), err)
+	}
+	store, err := getClients(ctx).openMetadataStore(ctx, cfg, adminClient)
+	if err != nil {
+		return JobData{}, fmt.Errorf("could not open the metadata db: %v", err)
+	}
+	defer store.Close()
+	def, tenant, found, err := store.ReadJobDefinition(ctx)
+	if err != nil {
+		return JobData{}, err
+	}
+	if !found || !cfg.isSameTenant(tenant) {
+		return JobData{}, fmt.Errorf("no definition of pipeline %s in %s. Pipelines created before definitions were recorded can't be exported", cfg.jobNamePrefix, cfg.getMetadataDbUri())
+	}
+	var exported JobData
+	if err := json.Unmarshal([]byte(def), &exported); err != nil {
+		return JobData{}, fmt.Errorf("could not parse the definition of pipeline %s: %v", cfg.jobNamePrefix, err)
+	}
+	return exported, nil
+}
@@ -0,0 +1,180 @@
+package reverserepl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/dataflowjobs"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+)
+
+// parseGcsObjectPath splits the gcs path of an object, of the form
+// gs://bucket/object, into its bucket and object name.
+func parseGcsObjectPath(path string) (string, string, error) {
+	u, err := url.Parse(path)
+	if err != nil || u.Scheme != "gs" || len(u.Path) < 2 {
+		return "", "", fmt.Errorf("invalid gcs path %s", path)
+	}
+	return u.Host, u.Path[1:], nil
+}
+
+// readGcsFile reads the whole gcs file at path, of the form
+// gs://bucket/object.
+func readGcsFile(ctx context.Context, path string) ([]byte, error) {
+	bucket, name, err := parseGcsObjectPath(path)
+	if err != nil {
+		return nil, err
+	}
+	gcs, err := getClients(ctx).NewStorageAccessor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create gcs client: %v", err)
+	}
+	defer gcs.Close()
+	return gcs.ReadObject(ctx, bucket, name)
+}
+
+// readSourceShards reads the list of shard configurations from the source
+// shards file.
+func (cfg *config) readSourceShards(ctx context.Context) ([]interface{}, error) {
+	return readShardsFile(ctx, cfg.sourceShardsFilePath)
+}
+
+// readShardsFile reads the list of shard configurations from the shards file
+// at the gcs path.
+func readShardsFile(ctx context.Context, path string) ([]interface{}, error) {
+	bArr, err := readGcsFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	var data []interface{}
+	if err := json.Unmarshal(bArr, &data); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", path, err)
+	}
+	return data, nil
+}
+
+// resolveShardSecrets replaces the references to environment variables in the
+// values of the shards by the values of the variables.
+func resolveShardSecrets(shards []interface{}) error {
+	for i, s := range shards {
+		shard, ok := s.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("shard at index %d is not a json object", i)
+		}
+		for k, v := range shard {
+			value, ok := v.(string)
+			if !ok {
+				continue
+			}
+			resolved, err := resolveSecretReference(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s of shard at index %d: %v", k, i, err)
+			}
+			shard[k] = resolved
+		}
+	}
+	return nil
+}
+
+// partitionShards distributes the shards round robin into n groups, so that
+// group sizes differ by at most one. Empty groups are dropped when there are
+// fewer shards than groups.
+func partitionShards(shards []interface{}, n int) [][]interface{} {
+	if n > len(shards) {
+		n = len(shards)
+	}
+	if n < 1 {
+		n = 1
+	}
+	groups := make([][]interface{}, n)
+	for i, shard := range shards {
+		groups[i%n] = append(groups[i%n], shard)
+	}
+	return groups
+}
+
+// getShardGroupFilePath returns the gcs path of the shards file for the i-th
+// writer group. It is placed next to the source shards file.
+func (cfg *config) getShardGroupFilePath(i int) string {
+	return fmt.Sprintf("%s-%s-writer-%d.json", strings.TrimSuffix(cfg.sourceShardsFilePath, ".json"), cfg.jobNamePrefix, i)
+}
+
+// writeShardsFile uploads a shards file listing shards to the gcs path.
+func writeShardsFile(ctx context.Context, gcs StorageAccessor, path string, shards []interface{}) error {
+	return writeGcsJsonFile(ctx, gcs, path, shards)
+}
+
+// writeGcsJsonFile uploads v, encoded as json, to the gcs path.
+func writeGcsJsonFile(ctx context.Context, gcs StorageAccessor, path string, v interface{}) error {
+	bucket, name, err := parseGcsObjectPath(path)
+	if err != nil {
+		return err
+	}
+	bArr, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal the content of %s: %v", path, err)
+	}
+	_, err = gcs.UploadObject(ctx, bucket, name, bytes.NewReader(bArr))
+	return err
+}
+
+// writeShardGroups uploads a shards file for every group and returns their gcs
+// paths, in the same order as groups.
+func (cfg *config) writeShardGroups(ctx context.Context, groups [][]interface{}) ([]string, error) {
+	gcs, err := getClients(ctx).NewStorageAccessor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create gcs client: %v", err)
+	}
+	defer gcs.Close()
+	var paths []string
+	for i, group := range groups {
+		path := cfg.getShardGroupFilePath(i)
+		if err := writeShardsFile(ctx, gcs, path, group); err != nil {
+			return nil, err
+		}
+		logger.FromContext(ctx).Info(fmt.Sprintf("Wrote shards file for writer group %d with %d shard(s): %s", i, len(group), path))
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// launchWriterJobs launches a writer job for every group of shards, and
+// returns the paths of the shards files read by the jobs. A single group
+// reads sourceShardsFilePath, while several groups get their own shards file
+// written next to it.
+func (cfg *config) launchWriterJobs(ctx context.Context, c dataflowjobs.DataflowAccessor, groups [][]interface{}) ([]string, error) {
+	paths := []string{cfg.sourceShardsFilePath}
+	if len(groups) > 1 {
+		var err error
+		if paths, err = cfg.writeShardGroups(ctx, groups); err != nil {
+			return nil, fmt.Errorf("could not write the shards files of the writer jobs: %v", err)
+		}
+	}
+	for i, name := range cfg.getWriterJobNames(len(groups)) {
+		req, err := cfg.getWriterJobRequest(name, paths[i], nil)
+		if err != nil {
+			return nil, fmt.Errorf("could not launch writer job: %v", err)
+		}
+		if err := cfg.launchJob(ctx, c, req, "writer"); err != nil {
+			return nil, fmt.Errorf("could not launch writer job: %v", err)
+		}
+	}
+	return paths, nil
+}
+
+// getWriterJobNames returns the names of the writer Dataflow jobs launched for
+// the pipeline. Without fan out there is a single writer job.
+func (cfg *config) getWriterJobNames(numGroups int) []string {
+	if numGroups <= 1 {
+		return []string{fmt.Sprintf("%s-writer", cfg.jobNamePrefix)}
+	}
+	var names []string
+	for i := 0; i < numGroups; i++ {
+		names = append(names, fmt.Sprintf("%s-writer-%d", cfg.jobNamePrefix, i))
+	}
+	return names
+}
@@ -0,0 +1,160 @@
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// filterConfig is the content of filterConfigFile, listing the tables and
+// columns of the Spanner database, by their Spanner names, which are not
+// replicated to the source shards.
+type filterConfig struct {
+	// Tables which are not replicated, e.g. tables only used by Spanner.
+	ExcludeTables []string `json:"excludeTables"`
+	// Non key columns which are not replicated, keyed by table, e.g. soft
+	// delete markers added to Spanner only.
+	ExcludeColumns map[string][]string `json:"excludeColumns"`
+}
+
+// watchedTable is a table watched by the change stream, along with its
+// watched non key columns.
+type watchedTable struct {
+	name string
+	// Empty if all the columns are watched.
+	cols []string
+	// True if some of the columns of the table are excluded.
+	partial bool
+}
+
+// readFilterConfig reads filterConfigFile from GCS or from the local disk.
+func (cfg *config) readFilterConfig(ctx context.Context) (filterConfig, error) {
+	var filter filterConfig
+	b, err := readFile(ctx, cfg.filterConfigFile)
+	if err != nil {
+		return filter, err
+	}
+	if err := json.Unmarshal(b, &filter); err != nil {
+		return filter, fmt.Errorf("could not parse filter config file %s: %v", cfg.filterConfigFile, err)
+	}
+	return filter, nil
+}
+
+// getWatchedTables returns the tables of the session file the change stream
+// watches with the filter config, ordered by name. Tables which are not in the
+// session file, e.g. tables created in Spanner after the migration, are not
+// watched either.
+func getWatchedTables(sessionJSON []byte, cfg filterConfig) ([]watchedTable, error) {
+	type column struct {
+		Name string
+	}
+	var session struct {
+		SpSchema map[string]struct {
+			Name        string
+			ColIds      []string
+			ColDefs     map[string]column
+			PrimaryKeys []struct {
+				ColId string
+			}
+		}
+	}
+	if err := json.Unmarshal(sessionJSON, &session); err != nil {
+		return nil, fmt.Errorf("could not parse session file: %v", err)
+	}
+	excludedTables := make(map[string]bool)
+	for _, t := range cfg.ExcludeTables {
+		excludedTables[t] = true
+	}
+	found := make(map[string]bool)
+	var tables []watchedTable
+	for _, spTable := range session.SpSchema {
+		found[spTable.Name] = true
+		if excludedTables[spTable.Name] {
+			continue
+		}
+		excludedCols := make(map[string]bool)
+		for _, c := range cfg.ExcludeColumns[spTable.Name] {
+			excludedCols[c] = true
+		}
+		keys := make(map[string]bool)
+		for _, pk := range spTable.PrimaryKeys {
+			keys[pk.ColId] = true
+		}
+		t := watchedTable{name: spTable.Name, partial: len(excludedCols) > 0}
+		foundCols := make(map[string]bool)
+		for _, colId := range spTable.ColIds {
+			name := spTable.ColDefs[colId].Name
+			foundCols[name] = true
+			if excludedCols[name] && keys[colId] {
+				return nil, fmt.Errorf("column %s of table %s is part of its primary key and can't be excluded", name, spTable.Name)
+			}
+			// Key columns are always watched and can't be listed.
+			if !excludedCols[name] && !keys[colId] {
+				t.cols = append(t.cols, name)
+			}
+		}
+		for c := range excludedCols {
+			if !foundCols[c] {
+				return nil, fmt.Errorf("excluded column %s of table %s is not in the session file", c, spTable.Name)
+			}
+		}
+		tables = append(tables, t)
+	}
+	for t := range excludedTables {
+		if !found[t] {
+			return nil, fmt.Errorf("excluded table %s is not in the session file", t)
+		}
+	}
+	for t := range cfg.ExcludeColumns {
+		if !found[t] || excludedTables[t] {
+			return nil, fmt.Errorf("table %s with excluded columns is not in the session file or is excluded", t)
+		}
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("the filter config excludes all the tables of the session file")
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].name < tables[j].name })
+	return tables, nil
+}
+
+// getChangeStreamWatch returns the FOR clause of the change stream watching
+// tables, e.g. FOR `Users`(`Name`), `Orders`.
+func getChangeStreamWatch(dialect string, tables []watchedTable) string {
+	var watched []string
+	for _, t := range tables {
+		w := quoteSpannerIdentifier(dialect, t.name)
+		if t.partial {
+			var cols []string
+			for _, c := range t.cols {
+				cols = append(cols, quoteSpannerIdentifier(dialect, c))
+			}
+			w += "(" + strings.Join(cols, ", ") + ")"
+		}
+		watched = append(watched, w)
+	}
+	return "FOR " + strings.Join(watched, ", ")
+}
+
+// getChangeStreamWatchForFilter returns the FOR clause of the change stream
+// created by the launcher: FOR ALL, or the tables and columns kept by
+// filterConfigFile if set.
+func (cfg *config) getChangeStreamWatchForFilter(ctx context.Context, dialect string) (string, error) {
+	if cfg.filterConfigFile == "" {
+		return "FOR ALL", nil
+	}
+	filter, err := cfg.readFilterConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	sessionJSON, err := readGcsFile(ctx, cfg.sessionFilePath)
+	if err != nil {
+		return "", err
+	}
+	tables, err := getWatchedTables(sessionJSON, filter)
+	if err != nil {
+		return "", fmt.Errorf("invalid filter config file %s: %v", cfg.filterConfigFile, err)
+	}
+	return getChangeStreamWatch(dialect, tables), nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWatchedTables(t *testing.T) {
+	session := []byte(`{"SpSchema": {
+		"t1": {"Name": "Users", "ColIds": ["c1", "c2", "c3"], "PrimaryKeys": [{"ColId": "c1"}],
+			"ColDefs": {"c1": {"Name": "UserId"}, "c2": {"Name": "Name"}, "c3": {"Name": "Deleted"}}},
+		"t2": {"Name": "Orders", "ColIds": ["c4", "c5"], "PrimaryKeys": [{"ColId": "c4"}],
+			"ColDefs": {"c4": {"Name": "OrderId"}, "c5": {"Name": "Amount"}}},
+		"t3": {"Name": "Audit", "ColIds": ["c6"], "PrimaryKeys": [{"ColId": "c6"}],
+			"ColDefs": {"c6": {"Name": "AuditId"}}}
+	}}`)
+	tests := []struct {
+		name        string
+		filter      filterConfig
+		want        []watchedTable
+		errContains string
+	}{
+		{
+			name: "no filter",
+			want: []watchedTable{
+				{name: "Audit"},
+				{name: "Orders", cols: []string{"Amount"}},
+				{name: "Users", cols: []string{"Name", "Deleted"}},
+			},
+		},
+		{
+			name:   "excluded table and column",
+			filter: filterConfig{ExcludeTables: []string{"Audit"}, ExcludeColumns: map[string][]string{"Users": {"Deleted"}}},
+			want: []watchedTable{
+				{name: "Orders", cols: []string{"Amount"}},
+				{name: "Users", cols: []string{"Name"}, partial: true},
+			},
+		},
+		{
+			name:        "excluded key column",
+			filter:      filterConfig{ExcludeColumns: map[string][]string{"Users": {"UserId"}}},
+			errContains: "column UserId of table Users is part of its primary key and can't be excluded",
+		},
+		{
+			name:        "unknown excluded column",
+			filter:      filterConfig{ExcludeColumns: map[string][]string{"Users": {"Email"}}},
+			errContains: "excluded column Email of table Users is not in the session file",
+		},
+		{
+			name:        "unknown excluded table",
+			filter:      filterConfig{ExcludeTables: []string{"Invoices"}},
+			errContains: "excluded table Invoices is not in the session file",
+		},
+		{
+			name:        "columns of an excluded table",
+			filter:      filterConfig{ExcludeTables: []string{"Users"}, ExcludeColumns: map[string][]string{"Users": {"Deleted"}}},
+			errContains: "table Users with excluded columns is not in the session file or is excluded",
+		},
+		{
+			name:        "all tables excluded",
+			filter:      filterConfig{ExcludeTables: []string{"Audit", "Orders", "Users"}},
+			errContains: "the filter config excludes all the tables of the session file",
+		},
+	}
+	for _, tc := range tests {
+		tables, err := getWatchedTables(session, tc.filter)
+		if tc.errContains == "" {
+			assert.Nil(t, err, tc.name)
+			assert.Equal(t, tc.want, tables, tc.name)
+		} else if assert.NotNil(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.errContains, tc.name)
+		}
+	}
+}
+
+func TestGetChangeStreamWatch(t *testing.T) {
+	tables := []watchedTable{
+		{name: "Orders", cols: []string{"Amount"}},
+		{name: "Users", cols: []string{"Name", "Email"}, partial: true},
+		// A table whose non key columns are all excluded only watches its
+		// keys.
+		{name: "Tokens", partial: true},
+	}
+	tests := []struct {
+		dialect string
+		want    string
+	}{
+		{dialect: constants.DIALECT_GOOGLESQL, want: "FOR `Orders`, `Users`(`Name`, `Email`), `Tokens`()"},
+		{dialect: constants.DIALECT_POSTGRESQL, want: `FOR "Orders", "Users"("Name", "Email"), "Tokens"()`},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, getChangeStreamWatch(tc.dialect, tables), tc.dialect)
+	}
+}
@@ -0,0 +1,277 @@
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// Reference to an environment variable in a job data or source shards file
+// value, e.g. ${SHARD_PASSWORD}.
+var secretReferenceRegex = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+var nonIdentifierRegex = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+// getShardPasswordVariable returns the environment variable the password of
+// the shard is read from in the source shards files written by
+// GenerateSourceShardsFromProfile, e.g. REVERSE_REPLICATION_SHARD1_PASSWORD
+// for shard1.
+func getShardPasswordVariable(shardId string) string {
+	return fmt.Sprintf("REVERSE_REPLICATION_%s_PASSWORD", strings.ToUpper(nonIdentifierRegex.ReplaceAllString(shardId, "_")))
+}
+
+// resolveSecretReference returns the value of the environment variable value
+// references, or value itself if it is not a reference.
+func resolveSecretReference(value string) (string, error) {
+	m := secretReferenceRegex.FindStringSubmatch(value)
+	if m == nil {
+		return value, nil
+	}
+	resolved, ok := os.LookupEnv(m[1])
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", m[1])
+	}
+	return resolved, nil
+}
+
+// getSecretReferences returns the names of the environment variables
+// referenced by the job data.
+func getSecretReferences(j JobData) []string {
+	var refs []string
+	add := func(value string) {
+		if m := secretReferenceRegex.FindStringSubmatch(value); m != nil {
+			refs = append(refs, m[1])
+		}
+	}
+	for _, f := range j.fields() {
+		add(*f.value)
+	}
+	for _, value := range j.Flags {
+		add(value)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// resolveJobData replaces the references to environment variables in the
+// values of the job data by the values of the variables. The references are
+// returned keyed by launcher flag, so that the job data can be recorded
+// without the values they resolve to.
+func resolveJobData(j JobData) (JobData, map[string]string, error) {
+	refs := make(map[string]string)
+	resolve := func(flagName string, value string) (string, error) {
+		resolved, err := resolveSecretReference(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s: %v", flagName, err)
+		}
+		if resolved != value {
+			refs[flagName] = value
+		}
+		return resolved, nil
+	}
+	var err error
+	for _, f := range j.fields() {
+		if *f.value, err = resolve(f.flag, *f.value); err != nil {
+			return j, nil, err
+		}
+	}
+	flags := make(map[string]string)
+	for name, value := range j.Flags {
+		if flags[name], err = resolve(name, value); err != nil {
+			return j, nil, err
+		}
+	}
+	if j.Flags != nil {
+		j.Flags = flags
+	}
+	return j, refs, nil
+}
+
+// isYamlPath returns true if the job data file at path is written in yaml
+// rather than json.
+func isYamlPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// stringifyYaml returns the decoded yaml value v with its scalars converted
+// to strings, as all the values of the job data are strings, e.g. so that
+// maxWorkers: 10 needs no quotes.
+func stringifyYaml(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, string:
+		return v
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = stringifyYaml(e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = stringifyYaml(e)
+		}
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ReadJobData reads the job data from the json or yaml file at path, as
+// written by WriteJobData. Values of the form ${NAME} are kept as is, and are
+// read from the environment variable NAME when the pipeline is configured
+// with the job data.
+func ReadJobData(path string) (JobData, error) {
+	var j JobData
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return j, fmt.Errorf("could not read %s: %v", path, err)
+	}
+	if isYamlPath(path) {
+		var v interface{}
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return j, fmt.Errorf("could not parse %s: %v", path, err)
+		}
+		if b, err = json.Marshal(stringifyYaml(v)); err != nil {
+			return j, fmt.Errorf("could not parse %s: %v", path, err)
+		}
+	}
+	if err := json.Unmarshal(b, &j); err != nil {
+		return j, fmt.Errorf("could not parse %s: %v", path, err)
+	}
+	return j, nil
+}
+
+// WriteJobData writes the job data to path as json, or as yaml if path ends
+// with .yaml or .yml, readable by the owner only. It returns the environment
+// variables referenced by the job data, which must be set when the job data is
+// used.
+func WriteJobData(path string, j JobData) ([]string, error) {
+	refs := getSecretReferences(j)
+	b, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, '\n')
+	if isYamlPath(path) {
+		var v map[string]interface{}
+		if err := json.Unmarshal(b, &v); err != nil {
+			return nil, err
+		}
+		if b, err = yaml.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		return nil, fmt.Errorf("could not write %s: %v", path, err)
+	}
+	return refs, nil
+}
+
+// The Check functions below validate a single field of the job data against
+// Google Cloud, so that a bad value can be reported as soon as it is entered,
+// e.g. by an interactive prompt, instead of when the pipeline is created.
+
+// CheckInstance checks that the Spanner instance exists and returns its node
+// count, processing units, configuration and leader region.
+func CheckInstance(ctx context.Context, projectId, instanceId string) (spanneradmin.InstanceInfo, error) {
+	instances, err := getClients(ctx).getInstanceInfoCache(ctx)
+	if err != nil {
+		return spanneradmin.InstanceInfo{}, err
+	}
+	name := fmt.Sprintf("projects/%s/instances/%s", projectId, instanceId)
+	info, err := instances.GetInstanceInfo(ctx, name)
+	if err != nil {
+		if gcp.IsNotFound(err) {
+			return info, fmt.Errorf("instance %s does not exist", name)
+		}
+		return info, fmt.Errorf("could not get instance %s: %v", name, err)
+	}
+	return info, nil
+}
+
+// CheckDatabases checks that every database of dbName, a comma separated list
+// of databases, exists on the Spanner instance.
+func CheckDatabases(ctx context.Context, projectId, instanceId, dbName string) error {
+	var dbs []string
+	for _, db := range strings.Split(dbName, ",") {
+		if db = strings.TrimSpace(db); db != "" {
+			dbs = append(dbs, db)
+		}
+	}
+	if err := validateDatabaseIds(dbs); err != nil {
+		return err
+	}
+	client, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer client.Close()
+	for _, db := range dbs {
+		dbUri := fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectId, instanceId, db)
+		if _, err := client.GetDatabase(ctx, &adminpb.GetDatabaseRequest{Name: dbUri}); err != nil {
+			if gcp.IsNotFound(err) {
+				return fmt.Errorf("database %s does not exist", dbUri)
+			}
+			return fmt.Errorf("could not get database %s: %v", dbUri, err)
+		}
+	}
+	return nil
+}
+
+// CheckSessionFile checks that the gcs or local file at path is a session
+// file, and returns the dialect of the Spanner database it was generated for.
+func CheckSessionFile(ctx context.Context, path string) (string, error) {
+	b, err := readFile(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	return getSessionDialect(b)
+}
+
+// CheckSourceShardsFile checks that the gcs or local file at path lists the
+// source shards, each with a logicalShardId, and returns their ids. The
+// environment variables referenced by a local file must be set.
+func CheckSourceShardsFile(ctx context.Context, path string) ([]string, error) {
+	b, err := readFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	var shards []interface{}
+	if err := json.Unmarshal(b, &shards); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", path, err)
+	}
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("%s does not list any shard", path)
+	}
+	// The environment variables referenced by a local file are read when it
+	// is uploaded.
+	if !isGcsPath(path) {
+		if err := resolveShardSecrets(shards); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", path, err)
+		}
+	}
+	return getLogicalShardIds(shards)
+}
+
+// readFile reads the whole gcs or local file at path.
+func readFile(ctx context.Context, path string) ([]byte, error) {
+	if isGcsPath(path) {
+		return readGcsFile(ctx, path)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", path, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/testutil"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/profiles"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardPasswordsAreNotWritten(t *testing.T) {
+	src := profiles.SourceProfile{
+		Ty: profiles.SourceProfileTypeConfig,
+		Config: profiles.SourceProfileConfig{
+			ConfigType: constants.BULK_MIGRATION,
+			ShardConfigurationBulk: profiles.ShardConfigurationBulk{
+				DataShards: []profiles.DirectConnectionConfig{
+					{DataShardId: "shard-1", Host: "10.0.0.1", User: "root", Password: "s3cr3t-1", Port: "3306", DbName: "orders"},
+					{DataShardId: "shard-2", Host: "10.0.0.2", User: "root", Password: "s3cr3t-2", Port: "3306", DbName: "orders"},
+				},
+			},
+		},
+	}
+	b, notes, err := GenerateSourceShardsFromProfile(context.Background(), src, "")
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), "s3cr3t")
+	assert.Contains(t, string(b), `"password": "${REVERSE_REPLICATION_SHARD_1_PASSWORD}"`)
+	if assert.Equal(t, 1, len(notes)) {
+		assert.Contains(t, notes[0], "REVERSE_REPLICATION_SHARD_1_PASSWORD, REVERSE_REPLICATION_SHARD_2_PASSWORD")
+	}
+
+	dir := t.TempDir()
+	shardsFile := filepath.Join(dir, "shards.json")
+	assert.Nil(t, os.WriteFile(shardsFile, b, 0600))
+	j := getTestJobData()
+	j.SourceShardsFilePath = shardsFile
+	j.Flags = map[string]string{"artifactsPath": "gs://my-bucket/artifacts"}
+
+	// The variables must be set to use the file.
+	_, err = CheckSourceShardsFile(context.Background(), shardsFile)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "environment variable REVERSE_REPLICATION_SHARD_1_PASSWORD is not set")
+	}
+	t.Setenv("REVERSE_REPLICATION_SHARD_1_PASSWORD", "s3cr3t-1")
+	t.Setenv("REVERSE_REPLICATION_SHARD_2_PASSWORD", "s3cr3t-2")
+	ids, err := CheckSourceShardsFile(context.Background(), shardsFile)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"shard-1", "shard-2"}, ids)
+
+	// The dataflow jobs read the passwords from the uploaded file.
+	gcs := testutil.NewFakeStorageClient()
+	ctx := withFakeClients(context.Background(), testutil.NewFakeDataflowAccessor(), gcs)
+	cfg, err := newConfig(j)
+	assert.Nil(t, err)
+	assert.Nil(t, cfg.uploadLocalArtifacts(ctx))
+	assert.Equal(t, "gs://my-bucket/artifacts/orders/shards.json", cfg.sourceShardsFilePath)
+	uploaded, ok := gcs.GetObject("my-bucket", "artifacts/orders/shards.json")
+	if assert.True(t, ok) {
+		var shards []sourceShard
+		assert.Nil(t, json.Unmarshal(uploaded, &shards))
+		if assert.Equal(t, 2, len(shards)) {
+			assert.Equal(t, "s3cr3t-1", shards[0].Password)
+			assert.Equal(t, "s3cr3t-2", shards[1].Password)
+		}
+	}
+
+	// Neither the job data nor the recorded job definition hold them.
+	jobDataFile := filepath.Join(dir, "pipeline.yaml")
+	refs, err := WriteJobData(jobDataFile, j)
+	assert.Nil(t, err)
+	assert.Nil(t, refs)
+	written, err := os.ReadFile(jobDataFile)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(written), "s3cr3t")
+	definition, err := json.Marshal(cfg.getJobDefinition())
+	assert.Nil(t, err)
+	assert.NotContains(t, string(definition), "s3cr3t")
+}
+
+func TestJobDataSecretReferences(t *testing.T) {
+	t.Setenv("ORDERS_METADATA_INSTANCE", "metadata-instance")
+	t.Setenv("ORDERS_STAGING_LOCATION", "gs://my-bucket/staging")
+	j := getTestJobData()
+	j.MetadataInstance = "${ORDERS_METADATA_INSTANCE}"
+	j.Flags = map[string]string{"stagingLocation": "${ORDERS_STAGING_LOCATION}"}
+
+	cfg, err := newConfig(j)
+	assert.Nil(t, err)
+	assert.Equal(t, "metadata-instance", cfg.metadataInstance)
+	assert.Equal(t, "gs://my-bucket/staging", cfg.stagingLocation)
+	// The job definition keeps the references rather than their values.
+	definition := cfg.getJobDefinition()
+	assert.Equal(t, "${ORDERS_METADATA_INSTANCE}", definition.MetadataInstance)
+	assert.Equal(t, "${ORDERS_STAGING_LOCATION}", definition.Flags["stagingLocation"])
+
+	path := filepath.Join(t.TempDir(), "pipeline.json")
+	refs, err := WriteJobData(path, j)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ORDERS_METADATA_INSTANCE", "ORDERS_STAGING_LOCATION"}, refs)
+	read, err := ReadJobData(path)
+	assert.Nil(t, err)
+	assert.Equal(t, j, read)
+
+	j.Flags["stagingLocation"] = "${ORDERS_UNSET_VARIABLE}"
+	_, err = newConfig(j)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "invalid stagingLocation: environment variable ORDERS_UNSET_VARIABLE is not set")
+	}
+}
+
+func TestValidateSourceShardsRejectsSecretReferences(t *testing.T) {
+	cfg, err := newConfig(getTestJobData())
+	assert.Nil(t, err)
+	err = cfg.validateSourceShards([]interface{}{
+		map[string]interface{}{"logicalShardId": "shard1", "host": "10.0.0.1", "user": "root", "password": "${SHARD1_PASSWORD}", "port": "3306", "dbName": "orders"},
+	})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "the password of shard at index 0 references an environment variable")
+	}
+}
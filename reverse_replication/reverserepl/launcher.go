@@ -0,0 +1,1250 @@
+package reverserepl
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"cloud.google.com/go/pubsub"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/dataflowjobs"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/dataflowlaunch"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/settings"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/tracing"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/api/iterator"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+/*
+	TODO: Add modes for running such as:
+		Launch only the Dataflow jobs and skip the ChangeStream and PubSub
+		Launch only the Ordering Dataflow job
+		Launch only the Writer Dataflow job etc.
+*/
+
+// config is the configuration of a pipeline, parsed from the launcher flags
+// or from a JobData. Every step of the pipeline reads it from the config it is
+// called on, so that several pipelines can be managed at the same time by the
+// same process.
+type config struct {
+	projectId                     string
+	dataflowRegion                string
+	jobNamePrefix                 string
+	changeStreamName              string
+	instanceId                    string
+	dbName                        string
+	metadataProject               string
+	metadataInstance              string
+	createMetadataInstance        bool
+	metadataDatabase              string
+	metadataTableSuffix           string
+	tenant                        string
+	startTimestamp                string
+	endTimestamp                  string
+	pubSubDataTopicId             string
+	pubSubEndpoint                string
+	sourceShardsFilePath          string
+	sessionFilePath               string
+	sourceType                    string
+	sourceDbTimezoneOffset        string
+	allowExperimental             bool
+	machineType                   string
+	vpcNetwork                    string
+	vpcSubnetwork                 string
+	vpcHostProjectId              string
+	serviceAccountEmail           string
+	orderingWorkers               int
+	writerWorkers                 int
+	orderingMaxWorkers            int
+	writerMaxWorkers              int
+	autoSizeWorkers               bool
+	writeQpsPerShard              float64
+	writerFanOut                  int
+	streamingEngine               bool
+	runnerV2                      bool
+	rollbackOnFailure             bool
+	tags                          string
+	stagingLocation               string
+	tempLocation                  string
+	diskSizeGb                    int
+	launcherMachineType           string
+	flexrsGoal                    string
+	sdkContainerImage             string
+	kmsKeyName                    string
+	additionalExperiments         string
+	artifactsPath                 string
+	templateCacheDir              string
+	networkTags                   string
+	filtrationMode                string
+	filterConfigFile              string
+	shardingFunction              string
+	shardingColumn                string
+	shardingRanges                string
+	changeStreamRetention         string
+	changeStreamValueCaptureType  string
+	autoFixChangeStream           bool
+	forceChangeStream             bool
+	cleanup                       bool
+	dryRun                        bool
+	autoUniquifySuffix            bool
+	verify                        bool
+	verifyTable                   string
+	verifySample                  int
+	verifySampleWindow            time.Duration
+	verifyTimeout                 time.Duration
+	cutback                       bool
+	cutbackTimeout                time.Duration
+	updateShards                  bool
+	newSourceShardsFilePath       string
+	updateShardsTimeout           time.Duration
+	rotateCredentials             bool
+	estimateCost                  bool
+	monthlyChangeVolumeGB         float64
+	orderingTemplate              string
+	orderingRunMode               string
+	relaunchOrdering              bool
+	writerTemplate                string
+	writerTransformationJarPath   string
+	writerTransformationClassName string
+	reprocessSkipped              bool
+	reprocessShardIds             string
+	reprocessStart                string
+	reprocessEnd                  string
+	validate                      bool
+	validateTables                string
+	validateMaxRows               int
+	backfill                      bool
+	backfillTables                string
+	backfillBatchSize             int
+	validateReportPath            string
+	validateEvery                 time.Duration
+	detectSchemaDrift             bool
+	schemaDriftEvery              time.Duration
+	applySessionUpdateMode        bool
+	newSessionFilePath            string
+	validateDriftThreshold        float64
+	validateAlertTopic            string
+	logLevel                      string
+	logFormat                     string
+	logFile                       string
+	// Flags the pipeline configuration was parsed from.
+	flagSet *flag.FlagSet
+	// Flags the pipeline was defined with, see nonDefinitionFlags.
+	definitionFlags *flag.FlagSet
+	// References to the environment variables the values of the job data
+	// were read from, keyed by flag. They are recorded instead of the values.
+	secretReferences map[string]string
+}
+
+// Flexible resource scheduling goals of the dataflow jobs.
+const (
+	FLEXRS_SPEED_OPTIMIZED = "SPEED_OPTIMIZED"
+	FLEXRS_COST_OPTIMIZED  = "COST_OPTIMIZED"
+)
+
+// MAX_DISK_SIZE_GB is the size of the largest persistent disk of a worker.
+const MAX_DISK_SIZE_GB = 65536
+
+var kmsKeyNameRegex = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+
+// Run modes of the ordering job. The resume modes restart reading the change
+// stream from the partitions recorded in the metadata tables by a previous
+// ordering job, instead of from startTimestamp.
+const (
+	RUN_MODE_REGULAR        = "regular"
+	RUN_MODE_RESUME_FAILED  = "resumeFailed"
+	RUN_MODE_RESUME_SUCCESS = "resumeSuccess"
+	RUN_MODE_RESUME_ALL     = "resumeAll"
+)
+
+// setupFlags defines the flags of the launcher on fs, bound to the pipeline
+// configuration.
+func (cfg *config) setupFlags(fs *flag.FlagSet) {
+	cfg.flagSet = fs
+	fs.StringVar(&cfg.projectId, "projectId", "", "projectId")
+	fs.StringVar(&cfg.dataflowRegion, "dataflowRegion", "", "region for dataflow jobs")
+	fs.StringVar(&cfg.jobNamePrefix, "jobNamePrefix", "reverse-rep", "job name prefix for the dataflow jobs, defaults to reverse-rep. Automatically converted to lower case due to Dataflow name constraints.")
+	fs.StringVar(&cfg.changeStreamName, "changeStreamName", "reverseReplicationStream", "change stream name, defaults to reverseReplicationStream")
+	fs.StringVar(&cfg.instanceId, "instanceId", "", "spanner instance id")
+	fs.StringVar(&cfg.dbName, "dbName", "", "spanner database name, or a comma separated list of databases on the instance replicated by the same pipeline. Each database gets its own change stream and ordering job, the writer jobs are shared")
+	fs.StringVar(&cfg.metadataProject, "metadataProject", "", "project of the spanner instance storing the changestream metadata, defaults to projectId")
+	fs.StringVar(&cfg.metadataInstance, "metadataInstance", "", "spanner instance name to store changestream metadata, defaults to target Spanner instance")
+	fs.BoolVar(&cfg.createMetadataInstance, "createMetadataInstance", false, "If metadataInstance is not provided, create a dedicated spanner instance with 100 processing units named <jobNamePrefix>-metadata to store changestream metadata, deleted by -cleanup")
+	fs.StringVar(&cfg.metadataDatabase, "metadataDatabase", "change-stream-metadata", "spanner database name to store changestream metadata, defaults to change-stream-metadata")
+	fs.StringVar(&cfg.tenant, "tenant", "", "team or owner the pipeline belongs to, recorded in the metadata database. Other tenants sharing the metadata database can't inspect, relaunch or delete the pipeline. Defaults to the account gcloud is logged in with")
+	fs.StringVar(&cfg.metadataTableSuffix, "metadataTableSuffix", "", "suffix appended to the names of the changestream metadata tables, needed when several pipelines share the same metadataDatabase. Defaults to empty string")
+	fs.BoolVar(&cfg.autoUniquifySuffix, "autoUniquifySuffix", false, "If metadataTableSuffix is already used by another active pipeline, pick a free suffix of the form <metadataTableSuffix>_<n> instead of failing")
+	fs.StringVar(&cfg.startTimestamp, "startTimestamp", "", "timestamp from which the changestream should start reading changes in RFC 3339 format, defaults to empty string which is equivalent to the current timestamp. Must be within the retention period of the changestream")
+	fs.StringVar(&cfg.endTimestamp, "endTimestamp", "", "timestamp at which the changestream should stop reading changes in RFC 3339 format, defaults to empty string which reads changes until the ordering job is stopped")
+	fs.StringVar(&cfg.pubSubDataTopicId, "pubSubDataTopicId", "reverse-replication", "pub/sub data topic id. DO NOT INCLUDE the prefix 'projects/<project_name>/topics/'. Defaults to 'reverse-replication'")
+	fs.StringVar(&cfg.pubSubEndpoint, "pubSubEndpoint", "", "pub/sub endpoint, defaults to same endpoint as the dataflow region.")
+	fs.StringVar(&cfg.sourceShardsFilePath, "sourceShardsFilePath", "", "gcs or local file path for file containing shard info. A local file is uploaded to artifactsPath")
+	fs.StringVar(&cfg.sessionFilePath, "sessionFilePath", "", "gcs or local file path for session file generated via Spanner migration tool. A local file is uploaded to artifactsPath")
+	fs.StringVar(&cfg.sourceType, "sourceType", constants.MYSQL, "Type of the source shards (mysql, sqlserver, cassandra, oracle), defaults to mysql. The other types need a writerTemplate supporting them, and oracle is experimental")
+	fs.BoolVar(&cfg.allowExperimental, "allowExperimental", false, "Allow the experimental features, i.e. the oracle sourceType. Defaults to false")
+	fs.StringVar(&cfg.sourceDbTimezoneOffset, "sourceDbTimezoneOffset", "", "Timezone offset of the source shards the writer jobs convert the Spanner timestamps to, e.g. +05:30. Defaults to the one of the sourceType, +00:00")
+	fs.StringVar(&cfg.artifactsPath, "artifactsPath", "", "gcs path the local sessionFilePath and sourceShardsFilePath are uploaded to, under a directory named after jobNamePrefix, e.g. gs://bucket-name/reverse-replication. Required when either of them is a local file")
+	fs.StringVar(&cfg.machineType, "machineType", settings.Get(settings.REVERSE_REPLICATION_MACHINE_TYPE), "dataflow worker machine type, defaults to n2-standard-4 or the reverseReplicationMachineType setting")
+	fs.StringVar(&cfg.vpcNetwork, "vpcNetwork", "", "Name of the VPC network to be used for the dataflow jobs")
+	fs.StringVar(&cfg.vpcSubnetwork, "vpcSubnetwork", "", "Name of the VPC subnetwork to be used for the dataflow jobs. Subnet should exist in the same region as the 'dataflowRegion' parameter")
+	fs.StringVar(&cfg.vpcHostProjectId, "vpcHostProjectId", "", "Project ID hosting the subnetwork. If unspecified, the 'projectId' parameter value will be used for subnetwork.")
+	fs.StringVar(&cfg.serviceAccountEmail, "serviceAccountEmail", "", "The email address of the service account to run the job as")
+	fs.IntVar(&cfg.orderingWorkers, "orderingWorkers", 5, "number of workers for ordering job")
+	fs.IntVar(&cfg.writerWorkers, "writerWorkers", 5, "number of workers for writer job")
+	fs.IntVar(&cfg.orderingMaxWorkers, "orderingMaxWorkers", 0, "maximum number of workers for ordering job, defaults to the Dataflow default")
+	fs.IntVar(&cfg.writerMaxWorkers, "writerMaxWorkers", 0, "maximum number of workers for writer job, defaults to the Dataflow default")
+	fs.BoolVar(&cfg.autoSizeWorkers, "autoSizeWorkers", false, "Derive machineType and the number of workers of the ordering and writer jobs from the number of source shards and writeQpsPerShard. Explicitly passed flags take precedence")
+	fs.Float64Var(&cfg.writeQpsPerShard, "writeQpsPerShard", 100, "Used with -autoSizeWorkers. Expected number of writes per second to replicate per source shard, defaults to 100")
+	fs.IntVar(&cfg.writerFanOut, "writerFanOut", 1, "number of writer jobs to split the source shards across, defaults to 1. Each writer job gets writerWorkers workers")
+	fs.BoolVar(&cfg.streamingEngine, "streamingEngine", false, "Enable Streaming Engine for the dataflow jobs, defaults to false")
+	fs.BoolVar(&cfg.runnerV2, "runnerV2", true, "Run the dataflow jobs on Dataflow Runner v2, defaults to true. The templates are tested on Runner v2, only disable it when an organization policy or the region doesn't allow it")
+	fs.StringVar(&cfg.stagingLocation, "stagingLocation", "", "gcs path reused by the dataflow jobs for staging and temporary files, e.g. gs://bucket-name/dataflow. Defaults to a directory of the pipeline under artifactsPath if set, else to a location chosen by Dataflow")
+	fs.StringVar(&cfg.tempLocation, "tempLocation", "", "gcs path of the temporary files of the dataflow jobs. Defaults to the temp directory under stagingLocation")
+	fs.IntVar(&cfg.diskSizeGb, "diskSizeGb", 0, "Size in GB of the disk of the dataflow workers, defaults to the Dataflow default")
+	fs.StringVar(&cfg.launcherMachineType, "launcherMachineType", "", "Machine type of the VM launching the dataflow jobs from the flex templates, defaults to the Dataflow default")
+	fs.StringVar(&cfg.flexrsGoal, "flexrsGoal", "", "Flexible resource scheduling goal of the dataflow jobs, SPEED_OPTIMIZED or COST_OPTIMIZED. Dataflow only supports it for batch jobs. Disabled by default")
+	fs.StringVar(&cfg.sdkContainerImage, "sdkContainerImage", "", "Custom container image of the Beam SDK run by the dataflow workers, e.g. gcr.io/my-project/beam-sdk:latest. Defaults to the image of the templates")
+	fs.StringVar(&cfg.kmsKeyName, "kmsKeyName", "", "Cloud KMS key encrypting the data at rest of the dataflow jobs, as projects/<project>/locations/<location>/keyRings/<key ring>/cryptoKeys/<key>. Defaults to a Google managed key")
+	fs.StringVar(&cfg.additionalExperiments, "additionalExperiments", "", "Comma separated experiments passed to the dataflow jobs in addition to the ones the launcher sets, e.g. enable_stackdriver_agent_metrics")
+	fs.StringVar(&cfg.templateCacheDir, "templateCacheDir", "", "Local directory caching the validated dataflow template specs, to skip template validation when repeatedly launching pipelines. Disabled by default")
+	fs.StringVar(&cfg.networkTags, "networkTags", "", "Network tags addded to the Dataflow jobs worker and launcher VMs")
+	fs.StringVar(&cfg.filtrationMode, "filtrationMode", "forward_migration", "Whether to filter forward migrated data or not. Supported values are forward_migration and none, defaults to 'forward_migration'")
+	fs.StringVar(&cfg.filterConfigFile, "filterConfigFile", "", "gcs or local path of a json file listing the tables (excludeTables) and the columns of each table (excludeColumns) which are not replicated. Applies to the changestream created by the launcher")
+	fs.StringVar(&cfg.shardingFunction, "shardingFunction", SHARDING_FUNCTION_IDENTITY, "built-in function assigning the changes to the source shards. Supported values are identity (the shard id column of the session file), modulo and rangeMap, defaults to 'identity'. modulo and rangeMap need an orderingTemplate supporting the shardingConfigFilePath parameter")
+	fs.StringVar(&cfg.shardingColumn, "shardingColumn", "", "Spanner column of every table holding the integer sharding key of the modulo and rangeMap shardingFunction")
+	fs.StringVar(&cfg.shardingRanges, "shardingRanges", "", "comma separated <start>:<logicalShardId> ranges of sharding key values of the rangeMap shardingFunction, e.g. 0:shard1,1000000:shard2. Each range ends at the start of the next one")
+	fs.StringVar(&cfg.changeStreamRetention, "changeStreamRetention", "1d", "minimum retention period of the change stream, between 1d and 7d, in the format of the change stream retention_period option, e.g. 36h. Increase it to survive longer outages of the source shards. Defaults to 1d")
+	fs.StringVar(&cfg.changeStreamValueCaptureType, "changeStreamValueCaptureType", "NEW_ROW", "value capture type of the change stream, NEW_ROW or NEW_ROW_AND_OLD_VALUES. Used when creating the change stream, and checked for an existing one. Defaults to NEW_ROW")
+	fs.BoolVar(&cfg.autoFixChangeStream, "autoFixChangeStream", false, "If an existing change stream does not have the required options, alter it to set them after confirmation, instead of failing")
+	fs.BoolVar(&cfg.forceChangeStream, "forceChangeStream", false, "Create the change stream even if the CPU utilization of the Spanner instance was recently above 65%, instead of failing")
+	fs.StringVar(&cfg.tags, "tags", "", "Comma separated tags of the pipeline, each a key or key=value, e.g. env=prod,app=billing. They are set as labels of the dataflow jobs, and filter the pipelines listed by reverse-replication list")
+	fs.BoolVar(&cfg.rollbackOnFailure, "rollbackOnFailure", false, "Cancel the dataflow jobs already launched if the creation of the pipeline fails or is interrupted. By default they are left running, so that relaunching with the same jobNamePrefix completes the pipeline")
+	fs.BoolVar(&cfg.verify, "verifyPipeline", false, "After launching, write a marker row per shard to verifyTable in Spanner and wait for it to reach the source shards, to check that the pipeline works end to end")
+	fs.StringVar(&cfg.verifyTable, "verifyTable", "", "Used with -verifyPipeline and -cutback. Table present in Spanner and the source shards, with a string primary key column named id, used for the marker rows")
+	fs.IntVar(&cfg.verifySample, "verifySample", 0, "Instead of launching the pipeline, sample this many primary keys per database from the changes read from the changestream over the last verifySampleWindow, and check that the source shards hold the same rows as Spanner, reporting the match rate per shard. Disabled by default")
+	fs.DurationVar(&cfg.verifySampleWindow, "verifySampleWindow", time.Hour, "Used with -verifySample. Window of the recent changes the keys are sampled from, within the retention of the changestream. The changes of the last minute are left out. Defaults to 1h")
+	fs.DurationVar(&cfg.verifyTimeout, "verifyTimeout", 20*time.Minute, "Used with -verifyPipeline. Maximum time to wait for the marker rows to reach the source shards, defaults to 20m")
+	fs.BoolVar(&cfg.cutback, "cutback", false, "Instead of launching the pipeline, cut back to the source shards once the application stopped writing to Spanner: wait for every change to be replicated using marker rows written to verifyTable, then drain the ordering and writer jobs")
+	fs.DurationVar(&cfg.cutbackTimeout, "cutbackTimeout", time.Hour, "Used with -cutback. Maximum time the cutback may take, defaults to 1h")
+	fs.BoolVar(&cfg.updateShards, "updateShards", false, "Instead of launching the pipeline, move the running pipeline from the shards of sourceShardsFilePath to the ones of newSourceShardsFilePath, relaunching the writer jobs")
+	fs.StringVar(&cfg.newSourceShardsFilePath, "newSourceShardsFilePath", "", "Used with -updateShards and -rotateCredentials. gcs path of the source shards file listing the shards to replicate to from now on")
+	fs.BoolVar(&cfg.rotateCredentials, "rotateCredentials", false, "Instead of launching the pipeline, relaunch the writer jobs with the shard credentials of newSourceShardsFilePath, which must list the same shards as sourceShardsFilePath")
+	fs.DurationVar(&cfg.updateShardsTimeout, "updateShardsTimeout", 30*time.Minute, "Used with -updateShards, -rotateCredentials and -applySessionUpdate. Maximum time to wait for the writer jobs to drain, defaults to 30m")
+	fs.BoolVar(&cfg.estimateCost, "estimateCost", false, "Instead of launching the pipeline, print the approximate monthly cost of running it with the given Dataflow configs")
+	fs.Float64Var(&cfg.monthlyChangeVolumeGB, "monthlyChangeVolumeGB", 0, "Used with -estimateCost. Expected volume of changes replicated per month in GB, defaults to 0")
+	fs.StringVar(&cfg.orderingTemplate, "orderingTemplate", settings.Get(settings.ORDERING_TEMPLATE), "gcs path of the ordering job flex template, defaults to the orderingTemplate setting or the template version validated with this launcher")
+	fs.StringVar(&cfg.orderingRunMode, "orderingRunMode", RUN_MODE_REGULAR, "run mode of the ordering job. Supported values are regular, resumeFailed, resumeSuccess and resumeAll, defaults to 'regular'. The resume modes need an orderingTemplate supporting the runMode parameter")
+	fs.BoolVar(&cfg.relaunchOrdering, "relaunchOrdering", false, "Instead of launching the pipeline, relaunch the ordering jobs of a previously launched pipeline in orderingRunMode, e.g. to recover from failed ordering jobs. The other resources of the pipeline are left untouched")
+	fs.StringVar(&cfg.writerTemplate, "writerTemplate", settings.Get(settings.WRITER_TEMPLATE), "gcs path of the writer job flex template, defaults to the writerTemplate setting or the template version validated with this launcher")
+	fs.StringVar(&cfg.writerTransformationJarPath, "writerTransformationJarPath", "", "gcs path of a jar with a custom transformation applied by the writer jobs to the values written to the source. Needs a writerTemplate supporting custom transformations")
+	fs.StringVar(&cfg.writerTransformationClassName, "writerTransformationClassName", "", "fully qualified name of the custom transformation class in writerTransformationJarPath")
+	fs.BoolVar(&cfg.reprocessSkipped, "reprocessSkipped", false, "Instead of launching the pipeline, launch a writer job in reprocessing mode to apply the changes skipped by the writer jobs of a previously launched pipeline, e.g. after fixing the rows they failed on at the source. Needs a writerTemplate supporting the runMode parameter")
+	fs.StringVar(&cfg.reprocessShardIds, "reprocessShardIds", "", "Used with -reprocessSkipped. Comma separated logicalShardIds of the shards whose skipped changes are reprocessed, defaults to all the shards")
+	fs.StringVar(&cfg.reprocessStart, "reprocessStartTimestamp", "", "Used with -reprocessSkipped. Only reprocess the changes skipped from this timestamp on, in RFC 3339 format. Defaults to the oldest skipped change")
+	fs.StringVar(&cfg.reprocessEnd, "reprocessEndTimestamp", "", "Used with -reprocessSkipped. Only reprocess the changes skipped before this timestamp, in RFC 3339 format. Defaults to the newest skipped change")
+	fs.BoolVar(&cfg.validate, "validate", false, "Instead of launching the pipeline, compare the row counts and checksums of the tables of the session file between Spanner and every source shard, and print a reconciliation report")
+	fs.StringVar(&cfg.validateTables, "validateTables", "", "Used with -validate. Comma separated Spanner names of the tables to compare, defaults to all the tables of the session file")
+	fs.IntVar(&cfg.validateMaxRows, "validateMaxRows", 1000000, "Used with -validate. Tables with more rows in a shard only have their row counts compared, as the checksums are computed by reading all the rows. Defaults to 1000000")
+	fs.StringVar(&cfg.validateReportPath, "validateReportPath", "", "Used with -validate. Local file the reconciliation report is written to in json format")
+	fs.DurationVar(&cfg.validateEvery, "validateEvery", 0, "Used with -validate. Interval at which the validation is repeated while the pipeline is running, e.g. 6h, recording every run in the metadata database. Disabled by default")
+	fs.Float64Var(&cfg.validateDriftThreshold, "validateDriftThreshold", 0, "Used with -validateEvery. Fraction of the validated tables, per shard, which may differ between Spanner and the source before an alert is raised. Defaults to 0, alerting on any mismatch")
+	fs.StringVar(&cfg.validateAlertTopic, "validateAlertTopic", "", "Used with -validateEvery. Pub/Sub topic the drift alerts are published to, in addition to being logged")
+	fs.BoolVar(&cfg.detectSchemaDrift, "detectSchemaDrift", false, "Instead of launching the pipeline, compare the schema of the replicated databases with the Spanner schema of sessionFilePath, and report the added or dropped tables and columns and the type changes")
+	fs.DurationVar(&cfg.schemaDriftEvery, "schemaDriftEvery", 0, "Used with -detectSchemaDrift. Interval at which the comparison is repeated while the pipeline is running, e.g. 1h. Disabled by default")
+	fs.BoolVar(&cfg.applySessionUpdateMode, "applySessionUpdate", false, "Instead of launching the pipeline, relaunch the writer jobs with newSessionFilePath, once the schema changes reported by -detectSchemaDrift were applied to the source shards")
+	fs.StringVar(&cfg.newSessionFilePath, "newSessionFilePath", "", "Used with -applySessionUpdate. Session file matching the schema of the replicated databases, e.g. the one written by -detectSchemaDrift, to use from now on. Local files are uploaded to artifactsPath")
+	fs.StringVar(&cfg.logLevel, "logLevel", "INFO", "Lowest level of the logs written by the launcher, e.g. INFO or DEBUG. Defaults to INFO, which includes the progress of the launcher")
+	fs.StringVar(&cfg.logFormat, "logFormat", logger.CONSOLE_ENCODING, "Encoding of the logs written to the console (console, json)")
+	fs.StringVar(&cfg.logFile, "logFile", logger.LOG_FILE_NAME, "File the logs are appended to")
+	fs.BoolVar(&cfg.cleanup, "cleanup", false, "Instead of launching the pipeline, delete the resources of a previously launched pipeline whose Dataflow jobs are no longer running")
+	fs.BoolVar(&cfg.dryRun, "dryRun", false, "Used with -cleanup. Only report the orphaned resources, without deleting them")
+	fs.BoolVar(&cfg.backfill, "backfill", false, "Before launching the ordering jobs, copy the rows the tables of the session file have in Spanner to the empty source shards, from a snapshot from which the changestream is then read. Needs a sourceType the launcher can connect to, and can't be used with startTimestamp")
+	fs.StringVar(&cfg.backfillTables, "backfillTables", "", "Used with -backfill. Comma separated Spanner names of the tables to copy, defaults to all the tables of the session file")
+	fs.IntVar(&cfg.backfillBatchSize, "backfillBatchSize", 500, "Used with -backfill. Number of rows written to the source shards per transaction. Defaults to 500")
+}
+
+// checkPipelineFlags checks the flags identifying the pipeline and its
+// metadata database, and applies their defaults.
+func (cfg *config) checkPipelineFlags() error {
+	if err := settings.Load(); err != nil {
+		return err
+	}
+	if cfg.projectId == "" {
+		return fmt.Errorf("please specify a valid projectId")
+	}
+	if cfg.dataflowRegion == "" {
+		return fmt.Errorf("please specify a valid dataflowRegion")
+	}
+	if cfg.jobNamePrefix == "" {
+		return fmt.Errorf("please specify a non-empty jobNamePrefix")
+	} else {
+		// Capital letters not allowed in Dataflow job names.
+		cfg.jobNamePrefix = strings.ToLower(cfg.jobNamePrefix)
+	}
+	if cfg.changeStreamName == "" {
+		return fmt.Errorf("please specify a valid changeStreamName")
+	}
+	if cfg.instanceId == "" {
+		return fmt.Errorf("please specify a valid instanceId")
+	}
+	if err := validateDatabaseIds(cfg.getDatabaseIds()); err != nil {
+		return err
+	}
+	if cfg.metadataProject == "" {
+		cfg.metadataProject = cfg.projectId
+	}
+	if cfg.metadataInstance == "" && cfg.createMetadataInstance {
+		cfg.metadataInstance = cfg.getMetadataInstanceId()
+		logger.FromContext(context.Background()).Info(fmt.Sprintf("metadataInstance not provided, using a dedicated instance: %s", cfg.metadataInstance))
+	}
+	if cfg.metadataInstance == "" {
+		cfg.metadataInstance = cfg.instanceId
+		logger.FromContext(context.Background()).Info(fmt.Sprintf("metadataInstance not provided, defaulting to target spanner instance id: %s", cfg.metadataInstance))
+	}
+	if cfg.metadataDatabase == "" {
+		cfg.metadataDatabase = "change-stream-metadata"
+		logger.FromContext(context.Background()).Info(fmt.Sprintf("metadataDatabase not provided, defaulting to: %s", cfg.metadataDatabase))
+	}
+	return nil
+}
+
+func (cfg *config) prechecks() error {
+	if err := cfg.checkPipelineFlags(); err != nil {
+		return err
+	}
+	if !metadataTableSuffixRegex.MatchString(cfg.metadataTableSuffix) {
+		return fmt.Errorf("please specify a valid metadataTableSuffix, only letters, digits and underscores are allowed")
+	}
+	if cfg.pubSubDataTopicId == "" {
+		return fmt.Errorf("please specify a valid pubSubDataTopicId")
+	} else if strings.Contains(cfg.pubSubDataTopicId, "/") {
+		return fmt.Errorf("please specify a valid pubSubDataTopicId. '/' is not a valid character for topic id. DO NOT INCLUDE the prefix 'projects/<project_name>/topics/' for this flag.")
+	}
+	if err := cfg.checkChangeStreamFlags(); err != nil {
+		return err
+	}
+	if cfg.sourceShardsFilePath == "" {
+		return fmt.Errorf("please specify a valid sourceShardsFilePath")
+	}
+	if cfg.sessionFilePath == "" && !cfg.cleanup {
+		return fmt.Errorf("please specify a valid sessionFilePath")
+	}
+	if (cfg.sessionFilePath != "" && !isGcsPath(cfg.sessionFilePath)) || !isGcsPath(cfg.sourceShardsFilePath) || (cfg.newSessionFilePath != "" && !isGcsPath(cfg.newSessionFilePath)) {
+		if !isGcsPath(cfg.artifactsPath) {
+			return fmt.Errorf("please specify a valid artifactsPath starting with gs:// to upload the local sessionFilePath and sourceShardsFilePath to")
+		}
+		if cfg.sessionFilePath != "" && !isGcsPath(cfg.sessionFilePath) && !isGcsPath(cfg.sourceShardsFilePath) && filepath.Base(cfg.sessionFilePath) == filepath.Base(cfg.sourceShardsFilePath) {
+			return fmt.Errorf("the local sessionFilePath and sourceShardsFilePath must have different file names")
+		}
+		if cfg.newSessionFilePath != "" && !isGcsPath(cfg.newSessionFilePath) && (filepath.Base(cfg.newSessionFilePath) == filepath.Base(cfg.sessionFilePath) || filepath.Base(cfg.newSessionFilePath) == filepath.Base(cfg.sourceShardsFilePath)) {
+			return fmt.Errorf("the local newSessionFilePath must have a different file name than sessionFilePath and sourceShardsFilePath")
+		}
+	}
+	switch cfg.orderingRunMode {
+	case RUN_MODE_REGULAR, RUN_MODE_RESUME_FAILED, RUN_MODE_RESUME_SUCCESS, RUN_MODE_RESUME_ALL:
+	default:
+		return fmt.Errorf("please specify a valid orderingRunMode. Supported values are %s, %s, %s and %s", RUN_MODE_REGULAR, RUN_MODE_RESUME_FAILED, RUN_MODE_RESUME_SUCCESS, RUN_MODE_RESUME_ALL)
+	}
+	if countSet(cfg.relaunchOrdering, cfg.reprocessSkipped, cfg.validate, cfg.verifySample > 0, cfg.detectSchemaDrift, cfg.applySessionUpdateMode, cfg.cutback, cfg.updateShards, cfg.rotateCredentials, cfg.cleanup) > 1 {
+		return fmt.Errorf("only one of relaunchOrdering, reprocessSkipped, validate, verifySample, detectSchemaDrift, applySessionUpdate, cutback, updateShards, rotateCredentials and cleanup can be used at a time")
+	}
+	if cfg.applySessionUpdateMode && (cfg.newSessionFilePath == "" || cfg.newSessionFilePath == cfg.sessionFilePath) {
+		return fmt.Errorf("please specify a valid newSessionFilePath, other than sessionFilePath, to use with applySessionUpdate")
+	}
+	if cfg.schemaDriftEvery < 0 || (cfg.schemaDriftEvery > 0 && !cfg.detectSchemaDrift) {
+		return fmt.Errorf("schemaDriftEvery must be positive and used with -detectSchemaDrift")
+	}
+	if (cfg.updateShards || cfg.rotateCredentials) && (!isGcsPath(cfg.newSourceShardsFilePath) || cfg.newSourceShardsFilePath == cfg.sourceShardsFilePath) {
+		return fmt.Errorf("please specify a valid newSourceShardsFilePath starting with gs://, other than sourceShardsFilePath, to use with updateShards and rotateCredentials")
+	}
+	if cfg.validateMaxRows < 0 {
+		return fmt.Errorf("please specify a non-negative validateMaxRows")
+	}
+	if cfg.validateEvery < 0 || (cfg.validateEvery > 0 && !cfg.validate) {
+		return fmt.Errorf("validateEvery must be positive and used with -validate")
+	}
+	if cfg.validateDriftThreshold < 0 || cfg.validateDriftThreshold >= 1 {
+		return fmt.Errorf("please specify a validateDriftThreshold in the range [0, 1)")
+	}
+	if err := cfg.validateReprocessWindow(); err != nil {
+		return err
+	}
+	if err := cfg.validateReadWindow(); err != nil {
+		return err
+	}
+	if cfg.verify && cfg.verifyTable == "" {
+		return fmt.Errorf("please specify a valid verifyTable to use with verifyPipeline")
+	}
+	if cfg.cutback && cfg.verifyTable == "" {
+		return fmt.Errorf("please specify a valid verifyTable to use with cutback")
+	}
+	if err := cfg.validateSource(); err != nil {
+		return err
+	}
+	if cfg.verifySample < 0 || cfg.verifySampleWindow <= VERIFY_SAMPLE_SETTLE_TIME {
+		return fmt.Errorf("please specify a non-negative verifySample and a verifySampleWindow longer than %s", VERIFY_SAMPLE_SETTLE_TIME)
+	}
+	if cfg.verifySample > 0 && cfg.getShardDriverName() == "" {
+		return fmt.Errorf("verifySample is not supported for %s sources, as the launcher can't connect to them", cfg.sourceType)
+	}
+	if cfg.backfill && cfg.getShardDriverName() == "" {
+		return fmt.Errorf("backfill is not supported for %s sources, as the launcher can't connect to them", cfg.sourceType)
+	}
+	if cfg.backfill && cfg.startTimestamp != "" {
+		return fmt.Errorf("startTimestamp can't be used with backfill, as the changestream is read from the snapshot copied to the source shards")
+	}
+	if cfg.backfillBatchSize < 1 {
+		return fmt.Errorf("please specify a backfillBatchSize of at least 1")
+	}
+	if err := cfg.validateWriterTransformation(); err != nil {
+		return err
+	}
+	if err := cfg.validateSharding(); err != nil {
+		return err
+	}
+	if cfg.stagingLocation != "" && !strings.HasPrefix(cfg.stagingLocation, "gs://") {
+		return fmt.Errorf("please specify a valid stagingLocation starting with gs://")
+	}
+	if cfg.tempLocation != "" && !isGcsPath(cfg.tempLocation) {
+		return fmt.Errorf("please specify a valid tempLocation starting with gs://")
+	}
+	if cfg.artifactsPath != "" && !isGcsPath(cfg.artifactsPath) {
+		return fmt.Errorf("please specify a valid artifactsPath starting with gs://")
+	}
+	if _, err := parseTags(cfg.tags); err != nil {
+		return err
+	}
+	if err := cfg.validateRuntimeEnvironmentFlags(); err != nil {
+		return err
+	}
+	if cfg.writerFanOut < 1 {
+		return fmt.Errorf("please specify a writerFanOut of at least 1")
+	}
+	if cfg.orderingMaxWorkers < 0 || cfg.writerMaxWorkers < 0 {
+		return fmt.Errorf("please specify a non-negative orderingMaxWorkers and writerMaxWorkers")
+	}
+	if cfg.autoSizeWorkers && cfg.writeQpsPerShard <= 0 {
+		return fmt.Errorf("please specify a positive writeQpsPerShard to use with autoSizeWorkers")
+	}
+	if cfg.machineType == "" {
+		cfg.machineType = settings.Get(settings.REVERSE_REPLICATION_MACHINE_TYPE)
+		logger.FromContext(context.Background()).Info(fmt.Sprintf("machineType not provided, defaulting to: %s", cfg.machineType))
+	}
+	if cfg.pubSubEndpoint == "" {
+		cfg.pubSubEndpoint = fmt.Sprintf("%s-pubsub.googleapis.com:443", cfg.dataflowRegion)
+	}
+	if cfg.vpcHostProjectId == "" {
+		cfg.vpcHostProjectId = cfg.projectId
+	}
+	return nil
+}
+
+// Main runs the launcher with the pipeline configuration of the command line
+// flags.
+func Main() {
+	fmt.Println("Setting up reverse replication pipeline...")
+	cfg := &config{}
+	cfg.setupFlags(flag.CommandLine)
+	flag.Parse()
+	cfg.definitionFlags = flag.CommandLine
+
+	if cfg.estimateCost {
+		estimate, err := EstimateCost(context.Background(), CostEstimateRequest{
+			MachineType:           cfg.machineType,
+			OrderingWorkers:       cfg.orderingWorkers,
+			WriterWorkers:         cfg.writerWorkers,
+			WriterJobs:            cfg.writerFanOut,
+			StreamingEngine:       cfg.streamingEngine,
+			MonthlyChangeVolumeGB: cfg.monthlyChangeVolumeGB,
+		})
+		if err != nil {
+			fmt.Println("Error in estimating cost:", err)
+			return
+		}
+		printCostEstimate(estimate)
+		return
+	}
+
+	// The launcher reports its progress through the logger, including the
+	// defaults the prechecks fill in.
+	if err := logger.InitializeLoggerWithConfig(logger.Config{Level: cfg.logLevel, Encoding: cfg.logFormat, OutputPath: cfg.logFile}); err != nil {
+		fmt.Println("Error in initializing the logger:", err)
+		return
+	}
+	defer logger.Log.Sync()
+	err := cfg.prechecks()
+	if err != nil {
+		fmt.Println("incorrect arguments passed:", err)
+		return
+	}
+
+	ctx, stop := utils.WithShutdownSignals(context.Background())
+	defer stop()
+	ctx = logger.WithMigration(WithClientProvider(ctx, DefaultClientProvider()), cfg.jobNamePrefix, "reverse_replication")
+	flushTraces, err := tracing.Init(ctx)
+	if err != nil {
+		fmt.Println("Error in initializing the tracing:", err)
+		return
+	}
+	defer flushTraces()
+	if err := cfg.uploadLocalArtifacts(ctx); err != nil {
+		fmt.Println("Error in uploading local files:", err)
+		return
+	}
+	// Launching a pipeline checks its tenant once the metadata database
+	// exists.
+	if cfg.relaunchOrdering || cfg.validate || cfg.verifySample > 0 || cfg.detectSchemaDrift || cfg.applySessionUpdateMode || cfg.updateShards || cfg.rotateCredentials || cfg.cutback || cfg.reprocessSkipped || cfg.cleanup {
+		if err := cfg.checkTenantAccess(ctx); err != nil {
+			fmt.Println("Error in checking the tenant of the pipeline:", err)
+			return
+		}
+	}
+	if cfg.relaunchOrdering {
+		fmt.Printf("Relaunching the ordering jobs in %s mode...\n", cfg.orderingRunMode)
+		if err := cfg.relaunchOrderingJobs(ctx, cfg.getDatabaseIds()); err != nil {
+			fmt.Println("Error in relaunching the ordering jobs:", err)
+		}
+		return
+	}
+	if cfg.validate && cfg.validateEvery > 0 {
+		fmt.Printf("Validating the tables between Spanner and the source shards every %s...\n", cfg.validateEvery)
+		if err := cfg.runScheduledValidation(ctx); err != nil {
+			fmt.Println("Error in the scheduled validation:", err)
+		}
+		return
+	}
+	if cfg.validate {
+		fmt.Println("Validating the tables between Spanner and the source shards...")
+		if err := cfg.runValidation(ctx); err != nil {
+			fmt.Println("Error in validating the tables:", err)
+		}
+		return
+	}
+	if cfg.verifySample > 0 {
+		fmt.Printf("Verifying %d sampled rows per database between Spanner and the source shards...\n", cfg.verifySample)
+		if err := cfg.runSampleVerification(ctx); err != nil {
+			fmt.Println("Error in verifying the sampled rows:", err)
+		}
+		return
+	}
+	if cfg.detectSchemaDrift {
+		fmt.Println("Comparing the schema of the replicated databases with the session file...")
+		if err := cfg.runSchemaDriftCheck(ctx); err != nil {
+			fmt.Println("Error in checking the schema drift:", err)
+		}
+		return
+	}
+	if cfg.applySessionUpdateMode {
+		fmt.Println("Applying the session update to the writer jobs...")
+		if err := cfg.applySessionUpdate(ctx); err != nil {
+			fmt.Println("Error in applying the session update:", err)
+		}
+		return
+	}
+	if cfg.updateShards {
+		fmt.Println("Updating the source shards of the pipeline...")
+		if err := cfg.updateSourceShards(ctx); err != nil {
+			fmt.Println("Error in updating the source shards:", err)
+		}
+		return
+	}
+	if cfg.rotateCredentials {
+		fmt.Println("Rotating the source shard credentials...")
+		if err := cfg.rotateSourceCredentials(ctx); err != nil {
+			fmt.Println("Error in rotating the source shard credentials:", err)
+		}
+		return
+	}
+	if cfg.cutback {
+		fmt.Println("Cutting back to the source shards...")
+		if err := cfg.runCutback(ctx); err != nil {
+			fmt.Println("Error in cutting back:", err)
+		}
+		return
+	}
+	if cfg.reprocessSkipped {
+		fmt.Println("Reprocessing the skipped changes...")
+		if err := cfg.reprocessSkippedChanges(ctx); err != nil {
+			fmt.Println("Error in reprocessing the skipped changes:", err)
+		}
+		return
+	}
+	if cfg.cleanup {
+		fmt.Println("Looking for orphaned reverse replication resources...")
+		if err := cfg.cleanupOrphans(ctx); err != nil {
+			fmt.Println("Error in cleaning up orphaned resources:", err)
+		}
+		return
+	}
+
+	if err := cfg.launchPipeline(ctx); err != nil {
+		fmt.Println("Error in launching the pipeline:", err)
+	}
+}
+
+// launchPipeline creates the metadata database, validates or creates the
+// change stream of every database while holding its creation lock, creates
+// the Pub/Sub resources of the pipeline and launches its ordering and writer
+// jobs. The pipeline is verified
+// afterwards if verify is set. The outcome of the creation is recorded in the
+// metadata database, including when ctx is cancelled midway.
+func (cfg *config) launchPipeline(ctx context.Context) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "LaunchPipeline", attribute.String("jobNamePrefix", cfg.jobNamePrefix))
+	defer func() { tracing.EndSpan(span, err) }()
+	dbs := cfg.getDatabaseIds()
+	multiDb := len(dbs) > 1
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	// Every database gets its own change stream and ordering job, while the
+	// writer jobs are shared by all of them. The session file is validated
+	// against each database, so they all have the same dialect.
+	spClients := make(map[string]*spanner.Client)
+	var dialect string
+	for _, db := range dbs {
+		dbUri := cfg.getDbUri(db)
+		spClient, err := getClients(ctx).NewSpannerClient(ctx, dbUri)
+		if err != nil {
+			return fmt.Errorf("could not create spanner client for %s: %v", dbUri, err)
+		}
+		defer spClient.Close()
+		spClients[db] = spClient
+		dialect, err = spanneradmin.GetDatabaseDialect(ctx, adminClient, dbUri)
+		if err != nil {
+			return fmt.Errorf("could not get the database dialect: %v", err)
+		}
+		if err := cfg.validateSessionDialect(ctx, db, dialect); err != nil {
+			return fmt.Errorf("invalid session file: %v", err)
+		}
+	}
+	instances, err := getClients(ctx).getInstanceInfoCache(ctx)
+	if err != nil {
+		return err
+	}
+	if cfg.createMetadataInstance && cfg.metadataInstance == cfg.getMetadataInstanceId() {
+		if err := cfg.provisionMetadataInstance(ctx, instances); err != nil {
+			return err
+		}
+	}
+	metadataDbCreated, err := cfg.createMetadataDatabase(ctx, adminClient, dialect)
+	if err != nil {
+		return err
+	}
+	store, err := getClients(ctx).openMetadataStore(ctx, cfg, adminClient)
+	if err != nil {
+		return fmt.Errorf("could not open the metadata db: %v", err)
+	}
+	defer store.Close()
+	if metadataDbCreated {
+		if err := store.RecordCreatedResource(ctx, createdResource{kind: CREATED_METADATA_DATABASE, name: cfg.metadataDatabase}); err != nil {
+			return err
+		}
+	}
+	if err := cfg.checkTenant(ctx, store); err != nil {
+		return err
+	}
+	// The change streams and metadata tables of a database are only set up by
+	// one creation at a time, whatever the metadata database of the creation.
+	locks, err := getClients(ctx).openCreationLockStore(ctx, cfg, adminClient)
+	if err != nil {
+		return fmt.Errorf("could not open the creation locks db: %v", err)
+	}
+	defer locks.Close()
+	holder := uuid.New().String()
+	release, err := cfg.acquireCreationLocks(ctx, locks, dbs, holder)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := store.RecordCreationStatus(ctx, holder, CREATION_STATUS_CREATING, ""); err != nil {
+		return err
+	}
+	jobsLaunched := false
+	defer func() {
+		cfg.finishCreation(ctx, store, holder, jobsLaunched, err)
+	}()
+	if err := cfg.recordJobDefinition(ctx, store); err != nil {
+		return err
+	}
+	for _, db := range dbs {
+		dbUri := cfg.getDbUri(db)
+		err = cfg.validateOrCreateChangeStream(ctx, adminClient, instances, store, spClients[db], db, dialect)
+		if err != nil {
+			return fmt.Errorf("could not validate or create the changestream in %s: %v", dbUri, err)
+		}
+		if err := cfg.validateStartTimestamp(ctx, adminClient, spClients[db], dbUri, dialect); err != nil {
+			return err
+		}
+	}
+	suffixes := make(map[string]string)
+	for _, db := range dbs {
+		suffix, err := cfg.reserveMetadataTableSuffix(ctx, store, db, cfg.getDatabaseTableSuffix(db, multiDb))
+		if err != nil {
+			return fmt.Errorf("invalid metadataTableSuffix: %v", err)
+		}
+		suffixes[db] = suffix
+	}
+
+	shards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return fmt.Errorf("could not read source shards: %v", err)
+	}
+	arr, err := getLogicalShardIds(shards)
+	if err != nil {
+		return fmt.Errorf("could not read source shards: %v", err)
+	}
+	if err := cfg.validateSourceShards(shards); err != nil {
+		return fmt.Errorf("invalid source shards: %v", err)
+	}
+	if err := cfg.writeShardingConfig(ctx, arr); err != nil {
+		return fmt.Errorf("could not write the sharding config: %v", err)
+	}
+	// The changestreams exist, so the changes committed after the snapshot are
+	// read by the ordering jobs, which start right after it.
+	if cfg.backfill {
+		snapshot, err := cfg.runBackfill(ctx, spClients, dialect, shards)
+		if err != nil {
+			return fmt.Errorf("could not backfill the source shards: %v", err)
+		}
+		var dbSuffixes []string
+		for _, db := range dbs {
+			dbSuffixes = append(dbSuffixes, suffixes[db])
+		}
+		if err := cfg.coordinateBackfill(ctx, store, dbSuffixes, snapshot); err != nil {
+			return fmt.Errorf("could not coordinate the backfill with the changestreams: %v", err)
+		}
+	}
+	shardGroups := partitionShards(shards, cfg.writerFanOut)
+	if cfg.autoSizeWorkers {
+		sizing, err := computeWorkerSizing(len(shards), len(shardGroups), cfg.writeQpsPerShard)
+		if err != nil {
+			return fmt.Errorf("could not auto size the dataflow jobs: %v", err)
+		}
+		sizing = cfg.applyWorkerSizing(sizing)
+		logWorkerSizing(ctx, sizing)
+		if err := recordWorkerSizing(ctx, store, dbs, suffixes, sizing); err != nil {
+			return fmt.Errorf("could not record the worker sizing: %v", err)
+		}
+	}
+
+	pubSubDataTopicUri := fmt.Sprintf("projects/%s/topics/%s", cfg.projectId, cfg.pubSubDataTopicId)
+	topicName := cfg.pubSubDataTopicId
+	client, err := getClients(ctx).NewPubsubClient(ctx, cfg.projectId)
+	if err != nil {
+		return fmt.Errorf("could not create pubsub client: %v", err)
+	}
+	defer client.Close()
+	_, err = gcp.DoWithResult(ctx, gcp.Call{Service: gcp.PUBSUB, Method: "CreateTopic", Resource: pubSubDataTopicUri}, func(ctx context.Context) (*pubsub.Topic, error) {
+		return client.CreateTopic(ctx, topicName)
+	})
+	if err != nil {
+		if !gcp.IsAlreadyExists(err) {
+			return fmt.Errorf("could not create topic: %v", err)
+		} else {
+			logger.FromContext(ctx).Info(fmt.Sprintf("topic '%s' already exists, skipping creation...", topicName))
+		}
+	} else {
+		logger.FromContext(ctx).Info(fmt.Sprintf("Created topic %s", pubSubDataTopicUri))
+	}
+	// Every goroutine reports the outcome of its shard in its own slot.
+	subErrors := make([]error, len(arr))
+	wg := &sync.WaitGroup{}
+	for i := 0; i < len(arr); i++ {
+		wg.Add(1)
+		go func(i int, shardId string) {
+			defer wg.Done()
+			err := cfg.createShardSubscription(ctx, client, shardId)
+			if err != nil {
+				if !gcp.IsAlreadyExists(err) {
+					subErrors[i] = fmt.Errorf("could not create subscription %s: %v", shardId, err)
+					return
+				}
+				if err := cfg.verifySubscription(ctx, client, shardId); err != nil {
+					subErrors[i] = fmt.Errorf("subscription '%s' already exists, but is configured incorrectly: %v", shardId, err)
+					return
+				}
+				logger.FromContext(ctx).Info(fmt.Sprintf("subscription '%s' already exists, skipping creation", shardId))
+				return
+			}
+			logger.FromContext(ctx).Info(fmt.Sprintf("Created Pub/Sub subscription: %s", shardId))
+		}(i, arr[i])
+	}
+	wg.Wait()
+	var subFailures []string
+	for _, err := range subErrors {
+		if err != nil {
+			subFailures = append(subFailures, err.Error())
+		}
+	}
+	if len(subFailures) > 0 {
+		return fmt.Errorf("could not create or validate the subscriptions: %s", strings.Join(subFailures, "; "))
+	}
+
+	c, err := getClients(ctx).NewDataflowAccessor(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create dataflow client: %v", err)
+	}
+	defer c.Close()
+
+	// A launch request may succeed even if it is interrupted.
+	jobsLaunched = true
+	for _, db := range dbs {
+		req, err := cfg.getOrderingJobRequest(db, suffixes[db], multiDb)
+		if err != nil {
+			return fmt.Errorf("could not launch ordering job: %v", err)
+		}
+		if err := cfg.launchJob(ctx, c, req, "ordering"); err != nil {
+			return fmt.Errorf("could not launch ordering job: %v", err)
+		}
+	}
+
+	if _, err := cfg.launchWriterJobs(ctx, c, shardGroups); err != nil {
+		return err
+	}
+
+	if cfg.verify {
+		for _, db := range dbs {
+			if multiDb {
+				logger.FromContext(ctx).Info(fmt.Sprintf("Verifying the pipeline from database %s", db))
+			}
+			if err := cfg.verifyPipeline(ctx, spClients[db], shards, dialect); err != nil {
+				return fmt.Errorf("could not verify the pipeline: %v", err)
+			}
+		}
+		logger.FromContext(ctx).Info("Pipeline verified successfully")
+	}
+	return nil
+}
+
+// getRuntimeEnvironment returns the runtime environment of a dataflow job of
+// the pipeline with the given number of workers.
+func (cfg *config) getRuntimeEnvironment(numWorkers, maxWorkers int) *dataflowpb.FlexTemplateRuntimeEnvironment {
+	// If custom network is not selected, use public IP. Typical for internal testing flow.
+	workerIpAddressConfig := dataflowpb.WorkerIPAddressConfiguration_WORKER_IP_PUBLIC
+	subnetwork := cfg.vpcSubnetwork
+	if cfg.vpcNetwork != "" || cfg.vpcSubnetwork != "" {
+		workerIpAddressConfig = dataflowpb.WorkerIPAddressConfiguration_WORKER_IP_PRIVATE
+		// If subnetwork is not provided, assume network has auto subnet configuration.
+		if cfg.vpcSubnetwork != "" {
+			subnetwork = fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/regions/%s/subnetworks/%s", cfg.vpcHostProjectId, cfg.dataflowRegion, cfg.vpcSubnetwork)
+		}
+	}
+
+	stagingDir, tempDir := cfg.getDataflowLocations()
+
+	var additionalExpr []string
+
+	if cfg.runnerV2 {
+		additionalExpr = append(additionalExpr, "use_runner_v2")
+	}
+	if cfg.networkTags != "" {
+		additionalExpr = append(additionalExpr, "use_network_tags="+cfg.networkTags, "use_network_tags_for_flex_templates="+cfg.networkTags)
+	}
+	additionalExpr = append(additionalExpr, cfg.getAdditionalExperiments()...)
+	return &dataflowpb.FlexTemplateRuntimeEnvironment{
+		NumWorkers:            int32(numWorkers),
+		MaxWorkers:            int32(maxWorkers),
+		AdditionalExperiments: additionalExpr,
+		MachineType:           cfg.machineType,
+		Network:               cfg.vpcNetwork,
+		Subnetwork:            subnetwork,
+		IpConfiguration:       workerIpAddressConfig,
+		ServiceAccountEmail:   cfg.serviceAccountEmail,
+		EnableStreamingEngine: cfg.streamingEngine,
+		StagingLocation:       stagingDir,
+		TempLocation:          tempDir,
+		DiskSizeGb:            int32(cfg.diskSizeGb),
+		LauncherMachineType:   cfg.launcherMachineType,
+		FlexrsGoal:            dataflowpb.FlexResourceSchedulingGoal(dataflowpb.FlexResourceSchedulingGoal_value["FLEXRS_"+cfg.flexrsGoal]),
+		SdkContainerImage:     cfg.sdkContainerImage,
+		KmsKeyName:            cfg.kmsKeyName,
+		AdditionalUserLabels:  cfg.getTagLabels(),
+	}
+}
+
+// validateRuntimeEnvironmentFlags checks the flags customizing the VMs of the
+// dataflow jobs, which are otherwise only rejected when launching the jobs.
+func (cfg *config) validateRuntimeEnvironmentFlags() error {
+	if cfg.diskSizeGb < 0 || cfg.diskSizeGb > MAX_DISK_SIZE_GB {
+		return fmt.Errorf("please specify a diskSizeGb between 0 and %d", MAX_DISK_SIZE_GB)
+	}
+	switch cfg.flexrsGoal {
+	case "", FLEXRS_SPEED_OPTIMIZED, FLEXRS_COST_OPTIMIZED:
+	default:
+		return fmt.Errorf("please specify a valid flexrsGoal. Supported values are %s and %s", FLEXRS_SPEED_OPTIMIZED, FLEXRS_COST_OPTIMIZED)
+	}
+	if strings.ContainsAny(cfg.sdkContainerImage, " \t\n") {
+		return fmt.Errorf("please specify a valid sdkContainerImage without whitespaces")
+	}
+	if cfg.kmsKeyName != "" && !kmsKeyNameRegex.MatchString(cfg.kmsKeyName) {
+		return fmt.Errorf("please specify a valid kmsKeyName of the form projects/<project>/locations/<location>/keyRings/<key ring>/cryptoKeys/<key>")
+	}
+	for _, exp := range cfg.getAdditionalExperiments() {
+		if strings.HasPrefix(exp, "use_network_tags") {
+			return fmt.Errorf("please specify the network tags with networkTags rather than additionalExperiments")
+		}
+		if exp == "use_runner_v2" || exp == "disable_runner_v2" {
+			return fmt.Errorf("please enable or disable Runner v2 with runnerV2 rather than additionalExperiments")
+		}
+	}
+	if !cfg.runnerV2 {
+		logger.FromContext(context.Background()).Warn("runnerV2 is disabled. The templates are tested on Dataflow Runner v2, the jobs may fail or be slower without it")
+	}
+	return nil
+}
+
+// getAdditionalExperiments returns the experiments of additionalExperiments.
+func (cfg *config) getAdditionalExperiments() []string {
+	var experiments []string
+	for _, exp := range strings.Split(cfg.additionalExperiments, ",") {
+		if exp = strings.TrimSpace(exp); exp != "" {
+			experiments = append(experiments, exp)
+		}
+	}
+	return experiments
+}
+
+// createMetadataDatabase creates the metadata database with the dialect of
+// the replicated databases, unless it exists, and returns true if it was
+// created. An existing one is used with its own dialect.
+func (cfg *config) createMetadataDatabase(ctx context.Context, adminClient *database.DatabaseAdminClient, dialect string) (created bool, err error) {
+	ctx, span := tracing.StartSpan(ctx, "CreateMetadataDatabase", attribute.String("database", cfg.getMetadataDbUri()))
+	defer func() { tracing.EndSpan(span, err) }()
+	createDbReq := cfg.getMetadataDbCreateRequest(dialect)
+	createDbOp, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "CreateDatabase", Resource: cfg.getMetadataDbUri()}, func(ctx context.Context) (*database.CreateDatabaseOperation, error) {
+		return adminClient.CreateDatabase(ctx, createDbReq)
+	})
+	if err != nil {
+		if !gcp.IsAlreadyExists(err) {
+			return false, fmt.Errorf("cannot submit create database request for metadata db: %v", err)
+		} else {
+			logger.FromContext(ctx).Info(fmt.Sprintf("metadata db %s already exists...skipping creation", cfg.getMetadataDbUri()))
+		}
+	} else {
+		if _, err := createDbOp.Wait(ctx); err != nil {
+			if !gcp.IsAlreadyExists(err) {
+				return false, fmt.Errorf("create database request failed for metadata db: %v", err)
+			} else {
+				logger.FromContext(ctx).Info(fmt.Sprintf("metadata db %s already exists...skipping creation", cfg.getMetadataDbUri()))
+			}
+		} else {
+			logger.FromContext(ctx).Info(fmt.Sprintf("Created metadata db %s", cfg.getMetadataDbUri()))
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getOrderingJobRequest returns the request launching the ordering job which
+// reads the change stream of db, using the metadata tables with the given
+// suffix, in orderingRunMode.
+func (cfg *config) getOrderingJobRequest(db, suffix string, multiDb bool) (*dataflowpb.LaunchFlexTemplateRequest, error) {
+	pubSubDataTopicUri := fmt.Sprintf("projects/%s/topics/%s", cfg.projectId, cfg.pubSubDataTopicId)
+	params := map[string]string{
+		"changeStreamName":    cfg.changeStreamName,
+		"instanceId":          cfg.instanceId,
+		"databaseId":          db,
+		"spannerProjectId":    cfg.projectId,
+		"metadataInstance":    cfg.metadataInstance,
+		"metadataDatabase":    cfg.metadataDatabase,
+		"metadataTableSuffix": suffix,
+		"startTimestamp":      cfg.startTimestamp,
+		"incrementInterval":   "10",
+		"sinkType":            "pubsub",
+		"pubSubDataTopicId":   pubSubDataTopicUri,
+		"pubSubErrorTopicId":  pubSubDataTopicUri,
+		"pubSubEndpoint":      cfg.pubSubEndpoint,
+		"sessionFilePath":     cfg.sessionFilePath,
+		"filtrationMode":      cfg.filtrationMode,
+	}
+	if cfg.endTimestamp != "" {
+		params["endTimestamp"] = cfg.endTimestamp
+	}
+	// Only passed when the metadata is stored in another project, as the
+	// default template does not have the parameter.
+	if cfg.metadataProject != cfg.projectId {
+		params["metadataProjectId"] = cfg.metadataProject
+	}
+	// Only passed for the built-in sharding functions other than identity, as
+	// the default template does not have the parameter.
+	if cfg.shardingFunction != SHARDING_FUNCTION_IDENTITY {
+		params["shardingConfigFilePath"] = cfg.getShardingConfigFilePath()
+	}
+	// Only passed when resuming, as the default template does not have the
+	// parameter.
+	if cfg.orderingRunMode != RUN_MODE_REGULAR {
+		params["runMode"] = cfg.orderingRunMode
+	}
+	return dataflowlaunch.NewLaunchRequestBuilder(cfg.projectId, cfg.dataflowRegion, getOrderingJobName(cfg.jobNamePrefix, db, multiDb)).
+		WithTemplate(cfg.orderingTemplate).
+		WithParams(params).
+		WithEnvironment(cfg.getRuntimeEnvironment(cfg.orderingWorkers, cfg.orderingMaxWorkers)).
+		Build()
+}
+
+// getWriterJobRequest returns the request launching the writer job applying
+// the changes of the shards listed in shardsFilePath. extraParams are added to
+// the template parameters.
+func (cfg *config) getWriterJobRequest(jobName, shardsFilePath string, extraParams map[string]string) (*dataflowpb.LaunchFlexTemplateRequest, error) {
+	params := map[string]string{
+		"sourceShardsFilePath": shardsFilePath,
+		"sessionFilePath":      cfg.sessionFilePath,
+		"bufferType":           "pubsub",
+		"pubSubProjectId":      cfg.projectId,
+	}
+	for k, v := range cfg.getSourceWriterParams() {
+		params[k] = v
+	}
+	// Only passed when set, as the default template does not have the
+	// parameters.
+	if cfg.writerTransformationJarPath != "" {
+		params["transformationJarPath"] = cfg.writerTransformationJarPath
+		params["transformationClassName"] = cfg.writerTransformationClassName
+	}
+	return dataflowlaunch.NewLaunchRequestBuilder(cfg.projectId, cfg.dataflowRegion, jobName).
+		WithTemplate(cfg.writerTemplate).
+		WithParams(params).
+		WithParams(extraParams).
+		WithEnvironment(cfg.getRuntimeEnvironment(cfg.writerWorkers, cfg.writerMaxWorkers)).
+		Build()
+}
+
+// launchJob validates the parameters of req against the template, or uses the
+// cached template spec if templateCacheDir is set, and launches the job. kind
+// names the job in the output.
+func (cfg *config) launchJob(ctx context.Context, c dataflowjobs.DataflowAccessor, req *dataflowpb.LaunchFlexTemplateRequest, kind string) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "LaunchDataflowJob", attribute.String("kind", kind), attribute.String("jobName", req.LaunchParameter.JobName))
+	defer func() { tracing.EndSpan(span, err) }()
+	templatePath := req.LaunchParameter.GetContainerSpecGcsPath()
+	logger.FromContext(ctx).Info(fmt.Sprintf("GCLOUD CMD FOR %s JOB:\n%s", strings.ToUpper(kind), getGcloudCommand(req, templatePath)))
+	if cfg.templateCacheDir != "" {
+		if err := cfg.useTemplateCache(ctx, c, req, templatePath); err != nil {
+			return fmt.Errorf("could not use cached template spec: %v", err)
+		}
+	} else {
+		spec, err := readFlexTemplateSpec(ctx, templatePath)
+		if err != nil {
+			return fmt.Errorf("invalid %s template parameters: %v", kind, err)
+		}
+		if err := utils.ValidateFlexTemplateParameters(templatePath, spec, req.LaunchParameter.Parameters); err != nil {
+			return fmt.Errorf("invalid %s template parameters: %v", kind, err)
+		}
+	}
+	if _, err = c.LaunchFlexTemplate(ctx, req); err != nil {
+		return fmt.Errorf("unable to launch %s job: %v \n REQUEST BODY: %+v", kind, err, req)
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Launched %s job: %s", kind, req.LaunchParameter.JobName))
+	return nil
+}
+
+// getLogicalShardIds returns the logicalShardId of every shard read from the
+// source shards file.
+func getLogicalShardIds(shards []interface{}) ([]string, error) {
+	arr := []string{}
+	for i := 0; i < len(shards); i++ {
+		shard, ok := shards[i].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("shard at index %d is not a json object", i)
+		}
+		logicalShardId, ok := shard["logicalShardId"].(string)
+		if !ok {
+			return nil, fmt.Errorf("shard at index %d does not have a logicalShardId", i)
+		}
+		arr = append(arr, logicalShardId)
+	}
+	return arr, nil
+}
+
+// createShardSubscription creates the subscription to pubSubDataTopicId
+// receiving, in order, the changes of the shard.
+func (cfg *config) createShardSubscription(ctx context.Context, client *pubsub.Client, shardId string) error {
+	call := gcp.Call{Service: gcp.PUBSUB, Method: "CreateSubscription", Resource: fmt.Sprintf("projects/%s/subscriptions/%s", cfg.projectId, shardId)}
+	_, err := gcp.DoWithResult(ctx, call, func(ctx context.Context) (*pubsub.Subscription, error) {
+		return client.CreateSubscription(ctx, shardId, pubsub.SubscriptionConfig{
+			Topic:                 client.Topic(cfg.pubSubDataTopicId),
+			AckDeadline:           600 * time.Second,
+			EnableMessageOrdering: true,
+			Filter:                fmt.Sprintf("attributes.shardId=\"%s\"", shardId),
+		})
+	})
+	return err
+}
+
+func (cfg *config) verifySubscription(ctx context.Context, client *pubsub.Client, subName string) error {
+	subscription := client.Subscription(subName)
+	subCfg, err := subscription.Config(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot fetch config for subscription %s", subName)
+	}
+	topicUri := subCfg.Topic.String()
+	if topicUri != fmt.Sprintf("projects/%s/topics/%s", cfg.projectId, cfg.pubSubDataTopicId) {
+		return fmt.Errorf("pubSubDataTopicId provided was %s, but existing subscription %s receives from %s. Please change pubSubDataTopicId or delete the subscription", cfg.pubSubDataTopicId, subName, topicUri)
+	}
+	if !subCfg.EnableMessageOrdering {
+		return fmt.Errorf("existing subscription %s has EnableMessageOrdering set to false. Pleasse update or delete the subscription", subName)
+	}
+	if !(strings.Contains(subCfg.Filter, fmt.Sprintf("attributes.shardId=\"%s\"", subName))) {
+		return fmt.Errorf("existing subscription %s does not have the correct filter. Please delete the subscription", subName)
+	}
+	return nil
+}
+
+func (cfg *config) validateOrCreateChangeStream(ctx context.Context, adminClient *database.DatabaseAdminClient, instances *spanneradmin.InstanceInfoCache, store metadataStore, spClient *spanner.Client, db, dialect string) error {
+	dbUri := cfg.getDbUri(db)
+	q := `SELECT * FROM information_schema.change_streams`
+	stmt := spanner.Statement{
+		SQL: q,
+	}
+	iter := spClient.Single().Query(ctx, stmt)
+	defer iter.Stop()
+	var cs_catalog, cs_schema, cs_name string
+	var coversAll bool
+	csExists := false
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't read row from change_streams table: %w", err)
+		}
+		err = row.Columns(&cs_catalog, &cs_schema, &cs_name, &coversAll)
+		if err != nil {
+			return fmt.Errorf("can't scan row from change_streams table: %v", err)
+		}
+		if cs_name == cfg.changeStreamName {
+			csExists = true
+			logger.FromContext(ctx).Info(fmt.Sprintf("Found changestream %s", cfg.changeStreamName))
+			break
+		}
+	}
+	if !csExists {
+		logger.FromContext(ctx).Info(fmt.Sprintf("changestream %s not found", cfg.changeStreamName))
+		// A change stream can't be read from before its creation.
+		if start, err := time.Parse(time.RFC3339Nano, cfg.startTimestamp); err == nil && start.Before(time.Now()) {
+			return fmt.Errorf("startTimestamp %s is before the creation of changestream %s, which is created now. Please remove startTimestamp or specify a timestamp in the future", cfg.startTimestamp, cfg.changeStreamName)
+		}
+		watch, err := cfg.getChangeStreamWatchForFilter(ctx, dialect)
+		if err != nil {
+			return err
+		}
+		if err := cfg.checkChangeStreamCapacity(ctx, instances); err != nil {
+			return err
+		}
+		err = cfg.createChangeStream(ctx, adminClient, dbUri, dialect, watch)
+		if err != nil {
+			return fmt.Errorf("could not create changestream: %v", err)
+		}
+		return store.RecordCreatedResource(ctx, createdResource{kind: CREATED_CHANGE_STREAM, name: cfg.changeStreamName, database: db})
+	}
+	options, err := cfg.getChangeStreamOptions(ctx, spClient, dialect)
+	if err != nil {
+		return err
+	}
+	fixes, err := cfg.getChangeStreamOptionFixes(options)
+	if err != nil {
+		return err
+	}
+	if len(fixes) > 0 {
+		if !cfg.autoFixChangeStream {
+			return fmt.Errorf("changestream %s is configured incorrectly: %s. Please update the changestream options, create a new one or rerun with -autoFixChangeStream", cfg.changeStreamName, strings.Join(fixes, ", "))
+		}
+		err = cfg.fixChangeStreamOptions(ctx, adminClient, dbUri, dialect, options, fixes)
+		if err != nil {
+			return err
+		}
+	}
+	if !coversAll {
+		logger.FromContext(ctx).Warn(fmt.Sprintf("watching definition for the existing changestream %s is not 'ALL'."+
+			" This means only specific tables and columns are tracked."+
+			" Only the tables and columns watched by this changestream will get reverse replicated.", cfg.changeStreamName))
+	}
+	if cfg.filterConfigFile != "" {
+		logger.FromContext(ctx).Warn(fmt.Sprintf("filterConfigFile is ignored as changestream %s already exists."+
+			" Only the tables and columns watched by this changestream will get reverse replicated.", cfg.changeStreamName))
+	}
+	logger.FromContext(ctx).Info("Skipping changestream creation ...")
+	return nil
+}
+
+func (cfg *config) createChangeStream(ctx context.Context, adminClient *database.DatabaseAdminClient, dbUri, dialect, watch string) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "CreateChangeStream", attribute.String("database", dbUri), attribute.String("changeStream", cfg.changeStreamName))
+	defer func() { tracing.EndSpan(span, err) }()
+	logger.FromContext(ctx).Info("Creating changestream")
+	op, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "UpdateDatabaseDdl", Resource: dbUri}, func(ctx context.Context) (*database.UpdateDatabaseDdlOperation, error) {
+		return adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+			Database:   dbUri,
+			Statements: []string{cfg.getCreateChangeStreamStmt(dialect, watch)},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("Cannot submit request create change stream request: %v\n", err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("Could not update database ddl: %v\n", err)
+	} else {
+		logger.FromContext(ctx).Info(fmt.Sprintf("Successfully created changestream %s", cfg.changeStreamName))
+	}
+	return nil
+}
+
+func getGcloudCommand(req *dataflowpb.LaunchFlexTemplateRequest, templatePath string) string {
+	lp := req.LaunchParameter
+	params := ""
+	for k, v := range lp.Parameters {
+		params = params + k + "=" + v + ","
+	}
+	params = strings.TrimSuffix(params, ",")
+	cmd := fmt.Sprintf("gcloud dataflow flex-template run %s --project=%s --region=%s --template-file-gcs-location=%s --parameters %s --num-workers=%d --worker-machine-type=%s",
+		lp.JobName, req.ProjectId, req.Location, templatePath, params, lp.Environment.NumWorkers, lp.Environment.MachineType)
+	if lp.Environment.AdditionalExperiments != nil {
+		exps := lp.Environment.AdditionalExperiments
+		experiments := strings.Join(exps[:], ",")
+		cmd += " --additional-experiments=" + experiments
+	}
+	if lp.Environment.StagingLocation != "" {
+		cmd += " --staging-location=" + lp.Environment.StagingLocation + " --temp-location=" + lp.Environment.TempLocation
+	}
+	if lp.Environment.EnableStreamingEngine {
+		cmd += " --enable-streaming-engine"
+	}
+	if lp.Environment.DiskSizeGb != 0 {
+		cmd += fmt.Sprintf(" --disk-size-gb=%d", lp.Environment.DiskSizeGb)
+	}
+	if lp.Environment.LauncherMachineType != "" {
+		cmd += " --launcher-machine-type=" + lp.Environment.LauncherMachineType
+	}
+	if lp.Environment.FlexrsGoal != dataflowpb.FlexResourceSchedulingGoal_FLEXRS_UNSPECIFIED {
+		cmd += " --flexrs-goal=" + strings.TrimPrefix(lp.Environment.FlexrsGoal.String(), "FLEXRS_")
+	}
+	if lp.Environment.KmsKeyName != "" {
+		cmd += " --dataflow-kms-key=" + lp.Environment.KmsKeyName
+	}
+	if len(lp.Environment.AdditionalUserLabels) > 0 {
+		var labels []string
+		for k, v := range lp.Environment.AdditionalUserLabels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		cmd += " --additional-user-labels=" + strings.Join(labels, ",")
+	}
+	return cmd
+}
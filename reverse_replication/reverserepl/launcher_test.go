@@ -0,0 +1,88 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLaunchWriterJobs(t *testing.T) {
+	dataflow := testutil.NewFakeDataflowAccessor()
+	gcs := testutil.NewFakeStorageClient()
+	ctx := withFakeClients(context.Background(), dataflow, gcs)
+	gcs.PutObject("my-bucket", "templates/writer.json", []byte(`{"image": "gcr.io/my-project/writer"}`), time.Now())
+
+	j := getTestJobData()
+	j.Flags = map[string]string{"writerTemplate": "gs://my-bucket/templates/writer.json", "writerFanOut": "2"}
+	cfg, err := newConfig(j)
+	assert.Nil(t, err)
+	shards := []interface{}{
+		map[string]interface{}{"logicalShardId": "shard1"},
+		map[string]interface{}{"logicalShardId": "shard2"},
+		map[string]interface{}{"logicalShardId": "shard3"},
+	}
+	paths, err := cfg.launchWriterJobs(ctx, dataflow, partitionShards(shards, cfg.writerFanOut))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"gs://my-bucket/shards-orders-writer-0.json", "gs://my-bucket/shards-orders-writer-1.json"}, paths)
+
+	// Every writer job reads its own shards file.
+	bArr, ok := gcs.GetObject("my-bucket", "shards-orders-writer-1.json")
+	assert.True(t, ok)
+	var group []interface{}
+	assert.Nil(t, json.Unmarshal(bArr, &group))
+	assert.Equal(t, []interface{}{map[string]interface{}{"logicalShardId": "shard2"}}, group)
+	if assert.Equal(t, 2, len(dataflow.Launches)) {
+		for i, req := range dataflow.Launches {
+			assert.Equal(t, cfg.getWriterJobNames(2)[i], req.LaunchParameter.JobName)
+			assert.Equal(t, "gs://my-bucket/templates/writer.json", req.LaunchParameter.GetContainerSpecGcsPath())
+			assert.Equal(t, paths[i], req.LaunchParameter.Parameters["sourceShardsFilePath"])
+			assert.Equal(t, "my-project", req.ProjectId)
+			assert.Equal(t, "us-central1", req.Location)
+		}
+	}
+}
+
+func TestLaunchJobChecksTemplateParameters(t *testing.T) {
+	dataflow := testutil.NewFakeDataflowAccessor()
+	gcs := testutil.NewFakeStorageClient()
+	ctx := withFakeClients(context.Background(), dataflow, gcs)
+	gcs.PutObject("my-bucket", "templates/writer.json", []byte(`{
+		"image": "gcr.io/my-project/writer",
+		"metadata": {"name": "writer", "parameters": [{"name": "sourceShardsFilePath"}]}
+	}`), time.Now())
+
+	j := getTestJobData()
+	j.Flags = map[string]string{"writerTemplate": "gs://my-bucket/templates/writer.json"}
+	cfg, err := newConfig(j)
+	assert.Nil(t, err)
+	req, err := cfg.getWriterJobRequest("orders-writer", cfg.sourceShardsFilePath, nil)
+	assert.Nil(t, err)
+	err = cfg.launchJob(ctx, dataflow, req, "writer")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "unrecognized parameters")
+	}
+	assert.Equal(t, 0, len(dataflow.Launches))
+
+	// The template spec must exist.
+	gcs.DeleteObject(ctx, "my-bucket", "templates/writer.json")
+	assert.NotNil(t, cfg.launchJob(ctx, dataflow, req, "writer"))
+	assert.Equal(t, 0, len(dataflow.Launches))
+}
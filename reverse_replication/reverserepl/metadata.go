@@ -0,0 +1,142 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+)
+
+const (
+	// Table in the metadata database recording which pipeline owns each
+	// metadata table suffix.
+	SUFFIX_REGISTRY_TABLE = "ReverseReplicationMetadataSuffixes"
+	// Upper bound on the candidates tried when uniquifying a suffix.
+	MAX_SUFFIX_ATTEMPTS = 100
+)
+
+var metadataTableSuffixRegex = regexp.MustCompile(`^[A-Za-z0-9_]*$`)
+
+// suffixOwner is the pipeline that registered a metadata table suffix.
+type suffixOwner struct {
+	jobNamePrefix string
+	instanceId    string
+	dbName        string
+	tenant        string
+}
+
+// getMetadataDbUri returns the uri of the database holding the change stream
+// metadata tables.
+func (cfg *config) getMetadataDbUri() string {
+	return fmt.Sprintf("projects/%s/instances/%s/databases/%s", cfg.metadataProject, cfg.metadataInstance, cfg.metadataDatabase)
+}
+
+// getSuffixRegistryDdl returns the statement creating the suffix registry
+// table in a metadata database of the given dialect.
+func getSuffixRegistryDdl(dialect string) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+	"MetadataTableSuffix" VARCHAR NOT NULL,
+	"JobNamePrefix" VARCHAR NOT NULL,
+	"InstanceId" VARCHAR NOT NULL,
+	"DatabaseId" VARCHAR NOT NULL,
+	"RegisteredAt" SPANNER.COMMIT_TIMESTAMP NOT NULL,
+	PRIMARY KEY ("MetadataTableSuffix")
+)`, SUFFIX_REGISTRY_TABLE)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	MetadataTableSuffix STRING(MAX) NOT NULL,
+	JobNamePrefix STRING(MAX) NOT NULL,
+	InstanceId STRING(MAX) NOT NULL,
+	DatabaseId STRING(MAX) NOT NULL,
+	RegisteredAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+) PRIMARY KEY (MetadataTableSuffix)`, SUFFIX_REGISTRY_TABLE)
+}
+
+// isOwnerActive returns true if the ordering job of the pipeline which
+// registered a suffix is still running. The job is looked up under the names
+// used by both single and multiple database pipelines.
+func (cfg *config) isOwnerActive(ctx context.Context, owner suffixOwner) (bool, error) {
+	orderingJobNames := []string{
+		getOrderingJobName(owner.jobNamePrefix, owner.dbName, false),
+		getOrderingJobName(owner.jobNamePrefix, owner.dbName, true),
+	}
+	states, err := cfg.getPipelineJobStates(ctx, orderingJobNames)
+	if err != nil {
+		return false, err
+	}
+	for _, name := range orderingJobNames {
+		for _, state := range states[name] {
+			if !isTerminalJobState(state) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// getSuffixCandidate returns the i-th candidate tried for suffix when
+// uniquifying it, starting with suffix itself.
+func getSuffixCandidate(suffix string, i int) string {
+	if i == 0 {
+		return suffix
+	}
+	if suffix == "" {
+		return fmt.Sprintf("%d", i+1)
+	}
+	return fmt.Sprintf("%s_%d", suffix, i+1)
+}
+
+// reserveMetadataTableSuffix makes sure no other active pipeline, or ordering
+// job of this pipeline, writing to the metadata database uses requestedSuffix,
+// since two ordering jobs sharing the metadata tables corrupt each other's
+// partition state. A suffix registered by this pipeline for database db, or by
+// a pipeline whose ordering job is no longer running, is taken over. If the
+// suffix is in use, an error is returned unless autoUniquifySuffix is set, in
+// which case the first free suffix of the form <suffix>_<n> is used instead.
+// The suffix used is registered in the metadata store and returned.
+func (cfg *config) reserveMetadataTableSuffix(ctx context.Context, store metadataStore, db, requestedSuffix string) (string, error) {
+	owners, err := store.ReadSuffixOwners(ctx)
+	if err != nil {
+		return "", err
+	}
+	suffix, found := "", false
+	for i := 0; i < MAX_SUFFIX_ATTEMPTS; i++ {
+		candidate := getSuffixCandidate(requestedSuffix, i)
+		owner, ok := owners[candidate]
+		if !ok || (owner.jobNamePrefix == cfg.jobNamePrefix && owner.dbName == db) {
+			suffix, found = candidate, true
+			break
+		}
+		active, err := cfg.isOwnerActive(ctx, owner)
+		if err != nil {
+			return "", err
+		}
+		if !active {
+			logger.FromContext(ctx).Info(fmt.Sprintf("metadata table suffix '%s' was registered by a pipeline whose ordering job is no longer running. Taking it over", candidate))
+			suffix, found = candidate, true
+			break
+		}
+		// The pipelines of other tenants are not disclosed.
+		ownerDesc := fmt.Sprintf("the active pipeline %s replicating projects/%s/instances/%s/databases/%s", owner.jobNamePrefix, cfg.projectId, owner.instanceId, owner.dbName)
+		if !cfg.isSameTenant(owner.tenant) {
+			ownerDesc = "an active pipeline of another tenant"
+		}
+		if !cfg.autoUniquifySuffix {
+			return "", fmt.Errorf("metadata table suffix '%s' is already used by %s. Please specify a different metadataTableSuffix, metadataDatabase or rerun with -autoUniquifySuffix", candidate, ownerDesc)
+		}
+		logger.FromContext(ctx).Info(fmt.Sprintf("metadata table suffix '%s' is already used by %s, trying another suffix", candidate, ownerDesc))
+	}
+	if !found {
+		return "", fmt.Errorf("could not find a free metadata table suffix after %d attempts", MAX_SUFFIX_ATTEMPTS)
+	}
+	if err := store.RegisterSuffix(ctx, suffix, suffixOwner{jobNamePrefix: cfg.jobNamePrefix, instanceId: cfg.instanceId, dbName: db, tenant: cfg.getTenant()}); err != nil {
+		return "", err
+	}
+	if suffix != requestedSuffix {
+		logger.FromContext(ctx).Info(fmt.Sprintf("Using metadata table suffix '%s' instead of '%s'", suffix, requestedSuffix))
+	}
+	return suffix, nil
+}
@@ -0,0 +1,118 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+)
+
+const (
+	// Compute capacity of the metadata instance created with
+	// -createMetadataInstance, the smallest Spanner allows.
+	METADATA_INSTANCE_PROCESSING_UNITS = 100
+	// Label of the metadata instance created with -createMetadataInstance,
+	// holding the jobNamePrefix of the pipeline it was created for, so that
+	// -cleanup only deletes the instances the launcher created.
+	METADATA_INSTANCE_LABEL = "reverse-replication-job-name-prefix"
+)
+
+// getMetadataInstanceId returns the id of the metadata instance created with
+// -createMetadataInstance, <jobNamePrefix>-metadata truncated to the 64
+// characters Spanner allows.
+func (cfg *config) getMetadataInstanceId() string {
+	prefix := cfg.jobNamePrefix
+	if len(prefix) > 55 {
+		prefix = strings.TrimRight(prefix[:55], "-")
+	}
+	return prefix + "-metadata"
+}
+
+// getMetadataInstanceLabel returns the value of METADATA_INSTANCE_LABEL on
+// the metadata instance of the pipeline.
+func (cfg *config) getMetadataInstanceLabel() string {
+	if len(cfg.jobNamePrefix) > 63 {
+		return cfg.jobNamePrefix[:63]
+	}
+	return cfg.jobNamePrefix
+}
+
+// provisionMetadataInstance creates the dedicated metadata instance with
+// METADATA_INSTANCE_PROCESSING_UNITS, using the instance configuration of the
+// replicated instance, looked up in instances. An existing instance is used as
+// is.
+func (cfg *config) provisionMetadataInstance(ctx context.Context, instances *spanneradmin.InstanceInfoCache) error {
+	client, err := getClients(ctx).NewInstanceAdminClient(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create instance admin client: %v", err)
+	}
+	defer client.Close()
+	info, err := instances.GetInstanceInfo(ctx, fmt.Sprintf("projects/%s/instances/%s", cfg.projectId, cfg.instanceId))
+	if err != nil {
+		return err
+	}
+	// Instance configurations have the same ids in every project.
+	config := fmt.Sprintf("projects/%s/instanceConfigs/%s", cfg.metadataProject, path.Base(info.Config))
+	name := fmt.Sprintf("projects/%s/instances/%s", cfg.metadataProject, cfg.metadataInstance)
+	op, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "CreateInstance", Resource: name}, func(ctx context.Context) (*instance.CreateInstanceOperation, error) {
+		return client.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+			Parent:     fmt.Sprintf("projects/%s", cfg.metadataProject),
+			InstanceId: cfg.metadataInstance,
+			Instance: &instancepb.Instance{
+				Config:          config,
+				DisplayName:     "Reverse replication metadata",
+				ProcessingUnits: METADATA_INSTANCE_PROCESSING_UNITS,
+				Labels:          map[string]string{METADATA_INSTANCE_LABEL: cfg.getMetadataInstanceLabel()},
+			},
+		})
+	})
+	if err == nil {
+		_, err = op.Wait(ctx)
+	}
+	if err != nil {
+		if gcp.IsAlreadyExists(err) {
+			logger.FromContext(ctx).Info(fmt.Sprintf("metadata instance %s already exists...skipping creation", name))
+			return nil
+		}
+		return fmt.Errorf("could not create metadata instance %s: %v", name, err)
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Created metadata instance %s with %d processing units", name, METADATA_INSTANCE_PROCESSING_UNITS))
+	return nil
+}
+
+// findOrphanMetadataInstance returns the metadata instance created for this
+// pipeline, or nil if it does not exist or was not created by the launcher.
+func (cfg *config) findOrphanMetadataInstance(ctx context.Context) (*orphanResource, error) {
+	client, err := getClients(ctx).NewInstanceAdminClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create instance admin client: %v", err)
+	}
+	defer client.Close()
+	name := fmt.Sprintf("projects/%s/instances/%s", cfg.metadataProject, cfg.metadataInstance)
+	inst, err := client.GetInstance(ctx, &instancepb.GetInstanceRequest{Name: name})
+	if err != nil {
+		if gcp.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not check metadata instance %s: %v", name, err)
+	}
+	if inst.Labels[METADATA_INSTANCE_LABEL] != cfg.getMetadataInstanceLabel() {
+		return nil, nil
+	}
+	return &orphanResource{kind: "metadata instance", name: name, delete: func(ctx context.Context) error {
+		client, err := getClients(ctx).NewInstanceAdminClient(ctx)
+		if err != nil {
+			return fmt.Errorf("could not create instance admin client: %v", err)
+		}
+		defer client.Close()
+		return spanneradmin.Do(ctx, gcp.Call{Method: "DeleteInstance", Resource: name}, func(ctx context.Context) error {
+			return client.DeleteInstance(ctx, &instancepb.DeleteInstanceRequest{Name: name})
+		})
+	}}, nil
+}
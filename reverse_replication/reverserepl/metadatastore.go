@@ -0,0 +1,937 @@
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	"google.golang.org/api/iterator"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/grpc/codes"
+)
+
+// metadataStore reads and writes the tables the launcher keeps in the
+// metadata database: the suffix registry, the jobs table, the creations, the
+// job definitions, the job restarts, the adopted and created resources, the
+// validation runs and the credential rotations. Records are written for the pipeline of
+// jobNamePrefix, and the jobs and suffixes are recorded with the tenant of the
+// pipeline. The reads don't change the schema of the metadata database: a table
+// which was not created yet reads as empty.
+type metadataStore interface {
+	// ReadSuffixOwners returns the owner of every registered suffix.
+	ReadSuffixOwners(ctx context.Context) (map[string]suffixOwner, error)
+	// RegisterSuffix records owner as the owner of suffix.
+	RegisterSuffix(ctx context.Context, suffix string, owner suffixOwner) error
+	// RecordWorkerSizing records the worker sizing of the pipeline, along with
+	// the metadata table suffix of each of its ordering jobs.
+	RecordWorkerSizing(ctx context.Context, suffixes []string, s workerSizing) error
+	// ReadJobTenant returns the tenant the pipeline was launched by, or empty
+	// if it was not launched or has no tenant.
+	ReadJobTenant(ctx context.Context) (string, error)
+	RecordValidationRun(ctx context.Context, report reconciliationReport) error
+	// RecordCredentialRotation records the ids of the shards whose credentials
+	// were rotated to the ones of newSourceShardsFilePath.
+	RecordCredentialRotation(ctx context.Context, rotated []string) error
+	// RecordCreationStatus records the status of the creation of the
+	// pipeline by holder, along with the reason of a failure.
+	RecordCreationStatus(ctx context.Context, holder, status, reason string) error
+	// RecordJobDefinition records definition, the json encoded job data the
+	// pipeline is created with.
+	RecordJobDefinition(ctx context.Context, definition string) error
+	// ReadJobDefinition returns the recorded definition of the pipeline and
+	// its tenant. found is false if no definition was recorded.
+	ReadJobDefinition(ctx context.Context) (definition, owner string, found bool, err error)
+	// RecordJobRestart records the relaunch of the Dataflow job jobName of
+	// the pipeline, whose failed job had the id failedJobId.
+	RecordJobRestart(ctx context.Context, jobName, failedJobId string) error
+	// RecordSnapshotWindow records the timestamp of the snapshot the source
+	// shards were backfilled from and the one the change streams are read
+	// from, along with the metadata table suffix of each ordering job.
+	RecordSnapshotWindow(ctx context.Context, suffixes []string, snapshot, start time.Time) error
+	// ReadSnapshotWindow returns the timestamps recorded by
+	// RecordSnapshotWindow. found is false if none were recorded.
+	ReadSnapshotWindow(ctx context.Context) (snapshot, start time.Time, found bool, err error)
+	// RecordAdoptedResource records a resource created by hand as a resource
+	// of the pipeline.
+	RecordAdoptedResource(ctx context.Context, r ManualResource) error
+	// ReadAdoptedResources returns the resources adopted by the pipeline,
+	// visible to its tenant.
+	ReadAdoptedResources(ctx context.Context) ([]ManualResource, error)
+	// RecordCreatedResource records a resource created by the launcher for
+	// the pipeline, or the metadata database.
+	RecordCreatedResource(ctx context.Context, r createdResource) error
+	// ReadCreatedResources returns the resources created by the launcher for
+	// the pipeline, and the metadata database if it was created by the
+	// launcher.
+	ReadCreatedResources(ctx context.Context) ([]createdResource, error)
+	// ListChangeStreamMetadataTables returns the names of the tables the
+	// ordering jobs keep the partitions of the change streams in, recognized
+	// by their PartitionToken column.
+	ListChangeStreamMetadataTables(ctx context.Context) ([]string, error)
+	// DropTables drops the tables, along with their indexes.
+	DropTables(ctx context.Context, tables []string) error
+	// DeletePipelineRecords deletes the rows of the pipeline from the tables
+	// the launcher keeps, see launcherTables.
+	DeletePipelineRecords(ctx context.Context) error
+	Close()
+}
+
+// launcherTables are the tables the launcher keeps in the metadata database.
+// Their rows are recorded for a pipeline, in the JobNamePrefix column.
+var launcherTables = []string{
+	SUFFIX_REGISTRY_TABLE,
+	JOBS_TABLE,
+	CREATIONS_TABLE,
+	JOB_DEFINITIONS_TABLE,
+	RESTARTS_TABLE,
+	ADOPTED_RESOURCES_TABLE,
+	CREATED_RESOURCES_TABLE,
+	VALIDATION_RUNS_TABLE,
+	CREDENTIAL_ROTATIONS_TABLE,
+}
+
+// spannerMetadataStore implements metadataStore on the metadata database.
+// Tables are created, or migrated to their current columns, on first write.
+type spannerMetadataStore struct {
+	cfg         *config
+	adminClient *database.DatabaseAdminClient
+	client      *spanner.Client
+	dialect     string
+	created     map[string]bool
+}
+
+var _ metadataStore = (*spannerMetadataStore)(nil)
+
+// newSpannerMetadataStore returns a store on the metadata database, using
+// adminClient to create its tables. adminClient is not closed with the store.
+func newSpannerMetadataStore(ctx context.Context, cfg *config, adminClient *database.DatabaseAdminClient) (metadataStore, error) {
+	dialect, err := spanneradmin.GetDatabaseDialect(ctx, adminClient, cfg.getMetadataDbUri())
+	if err != nil {
+		return nil, fmt.Errorf("could not get the metadata db dialect: %v", err)
+	}
+	client, err := getClients(ctx).NewSpannerClient(ctx, cfg.getMetadataDbUri())
+	if err != nil {
+		return nil, fmt.Errorf("could not create spanner client for metadata db: %v", err)
+	}
+	return &spannerMetadataStore{cfg: cfg, adminClient: adminClient, client: client, dialect: dialect, created: make(map[string]bool)}, nil
+}
+
+func (st *spannerMetadataStore) Close() {
+	st.client.Close()
+}
+
+// createTable creates a table of the store, described by desc in errors, if
+// it was not created yet by this store.
+func (st *spannerMetadataStore) createTable(ctx context.Context, table, desc string, getDdl func(dialect string) string) error {
+	if st.created[table] {
+		return nil
+	}
+	stmts := []string{getDdl(st.dialect)}
+	if table == JOBS_TABLE || table == SUFFIX_REGISTRY_TABLE {
+		stmts = append(stmts, getTenantColumnDdl(st.dialect, table))
+	}
+	if table == JOBS_TABLE {
+		stmts = append(stmts, getSnapshotColumnsDdl(st.dialect)...)
+	}
+	op, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "UpdateDatabaseDdl", Resource: st.cfg.getMetadataDbUri()}, func(ctx context.Context) (*database.UpdateDatabaseDdlOperation, error) {
+		return st.adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+			Database:   st.cfg.getMetadataDbUri(),
+			Statements: stmts,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("cannot submit create %s request: %v", desc, err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("could not create %s: %v", desc, err)
+	}
+	st.created[table] = true
+	return nil
+}
+
+// getColumns returns the columns of a table of the store, which are empty if
+// the table does not exist. The reads check them rather than creating the
+// table, so that reading a metadata database does not change its schema.
+func (st *spannerMetadataStore) getColumns(ctx context.Context, table string) (map[string]bool, error) {
+	stmt := spanner.Statement{
+		SQL:    fmt.Sprintf(`SELECT column_name FROM information_schema.columns WHERE table_schema = '%s' AND table_name = %s`, st.getSchema(), getQueryParam(st.dialect, 1)),
+		Params: map[string]interface{}{"p1": table},
+	}
+	cols := make(map[string]bool)
+	err := st.client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var name string
+		if err := row.Columns(&name); err != nil {
+			return err
+		}
+		cols[name] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the columns of the %s table: %w", table, err)
+	}
+	return cols, nil
+}
+
+func (st *spannerMetadataStore) ReadSuffixOwners(ctx context.Context) (map[string]suffixOwner, error) {
+	existing, err := st.getColumns(ctx, SUFFIX_REGISTRY_TABLE)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[string]suffixOwner)
+	if len(existing) == 0 {
+		return owners, nil
+	}
+	cols := []string{"MetadataTableSuffix", "JobNamePrefix", "InstanceId", "DatabaseId"}
+	// Registries created before the tenants only get the column on the next
+	// registration.
+	hasTenant := existing[TENANT_COLUMN]
+	if hasTenant {
+		cols = append(cols, TENANT_COLUMN)
+	}
+	for i, col := range cols {
+		cols[i] = quoteIdentifier(st.dialect, col)
+	}
+	stmt := spanner.Statement{
+		SQL: fmt.Sprintf(`SELECT %s FROM %s`, strings.Join(cols, ", "), quoteIdentifier(st.dialect, SUFFIX_REGISTRY_TABLE)),
+	}
+	iter := st.client.Single().Query(ctx, stmt)
+	defer iter.Stop()
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read row from %s table: %w", SUFFIX_REGISTRY_TABLE, err)
+		}
+		var suffix string
+		var owner suffixOwner
+		var tenant spanner.NullString
+		dest := []interface{}{&suffix, &owner.jobNamePrefix, &owner.instanceId, &owner.dbName}
+		if hasTenant {
+			dest = append(dest, &tenant)
+		}
+		if err := row.Columns(dest...); err != nil {
+			return nil, fmt.Errorf("can't scan row from %s table: %v", SUFFIX_REGISTRY_TABLE, err)
+		}
+		owner.tenant = tenant.StringVal
+		owners[suffix] = owner
+	}
+	return owners, nil
+}
+
+func (st *spannerMetadataStore) RegisterSuffix(ctx context.Context, suffix string, owner suffixOwner) error {
+	if err := st.createTable(ctx, SUFFIX_REGISTRY_TABLE, "suffix registry table", getSuffixRegistryDdl); err != nil {
+		return err
+	}
+	_, err := st.client.Apply(ctx, []*spanner.Mutation{
+		spanner.InsertOrUpdate(SUFFIX_REGISTRY_TABLE,
+			[]string{"MetadataTableSuffix", "JobNamePrefix", "InstanceId", "DatabaseId", TENANT_COLUMN, "RegisteredAt"},
+			[]interface{}{suffix, owner.jobNamePrefix, owner.instanceId, owner.dbName, owner.tenant, spanner.CommitTimestamp}),
+	})
+	if err != nil {
+		return fmt.Errorf("could not register metadata table suffix '%s': %v", suffix, err)
+	}
+	return nil
+}
+
+func (st *spannerMetadataStore) RecordWorkerSizing(ctx context.Context, suffixes []string, s workerSizing) error {
+	if err := st.createTable(ctx, JOBS_TABLE, "jobs table", getJobsTableDdl); err != nil {
+		return err
+	}
+	bArr, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("could not serialize worker sizing: %v", err)
+	}
+	_, err = st.client.Apply(ctx, []*spanner.Mutation{
+		spanner.InsertOrUpdate(JOBS_TABLE,
+			[]string{"JobNamePrefix", "MetadataTableSuffix", "WorkerSizing", TENANT_COLUMN, "UpdatedAt"},
+			[]interface{}{st.cfg.jobNamePrefix, strings.Join(suffixes, ","), string(bArr), st.cfg.getTenant(), spanner.CommitTimestamp}),
+	})
+	if err != nil {
+		return fmt.Errorf("could not record worker sizing of pipeline %s: %v", st.cfg.jobNamePrefix, err)
+	}
+	return nil
+}
+
+func (st *spannerMetadataStore) ReadJobTenant(ctx context.Context) (string, error) {
+	existing, err := st.getColumns(ctx, JOBS_TABLE)
+	if err != nil {
+		return "", err
+	}
+	if !existing[TENANT_COLUMN] {
+		return "", nil
+	}
+	row, err := st.client.Single().ReadRow(ctx, JOBS_TABLE, spanner.Key{st.cfg.jobNamePrefix}, []string{TENANT_COLUMN})
+	if spanner.ErrCode(err) == codes.NotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("couldn't read pipeline %s from %s table: %v", st.cfg.jobNamePrefix, JOBS_TABLE, err)
+	}
+	var tenant spanner.NullString
+	if err := row.Columns(&tenant); err != nil {
+		return "", fmt.Errorf("can't scan row from %s table: %v", JOBS_TABLE, err)
+	}
+	return tenant.StringVal, nil
+}
+
+func (st *spannerMetadataStore) RecordSnapshotWindow(ctx context.Context, suffixes []string, snapshot, start time.Time) error {
+	if err := st.createTable(ctx, JOBS_TABLE, "jobs table", getJobsTableDdl); err != nil {
+		return err
+	}
+	cols := []string{"JobNamePrefix", "MetadataTableSuffix", "SnapshotTimestamp", "StreamStartTimestamp", TENANT_COLUMN, "UpdatedAt"}
+	values := []interface{}{st.cfg.jobNamePrefix, strings.Join(suffixes, ","), snapshot, start, st.cfg.getTenant(), spanner.CommitTimestamp}
+	_, err := st.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		_, err := txn.ReadRow(ctx, JOBS_TABLE, spanner.Key{st.cfg.jobNamePrefix}, []string{"JobNamePrefix"})
+		if spanner.ErrCode(err) == codes.NotFound {
+			// The pipeline was not auto sized.
+			return txn.BufferWrite([]*spanner.Mutation{spanner.Insert(JOBS_TABLE, append(cols, "WorkerSizing"), append(values, ""))})
+		}
+		if err != nil {
+			return err
+		}
+		return txn.BufferWrite([]*spanner.Mutation{spanner.Update(JOBS_TABLE, cols, values)})
+	})
+	if err != nil {
+		return fmt.Errorf("could not record the snapshot timestamps of pipeline %s: %v", st.cfg.jobNamePrefix, err)
+	}
+	return nil
+}
+
+func (st *spannerMetadataStore) ReadSnapshotWindow(ctx context.Context) (time.Time, time.Time, bool, error) {
+	existing, err := st.getColumns(ctx, JOBS_TABLE)
+	if err != nil {
+		return time.Time{}, time.Time{}, false, err
+	}
+	if !existing["SnapshotTimestamp"] || !existing["StreamStartTimestamp"] {
+		return time.Time{}, time.Time{}, false, nil
+	}
+	row, err := st.client.Single().ReadRow(ctx, JOBS_TABLE, spanner.Key{st.cfg.jobNamePrefix}, []string{"SnapshotTimestamp", "StreamStartTimestamp"})
+	if spanner.ErrCode(err) == codes.NotFound {
+		return time.Time{}, time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, time.Time{}, false, fmt.Errorf("couldn't read pipeline %s from %s table: %v", st.cfg.jobNamePrefix, JOBS_TABLE, err)
+	}
+	var snapshot, start spanner.NullTime
+	if err := row.Columns(&snapshot, &start); err != nil {
+		return time.Time{}, time.Time{}, false, fmt.Errorf("can't scan row from %s table: %v", JOBS_TABLE, err)
+	}
+	return snapshot.Time, start.Time, snapshot.Valid && start.Valid, nil
+}
+
+func (st *spannerMetadataStore) RecordAdoptedResource(ctx context.Context, r ManualResource) error {
+	if err := st.createTable(ctx, ADOPTED_RESOURCES_TABLE, "adopted resources table", getAdoptedResourcesTableDdl); err != nil {
+		return err
+	}
+	_, err := st.client.Apply(ctx, []*spanner.Mutation{
+		spanner.InsertOrUpdate(ADOPTED_RESOURCES_TABLE,
+			[]string{"JobNamePrefix", "Kind", "Name", "DatabaseId", TENANT_COLUMN, "AdoptedAt"},
+			[]interface{}{st.cfg.jobNamePrefix, r.Kind, r.Name, r.Database, st.cfg.getTenant(), spanner.CommitTimestamp}),
+	})
+	if err != nil {
+		return fmt.Errorf("could not record adopted %s %s of pipeline %s: %v", r.Kind, r.Name, st.cfg.jobNamePrefix, err)
+	}
+	return nil
+}
+
+func (st *spannerMetadataStore) ReadAdoptedResources(ctx context.Context) ([]ManualResource, error) {
+	existing, err := st.getColumns(ctx, ADOPTED_RESOURCES_TABLE)
+	if err != nil || len(existing) == 0 {
+		return nil, err
+	}
+	cols := []string{"Kind", "Name", "DatabaseId", TENANT_COLUMN}
+	for i, col := range cols {
+		cols[i] = quoteIdentifier(st.dialect, col)
+	}
+	stmt := spanner.Statement{
+		SQL:    fmt.Sprintf(`SELECT %s FROM %s WHERE %s = %s ORDER BY %s`, strings.Join(cols, ", "), quoteIdentifier(st.dialect, ADOPTED_RESOURCES_TABLE), quoteIdentifier(st.dialect, "JobNamePrefix"), getQueryParam(st.dialect, 1), quoteIdentifier(st.dialect, "AdoptedAt")),
+		Params: map[string]interface{}{"p1": st.cfg.jobNamePrefix},
+	}
+	var resources []ManualResource
+	err = st.client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var r ManualResource
+		var tenant spanner.NullString
+		if err := row.Columns(&r.Kind, &r.Name, &r.Database, &tenant); err != nil {
+			return fmt.Errorf("can't scan row from %s table: %v", ADOPTED_RESOURCES_TABLE, err)
+		}
+		if st.cfg.isSameTenant(tenant.StringVal) {
+			resources = append(resources, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the adopted resources of pipeline %s: %w", st.cfg.jobNamePrefix, err)
+	}
+	return resources, nil
+}
+
+func (st *spannerMetadataStore) RecordCreatedResource(ctx context.Context, r createdResource) error {
+	if err := st.createTable(ctx, CREATED_RESOURCES_TABLE, "created resources table", getCreatedResourcesTableDdl); err != nil {
+		return err
+	}
+	_, err := st.client.Apply(ctx, []*spanner.Mutation{
+		spanner.InsertOrUpdate(CREATED_RESOURCES_TABLE,
+			[]string{"JobNamePrefix", "Kind", "Name", "DatabaseId", "CreatedAt"},
+			[]interface{}{r.getOwner(st.cfg.jobNamePrefix), r.kind, r.name, r.database, spanner.CommitTimestamp}),
+	})
+	if err != nil {
+		return fmt.Errorf("could not record created %s %s of pipeline %s: %v", r.kind, r.name, st.cfg.jobNamePrefix, err)
+	}
+	return nil
+}
+
+func (st *spannerMetadataStore) ReadCreatedResources(ctx context.Context) ([]createdResource, error) {
+	existing, err := st.getColumns(ctx, CREATED_RESOURCES_TABLE)
+	if err != nil || len(existing) == 0 {
+		return nil, err
+	}
+	cols := []string{"Kind", "Name", "DatabaseId"}
+	for i, col := range cols {
+		cols[i] = quoteIdentifier(st.dialect, col)
+	}
+	jobNamePrefixCol := quoteIdentifier(st.dialect, "JobNamePrefix")
+	stmt := spanner.Statement{
+		SQL:    fmt.Sprintf(`SELECT %s FROM %s WHERE %s = %s OR %s = '' ORDER BY %s`, strings.Join(cols, ", "), quoteIdentifier(st.dialect, CREATED_RESOURCES_TABLE), jobNamePrefixCol, getQueryParam(st.dialect, 1), jobNamePrefixCol, quoteIdentifier(st.dialect, "CreatedAt")),
+		Params: map[string]interface{}{"p1": st.cfg.jobNamePrefix},
+	}
+	var resources []createdResource
+	err = st.client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var r createdResource
+		if err := row.Columns(&r.kind, &r.name, &r.database); err != nil {
+			return fmt.Errorf("can't scan row from %s table: %v", CREATED_RESOURCES_TABLE, err)
+		}
+		resources = append(resources, r)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the created resources of pipeline %s: %w", st.cfg.jobNamePrefix, err)
+	}
+	return resources, nil
+}
+
+// getSchema returns the schema of the tables of the metadata database in
+// information_schema.
+func (st *spannerMetadataStore) getSchema() string {
+	if st.dialect == constants.DIALECT_POSTGRESQL {
+		return "public"
+	}
+	return ""
+}
+
+func (st *spannerMetadataStore) ListChangeStreamMetadataTables(ctx context.Context) ([]string, error) {
+	stmt := spanner.Statement{
+		SQL:    fmt.Sprintf(`SELECT table_name FROM information_schema.columns WHERE table_schema = '%s' AND column_name = %s ORDER BY table_name`, st.getSchema(), getQueryParam(st.dialect, 1)),
+		Params: map[string]interface{}{"p1": "PartitionToken"},
+	}
+	var tables []string
+	err := st.client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var name string
+		if err := row.Columns(&name); err != nil {
+			return err
+		}
+		tables = append(tables, name)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the change stream metadata tables: %w", err)
+	}
+	return tables, nil
+}
+
+func (st *spannerMetadataStore) DropTables(ctx context.Context, tables []string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	// The indexes of a table are dropped before it.
+	stmt := spanner.Statement{
+		SQL:    fmt.Sprintf(`SELECT table_name, index_name FROM information_schema.indexes WHERE table_schema = '%s' AND index_type = 'INDEX' AND table_name IN UNNEST(%s)`, st.getSchema(), getQueryParam(st.dialect, 1)),
+		Params: map[string]interface{}{"p1": tables},
+	}
+	if st.dialect == constants.DIALECT_POSTGRESQL {
+		stmt.SQL = fmt.Sprintf(`SELECT table_name, index_name FROM information_schema.indexes WHERE table_schema = '%s' AND index_type = 'INDEX' AND table_name = ANY(%s)`, st.getSchema(), getQueryParam(st.dialect, 1))
+	}
+	var stmts []string
+	err := st.client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var table, index string
+		if err := row.Columns(&table, &index); err != nil {
+			return err
+		}
+		stmts = append(stmts, fmt.Sprintf("DROP INDEX %s", quoteIdentifier(st.dialect, index)))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't read the indexes of the tables %s: %w", strings.Join(tables, ", "), err)
+	}
+	for _, table := range tables {
+		stmts = append(stmts, fmt.Sprintf("DROP TABLE %s", quoteIdentifier(st.dialect, table)))
+	}
+	op, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "UpdateDatabaseDdl", Resource: st.cfg.getMetadataDbUri()}, func(ctx context.Context) (*database.UpdateDatabaseDdlOperation, error) {
+		return st.adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+			Database:   st.cfg.getMetadataDbUri(),
+			Statements: stmts,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("cannot submit drop tables request: %v", err)
+	}
+	return op.Wait(ctx)
+}
+
+func (st *spannerMetadataStore) DeletePipelineRecords(ctx context.Context) error {
+	stmt := spanner.Statement{SQL: fmt.Sprintf(`SELECT table_name FROM information_schema.tables WHERE table_schema = '%s'`, st.getSchema())}
+	exists := make(map[string]bool)
+	err := st.client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var name string
+		if err := row.Columns(&name); err != nil {
+			return err
+		}
+		exists[name] = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't read the tables of the metadata db: %w", err)
+	}
+	_, err = st.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		for _, table := range launcherTables {
+			if !exists[table] {
+				continue
+			}
+			_, err := txn.Update(ctx, spanner.Statement{
+				SQL:    fmt.Sprintf(`DELETE FROM %s WHERE %s = %s`, quoteIdentifier(st.dialect, table), quoteIdentifier(st.dialect, "JobNamePrefix"), getQueryParam(st.dialect, 1)),
+				Params: map[string]interface{}{"p1": st.cfg.jobNamePrefix},
+			})
+			if err != nil {
+				return fmt.Errorf("%s table: %v", table, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not delete the records of pipeline %s: %v", st.cfg.jobNamePrefix, err)
+	}
+	return nil
+}
+
+func (st *spannerMetadataStore) RecordValidationRun(ctx context.Context, report reconciliationReport) error {
+	if err := st.createTable(ctx, VALIDATION_RUNS_TABLE, "validation runs table", getValidationRunsTableDdl); err != nil {
+		return err
+	}
+	bArr, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("could not serialize reconciliation report: %v", err)
+	}
+	_, err = st.client.Apply(ctx, []*spanner.Mutation{
+		spanner.Insert(VALIDATION_RUNS_TABLE,
+			[]string{"JobNamePrefix", "RunAt", "TablesValidated", "Mismatches", "Drift", "Report"},
+			[]interface{}{st.cfg.jobNamePrefix, spanner.CommitTimestamp, int64(len(report.Tables)), int64(report.Mismatches), getDrift(report), string(bArr)}),
+	})
+	if err != nil {
+		return fmt.Errorf("could not record validation run of pipeline %s: %v", st.cfg.jobNamePrefix, err)
+	}
+	return nil
+}
+
+func (st *spannerMetadataStore) RecordCredentialRotation(ctx context.Context, rotated []string) error {
+	if err := st.createTable(ctx, CREDENTIAL_ROTATIONS_TABLE, "credential rotations table", getCredentialRotationsTableDdl); err != nil {
+		return err
+	}
+	_, err := st.client.Apply(ctx, []*spanner.Mutation{
+		spanner.Insert(CREDENTIAL_ROTATIONS_TABLE,
+			[]string{"JobNamePrefix", "RotatedAt", "ShardIds", "SourceShardsFilePath"},
+			[]interface{}{st.cfg.jobNamePrefix, spanner.CommitTimestamp, strings.Join(rotated, ","), st.cfg.newSourceShardsFilePath}),
+	})
+	if err != nil {
+		return fmt.Errorf("could not record credential rotation of pipeline %s: %v", st.cfg.jobNamePrefix, err)
+	}
+	return nil
+}
+
+func (st *spannerMetadataStore) RecordCreationStatus(ctx context.Context, holder, status, reason string) error {
+	if err := st.createTable(ctx, CREATIONS_TABLE, "creations table", getCreationsTableDdl); err != nil {
+		return err
+	}
+	_, err := st.client.Apply(ctx, []*spanner.Mutation{
+		spanner.InsertOrUpdate(CREATIONS_TABLE,
+			[]string{"JobNamePrefix", "Holder", "Status", "Reason", TENANT_COLUMN, "UpdatedAt"},
+			[]interface{}{st.cfg.jobNamePrefix, holder, status, spanner.NullString{StringVal: reason, Valid: reason != ""}, st.cfg.getTenant(), spanner.CommitTimestamp}),
+	})
+	if err != nil {
+		return fmt.Errorf("could not record creation status of pipeline %s: %v", st.cfg.jobNamePrefix, err)
+	}
+	return nil
+}
+
+func (st *spannerMetadataStore) RecordJobDefinition(ctx context.Context, definition string) error {
+	if err := st.createTable(ctx, JOB_DEFINITIONS_TABLE, "job definitions table", getJobDefinitionsTableDdl); err != nil {
+		return err
+	}
+	_, err := st.client.Apply(ctx, []*spanner.Mutation{
+		spanner.InsertOrUpdate(JOB_DEFINITIONS_TABLE,
+			[]string{"JobNamePrefix", "Definition", TENANT_COLUMN, "UpdatedAt"},
+			[]interface{}{st.cfg.jobNamePrefix, definition, st.cfg.getTenant(), spanner.CommitTimestamp}),
+	})
+	if err != nil {
+		return fmt.Errorf("could not record definition of pipeline %s: %v", st.cfg.jobNamePrefix, err)
+	}
+	return nil
+}
+
+func (st *spannerMetadataStore) ReadJobDefinition(ctx context.Context) (string, string, bool, error) {
+	existing, err := st.getColumns(ctx, JOB_DEFINITIONS_TABLE)
+	if err != nil || len(existing) == 0 {
+		return "", "", false, err
+	}
+	row, err := st.client.Single().ReadRow(ctx, JOB_DEFINITIONS_TABLE, spanner.Key{st.cfg.jobNamePrefix}, []string{"Definition", TENANT_COLUMN})
+	if spanner.ErrCode(err) == codes.NotFound {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, fmt.Errorf("couldn't read pipeline %s from %s table: %v", st.cfg.jobNamePrefix, JOB_DEFINITIONS_TABLE, err)
+	}
+	var definition string
+	var owner spanner.NullString
+	if err := row.Columns(&definition, &owner); err != nil {
+		return "", "", false, fmt.Errorf("can't scan row from %s table: %v", JOB_DEFINITIONS_TABLE, err)
+	}
+	return definition, owner.StringVal, true, nil
+}
+
+func (st *spannerMetadataStore) RecordJobRestart(ctx context.Context, jobName, failedJobId string) error {
+	if err := st.createTable(ctx, RESTARTS_TABLE, "restarts table", getRestartsTableDdl); err != nil {
+		return err
+	}
+	_, err := st.client.Apply(ctx, []*spanner.Mutation{
+		spanner.Insert(RESTARTS_TABLE,
+			[]string{"JobNamePrefix", "RestartedAt", "JobName", "FailedJobId", TENANT_COLUMN},
+			[]interface{}{st.cfg.jobNamePrefix, spanner.CommitTimestamp, jobName, failedJobId, st.cfg.getTenant()}),
+	})
+	if err != nil {
+		return fmt.Errorf("could not record restart of job %s: %v", jobName, err)
+	}
+	return nil
+}
+
+// jobRecord is a row of the jobs table kept by localMetadataStore.
+type jobRecord struct {
+	suffixes  []string
+	sizing    workerSizing
+	snapshot  time.Time
+	start     time.Time
+	tenant    string
+	updatedAt time.Time
+}
+
+// credentialRotation is a row of the credential rotations table kept by
+// localMetadataStore.
+type credentialRotation struct {
+	jobNamePrefix        string
+	rotatedAt            time.Time
+	shardIds             []string
+	sourceShardsFilePath string
+}
+
+// validationRun is a row of the validation runs table kept by
+// localMetadataStore.
+type validationRun struct {
+	jobNamePrefix string
+	runAt         time.Time
+	report        reconciliationReport
+}
+
+// creationRecord is a row of the creations table kept by localMetadataStore.
+type creationRecord struct {
+	jobNamePrefix string
+	status        string
+	reason        string
+	updatedAt     time.Time
+}
+
+// jobDefinition is a row of the job definitions table kept by
+// localMetadataStore.
+type jobDefinition struct {
+	definition string
+	tenant     string
+}
+
+// jobRestart is a row of the restarts table kept by localMetadataStore.
+type jobRestart struct {
+	jobNamePrefix string
+	restartedAt   time.Time
+	jobName       string
+	failedJobId   string
+}
+
+// adoptedResource is a row of the adopted resources table kept by
+// localMetadataStore.
+type adoptedResource struct {
+	jobNamePrefix string
+	resource      ManualResource
+	tenant        string
+}
+
+// createdRecord is a row of the created resources table kept by
+// localMetadataStore.
+type createdRecord struct {
+	jobNamePrefix string
+	resource      createdResource
+}
+
+// localMetadataStore implements metadataStore in memory, for runs without a
+// metadata database such as dry runs.
+type localMetadataStore struct {
+	cfg            *config
+	mu             sync.Mutex
+	owners         map[string]suffixOwner
+	jobs           map[string]jobRecord
+	validationRuns []validationRun
+	rotations      []credentialRotation
+	creations      map[string]creationRecord
+	definitions    map[string]jobDefinition
+	restarts       []jobRestart
+	adopted        []adoptedResource
+	created        []createdRecord
+	// metadataTables are the change stream metadata tables of the ordering
+	// jobs.
+	metadataTables []string
+}
+
+var _ metadataStore = (*localMetadataStore)(nil)
+
+// newLocalMetadataStore returns an empty in-memory store.
+func newLocalMetadataStore(cfg *config) *localMetadataStore {
+	return &localMetadataStore{cfg: cfg, owners: make(map[string]suffixOwner), jobs: make(map[string]jobRecord), creations: make(map[string]creationRecord), definitions: make(map[string]jobDefinition)}
+}
+
+func (st *localMetadataStore) Close() {}
+
+func (st *localMetadataStore) ReadSuffixOwners(ctx context.Context) (map[string]suffixOwner, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	owners := make(map[string]suffixOwner)
+	for suffix, owner := range st.owners {
+		owners[suffix] = owner
+	}
+	return owners, nil
+}
+
+func (st *localMetadataStore) RegisterSuffix(ctx context.Context, suffix string, owner suffixOwner) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.owners[suffix] = owner
+	return nil
+}
+
+func (st *localMetadataStore) RecordWorkerSizing(ctx context.Context, suffixes []string, s workerSizing) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	job := st.jobs[st.cfg.jobNamePrefix]
+	job.suffixes, job.sizing, job.tenant, job.updatedAt = suffixes, s, st.cfg.getTenant(), time.Now()
+	st.jobs[st.cfg.jobNamePrefix] = job
+	return nil
+}
+
+func (st *localMetadataStore) ReadJobTenant(ctx context.Context) (string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.jobs[st.cfg.jobNamePrefix].tenant, nil
+}
+
+func (st *localMetadataStore) RecordSnapshotWindow(ctx context.Context, suffixes []string, snapshot, start time.Time) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	job := st.jobs[st.cfg.jobNamePrefix]
+	job.suffixes, job.snapshot, job.start, job.tenant, job.updatedAt = suffixes, snapshot, start, st.cfg.getTenant(), time.Now()
+	st.jobs[st.cfg.jobNamePrefix] = job
+	return nil
+}
+
+func (st *localMetadataStore) ReadSnapshotWindow(ctx context.Context) (time.Time, time.Time, bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	job := st.jobs[st.cfg.jobNamePrefix]
+	return job.snapshot, job.start, !job.snapshot.IsZero() && !job.start.IsZero(), nil
+}
+
+func (st *localMetadataStore) RecordAdoptedResource(ctx context.Context, r ManualResource) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for i, a := range st.adopted {
+		if a.jobNamePrefix == st.cfg.jobNamePrefix && a.resource == r {
+			st.adopted = append(st.adopted[:i], st.adopted[i+1:]...)
+			break
+		}
+	}
+	st.adopted = append(st.adopted, adoptedResource{jobNamePrefix: st.cfg.jobNamePrefix, resource: r, tenant: st.cfg.getTenant()})
+	return nil
+}
+
+func (st *localMetadataStore) ReadAdoptedResources(ctx context.Context) ([]ManualResource, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var resources []ManualResource
+	for _, a := range st.adopted {
+		if a.jobNamePrefix == st.cfg.jobNamePrefix && st.cfg.isSameTenant(a.tenant) {
+			resources = append(resources, a.resource)
+		}
+	}
+	return resources, nil
+}
+
+func (st *localMetadataStore) RecordCreatedResource(ctx context.Context, r createdResource) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	owner := r.getOwner(st.cfg.jobNamePrefix)
+	for _, c := range st.created {
+		if c.jobNamePrefix == owner && c.resource == r {
+			return nil
+		}
+	}
+	st.created = append(st.created, createdRecord{jobNamePrefix: owner, resource: r})
+	return nil
+}
+
+func (st *localMetadataStore) ReadCreatedResources(ctx context.Context) ([]createdResource, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var resources []createdResource
+	for _, c := range st.created {
+		if c.jobNamePrefix == st.cfg.jobNamePrefix || c.jobNamePrefix == "" {
+			resources = append(resources, c.resource)
+		}
+	}
+	return resources, nil
+}
+
+func (st *localMetadataStore) RecordValidationRun(ctx context.Context, report reconciliationReport) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.validationRuns = append(st.validationRuns, validationRun{jobNamePrefix: st.cfg.jobNamePrefix, runAt: time.Now(), report: report})
+	return nil
+}
+
+func (st *localMetadataStore) RecordCredentialRotation(ctx context.Context, rotated []string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	shardIds := append([]string(nil), rotated...)
+	sort.Strings(shardIds)
+	st.rotations = append(st.rotations, credentialRotation{jobNamePrefix: st.cfg.jobNamePrefix, rotatedAt: time.Now(), shardIds: shardIds, sourceShardsFilePath: st.cfg.newSourceShardsFilePath})
+	return nil
+}
+
+func (st *localMetadataStore) RecordCreationStatus(ctx context.Context, holder, status, reason string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.creations[holder] = creationRecord{jobNamePrefix: st.cfg.jobNamePrefix, status: status, reason: reason, updatedAt: time.Now()}
+	return nil
+}
+
+func (st *localMetadataStore) RecordJobDefinition(ctx context.Context, definition string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.definitions[st.cfg.jobNamePrefix] = jobDefinition{definition: definition, tenant: st.cfg.getTenant()}
+	return nil
+}
+
+func (st *localMetadataStore) ReadJobDefinition(ctx context.Context) (string, string, bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	def, ok := st.definitions[st.cfg.jobNamePrefix]
+	return def.definition, def.tenant, ok, nil
+}
+
+func (st *localMetadataStore) RecordJobRestart(ctx context.Context, jobName, failedJobId string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.restarts = append(st.restarts, jobRestart{jobNamePrefix: st.cfg.jobNamePrefix, restartedAt: time.Now(), jobName: jobName, failedJobId: failedJobId})
+	return nil
+}
+
+func (st *localMetadataStore) ListChangeStreamMetadataTables(ctx context.Context) ([]string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	tables := append([]string(nil), st.metadataTables...)
+	sort.Strings(tables)
+	return tables, nil
+}
+
+func (st *localMetadataStore) DropTables(ctx context.Context, tables []string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	dropped := make(map[string]bool)
+	for _, table := range tables {
+		dropped[table] = true
+	}
+	var kept []string
+	for _, table := range st.metadataTables {
+		if !dropped[table] {
+			kept = append(kept, table)
+		}
+	}
+	st.metadataTables = kept
+	return nil
+}
+
+func (st *localMetadataStore) DeletePipelineRecords(ctx context.Context) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	prefix := st.cfg.jobNamePrefix
+	for suffix, owner := range st.owners {
+		if owner.jobNamePrefix == prefix {
+			delete(st.owners, suffix)
+		}
+	}
+	delete(st.jobs, prefix)
+	delete(st.definitions, prefix)
+	for holder, c := range st.creations {
+		if c.jobNamePrefix == prefix {
+			delete(st.creations, holder)
+		}
+	}
+	var runs []validationRun
+	for _, r := range st.validationRuns {
+		if r.jobNamePrefix != prefix {
+			runs = append(runs, r)
+		}
+	}
+	st.validationRuns = runs
+	var rotations []credentialRotation
+	for _, r := range st.rotations {
+		if r.jobNamePrefix != prefix {
+			rotations = append(rotations, r)
+		}
+	}
+	st.rotations = rotations
+	var restarts []jobRestart
+	for _, r := range st.restarts {
+		if r.jobNamePrefix != prefix {
+			restarts = append(restarts, r)
+		}
+	}
+	st.restarts = restarts
+	var adopted []adoptedResource
+	for _, a := range st.adopted {
+		if a.jobNamePrefix != prefix {
+			adopted = append(adopted, a)
+		}
+	}
+	st.adopted = adopted
+	var created []createdRecord
+	for _, c := range st.created {
+		if c.jobNamePrefix != prefix {
+			created = append(created, c)
+		}
+	}
+	st.created = created
+	return nil
+}
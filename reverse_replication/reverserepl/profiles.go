@@ -0,0 +1,144 @@
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/profiles"
+	datastreampb "google.golang.org/genproto/googleapis/cloud/datastream/v1"
+)
+
+// sourceShard is a shard of the source shards file of the sql source types.
+type sourceShard struct {
+	LogicalShardId string `json:"logicalShardId"`
+	Host           string `json:"host"`
+	User           string `json:"user"`
+	Password       string `json:"password"`
+	Port           string `json:"port"`
+	DbName         string `json:"dbName"`
+}
+
+// GenerateSourceShardsFromProfile returns the source shards file of the
+// source databases of a forward migration, described by the source profile
+// src, along with the notes on the shards to complete or review before using
+// it for reverse replication. A connection profile gives a single shard named
+// after its database. A bulk sharded config gives a shard per data shard, and
+// a dataflow sharded config a shard per logical shard, whose connection
+// details are read from the Datastream source connection profile of its data
+// shard in projectId. The passwords are not written: the password of every
+// shard is a reference to the environment variable it is read from when the
+// file is uploaded, which the notes list.
+func GenerateSourceShardsFromProfile(ctx context.Context, src profiles.SourceProfile, projectId string) ([]byte, []string, error) {
+	var shards []sourceShard
+	var notes []string
+	switch src.Ty {
+	case profiles.SourceProfileTypeConnection:
+		shard, note, err := getConnectionProfileShard(src.Conn)
+		if err != nil {
+			return nil, nil, err
+		}
+		shards = append(shards, shard)
+		if note != "" {
+			notes = append(notes, note)
+		}
+	case profiles.SourceProfileTypeConfig:
+		switch src.Config.ConfigType {
+		case constants.BULK_MIGRATION:
+			for _, s := range src.Config.ShardConfigurationBulk.DataShards {
+				shards = append(shards, sourceShard{LogicalShardId: s.DataShardId, Host: s.Host, User: s.User, Port: s.Port, DbName: s.DbName})
+			}
+		case constants.DATAFLOW_MIGRATION:
+			var err error
+			if shards, err = getDataflowConfigShards(ctx, src.Config.ShardConfigurationDataflow, projectId); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("can't generate source shards from a %s config", src.Config.ConfigType)
+		}
+	default:
+		return nil, nil, fmt.Errorf("can't generate source shards from a file or csv source profile, please use a connection or config source profile")
+	}
+	if len(shards) == 0 {
+		return nil, nil, fmt.Errorf("the source profile does not list any shard")
+	}
+	seen := make(map[string]bool)
+	var vars []string
+	for i := range shards {
+		s := &shards[i]
+		if s.LogicalShardId == "" || seen[s.LogicalShardId] {
+			return nil, nil, fmt.Errorf("shard at index %d of the source profile does not have a unique shard id", i)
+		}
+		seen[s.LogicalShardId] = true
+		v := getShardPasswordVariable(s.LogicalShardId)
+		s.Password = fmt.Sprintf("${%s}", v)
+		vars = append(vars, v)
+	}
+	notes = append(notes, fmt.Sprintf("The passwords of the shards are not written to the file, please set %s to the password of every shard before creating the pipeline from the local file", strings.Join(vars, ", ")))
+	b, err := json.MarshalIndent(shards, "", "    ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't encode source shards to JSON: %v", err)
+	}
+	return b, notes, nil
+}
+
+// getConnectionProfileShard returns the shard of a connection source profile,
+// and a note on the sourceType to use with it.
+func getConnectionProfileShard(conn profiles.SourceProfileConnection) (sourceShard, string, error) {
+	switch conn.Ty {
+	case profiles.SourceProfileConnectionTypeMySQL:
+		c := conn.Mysql
+		return sourceShard{LogicalShardId: c.Db, Host: c.Host, User: c.User, Port: c.Port, DbName: c.Db}, "", nil
+	case profiles.SourceProfileConnectionTypeSqlServer:
+		c := conn.SqlServer
+		return sourceShard{LogicalShardId: c.Db, Host: c.Host, User: c.User, Port: c.Port, DbName: c.Db},
+			fmt.Sprintf("please launch the pipeline with -sourceType=%s", constants.SQLSERVER), nil
+	case profiles.SourceProfileConnectionTypeOracle:
+		c := conn.Oracle
+		return sourceShard{LogicalShardId: c.Db, Host: c.Host, User: c.User, Port: c.Port, DbName: c.Db},
+			fmt.Sprintf("please launch the pipeline with -sourceType=%s -allowExperimental", constants.ORACLE), nil
+	}
+	return sourceShard{}, "", fmt.Errorf("reverse replication only supports %s, %s and %s sources", constants.MYSQL, constants.SQLSERVER, constants.ORACLE)
+}
+
+// getDataflowConfigShards returns a shard per logical shard of a dataflow
+// sharded config, with the connection details of the Datastream source
+// connection profile of its data shard.
+func getDataflowConfigShards(ctx context.Context, dataflowCfg profiles.ShardConfigurationDataflow, projectId string) ([]sourceShard, error) {
+	if projectId == "" {
+		return nil, fmt.Errorf("please specify the project of the Datastream connection profiles of the dataflow config")
+	}
+	dsClient, err := getClients(ctx).NewDatastreamClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create datastream client: %v", err)
+	}
+	defer dsClient.Close()
+	var shards []sourceShard
+	for _, dataShard := range dataflowCfg.DataShards {
+		srcProfile := dataShard.SrcConnectionProfile
+		name := fmt.Sprintf("projects/%s/locations/%s/connectionProfiles/%s", projectId, srcProfile.Location, srcProfile.Name)
+		res, err := gcp.DoWithResult(ctx, gcp.Call{Service: gcp.DATASTREAM, Method: "GetConnectionProfile", Resource: name, Idempotent: true}, func(ctx context.Context) (*datastreampb.ConnectionProfile, error) {
+			return dsClient.GetConnectionProfile(ctx, &datastreampb.GetConnectionProfileRequest{Name: name})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not get connection profile %s of data shard %s: %v", name, dataShard.DataShardId, err)
+		}
+		mysql := res.GetMysqlProfile()
+		if mysql == nil {
+			return nil, fmt.Errorf("connection profile %s of data shard %s is not a %s profile", name, dataShard.DataShardId, constants.MYSQL)
+		}
+		for _, logicalShard := range dataShard.LogicalShards {
+			shards = append(shards, sourceShard{
+				LogicalShardId: logicalShard.LogicalShardId,
+				Host:           mysql.GetHostname(),
+				User:           mysql.GetUsername(),
+				Port:           fmt.Sprint(mysql.GetPort()),
+				DbName:         logicalShard.DbName,
+			})
+		}
+	}
+	return shards, nil
+}
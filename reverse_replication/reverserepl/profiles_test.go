@@ -0,0 +1,133 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/profiles"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateSourceShardsFromProfile(t *testing.T) {
+	bulk := func(shards ...profiles.DirectConnectionConfig) profiles.SourceProfile {
+		return profiles.SourceProfile{Ty: profiles.SourceProfileTypeConfig, Config: profiles.SourceProfileConfig{
+			ConfigType:             constants.BULK_MIGRATION,
+			ShardConfigurationBulk: profiles.ShardConfigurationBulk{DataShards: shards},
+		}}
+	}
+	tests := []struct {
+		name        string
+		src         profiles.SourceProfile
+		want        []sourceShard
+		sourceNote  string
+		errContains string
+	}{
+		{
+			name: "mysql connection",
+			src: profiles.SourceProfile{Ty: profiles.SourceProfileTypeConnection, Conn: profiles.SourceProfileConnection{
+				Ty:    profiles.SourceProfileConnectionTypeMySQL,
+				Mysql: profiles.SourceProfileConnectionMySQL{Host: "10.0.0.1", Port: "3306", User: "root", Db: "orders", Pwd: "secret"},
+			}},
+			want: []sourceShard{{LogicalShardId: "orders", Host: "10.0.0.1", User: "root", Password: "${REVERSE_REPLICATION_ORDERS_PASSWORD}", Port: "3306", DbName: "orders"}},
+		},
+		{
+			name: "sql server connection",
+			src: profiles.SourceProfile{Ty: profiles.SourceProfileTypeConnection, Conn: profiles.SourceProfileConnection{
+				Ty:        profiles.SourceProfileConnectionTypeSqlServer,
+				SqlServer: profiles.SourceProfileConnectionSqlServer{Host: "10.0.0.1", Port: "1433", User: "sa", Db: "orders"},
+			}},
+			want:       []sourceShard{{LogicalShardId: "orders", Host: "10.0.0.1", User: "sa", Password: "${REVERSE_REPLICATION_ORDERS_PASSWORD}", Port: "1433", DbName: "orders"}},
+			sourceNote: "please launch the pipeline with -sourceType=sqlserver",
+		},
+		{
+			name: "oracle connection",
+			src: profiles.SourceProfile{Ty: profiles.SourceProfileTypeConnection, Conn: profiles.SourceProfileConnection{
+				Ty:     profiles.SourceProfileConnectionTypeOracle,
+				Oracle: profiles.SourceProfileConnectionOracle{Host: "10.0.0.1", Port: "1521", User: "system", Db: "ORCL"},
+			}},
+			want:       []sourceShard{{LogicalShardId: "ORCL", Host: "10.0.0.1", User: "system", Password: "${REVERSE_REPLICATION_ORCL_PASSWORD}", Port: "1521", DbName: "ORCL"}},
+			sourceNote: "please launch the pipeline with -sourceType=oracle -allowExperimental",
+		},
+		{
+			name: "postgres connection",
+			src: profiles.SourceProfile{Ty: profiles.SourceProfileTypeConnection, Conn: profiles.SourceProfileConnection{
+				Ty: profiles.SourceProfileConnectionTypePostgreSQL,
+			}},
+			errContains: "reverse replication only supports mysql, sqlserver and oracle sources",
+		},
+		{
+			name: "bulk config",
+			src: bulk(
+				profiles.DirectConnectionConfig{DataShardId: "shard-1", Host: "10.0.0.1", User: "root", Port: "3306", DbName: "orders"},
+				profiles.DirectConnectionConfig{DataShardId: "shard-2", Host: "10.0.0.2", User: "root", Port: "3306", DbName: "orders"},
+			),
+			want: []sourceShard{
+				{LogicalShardId: "shard-1", Host: "10.0.0.1", User: "root", Password: "${REVERSE_REPLICATION_SHARD_1_PASSWORD}", Port: "3306", DbName: "orders"},
+				{LogicalShardId: "shard-2", Host: "10.0.0.2", User: "root", Password: "${REVERSE_REPLICATION_SHARD_2_PASSWORD}", Port: "3306", DbName: "orders"},
+			},
+		},
+		{
+			name:        "bulk config without shards",
+			src:         bulk(),
+			errContains: "the source profile does not list any shard",
+		},
+		{
+			name: "bulk config with duplicate shard ids",
+			src: bulk(
+				profiles.DirectConnectionConfig{DataShardId: "shard-1", Host: "10.0.0.1"},
+				profiles.DirectConnectionConfig{DataShardId: "shard-1", Host: "10.0.0.2"},
+			),
+			errContains: "shard at index 1 of the source profile does not have a unique shard id",
+		},
+		{
+			name: "dataflow config without project",
+			src: profiles.SourceProfile{Ty: profiles.SourceProfileTypeConfig, Config: profiles.SourceProfileConfig{
+				ConfigType: constants.DATAFLOW_MIGRATION,
+			}},
+			errContains: "please specify the project of the Datastream connection profiles of the dataflow config",
+		},
+		{
+			name:        "file profile",
+			src:         profiles.SourceProfile{Ty: profiles.SourceProfileTypeFile},
+			errContains: "can't generate source shards from a file or csv source profile",
+		},
+	}
+	for _, tc := range tests {
+		b, notes, err := GenerateSourceShardsFromProfile(context.Background(), tc.src, "")
+		if tc.errContains != "" {
+			if assert.NotNil(t, err, tc.name) {
+				assert.Contains(t, err.Error(), tc.errContains, tc.name)
+			}
+			continue
+		}
+		if !assert.Nil(t, err, tc.name) {
+			continue
+		}
+		var shards []sourceShard
+		assert.Nil(t, json.Unmarshal(b, &shards), tc.name)
+		assert.Equal(t, tc.want, shards, tc.name)
+		// The note on the passwords comes last.
+		if tc.sourceNote != "" && assert.Equal(t, 2, len(notes), tc.name) {
+			assert.Equal(t, tc.sourceNote, notes[0], tc.name)
+		}
+		if assert.NotEmpty(t, notes, tc.name) {
+			assert.Contains(t, notes[len(notes)-1], "The passwords of the shards are not written to the file", tc.name)
+		}
+	}
+}
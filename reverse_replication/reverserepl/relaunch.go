@@ -0,0 +1,91 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// getRegisteredSuffix returns the metadata table suffix registered by this
+// pipeline for the ordering job of db. If the pipeline registered several
+// suffixes for db, the requested one must be among them.
+func (cfg *config) getRegisteredSuffix(owners map[string]suffixOwner, db, requestedSuffix string) (string, error) {
+	var suffixes []string
+	for suffix, owner := range owners {
+		if owner.jobNamePrefix == cfg.jobNamePrefix && owner.instanceId == cfg.instanceId && owner.dbName == db {
+			suffixes = append(suffixes, suffix)
+		}
+	}
+	sort.Strings(suffixes)
+	switch {
+	case len(suffixes) == 0:
+		return "", fmt.Errorf("pipeline %s has no metadata tables registered for database %s. Please launch the pipeline without -relaunchOrdering", cfg.jobNamePrefix, db)
+	case len(suffixes) == 1:
+		return suffixes[0], nil
+	}
+	for _, suffix := range suffixes {
+		if suffix == requestedSuffix {
+			return suffix, nil
+		}
+	}
+	return "", fmt.Errorf("pipeline %s registered the metadata table suffixes '%s' for database %s. Please pass the one to resume from as metadataTableSuffix", cfg.jobNamePrefix, strings.Join(suffixes, "', '"), db)
+}
+
+// relaunchOrderingJobs relaunches the ordering job of every database in dbs
+// in orderingRunMode, with the metadata tables registered by the previous
+// launch of the pipeline so that the resume modes pick up where the previous
+// ordering jobs left off. The ordering jobs must no longer be running. The
+// writer jobs and the other resources of the pipeline are left untouched.
+func (cfg *config) relaunchOrderingJobs(ctx context.Context, dbs []string) error {
+	multiDb := len(dbs) > 1
+	orderingJobNames := cfg.getOrderingJobNames(dbs)
+	states, err := cfg.getPipelineJobStates(ctx, orderingJobNames)
+	if err != nil {
+		return err
+	}
+	for _, name := range orderingJobNames {
+		for _, state := range states[name] {
+			if !isTerminalJobState(state) {
+				return fmt.Errorf("ordering job %s is still running (%s). Please cancel or drain it before relaunching", name, state)
+			}
+		}
+	}
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	store, err := getClients(ctx).openMetadataStore(ctx, cfg, adminClient)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	owners, err := store.ReadSuffixOwners(ctx)
+	if err != nil {
+		return err
+	}
+	suffixes := make(map[string]string)
+	for _, db := range dbs {
+		suffix, err := cfg.getRegisteredSuffix(owners, db, cfg.getDatabaseTableSuffix(db, multiDb))
+		if err != nil {
+			return err
+		}
+		suffixes[db] = suffix
+	}
+	c, err := getClients(ctx).NewDataflowAccessor(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create dataflow client: %v", err)
+	}
+	defer c.Close()
+	for _, db := range dbs {
+		req, err := cfg.getOrderingJobRequest(db, suffixes[db], multiDb)
+		if err != nil {
+			return err
+		}
+		if err := cfg.launchJob(ctx, c, req, "ordering"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+)
+
+// Run mode of the writer job applying the changes previously skipped by the
+// writer jobs of the pipeline.
+const WRITER_RUN_MODE_REPROCESS = "reprocess"
+
+// countSet returns the number of set flags.
+func countSet(flags ...bool) int {
+	n := 0
+	for _, f := range flags {
+		if f {
+			n++
+		}
+	}
+	return n
+}
+
+// validateReprocessWindow checks that the bounds of the reprocessed window are
+// RFC 3339 timestamps, in order.
+func (cfg *config) validateReprocessWindow() error {
+	var start, end time.Time
+	var err error
+	if cfg.reprocessStart != "" {
+		if start, err = time.Parse(time.RFC3339, cfg.reprocessStart); err != nil {
+			return fmt.Errorf("please specify a valid reprocessStartTimestamp in RFC 3339 format: %v", err)
+		}
+	}
+	if cfg.reprocessEnd != "" {
+		if end, err = time.Parse(time.RFC3339, cfg.reprocessEnd); err != nil {
+			return fmt.Errorf("please specify a valid reprocessEndTimestamp in RFC 3339 format: %v", err)
+		}
+	}
+	if cfg.reprocessStart != "" && cfg.reprocessEnd != "" && !start.Before(end) {
+		return fmt.Errorf("reprocessStartTimestamp must be before reprocessEndTimestamp")
+	}
+	return nil
+}
+
+func (cfg *config) getReprocessJobName() string {
+	return fmt.Sprintf("%s-writer-reprocess", cfg.jobNamePrefix)
+}
+
+// getReprocessShardsFilePath returns the gcs path of the shards file listing
+// the shards whose skipped changes are reprocessed. It is placed next to the
+// source shards file.
+func (cfg *config) getReprocessShardsFilePath() string {
+	return fmt.Sprintf("%s-%s-reprocess.json", strings.TrimSuffix(cfg.sourceShardsFilePath, ".json"), cfg.jobNamePrefix)
+}
+
+// selectShards returns the shards whose logicalShardId is in ids, or all the
+// shards if ids is empty.
+func (cfg *config) selectShards(shards []interface{}, ids []string) ([]interface{}, error) {
+	if len(ids) == 0 {
+		return shards, nil
+	}
+	shardIds, err := getLogicalShardIds(shards)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[string]interface{})
+	for i, id := range shardIds {
+		byId[id] = shards[i]
+	}
+	var selected []interface{}
+	for _, id := range ids {
+		shard, ok := byId[id]
+		if !ok {
+			return nil, fmt.Errorf("shard %s is not in %s", id, cfg.sourceShardsFilePath)
+		}
+		selected = append(selected, shard)
+	}
+	return selected, nil
+}
+
+// reprocessSkippedChanges launches a writer job in reprocessing mode, applying
+// the changes of the selected shards which the writer jobs of the pipeline
+// skipped, within the requested window. It replaces the manual recovery of
+// relaunching a writer by hand once the rows the changes failed on have been
+// fixed at the source. The writer jobs of the pipeline keep running.
+func (cfg *config) reprocessSkippedChanges(ctx context.Context) error {
+	states, err := cfg.getPipelineJobStates(ctx, []string{cfg.getReprocessJobName()})
+	if err != nil {
+		return err
+	}
+	for _, state := range states[cfg.getReprocessJobName()] {
+		if !isTerminalJobState(state) {
+			return fmt.Errorf("reprocessing job %s is still running (%s). Please wait for it to finish before reprocessing again", cfg.getReprocessJobName(), state)
+		}
+	}
+	shards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for _, id := range strings.Split(cfg.reprocessShardIds, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	selected, err := cfg.selectShards(shards, ids)
+	if err != nil {
+		return err
+	}
+	gcs, err := getClients(ctx).NewStorageAccessor(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create gcs client: %v", err)
+	}
+	defer gcs.Close()
+	shardsFilePath := cfg.getReprocessShardsFilePath()
+	if err := writeShardsFile(ctx, gcs, shardsFilePath, selected); err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Wrote shards file for reprocessing with %d shard(s): %s", len(selected), shardsFilePath))
+
+	params := map[string]string{"runMode": WRITER_RUN_MODE_REPROCESS}
+	if cfg.reprocessStart != "" {
+		params["startTimestamp"] = cfg.reprocessStart
+	}
+	if cfg.reprocessEnd != "" {
+		params["endTimestamp"] = cfg.reprocessEnd
+	}
+	req, err := cfg.getWriterJobRequest(cfg.getReprocessJobName(), shardsFilePath, params)
+	if err != nil {
+		return err
+	}
+	c, err := getClients(ctx).NewDataflowAccessor(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create dataflow client: %v", err)
+	}
+	defer c.Close()
+	return cfg.launchJob(ctx, c, req, "writer")
+}
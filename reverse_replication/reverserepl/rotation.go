@@ -0,0 +1,186 @@
+package reverserepl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+)
+
+// Table in the metadata database recording the credential rotations of every
+// pipeline.
+const CREDENTIAL_ROTATIONS_TABLE = "ReverseReplicationCredentialRotations"
+
+// Fields of a shard configuration holding its credentials.
+var credentialFields = []string{"user", "password", "secretManagerUri"}
+
+// withoutCredentials returns a copy of the shard configuration without its
+// credential fields.
+func withoutCredentials(shard map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{})
+	for k, v := range shard {
+		c[k] = v
+	}
+	for _, f := range credentialFields {
+		delete(c, f)
+	}
+	return c
+}
+
+// getRotatedShards checks that newShards only differ from oldShards by their
+// credentials, and returns the ids of the shards whose credentials changed.
+func (cfg *config) getRotatedShards(oldShards, newShards []interface{}) ([]string, error) {
+	if len(oldShards) != len(newShards) {
+		return nil, fmt.Errorf("%s lists %d shard(s) instead of %d. Please use -updateShards to add or remove shards", cfg.newSourceShardsFilePath, len(newShards), len(oldShards))
+	}
+	byId := make(map[string]map[string]interface{})
+	for i, s := range oldShards {
+		shard, ok := s.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("shard at index %d of %s is not a json object", i, cfg.sourceShardsFilePath)
+		}
+		id, _ := shard["logicalShardId"].(string)
+		byId[id] = shard
+	}
+	var rotated []string
+	for i, s := range newShards {
+		shard, ok := s.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("shard at index %d of %s is not a json object", i, cfg.newSourceShardsFilePath)
+		}
+		id, _ := shard["logicalShardId"].(string)
+		old, ok := byId[id]
+		if !ok {
+			return nil, fmt.Errorf("shard %s is not in %s. Please use -updateShards to add or remove shards", id, cfg.sourceShardsFilePath)
+		}
+		if !reflect.DeepEqual(withoutCredentials(old), withoutCredentials(shard)) {
+			return nil, fmt.Errorf("shard %s has changes other than its %s. Please use -updateShards to change the shards", id, strings.Join(credentialFields, ", "))
+		}
+		if !reflect.DeepEqual(old, shard) {
+			rotated = append(rotated, id)
+		}
+	}
+	return rotated, nil
+}
+
+// checkShardCredentials connects to the shards whose credentials changed, so
+// that the writer jobs are not relaunched with wrong credentials. Shards using
+// a secret reference are not checked, as the secret is resolved by the writer
+// jobs.
+func (cfg *config) checkShardCredentials(ctx context.Context, shards []interface{}, rotated []string) error {
+	isRotated := make(map[string]bool)
+	for _, id := range rotated {
+		isRotated[id] = true
+	}
+	for _, s := range shards {
+		shard := s.(map[string]interface{})
+		id, _ := shard["logicalShardId"].(string)
+		if !isRotated[id] {
+			continue
+		}
+		if uri, _ := shard["secretManagerUri"].(string); uri != "" {
+			logger.FromContext(ctx).Info(fmt.Sprintf("shard %s reads its password from %s, skipping the connection check", id, uri))
+			continue
+		}
+		connStr, err := cfg.getShardConnectionString(shard)
+		if err != nil {
+			return err
+		}
+		db, err := sql.Open(cfg.getShardDriverName(), connStr)
+		if err != nil {
+			return fmt.Errorf("could not connect to shard %s with the new credentials: %v", id, err)
+		}
+		err = db.PingContext(ctx)
+		db.Close()
+		if err != nil {
+			return fmt.Errorf("could not connect to shard %s with the new credentials: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// getCredentialRotationsTableDdl returns the statement creating the credential
+// rotations table in a metadata database of the given dialect.
+func getCredentialRotationsTableDdl(dialect string) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+	"JobNamePrefix" VARCHAR NOT NULL,
+	"RotatedAt" SPANNER.COMMIT_TIMESTAMP NOT NULL,
+	"ShardIds" VARCHAR NOT NULL,
+	"SourceShardsFilePath" VARCHAR NOT NULL,
+	PRIMARY KEY ("JobNamePrefix", "RotatedAt")
+)`, CREDENTIAL_ROTATIONS_TABLE)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	JobNamePrefix STRING(MAX) NOT NULL,
+	RotatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+	ShardIds STRING(MAX) NOT NULL,
+	SourceShardsFilePath STRING(MAX) NOT NULL,
+) PRIMARY KEY (JobNamePrefix, RotatedAt)`, CREDENTIAL_ROTATIONS_TABLE)
+}
+
+// recordCredentialRotation stores the ids of the shards whose credentials
+// were rotated, and the shards file holding the new credentials, in the
+// credential rotations table of the metadata database. The credentials
+// themselves are not recorded.
+func (cfg *config) recordCredentialRotation(ctx context.Context, rotated []string) error {
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	store, err := getClients(ctx).openMetadataStore(ctx, cfg, adminClient)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return store.RecordCredentialRotation(ctx, rotated)
+}
+
+// rotateSourceCredentials moves the writer jobs of the running pipeline to the
+// credentials of newSourceShardsFilePath, which must list the same shards as
+// sourceShardsFilePath with only their credentials changed. The new
+// credentials are checked against the shards before the writer jobs are
+// drained and relaunched, and the rotation is recorded in the metadata
+// database. The old credentials must stay valid until the writer jobs are
+// relaunched.
+func (cfg *config) rotateSourceCredentials(ctx context.Context) error {
+	deadline := time.Now().Add(cfg.updateShardsTimeout)
+	oldShards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return err
+	}
+	newShards, err := readShardsFile(ctx, cfg.newSourceShardsFilePath)
+	if err != nil {
+		return err
+	}
+	rotated, err := cfg.getRotatedShards(oldShards, newShards)
+	if err != nil {
+		return err
+	}
+	if len(rotated) == 0 {
+		return fmt.Errorf("the credentials of the shards in %s are the same as in %s", cfg.newSourceShardsFilePath, cfg.sourceShardsFilePath)
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Rotating the credentials of %d shard(s): %s", len(rotated), strings.Join(rotated, ", ")))
+	if err := cfg.checkShardCredentials(ctx, newShards, rotated); err != nil {
+		return err
+	}
+	oldGroupCount := len(partitionShards(oldShards, cfg.writerFanOut))
+	writerJobs, err := cfg.getRunningJobs(ctx, cfg.getWriterJobNames(oldGroupCount))
+	if err != nil {
+		return err
+	}
+	if err := cfg.relaunchWriterJobs(ctx, writerJobs, oldGroupCount, newShards, deadline); err != nil {
+		return err
+	}
+	if err := cfg.recordCredentialRotation(ctx, rotated); err != nil {
+		logger.FromContext(ctx).Error(fmt.Sprintf("Error in recording the credential rotation: %v", err))
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Credentials rotated. Please use %s as sourceShardsFilePath from now on, and revoke the previous credentials.", cfg.newSourceShardsFilePath))
+	return nil
+}
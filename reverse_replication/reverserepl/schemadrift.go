@@ -0,0 +1,231 @@
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/ddl"
+)
+
+// Kinds of differences between the live Spanner schema and the session file.
+const (
+	SCHEMA_DRIFT_TABLE_ADDED    = "table added"
+	SCHEMA_DRIFT_TABLE_DROPPED  = "table dropped"
+	SCHEMA_DRIFT_COLUMN_ADDED   = "column added"
+	SCHEMA_DRIFT_COLUMN_DROPPED = "column dropped"
+	SCHEMA_DRIFT_TYPE_CHANGED   = "type changed"
+)
+
+// SchemaDrift is a difference between the schema of a replicated database
+// and the Spanner schema of the session file used by the pipeline.
+type SchemaDrift struct {
+	Database string
+	Table    string
+	// Empty for the table level differences.
+	Column string
+	Kind   string
+	// The effect of the difference on the pipeline.
+	Detail string
+}
+
+// getSpannerTypeName returns the type t as written in the DDL of dialect.
+func getSpannerTypeName(dialect string, t ddl.Type) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return t.PGPrintColumnDefType()
+	}
+	return t.PrintColumnDefType()
+}
+
+// compareSpannerSchemas returns the differences of the live schema of the
+// database db from the session schema, ordered by table and column.
+func compareSpannerSchemas(db, dialect string, session, live ddl.Schema) []SchemaDrift {
+	byName := func(s ddl.Schema) map[string]ddl.CreateTable {
+		tables := make(map[string]ddl.CreateTable)
+		for _, t := range s {
+			tables[t.Name] = t
+		}
+		return tables
+	}
+	colsByName := func(t ddl.CreateTable) map[string]ddl.ColumnDef {
+		cols := make(map[string]ddl.ColumnDef)
+		for _, c := range t.ColDefs {
+			cols[c.Name] = c
+		}
+		return cols
+	}
+	sessionTables, liveTables := byName(session), byName(live)
+	var drifts []SchemaDrift
+	for name := range liveTables {
+		if _, ok := sessionTables[name]; !ok {
+			drifts = append(drifts, SchemaDrift{Database: db, Table: name, Kind: SCHEMA_DRIFT_TABLE_ADDED,
+				Detail: "the table is not in the session file, its changes are not replicated"})
+		}
+	}
+	for name, sessionTable := range sessionTables {
+		liveTable, ok := liveTables[name]
+		if !ok {
+			drifts = append(drifts, SchemaDrift{Database: db, Table: name, Kind: SCHEMA_DRIFT_TABLE_DROPPED,
+				Detail: "the table of the session file no longer exists in Spanner"})
+			continue
+		}
+		sessionCols, liveCols := colsByName(sessionTable), colsByName(liveTable)
+		for col := range liveCols {
+			if _, ok := sessionCols[col]; !ok {
+				drifts = append(drifts, SchemaDrift{Database: db, Table: name, Column: col, Kind: SCHEMA_DRIFT_COLUMN_ADDED,
+					Detail: "the column is not in the session file, its values are not replicated"})
+			}
+		}
+		for col, sessionCol := range sessionCols {
+			liveCol, ok := liveCols[col]
+			if !ok {
+				drifts = append(drifts, SchemaDrift{Database: db, Table: name, Column: col, Kind: SCHEMA_DRIFT_COLUMN_DROPPED,
+					Detail: "the column of the session file no longer exists in Spanner, the writer may fail on the changes of the table"})
+				continue
+			}
+			if liveCol.T != sessionCol.T {
+				drifts = append(drifts, SchemaDrift{Database: db, Table: name, Column: col, Kind: SCHEMA_DRIFT_TYPE_CHANGED,
+					Detail: fmt.Sprintf("the column is %s in Spanner but %s in the session file, the writer may fail to convert its values",
+						getSpannerTypeName(dialect, liveCol.T), getSpannerTypeName(dialect, sessionCol.T))})
+			}
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool {
+		if drifts[i].Table != drifts[j].Table {
+			return drifts[i].Table < drifts[j].Table
+		}
+		return drifts[i].Column < drifts[j].Column
+	})
+	return drifts
+}
+
+// readSessionConv reads the session file at the gcs or local path.
+func readSessionConv(ctx context.Context, path string) (*internal.Conv, error) {
+	sessionJSON, err := readFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	conv := internal.MakeConv()
+	if err := json.Unmarshal(sessionJSON, conv); err != nil {
+		return nil, fmt.Errorf("could not parse session file %s: %v", path, err)
+	}
+	return conv, nil
+}
+
+// readLiveConv reads the schema of the database db from Spanner, as both the
+// source and Spanner schema of the returned conv.
+func (cfg *config) readLiveConv(ctx context.Context, adminClient *database.DatabaseAdminClient, db string) (*internal.Conv, error) {
+	dbUri := cfg.getDbUri(db)
+	dialect, err := spanneradmin.GetDatabaseDialect(ctx, adminClient, dbUri)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the database dialect: %v", err)
+	}
+	spClient, err := getClients(ctx).NewSpannerClient(ctx, dbUri)
+	if err != nil {
+		return nil, fmt.Errorf("could not create spanner client for %s: %v", dbUri, err)
+	}
+	defer spClient.Close()
+	conv := internal.MakeConv()
+	conv.SpDialect = dialect
+	if err := utils.ReadSpannerSchema(ctx, conv, spClient); err != nil {
+		return nil, fmt.Errorf("can't read spanner schema of %s: %v", dbUri, err)
+	}
+	return conv, nil
+}
+
+// getSchemaDrift compares the live schema of every database in dbs with the
+// Spanner schema of the session file at sessionPath.
+func (cfg *config) getSchemaDrift(ctx context.Context, dbs []string, sessionPath string) ([]SchemaDrift, error) {
+	sessionConv, err := readSessionConv(ctx, sessionPath)
+	if err != nil {
+		return nil, err
+	}
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	var drifts []SchemaDrift
+	for _, db := range dbs {
+		liveConv, err := cfg.readLiveConv(ctx, adminClient, db)
+		if err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, compareSpannerSchemas(db, liveConv.SpDialect, sessionConv.SpSchema, liveConv.SpSchema)...)
+	}
+	return drifts, nil
+}
+
+// logSchemaDrift logs the differences found by getSchemaDrift.
+func (cfg *config) logSchemaDrift(ctx context.Context, drifts []SchemaDrift) {
+	if len(drifts) == 0 {
+		logger.FromContext(ctx).Info("The schema of the replicated databases matches the session file")
+		return
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "The schema of the replicated databases differs from the session file %s in %d place(s):\n", cfg.sessionFilePath, len(drifts))
+	for _, d := range drifts {
+		name := d.Table
+		if d.Column != "" {
+			name += "." + d.Column
+		}
+		fmt.Fprintf(&msg, "  %s: %s %s: %s\n", d.Database, d.Kind, name, d.Detail)
+	}
+	msg.WriteString("Please update the session file and the source schema, then apply the session update with -applySessionUpdate to replicate the new schema.")
+	logger.FromContext(ctx).Warn(msg.String())
+}
+
+// runSchemaDriftCheck compares the schema of the replicated databases with
+// the session file, and repeats the comparison every schemaDriftEvery while
+// the pipeline is running if set. A failed comparison is reported and retried
+// at the next interval. A single comparison finding differences also writes
+// the proposed source statements and session file update.
+func (cfg *config) runSchemaDriftCheck(ctx context.Context) error {
+	dbs := cfg.getDatabaseIds()
+	if cfg.schemaDriftEvery == 0 {
+		drifts, err := cfg.getSchemaDrift(ctx, dbs, cfg.sessionFilePath)
+		if err != nil {
+			return err
+		}
+		cfg.logSchemaDrift(ctx, drifts)
+		if len(drifts) == 0 {
+			return nil
+		}
+		if cfg.sourceType != constants.MYSQL {
+			logger.FromContext(ctx).Info(fmt.Sprintf("The source statements and session file update can only be proposed for %s sources", constants.MYSQL))
+			return nil
+		}
+		// The databases of a pipeline share the schema of the session file.
+		return cfg.writeSessionUpdateProposal(ctx, drifts[0].Database)
+	}
+	for {
+		logger.FromContext(ctx).Info(fmt.Sprintf("Checking the schema drift at %s", time.Now().UTC().Format(time.RFC3339)))
+		drifts, err := cfg.getSchemaDrift(ctx, dbs, cfg.sessionFilePath)
+		if err != nil {
+			logger.FromContext(ctx).Error(fmt.Sprintf("Error in checking the schema drift: %v", err))
+		} else {
+			cfg.logSchemaDrift(ctx, drifts)
+		}
+		active, err := cfg.isPipelineActive(ctx, dbs)
+		if err != nil {
+			logger.FromContext(ctx).Error(fmt.Sprintf("Error in checking the pipeline jobs: %v", err))
+		} else if !active {
+			logger.FromContext(ctx).Info(fmt.Sprintf("Pipeline %s is no longer running, stopping the schema drift checks", cfg.jobNamePrefix))
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(cfg.schemaDriftEvery):
+		}
+	}
+}
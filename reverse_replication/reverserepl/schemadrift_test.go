@@ -0,0 +1,95 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareSpannerSchemas(t *testing.T) {
+	orders := testTable{id: "t1", name: "orders", cols: []testColumn{
+		{id: "c1", name: "id", spType: testInt64Col},
+		{id: "c2", name: "customer", spType: testStringCol},
+	}}
+	// The ids of the live schema differ from the session file, as they are
+	// generated when reading it.
+	liveOrders := testTable{id: "t5", name: "orders", cols: []testColumn{
+		{id: "c5", name: "id", spType: testInt64Col},
+		{id: "c6", name: "customer", spType: testStringCol},
+	}}
+	maxString := ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
+	tests := []struct {
+		name    string
+		dialect string
+		session testTable
+		live    []testTable
+		want    []SchemaDrift
+	}{
+		{
+			name:    "no drift",
+			dialect: constants.DIALECT_GOOGLESQL,
+			session: orders,
+			live:    []testTable{liveOrders},
+		},
+		{
+			name:    "tables and columns added and dropped",
+			dialect: constants.DIALECT_GOOGLESQL,
+			session: orders,
+			live: []testTable{
+				{id: "t5", name: "orders", cols: []testColumn{liveOrders.cols[0], {id: "c7", name: "status", spType: testStringCol}}},
+				{id: "t6", name: "invoices", cols: []testColumn{{id: "c8", name: "id", spType: testInt64Col}}},
+			},
+			want: []SchemaDrift{
+				{Database: "orders", Table: "invoices", Kind: SCHEMA_DRIFT_TABLE_ADDED, Detail: "the table is not in the session file, its changes are not replicated"},
+				{Database: "orders", Table: "orders", Column: "customer", Kind: SCHEMA_DRIFT_COLUMN_DROPPED, Detail: "the column of the session file no longer exists in Spanner, the writer may fail on the changes of the table"},
+				{Database: "orders", Table: "orders", Column: "status", Kind: SCHEMA_DRIFT_COLUMN_ADDED, Detail: "the column is not in the session file, its values are not replicated"},
+			},
+		},
+		{
+			name:    "table dropped",
+			dialect: constants.DIALECT_GOOGLESQL,
+			session: orders,
+			want: []SchemaDrift{
+				{Database: "orders", Table: "orders", Kind: SCHEMA_DRIFT_TABLE_DROPPED, Detail: "the table of the session file no longer exists in Spanner"},
+			},
+		},
+		{
+			name:    "type changed",
+			dialect: constants.DIALECT_GOOGLESQL,
+			session: orders,
+			live:    []testTable{{id: "t5", name: "orders", cols: []testColumn{liveOrders.cols[0], {id: "c6", name: "customer", spType: maxString}}}},
+			want: []SchemaDrift{
+				{Database: "orders", Table: "orders", Column: "customer", Kind: SCHEMA_DRIFT_TYPE_CHANGED, Detail: "the column is STRING(MAX) in Spanner but STRING(255) in the session file, the writer may fail to convert its values"},
+			},
+		},
+		{
+			name:    "type changed in a PostgreSQL database",
+			dialect: constants.DIALECT_POSTGRESQL,
+			session: orders,
+			live:    []testTable{{id: "t5", name: "orders", cols: []testColumn{liveOrders.cols[0], {id: "c6", name: "customer", spType: maxString}}}},
+			want: []SchemaDrift{
+				{Database: "orders", Table: "orders", Column: "customer", Kind: SCHEMA_DRIFT_TYPE_CHANGED, Detail: "the column is VARCHAR(2621440) in Spanner but VARCHAR(255) in the session file, the writer may fail to convert its values"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		drifts := compareSpannerSchemas("orders", tc.dialect, newTestConv(tc.session).SpSchema, newTestConv(tc.live...).SpSchema)
+		assert.Equal(t, tc.want, drifts, tc.name)
+	}
+}
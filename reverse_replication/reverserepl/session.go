@@ -0,0 +1,145 @@
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/schema"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/ddl"
+)
+
+// Longest MySQL varchar and varbinary, in characters and bytes, mapped from
+// Spanner strings and bytes. Longer or unbounded ones become longtext and
+// longblob.
+const (
+	MYSQL_MAX_VARCHAR_LENGTH   = 16383
+	MYSQL_MAX_VARBINARY_LENGTH = 65535
+)
+
+// GenerateSessionFromSpanner returns a best effort session file for the Spanner
+// database at dbURI, for databases which were not migrated with Spanner
+// migration tool, along with the notes on the mappings to review before using
+// it for reverse replication. The source tables and columns get the Spanner
+// names, and the source types are the closest sourceType equivalents of the
+// Spanner types. A migration_shard_id column is used as the shard id column of
+// its table. Only mysql sources are supported.
+func GenerateSessionFromSpanner(ctx context.Context, dbURI, sourceType string) ([]byte, []string, error) {
+	if sourceType != constants.MYSQL {
+		return nil, nil, fmt.Errorf("can't generate a session file for %s sources, only %s is supported", sourceType, constants.MYSQL)
+	}
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	dialect, err := spanneradmin.GetDatabaseDialect(ctx, adminClient, dbURI)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get the database dialect: %v", err)
+	}
+	spClient, err := getClients(ctx).NewSpannerClient(ctx, dbURI)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create spanner client for %s: %v", dbURI, err)
+	}
+	defer spClient.Close()
+	conv := internal.MakeConv()
+	conv.SpDialect = dialect
+	if err := utils.ReadSpannerSchema(ctx, conv, spClient); err != nil {
+		return nil, nil, fmt.Errorf("can't read spanner schema: %v", err)
+	}
+	notes := mapSpannerSchemaToMySQL(conv)
+	b, err := json.MarshalIndent(conv, "", " ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't encode session state to JSON: %v", err)
+	}
+	return b, notes, nil
+}
+
+// mapSpannerSchemaToMySQL replaces the Spanner types of the source schema of
+// conv, read from Spanner, by MySQL types, and sets the shard id column of the
+// tables with a migration_shard_id column. It returns the notes on the
+// mappings to review, ordered by table and column.
+func mapSpannerSchemaToMySQL(conv *internal.Conv) []string {
+	var notes []string
+	for tableId, srcTable := range conv.SrcSchema {
+		spTable := conv.SpSchema[tableId]
+		var colIds []string
+		for _, colId := range srcTable.ColIds {
+			col := srcTable.ColDefs[colId]
+			// The shard id column only exists in Spanner.
+			if col.Name == internal.ShardIdColumn {
+				spTable.ShardIdColumn = colId
+				conv.IsSharded = true
+				delete(srcTable.ColDefs, colId)
+				continue
+			}
+			t, note := toMySQLType(col.Type)
+			if note != "" {
+				notes = append(notes, fmt.Sprintf("%s.%s: %s", srcTable.Name, col.Name, note))
+			}
+			col.Type = t
+			srcTable.ColDefs[colId] = col
+			colIds = append(colIds, colId)
+		}
+		srcTable.ColIds = colIds
+		var keys []schema.Key
+		for _, k := range srcTable.PrimaryKeys {
+			if _, ok := srcTable.ColDefs[k.ColId]; ok {
+				keys = append(keys, k)
+			}
+		}
+		srcTable.PrimaryKeys = keys
+		conv.SrcSchema[tableId] = srcTable
+		conv.SpSchema[tableId] = spTable
+	}
+	sort.Strings(notes)
+	return notes
+}
+
+// toMySQLType returns the MySQL type closest to the Spanner type t, of either
+// dialect, and a note if values may not round trip.
+func toMySQLType(t schema.Type) (schema.Type, string) {
+	name := strings.ToLower(t.Name)
+	if len(t.ArrayBounds) > 0 || strings.HasSuffix(name, "[]") {
+		return schema.Type{Name: "json"}, fmt.Sprintf("arrays have no MySQL equivalent, the %s array is mapped to json", strings.TrimSuffix(t.Name, "[]"))
+	}
+	length := int64(ddl.MaxLength)
+	if len(t.Mods) > 0 {
+		length = t.Mods[0]
+	}
+	switch name {
+	case "bool", "boolean":
+		return schema.Type{Name: "bool"}, ""
+	case "int64", "bigint":
+		return schema.Type{Name: "bigint"}, ""
+	case "float64", "double precision":
+		return schema.Type{Name: "double"}, ""
+	case "float32", "real":
+		return schema.Type{Name: "float"}, ""
+	case "numeric":
+		return schema.Type{Name: "decimal", Mods: []int64{38, 9}}, "mapped to decimal(38,9), please check the precision and scale used at the source"
+	case "string", "character varying":
+		if length == ddl.MaxLength || length > MYSQL_MAX_VARCHAR_LENGTH {
+			return schema.Type{Name: "longtext"}, ""
+		}
+		return schema.Type{Name: "varchar", Mods: []int64{length}}, ""
+	case "bytes", "bytea":
+		if length == ddl.MaxLength || length > MYSQL_MAX_VARBINARY_LENGTH {
+			return schema.Type{Name: "longblob"}, ""
+		}
+		return schema.Type{Name: "varbinary", Mods: []int64{length}}, ""
+	case "date":
+		return schema.Type{Name: "date"}, ""
+	case "timestamp", "timestamp with time zone":
+		return schema.Type{Name: "datetime", Mods: []int64{6}}, "mapped to datetime(6) in UTC, please check whether the source uses timestamp or another timezone"
+	case "json", "jsonb":
+		return schema.Type{Name: "json"}, ""
+	}
+	return schema.Type{Name: "longtext"}, fmt.Sprintf("%s has no MySQL equivalent, mapped to longtext", t.Name)
+}
@@ -0,0 +1,274 @@
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/schema"
+)
+
+// printMySQLType returns the MySQL DDL of the type t, e.g. varchar(255).
+func printMySQLType(t schema.Type) string {
+	if len(t.Mods) == 0 {
+		return t.Name
+	}
+	var mods []string
+	for _, m := range t.Mods {
+		mods = append(mods, fmt.Sprint(m))
+	}
+	return fmt.Sprintf("%s(%s)", t.Name, strings.Join(mods, ","))
+}
+
+func quoteMySQLIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// printMySQLColumn returns the definition of the column col in a MySQL
+// CREATE or ALTER TABLE statement.
+func printMySQLColumn(col schema.Column) string {
+	s := quoteMySQLIdentifier(col.Name) + " " + printMySQLType(col.Type)
+	if col.NotNull {
+		s += " NOT NULL"
+	}
+	return s
+}
+
+// getSpTableIdsByName maps the name of every Spanner table of conv to its id.
+func getSpTableIdsByName(conv *internal.Conv) map[string]string {
+	ids := make(map[string]string)
+	for id, t := range conv.SpSchema {
+		ids[t.Name] = id
+	}
+	return ids
+}
+
+// getSpColIdsByName maps the name of every column of the Spanner table of conv
+// with the given id to its id.
+func getSpColIdsByName(conv *internal.Conv, tableId string) map[string]string {
+	ids := make(map[string]string)
+	for id, c := range conv.SpSchema[tableId].ColDefs {
+		ids[c.Name] = id
+	}
+	return ids
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// getSessionUpdate updates the session to the Spanner schema of live, a
+// database schema mapped to MySQL by mapSpannerSchemaToMySQL, and returns the
+// statements applying the same changes to the MySQL source shards. Existing
+// tables and columns keep their source names. Dropping tables and columns at
+// the source is left to the user, as the statements are commented out.
+func getSessionUpdate(session, live *internal.Conv) []string {
+	var stmts []string
+	sessionTables, liveTables := getSpTableIdsByName(session), getSpTableIdsByName(live)
+	for _, name := range sortedKeys(liveTables) {
+		liveId := liveTables[name]
+		sessionId, ok := sessionTables[name]
+		if !ok {
+			// The ids generated when reading the live schema are unique in the
+			// session as well.
+			session.SpSchema[liveId] = live.SpSchema[liveId]
+			srcTable := live.SrcSchema[liveId]
+			session.SrcSchema[liveId] = srcTable
+			var defs []string
+			for _, colId := range srcTable.ColIds {
+				defs = append(defs, "\t"+printMySQLColumn(srcTable.ColDefs[colId]))
+			}
+			var keys []string
+			for _, k := range srcTable.PrimaryKeys {
+				keys = append(keys, quoteMySQLIdentifier(srcTable.ColDefs[k.ColId].Name))
+			}
+			if len(keys) > 0 {
+				defs = append(defs, fmt.Sprintf("\tPRIMARY KEY (%s)", strings.Join(keys, ", ")))
+			}
+			stmts = append(stmts, fmt.Sprintf("CREATE TABLE %s (\n%s\n)", quoteMySQLIdentifier(srcTable.Name), strings.Join(defs, ",\n")))
+			continue
+		}
+		spTable, srcTable := session.SpSchema[sessionId], session.SrcSchema[sessionId]
+		liveSpTable, liveSrcTable := live.SpSchema[liveId], live.SrcSchema[liveId]
+		alter := "ALTER TABLE " + quoteMySQLIdentifier(srcTable.Name)
+		sessionCols, liveCols := getSpColIdsByName(session, sessionId), getSpColIdsByName(live, liveId)
+		for _, col := range sortedKeys(liveCols) {
+			liveColId := liveCols[col]
+			sessionColId, ok := sessionCols[col]
+			if !ok {
+				spTable.ColIds = append(spTable.ColIds, liveColId)
+				spTable.ColDefs[liveColId] = liveSpTable.ColDefs[liveColId]
+				if liveSpTable.ShardIdColumn == liveColId {
+					spTable.ShardIdColumn = liveColId
+				}
+				srcCol, ok := liveSrcTable.ColDefs[liveColId]
+				if !ok {
+					continue
+				}
+				// Existing rows have no value for the column at the source.
+				srcCol.NotNull = false
+				srcTable.ColIds = append(srcTable.ColIds, liveColId)
+				srcTable.ColDefs[liveColId] = srcCol
+				stmts = append(stmts, fmt.Sprintf("%s ADD COLUMN %s", alter, printMySQLColumn(srcCol)))
+				continue
+			}
+			if spTable.ColDefs[sessionColId].T == liveSpTable.ColDefs[liveColId].T {
+				continue
+			}
+			spCol := spTable.ColDefs[sessionColId]
+			spCol.T = liveSpTable.ColDefs[liveColId].T
+			spTable.ColDefs[sessionColId] = spCol
+			srcCol, ok := srcTable.ColDefs[sessionColId]
+			if !ok {
+				continue
+			}
+			srcCol.Type = liveSrcTable.ColDefs[liveColId].Type
+			srcTable.ColDefs[sessionColId] = srcCol
+			stmts = append(stmts, fmt.Sprintf("%s MODIFY COLUMN %s", alter, printMySQLColumn(srcCol)))
+		}
+		for _, col := range sortedKeys(sessionCols) {
+			if _, ok := liveCols[col]; ok {
+				continue
+			}
+			colId := sessionCols[col]
+			spTable.ColIds = removeId(spTable.ColIds, colId)
+			delete(spTable.ColDefs, colId)
+			if srcCol, ok := srcTable.ColDefs[colId]; ok {
+				srcTable.ColIds = removeId(srcTable.ColIds, colId)
+				delete(srcTable.ColDefs, colId)
+				stmts = append(stmts, fmt.Sprintf("-- Optional, the column is no longer written to: %s DROP COLUMN %s", alter, quoteMySQLIdentifier(srcCol.Name)))
+			}
+		}
+		session.SpSchema[sessionId] = spTable
+		session.SrcSchema[sessionId] = srcTable
+	}
+	for _, name := range sortedKeys(sessionTables) {
+		if _, ok := liveTables[name]; ok {
+			continue
+		}
+		id := sessionTables[name]
+		srcName := session.SrcSchema[id].Name
+		delete(session.SpSchema, id)
+		delete(session.SrcSchema, id)
+		stmts = append(stmts, fmt.Sprintf("-- Optional, the table is no longer written to: DROP TABLE %s", quoteMySQLIdentifier(srcName)))
+	}
+	return stmts
+}
+
+func removeId(ids []string, id string) []string {
+	var kept []string
+	for _, i := range ids {
+		if i != id {
+			kept = append(kept, i)
+		}
+	}
+	return kept
+}
+
+// getSourceDdlFilePath returns the file the statements applying the schema
+// drift to the source shards are written to, in the current directory.
+func (cfg *config) getSourceDdlFilePath() string {
+	return fmt.Sprintf("%s-source-ddl.sql", cfg.jobNamePrefix)
+}
+
+// getSessionUpdateFilePath returns the file the session file updated to the
+// schema drift is written to, in the current directory.
+func (cfg *config) getSessionUpdateFilePath() string {
+	return fmt.Sprintf("%s-session.json", cfg.jobNamePrefix)
+}
+
+// writeSessionUpdateProposal writes the MySQL statements applying the schema
+// drift of the database db to the source shards, and the session file updated
+// to its live schema, to the current directory.
+func (cfg *config) writeSessionUpdateProposal(ctx context.Context, db string) error {
+	session, err := readSessionConv(ctx, cfg.sessionFilePath)
+	if err != nil {
+		return err
+	}
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	live, err := cfg.readLiveConv(ctx, adminClient, db)
+	if err != nil {
+		return err
+	}
+	notes := mapSpannerSchemaToMySQL(live)
+	stmts := getSessionUpdate(session, live)
+	var sql strings.Builder
+	fmt.Fprintf(&sql, "-- Statements applying the schema changes of %s to every source shard of pipeline %s, generated at %s.\n", cfg.getDbUri(db), cfg.jobNamePrefix, time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&sql, "-- Please review them, and %s, before applying them.\n", cfg.getSessionUpdateFilePath())
+	for _, n := range notes {
+		fmt.Fprintf(&sql, "-- Note: %s\n", n)
+	}
+	for _, stmt := range stmts {
+		sql.WriteString("\n" + stmt + ";\n")
+	}
+	if err := ioutil.WriteFile(cfg.getSourceDdlFilePath(), []byte(sql.String()), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", cfg.getSourceDdlFilePath(), err)
+	}
+	b, err := json.MarshalIndent(session, "", " ")
+	if err != nil {
+		return fmt.Errorf("can't encode session state to JSON: %v", err)
+	}
+	if err := ioutil.WriteFile(cfg.getSessionUpdateFilePath(), b, 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", cfg.getSessionUpdateFilePath(), err)
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Wrote the statements applying the schema changes to the source shards to %s, and the updated session file to %s.", cfg.getSourceDdlFilePath(), cfg.getSessionUpdateFilePath()))
+	logger.FromContext(ctx).Info(fmt.Sprintf("Once reviewed and applied to every source shard, run the launcher with -applySessionUpdate -newSessionFilePath=%s to move the writer jobs to the updated session file.", cfg.getSessionUpdateFilePath()))
+	return nil
+}
+
+// applySessionUpdate moves the writer jobs of the running pipeline to
+// newSessionFilePath, once the schema changes were applied to the source
+// shards. The new session file must match the schema of the replicated
+// databases. The writer jobs are drained, the changes waiting in Pub/Sub
+// meanwhile, and relaunched with the new session file.
+func (cfg *config) applySessionUpdate(ctx context.Context) error {
+	deadline := time.Now().Add(cfg.updateShardsTimeout)
+	dbs := cfg.getDatabaseIds()
+	drifts, err := cfg.getSchemaDrift(ctx, dbs, cfg.newSessionFilePath)
+	if err != nil {
+		return err
+	}
+	if len(drifts) > 0 {
+		cfg.logSchemaDrift(ctx, drifts)
+		return fmt.Errorf("%s does not match the schema of the replicated databases. Please regenerate it with -detectSchemaDrift", cfg.newSessionFilePath)
+	}
+	shards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return err
+	}
+	groups := partitionShards(shards, cfg.writerFanOut)
+	writerJobs, err := cfg.getRunningJobs(ctx, cfg.getWriterJobNames(len(groups)))
+	if err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info("Draining the writer jobs...")
+	if err := cfg.drainJobs(ctx, writerJobs, deadline); err != nil {
+		return err
+	}
+	cfg.sessionFilePath = cfg.newSessionFilePath
+	c, err := getClients(ctx).NewDataflowAccessor(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create dataflow client: %v", err)
+	}
+	defer c.Close()
+	if _, err := cfg.launchWriterJobs(ctx, c, groups); err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Session updated. Please use %s as sessionFilePath from now on.", cfg.newSessionFilePath))
+	return nil
+}
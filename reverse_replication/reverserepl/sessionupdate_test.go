@@ -0,0 +1,168 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/schema"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+// testColumn is a column of the tables of newTestConv, with its Spanner and
+// MySQL types.
+type testColumn struct {
+	id      string
+	name    string
+	spType  ddl.Type
+	srcType schema.Type
+	notNull bool
+}
+
+// testTable is a table of newTestConv, keyed by the columns of pk.
+type testTable struct {
+	id   string
+	name string
+	cols []testColumn
+	pk   []string
+}
+
+var (
+	testInt64Col  = ddl.Type{Name: ddl.Int64}
+	testStringCol = ddl.Type{Name: ddl.String, Len: 255}
+	testBigint    = schema.Type{Name: "bigint"}
+	testVarchar   = schema.Type{Name: "varchar", Mods: []int64{255}}
+)
+
+// newTestConv returns a conv holding the Spanner and source schema of tables.
+func newTestConv(tables ...testTable) *internal.Conv {
+	conv := &internal.Conv{SpSchema: ddl.Schema{}, SrcSchema: map[string]schema.Table{}}
+	for _, t := range tables {
+		spTable := ddl.CreateTable{Name: t.name, Id: t.id, ColDefs: map[string]ddl.ColumnDef{}}
+		srcTable := schema.Table{Name: t.name, Id: t.id, ColDefs: map[string]schema.Column{}}
+		for _, c := range t.cols {
+			spTable.ColIds = append(spTable.ColIds, c.id)
+			spTable.ColDefs[c.id] = ddl.ColumnDef{Name: c.name, Id: c.id, T: c.spType, NotNull: c.notNull}
+			srcTable.ColIds = append(srcTable.ColIds, c.id)
+			srcTable.ColDefs[c.id] = schema.Column{Name: c.name, Id: c.id, Type: c.srcType, NotNull: c.notNull}
+		}
+		for _, k := range t.pk {
+			srcTable.PrimaryKeys = append(srcTable.PrimaryKeys, schema.Key{ColId: k})
+		}
+		conv.SpSchema[t.id] = spTable
+		conv.SrcSchema[t.id] = srcTable
+	}
+	return conv
+}
+
+// getTestSchema returns the columns of every Spanner table of conv by table
+// name, in order.
+func getTestSchema(conv *internal.Conv) map[string][]string {
+	tables := map[string][]string{}
+	for _, t := range conv.SpSchema {
+		var cols []string
+		for _, id := range t.ColIds {
+			cols = append(cols, t.ColDefs[id].Name+" "+t.ColDefs[id].T.PrintColumnDefType())
+		}
+		sort.Strings(cols)
+		tables[t.Name] = cols
+	}
+	return tables
+}
+
+func TestPrintMySQLColumn(t *testing.T) {
+	tests := []struct {
+		col  schema.Column
+		want string
+	}{
+		{col: schema.Column{Name: "id", Type: testBigint, NotNull: true}, want: "`id` bigint NOT NULL"},
+		{col: schema.Column{Name: "name", Type: testVarchar}, want: "`name` varchar(255)"},
+		{col: schema.Column{Name: "amount", Type: schema.Type{Name: "decimal", Mods: []int64{10, 2}}}, want: "`amount` decimal(10,2)"},
+		{col: schema.Column{Name: "odd`name", Type: testBigint}, want: "`odd``name` bigint"},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, printMySQLColumn(tc.col))
+	}
+}
+
+func TestGetSessionUpdate(t *testing.T) {
+	orders := testTable{id: "t1", name: "orders", pk: []string{"c1"}, cols: []testColumn{
+		{id: "c1", name: "id", spType: testInt64Col, srcType: testBigint, notNull: true},
+		{id: "c2", name: "customer", spType: testStringCol, srcType: testVarchar},
+	}}
+	tests := []struct {
+		name       string
+		session    *internal.Conv
+		live       *internal.Conv
+		wantStmts  []string
+		wantSchema map[string][]string
+	}{
+		{
+			name:       "no drift",
+			session:    newTestConv(orders),
+			live:       newTestConv(orders),
+			wantSchema: map[string][]string{"orders": {"customer STRING(255)", "id INT64"}},
+		},
+		{
+			name:    "added table",
+			session: newTestConv(orders),
+			live: newTestConv(orders, testTable{id: "t9", name: "invoices", pk: []string{"c9"}, cols: []testColumn{
+				{id: "c9", name: "id", spType: testInt64Col, srcType: testBigint, notNull: true},
+				{id: "c10", name: "total", spType: testInt64Col, srcType: testBigint},
+			}}),
+			wantStmts: []string{"CREATE TABLE `invoices` (\n\t`id` bigint NOT NULL,\n\t`total` bigint,\n\tPRIMARY KEY (`id`)\n)"},
+			wantSchema: map[string][]string{
+				"orders":   {"customer STRING(255)", "id INT64"},
+				"invoices": {"id INT64", "total INT64"},
+			},
+		},
+		{
+			name:    "added column is nullable at the source",
+			session: newTestConv(orders),
+			live: newTestConv(testTable{id: "t5", name: "orders", cols: append(orders.cols,
+				testColumn{id: "c7", name: "status", spType: testStringCol, srcType: testVarchar, notNull: true})}),
+			wantStmts:  []string{"ALTER TABLE `orders` ADD COLUMN `status` varchar(255)"},
+			wantSchema: map[string][]string{"orders": {"customer STRING(255)", "id INT64", "status STRING(255)"}},
+		},
+		{
+			name:    "changed column type",
+			session: newTestConv(orders),
+			live: newTestConv(testTable{id: "t5", name: "orders", cols: []testColumn{
+				orders.cols[0],
+				{id: "c8", name: "customer", spType: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, srcType: schema.Type{Name: "text"}},
+			}}),
+			wantStmts:  []string{"ALTER TABLE `orders` MODIFY COLUMN `customer` text"},
+			wantSchema: map[string][]string{"orders": {"customer STRING(MAX)", "id INT64"}},
+		},
+		{
+			name:    "dropped column and table are commented out",
+			session: newTestConv(orders, testTable{id: "t2", name: "legacy", cols: []testColumn{{id: "c3", name: "id", spType: testInt64Col, srcType: testBigint}}}),
+			live:    newTestConv(testTable{id: "t5", name: "orders", cols: orders.cols[:1]}),
+			wantStmts: []string{
+				"-- Optional, the column is no longer written to: ALTER TABLE `orders` DROP COLUMN `customer`",
+				"-- Optional, the table is no longer written to: DROP TABLE `legacy`",
+			},
+			wantSchema: map[string][]string{"orders": {"id INT64"}},
+		},
+	}
+	for _, tc := range tests {
+		stmts := getSessionUpdate(tc.session, tc.live)
+		assert.Equal(t, tc.wantStmts, stmts, tc.name)
+		assert.Equal(t, tc.wantSchema, getTestSchema(tc.session), tc.name)
+	}
+}
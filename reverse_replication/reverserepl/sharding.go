@@ -0,0 +1,202 @@
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+)
+
+// Built-in functions the ordering job assigns the changes to the source shards
+// with.
+const (
+	// The logical shard id is the value of the shard id column of the session
+	// file.
+	SHARDING_FUNCTION_IDENTITY = "identity"
+	// The shard is the shardingColumn value modulo the number of shards, in
+	// the order of the source shards file.
+	SHARDING_FUNCTION_MODULO = "modulo"
+	// The shard is the one of the range of shardingRanges the shardingColumn
+	// value falls into.
+	SHARDING_FUNCTION_RANGE_MAP = "rangeMap"
+)
+
+// shardRange is the range of sharding key values from start on, up to the
+// start of the next range, which is assigned to a shard.
+type shardRange struct {
+	Start          int64  `json:"start"`
+	LogicalShardId string `json:"logicalShardId"`
+}
+
+// shardingConfig is the sharding configuration generated for the ordering
+// job from the sharding flags.
+type shardingConfig struct {
+	Function string `json:"function"`
+	Column   string `json:"column"`
+	// The shards of the modulo function, in order.
+	LogicalShardIds []string `json:"logicalShardIds,omitempty"`
+	// The ranges of the rangeMap function, ordered by start.
+	Ranges []shardRange `json:"ranges,omitempty"`
+}
+
+// parseShardingRanges parses shardingRanges, a comma separated list of
+// <start>:<logicalShardId>, e.g. 0:shard1,1000000:shard2, and returns the
+// ranges ordered by start.
+func parseShardingRanges(value string) ([]shardRange, error) {
+	var ranges []shardRange
+	for _, r := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(r), ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid range %q, expected <start>:<logicalShardId>", r)
+		}
+		start, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start of range %q: %v", r, err)
+		}
+		ranges = append(ranges, shardRange{Start: start, LogicalShardId: parts[1]})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	for i := 1; i < len(ranges); i++ {
+		if ranges[i].Start == ranges[i-1].Start {
+			return nil, fmt.Errorf("ranges of %s and %s have the same start %d", ranges[i-1].LogicalShardId, ranges[i].LogicalShardId, ranges[i].Start)
+		}
+	}
+	return ranges, nil
+}
+
+// validateSharding checks the sharding flags.
+func (cfg *config) validateSharding() error {
+	switch cfg.shardingFunction {
+	case SHARDING_FUNCTION_IDENTITY:
+		if cfg.shardingColumn != "" || cfg.shardingRanges != "" {
+			return fmt.Errorf("shardingColumn and shardingRanges can't be used with the %s shardingFunction", SHARDING_FUNCTION_IDENTITY)
+		}
+		return nil
+	case SHARDING_FUNCTION_MODULO, SHARDING_FUNCTION_RANGE_MAP:
+	default:
+		return fmt.Errorf("please specify a valid shardingFunction. Supported values are %s, %s and %s", SHARDING_FUNCTION_IDENTITY, SHARDING_FUNCTION_MODULO, SHARDING_FUNCTION_RANGE_MAP)
+	}
+	if cfg.shardingColumn == "" {
+		return fmt.Errorf("please specify the shardingColumn holding the sharding key to use with the %s shardingFunction", cfg.shardingFunction)
+	}
+	// The ordering jobs keep routing the changes with the config written at
+	// launch, whose path is derived from sourceShardsFilePath, while
+	// relaunching the writer jobs moves the pipeline to
+	// newSourceShardsFilePath.
+	if cfg.updateShards || cfg.rotateCredentials {
+		return fmt.Errorf("updateShards and rotateCredentials can't be used with the %s shardingFunction, as the ordering jobs keep the sharding config written at launch. Please relaunch the pipeline with the new source shards file instead", cfg.shardingFunction)
+	}
+	if cfg.shardingFunction == SHARDING_FUNCTION_MODULO {
+		if cfg.shardingRanges != "" {
+			return fmt.Errorf("shardingRanges can only be used with the %s shardingFunction", SHARDING_FUNCTION_RANGE_MAP)
+		}
+		return nil
+	}
+	if cfg.shardingRanges == "" {
+		return fmt.Errorf("please specify the shardingRanges to use with the %s shardingFunction", SHARDING_FUNCTION_RANGE_MAP)
+	}
+	if _, err := parseShardingRanges(cfg.shardingRanges); err != nil {
+		return fmt.Errorf("invalid shardingRanges: %v", err)
+	}
+	return nil
+}
+
+// getShardingConfig returns the sharding configuration of the flags for the
+// shards of the source shards file.
+func (cfg *config) getShardingConfig(shardIds []string) (shardingConfig, error) {
+	sharding := shardingConfig{Function: cfg.shardingFunction, Column: cfg.shardingColumn}
+	if cfg.shardingFunction == SHARDING_FUNCTION_MODULO {
+		sharding.LogicalShardIds = shardIds
+		return sharding, nil
+	}
+	ranges, err := parseShardingRanges(cfg.shardingRanges)
+	if err != nil {
+		return sharding, err
+	}
+	known := make(map[string]bool)
+	for _, id := range shardIds {
+		known[id] = true
+	}
+	for _, r := range ranges {
+		if !known[r.LogicalShardId] {
+			return sharding, fmt.Errorf("shard %s of shardingRanges is not in %s", r.LogicalShardId, cfg.sourceShardsFilePath)
+		}
+	}
+	sharding.Ranges = ranges
+	return sharding, nil
+}
+
+// validateShardingColumn checks that every table of the session file has
+// shardingColumn.
+func (cfg *config) validateShardingColumn(sessionJSON []byte) error {
+	var session struct {
+		SpSchema map[string]struct {
+			Name    string
+			ColDefs map[string]struct {
+				Name string
+			}
+		}
+	}
+	if err := json.Unmarshal(sessionJSON, &session); err != nil {
+		return fmt.Errorf("could not parse session file: %v", err)
+	}
+	var missing []string
+	for _, t := range session.SpSchema {
+		found := false
+		for _, c := range t.ColDefs {
+			if c.Name == cfg.shardingColumn {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, t.Name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("shardingColumn %s is missing from the tables %s of the session file", cfg.shardingColumn, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// getShardingConfigFilePath returns the gcs path of the sharding configuration
+// read by the ordering jobs. It is placed next to the source shards file.
+func (cfg *config) getShardingConfigFilePath() string {
+	return fmt.Sprintf("%s-%s-sharding.json", strings.TrimSuffix(cfg.sourceShardsFilePath, ".json"), cfg.jobNamePrefix)
+}
+
+// writeShardingConfig generates the sharding configuration of the built-in
+// shardingFunction for the shards and uploads it to
+// getShardingConfigFilePath. Nothing is written for the identity function.
+func (cfg *config) writeShardingConfig(ctx context.Context, shardIds []string) error {
+	if cfg.shardingFunction == SHARDING_FUNCTION_IDENTITY {
+		return nil
+	}
+	sessionJSON, err := readGcsFile(ctx, cfg.sessionFilePath)
+	if err != nil {
+		return err
+	}
+	if err := cfg.validateShardingColumn(sessionJSON); err != nil {
+		return err
+	}
+	sharding, err := cfg.getShardingConfig(shardIds)
+	if err != nil {
+		return err
+	}
+	gcs, err := getClients(ctx).NewStorageAccessor(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create gcs client: %v", err)
+	}
+	defer gcs.Close()
+	path := cfg.getShardingConfigFilePath()
+	if err := writeGcsJsonFile(ctx, gcs, path, sharding); err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Wrote %s sharding config: %s", cfg.shardingFunction, path))
+	return nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateShardingRejectsShardChanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		flags       map[string]string
+		errContains string
+	}{
+		{
+			name:  "update shards with identity",
+			flags: map[string]string{"updateShards": "true"},
+		},
+		{
+			name:  "rotate credentials with identity",
+			flags: map[string]string{"rotateCredentials": "true"},
+		},
+		{
+			name:        "update shards with modulo",
+			flags:       map[string]string{"updateShards": "true", "shardingFunction": "modulo", "shardingColumn": "CustomerId"},
+			errContains: "updateShards and rotateCredentials can't be used with the modulo shardingFunction",
+		},
+		{
+			name:        "rotate credentials with rangeMap",
+			flags:       map[string]string{"rotateCredentials": "true", "shardingFunction": "rangeMap", "shardingColumn": "CustomerId", "shardingRanges": "0:shard1,1000:shard2"},
+			errContains: "updateShards and rotateCredentials can't be used with the rangeMap shardingFunction",
+		},
+		{
+			name:  "launch with modulo",
+			flags: map[string]string{"shardingFunction": "modulo", "shardingColumn": "CustomerId"},
+		},
+	}
+	for _, tc := range tests {
+		j := getTestJobData()
+		j.Flags = tc.flags
+		if tc.flags["updateShards"] != "" || tc.flags["rotateCredentials"] != "" {
+			j.Flags["newSourceShardsFilePath"] = "gs://my-bucket/shards-v2.json"
+		}
+		_, err := newConfig(j)
+		if tc.errContains == "" {
+			assert.Nil(t, err, tc.name)
+		} else if assert.NotNil(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.errContains, tc.name)
+		}
+	}
+}
+
+func TestParseShardingRanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		want        []shardRange
+		errContains string
+	}{
+		{
+			name:  "ordered by start",
+			value: "1000:shard2, -5:shard0,0:shard1",
+			want:  []shardRange{{Start: -5, LogicalShardId: "shard0"}, {Start: 0, LogicalShardId: "shard1"}, {Start: 1000, LogicalShardId: "shard2"}},
+		},
+		{
+			name:        "missing shard",
+			value:       "0:shard1,1000:",
+			errContains: `invalid range "1000:"`,
+		},
+		{
+			name:        "missing separator",
+			value:       "0",
+			errContains: `invalid range "0"`,
+		},
+		{
+			name:        "invalid start",
+			value:       "abc:shard1",
+			errContains: `invalid start of range "abc:shard1"`,
+		},
+		{
+			name:        "same start",
+			value:       "0:shard1,0:shard2",
+			errContains: "ranges of shard1 and shard2 have the same start 0",
+		},
+	}
+	for _, tc := range tests {
+		ranges, err := parseShardingRanges(tc.value)
+		if tc.errContains == "" {
+			assert.Nil(t, err, tc.name)
+			assert.Equal(t, tc.want, ranges, tc.name)
+		} else if assert.NotNil(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.errContains, tc.name)
+		}
+	}
+}
+
+func TestGetShardingConfig(t *testing.T) {
+	shardIds := []string{"shard1", "shard2", "shard3"}
+	tests := []struct {
+		name        string
+		cfg         config
+		want        shardingConfig
+		errContains string
+	}{
+		{
+			name: "modulo keeps the order of the shards",
+			cfg:  config{shardingFunction: SHARDING_FUNCTION_MODULO, shardingColumn: "CustomerId"},
+			want: shardingConfig{Function: SHARDING_FUNCTION_MODULO, Column: "CustomerId", LogicalShardIds: shardIds},
+		},
+		{
+			name: "rangeMap",
+			cfg:  config{shardingFunction: SHARDING_FUNCTION_RANGE_MAP, shardingColumn: "CustomerId", shardingRanges: "500:shard3,0:shard1"},
+			want: shardingConfig{Function: SHARDING_FUNCTION_RANGE_MAP, Column: "CustomerId", Ranges: []shardRange{{Start: 0, LogicalShardId: "shard1"}, {Start: 500, LogicalShardId: "shard3"}}},
+		},
+		{
+			name:        "rangeMap with an unknown shard",
+			cfg:         config{shardingFunction: SHARDING_FUNCTION_RANGE_MAP, shardingColumn: "CustomerId", shardingRanges: "0:shard1,500:shard4", sourceShardsFilePath: "gs://my-bucket/shards.json"},
+			errContains: "shard shard4 of shardingRanges is not in gs://my-bucket/shards.json",
+		},
+	}
+	for _, tc := range tests {
+		sharding, err := tc.cfg.getShardingConfig(shardIds)
+		if tc.errContains == "" {
+			assert.Nil(t, err, tc.name)
+			assert.Equal(t, tc.want, sharding, tc.name)
+		} else if assert.NotNil(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.errContains, tc.name)
+		}
+	}
+}
+
+func TestValidateShardingColumn(t *testing.T) {
+	session := `{"SpSchema": {
+		"t1": {"Name": "Customers", "ColDefs": {"c1": {"Name": "CustomerId"}, "c2": {"Name": "Name"}}},
+		"t2": {"Name": "Orders", "ColDefs": {"c3": {"Name": "OrderId"}}},
+		"t3": {"Name": "Invoices", "ColDefs": {"c4": {"Name": "InvoiceId"}}}
+	}}`
+	tests := []struct {
+		name        string
+		column      string
+		session     string
+		errContains string
+	}{
+		{
+			name:    "column in every table",
+			column:  "CustomerId",
+			session: `{"SpSchema": {"t1": {"Name": "Customers", "ColDefs": {"c1": {"Name": "CustomerId"}}}}}`,
+		},
+		{
+			name:        "missing tables are listed in order",
+			column:      "CustomerId",
+			session:     session,
+			errContains: "shardingColumn CustomerId is missing from the tables Invoices, Orders of the session file",
+		},
+		{
+			name:        "invalid session file",
+			column:      "CustomerId",
+			session:     "{",
+			errContains: "could not parse session file",
+		},
+	}
+	for _, tc := range tests {
+		cfg := config{shardingColumn: tc.column}
+		err := cfg.validateShardingColumn([]byte(tc.session))
+		if tc.errContains == "" {
+			assert.Nil(t, err, tc.name)
+		} else if assert.NotNil(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.errContains, tc.name)
+		}
+	}
+}
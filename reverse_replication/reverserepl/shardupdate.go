@@ -0,0 +1,241 @@
+package reverserepl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"cloud.google.com/go/pubsub"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+)
+
+// sortedShardIds returns the shard ids of the set in order.
+func sortedShardIds(ids map[string]bool) []string {
+	var sorted []string
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// validateNewShards checks that every shard of the new source shards file has
+// a unique logicalShardId and connection details, and that the added shards
+// can be connected to.
+func (cfg *config) validateNewShards(ctx context.Context, shards []interface{}, added map[string]bool) error {
+	if len(shards) == 0 {
+		return fmt.Errorf("%s does not list any shard", cfg.newSourceShardsFilePath)
+	}
+	shardIds, err := getLogicalShardIds(shards)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for i, id := range shardIds {
+		if id == "" || seen[id] {
+			return fmt.Errorf("shard at index %d of %s does not have a unique logicalShardId", i, cfg.newSourceShardsFilePath)
+		}
+		seen[id] = true
+		shard, ok := shards[i].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("shard at index %d is not a json object", i)
+		}
+		if err := cfg.getSourceAdapter().ValidateShard(shard); err != nil {
+			return err
+		}
+		// Only the config of the shards the launcher can't connect to is
+		// checked.
+		if cfg.getShardDriverName() == "" {
+			continue
+		}
+		connStr, err := cfg.getShardConnectionString(shard)
+		if err != nil {
+			return err
+		}
+		if !added[id] {
+			continue
+		}
+		db, err := sql.Open(cfg.getShardDriverName(), connStr)
+		if err != nil {
+			return fmt.Errorf("could not connect to shard %s: %v", id, err)
+		}
+		err = db.PingContext(ctx)
+		db.Close()
+		if err != nil {
+			return fmt.Errorf("could not connect to shard %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// diffShards returns the ids of the shards in newIds but not in oldIds, and of
+// the shards in oldIds but not in newIds.
+func diffShards(oldIds, newIds []string) (map[string]bool, map[string]bool) {
+	added := make(map[string]bool)
+	removed := make(map[string]bool)
+	for _, id := range newIds {
+		added[id] = true
+	}
+	for _, id := range oldIds {
+		if added[id] {
+			delete(added, id)
+		} else {
+			removed[id] = true
+		}
+	}
+	return added, removed
+}
+
+// deleteRemovedSubscriptions deletes the subscriptions of the removed shards
+// which Cloud Monitoring reports as empty. The others are kept so that their
+// pending changes are not lost, and are reported.
+func (cfg *config) deleteRemovedSubscriptions(ctx context.Context, client *pubsub.Client, removed map[string]bool) {
+	metricClient, err := getClients(ctx).NewMetricReader(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Warn(fmt.Sprintf("could not create monitoring client, keeping the subscriptions of the removed shards: %v", err))
+		return
+	}
+	defer metricClient.Close()
+	for _, id := range sortedShardIds(removed) {
+		backlog, reported, err := cfg.getSubscriptionBacklog(ctx, metricClient, id)
+		switch {
+		case err != nil:
+			logger.FromContext(ctx).Warn(fmt.Sprintf("Kept subscription %s of removed shard %s: %v", id, id, err))
+		case !reported:
+			logger.FromContext(ctx).Info(fmt.Sprintf("Kept subscription %s of removed shard %s, its backlog is unknown. Please delete it once empty", id, id))
+		case backlog > 0:
+			logger.FromContext(ctx).Info(fmt.Sprintf("Kept subscription %s of removed shard %s, with %d pending change(s). Please delete it once they are applied", id, id, backlog))
+		default:
+			sub := client.Subscription(id)
+			if err := gcp.Do(ctx, gcp.Call{Service: gcp.PUBSUB, Method: "DeleteSubscription", Resource: sub.String()}, sub.Delete); err != nil {
+				logger.FromContext(ctx).Warn(fmt.Sprintf("could not delete subscription %s of removed shard %s: %v", id, id, err))
+				continue
+			}
+			logger.FromContext(ctx).Info(fmt.Sprintf("Deleted subscription %s of removed shard %s", id, id))
+		}
+	}
+}
+
+// updateSourceShards moves the running pipeline from the shards of
+// sourceShardsFilePath to the ones of newSourceShardsFilePath. The ordering
+// jobs route the changes by shard id and keep running: the subscriptions of
+// the added shards are created, the writer jobs are drained and relaunched
+// with the new shards, and the subscriptions of the removed shards are
+// deleted once empty. Changes keep buffering in the subscriptions while no
+// writer job is running.
+func (cfg *config) updateSourceShards(ctx context.Context) error {
+	deadline := time.Now().Add(cfg.updateShardsTimeout)
+	oldShards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return err
+	}
+	oldIds, err := getLogicalShardIds(oldShards)
+	if err != nil {
+		return err
+	}
+	newShards, err := readShardsFile(ctx, cfg.newSourceShardsFilePath)
+	if err != nil {
+		return err
+	}
+	newIds, err := getLogicalShardIds(newShards)
+	if err != nil {
+		return err
+	}
+	added, removed := diffShards(oldIds, newIds)
+	if err := cfg.validateNewShards(ctx, newShards, added); err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Adding %d shard(s): %s", len(added), strings.Join(sortedShardIds(added), ", ")))
+	logger.FromContext(ctx).Info(fmt.Sprintf("Removing %d shard(s): %s", len(removed), strings.Join(sortedShardIds(removed), ", ")))
+
+	oldGroupCount := len(partitionShards(oldShards, cfg.writerFanOut))
+	writerJobs, err := cfg.getRunningJobs(ctx, cfg.getWriterJobNames(oldGroupCount))
+	if err != nil {
+		return err
+	}
+	client, err := getClients(ctx).NewPubsubClient(ctx, cfg.projectId)
+	if err != nil {
+		return fmt.Errorf("could not create pubsub client: %v", err)
+	}
+	defer client.Close()
+	// The subscriptions of the added shards must exist before the writer jobs
+	// are drained, so that their changes are buffered from then on.
+	for _, id := range sortedShardIds(added) {
+		if err := cfg.createShardSubscription(ctx, client, id); err != nil {
+			if !gcp.IsAlreadyExists(err) {
+				return fmt.Errorf("could not create subscription %s: %v", id, err)
+			}
+			if err := cfg.verifySubscription(ctx, client, id); err != nil {
+				return fmt.Errorf("subscription '%s' already exists, but is configured incorrectly: %v", id, err)
+			}
+			logger.FromContext(ctx).Info(fmt.Sprintf("subscription '%s' already exists, skipping creation", id))
+			continue
+		}
+		logger.FromContext(ctx).Info(fmt.Sprintf("Created Pub/Sub subscription: %s", id))
+	}
+
+	if err := cfg.relaunchWriterJobs(ctx, writerJobs, oldGroupCount, newShards, deadline); err != nil {
+		return err
+	}
+	cfg.deleteRemovedSubscriptions(ctx, client, removed)
+	logger.FromContext(ctx).Info(fmt.Sprintf("Shards updated. Please use %s as sourceShardsFilePath from now on.", cfg.newSourceShardsFilePath))
+	return nil
+}
+
+// relaunchWriterJobs drains the running writer jobs, launched for
+// oldGroupCount groups of the shards of sourceShardsFilePath, and relaunches
+// them for newShards, read from newSourceShardsFilePath. sourceShardsFilePath
+// then points to newSourceShardsFilePath, and the shards files of the drained
+// writer jobs are deleted.
+func (cfg *config) relaunchWriterJobs(ctx context.Context, writerJobs []*dataflowpb.Job, oldGroupCount int, newShards []interface{}, deadline time.Time) error {
+	logger.FromContext(ctx).Info("Draining the writer jobs...")
+	if err := cfg.drainJobs(ctx, writerJobs, deadline); err != nil {
+		return err
+	}
+	var oldGroupFiles []string
+	if oldGroupCount > 1 {
+		for i := 0; i < oldGroupCount; i++ {
+			oldGroupFiles = append(oldGroupFiles, cfg.getShardGroupFilePath(i))
+		}
+	}
+	// The shard group files of the new writer jobs are placed next to the new
+	// source shards file.
+	cfg.sourceShardsFilePath = cfg.newSourceShardsFilePath
+	c, err := getClients(ctx).NewDataflowAccessor(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create dataflow client: %v", err)
+	}
+	defer c.Close()
+	paths, err := cfg.launchWriterJobs(ctx, c, partitionShards(newShards, cfg.writerFanOut))
+	if err != nil {
+		return err
+	}
+
+	gcs, err := getClients(ctx).NewStorageAccessor(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create gcs client: %v", err)
+	}
+	defer gcs.Close()
+	newGroupFiles := make(map[string]bool)
+	for _, path := range paths {
+		newGroupFiles[path] = true
+	}
+	for _, path := range oldGroupFiles {
+		if newGroupFiles[path] {
+			continue
+		}
+		bucket, name, err := parseGcsObjectPath(path)
+		if err == nil {
+			err = gcs.DeleteObject(ctx, bucket, name)
+		}
+		if err != nil {
+			logger.FromContext(ctx).Warn(fmt.Sprintf("could not delete previous shards file %s: %v", path, err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffShards(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldIds  []string
+		newIds  []string
+		added   []string
+		removed []string
+	}{
+		{
+			name:   "unchanged",
+			oldIds: []string{"shard1", "shard2"},
+			newIds: []string{"shard2", "shard1"},
+		},
+		{
+			name:   "added",
+			oldIds: []string{"shard1"},
+			newIds: []string{"shard1", "shard3", "shard2"},
+			added:  []string{"shard2", "shard3"},
+		},
+		{
+			name:    "removed",
+			oldIds:  []string{"shard1", "shard2", "shard3"},
+			newIds:  []string{"shard2"},
+			removed: []string{"shard1", "shard3"},
+		},
+		{
+			name:    "replaced",
+			oldIds:  []string{"shard1", "shard2"},
+			newIds:  []string{"shard2", "shard4"},
+			added:   []string{"shard4"},
+			removed: []string{"shard1"},
+		},
+	}
+	for _, tc := range tests {
+		added, removed := diffShards(tc.oldIds, tc.newIds)
+		assert.Equal(t, tc.added, sortedShardIds(added), tc.name)
+		assert.Equal(t, tc.removed, sortedShardIds(removed), tc.name)
+	}
+}
+
+func TestValidateNewShards(t *testing.T) {
+	shard := func(id string) map[string]interface{} {
+		return map[string]interface{}{"logicalShardId": id, "host": "10.0.0.1", "port": "3306", "user": "root", "password": "secret", "dbName": "orders"}
+	}
+	tests := []struct {
+		name        string
+		shards      []interface{}
+		errContains string
+	}{
+		{
+			name:   "kept shards are not connected to",
+			shards: []interface{}{shard("shard1"), shard("shard2")},
+		},
+		{
+			name:        "no shards",
+			errContains: "gs://my-bucket/shards-v2.json does not list any shard",
+		},
+		{
+			name:        "duplicate shard id",
+			shards:      []interface{}{shard("shard1"), shard("shard1")},
+			errContains: "shard at index 1 of gs://my-bucket/shards-v2.json does not have a unique logicalShardId",
+		},
+		{
+			name:        "missing connection details",
+			shards:      []interface{}{shard("shard1"), map[string]interface{}{"logicalShardId": "shard2", "host": "10.0.0.2"}},
+			errContains: "shard shard2 does not have a port",
+		},
+	}
+	for _, tc := range tests {
+		cfg := config{sourceType: constants.MYSQL, newSourceShardsFilePath: "gs://my-bucket/shards-v2.json"}
+		err := cfg.validateNewShards(context.Background(), tc.shards, map[string]bool{})
+		if tc.errContains == "" {
+			assert.Nil(t, err, tc.name)
+		} else if assert.NotNil(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.errContains, tc.name)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package reverserepl
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+)
+
+// Approximate throughput of a single vCPU, in changes per second, used when
+// auto sizing the dataflow jobs. The writer job is bounded by the writes to
+// the source shards, and is much slower than the ordering job.
+const (
+	ORDERING_QPS_PER_VCPU = 1000
+	WRITER_QPS_PER_VCPU   = 100
+	// Factor between the initial and the maximum number of workers, leaving
+	// room for autoscaling on bursts of changes.
+	MAX_WORKERS_FACTOR = 3
+	// Table in the metadata database recording the worker sizing of every
+	// pipeline.
+	JOBS_TABLE = "ReverseReplicationJobs"
+)
+
+// workerSizing is the machine type and worker counts of the dataflow jobs.
+// Zero max workers leave the maximum to Dataflow.
+type workerSizing struct {
+	ShardCount         int     `json:"shardCount"`
+	WriteQpsPerShard   float64 `json:"writeQpsPerShard"`
+	MachineType        string  `json:"machineType"`
+	OrderingWorkers    int     `json:"orderingWorkers"`
+	OrderingMaxWorkers int     `json:"orderingMaxWorkers"`
+	WriterWorkers      int     `json:"writerWorkers"`
+	WriterMaxWorkers   int     `json:"writerMaxWorkers"`
+}
+
+// getAutoMachineType returns the machine type used for the dataflow jobs when
+// auto sizing, based on the number of shards handled by each writer job.
+// Larger machines keep the number of workers, and of connections opened to
+// every shard, down.
+func getAutoMachineType(shardsPerWriterJob int) string {
+	switch {
+	case shardsPerWriterJob <= 4:
+		return "n2-standard-2"
+	case shardsPerWriterJob <= 16:
+		return "n2-standard-4"
+	default:
+		return "n2-standard-8"
+	}
+}
+
+func ceilDiv(a float64, b float64) int {
+	n := int(a / b)
+	if float64(n)*b < a {
+		n++
+	}
+	return n
+}
+
+// computeWorkerSizing derives the machine type and worker counts of the
+// ordering and writer jobs from the number of source shards, the number of
+// writer jobs they are split across and the expected write QPS per shard.
+// Changes of a shard are applied in order by a single worker, so a writer job
+// never gets more workers than it has shards.
+func computeWorkerSizing(shardCount, writerJobs int, writeQpsPerShard float64) (workerSizing, error) {
+	if shardCount < 1 || writerJobs < 1 {
+		return workerSizing{}, fmt.Errorf("the number of shards and writer jobs must be at least 1")
+	}
+	if writeQpsPerShard <= 0 {
+		return workerSizing{}, fmt.Errorf("the write QPS per shard must be positive")
+	}
+	shardsPerWriterJob := ceilDiv(float64(shardCount), float64(writerJobs))
+	machine := getAutoMachineType(shardsPerWriterJob)
+	vcpus, _, err := getMachineResources(machine)
+	if err != nil {
+		return workerSizing{}, err
+	}
+	totalQps := float64(shardCount) * writeQpsPerShard
+	s := workerSizing{
+		ShardCount:       shardCount,
+		WriteQpsPerShard: writeQpsPerShard,
+		MachineType:      machine,
+		OrderingWorkers:  ceilDiv(totalQps, float64(ORDERING_QPS_PER_VCPU*vcpus)),
+		WriterWorkers:    ceilDiv(float64(shardsPerWriterJob)*writeQpsPerShard, float64(WRITER_QPS_PER_VCPU*vcpus)),
+	}
+	if s.WriterWorkers > shardsPerWriterJob {
+		s.WriterWorkers = shardsPerWriterJob
+	}
+	s.OrderingMaxWorkers = s.OrderingWorkers * MAX_WORKERS_FACTOR
+	s.WriterMaxWorkers = s.WriterWorkers * MAX_WORKERS_FACTOR
+	if s.WriterMaxWorkers > shardsPerWriterJob {
+		s.WriterMaxWorkers = shardsPerWriterJob
+	}
+	return s, nil
+}
+
+// applyWorkerSizing sets the worker flags from s, except for the ones
+// explicitly passed on the command line, and returns the sizing in effect.
+func (cfg *config) applyWorkerSizing(s workerSizing) workerSizing {
+	passed := map[string]bool{}
+	cfg.flagSet.Visit(func(f *flag.Flag) { passed[f.Name] = true })
+	if !passed["machineType"] {
+		cfg.machineType = s.MachineType
+	}
+	if !passed["orderingWorkers"] {
+		cfg.orderingWorkers = s.OrderingWorkers
+	}
+	if !passed["orderingMaxWorkers"] {
+		cfg.orderingMaxWorkers = s.OrderingMaxWorkers
+	}
+	if !passed["writerWorkers"] {
+		cfg.writerWorkers = s.WriterWorkers
+	}
+	if !passed["writerMaxWorkers"] {
+		cfg.writerMaxWorkers = s.WriterMaxWorkers
+	}
+	s.MachineType, s.OrderingWorkers, s.OrderingMaxWorkers = cfg.machineType, cfg.orderingWorkers, cfg.orderingMaxWorkers
+	s.WriterWorkers, s.WriterMaxWorkers = cfg.writerWorkers, cfg.writerMaxWorkers
+	return s
+}
+
+// getJobsTableDdl returns the statement creating the jobs table in a
+// metadata database of the given dialect. The worker sizing is empty for the
+// pipelines which were not auto sized, and the snapshot timestamps are only
+// set for the pipelines which were backfilled.
+func getJobsTableDdl(dialect string) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+	"JobNamePrefix" VARCHAR NOT NULL,
+	"MetadataTableSuffix" VARCHAR NOT NULL,
+	"WorkerSizing" VARCHAR NOT NULL,
+	"SnapshotTimestamp" TIMESTAMPTZ,
+	"StreamStartTimestamp" TIMESTAMPTZ,
+	"UpdatedAt" SPANNER.COMMIT_TIMESTAMP NOT NULL,
+	PRIMARY KEY ("JobNamePrefix")
+)`, JOBS_TABLE)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	JobNamePrefix STRING(MAX) NOT NULL,
+	MetadataTableSuffix STRING(MAX) NOT NULL,
+	WorkerSizing STRING(MAX) NOT NULL,
+	SnapshotTimestamp TIMESTAMP,
+	StreamStartTimestamp TIMESTAMP,
+	UpdatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+) PRIMARY KEY (JobNamePrefix)`, JOBS_TABLE)
+}
+
+// recordWorkerSizing stores the worker sizing of the pipeline in the jobs
+// table of the metadata store, along with the metadata table suffix of the
+// ordering job of every database in dbs.
+func recordWorkerSizing(ctx context.Context, store metadataStore, dbs []string, suffixes map[string]string, s workerSizing) error {
+	var dbSuffixes []string
+	for _, db := range dbs {
+		dbSuffixes = append(dbSuffixes, suffixes[db])
+	}
+	return store.RecordWorkerSizing(ctx, dbSuffixes, s)
+}
+
+func logWorkerSizing(ctx context.Context, s workerSizing) {
+	logger.FromContext(ctx).Info(fmt.Sprintf("Auto sized the dataflow jobs for %d shards at %.0f writes per second per shard: machine type %s, "+
+		"ordering job with %d workers, at most %d, writer jobs with %d workers, at most %d",
+		s.ShardCount, s.WriteQpsPerShard, s.MachineType, s.OrderingWorkers, s.OrderingMaxWorkers, s.WriterWorkers, s.WriterMaxWorkers))
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeWorkerSizing(t *testing.T) {
+	tests := []struct {
+		name             string
+		shardCount       int
+		writerJobs       int
+		writeQpsPerShard float64
+		want             workerSizing
+		errContains      string
+	}{
+		{
+			name:             "writer max workers capped by the shards",
+			shardCount:       2,
+			writerJobs:       1,
+			writeQpsPerShard: 50,
+			want:             workerSizing{ShardCount: 2, WriteQpsPerShard: 50, MachineType: "n2-standard-2", OrderingWorkers: 1, OrderingMaxWorkers: 3, WriterWorkers: 1, WriterMaxWorkers: 2},
+		},
+		{
+			name:             "shards split across writer jobs",
+			shardCount:       20,
+			writerJobs:       2,
+			writeQpsPerShard: 200,
+			want:             workerSizing{ShardCount: 20, WriteQpsPerShard: 200, MachineType: "n2-standard-4", OrderingWorkers: 1, OrderingMaxWorkers: 3, WriterWorkers: 5, WriterMaxWorkers: 10},
+		},
+		{
+			name:             "writer workers capped by the shards",
+			shardCount:       40,
+			writerJobs:       1,
+			writeQpsPerShard: 1000,
+			want:             workerSizing{ShardCount: 40, WriteQpsPerShard: 1000, MachineType: "n2-standard-8", OrderingWorkers: 5, OrderingMaxWorkers: 15, WriterWorkers: 40, WriterMaxWorkers: 40},
+		},
+		{
+			name:             "no shards",
+			writerJobs:       1,
+			writeQpsPerShard: 50,
+			errContains:      "the number of shards and writer jobs must be at least 1",
+		},
+		{
+			name:        "no write qps",
+			shardCount:  2,
+			writerJobs:  1,
+			errContains: "the write QPS per shard must be positive",
+		},
+	}
+	for _, tc := range tests {
+		s, err := computeWorkerSizing(tc.shardCount, tc.writerJobs, tc.writeQpsPerShard)
+		if tc.errContains == "" {
+			assert.Nil(t, err, tc.name)
+			assert.Equal(t, tc.want, s, tc.name)
+		} else if assert.NotNil(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.errContains, tc.name)
+		}
+	}
+}
+
+func TestApplyWorkerSizing(t *testing.T) {
+	sizing := workerSizing{ShardCount: 20, WriteQpsPerShard: 200, MachineType: "n2-standard-4", OrderingWorkers: 1, OrderingMaxWorkers: 3, WriterWorkers: 5, WriterMaxWorkers: 10}
+	tests := []struct {
+		name string
+		args []string
+		want workerSizing
+	}{
+		{
+			name: "no worker flags passed",
+			want: sizing,
+		},
+		{
+			name: "passed flags are kept",
+			args: []string{"-machineType=n2-highmem-8", "-writerWorkers=7"},
+			want: workerSizing{ShardCount: 20, WriteQpsPerShard: 200, MachineType: "n2-highmem-8", OrderingWorkers: 1, OrderingMaxWorkers: 3, WriterWorkers: 7, WriterMaxWorkers: 10},
+		},
+	}
+	for _, tc := range tests {
+		cfg, err := parseConfig(tc.args)
+		if !assert.Nil(t, err, tc.name) {
+			continue
+		}
+		assert.Equal(t, tc.want, cfg.applyWorkerSizing(sizing), tc.name)
+		assert.Equal(t, tc.want.MachineType, cfg.machineType, tc.name)
+		assert.Equal(t, tc.want.WriterWorkers, cfg.writerWorkers, tc.name)
+	}
+}
@@ -0,0 +1,344 @@
+package reverserepl
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	_ "github.com/denisenkom/go-mssqldb"
+	_ "github.com/go-sql-driver/mysql"
+	go_ora "github.com/sijms/go-ora/v2"
+)
+
+// Timezone offset of the source shards, e.g. +05:30.
+var timezoneOffsetRegex = regexp.MustCompile(`^[+-]([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// Cassandra keyspace name.
+var cassandraKeyspaceRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_]{0,47}$`)
+
+const (
+	// Timezone offset the writer jobs assume for the source shards when the
+	// sourceDbTimezoneOffset parameter is not passed.
+	DEFAULT_SOURCE_DB_TIMEZONE_OFFSET = "+00:00"
+	// Cassandra source type. The launcher has no Cassandra driver, so the
+	// modes connecting to the source shards are not supported for it.
+	CASSANDRA = "cassandra"
+)
+
+// sourceAdapter is the logic specific to a type of source shards. Adding a
+// source type only needs an implementation registered in sourceAdapters.
+type sourceAdapter interface {
+	// Experimental returns true if the source type needs -allowExperimental.
+	Experimental() bool
+	// ValidateFlags checks the launcher flags for the source type.
+	ValidateFlags(cfg *config) error
+	// ValidateShard checks the connection config of a shard read from the
+	// source shards file at launch. Source types whose config is only checked
+	// by the writer jobs return nil.
+	ValidateShard(shard map[string]interface{}) error
+	// DriverName returns the database/sql driver connecting to the shards, or
+	// empty if the launcher can't connect to them.
+	DriverName() string
+	// ConnectionString returns the connection string of the driver for a
+	// shard read from the source shards file.
+	ConnectionString(shard map[string]interface{}) (string, error)
+	// QuoteIdentifier quotes a table or column name in the queries run on the
+	// shards.
+	QuoteIdentifier(name string) string
+	// QueryParam returns the placeholder of the i-th query parameter,
+	// starting from 1, in the queries run on the shards.
+	QueryParam(i int) string
+	// DefaultTimezoneOffset returns the timezone offset of the shards when
+	// sourceDbTimezoneOffset is not set.
+	DefaultTimezoneOffset() string
+	// WriterParams returns the writer template parameters of the source type,
+	// on top of the common ones.
+	WriterParams() map[string]string
+}
+
+// The supported source types.
+var sourceAdapters = map[string]sourceAdapter{
+	constants.MYSQL:     mysqlAdapter{},
+	constants.SQLSERVER: sqlServerAdapter{},
+	constants.ORACLE:    oracleAdapter{},
+	CASSANDRA:           cassandraAdapter{},
+}
+
+// getSourceAdapter returns the adapter of sourceType, which is checked by
+// validateSource.
+func (cfg *config) getSourceAdapter() sourceAdapter {
+	return sourceAdapters[cfg.sourceType]
+}
+
+// validateSource checks sourceType, sourceDbTimezoneOffset and the flags
+// specific to the source type.
+func (cfg *config) validateSource() error {
+	adapter, ok := sourceAdapters[cfg.sourceType]
+	if !ok {
+		var types []string
+		for t, a := range sourceAdapters {
+			if a.Experimental() {
+				t += " (experimental)"
+			}
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		return fmt.Errorf("please specify a valid sourceType. Supported values are %s", strings.Join(types, ", "))
+	}
+	if adapter.Experimental() && !cfg.allowExperimental {
+		return fmt.Errorf("%s sources are experimental, please pass -allowExperimental to use them", cfg.sourceType)
+	}
+	if cfg.sourceDbTimezoneOffset != "" && !timezoneOffsetRegex.MatchString(cfg.sourceDbTimezoneOffset) {
+		return fmt.Errorf("please specify a valid sourceDbTimezoneOffset in the +HH:MM or -HH:MM format, e.g. +05:30")
+	}
+	return adapter.ValidateFlags(cfg)
+}
+
+// getSourceDbTimezoneOffset returns the timezone offset of the source shards.
+func (cfg *config) getSourceDbTimezoneOffset() string {
+	if cfg.sourceDbTimezoneOffset != "" {
+		return cfg.sourceDbTimezoneOffset
+	}
+	return cfg.getSourceAdapter().DefaultTimezoneOffset()
+}
+
+// getSourceWriterParams returns the writer template parameters specific to
+// the source shards.
+func (cfg *config) getSourceWriterParams() map[string]string {
+	params := cfg.getSourceAdapter().WriterParams()
+	if params == nil {
+		params = make(map[string]string)
+	}
+	// Only passed when not the default, as the default template does not
+	// have the parameter.
+	if offset := cfg.getSourceDbTimezoneOffset(); offset != DEFAULT_SOURCE_DB_TIMEZONE_OFFSET {
+		params["sourceDbTimezoneOffset"] = offset
+	}
+	return params
+}
+
+// validateSourceShards checks the connection config of every shard read from
+// the source shards file.
+func (cfg *config) validateSourceShards(shards []interface{}) error {
+	for i, s := range shards {
+		shard, ok := s.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("shard at index %d is not a json object", i)
+		}
+		// Only the local shards files are uploaded with the references
+		// resolved, the dataflow jobs can't read the variables.
+		for k, v := range shard {
+			if value, ok := v.(string); ok && secretReferenceRegex.MatchString(value) {
+				return fmt.Errorf("the %s of shard at index %d references an environment variable, which the dataflow jobs can't read. Please use a local source shards file, which is uploaded with the values of the variables", k, i)
+			}
+		}
+		if err := cfg.getSourceAdapter().ValidateShard(shard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getShardConnectionString returns the connection string of the sourceType
+// driver for a shard read from the source shards file.
+func (cfg *config) getShardConnectionString(shard map[string]interface{}) (string, error) {
+	return cfg.getSourceAdapter().ConnectionString(shard)
+}
+
+// getShardDriverName returns the database/sql driver connecting to the source
+// shards, or empty if the launcher can't connect to them.
+func (cfg *config) getShardDriverName() string {
+	return cfg.getSourceAdapter().DriverName()
+}
+
+// quoteSourceIdentifier quotes the table or column name for the source shards.
+func (cfg *config) quoteSourceIdentifier(name string) string {
+	return cfg.getSourceAdapter().QuoteIdentifier(name)
+}
+
+// getSourceQueryParam returns the placeholder of the i-th query parameter,
+// starting from 1, for the source shards.
+func (cfg *config) getSourceQueryParam(i int) string {
+	return cfg.getSourceAdapter().QueryParam(i)
+}
+
+// getSqlShardFields returns the connection fields of a shard of a sql source
+// type read from the source shards file.
+func getSqlShardFields(shard map[string]interface{}) (map[string]string, error) {
+	fields := make(map[string]string)
+	for _, k := range []string{"host", "port", "user", "password", "dbName"} {
+		v, ok := shard[k].(string)
+		if !ok {
+			return nil, fmt.Errorf("shard %v does not have a %s", shard["logicalShardId"], k)
+		}
+		fields[k] = v
+	}
+	return fields, nil
+}
+
+// mysqlAdapter is the default source type, which the writer jobs support
+// without extra parameters.
+type mysqlAdapter struct{}
+
+func (mysqlAdapter) Experimental() bool              { return false }
+func (mysqlAdapter) ValidateFlags(cfg *config) error { return nil }
+
+// ValidateShard returns nil, the MySQL connection config is checked by the
+// writer jobs.
+func (mysqlAdapter) ValidateShard(shard map[string]interface{}) error { return nil }
+
+func (mysqlAdapter) DriverName() string { return constants.MYSQL }
+
+func (mysqlAdapter) ConnectionString(shard map[string]interface{}) (string, error) {
+	fields, err := getSqlShardFields(shard)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", fields["user"], fields["password"], fields["host"], fields["port"], fields["dbName"]), nil
+}
+
+func (mysqlAdapter) QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (mysqlAdapter) QueryParam(i int) string         { return "?" }
+func (mysqlAdapter) DefaultTimezoneOffset() string   { return DEFAULT_SOURCE_DB_TIMEZONE_OFFSET }
+func (mysqlAdapter) WriterParams() map[string]string { return nil }
+
+type sqlServerAdapter struct{}
+
+func (sqlServerAdapter) Experimental() bool              { return false }
+func (sqlServerAdapter) ValidateFlags(cfg *config) error { return nil }
+
+// ValidateShard returns nil, the SQL Server connection config is checked by
+// the writer jobs.
+func (sqlServerAdapter) ValidateShard(shard map[string]interface{}) error { return nil }
+
+func (sqlServerAdapter) DriverName() string { return constants.SQLSERVER }
+
+func (sqlServerAdapter) ConnectionString(shard map[string]interface{}) (string, error) {
+	fields, err := getSqlShardFields(shard)
+	if err != nil {
+		return "", err
+	}
+	u := url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(fields["user"], fields["password"]),
+		Host:     net.JoinHostPort(fields["host"], fields["port"]),
+		RawQuery: url.Values{"database": {fields["dbName"]}}.Encode(),
+	}
+	return u.String(), nil
+}
+
+func (sqlServerAdapter) QuoteIdentifier(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+func (sqlServerAdapter) QueryParam(i int) string       { return fmt.Sprintf("@p%d", i) }
+func (sqlServerAdapter) DefaultTimezoneOffset() string { return DEFAULT_SOURCE_DB_TIMEZONE_OFFSET }
+
+func (sqlServerAdapter) WriterParams() map[string]string {
+	return map[string]string{"sourceType": constants.SQLSERVER}
+}
+
+type oracleAdapter struct{}
+
+func (oracleAdapter) Experimental() bool              { return true }
+func (oracleAdapter) ValidateFlags(cfg *config) error { return nil }
+
+func (a oracleAdapter) ValidateShard(shard map[string]interface{}) error {
+	_, err := a.ConnectionString(shard)
+	return err
+}
+
+func (oracleAdapter) DriverName() string { return constants.ORACLE }
+
+// ConnectionString uses dbName as the service name of the Oracle database.
+func (oracleAdapter) ConnectionString(shard map[string]interface{}) (string, error) {
+	fields, err := getSqlShardFields(shard)
+	if err != nil {
+		return "", err
+	}
+	port, err := strconv.Atoi(fields["port"])
+	if err != nil {
+		return "", fmt.Errorf("invalid port %q of shard %v", fields["port"], shard["logicalShardId"])
+	}
+	return go_ora.BuildUrl(fields["host"], port, fields["dbName"], fields["user"], fields["password"], nil), nil
+}
+
+func (oracleAdapter) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (oracleAdapter) QueryParam(i int) string       { return fmt.Sprintf(":%d", i) }
+func (oracleAdapter) DefaultTimezoneOffset() string { return DEFAULT_SOURCE_DB_TIMEZONE_OFFSET }
+
+func (oracleAdapter) WriterParams() map[string]string {
+	return map[string]string{"sourceType": constants.ORACLE}
+}
+
+type cassandraAdapter struct{}
+
+func (cassandraAdapter) Experimental() bool { return false }
+
+func (cassandraAdapter) ValidateFlags(cfg *config) error {
+	// Cassandra stores timestamps in UTC.
+	if cfg.sourceDbTimezoneOffset != "" && cfg.sourceDbTimezoneOffset != DEFAULT_SOURCE_DB_TIMEZONE_OFFSET {
+		return fmt.Errorf("sourceDbTimezoneOffset can't be used with %s sources", CASSANDRA)
+	}
+	if cfg.verify || cfg.cutback || cfg.validate || cfg.rotateCredentials {
+		return fmt.Errorf("verifyPipeline, cutback, validate and rotateCredentials connect to the source shards, which is not supported for %s sources", CASSANDRA)
+	}
+	return nil
+}
+
+// ValidateShard checks a comma separated list of host:port contactPoints, a
+// valid keyspace and the localDataCenter of the driver, with optional username
+// and password.
+func (cassandraAdapter) ValidateShard(shard map[string]interface{}) error {
+	id := shard["logicalShardId"]
+	for _, k := range []string{"contactPoints", "keyspace", "localDataCenter"} {
+		if v, ok := shard[k].(string); !ok || v == "" {
+			return fmt.Errorf("shard %v does not have a %s", id, k)
+		}
+	}
+	for _, k := range []string{"username", "password"} {
+		if v, ok := shard[k]; ok {
+			if _, ok := v.(string); !ok {
+				return fmt.Errorf("%s of shard %v is not a string", k, id)
+			}
+		}
+	}
+	for _, cp := range strings.Split(shard["contactPoints"].(string), ",") {
+		host, port, err := net.SplitHostPort(strings.TrimSpace(cp))
+		if err != nil || host == "" || port == "" {
+			return fmt.Errorf("invalid contact point %q of shard %v, expected host:port", cp, id)
+		}
+	}
+	if keyspace := shard["keyspace"].(string); !cassandraKeyspaceRegex.MatchString(keyspace) {
+		return fmt.Errorf("invalid keyspace %q of shard %v", keyspace, id)
+	}
+	return nil
+}
+
+func (cassandraAdapter) DriverName() string { return "" }
+
+func (cassandraAdapter) ConnectionString(shard map[string]interface{}) (string, error) {
+	return "", fmt.Errorf("the launcher can't connect to %s shards", CASSANDRA)
+}
+
+func (cassandraAdapter) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (cassandraAdapter) QueryParam(i int) string       { return "?" }
+func (cassandraAdapter) DefaultTimezoneOffset() string { return DEFAULT_SOURCE_DB_TIMEZONE_OFFSET }
+
+func (cassandraAdapter) WriterParams() map[string]string {
+	return map[string]string{"sourceType": CASSANDRA}
+}
@@ -0,0 +1,90 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/dataflowjobs"
+)
+
+// TAG_LABEL_PREFIX prefixes the keys of the labels of the Dataflow jobs
+// holding the tags of the pipeline.
+const TAG_LABEL_PREFIX = "smt-tag-"
+
+// A tag is a key, e.g. prod, or a key and a value, e.g. env=prod. The label
+// key of a tag must have at most 63 characters with its prefix.
+var tagRegex = regexp.MustCompile(`^([a-z][-_a-z0-9]{0,54})(=([-_a-z0-9]{0,63}))?$`)
+
+// parseTags returns the labels of the comma separated tags s.
+func parseTags(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	if s == "" {
+		return labels, nil
+	}
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.TrimSpace(tag)
+		m := tagRegex.FindStringSubmatch(tag)
+		if m == nil {
+			return nil, fmt.Errorf("invalid tag %q, tags must be a key or key=value, with keys of at most 55 and values of at most 63 lowercase letters, digits, underscores and hyphens, and keys starting with a letter", tag)
+		}
+		labels[TAG_LABEL_PREFIX+m[1]] = m[3]
+	}
+	return labels, nil
+}
+
+// getTagLabels returns the labels of the tags of the pipeline, validated by
+// prechecks.
+func (cfg *config) getTagLabels() map[string]string {
+	labels, _ := parseTags(cfg.tags)
+	return labels
+}
+
+// getJobTags returns the tags of job, as given to the launcher, sorted.
+func getJobTags(job *dataflowpb.Job) []string {
+	var res []string
+	for k, v := range job.Labels {
+		if !strings.HasPrefix(k, TAG_LABEL_PREFIX) {
+			continue
+		}
+		tag := strings.TrimPrefix(k, TAG_LABEL_PREFIX)
+		if v != "" {
+			tag += "=" + v
+		}
+		res = append(res, tag)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// matchesTags returns true if the pipeline with the given tags has all of
+// filters. A filter which is a key matches the tags with that key whatever
+// their value.
+func matchesTags(pipelineTags, filters []string) bool {
+	for _, filter := range filters {
+		found := false
+		for _, tag := range pipelineTags {
+			if tag == filter || strings.HasPrefix(tag, filter+"=") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// readWorkflowTags returns the tags of the pipeline of job, one of its
+// Dataflow jobs. Listing the jobs doesn't return their labels.
+func (cfg *config) readWorkflowTags(ctx context.Context, c dataflowjobs.DataflowAccessor, job *dataflowpb.Job) ([]string, error) {
+	full, err := c.GetJob(ctx, cfg.projectId, cfg.dataflowRegion, job.Id)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the tags of dataflow job %s: %v", job.Name, err)
+	}
+	return getJobTags(full), nil
+}
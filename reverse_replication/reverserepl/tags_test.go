@@ -0,0 +1,80 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		tags        string
+		want        map[string]string
+		errContains string
+	}{
+		{tags: "", want: map[string]string{}},
+		{tags: "env=prod, team", want: map[string]string{"smt-tag-env": "prod", "smt-tag-team": ""}},
+		{tags: "cost_center=a-1,empty=", want: map[string]string{"smt-tag-cost_center": "a-1", "smt-tag-empty": ""}},
+		{tags: "Env=prod", errContains: `invalid tag "Env=prod"`},
+		{tags: "1env", errContains: `invalid tag "1env"`},
+		{tags: "env=prod,", errContains: `invalid tag ""`},
+		{tags: "env=a=b", errContains: `invalid tag "env=a=b"`},
+		{tags: strings.Repeat("k", 56), errContains: "invalid tag"},
+		{tags: "env=" + strings.Repeat("v", 64), errContains: "invalid tag"},
+	}
+	for _, tc := range tests {
+		labels, err := parseTags(tc.tags)
+		if tc.errContains == "" {
+			assert.Nil(t, err, tc.tags)
+			assert.Equal(t, tc.want, labels, tc.tags)
+		} else if assert.NotNil(t, err, tc.tags) {
+			assert.Contains(t, err.Error(), tc.errContains, tc.tags)
+		}
+	}
+}
+
+func TestGetJobTags(t *testing.T) {
+	job := &dataflowpb.Job{Labels: map[string]string{
+		"smt-tag-team": "",
+		"smt-tag-env":  "prod",
+		"goog-owner":   "me",
+	}}
+	assert.Equal(t, []string{"env=prod", "team"}, getJobTags(job))
+	assert.Nil(t, getJobTags(&dataflowpb.Job{}))
+}
+
+func TestMatchesTags(t *testing.T) {
+	tags := []string{"env=prod", "team"}
+	tests := []struct {
+		filters []string
+		want    bool
+	}{
+		{filters: nil, want: true},
+		{filters: []string{"env=prod"}, want: true},
+		{filters: []string{"env"}, want: true},
+		{filters: []string{"env", "team"}, want: true},
+		{filters: []string{"env=dev"}, want: false},
+		{filters: []string{"en"}, want: false},
+		{filters: []string{"team=a"}, want: false},
+		{filters: []string{"env=prod", "owner"}, want: false},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, matchesTags(tags, tc.filters), strings.Join(tc.filters, ","))
+	}
+}
@@ -0,0 +1,82 @@
+package reverserepl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/dataflowjobs"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// getTemplateCachePath returns the file in templateCacheDir holding the
+// validated container spec of the template at templatePath.
+func (cfg *config) getTemplateCachePath(templatePath string) string {
+	sum := sha256.Sum256([]byte(templatePath))
+	return filepath.Join(cfg.templateCacheDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// readFlexTemplateSpec reads the container spec of the template at the gcs
+// path templatePath.
+func readFlexTemplateSpec(ctx context.Context, templatePath string) (*dataflowpb.ContainerSpec, error) {
+	bArr, err := readGcsFile(ctx, templatePath)
+	if err != nil {
+		return nil, err
+	}
+	return utils.ParseFlexTemplateSpec(templatePath, bArr)
+}
+
+// useTemplateCache makes req launch the template at templatePath from its
+// container spec cached in templateCacheDir, which skips fetching and
+// validating the spec on every launch. On first use, the spec is downloaded,
+// validated by a validate only launch of req and then cached. The parameters
+// of req are checked against the spec in either case.
+func (cfg *config) useTemplateCache(ctx context.Context, c dataflowjobs.DataflowAccessor, req *dataflowpb.LaunchFlexTemplateRequest, templatePath string) error {
+	cachePath := cfg.getTemplateCachePath(templatePath)
+	if bArr, err := ioutil.ReadFile(cachePath); err == nil {
+		spec := &dataflowpb.ContainerSpec{}
+		if err := protojson.Unmarshal(bArr, spec); err == nil {
+			if err := utils.ValidateFlexTemplateParameters(templatePath, spec, req.LaunchParameter.Parameters); err != nil {
+				return err
+			}
+			req.LaunchParameter.Template = &dataflowpb.LaunchFlexTemplateParameter_ContainerSpec{ContainerSpec: spec}
+			logger.FromContext(ctx).Info(fmt.Sprintf("Using cached template spec %s for %s", cachePath, templatePath))
+			return nil
+		}
+		logger.FromContext(ctx).Warn(fmt.Sprintf("Ignoring unreadable cached template spec %s: %v", cachePath, err))
+	}
+	spec, err := readFlexTemplateSpec(ctx, templatePath)
+	if err != nil {
+		return err
+	}
+	if err := utils.ValidateFlexTemplateParameters(templatePath, spec, req.LaunchParameter.Parameters); err != nil {
+		return err
+	}
+	validateReq := proto.Clone(req).(*dataflowpb.LaunchFlexTemplateRequest)
+	validateReq.LaunchParameter.Template = &dataflowpb.LaunchFlexTemplateParameter_ContainerSpec{ContainerSpec: spec}
+	validateReq.ValidateOnly = true
+	if _, err := c.LaunchFlexTemplate(ctx, validateReq); err != nil {
+		return fmt.Errorf("validation of template %s failed: %v", templatePath, err)
+	}
+	bArr, err := protojson.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("could not serialize template spec %s: %v", templatePath, err)
+	}
+	if err := os.MkdirAll(cfg.templateCacheDir, 0755); err != nil {
+		return fmt.Errorf("could not create templateCacheDir %s: %v", cfg.templateCacheDir, err)
+	}
+	if err := ioutil.WriteFile(cachePath, bArr, 0644); err != nil {
+		return fmt.Errorf("could not write cached template spec %s: %v", cachePath, err)
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Validated template %s and cached its spec in %s", templatePath, cachePath))
+	req.LaunchParameter.Template = validateReq.LaunchParameter.Template
+	return nil
+}
@@ -0,0 +1,90 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+)
+
+// Column of the jobs table and of the suffix registry holding the tenant of
+// the pipeline. It is added to the tables created before tenants existed,
+// where it is null for the pipelines launched back then.
+const TENANT_COLUMN = "Tenant"
+
+var (
+	callerTenantOnce sync.Once
+	callerTenant     string
+)
+
+// getTenant returns the tenant of the pipeline: the -tenant flag, or the
+// account gcloud is logged in with. It is empty if neither is known, in which
+// case the pipeline is shared by all the tenants.
+func (cfg *config) getTenant() string {
+	if cfg.tenant != "" {
+		return cfg.tenant
+	}
+	callerTenantOnce.Do(func() {
+		out, err := exec.Command("gcloud", "config", "get-value", "account").Output()
+		if err == nil {
+			callerTenant = strings.TrimSpace(string(out))
+		}
+	})
+	return callerTenant
+}
+
+// getTenantColumnDdl returns the statement adding the tenant column to a table
+// of the metadata database of the given dialect, if missing.
+func getTenantColumnDdl(dialect, table string) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "%s" VARCHAR`, table, TENANT_COLUMN)
+	}
+	return fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s STRING(MAX)`, table, TENANT_COLUMN)
+}
+
+// isSameTenant returns true if a record of the given tenant is visible to the
+// tenant of the pipeline. Records without a tenant are visible to everyone.
+func (cfg *config) isSameTenant(owner string) bool {
+	return owner == "" || cfg.getTenant() == "" || owner == cfg.getTenant()
+}
+
+// checkTenant fails if the pipeline of jobNamePrefix was launched by another
+// tenant, according to the jobs table of the metadata store.
+func (cfg *config) checkTenant(ctx context.Context, store metadataStore) error {
+	owner, err := store.ReadJobTenant(ctx)
+	if err != nil {
+		return err
+	}
+	if !cfg.isSameTenant(owner) {
+		return fmt.Errorf("pipeline %s belongs to another tenant. Please specify a different jobNamePrefix or the -tenant the pipeline was launched with", cfg.jobNamePrefix)
+	}
+	return nil
+}
+
+// checkTenantAccess fails if the pipeline of jobNamePrefix was launched by
+// another tenant. A pipeline whose metadata database doesn't exist has no
+// tenant.
+func (cfg *config) checkTenantAccess(ctx context.Context) error {
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	if _, err := spanneradmin.GetDatabaseDialect(ctx, adminClient, cfg.getMetadataDbUri()); err != nil {
+		if gcp.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("could not check metadata database %s: %v", cfg.getMetadataDbUri(), err)
+	}
+	store, err := getClients(ctx).openMetadataStore(ctx, cfg, adminClient)
+	if err != nil {
+		return fmt.Errorf("could not open the metadata db: %v", err)
+	}
+	defer store.Close()
+	return cfg.checkTenant(ctx, store)
+}
@@ -0,0 +1,103 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+// normalizeTimestamp parses the RFC 3339 timestamp value of the flag name,
+// with any timezone offset, and returns it in UTC as passed to the Dataflow
+// jobs. An empty value is returned as is.
+func normalizeTimestamp(name, value string) (string, time.Time, error) {
+	if value == "" {
+		return "", time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("please specify a valid %s in RFC 3339 format, e.g. 2023-06-01T10:00:00Z or 2023-06-01T12:00:00+02:00: %v", name, err)
+	}
+	t = t.UTC()
+	return t.Format(time.RFC3339Nano), t, nil
+}
+
+// validateReadWindow normalizes startTimestamp and endTimestamp to UTC and
+// checks that they are in order.
+func (cfg *config) validateReadWindow() error {
+	var start, end time.Time
+	var err error
+	if cfg.startTimestamp, start, err = normalizeTimestamp("startTimestamp", cfg.startTimestamp); err != nil {
+		return err
+	}
+	if cfg.endTimestamp, end, err = normalizeTimestamp("endTimestamp", cfg.endTimestamp); err != nil {
+		return err
+	}
+	if cfg.endTimestamp == "" {
+		return nil
+	}
+	if cfg.startTimestamp != "" && !start.Before(end) {
+		return fmt.Errorf("startTimestamp must be before endTimestamp")
+	}
+	if cfg.startTimestamp == "" && !time.Now().Before(end) {
+		return fmt.Errorf("endTimestamp must be in the future when startTimestamp is not set, as the changestream is then read from now on")
+	}
+	return nil
+}
+
+// getEarliestReadableTime returns the oldest timestamp the change stream of
+// the database at dbUri can be read from: changes older than the retention
+// period of the change stream are deleted, and Spanner doesn't serve reads
+// older than the earliest version time of the database.
+func (cfg *config) getEarliestReadableTime(ctx context.Context, adminClient *database.DatabaseAdminClient, spClient *spanner.Client, dbUri, dialect string) (time.Time, string, error) {
+	options, err := cfg.getChangeStreamOptions(ctx, spClient, dialect)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	retention, ok := options["retention_period"]
+	if !ok {
+		retention = DEFAULT_RETENTION_PERIOD
+	}
+	period, err := parseRetentionPeriod(retention)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("can't parse retention_period of changestream %s: %v", cfg.changeStreamName, err)
+	}
+	earliest := time.Now().Add(-period)
+	reason := fmt.Sprintf("the retention period of changestream %s is %s", cfg.changeStreamName, retention)
+	db, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "GetDatabase", Resource: dbUri, Idempotent: true}, func(ctx context.Context) (*adminpb.Database, error) {
+		return adminClient.GetDatabase(ctx, &adminpb.GetDatabaseRequest{Name: dbUri})
+	})
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("could not get database %s: %v", dbUri, err)
+	}
+	if evt := db.GetEarliestVersionTime(); evt != nil && evt.AsTime().After(earliest) {
+		earliest = evt.AsTime()
+		reason = fmt.Sprintf("the version_retention_period of the database is %s", db.VersionRetentionPeriod)
+	}
+	return earliest, reason, nil
+}
+
+// validateStartTimestamp checks that the change stream of the database at
+// dbUri still holds the changes from startTimestamp on.
+func (cfg *config) validateStartTimestamp(ctx context.Context, adminClient *database.DatabaseAdminClient, spClient *spanner.Client, dbUri, dialect string) error {
+	if cfg.startTimestamp == "" {
+		return nil
+	}
+	start, err := time.Parse(time.RFC3339Nano, cfg.startTimestamp)
+	if err != nil {
+		return err
+	}
+	earliest, reason, err := cfg.getEarliestReadableTime(ctx, adminClient, spClient, dbUri, dialect)
+	if err != nil {
+		return err
+	}
+	if start.Before(earliest) {
+		return fmt.Errorf("startTimestamp %s is too old for %s: %s, so changes are only available from %s on. Please specify a later startTimestamp, or increase the retention with -changeStreamRetention and -autoFixChangeStream for future pipelines", cfg.startTimestamp, dbUri, reason, earliest.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
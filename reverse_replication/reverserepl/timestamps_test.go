@@ -0,0 +1,113 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTimestamp(t *testing.T) {
+	tests := []struct {
+		value       string
+		want        string
+		errContains string
+	}{
+		{value: "", want: ""},
+		{value: "2023-06-01T10:00:00Z", want: "2023-06-01T10:00:00Z"},
+		{value: "2023-06-01T12:00:00+02:00", want: "2023-06-01T10:00:00Z"},
+		{value: "2023-06-01T10:00:00.123456789-05:30", want: "2023-06-01T15:30:00.123456789Z"},
+		{value: "2023-06-01 10:00:00", errContains: "please specify a valid startTimestamp in RFC 3339 format"},
+		{value: "2023-06-01", errContains: "please specify a valid startTimestamp in RFC 3339 format"},
+	}
+	for _, tc := range tests {
+		normalized, parsed, err := normalizeTimestamp("startTimestamp", tc.value)
+		if tc.errContains != "" {
+			if assert.NotNil(t, err, tc.value) {
+				assert.Contains(t, err.Error(), tc.errContains, tc.value)
+			}
+			continue
+		}
+		assert.Nil(t, err, tc.value)
+		assert.Equal(t, tc.want, normalized, tc.value)
+		if tc.want != "" {
+			assert.Equal(t, tc.want, parsed.Format(time.RFC3339Nano), tc.value)
+		}
+	}
+}
+
+func TestValidateReadWindow(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name        string
+		start       string
+		end         string
+		wantStart   string
+		wantEnd     string
+		errContains string
+	}{
+		{
+			name: "no window",
+		},
+		{
+			name:      "start only",
+			start:     "2023-06-01T12:00:00+02:00",
+			wantStart: "2023-06-01T10:00:00Z",
+		},
+		{
+			name:      "start before end",
+			start:     "2023-06-01T10:00:00Z",
+			end:       "2023-06-01T13:00:00+02:00",
+			wantStart: "2023-06-01T10:00:00Z",
+			wantEnd:   "2023-06-01T11:00:00Z",
+		},
+		{
+			name:        "start at end",
+			start:       "2023-06-01T10:00:00Z",
+			end:         "2023-06-01T12:00:00+02:00",
+			errContains: "startTimestamp must be before endTimestamp",
+		},
+		{
+			name:    "future end without start",
+			end:     future,
+			wantEnd: future,
+		},
+		{
+			name:        "past end without start",
+			end:         "2023-06-01T10:00:00Z",
+			errContains: "endTimestamp must be in the future when startTimestamp is not set",
+		},
+		{
+			name:        "invalid end",
+			end:         "tomorrow",
+			errContains: "please specify a valid endTimestamp",
+		},
+	}
+	for _, tc := range tests {
+		cfg := config{startTimestamp: tc.start, endTimestamp: tc.end}
+		err := cfg.validateReadWindow()
+		if tc.errContains != "" {
+			if assert.NotNil(t, err, tc.name) {
+				assert.Contains(t, err.Error(), tc.errContains, tc.name)
+			}
+			continue
+		}
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, tc.wantStart, cfg.startTimestamp, tc.name)
+		assert.Equal(t, tc.wantEnd, cfg.endTimestamp, tc.name)
+	}
+}
@@ -0,0 +1,26 @@
+package reverserepl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Fully qualified Java class name, e.g. com.example.CustomTransformation.
+var javaClassNameRegex = regexp.MustCompile(`^([A-Za-z_$][A-Za-z0-9_$]*\.)*[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// validateWriterTransformation checks that writerTransformationJarPath and
+// writerTransformationClassName are either both empty or name a jar in GCS
+// and a class in it.
+func (cfg *config) validateWriterTransformation() error {
+	if cfg.writerTransformationJarPath == "" && cfg.writerTransformationClassName == "" {
+		return nil
+	}
+	if !isGcsPath(cfg.writerTransformationJarPath) || !strings.HasSuffix(cfg.writerTransformationJarPath, ".jar") {
+		return fmt.Errorf("please specify a valid writerTransformationJarPath starting with gs:// and ending with .jar to use with writerTransformationClassName")
+	}
+	if !javaClassNameRegex.MatchString(cfg.writerTransformationClassName) {
+		return fmt.Errorf("please specify a valid writerTransformationClassName, the fully qualified name of the class in writerTransformationJarPath e.g. com.example.CustomTransformation")
+	}
+	return nil
+}
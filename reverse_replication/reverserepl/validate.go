@@ -0,0 +1,546 @@
+package reverserepl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// Layouts of the timestamps and dates compared between Spanner and the source
+// shards. MySQL datetimes are expected to hold UTC times.
+const (
+	VALIDATE_TIMESTAMP_LAYOUT = "2006-01-02 15:04:05.999999999"
+	VALIDATE_DATE_LAYOUT      = "2006-01-02"
+)
+
+// validatedTable is a table of the session file compared between Spanner and
+// the source shards, with the names of its columns on both sides.
+type validatedTable struct {
+	spName        string
+	srcName       string
+	spCols        []string
+	srcCols       []string
+	shardIdColumn string
+}
+
+// tableReconciliation is the outcome of the comparison of a table between
+// Spanner and a source shard.
+type tableReconciliation struct {
+	Table            string `json:"table"`
+	ShardId          string `json:"shardId"`
+	SpannerRows      int64  `json:"spannerRows"`
+	SourceRows       int64  `json:"sourceRows"`
+	CountMatch       bool   `json:"countMatch"`
+	ChecksumCompared bool   `json:"checksumCompared"`
+	ChecksumMatch    bool   `json:"checksumMatch"`
+	Error            string `json:"error,omitempty"`
+}
+
+// reconciliationReport is the outcome of the comparison of all the validated
+// tables.
+type reconciliationReport struct {
+	GeneratedAt time.Time             `json:"generatedAt"`
+	Tables      []tableReconciliation `json:"tables"`
+	Mismatches  int                   `json:"mismatches"`
+}
+
+// getValidatedTables returns the tables of the session file to compare,
+// restricted to the comma separated names in selected if set. Only the
+// columns present both in Spanner and in the source are compared.
+func getValidatedTables(sessionJSON []byte, selected string) ([]validatedTable, error) {
+	type column struct {
+		Name string
+	}
+	var session struct {
+		SpSchema map[string]struct {
+			Name          string
+			ColIds        []string
+			ShardIdColumn string
+			ColDefs       map[string]column
+		}
+		SrcSchema map[string]struct {
+			Name    string
+			ColDefs map[string]column
+		}
+	}
+	if err := json.Unmarshal(sessionJSON, &session); err != nil {
+		return nil, fmt.Errorf("could not parse session file: %v", err)
+	}
+	wanted := map[string]bool{}
+	for _, t := range strings.Split(selected, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			wanted[t] = true
+		}
+	}
+	var tables []validatedTable
+	found := map[string]bool{}
+	for id, spTable := range session.SpSchema {
+		srcTable, ok := session.SrcSchema[id]
+		if !ok || (len(wanted) > 0 && !wanted[spTable.Name]) {
+			continue
+		}
+		found[spTable.Name] = true
+		t := validatedTable{spName: spTable.Name, srcName: srcTable.Name}
+		for _, colId := range spTable.ColIds {
+			srcCol, ok := srcTable.ColDefs[colId]
+			if !ok {
+				continue
+			}
+			t.spCols = append(t.spCols, spTable.ColDefs[colId].Name)
+			t.srcCols = append(t.srcCols, srcCol.Name)
+		}
+		if spTable.ShardIdColumn != "" {
+			t.shardIdColumn = spTable.ColDefs[spTable.ShardIdColumn].Name
+		}
+		tables = append(tables, t)
+	}
+	for t := range wanted {
+		if !found[t] {
+			return nil, fmt.Errorf("table %s is not in the session file", t)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].spName < tables[j].spName })
+	return tables, nil
+}
+
+func quoteSpannerIdentifier(dialect, name string) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return quoteIdentifier(dialect, name)
+	}
+	return fmt.Sprintf("`%s`", name)
+}
+
+// getSpannerTables returns the names of the tables of the database.
+func getSpannerTables(ctx context.Context, spClient *spanner.Client, dialect string) (map[string]bool, error) {
+	schema := ""
+	if dialect == constants.DIALECT_POSTGRESQL {
+		schema = "public"
+	}
+	stmt := spanner.Statement{SQL: fmt.Sprintf(`SELECT table_name FROM information_schema.tables WHERE table_schema = '%s'`, schema)}
+	tables := make(map[string]bool)
+	err := spClient.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var name string
+		if err := row.Columns(&name); err != nil {
+			return err
+		}
+		tables[name] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the tables of the database: %w", err)
+	}
+	return tables, nil
+}
+
+// getSpannerShardFilter returns the condition restricting the rows of the
+// table to the shard, and its parameters.
+func getSpannerShardFilter(t validatedTable, dialect, shardId string) (string, map[string]interface{}) {
+	if t.shardIdColumn == "" {
+		return "", nil
+	}
+	return fmt.Sprintf(" WHERE %s = %s", quoteSpannerIdentifier(dialect, t.shardIdColumn), getQueryParam(dialect, 1)), map[string]interface{}{"p1": shardId}
+}
+
+func countSpannerRows(ctx context.Context, spClient *spanner.Client, t validatedTable, dialect, shardId string) (int64, error) {
+	filter, params := getSpannerShardFilter(t, dialect, shardId)
+	stmt := spanner.Statement{
+		SQL:    fmt.Sprintf("SELECT COUNT(*) FROM %s%s", quoteSpannerIdentifier(dialect, t.spName), filter),
+		Params: params,
+	}
+	var count int64
+	err := spClient.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		return row.Columns(&count)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("couldn't count the rows of %s in Spanner: %w", t.spName, err)
+	}
+	return count, nil
+}
+
+// normalizeSpannerValue returns the representation of a Spanner value used in
+// the checksums, along with its type code which drives the normalization of
+// the matching source value. NULLs are returned as nil.
+func normalizeSpannerValue(v spanner.GenericColumnValue) (*string, sppb.TypeCode, error) {
+	code := v.Type.GetCode()
+	var s string
+	switch code {
+	case sppb.TypeCode_BOOL:
+		var b spanner.NullBool
+		if err := v.Decode(&b); err != nil || !b.Valid {
+			return nil, code, err
+		}
+		s = "0"
+		if b.Bool {
+			s = "1"
+		}
+	case sppb.TypeCode_INT64:
+		var i spanner.NullInt64
+		if err := v.Decode(&i); err != nil || !i.Valid {
+			return nil, code, err
+		}
+		s = strconv.FormatInt(i.Int64, 10)
+	case sppb.TypeCode_FLOAT64:
+		var f spanner.NullFloat64
+		if err := v.Decode(&f); err != nil || !f.Valid {
+			return nil, code, err
+		}
+		s = strconv.FormatFloat(f.Float64, 'g', -1, 64)
+	case sppb.TypeCode_NUMERIC:
+		if v.Type.GetTypeAnnotation() == sppb.TypeAnnotationCode_PG_NUMERIC {
+			var n spanner.PGNumeric
+			if err := v.Decode(&n); err != nil || !n.Valid {
+				return nil, code, err
+			}
+			s = normalizeSourceValue([]byte(n.Numeric), code)
+			break
+		}
+		var n spanner.NullNumeric
+		if err := v.Decode(&n); err != nil || !n.Valid {
+			return nil, code, err
+		}
+		s = n.Numeric.FloatString(spanner.NumericScaleDigits)
+	case sppb.TypeCode_TIMESTAMP:
+		var ts spanner.NullTime
+		if err := v.Decode(&ts); err != nil || !ts.Valid {
+			return nil, code, err
+		}
+		s = ts.Time.UTC().Format(VALIDATE_TIMESTAMP_LAYOUT)
+	case sppb.TypeCode_DATE:
+		var d spanner.NullDate
+		if err := v.Decode(&d); err != nil || !d.Valid {
+			return nil, code, err
+		}
+		s = d.Date.String()
+	case sppb.TypeCode_BYTES:
+		var b []byte
+		if err := v.Decode(&b); err != nil || b == nil {
+			return nil, code, err
+		}
+		s = string(b)
+	case sppb.TypeCode_JSON:
+		var j spanner.NullJSON
+		if v.Type.GetTypeAnnotation() == sppb.TypeAnnotationCode_PG_JSONB {
+			var pj spanner.PGJsonB
+			if err := v.Decode(&pj); err != nil || !pj.Valid {
+				return nil, code, err
+			}
+			j = spanner.NullJSON{Value: pj.Value, Valid: true}
+		} else if err := v.Decode(&j); err != nil || !j.Valid {
+			return nil, code, err
+		}
+		bArr, err := json.Marshal(j.Value)
+		if err != nil {
+			return nil, code, err
+		}
+		s = string(bArr)
+	default:
+		var str spanner.NullString
+		if err := v.Decode(&str); err != nil || !str.Valid {
+			return nil, code, err
+		}
+		s = str.StringVal
+	}
+	return &s, code, nil
+}
+
+// normalizeSourceValue returns the representation of a MySQL value used in
+// the checksums, converted the same way as the Spanner value of the given
+// type. Values which can't be converted are kept as is, and so make the
+// checksums differ.
+func normalizeSourceValue(v []byte, code sppb.TypeCode) string {
+	s := string(v)
+	switch code {
+	case sppb.TypeCode_BOOL:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			if i != 0 {
+				return "1"
+			}
+			return "0"
+		}
+	case sppb.TypeCode_FLOAT64:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	case sppb.TypeCode_NUMERIC:
+		if r, ok := new(big.Rat).SetString(s); ok {
+			return r.FloatString(spanner.NumericScaleDigits)
+		}
+	case sppb.TypeCode_TIMESTAMP:
+		if t, err := time.Parse(VALIDATE_TIMESTAMP_LAYOUT, s); err == nil {
+			return t.Format(VALIDATE_TIMESTAMP_LAYOUT)
+		}
+	case sppb.TypeCode_DATE:
+		if t, err := time.Parse(VALIDATE_DATE_LAYOUT, s); err == nil {
+			return t.Format(VALIDATE_DATE_LAYOUT)
+		}
+	case sppb.TypeCode_JSON:
+		var j interface{}
+		if err := json.Unmarshal(v, &j); err == nil {
+			if bArr, err := json.Marshal(j); err == nil {
+				return string(bArr)
+			}
+		}
+	}
+	return s
+}
+
+// hashRow returns the hash of the normalized values of a row. NULLs are hashed
+// differently from any value.
+func hashRow(values []*string) uint64 {
+	h := fnv.New64a()
+	for _, v := range values {
+		if v == nil {
+			h.Write([]byte{1})
+		} else {
+			h.Write([]byte{0})
+			h.Write([]byte(*v))
+		}
+		h.Write([]byte{0xff})
+	}
+	return h.Sum64()
+}
+
+// spannerChecksum returns the order independent checksum of the rows of the
+// table in the shard, and the type of every compared column.
+func spannerChecksum(ctx context.Context, spClient *spanner.Client, t validatedTable, dialect, shardId string) (uint64, []sppb.TypeCode, error) {
+	var cols []string
+	for _, c := range t.spCols {
+		cols = append(cols, quoteSpannerIdentifier(dialect, c))
+	}
+	filter, params := getSpannerShardFilter(t, dialect, shardId)
+	stmt := spanner.Statement{
+		SQL:    fmt.Sprintf("SELECT %s FROM %s%s", strings.Join(cols, ", "), quoteSpannerIdentifier(dialect, t.spName), filter),
+		Params: params,
+	}
+	var sum uint64
+	codes := make([]sppb.TypeCode, len(cols))
+	err := spClient.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		values := make([]*string, row.Size())
+		for i := 0; i < row.Size(); i++ {
+			var gcv spanner.GenericColumnValue
+			if err := row.Column(i, &gcv); err != nil {
+				return err
+			}
+			v, code, err := normalizeSpannerValue(gcv)
+			if err != nil {
+				return fmt.Errorf("can't decode column %s: %v", t.spCols[i], err)
+			}
+			values[i], codes[i] = v, code
+		}
+		sum += hashRow(values)
+		return nil
+	})
+	if err != nil {
+		return 0, nil, fmt.Errorf("couldn't read the rows of %s in Spanner: %w", t.spName, err)
+	}
+	return sum, codes, nil
+}
+
+// sourceChecksum returns the order independent checksum of the rows of the
+// table in the source shard, with the values normalized according to the
+// types of the Spanner columns.
+func (cfg *config) sourceChecksum(ctx context.Context, db *sql.DB, t validatedTable, codes []sppb.TypeCode) (uint64, error) {
+	var cols []string
+	for _, c := range t.srcCols {
+		cols = append(cols, cfg.quoteSourceIdentifier(c))
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s", strings.Join(cols, ", "), cfg.quoteSourceIdentifier(t.srcName)))
+	if err != nil {
+		return 0, fmt.Errorf("couldn't read the rows of %s in the source: %v", t.srcName, err)
+	}
+	defer rows.Close()
+	var sum uint64
+	raw := make([]sql.RawBytes, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range raw {
+		dest[i] = &raw[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return 0, fmt.Errorf("couldn't read the rows of %s in the source: %v", t.srcName, err)
+		}
+		values := make([]*string, len(raw))
+		for i, v := range raw {
+			if v == nil {
+				continue
+			}
+			s := normalizeSourceValue(v, sppb.TypeCode_STRING)
+			if i < len(codes) {
+				s = normalizeSourceValue(v, codes[i])
+			}
+			values[i] = &s
+		}
+		sum += hashRow(values)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("couldn't read the rows of %s in the source: %v", t.srcName, err)
+	}
+	return sum, nil
+}
+
+// reconcileTable compares the row counts of the table between Spanner and the
+// source shard, and their checksums if Spanner has at most validateMaxRows
+// rows.
+func (cfg *config) reconcileTable(ctx context.Context, spClient *spanner.Client, db *sql.DB, t validatedTable, dialect, shardId string) tableReconciliation {
+	res := tableReconciliation{Table: t.spName, ShardId: shardId}
+	var err error
+	if res.SpannerRows, err = countSpannerRows(ctx, spClient, t, dialect, shardId); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", cfg.quoteSourceIdentifier(t.srcName))).Scan(&res.SourceRows); err != nil {
+		res.Error = fmt.Sprintf("couldn't count the rows of %s in the source: %v", t.srcName, err)
+		return res
+	}
+	res.CountMatch = res.SpannerRows == res.SourceRows
+	if !res.CountMatch || res.SpannerRows > int64(cfg.validateMaxRows) {
+		return res
+	}
+	spSum, codes, err := spannerChecksum(ctx, spClient, t, dialect, shardId)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	srcSum, err := cfg.sourceChecksum(ctx, db, t, codes)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.ChecksumCompared = true
+	res.ChecksumMatch = spSum == srcSum
+	return res
+}
+
+// validateShards compares every table of the session file between Spanner and
+// each of the source shards, and returns the reconciliation report. Tables
+// are looked up in each of the replicated databases.
+func (cfg *config) validateShards(ctx context.Context, dbs []string, shards []interface{}) (reconciliationReport, error) {
+	report := reconciliationReport{GeneratedAt: time.Now().UTC()}
+	sessionJSON, err := readGcsFile(ctx, cfg.sessionFilePath)
+	if err != nil {
+		return report, err
+	}
+	tables, err := getValidatedTables(sessionJSON, cfg.validateTables)
+	if err != nil {
+		return report, err
+	}
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return report, fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	for _, dbId := range dbs {
+		dbUri := cfg.getDbUri(dbId)
+		dialect, err := spanneradmin.GetDatabaseDialect(ctx, adminClient, dbUri)
+		if err != nil {
+			return report, err
+		}
+		spClient, err := getClients(ctx).NewSpannerClient(ctx, dbUri)
+		if err != nil {
+			return report, fmt.Errorf("could not create spanner client for %s: %v", dbUri, err)
+		}
+		defer spClient.Close()
+		dbTables, err := getSpannerTables(ctx, spClient, dialect)
+		if err != nil {
+			return report, err
+		}
+		for _, s := range shards {
+			shard, ok := s.(map[string]interface{})
+			if !ok {
+				return report, fmt.Errorf("shard %v is not a json object", s)
+			}
+			shardId, _ := shard["logicalShardId"].(string)
+			connStr, err := cfg.getShardConnectionString(shard)
+			if err != nil {
+				return report, err
+			}
+			db, err := sql.Open(cfg.getShardDriverName(), connStr)
+			if err != nil {
+				return report, fmt.Errorf("could not connect to shard %s: %v", shardId, err)
+			}
+			for _, t := range tables {
+				if !dbTables[t.spName] {
+					continue
+				}
+				logger.FromContext(ctx).Info(fmt.Sprintf("Validating table %s of shard %s...", t.spName, shardId))
+				report.Tables = append(report.Tables, cfg.reconcileTable(ctx, spClient, db, t, dialect, shardId))
+			}
+			db.Close()
+		}
+	}
+	for _, r := range report.Tables {
+		if r.Error != "" || !r.CountMatch || (r.ChecksumCompared && !r.ChecksumMatch) {
+			report.Mismatches++
+		}
+	}
+	return report, nil
+}
+
+func logReconciliationReport(ctx context.Context, report reconciliationReport) {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "%d table(s) validated, %d mismatch(es):\n", len(report.Tables), report.Mismatches)
+	fmt.Fprintf(&msg, "%-30s %-20s %12s %12s %-10s %s", "TABLE", "SHARD", "SPANNER ROWS", "SOURCE ROWS", "CHECKSUM", "ERROR")
+	for _, r := range report.Tables {
+		checksum := "skipped"
+		if r.ChecksumCompared && r.ChecksumMatch {
+			checksum = "match"
+		} else if r.ChecksumCompared {
+			checksum = "MISMATCH"
+		}
+		fmt.Fprintf(&msg, "\n%-30s %-20s %12d %12d %-10s %s", r.Table, r.ShardId, r.SpannerRows, r.SourceRows, checksum, r.Error)
+	}
+	logger.FromContext(ctx).Info(msg.String())
+}
+
+// writeReconciliationReport writes the report to validateReportPath in json
+// format, if set.
+func (cfg *config) writeReconciliationReport(ctx context.Context, report reconciliationReport) error {
+	if cfg.validateReportPath == "" {
+		return nil
+	}
+	bArr, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not serialize reconciliation report: %v", err)
+	}
+	if err := ioutil.WriteFile(cfg.validateReportPath, bArr, 0644); err != nil {
+		return fmt.Errorf("could not write reconciliation report: %v", err)
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Wrote reconciliation report to %s", cfg.validateReportPath))
+	return nil
+}
+
+// runValidation compares the tables between Spanner and the source shards,
+// logs the reconciliation report and writes it to validateReportPath if
+// set. An error is returned if any table does not match.
+func (cfg *config) runValidation(ctx context.Context) error {
+	shards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return err
+	}
+	report, err := cfg.validateShards(ctx, cfg.getDatabaseIds(), shards)
+	if err != nil {
+		return err
+	}
+	logReconciliationReport(ctx, report)
+	if err := cfg.writeReconciliationReport(ctx, report); err != nil {
+		return err
+	}
+	if report.Mismatches > 0 {
+		return fmt.Errorf("%d table(s) differ between Spanner and the source shards", report.Mismatches)
+	}
+	return nil
+}
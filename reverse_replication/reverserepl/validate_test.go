@@ -0,0 +1,167 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestGetValidatedTables(t *testing.T) {
+	session := `{
+		"SpSchema": {
+			"t1": {"Name": "orders", "ColIds": ["c1", "c2", "c3"], "ShardIdColumn": "c3",
+				"ColDefs": {"c1": {"Name": "id"}, "c2": {"Name": "customer"}, "c3": {"Name": "migration_shard_id"}}},
+			"t2": {"Name": "customers", "ColIds": ["c4"], "ColDefs": {"c4": {"Name": "id"}}},
+			"t3": {"Name": "spanner_only", "ColIds": ["c5"], "ColDefs": {"c5": {"Name": "id"}}}
+		},
+		"SrcSchema": {
+			"t1": {"Name": "Orders", "ColDefs": {"c1": {"Name": "order_id"}, "c2": {"Name": "customer"}}},
+			"t2": {"Name": "Customers", "ColDefs": {"c4": {"Name": "id"}}}
+		}
+	}`
+	orders := validatedTable{spName: "orders", srcName: "Orders", spCols: []string{"id", "customer"}, srcCols: []string{"order_id", "customer"}, shardIdColumn: "migration_shard_id"}
+	customers := validatedTable{spName: "customers", srcName: "Customers", spCols: []string{"id"}, srcCols: []string{"id"}}
+	tests := []struct {
+		name        string
+		selected    string
+		want        []validatedTable
+		errContains string
+	}{
+		{
+			name: "all the tables of both schemas",
+			want: []validatedTable{customers, orders},
+		},
+		{
+			name:     "selected tables",
+			selected: " orders ,",
+			want:     []validatedTable{orders},
+		},
+		{
+			name:        "unknown selected table",
+			selected:    "orders,invoices",
+			errContains: "table invoices is not in the session file",
+		},
+		{
+			name:        "table without source schema",
+			selected:    "spanner_only",
+			errContains: "table spanner_only is not in the session file",
+		},
+	}
+	for _, tc := range tests {
+		tables, err := getValidatedTables([]byte(session), tc.selected)
+		if tc.errContains == "" {
+			assert.Nil(t, err, tc.name)
+			assert.Equal(t, tc.want, tables, tc.name)
+		} else if assert.NotNil(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.errContains, tc.name)
+		}
+	}
+}
+
+func TestNormalizeValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		spValue spanner.GenericColumnValue
+		source  string
+		want    string
+	}{
+		{
+			name:    "bool",
+			spValue: spanner.GenericColumnValue{Type: &sppb.Type{Code: sppb.TypeCode_BOOL}, Value: structpb.NewBoolValue(true)},
+			source:  "1",
+			want:    "1",
+		},
+		{
+			name:    "int64",
+			spValue: spanner.GenericColumnValue{Type: &sppb.Type{Code: sppb.TypeCode_INT64}, Value: structpb.NewStringValue("-42")},
+			source:  "-42",
+			want:    "-42",
+		},
+		{
+			name:    "float64",
+			spValue: spanner.GenericColumnValue{Type: &sppb.Type{Code: sppb.TypeCode_FLOAT64}, Value: structpb.NewNumberValue(1.5)},
+			source:  "1.50",
+			want:    "1.5",
+		},
+		{
+			name:    "numeric",
+			spValue: spanner.GenericColumnValue{Type: &sppb.Type{Code: sppb.TypeCode_NUMERIC}, Value: structpb.NewStringValue("12.5")},
+			source:  "12.500",
+			want:    "12.500000000",
+		},
+		{
+			name:    "timestamp",
+			spValue: spanner.GenericColumnValue{Type: &sppb.Type{Code: sppb.TypeCode_TIMESTAMP}, Value: structpb.NewStringValue("2023-06-01T10:00:00.5Z")},
+			source:  "2023-06-01 10:00:00.500000",
+			want:    "2023-06-01 10:00:00.5",
+		},
+		{
+			name:    "date",
+			spValue: spanner.GenericColumnValue{Type: &sppb.Type{Code: sppb.TypeCode_DATE}, Value: structpb.NewStringValue("2023-06-01")},
+			source:  "2023-06-01",
+			want:    "2023-06-01",
+		},
+		{
+			name:    "json",
+			spValue: spanner.GenericColumnValue{Type: &sppb.Type{Code: sppb.TypeCode_JSON}, Value: structpb.NewStringValue(`{"b":1,"a":"x"}`)},
+			source:  `{"a": "x", "b": 1}`,
+			want:    `{"a":"x","b":1}`,
+		},
+		{
+			name:    "string",
+			spValue: spanner.GenericColumnValue{Type: &sppb.Type{Code: sppb.TypeCode_STRING}, Value: structpb.NewStringValue("abc")},
+			source:  "abc",
+			want:    "abc",
+		},
+	}
+	for _, tc := range tests {
+		spValue, code, err := normalizeSpannerValue(tc.spValue)
+		if !assert.Nil(t, err, tc.name) || !assert.NotNil(t, spValue, tc.name) {
+			continue
+		}
+		assert.Equal(t, tc.want, *spValue, tc.name)
+		assert.Equal(t, tc.want, normalizeSourceValue([]byte(tc.source), code), tc.name)
+	}
+}
+
+func TestNormalizeSpannerNull(t *testing.T) {
+	for _, code := range []sppb.TypeCode{sppb.TypeCode_BOOL, sppb.TypeCode_INT64, sppb.TypeCode_TIMESTAMP, sppb.TypeCode_STRING} {
+		v, _, err := normalizeSpannerValue(spanner.GenericColumnValue{Type: &sppb.Type{Code: code}, Value: structpb.NewNullValue()})
+		assert.Nil(t, err, code.String())
+		assert.Nil(t, v, code.String())
+	}
+}
+
+func TestHashRow(t *testing.T) {
+	empty, a, b, ab := "", "a", "b", "ab"
+	tests := []struct {
+		name  string
+		row   []*string
+		other []*string
+	}{
+		{name: "null and empty string", row: []*string{nil}, other: []*string{&empty}},
+		{name: "column order", row: []*string{&a, &b}, other: []*string{&b, &a}},
+		{name: "column boundaries", row: []*string{&ab, &empty}, other: []*string{&a, &b}},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, hashRow(tc.row), hashRow(tc.row), tc.name)
+		assert.NotEqual(t, hashRow(tc.row), hashRow(tc.other), tc.name)
+	}
+}
@@ -0,0 +1,181 @@
+package reverserepl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+)
+
+// Table in the metadata database recording the result of every scheduled
+// validation run.
+const VALIDATION_RUNS_TABLE = "ValidationRuns"
+
+// driftAlert is the message published to validateAlertTopic when the drift of
+// a validation run exceeds validateDriftThreshold.
+type driftAlert struct {
+	JobNamePrefix string    `json:"jobNamePrefix"`
+	RunAt         time.Time `json:"runAt"`
+	Drift         float64   `json:"drift"`
+	Threshold     float64   `json:"threshold"`
+	Mismatches    int       `json:"mismatches"`
+	Tables        int       `json:"tables"`
+}
+
+// getValidationRunsTableDdl returns the statement creating the validation runs
+// table in a metadata database of the given dialect.
+func getValidationRunsTableDdl(dialect string) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+	"JobNamePrefix" VARCHAR NOT NULL,
+	"RunAt" SPANNER.COMMIT_TIMESTAMP NOT NULL,
+	"TablesValidated" BIGINT NOT NULL,
+	"Mismatches" BIGINT NOT NULL,
+	"Drift" FLOAT8 NOT NULL,
+	"Report" VARCHAR NOT NULL,
+	PRIMARY KEY ("JobNamePrefix", "RunAt")
+)`, VALIDATION_RUNS_TABLE)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	JobNamePrefix STRING(MAX) NOT NULL,
+	RunAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+	TablesValidated INT64 NOT NULL,
+	Mismatches INT64 NOT NULL,
+	Drift FLOAT64 NOT NULL,
+	Report STRING(MAX) NOT NULL,
+) PRIMARY KEY (JobNamePrefix, RunAt)`, VALIDATION_RUNS_TABLE)
+}
+
+// getDrift returns the fraction of the validated tables, per shard, which
+// differ between Spanner and the source.
+func getDrift(report reconciliationReport) float64 {
+	if len(report.Tables) == 0 {
+		return 0
+	}
+	return float64(report.Mismatches) / float64(len(report.Tables))
+}
+
+// alertDrift logs that the drift of a validation run exceeds the threshold,
+// and publishes the alert to validateAlertTopic if set.
+func (cfg *config) alertDrift(ctx context.Context, report reconciliationReport) error {
+	alert := driftAlert{
+		JobNamePrefix: cfg.jobNamePrefix,
+		RunAt:         report.GeneratedAt,
+		Drift:         getDrift(report),
+		Threshold:     cfg.validateDriftThreshold,
+		Mismatches:    report.Mismatches,
+		Tables:        len(report.Tables),
+	}
+	logger.FromContext(ctx).Warn(fmt.Sprintf("ALERT: %d of %d validated table(s) differ between Spanner and the source shards for pipeline %s (drift %.4f, threshold %.4f)",
+		alert.Mismatches, alert.Tables, cfg.jobNamePrefix, alert.Drift, alert.Threshold))
+	if cfg.validateAlertTopic == "" {
+		return nil
+	}
+	bArr, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("could not serialize drift alert: %v", err)
+	}
+	client, err := getClients(ctx).NewPubsubClient(ctx, cfg.projectId)
+	if err != nil {
+		return fmt.Errorf("could not create pubsub client: %v", err)
+	}
+	defer client.Close()
+	topic := client.Topic(cfg.validateAlertTopic)
+	defer topic.Stop()
+	if _, err := topic.Publish(ctx, &pubsub.Message{Data: bArr}).Get(ctx); err != nil {
+		return fmt.Errorf("could not publish drift alert to %s: %v", cfg.validateAlertTopic, err)
+	}
+	return nil
+}
+
+// isPipelineActive returns true if the ordering job of any of the databases
+// in dbs is still running.
+func (cfg *config) isPipelineActive(ctx context.Context, dbs []string) (bool, error) {
+	orderingJobNames := cfg.getOrderingJobNames(dbs)
+	states, err := cfg.getPipelineJobStates(ctx, orderingJobNames)
+	if err != nil {
+		return false, err
+	}
+	for _, name := range orderingJobNames {
+		for _, state := range states[name] {
+			if !isTerminalJobState(state) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// runScheduledValidation validates the tables between Spanner and the source
+// shards every validateEvery while the pipeline is running. The result of
+// every run is recorded in the validation runs table of the metadata
+// database, and an alert is raised when the drift exceeds
+// validateDriftThreshold. A failed run is reported and retried at the next
+// interval.
+func (cfg *config) runScheduledValidation(ctx context.Context) error {
+	dbs := cfg.getDatabaseIds()
+	active, err := cfg.isPipelineActive(ctx, dbs)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return fmt.Errorf("pipeline %s has no running ordering job. Scheduled validation requires a running pipeline", cfg.jobNamePrefix)
+	}
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	store, err := getClients(ctx).openMetadataStore(ctx, cfg, adminClient)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	for {
+		logger.FromContext(ctx).Info(fmt.Sprintf("Starting validation run at %s", time.Now().UTC().Format(time.RFC3339)))
+		if err := cfg.runValidationOnce(ctx, store, dbs); err != nil {
+			logger.FromContext(ctx).Error(fmt.Sprintf("Error in validation run: %v", err))
+		}
+		active, err := cfg.isPipelineActive(ctx, dbs)
+		if err != nil {
+			logger.FromContext(ctx).Error(fmt.Sprintf("Error in checking the pipeline jobs: %v", err))
+		} else if !active {
+			logger.FromContext(ctx).Info(fmt.Sprintf("Pipeline %s is no longer running, stopping the scheduled validation", cfg.jobNamePrefix))
+			return nil
+		}
+		logger.FromContext(ctx).Info(fmt.Sprintf("Next validation run at %s", time.Now().Add(cfg.validateEvery).UTC().Format(time.RFC3339)))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(cfg.validateEvery):
+		}
+	}
+}
+
+// runValidationOnce runs a single scheduled validation, records its result and
+// raises an alert if the drift exceeds the threshold.
+func (cfg *config) runValidationOnce(ctx context.Context, store metadataStore, dbs []string) error {
+	shards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return err
+	}
+	report, err := cfg.validateShards(ctx, dbs, shards)
+	if err != nil {
+		return err
+	}
+	logReconciliationReport(ctx, report)
+	if err := cfg.writeReconciliationReport(ctx, report); err != nil {
+		logger.FromContext(ctx).Error(fmt.Sprintf("Error in writing the reconciliation report: %v", err))
+	}
+	if err := store.RecordValidationRun(ctx, report); err != nil {
+		logger.FromContext(ctx).Error(fmt.Sprintf("Error in recording the validation run: %v", err))
+	}
+	if report.Mismatches > 0 && getDrift(report) > cfg.validateDriftThreshold {
+		return cfg.alertDrift(ctx, report)
+	}
+	return nil
+}
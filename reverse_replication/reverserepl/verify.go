@@ -0,0 +1,162 @@
+package reverserepl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+)
+
+const (
+	// Column of the verification table holding the marker id.
+	VERIFY_ID_COLUMN = "id"
+	// Column routing a row to its logical shard in sharded migrations.
+	SHARD_ID_COLUMN = "migration_shard_id"
+	// Interval between two checks for the marker row in the source.
+	VERIFY_POLL_INTERVAL = 5 * time.Second
+)
+
+// verifyResult is the outcome of the round trip of a marker row to one shard.
+type verifyResult struct {
+	shardId string
+	latency time.Duration
+	err     error
+}
+
+// hasShardIdColumn returns true if the verification table in Spanner has the
+// column used to route rows to logical shards.
+func (cfg *config) hasShardIdColumn(ctx context.Context, spClient *spanner.Client, dialect string) (bool, error) {
+	// Tables are in the unnamed schema in GoogleSQL, and in the public one in
+	// PostgreSQL.
+	schema := ""
+	if dialect == constants.DIALECT_POSTGRESQL {
+		schema = "public"
+	}
+	stmt := spanner.Statement{
+		SQL: fmt.Sprintf(`SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = '%s' AND table_name = %s AND column_name = %s`, schema, getQueryParam(dialect, 1), getQueryParam(dialect, 2)),
+		Params: map[string]interface{}{
+			"p1": cfg.verifyTable,
+			"p2": SHARD_ID_COLUMN,
+		},
+	}
+	var count int64
+	err := spClient.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		return row.Columns(&count)
+	})
+	if err != nil {
+		return false, fmt.Errorf("couldn't read columns of %s: %w", cfg.verifyTable, err)
+	}
+	return count > 0, nil
+}
+
+// waitForMarker polls the shard until the marker row shows up, or is gone if
+// present is false, or the deadline passes.
+func (cfg *config) waitForMarker(ctx context.Context, db *sql.DB, markerId string, present bool, deadline time.Time) error {
+	q := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = %s", cfg.quoteSourceIdentifier(cfg.verifyTable), cfg.quoteSourceIdentifier(VERIFY_ID_COLUMN), cfg.getSourceQueryParam(1))
+	for {
+		var count int64
+		if err := db.QueryRowContext(ctx, q, markerId).Scan(&count); err != nil {
+			return fmt.Errorf("could not read %s: %v", cfg.verifyTable, err)
+		}
+		if (count > 0) == present {
+			return nil
+		}
+		if time.Now().Add(VERIFY_POLL_INTERVAL).After(deadline) {
+			if !present {
+				return fmt.Errorf("deletion of marker row %s did not reach the source by %s", markerId, deadline.Format(time.RFC3339))
+			}
+			return fmt.Errorf("marker row %s did not reach the source by %s", markerId, deadline.Format(time.RFC3339))
+		}
+		time.Sleep(VERIFY_POLL_INTERVAL)
+	}
+}
+
+// verifyShard writes a marker row for the shard into the verification table
+// in Spanner, waits for the pipeline to replicate it to the shard and deletes
+// it again.
+func (cfg *config) verifyShard(ctx context.Context, spClient *spanner.Client, shard map[string]interface{}, sharded bool) verifyResult {
+	shardId, _ := shard["logicalShardId"].(string)
+	res := verifyResult{shardId: shardId}
+	connStr, err := cfg.getShardConnectionString(shard)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	db, err := sql.Open(cfg.getShardDriverName(), connStr)
+	if err != nil {
+		res.err = fmt.Errorf("could not connect to shard: %v", err)
+		return res
+	}
+	defer db.Close()
+
+	markerId := fmt.Sprintf("%s-verify-%s-%d", cfg.jobNamePrefix, shardId, time.Now().UnixNano())
+	cols := []string{VERIFY_ID_COLUMN}
+	vals := []interface{}{markerId}
+	if sharded {
+		cols = append(cols, SHARD_ID_COLUMN)
+		vals = append(vals, shardId)
+	}
+	commitTs, err := spClient.Apply(ctx, []*spanner.Mutation{spanner.Insert(cfg.verifyTable, cols, vals)})
+	if err != nil {
+		res.err = fmt.Errorf("could not write marker row to %s: %v", cfg.verifyTable, err)
+		return res
+	}
+	defer func() {
+		// Deleting the marker also replicates the delete to the shard.
+		if _, err := spClient.Apply(ctx, []*spanner.Mutation{spanner.Delete(cfg.verifyTable, spanner.Key{markerId})}); err != nil {
+			logger.FromContext(ctx).Warn(fmt.Sprintf("could not delete marker row %s from %s: %v", markerId, cfg.verifyTable, err))
+		}
+	}()
+	if err := cfg.waitForMarker(ctx, db, markerId, true, commitTs.Add(cfg.verifyTimeout)); err != nil {
+		res.err = err
+		return res
+	}
+	res.latency = time.Since(commitTs)
+	return res
+}
+
+// verifyPipeline proves that the launched pipeline replicates changes end to
+// end, by writing a marker row per shard into verifyTable in Spanner and
+// waiting for each of them to show up in the source shard.
+func (cfg *config) verifyPipeline(ctx context.Context, spClient *spanner.Client, shards []interface{}, dialect string) error {
+	sharded, err := cfg.hasShardIdColumn(ctx, spClient, dialect)
+	if err != nil {
+		return err
+	}
+	if !sharded && len(shards) > 1 {
+		return fmt.Errorf("table %s has no %s column, which is needed to route the marker rows to each of the %d shards", cfg.verifyTable, SHARD_ID_COLUMN, len(shards))
+	}
+	logger.FromContext(ctx).Info(fmt.Sprintf("Verifying the pipeline by writing a marker row per shard to %s, waiting up to %s...", cfg.verifyTable, cfg.verifyTimeout))
+	results := make([]verifyResult, len(shards))
+	wg := &sync.WaitGroup{}
+	for i, s := range shards {
+		shard, ok := s.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("shard at index %d is not a json object", i)
+		}
+		wg.Add(1)
+		go func(i int, shard map[string]interface{}) {
+			defer wg.Done()
+			results[i] = cfg.verifyShard(ctx, spClient, shard, sharded)
+		}(i, shard)
+	}
+	wg.Wait()
+	failed := 0
+	for _, res := range results {
+		if res.err != nil {
+			logger.FromContext(ctx).Warn(fmt.Sprintf("shard %s: FAILED: %v", res.shardId, res.err))
+			failed++
+			continue
+		}
+		logger.FromContext(ctx).Info(fmt.Sprintf("shard %s: marker row replicated in %s", res.shardId, res.latency.Round(time.Millisecond)))
+	}
+	if failed > 0 {
+		return fmt.Errorf("pipeline verification failed for %d of %d shard(s)", failed, len(shards))
+	}
+	return nil
+}
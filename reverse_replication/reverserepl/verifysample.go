@@ -0,0 +1,521 @@
+package reverserepl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+const (
+	// Changes committed within this time before the sampling are left out,
+	// as the pipeline may not have replicated them yet.
+	VERIFY_SAMPLE_SETTLE_TIME = time.Minute
+	// Interval of the heartbeat records of the change stream queries.
+	VERIFY_SAMPLE_HEARTBEAT_MILLISECONDS = 10000
+)
+
+// changeStreamColumnType is the type of a column of a data change record.
+type changeStreamColumnType struct {
+	Name         string           `spanner:"name" json:"name"`
+	Type         spanner.NullJSON `spanner:"type" json:"type"`
+	IsPrimaryKey bool             `spanner:"is_primary_key" json:"is_primary_key"`
+}
+
+// changeStreamMod is a row changed by a data change record.
+type changeStreamMod struct {
+	Keys spanner.NullJSON `spanner:"keys" json:"keys"`
+}
+
+// dataChangeRecord is a data change record of a change stream, with the
+// fields the sampling needs.
+type dataChangeRecord struct {
+	CommitTimestamp time.Time                `spanner:"commit_timestamp" json:"commit_timestamp"`
+	TableName       string                   `spanner:"table_name" json:"table_name"`
+	ColumnTypes     []changeStreamColumnType `spanner:"column_types" json:"column_types"`
+	Mods            []changeStreamMod        `spanner:"mods" json:"mods"`
+	ModType         string                   `spanner:"mod_type" json:"mod_type"`
+}
+
+// childPartition is a partition of a change stream, read with its token.
+type childPartition struct {
+	Token string `spanner:"token" json:"token"`
+}
+
+// childPartitionsRecord lists the partitions of a change stream starting at
+// StartTimestamp.
+type childPartitionsRecord struct {
+	StartTimestamp  time.Time        `spanner:"start_timestamp" json:"start_timestamp"`
+	ChildPartitions []childPartition `spanner:"child_partitions" json:"child_partitions"`
+}
+
+// changeStreamRecord is a record read from a change stream. GoogleSQL
+// databases return the records as arrays holding a single record, and
+// PostgreSQL ones as json objects.
+type changeStreamRecord struct {
+	DataChangeRecord      []dataChangeRecord      `spanner:"data_change_record"`
+	ChildPartitionsRecord []childPartitionsRecord `spanner:"child_partitions_record"`
+}
+
+// sampledKey is the primary key of a row changed in the sampled window.
+type sampledKey struct {
+	table validatedTable
+	// Names of the key columns and their values, as query parameters.
+	cols   []string
+	values []interface{}
+	id     string
+}
+
+// shardSampleResult is the outcome of the verification of the sampled rows of
+// a source shard.
+type shardSampleResult struct {
+	ShardId    string   `json:"shardId"`
+	Sampled    int      `json:"sampled"`
+	Matched    int      `json:"matched"`
+	MatchRate  float64  `json:"matchRate"`
+	Mismatches []string `json:"mismatches,omitempty"`
+}
+
+// getChangeStreamQuery returns the query reading the partition of the change
+// stream passed as third parameter, or its initial partitions if NULL.
+func (cfg *config) getChangeStreamQuery(dialect string) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf(`SELECT * FROM "spanner"."read_json_%s"($1, $2, $3, $4, null)`, cfg.changeStreamName)
+	}
+	return fmt.Sprintf("SELECT ChangeRecord FROM READ_%s (start_timestamp => @p1, end_timestamp => @p2, partition_token => @p3, heartbeat_milliseconds => @p4)", cfg.changeStreamName)
+}
+
+// decodeChangeStreamRecord decodes a row returned by a change stream query.
+func decodeChangeStreamRecord(row *spanner.Row, dialect string) (changeStreamRecord, error) {
+	var rec changeStreamRecord
+	if dialect != constants.DIALECT_POSTGRESQL {
+		var records struct {
+			ChangeRecord []changeStreamRecord
+		}
+		if err := row.ToStructLenient(&records); err != nil {
+			return rec, err
+		}
+		for _, r := range records.ChangeRecord {
+			rec.DataChangeRecord = append(rec.DataChangeRecord, r.DataChangeRecord...)
+			rec.ChildPartitionsRecord = append(rec.ChildPartitionsRecord, r.ChildPartitionsRecord...)
+		}
+		return rec, nil
+	}
+	var gcv spanner.GenericColumnValue
+	if err := row.Column(0, &gcv); err != nil {
+		return rec, err
+	}
+	var record struct {
+		DataChangeRecord      *dataChangeRecord      `json:"data_change_record"`
+		ChildPartitionsRecord *childPartitionsRecord `json:"child_partitions_record"`
+	}
+	if err := json.Unmarshal([]byte(gcv.Value.GetStringValue()), &record); err != nil {
+		return rec, err
+	}
+	if record.DataChangeRecord != nil {
+		rec.DataChangeRecord = append(rec.DataChangeRecord, *record.DataChangeRecord)
+	}
+	if record.ChildPartitionsRecord != nil {
+		rec.ChildPartitionsRecord = append(rec.ChildPartitionsRecord, *record.ChildPartitionsRecord)
+	}
+	return rec, nil
+}
+
+// readChangeStream calls visit on every data change record of the change
+// stream committed between start and end, reading all its partitions.
+func (cfg *config) readChangeStream(ctx context.Context, spClient *spanner.Client, dialect string, start, end time.Time, visit func(dataChangeRecord)) error {
+	type partition struct {
+		token spanner.NullString
+		start time.Time
+	}
+	queue := []partition{{start: start}}
+	seen := make(map[string]bool)
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		stmt := spanner.Statement{
+			SQL: cfg.getChangeStreamQuery(dialect),
+			Params: map[string]interface{}{
+				"p1": p.start,
+				"p2": end,
+				"p3": p.token,
+				"p4": int64(VERIFY_SAMPLE_HEARTBEAT_MILLISECONDS),
+			},
+		}
+		err := spClient.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+			rec, err := decodeChangeStreamRecord(row, dialect)
+			if err != nil {
+				return fmt.Errorf("can't decode change record: %v", err)
+			}
+			for _, d := range rec.DataChangeRecord {
+				visit(d)
+			}
+			for _, c := range rec.ChildPartitionsRecord {
+				for _, child := range c.ChildPartitions {
+					if seen[child.Token] || !c.StartTimestamp.Before(end) {
+						continue
+					}
+					seen[child.Token] = true
+					queue = append(queue, partition{token: spanner.NullString{StringVal: child.Token, Valid: true}, start: c.StartTimestamp})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't read changestream %s: %w", cfg.changeStreamName, err)
+		}
+	}
+	return nil
+}
+
+// getKeyParam returns the query parameter matching a key value of a data
+// change record, of the given column type.
+func getKeyParam(value interface{}, typ spanner.NullJSON) (interface{}, error) {
+	t, _ := typ.Value.(map[string]interface{})
+	code, _ := t["code"].(string)
+	s := fmt.Sprint(value)
+	switch code {
+	case "INT64":
+		return strconv.ParseInt(s, 10, 64)
+	case "FLOAT64":
+		return strconv.ParseFloat(s, 64)
+	case "BOOL":
+		return strconv.ParseBool(s)
+	case "NUMERIC":
+		if t["type_annotation"] == "PG_NUMERIC" {
+			return spanner.PGNumeric{Numeric: s, Valid: true}, nil
+		}
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid numeric %s", s)
+		}
+		return r, nil
+	case "DATE":
+		return civil.ParseDate(s)
+	case "TIMESTAMP":
+		return time.Parse(time.RFC3339Nano, s)
+	case "BYTES":
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return s, nil
+}
+
+// getSampledKeys returns the keys of the rows changed by a data change record
+// of a table of tables. Deleted rows are left out, as the shard they were
+// replicated to is no longer known.
+func getSampledKeys(d dataChangeRecord, tables map[string]validatedTable) ([]sampledKey, error) {
+	t, ok := tables[d.TableName]
+	if !ok || d.ModType == "DELETE" {
+		return nil, nil
+	}
+	types := make(map[string]spanner.NullJSON)
+	for _, c := range d.ColumnTypes {
+		if c.IsPrimaryKey {
+			types[c.Name] = c.Type
+		}
+	}
+	var keys []sampledKey
+	for _, m := range d.Mods {
+		values, _ := m.Keys.Value.(map[string]interface{})
+		k := sampledKey{table: t}
+		for col := range values {
+			k.cols = append(k.cols, col)
+		}
+		sort.Strings(k.cols)
+		for _, col := range k.cols {
+			v, err := getKeyParam(values[col], types[col])
+			if err != nil {
+				return nil, fmt.Errorf("invalid key column %s of %s: %v", col, d.TableName, err)
+			}
+			k.values = append(k.values, v)
+		}
+		bArr, _ := json.Marshal(values)
+		k.id = fmt.Sprintf("%s%s", d.TableName, bArr)
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// sampleChangedKeys returns up to n keys, picked uniformly at random, of the
+// rows of the session file tables changed between start and end.
+func (cfg *config) sampleChangedKeys(ctx context.Context, spClient *spanner.Client, dialect string, tables []validatedTable, n int, start, end time.Time) ([]sampledKey, error) {
+	byName := make(map[string]validatedTable)
+	for _, t := range tables {
+		byName[t.spName] = t
+	}
+	var sample []sampledKey
+	sampled := make(map[string]bool)
+	seen := 0
+	var sampleErr error
+	err := cfg.readChangeStream(ctx, spClient, dialect, start, end, func(d dataChangeRecord) {
+		keys, err := getSampledKeys(d, byName)
+		if err != nil {
+			sampleErr = err
+		}
+		for _, k := range keys {
+			if sampled[k.id] {
+				continue
+			}
+			seen++
+			if len(sample) < n {
+				sample = append(sample, k)
+				sampled[k.id] = true
+			} else if i := rand.Intn(seen); i < n {
+				delete(sampled, sample[i].id)
+				sample[i] = k
+				sampled[k.id] = true
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sample, sampleErr
+}
+
+// readSampledRow returns the normalized values of the compared columns of the
+// row of the key in Spanner, along with their types and the shard id of the
+// row. found is false if the row no longer exists.
+func readSampledRow(ctx context.Context, spClient *spanner.Client, dialect string, k sampledKey) (values []*string, codes []sppb.TypeCode, shardId string, found bool, err error) {
+	var cols []string
+	for _, c := range k.table.spCols {
+		cols = append(cols, quoteSpannerIdentifier(dialect, c))
+	}
+	if k.table.shardIdColumn != "" {
+		cols = append(cols, quoteSpannerIdentifier(dialect, k.table.shardIdColumn))
+	}
+	var conds []string
+	params := make(map[string]interface{})
+	for i, col := range k.cols {
+		conds = append(conds, fmt.Sprintf("%s = %s", quoteSpannerIdentifier(dialect, col), getQueryParam(dialect, i+1)))
+		params[fmt.Sprintf("p%d", i+1)] = k.values[i]
+	}
+	stmt := spanner.Statement{
+		SQL:    fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(cols, ", "), quoteSpannerIdentifier(dialect, k.table.spName), strings.Join(conds, " AND ")),
+		Params: params,
+	}
+	err = spClient.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		found = true
+		values = make([]*string, len(k.table.spCols))
+		codes = make([]sppb.TypeCode, len(k.table.spCols))
+		for i := range k.table.spCols {
+			var gcv spanner.GenericColumnValue
+			if err := row.Column(i, &gcv); err != nil {
+				return err
+			}
+			v, code, err := normalizeSpannerValue(gcv)
+			if err != nil {
+				return fmt.Errorf("can't decode column %s: %v", k.table.spCols[i], err)
+			}
+			values[i], codes[i] = v, code
+		}
+		if k.table.shardIdColumn != "" {
+			var id spanner.NullString
+			if err := row.Column(len(k.table.spCols), &id); err != nil {
+				return err
+			}
+			shardId = id.StringVal
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, "", false, fmt.Errorf("couldn't read row %s in Spanner: %w", k.id, err)
+	}
+	return values, codes, shardId, found, nil
+}
+
+// checkSampledRow returns true if the source shard holds the same values as
+// Spanner for the row of the key.
+func (cfg *config) checkSampledRow(ctx context.Context, db *sql.DB, k sampledKey, values []*string, codes []sppb.TypeCode) (bool, error) {
+	var cols, conds []string
+	var params []interface{}
+	for _, c := range k.table.srcCols {
+		cols = append(cols, cfg.quoteSourceIdentifier(c))
+	}
+	loc := cfg.getSourceLocation()
+	for i, spCol := range k.table.spCols {
+		for _, keyCol := range k.cols {
+			if spCol != keyCol || values[i] == nil {
+				continue
+			}
+			conds = append(conds, fmt.Sprintf("%s = %s", cfg.quoteSourceIdentifier(k.table.srcCols[i]), cfg.getSourceQueryParam(len(conds)+1)))
+			v := interface{}(*values[i])
+			if codes[i] == sppb.TypeCode_TIMESTAMP {
+				if t, err := time.Parse(VALIDATE_TIMESTAMP_LAYOUT, *values[i]); err == nil {
+					v = t.In(loc).Format(BACKFILL_TIMESTAMP_LAYOUT)
+				}
+			}
+			params = append(params, v)
+		}
+	}
+	if len(conds) != len(k.cols) {
+		return false, fmt.Errorf("the key columns of %s are not all in the source table %s", k.table.spName, k.table.srcName)
+	}
+	raw := make([]sql.RawBytes, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range raw {
+		dest[i] = &raw[i]
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(cols, ", "), cfg.quoteSourceIdentifier(k.table.srcName), strings.Join(conds, " AND ")), params...)
+	if err != nil {
+		return false, fmt.Errorf("couldn't read row %s in the source: %v", k.id, err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return false, rows.Err()
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return false, fmt.Errorf("couldn't read row %s in the source: %v", k.id, err)
+	}
+	for i, v := range raw {
+		if (v == nil) != (values[i] == nil) {
+			return false, nil
+		}
+		if v != nil && normalizeSourceValue(v, codes[i]) != *values[i] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// verifySampledRows samples up to verifySample keys of the rows changed in
+// every replicated database over the last verifySampleWindow, as read from
+// its change stream, and checks that the source shard each row is replicated
+// to holds the same values as Spanner. Rows deleted since, and rows of tables
+// without a shard id column when there are several shards, are skipped.
+func (cfg *config) verifySampledRows(ctx context.Context) ([]shardSampleResult, int, error) {
+	shards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	shardIds, err := getLogicalShardIds(shards)
+	if err != nil {
+		return nil, 0, err
+	}
+	sessionJSON, err := readGcsFile(ctx, cfg.sessionFilePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	tables, err := getValidatedTables(sessionJSON, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	conns := make(map[string]*sql.DB)
+	results := make(map[string]*shardSampleResult)
+	for i, s := range shards {
+		connStr, err := cfg.getShardConnectionString(s.(map[string]interface{}))
+		if err != nil {
+			return nil, 0, err
+		}
+		db, err := sql.Open(cfg.getShardDriverName(), connStr)
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not connect to shard %s: %v", shardIds[i], err)
+		}
+		defer db.Close()
+		conns[shardIds[i]] = db
+		results[shardIds[i]] = &shardSampleResult{ShardId: shardIds[i]}
+	}
+	end := time.Now().Add(-VERIFY_SAMPLE_SETTLE_TIME)
+	skipped := 0
+	for _, dbId := range cfg.getDatabaseIds() {
+		dbUri := cfg.getDbUri(dbId)
+		dialect, err := spanneradmin.GetDatabaseDialect(ctx, adminClient, dbUri)
+		if err != nil {
+			return nil, 0, err
+		}
+		spClient, err := getClients(ctx).NewSpannerClient(ctx, dbUri)
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not create spanner client for %s: %v", dbUri, err)
+		}
+		defer spClient.Close()
+		logger.FromContext(ctx).Info(fmt.Sprintf("Sampling the rows changed in %s from %s...", dbUri, end.Add(-cfg.verifySampleWindow).UTC().Format(time.RFC3339)))
+		keys, err := cfg.sampleChangedKeys(ctx, spClient, dialect, tables, cfg.verifySample, end.Add(-cfg.verifySampleWindow), end)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, k := range keys {
+			values, codes, shardId, found, err := readSampledRow(ctx, spClient, dialect, k)
+			if err != nil {
+				return nil, 0, err
+			}
+			if k.table.shardIdColumn == "" && len(shardIds) == 1 {
+				shardId = shardIds[0]
+			}
+			res, ok := results[shardId]
+			if !found || !ok {
+				skipped++
+				continue
+			}
+			res.Sampled++
+			match, err := cfg.checkSampledRow(ctx, conns[shardId], k, values, codes)
+			if err != nil {
+				return nil, 0, err
+			}
+			if match {
+				res.Matched++
+			} else {
+				res.Mismatches = append(res.Mismatches, k.id)
+			}
+		}
+	}
+	var report []shardSampleResult
+	for _, id := range shardIds {
+		res := results[id]
+		if res.Sampled > 0 {
+			res.MatchRate = float64(res.Matched) / float64(res.Sampled)
+		}
+		report = append(report, *res)
+	}
+	return report, skipped, nil
+}
+
+func logSampleReport(ctx context.Context, report []shardSampleResult, skipped int) {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "Verified the sampled rows, %d sampled row(s) skipped as they were deleted since or their shard is unknown:\n", skipped)
+	fmt.Fprintf(&msg, "%-20s %8s %8s %10s", "SHARD", "SAMPLED", "MATCHED", "MATCH RATE")
+	for _, r := range report {
+		fmt.Fprintf(&msg, "\n%-20s %8d %8d %9.1f%%", r.ShardId, r.Sampled, r.Matched, 100*r.MatchRate)
+		for _, id := range r.Mismatches {
+			fmt.Fprintf(&msg, "\n  mismatch: %s", id)
+		}
+	}
+	logger.FromContext(ctx).Info(msg.String())
+}
+
+// runSampleVerification verifies a sample of the recently replicated rows and
+// logs the match rate of every shard. An error is returned if any sampled
+// row differs between Spanner and its source shard.
+func (cfg *config) runSampleVerification(ctx context.Context) error {
+	report, skipped, err := cfg.verifySampledRows(ctx)
+	if err != nil {
+		return err
+	}
+	logSampleReport(ctx, report, skipped)
+	mismatches := 0
+	for _, r := range report {
+		mismatches += len(r.Mismatches)
+	}
+	if mismatches > 0 {
+		return fmt.Errorf("%d sampled row(s) differ between Spanner and the source shards", mismatches)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+)
+
+// Table in the metadata database recording the failed jobs restarted by
+// WatchWorkflow.
+const RESTARTS_TABLE = "ReverseReplicationRestarts"
+
+// WatchOptions configures WatchWorkflow.
+type WatchOptions struct {
+	// Time between two checks of the jobs of the pipeline.
+	Interval time.Duration
+	// Number of failed jobs WatchWorkflow restarts before giving up.
+	MaxRestarts int
+}
+
+// JobRestart is a failed Dataflow job of the pipeline relaunched by
+// WatchWorkflow.
+type JobRestart struct {
+	JobName     string
+	FailedJobId string
+	RestartedAt time.Time
+}
+
+// getRestartsTableDdl returns the statement creating the restarts table in a
+// metadata database of the given dialect.
+func getRestartsTableDdl(dialect string) string {
+	if dialect == constants.DIALECT_POSTGRESQL {
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+	"JobNamePrefix" VARCHAR NOT NULL,
+	"RestartedAt" SPANNER.COMMIT_TIMESTAMP NOT NULL,
+	"JobName" VARCHAR NOT NULL,
+	"FailedJobId" VARCHAR NOT NULL,
+	"Tenant" VARCHAR,
+	PRIMARY KEY ("JobNamePrefix", "RestartedAt", "JobName")
+)`, RESTARTS_TABLE)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	JobNamePrefix STRING(MAX) NOT NULL,
+	RestartedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+	JobName STRING(MAX) NOT NULL,
+	FailedJobId STRING(MAX) NOT NULL,
+	Tenant STRING(MAX),
+) PRIMARY KEY (JobNamePrefix, RestartedAt, JobName)`, RESTARTS_TABLE)
+}
+
+// getFailedJobs returns the ordering and writer jobs of the pipeline whose
+// latest launch failed, keyed by job name. Jobs cancelled or drained by the
+// user are not restarted.
+func (cfg *config) getFailedJobs(ctx context.Context, jobNames []string) (map[string]*dataflowpb.Job, error) {
+	jobs, err := cfg.listPipelineJobs(ctx, jobNames)
+	if err != nil {
+		return nil, err
+	}
+	failed := make(map[string]*dataflowpb.Job)
+	for name, nameJobs := range jobs {
+		var latest *dataflowpb.Job
+		for _, job := range nameJobs {
+			if latest == nil || job.CreateTime.AsTime().After(latest.CreateTime.AsTime()) {
+				latest = job
+			}
+		}
+		if latest.CurrentState == dataflowpb.JobState_JOB_STATE_FAILED {
+			failed[name] = latest
+		}
+	}
+	return failed, nil
+}
+
+// restartFailedJobs relaunches the failed ordering and writer jobs of the
+// pipeline, at most budget of them, and records every restart. The ordering
+// jobs are relaunched in orderingRunMode as with -relaunchOrdering, and the
+// writer jobs with the shards file they were launched with.
+func (cfg *config) restartFailedJobs(ctx context.Context, budget int) ([]JobRestart, error) {
+	dbs := cfg.getDatabaseIds()
+	shards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read source shards: %v", err)
+	}
+	orderingDbs := make(map[string]string)
+	for i, name := range cfg.getOrderingJobNames(dbs) {
+		orderingDbs[name] = dbs[i]
+	}
+	writerNames := cfg.getWriterJobNames(len(partitionShards(shards, cfg.writerFanOut)))
+	writerPaths := make(map[string]string)
+	for i, name := range writerNames {
+		writerPaths[name] = cfg.sourceShardsFilePath
+		if len(writerNames) > 1 {
+			writerPaths[name] = cfg.getShardGroupFilePath(i)
+		}
+	}
+	failed, err := cfg.getFailedJobs(ctx, append(cfg.getOrderingJobNames(dbs), writerNames...))
+	if err != nil {
+		return nil, err
+	}
+	if len(failed) == 0 {
+		return nil, nil
+	}
+	var names []string
+	for name := range failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	adminClient, err := getClients(ctx).NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create database admin client: %v", err)
+	}
+	defer adminClient.Close()
+	store, err := getClients(ctx).openMetadataStore(ctx, cfg, adminClient)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the metadata db: %v", err)
+	}
+	defer store.Close()
+	c, err := getClients(ctx).NewDataflowAccessor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create dataflow client: %v", err)
+	}
+	defer c.Close()
+	var restarts []JobRestart
+	for _, name := range names {
+		if len(restarts) == budget {
+			return restarts, fmt.Errorf("job %s failed but the restart budget is exhausted. Please check the logs of its failed job %s", name, failed[name].Id)
+		}
+		if db, ok := orderingDbs[name]; ok {
+			err = cfg.relaunchOrderingJobs(ctx, []string{db})
+		} else {
+			var req *dataflowpb.LaunchFlexTemplateRequest
+			if req, err = cfg.getWriterJobRequest(name, writerPaths[name], nil); err == nil {
+				err = cfg.launchJob(ctx, c, req, "writer")
+			}
+		}
+		if err != nil {
+			return restarts, fmt.Errorf("could not restart failed job %s: %v", name, err)
+		}
+		if err := store.RecordJobRestart(ctx, name, failed[name].Id); err != nil {
+			return restarts, err
+		}
+		restarts = append(restarts, JobRestart{JobName: name, FailedJobId: failed[name].Id, RestartedAt: time.Now()})
+	}
+	return restarts, nil
+}
+
+// watchOnce checks the jobs of the pipeline described by j once, and restarts
+// the failed ones within budget.
+func watchOnce(ctx context.Context, j JobData, budget int) ([]JobRestart, error) {
+	cfg, err := newConfig(j)
+	if err != nil {
+		return nil, err
+	}
+	ctx = cfg.getWorkflowContext(ctx)
+	if err := cfg.checkTenantAccess(ctx); err != nil {
+		return nil, err
+	}
+	return cfg.restartFailedJobs(ctx, budget)
+}
+
+// WatchWorkflow checks the ordering and writer jobs of the pipeline described
+// by j every opts.Interval, and relaunches the ones which failed, calling
+// onRestart for each. Every restart is recorded in the restarts table of the
+// metadata database. It returns nil once ctx is cancelled, and an error once
+// more than opts.MaxRestarts jobs failed.
+func WatchWorkflow(ctx context.Context, j JobData, opts WatchOptions, onRestart func(JobRestart)) error {
+	restarted := 0
+	for {
+		restarts, err := watchOnce(ctx, j, opts.MaxRestarts-restarted)
+		for _, r := range restarts {
+			onRestart(r)
+		}
+		restarted += len(restarts)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.Interval):
+		}
+	}
+}
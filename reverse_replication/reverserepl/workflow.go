@@ -0,0 +1,603 @@
+// Package reverserepl launches and manages the reverse replication pipeline,
+// which replicates the changes made in Spanner back to the source shards
+// through a change stream, an ordering Dataflow job, Pub/Sub and writer
+// Dataflow jobs.
+//
+// The reverse_replication command is a thin wrapper around Main. Go programs
+// can manage the pipeline directly through CreateWorkflow, GetJobStatus,
+// GetResources, GetMetrics, PauseWorkflow, ResumeWorkflow and
+// DeleteWorkflow, configured by a JobData, and find the pipelines of a
+// project through ListWorkflows. Every call is configured by its own JobData,
+// so that several pipelines can be managed at the same time.
+package reverserepl
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/tracing"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Maximum time DeleteWorkflow and PauseWorkflow wait for the Dataflow jobs of
+// the pipeline to stop.
+const WORKFLOW_STOP_TIMEOUT = 30 * time.Minute
+
+// workflowJobNameRegex matches the names of the Dataflow jobs of a pipeline:
+// <jobNamePrefix>-ordering[-<db>], <jobNamePrefix>-writer[-<n>] and
+// <jobNamePrefix>-writer-reprocess. The suffixes are matched from the end, so
+// that a job name prefix such as app-writer-prod is kept whole.
+var workflowJobNameRegex = regexp.MustCompile(`^(.+)-(ordering(-[a-z0-9-]+)?|writer(-[0-9]+|-reprocess)?)$`)
+
+// JobData is the configuration of a reverse replication pipeline. The fields
+// have the same meaning and defaults as the launcher flags of the same name.
+type JobData struct {
+	ProjectId            string `json:"projectId"`
+	DataflowRegion       string `json:"dataflowRegion"`
+	JobNamePrefix        string `json:"jobNamePrefix,omitempty"`
+	ChangeStreamName     string `json:"changeStreamName,omitempty"`
+	InstanceId           string `json:"instanceId"`
+	DbName               string `json:"dbName"`
+	MetadataProject      string `json:"metadataProject,omitempty"`
+	MetadataInstance     string `json:"metadataInstance,omitempty"`
+	MetadataDatabase     string `json:"metadataDatabase,omitempty"`
+	MetadataTableSuffix  string `json:"metadataTableSuffix,omitempty"`
+	Tenant               string `json:"tenant,omitempty"`
+	PubSubDataTopicId    string `json:"pubSubDataTopicId,omitempty"`
+	SourceShardsFilePath string `json:"sourceShardsFilePath"`
+	SessionFilePath      string `json:"sessionFilePath,omitempty"`
+	// Type of the source shards, one of mysql, sqlserver, cassandra and
+	// oracle. oracle needs the allowExperimental flag.
+	SourceType             string `json:"sourceType,omitempty"`
+	SourceDbTimezoneOffset string `json:"sourceDbTimezoneOffset,omitempty"`
+	// Custom transformation applied by the writer jobs.
+	WriterTransformationJarPath   string `json:"writerTransformationJarPath,omitempty"`
+	WriterTransformationClassName string `json:"writerTransformationClassName,omitempty"`
+	// Built-in function assigning the changes to the source shards, one of
+	// identity, modulo and rangeMap.
+	ShardingFunction string `json:"shardingFunction,omitempty"`
+	ShardingColumn   string `json:"shardingColumn,omitempty"`
+	ShardingRanges   string `json:"shardingRanges,omitempty"`
+	// Minimum retention period of the change stream, between 1d and 7d, and
+	// its value capture type, NEW_ROW or NEW_ROW_AND_OLD_VALUES. Used when
+	// creating the change stream, and checked for an existing one.
+	ChangeStreamRetention        string `json:"changeStreamRetention,omitempty"`
+	ChangeStreamValueCaptureType string `json:"changeStreamValueCaptureType,omitempty"`
+	// Tags of the pipeline, each a key or key=value, e.g. env=prod, set on
+	// its Dataflow jobs to filter ListWorkflows.
+	Tags []string `json:"tags,omitempty"`
+	// Any other launcher flag, keyed by flag name without the leading dash,
+	// e.g. {"writerFanOut": "2", "verify": "true"}.
+	Flags map[string]string `json:"flags,omitempty"`
+}
+
+// JobStatus is the state of a Dataflow job of the pipeline.
+type JobStatus struct {
+	Name  string
+	JobId string
+	State dataflowpb.JobState
+}
+
+// Resource is a resource created for the pipeline, e.g. its change stream,
+// metadata database or the Pub/Sub subscription of a shard.
+type Resource struct {
+	Kind string
+	Name string
+}
+
+// WorkflowSummary is a pipeline found by ListWorkflows.
+type WorkflowSummary struct {
+	JobNamePrefix string
+	// Active is true if any of the jobs is still running.
+	Active bool
+	// Tags of the pipeline, as set on its most recent job.
+	Tags []string
+	Jobs []JobStatus
+}
+
+// SubscriptionBacklog is the number of changes of a shard waiting in Pub/Sub
+// to be applied by the writer jobs.
+type SubscriptionBacklog struct {
+	ShardId             string
+	UndeliveredMessages int64
+	// Reported is false if Cloud Monitoring reported no value recently, e.g.
+	// right after the subscription was created.
+	Reported bool
+}
+
+// WorkflowMetrics are the metrics of a pipeline returned by GetMetrics.
+type WorkflowMetrics struct {
+	Jobs     []JobStatus
+	Backlogs []SubscriptionBacklog
+}
+
+// jobDataField is a string field of the job data, and the launcher flag of
+// the same meaning.
+type jobDataField struct {
+	flag  string
+	value *string
+}
+
+// fields returns the string fields of the job data.
+func (j *JobData) fields() []jobDataField {
+	return []jobDataField{
+		{"projectId", &j.ProjectId},
+		{"dataflowRegion", &j.DataflowRegion},
+		{"jobNamePrefix", &j.JobNamePrefix},
+		{"changeStreamName", &j.ChangeStreamName},
+		{"instanceId", &j.InstanceId},
+		{"dbName", &j.DbName},
+		{"metadataProject", &j.MetadataProject},
+		{"metadataInstance", &j.MetadataInstance},
+		{"metadataDatabase", &j.MetadataDatabase},
+		{"metadataTableSuffix", &j.MetadataTableSuffix},
+		{"tenant", &j.Tenant},
+		{"pubSubDataTopicId", &j.PubSubDataTopicId},
+		{"sourceShardsFilePath", &j.SourceShardsFilePath},
+		{"sessionFilePath", &j.SessionFilePath},
+		{"sourceType", &j.SourceType},
+		{"sourceDbTimezoneOffset", &j.SourceDbTimezoneOffset},
+		{"writerTransformationJarPath", &j.WriterTransformationJarPath},
+		{"writerTransformationClassName", &j.WriterTransformationClassName},
+		{"shardingFunction", &j.ShardingFunction},
+		{"shardingColumn", &j.ShardingColumn},
+		{"shardingRanges", &j.ShardingRanges},
+		{"changeStreamRetention", &j.ChangeStreamRetention},
+		{"changeStreamValueCaptureType", &j.ChangeStreamValueCaptureType},
+	}
+}
+
+// args returns the launcher arguments equivalent to the job data.
+func (j JobData) args() []string {
+	joinedTags := strings.Join(j.Tags, ",")
+	named := append(j.fields(), jobDataField{"tags", &joinedTags})
+	var args []string
+	for _, f := range named {
+		if *f.value != "" {
+			args = append(args, fmt.Sprintf("-%s=%s", f.flag, *f.value))
+		}
+	}
+	var names []string
+	for name := range j.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, fmt.Sprintf("-%s=%s", name, j.Flags[name]))
+	}
+	return args
+}
+
+// parseConfig returns the pipeline configuration of the launcher arguments,
+// without validating it.
+func parseConfig(args []string) (*config, error) {
+	cfg := &config{}
+	fs := flag.NewFlagSet("reverserepl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg.setupFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("invalid job data: %v", err)
+	}
+	cfg.definitionFlags = fs
+	return cfg, nil
+}
+
+// newConfig returns the pipeline configuration of the job data, followed by
+// extraArgs, once validated. The values of the job data referencing
+// environment variables are read from them.
+func newConfig(j JobData, extraArgs ...string) (*config, error) {
+	j, refs, err := resolveJobData(j)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job data: %v", err)
+	}
+	cfg, err := parseConfig(append(j.args(), extraArgs...))
+	if err != nil {
+		return nil, err
+	}
+	cfg.secretReferences = refs
+	if err := cfg.prechecks(); err != nil {
+		return nil, fmt.Errorf("invalid job data: %v", err)
+	}
+	return cfg, nil
+}
+
+// newConfigWithoutSession is newConfig for the functions which don't launch
+// jobs, and so don't need the session file. As with cleanup, it may be left
+// out of the job data.
+func newConfigWithoutSession(j JobData) (*config, error) {
+	return newConfig(j, "-cleanup")
+}
+
+// getWorkflowContext returns ctx with the logging fields of the pipeline.
+func (cfg *config) getWorkflowContext(ctx context.Context) context.Context {
+	return logger.WithMigration(ctx, cfg.jobNamePrefix, "reverse_replication")
+}
+
+// CreateWorkflow launches the reverse replication pipeline described by j, as
+// running the launcher with the equivalent flags does.
+func CreateWorkflow(ctx context.Context, j JobData) (err error) {
+	cfg, err := newConfig(j)
+	if err != nil {
+		return err
+	}
+	ctx = cfg.getWorkflowContext(ctx)
+	ctx, span := tracing.StartSpan(ctx, "CreateWorkflow", attribute.String("jobNamePrefix", cfg.jobNamePrefix))
+	defer func() { tracing.EndSpan(span, err) }()
+	if err := cfg.uploadLocalArtifacts(ctx); err != nil {
+		return fmt.Errorf("could not upload local files: %v", err)
+	}
+	return cfg.launchPipeline(ctx)
+}
+
+// DeleteWorkflow cancels the running Dataflow jobs of the pipeline described
+// by j, then deletes the change stream, metadata and Pub/Sub resources they
+// were using. The metadata database, topic and subscriptions are kept while
+// other pipelines use them, in which case only the metadata tables and rows of
+// the pipeline are deleted.
+func DeleteWorkflow(ctx context.Context, j JobData) (err error) {
+	cfg, err := newConfigWithoutSession(j)
+	if err != nil {
+		return err
+	}
+	ctx = cfg.getWorkflowContext(ctx)
+	ctx, span := tracing.StartSpan(ctx, "DeleteWorkflow", attribute.String("jobNamePrefix", cfg.jobNamePrefix))
+	defer func() { tracing.EndSpan(span, err) }()
+	if err := cfg.checkTenantAccess(ctx); err != nil {
+		return err
+	}
+	if err := cfg.cancelPipelineJobs(ctx); err != nil {
+		return err
+	}
+	return cfg.cleanupOrphans(ctx)
+}
+
+// cancelPipelineJobs cancels the running Dataflow jobs of the pipeline and
+// waits for them to stop.
+func (cfg *config) cancelPipelineJobs(ctx context.Context) error {
+	jobNames, err := cfg.getWorkflowJobNames(ctx)
+	if err != nil {
+		return err
+	}
+	jobs, err := cfg.listPipelineJobs(ctx, jobNames)
+	if err != nil {
+		return err
+	}
+	var running []*dataflowpb.Job
+	for _, name := range jobNames {
+		for _, job := range jobs[name] {
+			if !isTerminalJobState(job.CurrentState) {
+				running = append(running, job)
+			}
+		}
+	}
+	if len(running) > 0 {
+		if err := cfg.cancelJobs(ctx, running, time.Now().Add(WORKFLOW_STOP_TIMEOUT)); err != nil {
+			return fmt.Errorf("could not cancel the dataflow jobs: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetJobStatus returns the Dataflow jobs launched for the pipeline described
+// by j: the ordering job of every database, the writer jobs, the reprocess
+// job and the jobs adopted with AdoptResources, in that order. Job names which
+// were launched several times are reported once per launch.
+func GetJobStatus(ctx context.Context, j JobData) ([]JobStatus, error) {
+	cfg, err := newConfigWithoutSession(j)
+	if err != nil {
+		return nil, err
+	}
+	ctx = cfg.getWorkflowContext(ctx)
+	if err := cfg.checkTenantAccess(ctx); err != nil {
+		return nil, err
+	}
+	return cfg.getJobStatuses(ctx)
+}
+
+// DetectSchemaDrift compares the schema of the databases replicated by the
+// pipeline described by j with the Spanner schema of its session file, and
+// returns the added or dropped tables and columns and the type changes, which
+// the writer jobs don't handle.
+func DetectSchemaDrift(ctx context.Context, j JobData) ([]SchemaDrift, error) {
+	cfg, err := newConfig(j)
+	if err != nil {
+		return nil, err
+	}
+	ctx = cfg.getWorkflowContext(ctx)
+	if err := cfg.checkTenantAccess(ctx); err != nil {
+		return nil, err
+	}
+	return cfg.getSchemaDrift(ctx, cfg.getDatabaseIds(), cfg.sessionFilePath)
+}
+
+// ApplySessionUpdate relaunches the writer jobs of the pipeline described by j
+// with the session file at newSessionFilePath, once the schema changes of the
+// replicated databases were applied to the source shards, as running the
+// launcher with -applySessionUpdate does.
+func ApplySessionUpdate(ctx context.Context, j JobData, newSessionFilePath string) error {
+	cfg, err := newConfig(j, "-applySessionUpdate", "-newSessionFilePath="+newSessionFilePath)
+	if err != nil {
+		return err
+	}
+	ctx = cfg.getWorkflowContext(ctx)
+	if err := cfg.uploadLocalArtifacts(ctx); err != nil {
+		return fmt.Errorf("could not upload local files: %v", err)
+	}
+	if err := cfg.checkTenantAccess(ctx); err != nil {
+		return err
+	}
+	return cfg.applySessionUpdate(ctx)
+}
+
+// GetResources returns the resources created for the pipeline described by j
+// which still exist: the Pub/Sub topic and subscriptions, the change stream of
+// every database and the ones adopted with AdoptResources, the metadata
+// database and the shards files uploaded for the writer jobs.
+func GetResources(ctx context.Context, j JobData) ([]Resource, error) {
+	cfg, err := newConfigWithoutSession(j)
+	if err != nil {
+		return nil, err
+	}
+	ctx = cfg.getWorkflowContext(ctx)
+	if err := cfg.checkTenantAccess(ctx); err != nil {
+		return nil, err
+	}
+	shards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shardIds, err := getLogicalShardIds(shards)
+	if err != nil {
+		return nil, err
+	}
+	found, closeClients, err := cfg.findOrphanResources(ctx, shardIds, len(partitionShards(shards, cfg.writerFanOut)))
+	if err != nil {
+		return nil, err
+	}
+	closeClients()
+	var resources []Resource
+	for _, r := range found {
+		resources = append(resources, Resource{Kind: r.kind, Name: r.name})
+	}
+	return resources, nil
+}
+
+// getJobStatuses returns the Dataflow jobs launched for the pipeline.
+func (cfg *config) getJobStatuses(ctx context.Context) ([]JobStatus, error) {
+	jobNames, err := cfg.getWorkflowJobNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := cfg.listPipelineJobs(ctx, jobNames)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []JobStatus
+	for _, name := range jobNames {
+		for _, job := range jobs[name] {
+			statuses = append(statuses, JobStatus{Name: name, JobId: job.Id, State: job.CurrentState})
+		}
+	}
+	return statuses, nil
+}
+
+// ListWorkflows returns the pipelines with Dataflow jobs in the project and
+// region, ordered by job name prefix. A pipeline is recognized by the names
+// of its ordering and writer jobs, <jobNamePrefix>-ordering[-<db>] and
+// <jobNamePrefix>-writer[-<n>]. Only the pipelines with all the given tags
+// are returned, a tag given as a key matching the tags with that key whatever
+// their value.
+func ListWorkflows(ctx context.Context, project, region string, tags ...string) ([]WorkflowSummary, error) {
+	if project == "" || region == "" {
+		return nil, fmt.Errorf("please specify a valid project and region")
+	}
+	cfg := &config{projectId: project, dataflowRegion: region}
+	jobs, err := cfg.listJobs(ctx, func(name string) bool {
+		_, ok := getWorkflowJobNamePrefix(name)
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	summaries := make(map[string]*WorkflowSummary)
+	// The most recent job of every pipeline, holding its current tags.
+	latest := make(map[string]*dataflowpb.Job)
+	var prefixes []string
+	for _, name := range names {
+		prefix, _ := getWorkflowJobNamePrefix(name)
+		summary, ok := summaries[prefix]
+		if !ok {
+			summary = &WorkflowSummary{JobNamePrefix: prefix}
+			summaries[prefix] = summary
+			prefixes = append(prefixes, prefix)
+		}
+		for _, job := range jobs[name] {
+			summary.Jobs = append(summary.Jobs, JobStatus{Name: name, JobId: job.Id, State: job.CurrentState})
+			if !isTerminalJobState(job.CurrentState) {
+				summary.Active = true
+			}
+			if latest[prefix] == nil || job.CreateTime.AsTime().After(latest[prefix].CreateTime.AsTime()) {
+				latest[prefix] = job
+			}
+		}
+	}
+	sort.Strings(prefixes)
+	c, err := getClients(ctx).NewDataflowAccessor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create dataflow client: %v", err)
+	}
+	defer c.Close()
+	var res []WorkflowSummary
+	for _, prefix := range prefixes {
+		summary := summaries[prefix]
+		if summary.Tags, err = cfg.readWorkflowTags(ctx, c, latest[prefix]); err != nil {
+			return nil, err
+		}
+		if matchesTags(summary.Tags, tags) {
+			res = append(res, *summary)
+		}
+	}
+	return res, nil
+}
+
+// getWorkflowJobNamePrefix returns the job name prefix of the pipeline a
+// Dataflow job belongs to, and false if the job is not one of a pipeline.
+func getWorkflowJobNamePrefix(jobName string) (string, bool) {
+	m := workflowJobNameRegex.FindStringSubmatch(jobName)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// PauseWorkflow drains the writer jobs of the pipeline described by j. The
+// ordering jobs keep reading the change streams, and the changes wait in the
+// Pub/Sub subscriptions of the shards until ResumeWorkflow relaunches the
+// writer jobs, for as long as the subscriptions retain messages.
+func PauseWorkflow(ctx context.Context, j JobData) error {
+	cfg, err := newConfigWithoutSession(j)
+	if err != nil {
+		return err
+	}
+	ctx = cfg.getWorkflowContext(ctx)
+	if err := cfg.checkTenantAccess(ctx); err != nil {
+		return err
+	}
+	running, err := cfg.getRunningWriterJobs(ctx)
+	if err != nil {
+		return err
+	}
+	if len(running) == 0 {
+		return fmt.Errorf("no writer job of the pipeline is running")
+	}
+	if err := cfg.drainJobs(ctx, running, time.Now().Add(WORKFLOW_STOP_TIMEOUT)); err != nil {
+		return fmt.Errorf("could not drain the writer jobs: %v", err)
+	}
+	return nil
+}
+
+// ResumeWorkflow relaunches the writer jobs of the pipeline described by j,
+// paused by PauseWorkflow. The writer jobs apply the changes which waited in
+// Pub/Sub first.
+func ResumeWorkflow(ctx context.Context, j JobData) error {
+	cfg, err := newConfig(j)
+	if err != nil {
+		return err
+	}
+	ctx = cfg.getWorkflowContext(ctx)
+	if err := cfg.checkTenantAccess(ctx); err != nil {
+		return err
+	}
+	running, err := cfg.getRunningWriterJobs(ctx)
+	if err != nil {
+		return err
+	}
+	if len(running) > 0 {
+		return fmt.Errorf("writer job %s is already running", running[0].Name)
+	}
+	if err := cfg.uploadLocalArtifacts(ctx); err != nil {
+		return fmt.Errorf("could not upload local files: %v", err)
+	}
+	shards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return fmt.Errorf("could not read source shards: %v", err)
+	}
+	c, err := getClients(ctx).NewDataflowAccessor(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create dataflow client: %v", err)
+	}
+	defer c.Close()
+	_, err = cfg.launchWriterJobs(ctx, c, partitionShards(shards, cfg.writerFanOut))
+	return err
+}
+
+// getRunningWriterJobs returns the writer jobs of the pipeline which are
+// still running.
+func (cfg *config) getRunningWriterJobs(ctx context.Context) ([]*dataflowpb.Job, error) {
+	shards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobNames := cfg.getWriterJobNames(len(partitionShards(shards, cfg.writerFanOut)))
+	jobs, err := cfg.listPipelineJobs(ctx, jobNames)
+	if err != nil {
+		return nil, err
+	}
+	var running []*dataflowpb.Job
+	for _, name := range jobNames {
+		for _, job := range jobs[name] {
+			if !isTerminalJobState(job.CurrentState) {
+				running = append(running, job)
+			}
+		}
+	}
+	return running, nil
+}
+
+// GetMetrics returns the state of the Dataflow jobs of the pipeline described
+// by j, and the number of changes of every shard waiting in Pub/Sub to be
+// applied, as last reported by Cloud Monitoring.
+func GetMetrics(ctx context.Context, j JobData) (*WorkflowMetrics, error) {
+	cfg, err := newConfigWithoutSession(j)
+	if err != nil {
+		return nil, err
+	}
+	ctx = cfg.getWorkflowContext(ctx)
+	if err := cfg.checkTenantAccess(ctx); err != nil {
+		return nil, err
+	}
+	statuses, err := cfg.getJobStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shardIds, err := getLogicalShardIds(shards)
+	if err != nil {
+		return nil, err
+	}
+	client, err := getClients(ctx).NewMetricReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create monitoring client: %v", err)
+	}
+	defer client.Close()
+	metrics := &WorkflowMetrics{Jobs: statuses}
+	for _, id := range shardIds {
+		backlog, reported, err := cfg.getSubscriptionBacklog(ctx, client, id)
+		if err != nil {
+			return nil, err
+		}
+		metrics.Backlogs = append(metrics.Backlogs, SubscriptionBacklog{ShardId: id, UndeliveredMessages: backlog, Reported: reported})
+	}
+	return metrics, nil
+}
+
+// getWorkflowJobNames returns the names of the Dataflow jobs of the pipeline.
+func (cfg *config) getWorkflowJobNames(ctx context.Context) ([]string, error) {
+	shards, err := cfg.readSourceShards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobNames := append(cfg.getOrderingJobNames(cfg.getDatabaseIds()), cfg.getWriterJobNames(len(partitionShards(shards, cfg.writerFanOut)))...)
+	adopted, err := cfg.getAdoptedResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobNames = append(jobNames, cfg.getReprocessJobName())
+	return append(jobNames, getAdoptedJobNames(adopted)...), nil
+}
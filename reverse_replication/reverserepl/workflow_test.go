@@ -0,0 +1,227 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverserepl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/dataflowjobs"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestJobData() JobData {
+	return JobData{
+		ProjectId:            "my-project",
+		DataflowRegion:       "us-central1",
+		JobNamePrefix:        "Orders",
+		InstanceId:           "my-instance",
+		DbName:               "orders",
+		SourceShardsFilePath: "gs://my-bucket/shards.json",
+		SessionFilePath:      "gs://my-bucket/session.json",
+	}
+}
+
+// withFakeClients returns ctx whose steps reach Dataflow and Cloud Storage
+// through dataflow and gcs.
+func withFakeClients(ctx context.Context, dataflow dataflowjobs.DataflowAccessor, gcs *testutil.FakeStorageClient) context.Context {
+	p := DefaultClientProvider()
+	p.NewDataflowAccessor = func(ctx context.Context) (dataflowjobs.DataflowAccessor, error) { return dataflow, nil }
+	p.NewStorageAccessor = func(ctx context.Context) (StorageAccessor, error) { return gcs, nil }
+	return WithClientProvider(ctx, p)
+}
+
+func TestJobDataArgs(t *testing.T) {
+	j := getTestJobData()
+	j.Tags = []string{"env=prod", "team"}
+	j.Flags = map[string]string{"writerFanOut": "2", "dryRun": "true"}
+	assert.Equal(t, []string{
+		"-projectId=my-project",
+		"-dataflowRegion=us-central1",
+		"-jobNamePrefix=Orders",
+		"-instanceId=my-instance",
+		"-dbName=orders",
+		"-sourceShardsFilePath=gs://my-bucket/shards.json",
+		"-sessionFilePath=gs://my-bucket/session.json",
+		"-tags=env=prod,team",
+		"-dryRun=true",
+		"-writerFanOut=2",
+	}, j.args())
+}
+
+func TestNewConfig(t *testing.T) {
+	cfg, err := newConfig(getTestJobData())
+	assert.Nil(t, err)
+	// Job names are lower cased, and the metadata database defaults to the
+	// Spanner instance.
+	assert.Equal(t, "orders", cfg.jobNamePrefix)
+	assert.Equal(t, "my-project", cfg.metadataProject)
+	assert.Equal(t, "my-instance", cfg.metadataInstance)
+	assert.Equal(t, "change-stream-metadata", cfg.metadataDatabase)
+	assert.Equal(t, "projects/my-project/instances/my-instance/databases/change-stream-metadata", cfg.getMetadataDbUri())
+
+	testCases := []struct {
+		name   string
+		modify func(j *JobData)
+		errMsg string
+	}{
+		{"no project", func(j *JobData) { j.ProjectId = "" }, "please specify a valid projectId"},
+		{"no session file", func(j *JobData) { j.SessionFilePath = "" }, "please specify a valid sessionFilePath"},
+		{"topic with prefix", func(j *JobData) { j.PubSubDataTopicId = "projects/p/topics/t" }, "'/' is not a valid character"},
+		{"unknown flag", func(j *JobData) { j.Flags = map[string]string{"notAFlag": "1"} }, "flag provided but not defined: -notAFlag"},
+		{"invalid flag value", func(j *JobData) { j.Flags = map[string]string{"writerFanOut": "two"} }, "invalid value \"two\" for flag -writerFanOut"},
+	}
+	for _, tc := range testCases {
+		j := getTestJobData()
+		tc.modify(&j)
+		_, err := newConfig(j)
+		if assert.NotNil(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.errMsg, tc.name)
+		}
+	}
+
+	// The functions which don't launch jobs don't need the session file.
+	j := getTestJobData()
+	j.SessionFilePath = ""
+	cfg, err = newConfigWithoutSession(j)
+	assert.Nil(t, err)
+	assert.True(t, cfg.cleanup)
+}
+
+func TestNewConfigIsPerCall(t *testing.T) {
+	// Pipelines configured at the same time don't see each other's flags.
+	var wg sync.WaitGroup
+	cfgs := make([]*config, 8)
+	errs := make([]error, 8)
+	for i := range cfgs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			j := getTestJobData()
+			j.JobNamePrefix = fmt.Sprintf("pipeline-%d", i)
+			j.Flags = map[string]string{"writerFanOut": fmt.Sprint(i + 1)}
+			cfgs[i], errs[i] = newConfig(j)
+		}(i)
+	}
+	wg.Wait()
+	for i, cfg := range cfgs {
+		if assert.Nil(t, errs[i]) {
+			assert.Equal(t, fmt.Sprintf("pipeline-%d", i), cfg.jobNamePrefix)
+			assert.Equal(t, i+1, cfg.writerFanOut)
+			assert.Equal(t, []string{fmt.Sprintf("pipeline-%d-writer", i)}, cfg.getWriterJobNames(1))
+		}
+	}
+}
+
+func TestGetPipelineJobStates(t *testing.T) {
+	dataflow := testutil.NewFakeDataflowAccessor()
+	ctx := withFakeClients(context.Background(), dataflow, testutil.NewFakeStorageClient())
+	launch := func(name string) string {
+		resp, err := dataflow.LaunchFlexTemplate(ctx, &dataflowpb.LaunchFlexTemplateRequest{
+			ProjectId:       "my-project",
+			Location:        "us-central1",
+			LaunchParameter: &dataflowpb.LaunchFlexTemplateParameter{JobName: name},
+		})
+		assert.Nil(t, err)
+		return resp.Job.Id
+	}
+	cancelled := launch("orders-ordering")
+	dataflow.SetJobState(cancelled, dataflowpb.JobState_JOB_STATE_CANCELLED)
+	launch("orders-ordering")
+	launch("orders-writer")
+	// Jobs of another pipeline are left out.
+	launch("payments-writer")
+
+	cfg, err := newConfig(getTestJobData())
+	assert.Nil(t, err)
+	states, err := cfg.getPipelineJobStates(ctx, []string{"orders-ordering", "orders-writer", "orders-reprocess"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]dataflowpb.JobState{
+		"orders-ordering": {dataflowpb.JobState_JOB_STATE_CANCELLED, dataflowpb.JobState_JOB_STATE_RUNNING},
+		"orders-writer":   {dataflowpb.JobState_JOB_STATE_RUNNING},
+	}, states)
+}
+
+func TestPauseAndResumeWriterJobs(t *testing.T) {
+	// Every reading of the clock moves it by a minute, so that the jobs are
+	// running by the time they are listed and drained by the first poll.
+	clock := testutil.NewSimulatedClock(time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), time.Minute)
+	dataflow := testutil.NewSimulatedDataflowAccessor(clock)
+	dataflow.QueueDuration = time.Minute
+	dataflow.StopDuration = time.Minute
+	gcs := testutil.NewFakeStorageClient()
+	ctx := withFakeClients(context.Background(), dataflow, gcs)
+	gcs.PutObject("my-bucket", "templates/writer.json", []byte(`{"image": "gcr.io/my-project/writer"}`), time.Now())
+	gcs.PutObject("my-bucket", "shards.json", []byte(`[{"logicalShardId": "shard1"}]`), time.Now())
+
+	j := getTestJobData()
+	j.Flags = map[string]string{"writerTemplate": "gs://my-bucket/templates/writer.json"}
+	cfg, err := newConfig(j)
+	assert.Nil(t, err)
+	shards, err := cfg.readSourceShards(ctx)
+	assert.Nil(t, err)
+	_, err = cfg.launchWriterJobs(ctx, dataflow, partitionShards(shards, cfg.writerFanOut))
+	assert.Nil(t, err)
+	running, err := cfg.getRunningWriterJobs(ctx)
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(running)) {
+		assert.Equal(t, dataflowpb.JobState_JOB_STATE_RUNNING, running[0].CurrentState)
+	}
+
+	// Pausing drains the writer job, after which it can be launched again.
+	assert.Nil(t, cfg.drainJobs(ctx, running, time.Now().Add(time.Hour)))
+	running, err = cfg.getRunningWriterJobs(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, running)
+	_, err = cfg.launchWriterJobs(ctx, dataflow, partitionShards(shards, cfg.writerFanOut))
+	assert.Nil(t, err)
+	states, err := cfg.getPipelineJobStates(ctx, []string{"orders-writer"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]dataflowpb.JobState{
+		"orders-writer": {dataflowpb.JobState_JOB_STATE_DRAINED, dataflowpb.JobState_JOB_STATE_RUNNING},
+	}, states)
+}
+
+func TestGetWorkflowJobNamePrefix(t *testing.T) {
+	tests := []struct {
+		jobName string
+		prefix  string
+		ok      bool
+	}{
+		{"orders-ordering", "orders", true},
+		{"orders-ordering-payments", "orders", true},
+		{"orders-ordering-my-db", "orders", true},
+		{"orders-writer", "orders", true},
+		{"orders-writer-3", "orders", true},
+		{"orders-writer-reprocess", "orders", true},
+		// The prefix may contain the name of a job kind.
+		{"app-writer-prod-writer", "app-writer-prod", true},
+		{"app-writer-prod-ordering", "app-writer-prod", true},
+		{"app-ordering-1-writer-0", "app-ordering-1", true},
+		{"app-writer-prod", "", false},
+		{"orders-reader", "", false},
+		{"-writer", "", false},
+	}
+	for _, tc := range tests {
+		prefix, ok := getWorkflowJobNamePrefix(tc.jobName)
+		assert.Equal(t, tc.ok, ok, tc.jobName)
+		assert.Equal(t, tc.prefix, prefix, tc.jobName)
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testharness runs the reverse replication workflows end to end
+// without reaching Google Cloud. Spanner is the emulator pointed at by
+// SPANNER_EMULATOR_HOST, Pub/Sub is pstest, and Cloud Storage and Dataflow are
+// the in-memory fakes of testutil, so that tests can call CreateWorkflow and
+// DeleteWorkflow and check the databases, topics and jobs they leave behind.
+package testharness
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/dataflowjobs"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/testutil"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/reverse_replication/reverserepl"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	databasepb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// Bucket of the fake Cloud Storage holding the files of the pipelines.
+const BUCKET = "harness-bucket"
+
+// Harness holds the fakes the workflows run against. The Spanner databases
+// are created in the emulator instance InstanceId of ProjectId, which must
+// exist.
+type Harness struct {
+	ProjectId  string
+	InstanceId string
+	Storage    *testutil.FakeStorageClient
+	Dataflow   *testutil.FakeDataflowAccessor
+	Pubsub     *pstest.Server
+
+	clients *reverserepl.ClientProvider
+}
+
+// New returns a harness with empty fakes, or an error if SPANNER_EMULATOR_HOST
+// is not set, so that the workflows never reach a real Spanner instance.
+func New(projectId, instanceId string) (*Harness, error) {
+	if os.Getenv("SPANNER_EMULATOR_HOST") == "" {
+		return nil, fmt.Errorf("SPANNER_EMULATOR_HOST is not set, the harness only runs against the Spanner emulator")
+	}
+	h := &Harness{
+		ProjectId:  projectId,
+		InstanceId: instanceId,
+		Storage:    testutil.NewFakeStorageClient(),
+		Dataflow:   testutil.NewFakeDataflowAccessor(),
+		Pubsub:     pstest.NewServer(),
+	}
+	// The Spanner clients of the default provider honour
+	// SPANNER_EMULATOR_HOST.
+	h.clients = reverserepl.DefaultClientProvider()
+	h.clients.NewDataflowAccessor = func(ctx context.Context) (dataflowjobs.DataflowAccessor, error) {
+		return h.Dataflow, nil
+	}
+	h.clients.NewStorageAccessor = func(ctx context.Context) (reverserepl.StorageAccessor, error) {
+		return h.Storage, nil
+	}
+	h.clients.NewMetricReader = func(ctx context.Context) (reverserepl.MetricReader, error) {
+		return noMetrics{}, nil
+	}
+	// Every client dials pstest, as closing a client closes its connection.
+	h.clients.NewPubsubClient = func(ctx context.Context, projectId string) (*pubsub.Client, error) {
+		return pubsub.NewClient(ctx, projectId,
+			option.WithEndpoint(h.Pubsub.Addr),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	}
+	return h, nil
+}
+
+// Context returns a copy of ctx whose workflows run against the harness.
+func (h *Harness) Context(ctx context.Context) context.Context {
+	return reverserepl.WithClientProvider(ctx, h.clients)
+}
+
+// Close stops pstest. The databases created in the emulator are kept.
+func (h *Harness) Close() error {
+	return h.Pubsub.Close()
+}
+
+// getDbUri returns the uri of the emulator database dbName.
+func (h *Harness) getDbUri(dbName string) string {
+	return fmt.Sprintf("projects/%s/instances/%s/databases/%s", h.ProjectId, h.InstanceId, dbName)
+}
+
+// CreateDatabase creates the GoogleSQL database dbName with the tables of ddl.
+func (h *Harness) CreateDatabase(ctx context.Context, dbName string, ddl []string) error {
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer adminClient.Close()
+	op, err := adminClient.CreateDatabase(ctx, &databasepb.CreateDatabaseRequest{
+		Parent:          fmt.Sprintf("projects/%s/instances/%s", h.ProjectId, h.InstanceId),
+		CreateStatement: fmt.Sprintf("CREATE DATABASE `%s`", dbName),
+		ExtraStatements: ddl,
+	})
+	if err != nil {
+		return fmt.Errorf("could not create database %s: %v", dbName, err)
+	}
+	if _, err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("could not create database %s: %v", dbName, err)
+	}
+	return nil
+}
+
+// DropDatabase drops the database dbName, if it exists.
+func (h *Harness) DropDatabase(ctx context.Context, dbName string) error {
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer adminClient.Close()
+	if err := adminClient.DropDatabase(ctx, &databasepb.DropDatabaseRequest{Database: h.getDbUri(dbName)}); err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("could not drop database %s: %v", dbName, err)
+	}
+	return nil
+}
+
+// DatabaseExists returns true if the database dbName exists.
+func (h *Harness) DatabaseExists(ctx context.Context, dbName string) (bool, error) {
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer adminClient.Close()
+	_, err = adminClient.GetDatabase(ctx, &databasepb.GetDatabaseRequest{Name: h.getDbUri(dbName)})
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Query returns the rows of the query sql in the database dbName.
+func (h *Harness) Query(ctx context.Context, dbName, sql string) ([]*spanner.Row, error) {
+	client, err := spanner.NewClient(ctx, h.getDbUri(dbName))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	iter := client.Single().Query(ctx, spanner.Statement{SQL: sql})
+	defer iter.Stop()
+	var rows []*spanner.Row
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not query %s: %v", dbName, err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// TopicExists returns true if pstest has the Pub/Sub topic topicId.
+func (h *Harness) TopicExists(ctx context.Context, topicId string) (bool, error) {
+	client, err := h.clients.NewPubsubClient(ctx, h.ProjectId)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+	return client.Topic(topicId).Exists(ctx)
+}
+
+// JobData returns the job data of a pipeline replicating the database dbName
+// to the given shards. Its session, source shards and template files are
+// written to the fake Cloud Storage, under a directory named after
+// jobNamePrefix. The shards are never connected to.
+func (h *Harness) JobData(jobNamePrefix, dbName string, shardIds ...string) (reverserepl.JobData, error) {
+	dir := fmt.Sprintf("gs://%s/%s", BUCKET, jobNamePrefix)
+	var shards []map[string]string
+	for i, id := range shardIds {
+		shards = append(shards, map[string]string{
+			"logicalShardId": id,
+			"host":           fmt.Sprintf("10.0.0.%d", i+1),
+			"user":           "root",
+			"password":       "password",
+			"port":           "3306",
+			"dbName":         dbName,
+		})
+	}
+	files := map[string]interface{}{
+		"session.json":  map[string]string{"SpDialect": constants.DIALECT_GOOGLESQL},
+		"shards.json":   shards,
+		"ordering.json": map[string]string{"image": "gcr.io/harness/ordering"},
+		"writer.json":   map[string]string{"image": "gcr.io/harness/writer"},
+	}
+	for name, v := range files {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return reverserepl.JobData{}, err
+		}
+		h.Storage.PutObject(BUCKET, jobNamePrefix+"/"+name, b, time.Now())
+	}
+	return reverserepl.JobData{
+		ProjectId:            h.ProjectId,
+		DataflowRegion:       "us-central1",
+		JobNamePrefix:        jobNamePrefix,
+		InstanceId:           h.InstanceId,
+		DbName:               dbName,
+		MetadataDatabase:     jobNamePrefix + "-metadata",
+		Tenant:               "harness",
+		SourceShardsFilePath: dir + "/shards.json",
+		SessionFilePath:      dir + "/session.json",
+		Flags: map[string]string{
+			"orderingTemplate": dir + "/ordering.json",
+			"writerTemplate":   dir + "/writer.json",
+		},
+	}, nil
+}
+
+// noMetrics is a Cloud Monitoring without any time series, so that the
+// capacity checks of the workflows are skipped.
+type noMetrics struct{}
+
+func (noMetrics) ListTimeSeries(ctx context.Context, req *monitoringpb.ListTimeSeriesRequest) ([]*monitoringpb.TimeSeries, error) {
+	return nil, nil
+}
+
+func (noMetrics) Close() error {
+	return nil
+}
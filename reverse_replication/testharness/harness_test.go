@@ -0,0 +1,129 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testharness_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"cloud.google.com/go/dataflow/apiv1beta3/dataflowpb"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/reverse_replication/reverserepl"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/reverse_replication/testharness"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEmulator_CreateAndDeleteWorkflow launches a pipeline against the
+// Spanner emulator and deletes it, checking the metadata database in between.
+func TestEmulator_CreateAndDeleteWorkflow(t *testing.T) {
+	projectID := os.Getenv("SPANNER_MIGRATION_TOOL_TESTS_GCLOUD_PROJECT_ID")
+	instanceID := os.Getenv("SPANNER_MIGRATION_TOOL_TESTS_GCLOUD_INSTANCE_ID")
+	if testing.Short() || os.Getenv("SPANNER_EMULATOR_HOST") == "" || projectID == "" || instanceID == "" {
+		t.Skip("Skipping test which only runs against the emulator.")
+	}
+	h, err := testharness.New(projectID, instanceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	ctx := h.Context(context.Background())
+
+	dbName := "harness-orders"
+	j, err := h.JobData("harness", dbName, "shard1", "shard2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.CreateDatabase(ctx, dbName, []string{
+		"CREATE TABLE orders (id INT64 NOT NULL, amount FLOAT64) PRIMARY KEY (id)",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, db := range []string{dbName, j.MetadataDatabase, reverserepl.CREATION_LOCKS_DATABASE} {
+			if err := h.DropDatabase(context.Background(), db); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	if err := reverserepl.CreateWorkflow(ctx, j); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := h.Query(ctx, j.MetadataDatabase, "SELECT MetadataTableSuffix, JobNamePrefix, DatabaseId FROM ReverseReplicationMetadataSuffixes")
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(rows)) {
+		var suffix, jobNamePrefix, databaseId string
+		assert.Nil(t, rows[0].Columns(&suffix, &jobNamePrefix, &databaseId))
+		assert.Equal(t, []string{"", "harness", dbName}, []string{suffix, jobNamePrefix, databaseId})
+	}
+	rows, err = h.Query(ctx, j.MetadataDatabase, "SELECT JobNamePrefix, Tenant FROM ReverseReplicationJobDefinitions")
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(rows)) {
+		var jobNamePrefix, tenant string
+		assert.Nil(t, rows[0].Columns(&jobNamePrefix, &tenant))
+		assert.Equal(t, []string{"harness", "harness"}, []string{jobNamePrefix, tenant})
+	}
+	rows, err = h.Query(ctx, j.MetadataDatabase, "SELECT Status FROM ReverseReplicationCreations WHERE JobNamePrefix = 'harness'")
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(rows)) {
+		var creationStatus string
+		assert.Nil(t, rows[0].Columns(&creationStatus))
+		assert.Equal(t, reverserepl.CREATION_STATUS_CREATED, creationStatus)
+	}
+	rows, err = h.Query(ctx, dbName, "SELECT CHANGE_STREAM_NAME FROM information_schema.change_streams")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rows))
+	// Both were created by the launcher, so that DeleteWorkflow drops them.
+	rows, err = h.Query(ctx, j.MetadataDatabase, "SELECT Kind FROM ReverseReplicationCreatedResources ORDER BY Kind")
+	assert.Nil(t, err)
+	var kinds []string
+	for _, row := range rows {
+		var kind string
+		assert.Nil(t, row.Columns(&kind))
+		kinds = append(kinds, kind)
+	}
+	assert.Equal(t, []string{reverserepl.CREATED_CHANGE_STREAM, reverserepl.CREATED_METADATA_DATABASE}, kinds)
+	exists, err := h.TopicExists(ctx, "reverse-replication")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	jobs, err := reverserepl.GetJobStatus(ctx, j)
+	assert.Nil(t, err)
+	var names []string
+	for _, job := range jobs {
+		names = append(names, job.Name)
+		assert.Equal(t, dataflowpb.JobState_JOB_STATE_RUNNING, job.State)
+	}
+	assert.Equal(t, []string{"harness-ordering", "harness-writer"}, names)
+
+	if err := reverserepl.DeleteWorkflow(ctx, j); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err = reverserepl.GetJobStatus(ctx, j)
+	assert.Nil(t, err)
+	for _, job := range jobs {
+		assert.Equal(t, dataflowpb.JobState_JOB_STATE_CANCELLED, job.State)
+	}
+	// No other pipeline uses the metadata database, so it is dropped along
+	// with the change stream and the Pub/Sub topic.
+	exists, err = h.DatabaseExists(ctx, j.MetadataDatabase)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	rows, err = h.Query(ctx, dbName, "SELECT CHANGE_STREAM_NAME FROM information_schema.change_streams")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(rows))
+	exists, err = h.TopicExists(ctx, "reverse-replication")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}
@@ -52,6 +52,11 @@ type Column struct {
 	NotNull bool
 	Ignored Ignored
 	Id      string
+	// GeneratedExpr is the expression of a computed column, already
+	// translated to Spanner syntax. It is empty for regular columns and for
+	// computed columns whose expression can't be translated (see
+	// Ignored.Computed).
+	GeneratedExpr string
 }
 
 // ForeignKey represents a foreign key.
@@ -117,6 +122,7 @@ type Ignored struct {
 	Exclusion     bool
 	ForeignKey    bool
 	AutoIncrement bool
+	Computed      bool
 }
 
 // Print converts ty to a string suitable for printing.
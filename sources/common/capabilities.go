@@ -0,0 +1,88 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Feature is a source database feature which is not supported by every
+// source adapter.
+type Feature string
+
+const (
+	SchemaFromDatabase Feature = "schema conversion from a live database"
+	SchemaFromDump     Feature = "schema conversion from a dump file"
+	SchemaFromSnapshot Feature = "schema conversion from an information_schema snapshot"
+	ChangeDataCapture  Feature = "change data capture (streaming migration)"
+	SnapshotExport     Feature = "snapshot export of existing data"
+	Sharding           Feature = "sharded migrations"
+	ForeignKeys        Feature = "foreign key metadata"
+	CheckConstraints   Feature = "check constraints"
+)
+
+// Capabilities is declared by each source adapter and lists the features it
+// supports. It is consulted before a migration starts so that an unsupported
+// combination of source and options is reported up front instead of failing
+// part way through the migration.
+type Capabilities struct {
+	SchemaFromDatabase bool
+	SchemaFromDump     bool
+	SchemaFromSnapshot bool
+	ChangeDataCapture  bool
+	SnapshotExport     bool
+	Sharding           bool
+	ForeignKeys        bool
+	CheckConstraints   bool
+}
+
+// Supports returns true if the source adapter supports feature f.
+func (c Capabilities) Supports(f Feature) bool {
+	switch f {
+	case SchemaFromDatabase:
+		return c.SchemaFromDatabase
+	case SchemaFromDump:
+		return c.SchemaFromDump
+	case SchemaFromSnapshot:
+		return c.SchemaFromSnapshot
+	case ChangeDataCapture:
+		return c.ChangeDataCapture
+	case SnapshotExport:
+		return c.SnapshotExport
+	case Sharding:
+		return c.Sharding
+	case ForeignKeys:
+		return c.ForeignKeys
+	case CheckConstraints:
+		return c.CheckConstraints
+	}
+	return false
+}
+
+// Require returns an error listing every feature in features which is not
+// supported for the given source.
+func (c Capabilities) Require(source string, features ...Feature) error {
+	var unsupported []string
+	for _, f := range features {
+		if !c.Supports(f) {
+			unsupported = append(unsupported, string(f))
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("source %s does not support %s", source, strings.Join(unsupported, ", "))
+	}
+	return nil
+}
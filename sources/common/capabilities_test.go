@@ -0,0 +1,43 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilitiesRequire(t *testing.T) {
+	c := Capabilities{SchemaFromDatabase: true, SnapshotExport: true, ForeignKeys: true}
+	tests := []struct {
+		name        string
+		features    []Feature
+		expectError string
+	}{
+		{name: "no features", features: nil},
+		{name: "all supported", features: []Feature{SchemaFromDatabase, SnapshotExport, ForeignKeys}},
+		{name: "one unsupported", features: []Feature{SchemaFromDatabase, ChangeDataCapture}, expectError: "source sqlserver does not support change data capture (streaming migration)"},
+		{name: "several unsupported", features: []Feature{Sharding, CheckConstraints}, expectError: "source sqlserver does not support sharded migrations, check constraints"},
+	}
+	for _, tc := range tests {
+		err := c.Require("sqlserver", tc.features...)
+		if tc.expectError == "" {
+			assert.Nil(t, err, tc.name)
+		} else {
+			assert.EqualError(t, err, tc.expectError, tc.name)
+		}
+	}
+}
@@ -75,6 +75,7 @@ func ProcessSchema(conv *internal.Conv, infoSchema InfoSchema, numWorkers int, a
 		return fmt.Errorf("failed to load all the source tables, source table count: %v, processed tables:%v. Please retry connecting to the source database to load tables.", tableCount, len(conv.SpSchema))
 	}
 	conv.AddPrimaryKeys()
+	conv.AddTimezoneOffsetColumns()
 	if attributes.IsSharded {
 		conv.AddShardIdColumn()
 	}
@@ -0,0 +1,235 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/schema"
+)
+
+// describeSrcTables returns a canonical description of every table of the
+// source schema, keyed by table name. The descriptions only depend on the
+// names and definitions of columns, keys and indexes, not on the ids
+// generated for them, so that two reads of the same source schema compare
+// equal.
+func describeSrcTables(srcSchema map[string]schema.Table) map[string]string {
+	colName := func(t schema.Table, colId string) string {
+		return t.ColDefs[colId].Name
+	}
+	keys := func(t schema.Table, ks []schema.Key) string {
+		var parts []string
+		for _, k := range ks {
+			order := "ASC"
+			if k.Desc {
+				order = "DESC"
+			}
+			parts = append(parts, colName(t, k.ColId)+" "+order)
+		}
+		return strings.Join(parts, ", ")
+	}
+	descs := make(map[string]string)
+	for _, t := range srcSchema {
+		var parts []string
+		for _, colId := range t.ColIds {
+			c := t.ColDefs[colId]
+			parts = append(parts, fmt.Sprintf("column %s %s%v%v notnull=%t generated=%q", c.Name, c.Type.Name, c.Type.Mods, c.Type.ArrayBounds, c.NotNull, c.GeneratedExpr))
+		}
+		parts = append(parts, fmt.Sprintf("primary key (%s)", keys(t, t.PrimaryKeys)))
+		var others []string
+		for _, fk := range t.ForeignKeys {
+			refTable := srcSchema[fk.ReferTableId]
+			var cols, refCols []string
+			for _, colId := range fk.ColIds {
+				cols = append(cols, colName(t, colId))
+			}
+			for _, colId := range fk.ReferColumnIds {
+				refCols = append(refCols, colName(refTable, colId))
+			}
+			others = append(others, fmt.Sprintf("foreign key %s (%s) references %s (%s) on delete %s on update %s", fk.Name, strings.Join(cols, ", "), refTable.Name, strings.Join(refCols, ", "), fk.OnDelete, fk.OnUpdate))
+		}
+		for _, idx := range t.Indexes {
+			var stored []string
+			for _, colId := range idx.StoredColumnIds {
+				stored = append(stored, colName(t, colId))
+			}
+			others = append(others, fmt.Sprintf("index %s unique=%t (%s) storing (%s)", idx.Name, idx.Unique, keys(t, idx.Keys), strings.Join(stored, ", ")))
+		}
+		sort.Strings(others)
+		descs[t.Name] = strings.Join(append(parts, others...), "\n")
+	}
+	return descs
+}
+
+// HashSrcSchema returns a hash of the source schema which changes whenever a
+// table, column, key or index of the source schema changes.
+func HashSrcSchema(srcSchema map[string]schema.Table) string {
+	descs := describeSrcTables(srcSchema)
+	var names []string
+	for name := range descs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "table %s\n%s\n", name, descs[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// diffSrcSchemas lists the tables which were added, dropped or changed in the
+// current source schema compared to the baseline.
+func diffSrcSchemas(baseline, current map[string]string) []string {
+	var diffs []string
+	for name, desc := range current {
+		old, ok := baseline[name]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("table %s was added", name))
+		} else if old != desc {
+			diffs = append(diffs, fmt.Sprintf("table %s was changed", name))
+		}
+	}
+	for name := range baseline {
+		if _, ok := current[name]; !ok {
+			diffs = append(diffs, fmt.Sprintf("table %s was dropped", name))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// SchemaChangeMonitor re-reads the source schema while data is migrated and
+// reports when it no longer matches the schema the migration was started
+// with, since rows read with a changed source schema are converted using the
+// old schema mapping. Optionally the migration is paused for as long as the
+// source schema differs.
+type SchemaChangeMonitor struct {
+	infoSchema   InfoSchema
+	baseline     map[string]string
+	baselineHash string
+	interval     time.Duration
+	pause        bool
+	stop         chan struct{}
+	done         chan struct{}
+	mu           sync.Mutex
+	cond         *sync.Cond
+	paused       bool
+	changes      []string
+}
+
+// NewSchemaChangeMonitor returns a monitor comparing the schema read through
+// infoSchema with the source schema in conv every interval. If pause is true,
+// WaitIfPaused blocks while the schemas differ.
+func NewSchemaChangeMonitor(conv *internal.Conv, infoSchema InfoSchema, interval time.Duration, pause bool) *SchemaChangeMonitor {
+	m := &SchemaChangeMonitor{
+		infoSchema:   infoSchema,
+		baseline:     describeSrcTables(conv.SrcSchema),
+		baselineHash: HashSrcSchema(conv.SrcSchema),
+		interval:     interval,
+		pause:        pause,
+	}
+	m.cond = sync.NewCond(&m.mu)
+	return m
+}
+
+// Check reads the source schema once and returns the differences to the
+// baseline schema, which are empty if the schema is unchanged.
+func (m *SchemaChangeMonitor) Check() ([]string, error) {
+	conv := internal.MakeConv()
+	if _, err := GenerateSrcSchema(conv, m.infoSchema, 1); err != nil {
+		return nil, fmt.Errorf("couldn't read source schema: %v", err)
+	}
+	var diffs []string
+	if HashSrcSchema(conv.SrcSchema) != m.baselineHash {
+		diffs = diffSrcSchemas(m.baseline, describeSrcTables(conv.SrcSchema))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(diffs) > 0 {
+		if m.changes == nil {
+			fmt.Printf("\nWARNING: the source schema changed during the migration: %s. Rows read from here on are converted using the old schema.\n", strings.Join(diffs, ", "))
+		}
+		m.changes = diffs
+		if m.pause && !m.paused {
+			fmt.Printf("Pausing the migration until the source schema matches the schema it was started with again.\n")
+			m.paused = true
+		}
+	} else if m.paused {
+		fmt.Printf("The source schema matches the schema the migration was started with again, resuming the migration.\n")
+		m.paused = false
+		m.cond.Broadcast()
+	}
+	return diffs, nil
+}
+
+// Start checks the source schema every interval until Stop is called.
+func (m *SchemaChangeMonitor) Start() {
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				if _, err := m.Check(); err != nil {
+					fmt.Printf("WARNING: couldn't check the source schema for changes: %v\n", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic checks and unblocks any paused writer.
+func (m *SchemaChangeMonitor) Stop() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.mu.Lock()
+	m.paused = false
+	m.cond.Broadcast()
+	m.mu.Unlock()
+}
+
+// WaitIfPaused blocks while the migration is paused because of a source
+// schema change.
+func (m *SchemaChangeMonitor) WaitIfPaused() {
+	m.mu.Lock()
+	for m.paused {
+		m.cond.Wait()
+	}
+	m.mu.Unlock()
+}
+
+// Changes returns the differences found by the last check which detected a
+// source schema change, or nil if no change was detected.
+func (m *SchemaChangeMonitor) Changes() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.changes
+}
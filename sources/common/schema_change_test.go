@@ -0,0 +1,133 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/schema"
+)
+
+// fakeInfoSchema serves a schema of single column tables, whose column types
+// can be changed by the test.
+type fakeInfoSchema struct {
+	InfoSchema
+	mu      sync.Mutex
+	columns map[string]string
+}
+
+func (f *fakeInfoSchema) setColumns(columns map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.columns = columns
+}
+
+func (f *fakeInfoSchema) GetTableName(schema string, tableName string) string {
+	return tableName
+}
+
+func (f *fakeInfoSchema) GetTables() ([]SchemaAndName, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var tables []SchemaAndName
+	for name := range f.columns {
+		tables = append(tables, SchemaAndName{Schema: "db", Name: name})
+	}
+	return tables, nil
+}
+
+func (f *fakeInfoSchema) GetConstraints(conv *internal.Conv, table SchemaAndName) ([]string, map[string][]string, error) {
+	return []string{"id"}, nil, nil
+}
+
+func (f *fakeInfoSchema) GetForeignKeys(conv *internal.Conv, table SchemaAndName) ([]schema.ForeignKey, error) {
+	return nil, nil
+}
+
+func (f *fakeInfoSchema) GetColumns(conv *internal.Conv, table SchemaAndName, constraints map[string][]string, primaryKeys []string) (map[string]schema.Column, []string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	colId := internal.GenerateColumnId()
+	return map[string]schema.Column{colId: {Name: "id", Id: colId, Type: schema.Type{Name: f.columns[table.Name]}, NotNull: true}}, []string{colId}, nil
+}
+
+func (f *fakeInfoSchema) GetIndexes(conv *internal.Conv, table SchemaAndName, colNameIdMp map[string]string) ([]schema.Index, error) {
+	return nil, nil
+}
+
+func TestHashSrcSchema(t *testing.T) {
+	is := &fakeInfoSchema{columns: map[string]string{"users": "bigint", "orders": "varchar"}}
+	conv1, conv2 := internal.MakeConv(), internal.MakeConv()
+	_, err := GenerateSrcSchema(conv1, is, 1)
+	assert.Nil(t, err)
+	_, err = GenerateSrcSchema(conv2, is, 2)
+	assert.Nil(t, err)
+	// Ids are generated anew on every read, but don't affect the hash.
+	assert.Equal(t, HashSrcSchema(conv1.SrcSchema), HashSrcSchema(conv2.SrcSchema))
+
+	is.setColumns(map[string]string{"users": "int", "orders": "varchar"})
+	conv3 := internal.MakeConv()
+	_, err = GenerateSrcSchema(conv3, is, 1)
+	assert.Nil(t, err)
+	assert.NotEqual(t, HashSrcSchema(conv1.SrcSchema), HashSrcSchema(conv3.SrcSchema))
+}
+
+func TestSchemaChangeMonitor(t *testing.T) {
+	is := &fakeInfoSchema{columns: map[string]string{"users": "bigint", "orders": "varchar"}}
+	conv := internal.MakeConv()
+	_, err := GenerateSrcSchema(conv, is, 1)
+	assert.Nil(t, err)
+	m := NewSchemaChangeMonitor(conv, is, time.Hour, true)
+
+	diffs, err := m.Check()
+	assert.Nil(t, err)
+	assert.Empty(t, diffs)
+	assert.Nil(t, m.Changes())
+
+	is.setColumns(map[string]string{"users": "int", "carts": "bigint"})
+	diffs, err = m.Check()
+	assert.Nil(t, err)
+	expected := []string{"table carts was added", "table orders was dropped", "table users was changed"}
+	assert.Equal(t, expected, diffs)
+	assert.Equal(t, expected, m.Changes())
+
+	// Writes are blocked until the schema is back to the baseline.
+	resumed := make(chan struct{})
+	go func() {
+		m.WaitIfPaused()
+		close(resumed)
+	}()
+	select {
+	case <-resumed:
+		t.Fatal("WaitIfPaused returned while the schema differs")
+	case <-time.After(50 * time.Millisecond):
+	}
+	is.setColumns(map[string]string{"users": "bigint", "orders": "varchar"})
+	diffs, err = m.Check()
+	assert.Nil(t, err)
+	assert.Empty(t, diffs)
+	select {
+	case <-resumed:
+	case <-time.After(time.Second):
+		t.Fatal("WaitIfPaused did not return after the schema was restored")
+	}
+	// The change is still reported after the migration resumed.
+	assert.Equal(t, expected, m.Changes())
+}
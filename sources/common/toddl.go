@@ -103,6 +103,12 @@ func SchemaToSpannerDDLHelper(conv *internal.Conv, toddl ToDdl, srcTable schema.
 		if srcCol.Ignored.AutoIncrement { //TODO(adibh) - check why this is not there in postgres
 			issues = append(issues, internal.AutoIncrement)
 		}
+		if srcCol.GeneratedExpr != "" {
+			issues = append(issues, internal.GeneratedColumn)
+		}
+		if srcCol.Ignored.Computed {
+			issues = append(issues, internal.ComputedColumn)
+		}
 		// Set the not null constraint to false for unsupported source datatypes
 		isNotNull := srcCol.NotNull
 		if findSchemaIssue(issues, internal.NoGoodType) != -1 {
@@ -119,11 +125,13 @@ func SchemaToSpannerDDLHelper(conv *internal.Conv, toddl ToDdl, srcTable schema.
 		}
 
 		spColDef[srcColId] = ddl.ColumnDef{
-			Name:    colName,
-			T:       ty,
-			NotNull: isNotNull,
-			Comment: "From: " + quoteIfNeeded(srcCol.Name) + " " + srcCol.Type.Print(),
-			Id:      srcColId,
+			Name:                 colName,
+			T:                    ty,
+			NotNull:              isNotNull,
+			Comment:              "From: " + quoteIfNeeded(srcCol.Name) + " " + srcCol.Type.Print(),
+			Id:                   srcColId,
+			GeneratedExpr:        srcCol.GeneratedExpr,
+			AllowCommitTimestamp: findSchemaIssue(issues, internal.CommitTimestamp) != -1,
 		}
 		if !checkIfColumnIsPartOfPK(srcColId, srcTable.PrimaryKeys) {
 			totalNonKeyColumnSize += getColumnSize(ty.Name, ty.Len)
@@ -19,9 +19,16 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/common"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/ddl"
 )
 
+// Capabilities lists the features supported by the CSV source. The schema is
+// read from the existing Spanner database, so only data is loaded from CSV.
+var Capabilities = common.Capabilities{
+	SnapshotExport: true,
+}
+
 func ToSpannerType(columnType string) (ddl.Type, error) {
 	ty := strings.ToUpper(columnType)
 	switch {
@@ -51,6 +51,13 @@ const (
 	conflictThreshold = float64(0.05)
 )
 
+// Capabilities lists the features supported by the DynamoDB source.
+var Capabilities = common.Capabilities{
+	SchemaFromDatabase: true,
+	ChangeDataCapture:  true,
+	SnapshotExport:     true,
+}
+
 type InfoSchemaImpl struct {
 	DynamoClient        dynamodbiface.DynamoDBAPI
 	DynamoStreamsClient dynamodbstreamsiface.DynamoDBStreamsAPI
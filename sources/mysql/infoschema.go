@@ -33,6 +33,17 @@ import (
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/streaming"
 )
 
+// Capabilities lists the features supported by the MySQL source.
+var Capabilities = common.Capabilities{
+	SchemaFromDatabase: true,
+	SchemaFromDump:     true,
+	SchemaFromSnapshot: true,
+	ChangeDataCapture:  true,
+	SnapshotExport:     true,
+	Sharding:           true,
+	ForeignKeys:        true,
+}
+
 // InfoSchemaImpl is MySQL specific implementation for InfoSchema.
 type InfoSchemaImpl struct {
 	DbName        string
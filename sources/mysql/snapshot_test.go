@@ -0,0 +1,93 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/common"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/snapshot"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/ddl"
+)
+
+func TestProcessSchemaFromSnapshot(t *testing.T) {
+	nullInt := func(i int64) snapshot.NullInt64 {
+		return snapshot.NullInt64{NullInt64: sql.NullInt64{Int64: i, Valid: true}}
+	}
+	s := &snapshot.Snapshot{
+		Tables: []snapshot.Table{{TableSchema: "test", TableName: "user"}, {TableSchema: "test", TableName: "cart"}},
+		Columns: []snapshot.Column{
+			{TableSchema: "test", TableName: "user", ColumnName: "user_id", OrdinalPosition: 1, DataType: "text", ColumnType: "text", IsNullable: "NO"},
+			{TableSchema: "test", TableName: "user", ColumnName: "name", OrdinalPosition: 2, DataType: "varchar", ColumnType: "varchar(20)", IsNullable: "NO", CharMaxLen: nullInt(20)},
+			{TableSchema: "test", TableName: "user", ColumnName: "active", OrdinalPosition: 3, DataType: "tinyint", ColumnType: "tinyint(1)", IsNullable: "YES", NumericPrecision: nullInt(3)},
+			{TableSchema: "test", TableName: "cart", ColumnName: "user_id", OrdinalPosition: 2, DataType: "text", ColumnType: "text", IsNullable: "NO"},
+			{TableSchema: "test", TableName: "cart", ColumnName: "id", OrdinalPosition: 1, DataType: "bigint", ColumnType: "bigint", IsNullable: "NO", NumericPrecision: nullInt(64), Extra: "auto_increment"},
+			{TableSchema: "test", TableName: "cart", ColumnName: "total", OrdinalPosition: 3, DataType: "decimal", ColumnType: "decimal(10,2)", IsNullable: "YES", NumericPrecision: nullInt(10), NumericScale: nullInt(2)},
+		},
+		Constraints: []snapshot.Constraint{
+			{TableSchema: "test", TableName: "user", ColumnName: "user_id", ConstraintType: "PRIMARY KEY", OrdinalPosition: 1},
+			{TableSchema: "test", TableName: "cart", ColumnName: "id", ConstraintType: "PRIMARY KEY", OrdinalPosition: 1},
+			{TableSchema: "test", TableName: "cart", ColumnName: "user_id", ConstraintType: "FOREIGN KEY", OrdinalPosition: 1},
+		},
+		ForeignKeys: []snapshot.ForeignKey{
+			{TableSchema: "test", TableName: "cart", ConstraintName: "fk_user", ColumnName: "user_id", ReferencedTableSchema: "test", ReferencedTableName: "user", ReferencedColumnName: "user_id", OrdinalPosition: 1},
+		},
+		Indexes: []snapshot.Index{
+			{TableSchema: "test", TableName: "user", IndexName: "idx_name", ColumnName: "name", OrdinalPosition: 1, IsUnique: true, IsDescending: true},
+		},
+	}
+	conv := internal.MakeConv()
+	err := common.ProcessSchema(conv, NewSnapshotInfoSchema(s), 1, internal.AdditionalSchemaAttributes{})
+	assert.Nil(t, err)
+	expectedSchema := map[string]ddl.CreateTable{
+		"user": {
+			Name:   "user",
+			ColIds: []string{"user_id", "name", "active"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"user_id": {Name: "user_id", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: true},
+				"name":    {Name: "name", T: ddl.Type{Name: ddl.String, Len: 20}, NotNull: true},
+				"active":  {Name: "active", T: ddl.Type{Name: ddl.Bool}},
+			},
+			PrimaryKeys: []ddl.IndexKey{{ColId: "user_id", Order: 1}},
+			Indexes:     []ddl.CreateIndex{{Name: "idx_name", TableId: "user", Unique: true, Keys: []ddl.IndexKey{{ColId: "name", Desc: true, Order: 1}}}},
+		},
+		"cart": {
+			Name:   "cart",
+			ColIds: []string{"id", "user_id", "total"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"id":      {Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+				"user_id": {Name: "user_id", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: true},
+				"total":   {Name: "total", T: ddl.Type{Name: ddl.Numeric}},
+			},
+			PrimaryKeys: []ddl.IndexKey{{ColId: "id", Order: 1}},
+			ForeignKeys: []ddl.Foreignkey{{Name: "fk_user", ColIds: []string{"user_id"}, ReferTableId: "user", ReferColumnIds: []string{"user_id"}}},
+		},
+	}
+	internal.AssertSpSchema(conv, t, expectedSchema, stripSchemaComments(conv.SpSchema))
+	cartId, err := internal.GetTableIdFromSpName(conv.SpSchema, "cart")
+	assert.Nil(t, err)
+	idColId, err := internal.GetColIdFromSpName(conv.SpSchema[cartId].ColDefs, "id")
+	assert.Nil(t, err)
+	assert.True(t, conv.SrcSchema[cartId].ColDefs[idColId].Ignored.AutoIncrement)
+	assert.Equal(t, []string{idColId}, conv.SpSchema[cartId].ColIds[:1])
+	assert.Equal(t, int64(0), conv.Unexpecteds())
+
+	err = NewSnapshotInfoSchema(s).ProcessData(conv, cartId, conv.SrcSchema[cartId], nil, conv.SpSchema[cartId], internal.AdditionalDataAttributes{})
+	assert.NotNil(t, err)
+}
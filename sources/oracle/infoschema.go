@@ -31,6 +31,14 @@ import (
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/streaming"
 )
 
+// Capabilities lists the features supported by the Oracle source.
+var Capabilities = common.Capabilities{
+	SchemaFromDatabase: true,
+	ChangeDataCapture:  true,
+	SnapshotExport:     true,
+	ForeignKeys:        true,
+}
+
 type InfoSchemaImpl struct {
 	DbName        string
 	Db            *sql.DB
@@ -255,6 +255,10 @@ func convArray(spannerType ddl.Type, srcTypeName string, location *time.Location
 		return []interface{}{}, fmt.Errorf("unrecognized data format for array: expected {v1, v2, ...}")
 	}
 	a := strings.Split(v[1:len(v)-1], ",")
+	// Whitespace around array elements is ignored, e.g. {42, 6}.
+	for i := range a {
+		a[i] = strings.TrimSpace(a[i])
+	}
 
 	// The Spanner client for go does not accept []interface{} for arrays.
 	// Instead it only accepts slices of a specific type e.g. []int64, []string.
@@ -350,6 +354,24 @@ func convArray(spannerType ddl.Type, srcTypeName string, location *time.Location
 			r = append(r, spanner.NullInt64{Int64: i, Valid: true})
 		}
 		return r, nil
+	case ddl.Numeric:
+		var r []spanner.NullNumeric
+		for _, s := range a {
+			if s == "NULL" {
+				r = append(r, spanner.NullNumeric{Valid: false})
+				continue
+			}
+			s, err := processQuote(s)
+			if err != nil {
+				return []spanner.NullNumeric{}, err
+			}
+			n := new(big.Rat)
+			if _, ok := n.SetString(s); !ok {
+				return []spanner.NullNumeric{}, fmt.Errorf("can't convert %q to big.Rat", s)
+			}
+			r = append(r, spanner.NullNumeric{Numeric: *n, Valid: true})
+		}
+		return r, nil
 	case ddl.String:
 		var r []spanner.NullString
 		for _, s := range a {
@@ -16,6 +16,7 @@ package postgres
 
 import (
 	"fmt"
+	"math/big"
 	"math/bits"
 	"testing"
 	"time"
@@ -102,6 +103,9 @@ func TestConvertData(t *testing.T) {
 			spanner.NullInt64{Int64: 1, Valid: true},
 			spanner.NullInt64{Int64: 2, Valid: true},
 			spanner.NullInt64{Int64: 3, Valid: true}}},
+		{"numeric array", ddl.Type{Name: ddl.Numeric, IsArray: true}, "", "{1.5,NULL}", []spanner.NullNumeric{
+			spanner.NullNumeric{Numeric: *big.NewRat(3, 2), Valid: true},
+			spanner.NullNumeric{Valid: false}}},
 		{"string array", ddl.Type{Name: ddl.String, Len: ddl.MaxLength, IsArray: true}, "", `{1,NULL,3,"NULL"}`, []spanner.NullString{
 			spanner.NullString{StringVal: "1", Valid: true},
 			spanner.NullString{Valid: false},
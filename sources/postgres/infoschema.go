@@ -37,6 +37,16 @@ import (
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/streaming"
 )
 
+// Capabilities lists the features supported by the PostgreSQL source.
+var Capabilities = common.Capabilities{
+	SchemaFromDatabase: true,
+	SchemaFromDump:     true,
+	SchemaFromSnapshot: true,
+	ChangeDataCapture:  true,
+	SnapshotExport:     true,
+	ForeignKeys:        true,
+}
+
 // InfoSchemaImpl postgres specific implementation for InfoSchema.
 type InfoSchemaImpl struct {
 	Db             *sql.DB
@@ -127,7 +137,7 @@ func (isi InfoSchemaImpl) GetRowsFromTable(conv *internal.Conv, tableId string)
 	} else {
 		tableName = conv.SrcSchema[tableId].Name
 	}
-	q := fmt.Sprintf(`SELECT * FROM "%s"."%s";`, conv.SrcSchema[tableId].Schema, tableName)
+	q := fmt.Sprintf(`SELECT %s FROM "%s"."%s";`, getSelectList(conv, tableId), conv.SrcSchema[tableId].Schema, tableName)
 	rows, err := isi.Db.Query(q)
 	if err != nil {
 		return nil, err
@@ -135,6 +145,29 @@ func (isi InfoSchemaImpl) GetRowsFromTable(conv *internal.Conv, tableId string)
 	return rows, err
 }
 
+// getSelectList returns the select list used to read data from a table.
+// This is '*' unless the table has composite columns mapped to JSON, in
+// which case the columns are listed explicitly and the composite columns
+// are read using to_jsonb so that attribute names and types are preserved.
+func getSelectList(conv *internal.Conv, tableId string) string {
+	srcTable := conv.SrcSchema[tableId]
+	var cols []string
+	hasJsonComposite := false
+	for _, colId := range srcTable.ColIds {
+		srcCol := srcTable.ColDefs[colId]
+		col := fmt.Sprintf(`"%s"`, srcCol.Name)
+		if srcCol.Type.Name == compositeType && len(srcCol.Type.ArrayBounds) == 0 && conv.SpSchema[tableId].ColDefs[colId].T.Name == ddl.JSON {
+			col = fmt.Sprintf(`to_jsonb("%s") AS "%s"`, srcCol.Name, srcCol.Name)
+			hasJsonComposite = true
+		}
+		cols = append(cols, col)
+	}
+	if !hasJsonComposite {
+		return "*"
+	}
+	return strings.Join(cols, ", ")
+}
+
 // ProcessDataRows performs data conversion for source database
 // 'db'. For each table, we extract data using a "SELECT *" query,
 // convert the data to Spanner data (based on the source and Spanner
@@ -277,10 +310,17 @@ func (isi InfoSchemaImpl) GetTables() ([]common.SchemaAndName, error) {
 
 // GetColumns returns a list of Column objects and names
 func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAndName, constraints map[string][]string, primaryKeys []string) (map[string]schema.Column, []string, error) {
-	q := `SELECT c.column_name, c.data_type, e.data_type, c.is_nullable, c.column_default, c.character_maximum_length, c.numeric_precision, c.numeric_scale
+	// Columns of composite types are reported as USER-DEFINED, so we look up
+	// the kind of the type in pg_type and report them as record instead.
+	// Domains don't need special handling since data_type is the base type.
+	q := `SELECT c.column_name,
+                 CASE WHEN c.data_type = 'USER-DEFINED' AND t.typtype = 'c' THEN 'record' ELSE c.data_type END,
+                 e.data_type, c.is_nullable, c.column_default, c.character_maximum_length, c.numeric_precision, c.numeric_scale
               FROM information_schema.COLUMNS c LEFT JOIN information_schema.element_types e
                  ON ((c.table_catalog, c.table_schema, c.table_name, 'TABLE', c.dtd_identifier)
                      = (e.object_catalog, e.object_schema, e.object_name, e.object_type, e.collection_type_identifier))
+              LEFT JOIN (pg_catalog.pg_type t JOIN pg_catalog.pg_namespace n ON t.typnamespace = n.oid)
+                 ON t.typname = c.udt_name AND n.nspname = c.udt_schema
               where table_schema = $1 and table_name = $2 ORDER BY c.ordinal_position;`
 	cols, err := isi.Db.Query(q, table.Schema, table.Name)
 	if err != nil {
@@ -269,8 +269,8 @@ func TestProcessSchema(t *testing.T) {
 			ColIds: []string{"id", "aint", "atext", "b", "bs", "by", "c", "c_8", "d", "f8", "f4", "i8", "i4", "i2", "num", "s", "ts", "tz", "txt", "vc", "vc6"},
 			ColDefs: map[string]ddl.ColumnDef{
 				"id":    ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
-				"aint":  ddl.ColumnDef{Name: "aint", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength, IsArray: false}},
-				"atext": ddl.ColumnDef{Name: "atext", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength, IsArray: false}},
+				"aint":  ddl.ColumnDef{Name: "aint", T: ddl.Type{Name: ddl.Int64, IsArray: true}},
+				"atext": ddl.ColumnDef{Name: "atext", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength, IsArray: true}},
 				"b":     ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.Bool}},
 				"bs":    ddl.ColumnDef{Name: "bs", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
 				"by":    ddl.ColumnDef{Name: "by", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}},
@@ -546,6 +546,31 @@ func TestSetRowStats(t *testing.T) {
 	assert.Equal(t, int64(0), conv.Unexpecteds())
 }
 
+func TestGetSelectList(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SrcSchema["t1"] = schema.Table{
+		Name:   "test",
+		ColIds: []string{"c1", "c2"},
+		ColDefs: map[string]schema.Column{
+			"c1": {Name: "id", Id: "c1", Type: schema.Type{Name: "int8"}},
+			"c2": {Name: "address", Id: "c2", Type: schema.Type{Name: "record"}},
+		},
+	}
+	conv.SpSchema["t1"] = ddl.CreateTable{
+		Name:   "test",
+		ColIds: []string{"c1", "c2"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"c1": {Name: "id", Id: "c1", T: ddl.Type{Name: ddl.Int64}},
+			"c2": {Name: "address", Id: "c2", T: ddl.Type{Name: ddl.JSON}},
+		},
+	}
+	assert.Equal(t, `"id", to_jsonb("address") AS "address"`, getSelectList(conv, "t1"))
+
+	// Composite columns mapped to STRING are read in their text representation.
+	conv.SpSchema["t1"].ColDefs["c2"] = ddl.ColumnDef{Name: "address", Id: "c2", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}}
+	assert.Equal(t, "*", getSelectList(conv, "t1"))
+}
+
 func mkMockDB(t *testing.T, ms []mockSpec) *sql.DB {
 	db, mock, err := sqlmock.New()
 	assert.Nil(t, err)
@@ -36,11 +36,19 @@ func (tdi ToDdlImpl) ToSpannerType(conv *internal.Conv, spType string, srcType s
 	if len(srcType.ArrayBounds) > 1 {
 		ty = ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
 		issues = append(issues, internal.MultiDimensionalArray)
-	} else if len(srcType.ArrayBounds) == 1 {
-		// This check has been added because we don't support Array<primitive type> to string conversions
-		// and Array datatype is currently not supported in datastream.
+	} else if len(srcType.ArrayBounds) == 1 && conv.Audit.StreamingStats.Streaming {
+		// Array datatype is currently not supported in datastream, so arrays
+		// are kept as strings for streaming migrations.
 		ty = ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
 		issues = append(issues, internal.ArrayTypeNotSupported)
+	} else if len(srcType.ArrayBounds) == 1 {
+		// Single dimensional arrays map to Spanner arrays of the mapped element type.
+		ty.IsArray = true
+		if conv.SpDialect == constants.DIALECT_POSTGRESQL {
+			// Arrays are converted to STRING(MAX) for the PostgreSQL dialect,
+			// so flag them here since the array type is lost.
+			issues = append(issues, internal.ArrayTypeNotSupported)
+		}
 	}
 	if conv.SpDialect == constants.DIALECT_POSTGRESQL {
 		ty = common.ToPGDialectType(ty)
@@ -48,6 +56,11 @@ func (tdi ToDdlImpl) ToSpannerType(conv *internal.Conv, spType string, srcType s
 	return ty, issues
 }
 
+// compositeType is the source type name used for columns of PostgreSQL
+// composite (row) types. We use the name of the PostgreSQL pseudo-type for
+// rows since the actual composite type names are user defined.
+const compositeType = "record"
+
 // toSpannerTypeInternal defines the mapping of source types into Spanner
 // types. Each source type has a default Spanner type, as well as other potential
 // Spanner types it could map to. When calling toSpannerTypeInternal, you specify
@@ -146,6 +159,15 @@ func toSpannerTypeInternal(srcType schema.Type, spType string) (ddl.Type, []inte
 			// if this numeric won't fit in Spanner's NUMERIC.
 			return ddl.Type{Name: ddl.Numeric}, nil
 		}
+	case compositeType:
+		// Composite values are converted to a JSON object keyed by attribute
+		// name, or kept in their textual row representation e.g. (1,"a b").
+		switch spType {
+		case ddl.String:
+			return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, nil
+		default:
+			return ddl.Type{Name: ddl.JSON}, nil
+		}
 	case "serial":
 		switch spType {
 		case ddl.String:
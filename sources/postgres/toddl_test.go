@@ -271,6 +271,40 @@ func TestToExperimentalSpannerType(t *testing.T) {
 	assert.Equal(t, expectedIssues, conv.SchemaIssues[tableId].ColumnLevelIssues)
 }
 
+func TestToSpannerTypeArrayAndComposite(t *testing.T) {
+	conv := internal.MakeConv()
+	tc := []struct {
+		name           string
+		dialect        string
+		spType         string
+		srcType        schema.Type
+		expectedType   ddl.Type
+		expectedIssues []internal.SchemaIssue
+	}{
+		{"int array", "", "", schema.Type{Name: "int8", ArrayBounds: []int64{-1}}, ddl.Type{Name: ddl.Int64, IsArray: true}, nil},
+		{"widened int array", "", "", schema.Type{Name: "int4", ArrayBounds: []int64{-1}}, ddl.Type{Name: ddl.Int64, IsArray: true}, []internal.SchemaIssue{internal.Widened}},
+		{"varchar array", "", "", schema.Type{Name: "varchar", Mods: []int64{10}, ArrayBounds: []int64{-1}}, ddl.Type{Name: ddl.String, Len: 10, IsArray: true}, nil},
+		{"multi dimensional array", "", "", schema.Type{Name: "text", ArrayBounds: []int64{-1, -1}}, ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.MultiDimensionalArray}},
+		{"array with pg dialect", constants.DIALECT_POSTGRESQL, "", schema.Type{Name: "int8", ArrayBounds: []int64{-1}}, ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.ArrayTypeNotSupported}},
+		{"composite", "", "", schema.Type{Name: "record"}, ddl.Type{Name: ddl.JSON}, nil},
+		{"composite as string", "", ddl.String, schema.Type{Name: "record"}, ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, nil},
+	}
+	for _, tt := range tc {
+		conv.SpDialect = tt.dialect
+		ty, issues := ToDdlImpl{}.ToSpannerType(conv, tt.spType, tt.srcType)
+		assert.Equal(t, tt.expectedType, ty, tt.name)
+		assert.Equal(t, tt.expectedIssues, issues, tt.name)
+	}
+}
+
+func TestToSpannerTypeArrayStreaming(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.Audit.StreamingStats.Streaming = true
+	ty, issues := ToDdlImpl{}.ToSpannerType(conv, "", schema.Type{Name: "int8", ArrayBounds: []int64{-1}})
+	assert.Equal(t, ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, ty)
+	assert.Equal(t, []internal.SchemaIssue{internal.ArrayTypeNotSupported}, issues)
+}
+
 func dropComments(t *ddl.CreateTable) {
 	t.Comment = ""
 	for _, c := range t.ColIds {
@@ -0,0 +1,257 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	sp "cloud.google.com/go/spanner"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/schema"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/common"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/ddl"
+)
+
+// Dialect holds the source specific parts of schema conversion, supplied by
+// the source package whose information_schema was exported.
+type Dialect struct {
+	ToDdl common.ToDdl
+	// ToType builds the source type of a column.
+	ToType func(c Column) schema.Type
+	// GetTableName returns the name used for a table in the session.
+	GetTableName func(schema, tableName string) string
+	// GeneratedExpr translates the definition of a computed column to a
+	// Spanner expression, given the data types of the table's columns. It
+	// returns false if the definition can't be translated. Optional.
+	GeneratedExpr func(definition string, colTypes map[string]string) (string, bool)
+}
+
+// InfoSchemaImpl implements common.InfoSchema by reading from a snapshot
+// instead of querying the source database. Only schema conversion is
+// supported: the methods reading data return an error.
+type InfoSchemaImpl struct {
+	Snapshot *Snapshot
+	Dialect  Dialect
+}
+
+// GetToDdl implements the common.InfoSchema interface.
+func (isi InfoSchemaImpl) GetToDdl() common.ToDdl {
+	return isi.Dialect.ToDdl
+}
+
+// GetTableName returns table name.
+func (isi InfoSchemaImpl) GetTableName(schema string, tableName string) string {
+	return isi.Dialect.GetTableName(schema, tableName)
+}
+
+// GetTables returns the tables in the snapshot.
+func (isi InfoSchemaImpl) GetTables() ([]common.SchemaAndName, error) {
+	var tables []common.SchemaAndName
+	for _, t := range isi.Snapshot.Tables {
+		tables = append(tables, common.SchemaAndName{Schema: t.TableSchema, Name: t.TableName})
+	}
+	return tables, nil
+}
+
+// GetColumns returns a list of Column objects and names.
+func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAndName, constraints map[string][]string, primaryKeys []string) (map[string]schema.Column, []string, error) {
+	var rows []Column
+	for _, c := range isi.Snapshot.Columns {
+		if c.TableSchema == table.Schema && c.TableName == table.Name {
+			rows = append(rows, c)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].OrdinalPosition < rows[j].OrdinalPosition })
+	colDefs := make(map[string]schema.Column)
+	var colIds []string
+	colTypes := make(map[string]string)
+	computedCols := make(map[string]string)
+	for _, row := range rows {
+		ignored := schema.Ignored{}
+		for _, c := range constraints[row.ColumnName] {
+			// PRIMARY KEY has already been filtered out, and UNIQUE and
+			// FOREIGN KEY are handled elsewhere.
+			if c == "CHECK" {
+				ignored.Check = true
+			}
+		}
+		ignored.Default = row.ColumnDefault.Valid
+		ignored.AutoIncrement = row.Extra == "auto_increment"
+		colId := internal.GenerateColumnId()
+		colDefs[colId] = schema.Column{
+			Id:      colId,
+			Name:    row.ColumnName,
+			Type:    isi.Dialect.ToType(row),
+			NotNull: common.ToNotNull(conv, row.IsNullable),
+			Ignored: ignored,
+		}
+		colIds = append(colIds, colId)
+		colTypes[row.ColumnName] = row.DataType
+		if row.ComputedDefinition.Valid {
+			computedCols[colId] = row.ComputedDefinition.String
+		}
+	}
+	for colId, definition := range computedCols {
+		c := colDefs[colId]
+		if isi.Dialect.GeneratedExpr == nil {
+			c.Ignored.Computed = true
+		} else if expr, ok := isi.Dialect.GeneratedExpr(definition, colTypes); ok {
+			c.GeneratedExpr = expr
+		} else {
+			c.Ignored.Computed = true
+		}
+		colDefs[colId] = c
+	}
+	return colDefs, colIds, nil
+}
+
+// GetConstraints returns a list of primary keys and by-column map of
+// other constraints, preserving the ordinal order of primary key columns.
+func (isi InfoSchemaImpl) GetConstraints(conv *internal.Conv, table common.SchemaAndName) ([]string, map[string][]string, error) {
+	var rows []Constraint
+	for _, c := range isi.Snapshot.Constraints {
+		if c.TableSchema == table.Schema && c.TableName == table.Name {
+			rows = append(rows, c)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].OrdinalPosition < rows[j].OrdinalPosition })
+	var primaryKeys []string
+	m := make(map[string][]string)
+	for _, row := range rows {
+		if row.ColumnName == "" || row.ConstraintType == "" {
+			conv.Unexpected("Got empty col or constraint")
+			continue
+		}
+		switch row.ConstraintType {
+		case "PRIMARY KEY":
+			primaryKeys = append(primaryKeys, row.ColumnName)
+		default:
+			m[row.ColumnName] = append(m[row.ColumnName], row.ConstraintType)
+		}
+	}
+	return primaryKeys, m, nil
+}
+
+// GetForeignKeys returns a list of all the foreign key constraints.
+func (isi InfoSchemaImpl) GetForeignKeys(conv *internal.Conv, table common.SchemaAndName) (foreignKeys []schema.ForeignKey, err error) {
+	var rows []ForeignKey
+	for _, fk := range isi.Snapshot.ForeignKeys {
+		if fk.TableSchema == table.Schema && fk.TableName == table.Name {
+			rows = append(rows, fk)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].OrdinalPosition < rows[j].OrdinalPosition })
+	fKeys := make(map[string]common.FkConstraint)
+	var keyNames []string
+	for _, row := range rows {
+		if _, found := fKeys[row.ConstraintName]; found {
+			fk := fKeys[row.ConstraintName]
+			fk.Cols = append(fk.Cols, row.ColumnName)
+			fk.Refcols = append(fk.Refcols, row.ReferencedColumnName)
+			fKeys[row.ConstraintName] = fk
+			continue
+		}
+		fKeys[row.ConstraintName] = common.FkConstraint{
+			Name:    row.ConstraintName,
+			Table:   isi.GetTableName(row.ReferencedTableSchema, row.ReferencedTableName),
+			Refcols: []string{row.ReferencedColumnName},
+			Cols:    []string{row.ColumnName}}
+		keyNames = append(keyNames, row.ConstraintName)
+	}
+	sort.Strings(keyNames)
+	for _, k := range keyNames {
+		foreignKeys = append(foreignKeys,
+			schema.ForeignKey{
+				Id:               internal.GenerateForeignkeyId(),
+				Name:             fKeys[k].Name,
+				ColumnNames:      fKeys[k].Cols,
+				ReferTableName:   fKeys[k].Table,
+				ReferColumnNames: fKeys[k].Refcols})
+	}
+	return foreignKeys, nil
+}
+
+// GetIndexes return a list of all indexes for the specified table.
+func (isi InfoSchemaImpl) GetIndexes(conv *internal.Conv, table common.SchemaAndName, colNameIdMap map[string]string) ([]schema.Index, error) {
+	var rows []Index
+	for _, idx := range isi.Snapshot.Indexes {
+		if idx.TableSchema == table.Schema && idx.TableName == table.Name {
+			rows = append(rows, idx)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].IndexName != rows[j].IndexName {
+			return rows[i].IndexName < rows[j].IndexName
+		}
+		return rows[i].OrdinalPosition < rows[j].OrdinalPosition
+	})
+	indexMap := make(map[string]schema.Index)
+	var indexNames []string
+	var indexes []schema.Index
+	for _, row := range rows {
+		if _, found := indexMap[row.IndexName]; !found {
+			indexNames = append(indexNames, row.IndexName)
+			indexMap[row.IndexName] = schema.Index{
+				Id:     internal.GenerateIndexesId(),
+				Name:   row.IndexName,
+				Unique: row.IsUnique}
+		}
+		index := indexMap[row.IndexName]
+		if row.IsIncluded {
+			index.StoredColumnIds = append(index.StoredColumnIds, colNameIdMap[row.ColumnName])
+		} else {
+			index.Keys = append(index.Keys, schema.Key{
+				ColId: colNameIdMap[row.ColumnName],
+				Desc:  row.IsDescending})
+		}
+		indexMap[row.IndexName] = index
+	}
+	for _, k := range indexNames {
+		indexes = append(indexes, indexMap[k])
+	}
+	return indexes, nil
+}
+
+func errDataNotSupported() error {
+	return fmt.Errorf("data migration is not supported from an information_schema snapshot, please connect to the source database instead")
+}
+
+// GetRowsFromTable is not supported for snapshots.
+func (isi InfoSchemaImpl) GetRowsFromTable(conv *internal.Conv, tableId string) (interface{}, error) {
+	return nil, errDataNotSupported()
+}
+
+// GetRowCount is not supported for snapshots.
+func (isi InfoSchemaImpl) GetRowCount(table common.SchemaAndName) (int64, error) {
+	return 0, errDataNotSupported()
+}
+
+// ProcessData is not supported for snapshots.
+func (isi InfoSchemaImpl) ProcessData(conv *internal.Conv, tableId string, srcSchema schema.Table, spCols []string, spSchema ddl.CreateTable, additionalAttributes internal.AdditionalDataAttributes) error {
+	return errDataNotSupported()
+}
+
+// StartChangeDataCapture is not supported for snapshots.
+func (isi InfoSchemaImpl) StartChangeDataCapture(ctx context.Context, conv *internal.Conv) (map[string]interface{}, error) {
+	return nil, errDataNotSupported()
+}
+
+// StartStreamingMigration is not supported for snapshots.
+func (isi InfoSchemaImpl) StartStreamingMigration(ctx context.Context, client *sp.Client, conv *internal.Conv, streamInfo map[string]interface{}) (internal.DataflowOutput, error) {
+	return internal.DataflowOutput{}, errDataNotSupported()
+}
@@ -0,0 +1,275 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snapshot implements schema conversion from an offline export
+// (snapshot) of a source database's information_schema, for environments
+// where the Spanner migration tool cannot connect to the source directly.
+//
+// A snapshot is either a single JSON file with one array per kind of row
+// (see Snapshot), or a directory holding one CSV file per kind of row named
+// tables.csv, columns.csv, constraints.csv, foreign_keys.csv and indexes.csv.
+// CSV headers use the same names as the JSON fields, and an empty CSV cell is
+// read as NULL.
+package snapshot
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// NullString is a string in the snapshot which may be NULL.
+type NullString struct {
+	sql.NullString
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (ns *NullString) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		ns.Valid = false
+		return nil
+	}
+	ns.Valid = true
+	return json.Unmarshal(b, &ns.String)
+}
+
+// NullInt64 is an integer in the snapshot which may be NULL.
+type NullInt64 struct {
+	sql.NullInt64
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (ni *NullInt64) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		ni.Valid = false
+		return nil
+	}
+	ni.Valid = true
+	return json.Unmarshal(b, &ni.Int64)
+}
+
+// Table is a row of the tables export, one per user table.
+type Table struct {
+	TableSchema string `json:"table_schema"`
+	TableName   string `json:"table_name"`
+}
+
+// Column is a row of the columns export, mirroring information_schema.columns.
+// ColumnType and Extra are only used for MySQL, ElementDataType for
+// PostgreSQL arrays and ComputedDefinition for SQL Server computed columns.
+type Column struct {
+	TableSchema        string     `json:"table_schema"`
+	TableName          string     `json:"table_name"`
+	ColumnName         string     `json:"column_name"`
+	OrdinalPosition    int64      `json:"ordinal_position"`
+	DataType           string     `json:"data_type"`
+	ColumnType         string     `json:"column_type"`
+	ElementDataType    NullString `json:"element_data_type"`
+	IsNullable         string     `json:"is_nullable"`
+	ColumnDefault      NullString `json:"column_default"`
+	CharMaxLen         NullInt64  `json:"character_maximum_length"`
+	NumericPrecision   NullInt64  `json:"numeric_precision"`
+	NumericScale       NullInt64  `json:"numeric_scale"`
+	Extra              string     `json:"extra"`
+	ComputedDefinition NullString `json:"computed_definition"`
+}
+
+// Constraint is a row of the constraints export, one per column of each
+// PRIMARY KEY, UNIQUE, CHECK or FOREIGN KEY constraint.
+type Constraint struct {
+	TableSchema     string `json:"table_schema"`
+	TableName       string `json:"table_name"`
+	ColumnName      string `json:"column_name"`
+	ConstraintType  string `json:"constraint_type"`
+	OrdinalPosition int64  `json:"ordinal_position"`
+}
+
+// ForeignKey is a row of the foreign keys export, one per column of each
+// foreign key.
+type ForeignKey struct {
+	TableSchema           string `json:"table_schema"`
+	TableName             string `json:"table_name"`
+	ConstraintName        string `json:"constraint_name"`
+	ColumnName            string `json:"column_name"`
+	ReferencedTableSchema string `json:"referenced_table_schema"`
+	ReferencedTableName   string `json:"referenced_table_name"`
+	ReferencedColumnName  string `json:"referenced_column_name"`
+	OrdinalPosition       int64  `json:"ordinal_position"`
+}
+
+// Index is a row of the indexes export, one per column of each secondary
+// index. Primary key indexes must not be included.
+type Index struct {
+	TableSchema     string `json:"table_schema"`
+	TableName       string `json:"table_name"`
+	IndexName       string `json:"index_name"`
+	ColumnName      string `json:"column_name"`
+	OrdinalPosition int64  `json:"ordinal_position"`
+	IsUnique        bool   `json:"is_unique"`
+	IsDescending    bool   `json:"is_descending"`
+	IsIncluded      bool   `json:"is_included"`
+}
+
+// Snapshot holds the exported information_schema of a source database.
+type Snapshot struct {
+	Tables      []Table      `json:"tables"`
+	Columns     []Column     `json:"columns"`
+	Constraints []Constraint `json:"constraints"`
+	ForeignKeys []ForeignKey `json:"foreign_keys"`
+	Indexes     []Index      `json:"indexes"`
+}
+
+// Load reads a snapshot from path, which is either a JSON file or a
+// directory of CSV files.
+func Load(path string) (*Snapshot, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read information_schema snapshot: %v", err)
+	}
+	if fi.IsDir() {
+		return loadCSV(path)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read information_schema snapshot: %v", err)
+	}
+	s := &Snapshot{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("can't parse information_schema snapshot %s: %v", path, err)
+	}
+	return s, s.validate()
+}
+
+func loadCSV(dir string) (*Snapshot, error) {
+	s := &Snapshot{}
+	files := []struct {
+		name     string
+		rows     interface{}
+		required bool
+	}{
+		{"tables.csv", &s.Tables, true},
+		{"columns.csv", &s.Columns, true},
+		{"constraints.csv", &s.Constraints, false},
+		{"foreign_keys.csv", &s.ForeignKeys, false},
+		{"indexes.csv", &s.Indexes, false},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if _, err := os.Stat(path); os.IsNotExist(err) && !f.required {
+			continue
+		}
+		if err := readCSVRows(path, f.rows); err != nil {
+			return nil, err
+		}
+	}
+	return s, s.validate()
+}
+
+// readCSVRows reads the rows of the CSV file at path into rows, a pointer to
+// a slice of structs. Columns are matched to struct fields by json tag.
+func readCSVRows(path string, rows interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("can't read information_schema snapshot: %v", err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return fmt.Errorf("can't parse %s: %v", path, err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("can't parse %s: missing header", path)
+	}
+	slice := reflect.ValueOf(rows).Elem()
+	rowType := slice.Type().Elem()
+	fields := make(map[string]int)
+	for i := 0; i < rowType.NumField(); i++ {
+		fields[rowType.Field(i).Tag.Get("json")] = i
+	}
+	header := records[0]
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if _, ok := fields[h]; !ok {
+			return fmt.Errorf("can't parse %s: unknown column %s", path, h)
+		}
+		header[i] = h
+	}
+	for line, record := range records[1:] {
+		row := reflect.New(rowType).Elem()
+		for i, val := range record {
+			if err := setField(row.Field(fields[header[i]]), val); err != nil {
+				return fmt.Errorf("can't parse %s line %d column %s: %v", path, line+2, header[i], err)
+			}
+		}
+		slice.Set(reflect.Append(slice, row))
+	}
+	return nil
+}
+
+func setField(field reflect.Value, val string) error {
+	switch v := field.Addr().Interface().(type) {
+	case *string:
+		*v = val
+	case *NullString:
+		v.String, v.Valid = val, val != ""
+	case *int64:
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return err
+		}
+		*v = n
+	case *NullInt64:
+		if val == "" {
+			v.Valid = false
+			return nil
+		}
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.Int64, v.Valid = n, true
+	case *bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		*v = b
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+func (s *Snapshot) validate() error {
+	if len(s.Tables) == 0 {
+		return fmt.Errorf("information_schema snapshot has no tables")
+	}
+	tables := make(map[Table]bool)
+	for _, t := range s.Tables {
+		tables[t] = true
+	}
+	for _, c := range s.Columns {
+		if !tables[Table{TableSchema: c.TableSchema, TableName: c.TableName}] {
+			return fmt.Errorf("information_schema snapshot has column %s for unknown table %s.%s", c.ColumnName, c.TableSchema, c.TableName)
+		}
+	}
+	return nil
+}
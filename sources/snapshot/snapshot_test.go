@@ -0,0 +1,128 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var expectedSnapshot = &Snapshot{
+	Tables: []Table{{TableSchema: "public", TableName: "orders"}},
+	Columns: []Column{
+		{TableSchema: "public", TableName: "orders", ColumnName: "id", OrdinalPosition: 1, DataType: "bigint", IsNullable: "NO", NumericPrecision: NullInt64{sql.NullInt64{Int64: 64, Valid: true}}, NumericScale: NullInt64{sql.NullInt64{Int64: 0, Valid: true}}},
+		{TableSchema: "public", TableName: "orders", ColumnName: "tags", OrdinalPosition: 2, DataType: "ARRAY", ElementDataType: NullString{sql.NullString{String: "text", Valid: true}}, IsNullable: "YES", ColumnDefault: NullString{sql.NullString{String: "'{}'", Valid: true}}},
+	},
+	Constraints: []Constraint{{TableSchema: "public", TableName: "orders", ColumnName: "id", ConstraintType: "PRIMARY KEY", OrdinalPosition: 1}},
+	Indexes:     []Index{{TableSchema: "public", TableName: "orders", IndexName: "idx_tags", ColumnName: "tags", OrdinalPosition: 1, IsDescending: true}},
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.Nil(t, err)
+	for name, content := range files {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestLoadJSON(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"infoschema.json": `{
+		"tables": [{"table_schema": "public", "table_name": "orders"}],
+		"columns": [
+			{"table_schema": "public", "table_name": "orders", "column_name": "id", "ordinal_position": 1, "data_type": "bigint", "is_nullable": "NO", "column_default": null, "numeric_precision": 64, "numeric_scale": 0},
+			{"table_schema": "public", "table_name": "orders", "column_name": "tags", "ordinal_position": 2, "data_type": "ARRAY", "element_data_type": "text", "is_nullable": "YES", "column_default": "'{}'", "character_maximum_length": null}
+		],
+		"constraints": [{"table_schema": "public", "table_name": "orders", "column_name": "id", "constraint_type": "PRIMARY KEY", "ordinal_position": 1}],
+		"indexes": [{"table_schema": "public", "table_name": "orders", "index_name": "idx_tags", "column_name": "tags", "ordinal_position": 1, "is_unique": false, "is_descending": true}]
+	}`})
+	defer os.RemoveAll(dir)
+	s, err := Load(filepath.Join(dir, "infoschema.json"))
+	assert.Nil(t, err)
+	assert.Equal(t, expectedSnapshot, s)
+}
+
+func TestLoadCSV(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"tables.csv": "table_schema,table_name\npublic,orders\n",
+		"columns.csv": "table_schema,table_name,column_name,ordinal_position,data_type,element_data_type,is_nullable,column_default,numeric_precision,numeric_scale\n" +
+			"public,orders,id,1,bigint,,NO,,64,0\n" +
+			"public,orders,tags,2,ARRAY,text,YES,'{}',,\n",
+		"constraints.csv": "table_schema,table_name,column_name,constraint_type,ordinal_position\npublic,orders,id,PRIMARY KEY,1\n",
+		"indexes.csv":     "table_schema,table_name,index_name,column_name,ordinal_position,is_unique,is_descending,is_included\npublic,orders,idx_tags,tags,1,false,true,false\n",
+	})
+	defer os.RemoveAll(dir)
+	s, err := Load(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedSnapshot, s)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		path        string
+		expectError string
+	}{
+		{
+			name:        "missing columns file",
+			files:       map[string]string{"tables.csv": "table_schema,table_name\npublic,orders\n"},
+			expectError: "columns.csv",
+		},
+		{
+			name: "unknown csv column",
+			files: map[string]string{
+				"tables.csv":  "table_schema,table_name,owner\npublic,orders,me\n",
+				"columns.csv": "table_schema,table_name,column_name\n",
+			},
+			expectError: "unknown column owner",
+		},
+		{
+			name: "bad integer",
+			files: map[string]string{
+				"tables.csv":  "table_schema,table_name\npublic,orders\n",
+				"columns.csv": "table_schema,table_name,column_name,ordinal_position\npublic,orders,id,first\n",
+			},
+			expectError: "line 2 column ordinal_position",
+		},
+		{
+			name: "column of unknown table",
+			files: map[string]string{
+				"tables.csv":  "table_schema,table_name\npublic,orders\n",
+				"columns.csv": "table_schema,table_name,column_name\npublic,users,id\n",
+			},
+			expectError: "unknown table public.users",
+		},
+		{
+			name:        "no tables",
+			files:       map[string]string{"infoschema.json": `{"tables": []}`},
+			path:        "infoschema.json",
+			expectError: "has no tables",
+		},
+	}
+	for _, tc := range tests {
+		dir := writeFiles(t, tc.files)
+		_, err := Load(filepath.Join(dir, tc.path))
+		if assert.NotNil(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.expectError, tc.name)
+		}
+		os.RemoveAll(dir)
+	}
+}
@@ -61,16 +61,25 @@ func ConvertData(conv *internal.Conv, tableId string, colIds []string, srcSchema
 		return "", []string{}, []interface{}{}, fmt.Errorf("ConvertData: colId and vals don't all have the same lengths: len(colIds)=%d, len(vals)=%d", len(colIds), len(vals))
 	}
 	for i, colId := range colIds {
-		// Skip columns with 'NULL' values.
-		if vals[i] == "NULL" {
-			continue
-		}
-
 		spColDef, ok1 := spSchema.ColDefs[colId]
 		srcColDef, ok2 := srcSchema.ColDefs[colId]
 		if !ok1 || !ok2 {
 			return "", []string{}, []interface{}{}, fmt.Errorf("can't find Spanner and source-db schema for colId %s", colId)
 		}
+		// Generated columns are computed by Spanner and can't be written.
+		if spColDef.GeneratedExpr != "" {
+			continue
+		}
+		// Source row versions are replaced by the commit timestamp.
+		if spColDef.IsCommitTimestamp() {
+			v = append(v, spanner.CommitTimestamp)
+			c = append(c, spColDef.Name)
+			continue
+		}
+		// Skip columns with 'NULL' values.
+		if vals[i] == "NULL" {
+			continue
+		}
 		var x interface{}
 		var err error
 		x, err = convScalar(conv, spColDef.T, srcColDef.Type.Name, conv.TimezoneOffset, vals[i])
@@ -79,6 +88,14 @@ func ConvertData(conv *internal.Conv, tableId string, colIds []string, srcSchema
 		}
 		v = append(v, x)
 		c = append(c, spColDef.Name)
+		if offsetColDef, ok := spSchema.ColDefs[spColDef.TimezoneOffsetColId]; ok && spColDef.T.Name == ddl.Timestamp {
+			t, ok := x.(time.Time)
+			if !ok {
+				return "", []string{}, []interface{}{}, fmt.Errorf("can't get the timezone offset of column %s: %v is not a timestamp", spColDef.Name, x)
+			}
+			v = append(v, t.Format("-07:00"))
+			c = append(c, offsetColDef.Name)
+		}
 	}
 	if aux, ok := conv.SyntheticPKeys[tableId]; ok {
 		c = append(c, conv.SpSchema[tableId].ColDefs[aux.ColId].Name)
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/schema"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/ddl"
@@ -340,3 +341,33 @@ func getTimeWithTimezone(t *testing.T, s string) time.Time {
 	assert.Nil(t, err, fmt.Sprintf("getTime can't parse %s:", s))
 	return x
 }
+
+func TestConvertSpecialColumns(t *testing.T) {
+	tableName := "testtable"
+	tableId := "t1"
+	spTable := ddl.CreateTable{
+		Name:   tableName,
+		Id:     tableId,
+		ColIds: []string{"c1", "c2", "c3", "c4", "c5"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"c1": {Name: "a", Id: "c1", T: ddl.Type{Name: ddl.Int64}},
+			"c2": {Name: "b", Id: "c2", T: ddl.Type{Name: ddl.Int64}, GeneratedExpr: "a*2"},
+			"c3": {Name: "c", Id: "c3", T: ddl.Type{Name: ddl.Timestamp}, AllowCommitTimestamp: true},
+			"c4": {Name: "d", Id: "c4", T: ddl.Type{Name: ddl.Timestamp}, TimezoneOffsetColId: "c5"},
+			"c5": {Name: "d_offset", Id: "c5", T: ddl.Type{Name: ddl.String, Len: 6}},
+		}}
+	srcTable := schema.Table{
+		Name:   tableName,
+		Id:     tableId,
+		ColIds: []string{"c1", "c2", "c3", "c4"},
+		ColDefs: map[string]schema.Column{
+			"c1": {Name: "a", Id: "c1", Type: schema.Type{Name: "int"}},
+			"c2": {Name: "b", Id: "c2", Type: schema.Type{Name: "int"}},
+			"c3": {Name: "c", Id: "c3", Type: schema.Type{Name: "timestamp"}},
+			"c4": {Name: "d", Id: "c4", Type: schema.Type{Name: "datetimeoffset"}},
+		}}
+	conv := buildConv(spTable, srcTable)
+	atable, acols, avals, err := ConvertData(conv, tableId, srcTable.ColIds, conv.SrcSchema[tableId], conv.SpSchema[tableId], []string{"6", "12", "2010", "2021-12-15T07:39:52.9433333+01:20"})
+	checkResults(t, atable, acols, avals, err, tableName, []string{"a", "c", "d", "d_offset"},
+		[]interface{}{int64(6), spanner.CommitTimestamp, getTimeWithTimezone(t, "2021-12-15T07:39:52.9433333+01:20"), "+01:20"}, "special columns")
+}
@@ -43,6 +43,14 @@ const (
 	dateType           string = "date"
 )
 
+// Capabilities lists the features supported by the SQL Server source.
+var Capabilities = common.Capabilities{
+	SchemaFromDatabase: true,
+	SchemaFromSnapshot: true,
+	SnapshotExport:     true,
+	ForeignKeys:        true,
+}
+
 type InfoSchemaImpl struct {
 	DbName string
 	Db     *sql.DB
@@ -220,16 +228,19 @@ func (isi InfoSchemaImpl) GetTables() ([]common.SchemaAndName, error) {
 func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAndName, constraints map[string][]string, primaryKeys []string) (map[string]schema.Column, []string, error) {
 	q := `
 		SELECT 
-			column_name, 
-			data_type, 
-			is_nullable, 
-			column_default, 
-			character_maximum_length, 
-			numeric_precision, 
-			numeric_scale
-		FROM information_schema.COLUMNS 
-		WHERE table_schema = @p1 and table_name = @p2 
-		ORDER BY ordinal_position;
+			c.column_name, 
+			c.data_type, 
+			c.is_nullable, 
+			c.column_default, 
+			c.character_maximum_length, 
+			c.numeric_precision, 
+			c.numeric_scale,
+			cc.definition
+		FROM information_schema.COLUMNS AS c
+		LEFT JOIN sys.computed_columns AS cc
+			ON cc.object_id = OBJECT_ID(QUOTENAME(c.table_schema) + '.' + QUOTENAME(c.table_name)) AND cc.name = c.column_name
+		WHERE c.table_schema = @p1 and c.table_name = @p2 
+		ORDER BY c.ordinal_position;
 	`
 	cols, err := isi.Db.Query(q, table.Schema, table.Name)
 	if err != nil {
@@ -240,11 +251,13 @@ func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAnd
 	var colIds []string
 	var colName, dataType string
 	var isNullable string
-	var colDefault sql.NullString
+	var colDefault, computedDefinition sql.NullString
 	// elementDataType
 	var charMaxLen, numericPrecision, numericScale sql.NullInt64
+	colTypes := make(map[string]string)
+	computedCols := make(map[string]string)
 	for cols.Next() {
-		err := cols.Scan(&colName, &dataType, &isNullable, &colDefault, &charMaxLen, &numericPrecision, &numericScale)
+		err := cols.Scan(&colName, &dataType, &isNullable, &colDefault, &charMaxLen, &numericPrecision, &numericScale, &computedDefinition)
 		if err != nil {
 			conv.Unexpected(fmt.Sprintf("Can't scan: %v", err))
 			continue
@@ -272,6 +285,21 @@ func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAnd
 		}
 		colDefs[colId] = c
 		colIds = append(colIds, colId)
+		colTypes[colName] = dataType
+		if computedDefinition.Valid {
+			computedCols[colId] = computedDefinition.String
+		}
+	}
+	// Computed columns can reference any column of the table, so they are
+	// translated once all the columns are known.
+	for colId, definition := range computedCols {
+		c := colDefs[colId]
+		if expr, ok := toGeneratedExpr(definition, colTypes); ok {
+			c.GeneratedExpr = expr
+		} else {
+			c.Ignored.Computed = true
+		}
+		colDefs[colId] = c
 	}
 	return colDefs, colIds, nil
 }
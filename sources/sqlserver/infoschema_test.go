@@ -71,11 +71,11 @@ func TestProcessSchema(t *testing.T) {
 		{
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"dbo", "user"},
-			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "definition"},
 			rows: [][]driver.Value{
-				{"user_id", "text", "NO", nil, nil, nil, nil},
-				{"name", "text", "NO", nil, nil, nil, nil},
-				{"ref", "bigint", "YES", nil, nil, nil, nil}},
+				{"user_id", "text", "NO", nil, nil, nil, nil, nil},
+				{"name", "text", "NO", nil, nil, nil, nil, nil},
+				{"ref", "bigint", "YES", nil, nil, nil, nil, nil}},
 		},
 		// db call to fetch index happens after fetching of column
 		{
@@ -99,45 +99,47 @@ func TestProcessSchema(t *testing.T) {
 		{
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"dbo", "test"},
-			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "definition"},
 			rows: [][]driver.Value{
-				{"Id", "int", "NO", nil, nil, 10, 0},
-				{"BigInt", "bigint", "YES", nil, nil, 19, 0},
-				{"Binary", "binary", "YES", nil, 50, nil, nil},
-				{"Bit", "bit", "YES", nil, nil, nil, nil},
-				{"Char", "char", "YES", nil, 10, nil, nil},
-				{"Date", "date", "YES", nil, nil, nil, nil},
-				{"DateTime", "datetime", "YES", nil, nil, nil, nil},
-				{"DateTime2", "datetime2", "YES", nil, nil, nil, nil},
-				{"DateTimeOffset", "datetimeoffset", "YES", nil, nil, nil, nil},
-				{"Decimal", "decimal", "YES", nil, nil, 18, 9},
-				{"Float", "float", "YES", nil, nil, 53, nil},
-				{"Geography", "geography", "YES", nil, -1, nil, nil},
-				{"Geometry", "geometry", "YES", nil, -1, nil, nil},
-				{"HierarchyId", "hierarchyid", "YES", nil, 892, nil, nil},
-				{"Image", "image", "YES", nil, 2147483647, nil, nil},
-				{"Int", "int", "YES", nil, nil, 10, 0},
-				{"Money", "money", "YES", nil, nil, 19, 4},
-				{"NChar", "nchar", "YES", nil, 10, nil, nil},
-				{"NText", "ntext", "YES", nil, 1073741823, nil, nil},
-				{"Numeric", "numeric", "YES", nil, nil, 18, 17},
-				{"NVarChar", "nvarchar", "YES", nil, 50, nil, nil},
-				{"NVarCharMax", "nvarchar", "YES", nil, -1, nil, nil},
-				{"Real", "real", "YES", nil, nil, 24, nil},
-				{"SmallDateTime", "smalldatetime", "YES", nil, nil, nil, nil},
-				{"SmallInt", "smallint", "YES", nil, nil, 5, 0},
-				{"SmallMoney", "smallmoney", "YES", nil, nil, 10, 4},
-				{"SQLVariant", "sql_variant", "YES", nil, 0, nil, nil},
-				{"Text", "text", "YES", nil, 2147483647, nil, nil},
-				{"Time", "time", "YES", nil, nil, nil, nil},
-				{"TimeStamp", "timestamp", "YES", nil, nil, nil, nil},
-				{"TinyInt", "tinyint", "YES", nil, nil, 3, 0},
-				{"UniqueIdentifier", "uniqueidentifier", "YES", nil, nil, nil, nil},
-				{"VarBinary", "varbinary", "YES", nil, 50, nil, nil},
-				{"VarBinaryMax", "varbinary", "YES", nil, -1, nil, nil},
-				{"VarChar", "varchar", "YES", nil, 50, nil, nil},
-				{"VarCharMax", "varchar", "YES", nil, -1, nil, nil},
-				{"Xml", "xml", "YES", nil, -1, nil, nil},
+				{"Id", "int", "NO", nil, nil, 10, 0, nil},
+				{"BigInt", "bigint", "YES", nil, nil, 19, 0, nil},
+				{"Binary", "binary", "YES", nil, 50, nil, nil, nil},
+				{"Bit", "bit", "YES", nil, nil, nil, nil, nil},
+				{"Char", "char", "YES", nil, 10, nil, nil, nil},
+				{"Date", "date", "YES", nil, nil, nil, nil, nil},
+				{"DateTime", "datetime", "YES", nil, nil, nil, nil, nil},
+				{"DateTime2", "datetime2", "YES", nil, nil, nil, nil, nil},
+				{"DateTimeOffset", "datetimeoffset", "YES", nil, nil, nil, nil, nil},
+				{"Decimal", "decimal", "YES", nil, nil, 18, 9, nil},
+				{"Float", "float", "YES", nil, nil, 53, nil, nil},
+				{"Geography", "geography", "YES", nil, -1, nil, nil, nil},
+				{"Geometry", "geometry", "YES", nil, -1, nil, nil, nil},
+				{"HierarchyId", "hierarchyid", "YES", nil, 892, nil, nil, nil},
+				{"Image", "image", "YES", nil, 2147483647, nil, nil, nil},
+				{"Int", "int", "YES", nil, nil, 10, 0, nil},
+				{"Money", "money", "YES", nil, nil, 19, 4, nil},
+				{"NChar", "nchar", "YES", nil, 10, nil, nil, nil},
+				{"NText", "ntext", "YES", nil, 1073741823, nil, nil, nil},
+				{"Numeric", "numeric", "YES", nil, nil, 18, 17, nil},
+				{"NVarChar", "nvarchar", "YES", nil, 50, nil, nil, nil},
+				{"NVarCharMax", "nvarchar", "YES", nil, -1, nil, nil, nil},
+				{"Real", "real", "YES", nil, nil, 24, nil, nil},
+				{"SmallDateTime", "smalldatetime", "YES", nil, nil, nil, nil, nil},
+				{"SmallInt", "smallint", "YES", nil, nil, 5, 0, nil},
+				{"SmallMoney", "smallmoney", "YES", nil, nil, 10, 4, nil},
+				{"SQLVariant", "sql_variant", "YES", nil, 0, nil, nil, nil},
+				{"Text", "text", "YES", nil, 2147483647, nil, nil, nil},
+				{"Time", "time", "YES", nil, nil, nil, nil, nil},
+				{"TimeStamp", "timestamp", "YES", nil, nil, nil, nil, nil},
+				{"TinyInt", "tinyint", "YES", nil, nil, 3, 0, nil},
+				{"UniqueIdentifier", "uniqueidentifier", "YES", nil, nil, nil, nil, nil},
+				{"VarBinary", "varbinary", "YES", nil, 50, nil, nil, nil},
+				{"VarBinaryMax", "varbinary", "YES", nil, -1, nil, nil, nil},
+				{"VarChar", "varchar", "YES", nil, 50, nil, nil, nil},
+				{"VarCharMax", "varchar", "YES", nil, -1, nil, nil, nil},
+				{"Xml", "xml", "YES", nil, -1, nil, nil, nil},
+				{"Computed", "bigint", "YES", nil, nil, 19, 0, "([BigInt]*(2)+abs([Int]))"},
+				{"ComputedConcat", "varchar", "YES", nil, 100, nil, nil, "([VarChar]+'-'+[Char])"},
 			},
 		},
 		// db call to fetch index happens after fetching of column
@@ -167,11 +169,11 @@ func TestProcessSchema(t *testing.T) {
 		{
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"dbo", "cart"},
-			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "definition"},
 			rows: [][]driver.Value{
-				{"productid", "text", "NO", nil, nil, nil, nil},
-				{"userid", "text", "NO", nil, nil, nil, nil},
-				{"quantity", "bigint", "YES", nil, nil, 64, 0}},
+				{"productid", "text", "NO", nil, nil, nil, nil, nil},
+				{"userid", "text", "NO", nil, nil, nil, nil, nil},
+				{"quantity", "bigint", "YES", nil, nil, 64, 0, nil}},
 		},
 		// db call to fetch index happens after fetching of column
 		{
@@ -202,10 +204,10 @@ func TestProcessSchema(t *testing.T) {
 		{
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"production", "product"},
-			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "definition"},
 			rows: [][]driver.Value{
-				{"product_id", "text", "NO", nil, nil, nil, nil},
-				{"product_name", "text", "NO", nil, nil, nil, nil},
+				{"product_id", "text", "NO", nil, nil, nil, nil, nil},
+				{"product_name", "text", "NO", nil, nil, nil, nil, nil},
 			},
 		},
 		// db call to fetch index happens after fetching of column
@@ -232,11 +234,11 @@ func TestProcessSchema(t *testing.T) {
 		{
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"dbo", "test_ref"},
-			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "definition"},
 			rows: [][]driver.Value{
-				{"ref_id", "bigint", "NO", nil, nil, 64, 0},
-				{"ref_txt", "text", "NO", nil, nil, nil, nil},
-				{"abc", "text", "NO", nil, nil, nil, nil},
+				{"ref_id", "bigint", "NO", nil, nil, 64, 0, nil},
+				{"ref_txt", "text", "NO", nil, nil, nil, nil, nil},
+				{"abc", "text", "NO", nil, nil, nil, nil, nil},
 			},
 		},
 		// db call to fetch index happens after fetching of column
@@ -267,45 +269,48 @@ func TestProcessSchema(t *testing.T) {
 				"DateTime2", "DateTimeOffset", "Decimal", "Float", "Geography", "Geometry", "HierarchyId",
 				"Image", "Int", "Money", "NChar", "NText", "Numeric", "NVarChar", "NVarCharMax", "Real", "SmallDateTime",
 				"SmallInt", "SmallMoney", "SQLVariant", "Text", "Time", "TimeStamp",
-				"TinyInt", "UniqueIdentifier", "VarBinary", "VarBinaryMax", "VarChar", "VarCharMax", "Xml"},
+				"TinyInt", "UniqueIdentifier", "VarBinary", "VarBinaryMax", "VarChar", "VarCharMax", "Xml", "Computed", "ComputedConcat"},
 			ColDefs: map[string]ddl.ColumnDef{
-				"Id":               {Name: "Id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
-				"BigInt":           {Name: "BigInt", T: ddl.Type{Name: ddl.Int64}, NotNull: false},
-				"Binary":           {Name: "Binary", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, NotNull: false},
-				"Bit":              {Name: "Bit", T: ddl.Type{Name: ddl.Bool}, NotNull: false},
-				"Char":             {Name: "Char", T: ddl.Type{Name: ddl.String, Len: 10, IsArray: false}, NotNull: false},
-				"Date":             {Name: "Date", T: ddl.Type{Name: ddl.Date}, NotNull: false},
-				"DateTime":         {Name: "DateTime", T: ddl.Type{Name: ddl.Timestamp}, NotNull: false},
-				"DateTime2":        {Name: "DateTime2", T: ddl.Type{Name: ddl.Timestamp}, NotNull: false},
-				"DateTimeOffset":   {Name: "DateTimeOffset", T: ddl.Type{Name: ddl.Timestamp}, NotNull: false},
-				"Decimal":          {Name: "Decimal", T: ddl.Type{Name: ddl.Numeric}, NotNull: false},
-				"Float":            {Name: "Float", T: ddl.Type{Name: ddl.Float64}, NotNull: false},
-				"Geography":        {Name: "Geography", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
-				"Geometry":         {Name: "Geometry", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
-				"HierarchyId":      {Name: "HierarchyId", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
-				"Image":            {Name: "Image", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, NotNull: false},
-				"Int":              {Name: "Int", T: ddl.Type{Name: ddl.Int64}, NotNull: false},
-				"Money":            {Name: "Money", T: ddl.Type{Name: ddl.Numeric}, NotNull: false},
-				"NChar":            {Name: "NChar", T: ddl.Type{Name: ddl.String, Len: 10}, NotNull: false},
-				"NText":            {Name: "NText", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
-				"Numeric":          {Name: "Numeric", T: ddl.Type{Name: ddl.Numeric}, NotNull: false},
-				"NVarChar":         {Name: "NVarChar", T: ddl.Type{Name: ddl.String, Len: 50}, NotNull: false},
-				"NVarCharMax":      {Name: "NVarCharMax", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
-				"Real":             {Name: "Real", T: ddl.Type{Name: ddl.Float64}, NotNull: false},
-				"SmallDateTime":    {Name: "SmallDateTime", T: ddl.Type{Name: ddl.Timestamp}, NotNull: false},
-				"SmallInt":         {Name: "SmallInt", T: ddl.Type{Name: ddl.Int64}, NotNull: false},
-				"SmallMoney":       {Name: "SmallMoney", T: ddl.Type{Name: ddl.Numeric}, NotNull: false},
-				"SQLVariant":       {Name: "SQLVariant", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
-				"Text":             {Name: "Text", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
-				"Time":             {Name: "Time", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
-				"TimeStamp":        {Name: "TimeStamp", T: ddl.Type{Name: ddl.Int64}, NotNull: false},
-				"TinyInt":          {Name: "TinyInt", T: ddl.Type{Name: ddl.Int64}, NotNull: false},
-				"UniqueIdentifier": {Name: "UniqueIdentifier", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
-				"VarBinary":        {Name: "VarBinary", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, NotNull: false},
-				"VarBinaryMax":     {Name: "VarBinaryMax", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, NotNull: false},
-				"VarChar":          {Name: "VarChar", T: ddl.Type{Name: ddl.String, Len: 50}, NotNull: false},
-				"VarCharMax":       {Name: "VarCharMax", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
-				"Xml":              {Name: "Xml", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
+				"Id":                    {Name: "Id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+				"BigInt":                {Name: "BigInt", T: ddl.Type{Name: ddl.Int64}, NotNull: false},
+				"Binary":                {Name: "Binary", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, NotNull: false},
+				"Bit":                   {Name: "Bit", T: ddl.Type{Name: ddl.Bool}, NotNull: false},
+				"Char":                  {Name: "Char", T: ddl.Type{Name: ddl.String, Len: 10, IsArray: false}, NotNull: false},
+				"Date":                  {Name: "Date", T: ddl.Type{Name: ddl.Date}, NotNull: false},
+				"DateTime":              {Name: "DateTime", T: ddl.Type{Name: ddl.Timestamp}, NotNull: false},
+				"DateTime2":             {Name: "DateTime2", T: ddl.Type{Name: ddl.Timestamp}, NotNull: false},
+				"DateTimeOffset":        {Name: "DateTimeOffset", T: ddl.Type{Name: ddl.Timestamp}, NotNull: false, TimezoneOffsetColId: "DateTimeOffset_offset"},
+				"DateTimeOffset_offset": {Name: "DateTimeOffset_offset", T: ddl.Type{Name: ddl.String, Len: 6}},
+				"Decimal":               {Name: "Decimal", T: ddl.Type{Name: ddl.Numeric}, NotNull: false},
+				"Float":                 {Name: "Float", T: ddl.Type{Name: ddl.Float64}, NotNull: false},
+				"Geography":             {Name: "Geography", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
+				"Geometry":              {Name: "Geometry", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
+				"HierarchyId":           {Name: "HierarchyId", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
+				"Image":                 {Name: "Image", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, NotNull: false},
+				"Int":                   {Name: "Int", T: ddl.Type{Name: ddl.Int64}, NotNull: false},
+				"Money":                 {Name: "Money", T: ddl.Type{Name: ddl.Numeric}, NotNull: false},
+				"NChar":                 {Name: "NChar", T: ddl.Type{Name: ddl.String, Len: 10}, NotNull: false},
+				"NText":                 {Name: "NText", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
+				"Numeric":               {Name: "Numeric", T: ddl.Type{Name: ddl.Numeric}, NotNull: false},
+				"NVarChar":              {Name: "NVarChar", T: ddl.Type{Name: ddl.String, Len: 50}, NotNull: false},
+				"NVarCharMax":           {Name: "NVarCharMax", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
+				"Real":                  {Name: "Real", T: ddl.Type{Name: ddl.Float64}, NotNull: false},
+				"SmallDateTime":         {Name: "SmallDateTime", T: ddl.Type{Name: ddl.Timestamp}, NotNull: false},
+				"SmallInt":              {Name: "SmallInt", T: ddl.Type{Name: ddl.Int64}, NotNull: false},
+				"SmallMoney":            {Name: "SmallMoney", T: ddl.Type{Name: ddl.Numeric}, NotNull: false},
+				"SQLVariant":            {Name: "SQLVariant", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
+				"Text":                  {Name: "Text", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
+				"Time":                  {Name: "Time", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
+				"TimeStamp":             {Name: "TimeStamp", T: ddl.Type{Name: ddl.Timestamp}, NotNull: false, AllowCommitTimestamp: true},
+				"TinyInt":               {Name: "TinyInt", T: ddl.Type{Name: ddl.Int64}, NotNull: false},
+				"UniqueIdentifier":      {Name: "UniqueIdentifier", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
+				"VarBinary":             {Name: "VarBinary", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, NotNull: false},
+				"VarBinaryMax":          {Name: "VarBinaryMax", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, NotNull: false},
+				"VarChar":               {Name: "VarChar", T: ddl.Type{Name: ddl.String, Len: 50}, NotNull: false},
+				"VarCharMax":            {Name: "VarCharMax", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
+				"Xml":                   {Name: "Xml", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
+				"Computed":              {Name: "Computed", T: ddl.Type{Name: ddl.Int64}, GeneratedExpr: "(BigInt*(2)+ABS(Int))"},
+				"ComputedConcat":        {Name: "ComputedConcat", T: ddl.Type{Name: ddl.String, Len: 100}},
 			},
 			PrimaryKeys: []ddl.IndexKey{{ColId: "Id", Order: 1}},
 			ForeignKeys: []ddl.Foreignkey{{Name: "fk_test4", ColIds: []string{"Id"}, ReferTableId: "test_ref", ReferColumnIds: []string{"ref_id"}}},
@@ -349,7 +354,7 @@ func TestProcessSchema(t *testing.T) {
 	testTableId, err := internal.GetTableIdFromSpName(conv.SpSchema, "test")
 	assert.Equal(t, nil, err)
 	assert.Equal(t, len(conv.SchemaIssues[cartTableId].ColumnLevelIssues), 0)
-	assert.Equal(t, len(conv.SchemaIssues[testTableId].ColumnLevelIssues), 17)
+	assert.Equal(t, len(conv.SchemaIssues[testTableId].ColumnLevelIssues), 20)
 	assert.Equal(t, int64(0), conv.Unexpecteds())
 
 }
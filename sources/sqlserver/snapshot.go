@@ -0,0 +1,35 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlserver
+
+import (
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/schema"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/snapshot"
+)
+
+// NewSnapshotInfoSchema returns an InfoSchema converting the schema exported
+// in an information_schema snapshot of a SQL Server database, building
+// column types and table names the same way as InfoSchemaImpl does.
+func NewSnapshotInfoSchema(s *snapshot.Snapshot) snapshot.InfoSchemaImpl {
+	isi := InfoSchemaImpl{}
+	return snapshot.InfoSchemaImpl{Snapshot: s, Dialect: snapshot.Dialect{
+		ToDdl: ToDdlImpl{},
+		ToType: func(c snapshot.Column) schema.Type {
+			return toType(c.DataType, c.CharMaxLen.NullInt64, c.NumericPrecision.NullInt64, c.NumericScale.NullInt64)
+		},
+		GetTableName:  isi.GetTableName,
+		GeneratedExpr: toGeneratedExpr,
+	}}
+}
@@ -16,6 +16,9 @@
 package sqlserver
 
 import (
+	"regexp"
+	"strings"
+
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/schema"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/ddl"
@@ -143,22 +146,119 @@ func toSpannerTypeInternal(srcType schema.Type, spType string) (ddl.Type, []inte
 		default:
 			return ddl.Type{Name: ddl.Date}, nil
 		}
-	case "datetime2", "datetime", "datetimeoffset", "smalldatetime", "rowversion":
+	case "datetime2", "datetime", "smalldatetime":
 		switch spType {
 		case ddl.String:
 			return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.Widened}
 		default:
 			return ddl.Type{Name: ddl.Timestamp}, []internal.SchemaIssue{internal.Timestamp}
 		}
-	case "timestamp":
+	case "datetimeoffset":
 		switch spType {
 		case ddl.String:
 			return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.Widened}
 		default:
+			// Spanner normalizes timestamps to UTC, so the source offset
+			// is kept in a separate column.
+			return ddl.Type{Name: ddl.Timestamp}, []internal.SchemaIssue{internal.TimestampOffset}
+		}
+	// Information schema reports rowversion columns as timestamp.
+	case "timestamp", "rowversion":
+		switch spType {
+		case ddl.String:
+			return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.Widened}
+		case ddl.Int64:
 			return ddl.Type{Name: ddl.Int64}, nil
+		default:
+			// The source updates rowversion on every write, which is
+			// closest to a Spanner commit timestamp.
+			return ddl.Type{Name: ddl.Timestamp}, []internal.SchemaIssue{internal.CommitTimestamp}
 		}
 	case "time":
 		return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.Time}
 	}
 	return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.NoGoodType}
 }
+
+// generatedExprFuncs maps the SQL Server functions allowed in computed
+// column expressions to their Spanner equivalents. The equivalents have the
+// same name and semantics in both GoogleSQL and PostgreSQL dialects.
+var generatedExprFuncs = map[string]string{
+	"ABS":       "ABS",
+	"CEILING":   "CEIL",
+	"COALESCE":  "COALESCE",
+	"CONCAT":    "CONCAT",
+	"FLOOR":     "FLOOR",
+	"ISNULL":    "COALESCE",
+	"LEN":       "LENGTH",
+	"LOWER":     "LOWER",
+	"LTRIM":     "LTRIM",
+	"REPLACE":   "REPLACE",
+	"ROUND":     "ROUND",
+	"RTRIM":     "RTRIM",
+	"SUBSTRING": "SUBSTR",
+	"UPPER":     "UPPER",
+}
+
+var generatedExprToken = regexp.MustCompile(`^(\s+|\[[^\]]*\]|'(?:[^']|'')*'|[0-9]+(?:\.[0-9]+)?|[A-Za-z_][A-Za-z0-9_]*|[-+*/(),])`)
+
+var plainIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// toGeneratedExpr translates the definition of a SQL Server computed column
+// into a Spanner generated column expression. colTypes maps the names of the
+// columns of the table to their source types. Only column references,
+// literals, arithmetic and the functions in generatedExprFuncs are
+// supported; ok is false for anything else. Since SQL Server overloads '+'
+// for string concatenation, expressions using '+' together with character
+// data are not translated either.
+func toGeneratedExpr(definition string, colTypes map[string]string) (expr string, ok bool) {
+	var sb strings.Builder
+	hasPlus, hasString := false, false
+	for rest := definition; rest != ""; {
+		tok := generatedExprToken.FindString(rest)
+		if tok == "" {
+			return "", false
+		}
+		rest = rest[len(tok):]
+		switch {
+		case strings.TrimSpace(tok) == "":
+			sb.WriteString(" ")
+		case tok[0] == '[':
+			name := tok[1 : len(tok)-1]
+			ty, found := colTypes[name]
+			if !found || !plainIdentifier.MatchString(name) {
+				return "", false
+			}
+			if isCharacterType(ty) {
+				hasString = true
+			}
+			sb.WriteString(name)
+		case tok[0] == '\'':
+			hasString = true
+			sb.WriteString(tok)
+		case plainIdentifier.MatchString(tok):
+			f, found := generatedExprFuncs[strings.ToUpper(tok)]
+			if !found || !strings.HasPrefix(strings.TrimSpace(rest), "(") {
+				return "", false
+			}
+			sb.WriteString(f)
+		default:
+			if tok == "+" {
+				hasPlus = true
+			}
+			sb.WriteString(tok)
+		}
+	}
+	if hasPlus && hasString {
+		return "", false
+	}
+	return strings.TrimSpace(sb.String()), true
+}
+
+func isCharacterType(ty string) bool {
+	switch ty {
+	case "varchar", "char", "nvarchar", "nchar", "ntext", "text", "xml", "uniqueidentifier":
+		return true
+	}
+	return false
+}
@@ -26,83 +26,83 @@ import (
 )
 
 func TestToSpannerTypeInternal(t *testing.T) {
-	_, errCheck := toSpannerTypeInternal(schema.Type{"bigint", []int64{1, 2, 3}, []int64{1, 2, 3}}, "STRING")
+	_, errCheck := toSpannerTypeInternal(schema.Type{Name: "bigint", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "STRING")
 	if errCheck == nil {
 		t.Errorf("Error in bigint of sptype string")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"bigint", []int64{1, 2, 3}, []int64{1, 2, 3}}, "INT64")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "bigint", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "INT64")
 	if errCheck == nil {
 		t.Errorf("Error in bigint of sptype int64")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"tinyint", []int64{1, 2, 3}, []int64{1, 2, 3}}, "STRING")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "tinyint", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "STRING")
 	if errCheck == nil {
 		t.Errorf("Error in tinyint of sptype string")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"tinyint", []int64{1, 2, 3}, []int64{1, 2, 3}}, "INT64")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "tinyint", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "INT64")
 	if errCheck == nil {
 		t.Errorf("Error in tinyint of sptype int64")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"float", []int64{1, 2, 3}, []int64{1, 2, 3}}, "STRING")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "float", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "STRING")
 	if errCheck == nil {
 		t.Errorf("Error in float of sptype string")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"numeric", []int64{1, 2, 3}, []int64{1, 2, 3}}, "STRING")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "numeric", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "STRING")
 	if errCheck == nil {
 		t.Errorf("Error in numeric of sptype string")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"bit", []int64{1, 2, 3}, []int64{1, 2, 3}}, "STRING")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "bit", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "STRING")
 	if errCheck != nil {
 		t.Errorf("Error in bit of sptype string")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"uniqueidentifier", []int64{}, []int64{1, 2, 3}}, "BYTES")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "uniqueidentifier", Mods: []int64{}, ArrayBounds: []int64{1, 2, 3}}, "BYTES")
 	if errCheck != nil {
 		t.Errorf("Error in uniqueidentifier of sptype bytes")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"uniqueidentifier", []int64{1}, []int64{1, 2, 3}}, "BYTES")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "uniqueidentifier", Mods: []int64{1}, ArrayBounds: []int64{1, 2, 3}}, "BYTES")
 	if errCheck != nil {
 		t.Errorf("Error in uniqueidentifier of sptype bytes")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"uniqueidentifier", []int64{1, 2, 3}, []int64{1, 2, 3}}, "STRING")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "uniqueidentifier", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "STRING")
 	if errCheck != nil {
 		t.Errorf("Error in uniqueidentifier of sptype string")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"varchar", []int64{1, 2, 3}, []int64{1, 2, 3}}, "BYTES")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "varchar", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "BYTES")
 	if errCheck != nil {
 		t.Errorf("Error in varchar of sptype bytes")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"varchar", []int64{}, []int64{1, 2, 3}}, "BYTES")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "varchar", Mods: []int64{}, ArrayBounds: []int64{1, 2, 3}}, "BYTES")
 	if errCheck != nil {
 		t.Errorf("Error in varchar of sptype bytes")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"varchar", []int64{}, []int64{1, 2, 3}}, "")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "varchar", Mods: []int64{}, ArrayBounds: []int64{1, 2, 3}}, "")
 	if errCheck != nil {
 		t.Errorf("Error in varchar of default sptype")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"ntext", []int64{}, []int64{1, 2, 3}}, "BYTES")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "ntext", Mods: []int64{}, ArrayBounds: []int64{1, 2, 3}}, "BYTES")
 	if errCheck != nil {
 		t.Errorf("Error in ntext of sptype bytes")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"binary", []int64{1, 2, 3}, []int64{1, 2, 3}}, "STRING")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "binary", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "STRING")
 	if errCheck != nil {
 		t.Errorf("Error in binary of sptype string")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"date", []int64{1, 2, 3}, []int64{1, 2, 3}}, "STRING")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "date", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "STRING")
 	if errCheck == nil {
 		t.Errorf("Error in date of sptype string")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"datetime", []int64{1, 2, 3}, []int64{1, 2, 3}}, "STRING")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "datetime", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "STRING")
 	if errCheck == nil {
 		t.Errorf("Error in datetime of sptype string")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"timestamp", []int64{1, 2, 3}, []int64{1, 2, 3}}, "STRING")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "timestamp", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "STRING")
 	if errCheck == nil {
 		t.Errorf("Error in timestamp of sptype string")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"time", []int64{1, 2, 3}, []int64{1, 2, 3}}, "STRING")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "time", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "STRING")
 	if errCheck == nil {
 		t.Errorf("Error in time of sptype string")
 	}
-	_, errCheck = toSpannerTypeInternal(schema.Type{"DEFAULT", []int64{1, 2, 3}, []int64{1, 2, 3}}, "")
+	_, errCheck = toSpannerTypeInternal(schema.Type{Name: "DEFAULT", Mods: []int64{1, 2, 3}, ArrayBounds: []int64{1, 2, 3}}, "")
 	if errCheck == nil {
 		t.Errorf("Error in default case")
 	}
@@ -177,7 +177,7 @@ func TestToSpannerType(t *testing.T) {
 			"c3":  {Name: "c", Id: "c3", T: ddl.Type{Name: ddl.Int64}},
 			"c4":  {Name: "d", Id: "c4", T: ddl.Type{Name: ddl.String, Len: int64(6)}},
 			"c5":  {Name: "e", Id: "c5", T: ddl.Type{Name: ddl.Numeric}},
-			"c6":  {Name: "f", Id: "c6", T: ddl.Type{Name: ddl.Int64}},
+			"c6":  {Name: "f", Id: "c6", T: ddl.Type{Name: ddl.Timestamp}, AllowCommitTimestamp: true},
 			"c7":  {Name: "g", Id: "c7", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}},
 			"c8":  {Name: "h", Id: "c8", T: ddl.Type{Name: ddl.Date}},
 			"c9":  {Name: "i", Id: "c9", T: ddl.Type{Name: ddl.Numeric}},
@@ -199,6 +199,7 @@ func TestToSpannerType(t *testing.T) {
 		"c1":  {internal.Widened},
 		"c2":  {internal.Widened},
 		"c3":  {internal.Widened},
+		"c6":  {internal.CommitTimestamp},
 		"c10": {internal.Timestamp},
 		"c13": {internal.NoGoodType},
 	}
@@ -275,7 +276,7 @@ func TestToSpannerPostgreSQLDialectType(t *testing.T) {
 			"c3":  {Name: "c", Id: "c3", T: ddl.Type{Name: ddl.Int64}},
 			"c4":  {Name: "d", Id: "c4", T: ddl.Type{Name: ddl.String, Len: int64(6)}},
 			"c5":  {Name: "e", Id: "c5", T: ddl.Type{Name: ddl.Numeric}},
-			"c6":  {Name: "f", Id: "c6", T: ddl.Type{Name: ddl.Int64}},
+			"c6":  {Name: "f", Id: "c6", T: ddl.Type{Name: ddl.Timestamp}, AllowCommitTimestamp: true},
 			"c7":  {Name: "g", Id: "c7", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}},
 			"c8":  {Name: "h", Id: "c8", T: ddl.Type{Name: ddl.Date}},
 			"c9":  {Name: "i", Id: "c9", T: ddl.Type{Name: ddl.Numeric}},
@@ -297,6 +298,7 @@ func TestToSpannerPostgreSQLDialectType(t *testing.T) {
 		"c1":  {internal.Widened},
 		"c2":  {internal.Widened},
 		"c3":  {internal.Widened},
+		"c6":  {internal.CommitTimestamp},
 		"c10": {internal.Timestamp},
 		"c13": {internal.NoGoodType},
 	}
@@ -311,3 +313,30 @@ func dropComments(t *ddl.CreateTable) {
 		t.ColDefs[c] = cd
 	}
 }
+
+func TestToGeneratedExpr(t *testing.T) {
+	colTypes := map[string]string{"price": "decimal", "qty": "int", "first": "varchar", "last": "nvarchar", "bad name": "int"}
+	tests := []struct {
+		definition string
+		expr       string
+		ok         bool
+	}{
+		{"([price]*[qty])", "(price*qty)", true},
+		{"(([qty]+(1))*(2.5))", "((qty+(1))*(2.5))", true},
+		{"(upper([first]))", "(UPPER(first))", true},
+		{"(isnull([qty],(0)))", "(COALESCE(qty,(0)))", true},
+		{"(concat([first],' ',[last]))", "(CONCAT(first,' ',last))", true},
+		{"(len([last]))", "(LENGTH(last))", true},
+		{"(([first]+' ')+[last])", "", false},
+		{"(getdate())", "", false},
+		{"([qty]%(2))", "", false},
+		{"([missing]*(2))", "", false},
+		{"([bad name]*(2))", "", false},
+		{"(case when [qty]>(0) then (1) else (0) end)", "", false},
+	}
+	for _, tc := range tests {
+		expr, ok := toGeneratedExpr(tc.definition, colTypes)
+		assert.Equal(t, tc.ok, ok, tc.definition)
+		assert.Equal(t, tc.expr, expr, tc.definition)
+	}
+}
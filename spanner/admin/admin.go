@@ -0,0 +1,57 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package admin makes the calls to the Spanner admin APIs of Spanner
+// migration tool through the retries of package gcp, rate limited by a
+// limiter shared by all the migrations running in the process, so that
+// creating many migrations at once doesn't exhaust the admin quotas of the
+// project. It also provides the admin lookups shared by the commands, such as
+// the dialect of a database and the cached instance information.
+package admin
+
+import (
+	"context"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+)
+
+const (
+	callsPerSecond = 5
+	burst          = 10
+)
+
+// Limiter shared by the admin calls of the process.
+var limiter = gcp.NewLimiter(callsPerSecond, burst)
+
+// Do makes the admin call c with f through the limiter shared by the process,
+// with the retries of gcp.Do. The calls which are not Idempotent, such as
+// CreateDatabase or UpdateDatabaseDdl, are only retried when rejected before
+// being applied, e.g. by a quota or a concurrent schema change.
+func Do(ctx context.Context, c gcp.Call, f func(ctx context.Context) error) error {
+	c.Service = gcp.SPANNER
+	c.Limiter = limiter
+	return gcp.Do(ctx, c, f)
+}
+
+// DoWithResult is Do for calls returning a result, such as the long running
+// operations started by the call.
+func DoWithResult[T any](ctx context.Context, c gcp.Call, f func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := Do(ctx, c, func(ctx context.Context) error {
+		var err error
+		result, err = f(ctx)
+		return err
+	})
+	return result, err
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/stretchr/testify/assert"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDoRetriesRejectedSchemaChanges(t *testing.T) {
+	concurrentErr := status.Error(codes.FailedPrecondition, "Schema change operation rejected because a concurrent schema change operation or read-write transaction is already in progress.")
+	attempts := 0
+	err := Do(context.Background(), gcp.Call{Method: "UpdateDatabaseDdl", Resource: "db"}, func(ctx context.Context) error {
+		attempts++
+		if attempts == 1 {
+			return concurrentErr
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestDoDoesNotRetryAppliedCalls(t *testing.T) {
+	// An UNAVAILABLE CreateDatabase may have created the database.
+	attempts := 0
+	_, err := DoWithResult(context.Background(), gcp.Call{Method: "CreateDatabase", Resource: "db"}, func(ctx context.Context) (string, error) {
+		attempts++
+		return "", status.Error(codes.Unavailable, "unavailable")
+	})
+	assert.Equal(t, 1, attempts)
+	var callErr *gcp.Error
+	assert.True(t, errors.As(err, &callErr))
+	assert.Equal(t, gcp.SPANNER, callErr.Call.Service)
+	assert.Equal(t, gcp.CATEGORY_UNAVAILABLE, callErr.Category)
+}
+
+func TestDoWithResult(t *testing.T) {
+	result, err := DoWithResult(context.Background(), gcp.Call{Method: "GetDatabase", Idempotent: true}, func(ctx context.Context) (string, error) {
+		return "done", nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "done", result)
+
+	_, err = DoWithResult(context.Background(), gcp.Call{Method: "GetDatabase", Resource: "db", Idempotent: true}, func(ctx context.Context) (string, error) {
+		return "", fmt.Errorf("failed")
+	})
+	assert.Equal(t, "spanner GetDatabase of db failed (UNKNOWN): failed", err.Error())
+}
+
+func TestToDialect(t *testing.T) {
+	assert.Equal(t, constants.DIALECT_POSTGRESQL, toDialect(adminpb.DatabaseDialect_POSTGRESQL))
+	assert.Equal(t, constants.DIALECT_GOOGLESQL, toDialect(adminpb.DatabaseDialect_GOOGLE_STANDARD_SQL))
+	assert.Equal(t, constants.DIALECT_GOOGLESQL, toDialect(adminpb.DatabaseDialect_DATABASE_DIALECT_UNSPECIFIED))
+}
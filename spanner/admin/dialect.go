@@ -0,0 +1,47 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"fmt"
+
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+// GetDatabaseDialect returns the dialect of the database dbURI, either
+// constants.DIALECT_GOOGLESQL or constants.DIALECT_POSTGRESQL.
+func GetDatabaseDialect(ctx context.Context, adminClient *database.DatabaseAdminClient, dbURI string) (string, error) {
+	db, err := DoWithResult(ctx, gcp.Call{Method: "GetDatabase", Resource: dbURI, Idempotent: true}, func(ctx context.Context) (*adminpb.Database, error) {
+		return adminClient.GetDatabase(ctx, &adminpb.GetDatabaseRequest{Name: dbURI})
+	})
+	if err != nil {
+		return "", fmt.Errorf("can't get dialect of database %s: %w", dbURI, err)
+	}
+	return toDialect(db.DatabaseDialect), nil
+}
+
+// toDialect maps the dialect of a database to the dialect constants used
+// across Spanner migration tool. Databases with an unspecified dialect use
+// GoogleSQL.
+func toDialect(d adminpb.DatabaseDialect) string {
+	if d == adminpb.DatabaseDialect_POSTGRESQL {
+		return constants.DIALECT_POSTGRESQL
+	}
+	return constants.DIALECT_GOOGLESQL
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/googleapis/gax-go/v2"
+	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
+)
+
+// Time the instance information is cached for by default. The configuration
+// of an instance rarely changes, while its compute capacity may be changed by
+// the migrations themselves, which invalidate the cached information.
+const DEFAULT_INSTANCE_INFO_TTL = 10 * time.Minute
+
+// InstanceAdmin is the part of the instance admin API read by
+// InstanceInfoCache, implemented by the instance admin client.
+type InstanceAdmin interface {
+	GetInstance(ctx context.Context, req *instancepb.GetInstanceRequest, opts ...gax.CallOption) (*instancepb.Instance, error)
+	GetInstanceConfig(ctx context.Context, req *instancepb.GetInstanceConfigRequest, opts ...gax.CallOption) (*instancepb.InstanceConfig, error)
+}
+
+// InstanceInfo describes a Spanner instance.
+type InstanceInfo struct {
+	InstanceURI     string
+	NodeCount       int32
+	ProcessingUnits int32
+	// Config is the name of the instance configuration, e.g.
+	// projects/p/instanceConfigs/regional-us-east1.
+	Config string
+	// LeaderLocation is the default leader region of the configuration, or
+	// empty if it has none.
+	LeaderLocation string
+}
+
+type cachedInstanceInfo struct {
+	info    InstanceInfo
+	expires time.Time
+}
+
+// InstanceInfoCache looks up instances and the default leader of their
+// configuration, and caches the results by instance URI until they expire.
+// It is safe for concurrent use.
+type InstanceInfoCache struct {
+	admin InstanceAdmin
+	ttl   time.Duration
+	mu    sync.Mutex
+	infos map[string]cachedInstanceInfo
+	// Leader locations by instance configuration, which are shared by many
+	// instances and expire as the instances do.
+	leaders map[string]cachedInstanceInfo
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// NewInstanceInfoCache returns a cache looking up instances through admin,
+// whose entries expire after ttl.
+func NewInstanceInfoCache(admin InstanceAdmin, ttl time.Duration) *InstanceInfoCache {
+	return &InstanceInfoCache{
+		admin:   admin,
+		ttl:     ttl,
+		infos:   make(map[string]cachedInstanceInfo),
+		leaders: make(map[string]cachedInstanceInfo),
+		now:     time.Now,
+	}
+}
+
+// GetInstanceInfo returns the information of the instance instanceURI, of the
+// form projects/<project>/instances/<instance>.
+func (c *InstanceInfoCache) GetInstanceInfo(ctx context.Context, instanceURI string) (InstanceInfo, error) {
+	c.mu.Lock()
+	cached, ok := c.infos[instanceURI]
+	c.mu.Unlock()
+	if ok && c.now().Before(cached.expires) {
+		return cached.info, nil
+	}
+	inst, err := DoWithResult(ctx, gcp.Call{Method: "GetInstance", Resource: instanceURI, Idempotent: true}, func(ctx context.Context) (*instancepb.Instance, error) {
+		return c.admin.GetInstance(ctx, &instancepb.GetInstanceRequest{Name: instanceURI})
+	})
+	if err != nil {
+		return InstanceInfo{}, fmt.Errorf("can't get instance %s: %w", instanceURI, err)
+	}
+	leader, err := c.getLeaderLocation(ctx, inst.Config)
+	if err != nil {
+		return InstanceInfo{}, err
+	}
+	info := InstanceInfo{
+		InstanceURI:     instanceURI,
+		NodeCount:       inst.NodeCount,
+		ProcessingUnits: inst.ProcessingUnits,
+		Config:          inst.Config,
+		LeaderLocation:  leader,
+	}
+	c.mu.Lock()
+	c.infos[instanceURI] = cachedInstanceInfo{info: info, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return info, nil
+}
+
+// GetLeaderLocation returns the default leader region of the instance
+// instanceURI.
+func (c *InstanceInfoCache) GetLeaderLocation(ctx context.Context, instanceURI string) (string, error) {
+	info, err := c.GetInstanceInfo(ctx, instanceURI)
+	if err != nil {
+		return "", err
+	}
+	return info.LeaderLocation, nil
+}
+
+// Invalidate drops the cached information of the instance instanceURI, e.g.
+// after its compute capacity was changed.
+func (c *InstanceInfoCache) Invalidate(instanceURI string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.infos, instanceURI)
+}
+
+// getLeaderLocation returns the default leader region of the instance
+// configuration config.
+func (c *InstanceInfoCache) getLeaderLocation(ctx context.Context, config string) (string, error) {
+	c.mu.Lock()
+	cached, ok := c.leaders[config]
+	c.mu.Unlock()
+	if ok && c.now().Before(cached.expires) {
+		return cached.info.LeaderLocation, nil
+	}
+	instanceConfig, err := DoWithResult(ctx, gcp.Call{Method: "GetInstanceConfig", Resource: config, Idempotent: true}, func(ctx context.Context) (*instancepb.InstanceConfig, error) {
+		return c.admin.GetInstanceConfig(ctx, &instancepb.GetInstanceConfigRequest{Name: config})
+	})
+	if err != nil {
+		return "", fmt.Errorf("can't get instance config %s: %w", config, err)
+	}
+	leader := ""
+	for _, replica := range instanceConfig.Replicas {
+		if replica.DefaultLeaderLocation {
+			leader = replica.Location
+		}
+	}
+	c.mu.Lock()
+	c.leaders[config] = cachedInstanceInfo{info: InstanceInfo{Config: config, LeaderLocation: leader}, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return leader, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/assert"
+	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
+)
+
+type fakeInstanceAdmin struct {
+	instances     map[string]*instancepb.Instance
+	configs       map[string]*instancepb.InstanceConfig
+	instanceCalls int
+	configCalls   int
+}
+
+func (f *fakeInstanceAdmin) GetInstance(ctx context.Context, req *instancepb.GetInstanceRequest, opts ...gax.CallOption) (*instancepb.Instance, error) {
+	f.instanceCalls++
+	if inst, ok := f.instances[req.Name]; ok {
+		return inst, nil
+	}
+	return nil, fmt.Errorf("instance %s not found", req.Name)
+}
+
+func (f *fakeInstanceAdmin) GetInstanceConfig(ctx context.Context, req *instancepb.GetInstanceConfigRequest, opts ...gax.CallOption) (*instancepb.InstanceConfig, error) {
+	f.configCalls++
+	if config, ok := f.configs[req.Name]; ok {
+		return config, nil
+	}
+	return nil, fmt.Errorf("instance config %s not found", req.Name)
+}
+
+func TestInstanceInfoCache(t *testing.T) {
+	ctx := context.Background()
+	config := "projects/p/instanceConfigs/nam3"
+	admin := &fakeInstanceAdmin{
+		instances: map[string]*instancepb.Instance{
+			"projects/p/instances/i1": {Config: config, NodeCount: 1, ProcessingUnits: 1000},
+			"projects/p/instances/i2": {Config: config, ProcessingUnits: 300},
+		},
+		configs: map[string]*instancepb.InstanceConfig{
+			config: {Replicas: []*instancepb.ReplicaInfo{
+				{Location: "us-east4"},
+				{Location: "us-east1", DefaultLeaderLocation: true},
+			}},
+		},
+	}
+	now := time.Now()
+	cache := NewInstanceInfoCache(admin, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	info, err := cache.GetInstanceInfo(ctx, "projects/p/instances/i1")
+	assert.Nil(t, err)
+	assert.Equal(t, InstanceInfo{InstanceURI: "projects/p/instances/i1", NodeCount: 1, ProcessingUnits: 1000, Config: config, LeaderLocation: "us-east1"}, info)
+
+	// Cached lookups, with the configuration shared by both instances.
+	leader, err := cache.GetLeaderLocation(ctx, "projects/p/instances/i1")
+	assert.Nil(t, err)
+	assert.Equal(t, "us-east1", leader)
+	info, err = cache.GetInstanceInfo(ctx, "projects/p/instances/i2")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(300), info.ProcessingUnits)
+	assert.Equal(t, "us-east1", info.LeaderLocation)
+	assert.Equal(t, 2, admin.instanceCalls)
+	assert.Equal(t, 1, admin.configCalls)
+
+	// Invalidated entries are looked up again.
+	admin.instances["projects/p/instances/i1"].NodeCount = 2
+	cache.Invalidate("projects/p/instances/i1")
+	info, err = cache.GetInstanceInfo(ctx, "projects/p/instances/i1")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), info.NodeCount)
+	assert.Equal(t, 3, admin.instanceCalls)
+	assert.Equal(t, 1, admin.configCalls)
+
+	// So are expired ones.
+	now = now.Add(2 * time.Minute)
+	_, err = cache.GetInstanceInfo(ctx, "projects/p/instances/i2")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, admin.instanceCalls)
+	assert.Equal(t, 2, admin.configCalls)
+
+	_, err = cache.GetInstanceInfo(ctx, "projects/p/instances/missing")
+	assert.NotNil(t, err)
+}
@@ -172,13 +172,28 @@ func (ty Type) PGPrintColumnDefType() string {
 // ColumnDef encodes the following DDL definition:
 //
 //	column_def:
-//	  column_name type [NOT NULL] [options_def]
+//	  column_name type [NOT NULL] [AS ( expression ) STORED] [options_def]
 type ColumnDef struct {
 	Name    string
 	T       Type
 	NotNull bool
 	Comment string
 	Id      string
+	// GeneratedExpr is the expression of a stored generated column, empty
+	// for regular columns.
+	GeneratedExpr string
+	// AllowCommitTimestamp sets allow_commit_timestamp=true on the column.
+	// Only takes effect for (non-array) TIMESTAMP columns.
+	AllowCommitTimestamp bool
+	// TimezoneOffsetColId is the id of the column storing the source
+	// timezone offset of the values of this TIMESTAMP column, if any.
+	TimezoneOffsetColId string
+}
+
+// IsCommitTimestamp returns true if values of the column are populated
+// with the commit timestamp of the transaction writing them.
+func (cd ColumnDef) IsCommitTimestamp() bool {
+	return cd.AllowCommitTimestamp && cd.T.Name == Timestamp && !cd.T.IsArray
 }
 
 // Config controls how AST nodes are printed (aka unparsed).
@@ -230,13 +245,29 @@ func (c Config) quote(s string) string {
 func (cd ColumnDef) PrintColumnDef(c Config) (string, string) {
 	var s string
 	if c.SpDialect == constants.DIALECT_POSTGRESQL {
-		s = fmt.Sprintf("%s %s", c.quote(cd.Name), cd.T.PGPrintColumnDefType())
-	} else {
-		s = fmt.Sprintf("%s %s", c.quote(cd.Name), cd.T.PrintColumnDefType())
+		if cd.IsCommitTimestamp() {
+			s = fmt.Sprintf("%s SPANNER.COMMIT_TIMESTAMP", c.quote(cd.Name))
+		} else {
+			s = fmt.Sprintf("%s %s", c.quote(cd.Name), cd.T.PGPrintColumnDefType())
+		}
+		if cd.NotNull {
+			s += " NOT NULL"
+		}
+		if cd.GeneratedExpr != "" {
+			s += fmt.Sprintf(" GENERATED ALWAYS AS (%s) STORED", cd.GeneratedExpr)
+		}
+		return s, cd.Comment
 	}
+	s = fmt.Sprintf("%s %s", c.quote(cd.Name), cd.T.PrintColumnDefType())
 	if cd.NotNull {
 		s += " NOT NULL"
 	}
+	if cd.GeneratedExpr != "" {
+		s += fmt.Sprintf(" AS (%s) STORED", cd.GeneratedExpr)
+	}
+	if cd.IsCommitTimestamp() {
+		s += " OPTIONS (allow_commit_timestamp=true)"
+	}
 	return s, cd.Comment
 }
 
@@ -77,6 +77,9 @@ func TestPrintColumnDef(t *testing.T) {
 		{in: ColumnDef{Name: "col1", T: Type{Name: Int64}, NotNull: true}, expected: "col1 INT64 NOT NULL"},
 		{in: ColumnDef{Name: "col1", T: Type{Name: Int64, IsArray: true}, NotNull: true}, expected: "col1 ARRAY<INT64> NOT NULL"},
 		{in: ColumnDef{Name: "col1", T: Type{Name: Int64}}, protectIds: true, expected: "`col1` INT64"},
+		{in: ColumnDef{Name: "col1", T: Type{Name: Int64}, GeneratedExpr: "a + b"}, expected: "col1 INT64 AS (a + b) STORED"},
+		{in: ColumnDef{Name: "col1", T: Type{Name: Timestamp}, NotNull: true, AllowCommitTimestamp: true}, expected: "col1 TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true)"},
+		{in: ColumnDef{Name: "col1", T: Type{Name: Int64}, AllowCommitTimestamp: true}, expected: "col1 INT64"},
 	}
 	for _, tc := range tests {
 		s, _ := tc.in.PrintColumnDef(Config{ProtectIds: tc.protectIds})
@@ -95,6 +98,8 @@ func TestPrintColumnDefPG(t *testing.T) {
 		{in: ColumnDef{Name: "col1", T: Type{Name: Int64}, NotNull: true}, expected: "col1 INT8 NOT NULL"},
 		{in: ColumnDef{Name: "col1", T: Type{Name: Int64, IsArray: true}, NotNull: true}, expected: "col1 VARCHAR(2621440) NOT NULL"},
 		{in: ColumnDef{Name: "col1", T: Type{Name: Int64}}, protectIds: true, expected: "col1 INT8"},
+		{in: ColumnDef{Name: "col1", T: Type{Name: Int64}, GeneratedExpr: "a + b"}, expected: "col1 INT8 GENERATED ALWAYS AS (a + b) STORED"},
+		{in: ColumnDef{Name: "col1", T: Type{Name: Timestamp}, NotNull: true, AllowCommitTimestamp: true}, expected: "col1 SPANNER.COMMIT_TIMESTAMP NOT NULL"},
 	}
 	for _, tc := range tests {
 		s, _ := tc.in.PrintColumnDef(Config{ProtectIds: tc.protectIds, SpDialect: constants.DIALECT_POSTGRESQL})
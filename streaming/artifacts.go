@@ -0,0 +1,126 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
+)
+
+// statsMutex guards the streaming stats recorded in conv when launching the
+// dataflow job, as the jobs of the shards of a sharded migration are launched
+// concurrently.
+var statsMutex sync.Mutex
+
+// ArtifactStatus tells whether a GCS file read by the dataflow job changed
+// since the job was launched.
+type ArtifactStatus struct {
+	Path       string
+	Generation int64 // Generation recorded at launch time.
+	Modified   bool
+	Deleted    bool
+	Error      string
+}
+
+func getGcsObject(client *storage.Client, path string) (*storage.ObjectHandle, error) {
+	u, err := utils.ParseGCSFilePath(path)
+	if err != nil {
+		return nil, err
+	}
+	// ParseGCSFilePath treats the path as a directory, and appends a '/'.
+	return client.Bucket(u.Host).Object(u.Path[1 : len(u.Path)-1]), nil
+}
+
+// recordArtifacts stores the generation and MD5 hash of the GCS files at
+// paths in conv, so that changes made to them after the dataflow job read
+// them can be detected.
+func recordArtifacts(ctx context.Context, conv *internal.Conv, paths ...string) error {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create GCS client: %v", err)
+	}
+	defer client.Close()
+	var artifacts []internal.GcsArtifact
+	for _, path := range paths {
+		obj, err := getGcsObject(client, path)
+		if err != nil {
+			return err
+		}
+		attrs, err := obj.Attrs(ctx)
+		if err != nil {
+			return fmt.Errorf("can't get attributes of %s: %v", path, err)
+		}
+		artifacts = append(artifacts, internal.GcsArtifact{Path: path, Generation: attrs.Generation, MD5: attrs.MD5})
+	}
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	conv.Audit.StreamingStats.GcsArtifacts = append(conv.Audit.StreamingStats.GcsArtifacts, artifacts...)
+	return nil
+}
+
+// VerifyArtifacts checks whether the GCS files read by the dataflow jobs of
+// the migration were modified or deleted since the jobs were launched. A
+// file rewritten with the same content is not reported as modified.
+func VerifyArtifacts(ctx context.Context, conv *internal.Conv) ([]ArtifactStatus, error) {
+	if len(conv.Audit.StreamingStats.GcsArtifacts) == 0 {
+		return nil, nil
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %v", err)
+	}
+	defer client.Close()
+	var statuses []ArtifactStatus
+	for _, a := range conv.Audit.StreamingStats.GcsArtifacts {
+		s := ArtifactStatus{Path: a.Path, Generation: a.Generation}
+		obj, err := getGcsObject(client, a.Path)
+		if err != nil {
+			s.Error = err.Error()
+			statuses = append(statuses, s)
+			continue
+		}
+		attrs, err := obj.Attrs(ctx)
+		switch {
+		case gcp.IsNotFound(err):
+			s.Deleted = true
+		case err != nil:
+			s.Error = err.Error()
+		default:
+			s.Modified = attrs.Generation != a.Generation && !bytes.Equal(attrs.MD5, a.MD5)
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// IsInconsistent returns whether any of the artifacts changed since launch,
+// in which case the data migrated by the dataflow jobs may not match the
+// session.
+func IsInconsistent(statuses []ArtifactStatus) bool {
+	for _, s := range statuses {
+		if s.Modified || s.Deleted {
+			return true
+		}
+	}
+	return false
+}
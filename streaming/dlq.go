@@ -0,0 +1,210 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
+)
+
+// Sub directories of the dead letter queue directory of the dataflow job.
+// Records failing with retryable errors are written to the retry directory and
+// retried by the job, the others are written to the severe directory and need
+// to be fixed by the user.
+const (
+	DLQ_SEVERE_DIR = "severe"
+	DLQ_RETRY_DIR  = "retry"
+)
+
+const (
+	// Maximum number of sample errors kept per table and shard.
+	MAX_FAILED_RECORD_SAMPLES = 5
+	// Maximum number of dead letter queue records read, to bound the time
+	// taken when the queue grows large.
+	MAX_FAILED_RECORDS_READ = 100000
+)
+
+// FailedRecordStats is the number of records of a table the dataflow job of a
+// shard failed to apply, along with sample errors.
+type FailedRecordStats struct {
+	Table        string
+	ShardId      string
+	Severe       int64
+	Retry        int64
+	SampleErrors []string
+}
+
+// FailedRecords summarizes the records in the dead letter queues of the
+// dataflow jobs of a migration.
+type FailedRecords struct {
+	Severe    int64
+	Retry     int64
+	Tables    []FailedRecordStats
+	Truncated bool // Set if more than MAX_FAILED_RECORDS_READ records were found, in which case the counts are lower bounds.
+}
+
+// dlqRecord is a line of a dead letter queue file. The message holds the
+// change event, either as a json object or as a string.
+type dlqRecord struct {
+	Message      json.RawMessage `json:"message"`
+	ErrorMessage string          `json:"error_message"`
+}
+
+// recordDlqDirectory stores in conv the dead letter queue directory of the
+// dataflow job of the data shard.
+func recordDlqDirectory(conv *internal.Conv, dataShardId, dir string) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	if conv.Audit.StreamingStats.DlqDirectories == nil {
+		conv.Audit.StreamingStats.DlqDirectories = make(map[string]string)
+	}
+	conv.Audit.StreamingStats.DlqDirectories[dataShardId] = dir
+}
+
+// getRecordTable returns the table of the change event in the message of a
+// dead letter queue record.
+func getRecordTable(message json.RawMessage) string {
+	var event map[string]interface{}
+	if err := json.Unmarshal(message, &event); err != nil {
+		var s string
+		if err := json.Unmarshal(message, &s); err != nil {
+			return ""
+		}
+		if err := json.Unmarshal([]byte(s), &event); err != nil {
+			return ""
+		}
+	}
+	table, _ := event["_metadata_table"].(string)
+	return table
+}
+
+// failedRecordsAggregator aggregates the dead letter queue records per table
+// and shard.
+type failedRecordsAggregator struct {
+	summary FailedRecords
+	stats   map[string]*FailedRecordStats
+	read    int64
+}
+
+func (a *failedRecordsAggregator) add(shardId string, severe bool, line []byte) {
+	a.read++
+	var r dlqRecord
+	table := ""
+	if err := json.Unmarshal(line, &r); err == nil {
+		table = getRecordTable(r.Message)
+	}
+	key := shardId + "/" + table
+	s, ok := a.stats[key]
+	if !ok {
+		s = &FailedRecordStats{Table: table, ShardId: shardId}
+		a.stats[key] = s
+	}
+	if severe {
+		s.Severe++
+		a.summary.Severe++
+	} else {
+		s.Retry++
+		a.summary.Retry++
+	}
+	if r.ErrorMessage != "" && len(s.SampleErrors) < MAX_FAILED_RECORD_SAMPLES {
+		s.SampleErrors = append(s.SampleErrors, r.ErrorMessage)
+	}
+}
+
+// readDlqDirectory adds the records of the files under the dead letter queue
+// sub directory to a.
+func (a *failedRecordsAggregator) readDlqDirectory(ctx context.Context, client *storage.Client, shardId, dir string, severe bool) error {
+	u, err := utils.ParseGCSFilePath(dir)
+	if err != nil {
+		return err
+	}
+	bucket := client.Bucket(u.Host)
+	it := bucket.Objects(ctx, &storage.Query{Prefix: u.Path[1:]})
+	for a.read < MAX_FAILED_RECORDS_READ {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("can't list files in %s: %v", dir, err)
+		}
+		if strings.HasSuffix(attrs.Name, "/") {
+			continue
+		}
+		rc, err := bucket.Object(attrs.Name).NewReader(ctx)
+		if err != nil {
+			return fmt.Errorf("can't read gs://%s/%s: %v", u.Host, attrs.Name, err)
+		}
+		scanner := bufio.NewScanner(rc)
+		scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+		for a.read < MAX_FAILED_RECORDS_READ && scanner.Scan() {
+			if line := scanner.Bytes(); len(strings.TrimSpace(string(line))) > 0 {
+				a.add(shardId, severe, line)
+			}
+		}
+		err = scanner.Err()
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("can't read gs://%s/%s: %v", u.Host, attrs.Name, err)
+		}
+	}
+	a.summary.Truncated = true
+	return nil
+}
+
+// GetFailedRecords reads the dead letter queues of the dataflow jobs of the
+// migration, and returns the number of records which failed to be applied to
+// Spanner per table and shard, with sample errors.
+func GetFailedRecords(ctx context.Context, conv *internal.Conv) (FailedRecords, error) {
+	a := &failedRecordsAggregator{stats: make(map[string]*FailedRecordStats)}
+	if len(conv.Audit.StreamingStats.DlqDirectories) == 0 {
+		return a.summary, nil
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return FailedRecords{}, fmt.Errorf("failed to create GCS client: %v", err)
+	}
+	defer client.Close()
+	for _, shardId := range sortedKeys(conv.Audit.StreamingStats.DlqDirectories) {
+		dir := strings.TrimSuffix(conv.Audit.StreamingStats.DlqDirectories[shardId], "/")
+		if err := a.readDlqDirectory(ctx, client, shardId, dir+"/"+DLQ_SEVERE_DIR+"/", true); err != nil {
+			return FailedRecords{}, err
+		}
+		if err := a.readDlqDirectory(ctx, client, shardId, dir+"/"+DLQ_RETRY_DIR+"/", false); err != nil {
+			return FailedRecords{}, err
+		}
+	}
+	for _, s := range a.stats {
+		a.summary.Tables = append(a.summary.Tables, *s)
+	}
+	sort.Slice(a.summary.Tables, func(i, j int) bool {
+		ti, tj := a.summary.Tables[i], a.summary.Tables[j]
+		if ti.Table != tj.Table {
+			return ti.Table < tj.Table
+		}
+		return ti.ShardId < tj.ShardId
+	})
+	return a.summary, nil
+}
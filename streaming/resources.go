@@ -0,0 +1,482 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	dataflow "cloud.google.com/go/dataflow/apiv1beta3"
+	datastream "cloud.google.com/go/datastream/apiv1"
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	dashboard "cloud.google.com/go/monitoring/dashboard/apiv1"
+	"cloud.google.com/go/monitoring/dashboard/apiv1/dashboardpb"
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/storage"
+	datastreampb "google.golang.org/genproto/googleapis/cloud/datastream/v1"
+	dataflowpb "google.golang.org/genproto/googleapis/dataflow/v1beta3"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+)
+
+// Kinds of the resources generated by a streaming migration.
+const (
+	DATAFLOW_JOB_RESOURCE         = "dataflow job"
+	DATASTREAM_RESOURCE           = "datastream"
+	PUBSUB_SUBSCRIPTION_RESOURCE  = "pubsub subscription"
+	PUBSUB_TOPIC_RESOURCE         = "pubsub topic"
+	PUBSUB_NOTIFICATION_RESOURCE  = "gcs pubsub notification"
+	MONITORING_DASHBOARD_RESOURCE = "monitoring dashboard"
+	ALERT_POLICY_RESOURCE         = "monitoring alert policy"
+	// Registered type listing the subscription, topic and notification of
+	// every pubsub config.
+	PUBSUB_RESOURCES = "pubsub"
+)
+
+// Resource is a cloud resource generated by a streaming migration. Resources
+// are restored from the generated resources recorded in the conv, so Create
+// only makes sure that the resource still exists: resources are created with
+// their full configuration by the migration step generating them.
+type Resource interface {
+	// Kind is the type of the resource, e.g. DATAFLOW_JOB_RESOURCE.
+	Kind() string
+	// Name identifies the resource within its kind.
+	Name() string
+	Create(ctx context.Context) error
+	// Exists returns false once the resource is deleted, or can no longer
+	// process data, e.g. a cancelled Dataflow job.
+	Exists(ctx context.Context) (bool, error)
+	Delete(ctx context.Context) error
+	// Describe returns a human readable description of the resource.
+	Describe() string
+}
+
+// ResourceLister returns the resources of one kind generated by the migration
+// recorded in conv.
+type ResourceLister func(conv *internal.Conv, projectID, region string) []Resource
+
+type registeredResourceType struct {
+	kind   string
+	lister ResourceLister
+}
+
+// resourceRegistry holds the resource types in registration order, which is
+// the order in which they are cleaned up.
+var resourceRegistry []registeredResourceType
+
+// RegisterResourceType adds a kind of generated resource, making it part of
+// the clean up and status of streaming migrations. It is meant to be called
+// from init functions.
+func RegisterResourceType(kind string, lister ResourceLister) {
+	for _, t := range resourceRegistry {
+		if t.kind == kind {
+			panic(fmt.Sprintf("resource type %s registered twice", kind))
+		}
+	}
+	resourceRegistry = append(resourceRegistry, registeredResourceType{kind: kind, lister: lister})
+}
+
+// ListResources returns the resources generated by the migration recorded in
+// conv, of all the registered types.
+func ListResources(conv *internal.Conv, projectID, region string) []Resource {
+	var resources []Resource
+	for _, t := range resourceRegistry {
+		resources = append(resources, t.lister(conv, projectID, region)...)
+	}
+	return resources
+}
+
+// ResourceStatus is the state of a generated resource.
+type ResourceStatus struct {
+	Kind        string
+	Name        string
+	Description string
+	Exists      bool
+	Error       string
+}
+
+// GetResourceStatuses checks which of the resources generated by the migration
+// recorded in conv still exist.
+func GetResourceStatuses(ctx context.Context, conv *internal.Conv, projectID, region string) []ResourceStatus {
+	var statuses []ResourceStatus
+	for _, r := range ListResources(conv, projectID, region) {
+		s := ResourceStatus{Kind: r.Kind(), Name: r.Name(), Description: r.Describe()}
+		exists, err := r.Exists(ctx)
+		if err != nil {
+			s.Error = err.Error()
+		}
+		s.Exists = exists
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// generatedResource implements Resource from the functions checking for and
+// deleting the resource.
+type generatedResource struct {
+	kind        string
+	name        string
+	description string
+	exists      func(ctx context.Context) (bool, error)
+	delete      func(ctx context.Context) error
+}
+
+func (r *generatedResource) Kind() string     { return r.kind }
+func (r *generatedResource) Name() string     { return r.name }
+func (r *generatedResource) Describe() string { return r.description }
+
+func (r *generatedResource) Create(ctx context.Context) error {
+	exists, err := r.Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%s %s no longer exists, and can only be created again by rerunning the migration", r.kind, r.name)
+	}
+	return nil
+}
+
+func (r *generatedResource) Exists(ctx context.Context) (bool, error) {
+	return r.exists(ctx)
+}
+
+func (r *generatedResource) Delete(ctx context.Context) error {
+	return r.delete(ctx)
+}
+
+func newDataflowJobResource(jobId, projectID, region string) Resource {
+	return &generatedResource{
+		kind:        DATAFLOW_JOB_RESOURCE,
+		name:        jobId,
+		description: fmt.Sprintf("Dataflow job %s in projects/%s/locations/%s", jobId, projectID, region),
+		exists: func(ctx context.Context) (bool, error) {
+			c, err := dataflow.NewJobsV1Beta3Client(ctx)
+			if err != nil {
+				return false, fmt.Errorf("could not create job client: %v", err)
+			}
+			defer c.Close()
+			job, err := gcp.DoWithResult(ctx, gcp.Call{Service: gcp.DATAFLOW, Method: "GetJob", Resource: jobId, Idempotent: true}, func(ctx context.Context) (*dataflowpb.Job, error) {
+				return c.GetJob(ctx, &dataflowpb.GetJobRequest{ProjectId: projectID, JobId: jobId, Location: region})
+			})
+			if gcp.IsNotFound(err) {
+				return false, nil
+			}
+			if err != nil {
+				return false, fmt.Errorf("could not get dataflow job %s: %v", jobId, err)
+			}
+			switch job.CurrentState {
+			case dataflowpb.JobState_JOB_STATE_DONE, dataflowpb.JobState_JOB_STATE_FAILED, dataflowpb.JobState_JOB_STATE_CANCELLED,
+				dataflowpb.JobState_JOB_STATE_DRAINED, dataflowpb.JobState_JOB_STATE_UPDATED, dataflowpb.JobState_JOB_STATE_STOPPED:
+				return false, nil
+			}
+			return true, nil
+		},
+		delete: func(ctx context.Context) error {
+			c, err := dataflow.NewJobsV1Beta3Client(ctx)
+			if err != nil {
+				return fmt.Errorf("could not create job client: %v", err)
+			}
+			defer c.Close()
+			return CleanupDataflowJob(ctx, c, jobId, projectID, region)
+		},
+	}
+}
+
+func newDatastreamResource(dsName, projectID, region string) Resource {
+	streamName := fmt.Sprintf("projects/%s/locations/%s/streams/%s", projectID, region, dsName)
+	return &generatedResource{
+		kind:        DATASTREAM_RESOURCE,
+		name:        dsName,
+		description: fmt.Sprintf("Datastream stream %s", streamName),
+		exists: func(ctx context.Context) (bool, error) {
+			dsClient, err := datastream.NewClient(ctx)
+			if err != nil {
+				return false, fmt.Errorf("datastream client can not be created: %v", err)
+			}
+			defer dsClient.Close()
+			err = gcp.Do(ctx, gcp.Call{Service: gcp.DATASTREAM, Method: "GetStream", Resource: streamName, Idempotent: true}, func(ctx context.Context) error {
+				_, err := dsClient.GetStream(ctx, &datastreampb.GetStreamRequest{Name: streamName})
+				return err
+			})
+			if gcp.IsNotFound(err) {
+				return false, nil
+			}
+			if err != nil {
+				return false, fmt.Errorf("could not get datastream %s: %v", dsName, err)
+			}
+			return true, nil
+		},
+		delete: func(ctx context.Context) error {
+			dsClient, err := datastream.NewClient(ctx)
+			if err != nil {
+				return fmt.Errorf("datastream client can not be created: %v", err)
+			}
+			defer dsClient.Close()
+			return CleanupDatastream(ctx, dsClient, dsName, projectID, region)
+		},
+	}
+}
+
+// newPubsubResources returns the subscription, topic and gcs notification
+// created for a pubsub config, in the order in which they are deleted.
+func newPubsubResources(pubsubCfg internal.PubsubCfg, projectID string) []Resource {
+	withClient := func(f func(ctx context.Context, client *pubsub.Client) error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			client, err := pubsub.NewClient(ctx, projectID)
+			if err != nil {
+				return fmt.Errorf("pubsub client cannot be created: %v", err)
+			}
+			defer client.Close()
+			return f(ctx, client)
+		}
+	}
+	existsWithClient := func(f func(ctx context.Context, client *pubsub.Client) (bool, error)) func(ctx context.Context) (bool, error) {
+		return func(ctx context.Context) (bool, error) {
+			client, err := pubsub.NewClient(ctx, projectID)
+			if err != nil {
+				return false, fmt.Errorf("pubsub client cannot be created: %v", err)
+			}
+			defer client.Close()
+			return f(ctx, client)
+		}
+	}
+	var resources []Resource
+	if pubsubCfg.SubscriptionId != "" {
+		resources = append(resources, &generatedResource{
+			kind:        PUBSUB_SUBSCRIPTION_RESOURCE,
+			name:        pubsubCfg.SubscriptionId,
+			description: fmt.Sprintf("Pub/Sub subscription projects/%s/subscriptions/%s", projectID, pubsubCfg.SubscriptionId),
+			exists: existsWithClient(func(ctx context.Context, client *pubsub.Client) (bool, error) {
+				call := gcp.Call{Service: gcp.PUBSUB, Method: "GetSubscription", Resource: pubsubCfg.SubscriptionId, Idempotent: true}
+				return gcp.DoWithResult(ctx, call, client.Subscription(pubsubCfg.SubscriptionId).Exists)
+			}),
+			delete: withClient(func(ctx context.Context, client *pubsub.Client) error {
+				call := gcp.Call{Service: gcp.PUBSUB, Method: "DeleteSubscription", Resource: pubsubCfg.SubscriptionId}
+				return gcp.Do(ctx, call, client.Subscription(pubsubCfg.SubscriptionId).Delete)
+			}),
+		})
+	}
+	if pubsubCfg.TopicId != "" {
+		resources = append(resources, &generatedResource{
+			kind:        PUBSUB_TOPIC_RESOURCE,
+			name:        pubsubCfg.TopicId,
+			description: fmt.Sprintf("Pub/Sub topic projects/%s/topics/%s", projectID, pubsubCfg.TopicId),
+			exists: existsWithClient(func(ctx context.Context, client *pubsub.Client) (bool, error) {
+				call := gcp.Call{Service: gcp.PUBSUB, Method: "GetTopic", Resource: pubsubCfg.TopicId, Idempotent: true}
+				return gcp.DoWithResult(ctx, call, client.Topic(pubsubCfg.TopicId).Exists)
+			}),
+			delete: withClient(func(ctx context.Context, client *pubsub.Client) error {
+				call := gcp.Call{Service: gcp.PUBSUB, Method: "DeleteTopic", Resource: pubsubCfg.TopicId}
+				return gcp.Do(ctx, call, client.Topic(pubsubCfg.TopicId).Delete)
+			}),
+		})
+	}
+	if pubsubCfg.NotificationId != "" {
+		resources = append(resources, &generatedResource{
+			kind:        PUBSUB_NOTIFICATION_RESOURCE,
+			name:        pubsubCfg.NotificationId,
+			description: fmt.Sprintf("Pub/Sub notification %s on gcs bucket %s", pubsubCfg.NotificationId, pubsubCfg.BucketName),
+			exists: func(ctx context.Context) (bool, error) {
+				storageClient, err := storage.NewClient(ctx)
+				if err != nil {
+					return false, fmt.Errorf("storage client cannot be created: %v", err)
+				}
+				defer storageClient.Close()
+				notifications, err := storageClient.Bucket(pubsubCfg.BucketName).Notifications(ctx)
+				if err != nil {
+					return false, fmt.Errorf("could not list notifications of bucket %s: %v", pubsubCfg.BucketName, err)
+				}
+				_, ok := notifications[pubsubCfg.NotificationId]
+				return ok, nil
+			},
+			delete: func(ctx context.Context) error {
+				storageClient, err := storage.NewClient(ctx)
+				if err != nil {
+					return fmt.Errorf("storage client cannot be created: %v", err)
+				}
+				defer storageClient.Close()
+				return storageClient.Bucket(pubsubCfg.BucketName).DeleteNotification(ctx, pubsubCfg.NotificationId)
+			},
+		})
+	}
+	return resources
+}
+
+func newMonitoringDashboardResource(dashboardName, projectID string) Resource {
+	name := fmt.Sprintf("projects/%s/dashboards/%s", projectID, dashboardName)
+	withClient := func(f func(ctx context.Context, client *dashboard.DashboardsClient) error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			client, err := dashboard.NewDashboardsClient(ctx)
+			if err != nil {
+				return fmt.Errorf("dashboards client can not be created: %v", err)
+			}
+			defer client.Close()
+			return f(ctx, client)
+		}
+	}
+	return &generatedResource{
+		kind:        MONITORING_DASHBOARD_RESOURCE,
+		name:        dashboardName,
+		description: fmt.Sprintf("Monitoring dashboard %s", name),
+		exists: func(ctx context.Context) (bool, error) {
+			exists := false
+			err := withClient(func(ctx context.Context, client *dashboard.DashboardsClient) error {
+				_, err := client.GetDashboard(ctx, &dashboardpb.GetDashboardRequest{Name: name})
+				if gcp.IsNotFound(err) {
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("could not get monitoring dashboard %s: %v", dashboardName, err)
+				}
+				exists = true
+				return nil
+			})(ctx)
+			return exists, err
+		},
+		delete: withClient(func(ctx context.Context, client *dashboard.DashboardsClient) error {
+			return client.DeleteDashboard(ctx, &dashboardpb.DeleteDashboardRequest{Name: name})
+		}),
+	}
+}
+
+func newAlertPolicyResource(name string) Resource {
+	withClient := func(f func(ctx context.Context, client *monitoring.AlertPolicyClient) error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			client, err := monitoring.NewAlertPolicyClient(ctx)
+			if err != nil {
+				return fmt.Errorf("alert policy client can not be created: %v", err)
+			}
+			defer client.Close()
+			return f(ctx, client)
+		}
+	}
+	return &generatedResource{
+		kind:        ALERT_POLICY_RESOURCE,
+		name:        name,
+		description: fmt.Sprintf("Monitoring alert policy %s", name),
+		exists: func(ctx context.Context) (bool, error) {
+			exists := false
+			err := withClient(func(ctx context.Context, client *monitoring.AlertPolicyClient) error {
+				_, err := client.GetAlertPolicy(ctx, &monitoringpb.GetAlertPolicyRequest{Name: name})
+				if gcp.IsNotFound(err) {
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("could not get alert policy %s: %v", name, err)
+				}
+				exists = true
+				return nil
+			})(ctx)
+			return exists, err
+		},
+		delete: withClient(func(ctx context.Context, client *monitoring.AlertPolicyClient) error {
+			return client.DeleteAlertPolicy(ctx, &monitoringpb.DeleteAlertPolicyRequest{Name: name})
+		}),
+	}
+}
+
+// sortedKeys returns the keys of m sorted, so that the per shard resources are
+// listed in a stable order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	RegisterResourceType(DATAFLOW_JOB_RESOURCE, func(conv *internal.Conv, projectID, region string) []Resource {
+		var resources []Resource
+		stats := conv.Audit.StreamingStats
+		if stats.DataflowJobId != "" {
+			resources = append(resources, newDataflowJobResource(stats.DataflowJobId, projectID, region))
+		}
+		for _, shardId := range sortedKeys(stats.ShardToDataflowInfoMap) {
+			resources = append(resources, newDataflowJobResource(stats.ShardToDataflowInfoMap[shardId].JobId, projectID, region))
+		}
+		return resources
+	})
+	RegisterResourceType(DATASTREAM_RESOURCE, func(conv *internal.Conv, projectID, region string) []Resource {
+		var resources []Resource
+		stats := conv.Audit.StreamingStats
+		if stats.DataStreamName != "" {
+			resources = append(resources, newDatastreamResource(stats.DataStreamName, projectID, region))
+		}
+		for _, shardId := range sortedKeys(stats.ShardToDataStreamNameMap) {
+			resources = append(resources, newDatastreamResource(stats.ShardToDataStreamNameMap[shardId], projectID, region))
+		}
+		return resources
+	})
+	RegisterResourceType(PUBSUB_RESOURCES, func(conv *internal.Conv, projectID, region string) []Resource {
+		var resources []Resource
+		stats := conv.Audit.StreamingStats
+		if stats.PubsubCfg.TopicId != "" && !conv.IsSharded {
+			resources = append(resources, newPubsubResources(stats.PubsubCfg, projectID)...)
+		}
+		for _, shardId := range sortedKeys(stats.ShardToPubsubIdMap) {
+			resources = append(resources, newPubsubResources(stats.ShardToPubsubIdMap[shardId], projectID)...)
+		}
+		return resources
+	})
+	RegisterResourceType(MONITORING_DASHBOARD_RESOURCE, func(conv *internal.Conv, projectID, region string) []Resource {
+		var resources []Resource
+		stats := conv.Audit.StreamingStats
+		if stats.MonitoringResources.DashboardName != "" && !conv.IsSharded {
+			resources = append(resources, newMonitoringDashboardResource(stats.MonitoringResources.DashboardName, projectID))
+		}
+		if stats.AggMonitoringResources.DashboardName != "" && conv.IsSharded {
+			resources = append(resources, newMonitoringDashboardResource(stats.AggMonitoringResources.DashboardName, projectID))
+		}
+		for _, shardId := range sortedKeys(stats.ShardToMonitoringResourcesMap) {
+			if name := stats.ShardToMonitoringResourcesMap[shardId].DashboardName; name != "" {
+				resources = append(resources, newMonitoringDashboardResource(name, projectID))
+			}
+		}
+		return resources
+	})
+	RegisterResourceType(ALERT_POLICY_RESOURCE, func(conv *internal.Conv, projectID, region string) []Resource {
+		var names []string
+		stats := conv.Audit.StreamingStats
+		if !conv.IsSharded {
+			names = append(names, stats.MonitoringResources.AlertPolicies...)
+		}
+		for _, shardId := range sortedKeys(stats.ShardToMonitoringResourcesMap) {
+			names = append(names, stats.ShardToMonitoringResourcesMap[shardId].AlertPolicies...)
+		}
+		var resources []Resource
+		for _, name := range names {
+			resources = append(resources, newAlertPolicyResource(name))
+		}
+		return resources
+	})
+}
+
+// deleteResources deletes resources, logging the ones which could not be
+// deleted so that they are cleaned up manually.
+func deleteResources(ctx context.Context, resources []Resource) {
+	for _, r := range resources {
+		if err := r.Delete(ctx); err != nil {
+			logger.FromContext(ctx).Error(fmt.Sprintf("Cleanup of the %s: %s failed, please clean up the %s manually\n error=%v\n", r.Kind(), r.Name(), r.Kind(), err))
+			continue
+		}
+		logger.FromContext(ctx).Info(fmt.Sprintf("Successfully deleted %s: %s\n\n", r.Kind(), r.Name()))
+	}
+}
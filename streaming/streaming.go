@@ -29,11 +29,16 @@ import (
 	"cloud.google.com/go/monitoring/dashboard/apiv1/dashboardpb"
 	"cloud.google.com/go/pubsub"
 	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/dataflowlaunch"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/settings"
 	datastreampb "google.golang.org/genproto/googleapis/cloud/datastream/v1"
 	dataflowpb "google.golang.org/genproto/googleapis/dataflow/v1beta3"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
 
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/metrics"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/tracing"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
@@ -57,8 +62,6 @@ var (
 	MAX_WORKER_LIMIT int32 = 1000
 	// Min allowed value for maxWorkers and numWorkers.
 	MIN_WORKER_LIMIT int32 = 1
-	// Default gcs path of the Dataflow template.
-	DEFAULT_TEMPLATE_PATH string = "gs://dataflow-templates-southamerica-west1/2023-09-12-00_RC00/flex/Cloud_Datastream_to_Spanner"
 )
 
 type SrcConnCfg struct {
@@ -98,15 +101,25 @@ type DataflowCfg struct {
 	AdditionalUserLabels string            `json:"additionalUserLabels"`
 	KmsKeyName           string            `json:"kmsKeyName"`
 	GcsTemplatePath      string            `json:"gcsTemplatePath"`
+	StreamingEngine      string            `json:"streamingEngine"` // "false" to run without Streaming Engine, enabled by default
 	DbNameToShardIdMap   map[string]string `json:"dbNameToShardIdMap"`
 }
 
+// AlertingCfg enables the creation of Cloud Monitoring alert policies for the
+// dataflow job, notifying NotificationChannel. The watermark lag threshold
+// defaults to metrics.DefaultWatermarkLagMinutes.
+type AlertingCfg struct {
+	NotificationChannel string `json:"notificationChannel"`
+	WatermarkLagMinutes int64  `json:"watermarkLagMinutes"`
+}
+
 type StreamingCfg struct {
 	DatastreamCfg DatastreamCfg      `json:"datastreamCfg"`
 	DataflowCfg   DataflowCfg        `json:"dataflowCfg"`
 	TmpDir        string             `json:"tmpDir"`
 	PubsubCfg     internal.PubsubCfg `json:"pubsubCfg"`
 	DataShardId   string             `json:"dataShardId"`
+	AlertingCfg   AlertingCfg        `json:"alertingCfg"`
 }
 
 // VerifyAndUpdateCfg checks the fields and errors out if certain fields are empty.
@@ -321,6 +334,9 @@ func getSourceStreamConfig(srcCfg *datastreampb.SourceConfig, sourceProfile prof
 }
 
 func CreatePubsubResources(ctx context.Context, projectID string, datastreamDestinationConnCfg DstConnCfg, dbName string) (*internal.PubsubCfg, error) {
+	ctx, span := tracing.StartSpan(ctx, "CreatePubsubResources")
+	defer span.End()
+	defer metrics.ObserveStep("CreatePubsubResources", time.Now())
 	pubsubClient, err := pubsub.NewClient(ctx, projectID)
 	if err != nil {
 		return nil, fmt.Errorf("pubsub client can not be created: %v", err)
@@ -330,7 +346,7 @@ func CreatePubsubResources(ctx context.Context, projectID string, datastreamDest
 	// Create pubsub topic and subscription
 	pubsubCfg, err := createPubsubTopicAndSubscription(ctx, pubsubClient, dbName)
 	if err != nil {
-		logger.Log.Error(fmt.Sprintf("Could not create pubsub resources. Some permissions missing. Please check https://googlecloudplatform.github.io/spanner-migration-tool/permissions.html for required pubsub permissions. error=%v", err))
+		logger.FromContext(ctx).Error(fmt.Sprintf("Could not create pubsub resources. Some permissions missing. Please check https://googlecloudplatform.github.io/spanner-migration-tool/permissions.html for required pubsub permissions. error=%v", err))
 		return nil, err
 	}
 
@@ -357,12 +373,12 @@ func CreatePubsubResources(ctx context.Context, projectID string, datastreamDest
 
 	notificationID, err := createNotificationOnBucket(ctx, storageClient, projectID, pubsubCfg.TopicId, bucketName, prefix)
 	if err != nil {
-		logger.Log.Error(fmt.Sprintf("Could not create pubsub resources. Some permissions missing. Please check https://googlecloudplatform.github.io/spanner-migration-tool/permissions.html for required pubsub permissions. error=%v", err))
+		logger.FromContext(ctx).Error(fmt.Sprintf("Could not create pubsub resources. Some permissions missing. Please check https://googlecloudplatform.github.io/spanner-migration-tool/permissions.html for required pubsub permissions. error=%v", err))
 		return nil, err
 	}
 	pubsubCfg.BucketName = bucketName
 	pubsubCfg.NotificationId = notificationID
-	logger.Log.Info(fmt.Sprintf("Successfully created pubsub topic id=%s, subscription id=%s, notification for bucket=%s with id=%s.\n", pubsubCfg.TopicId, pubsubCfg.SubscriptionId, bucketName, notificationID))
+	logger.FromContext(ctx).Info(fmt.Sprintf("Successfully created pubsub topic id=%s, subscription id=%s, notification for bucket=%s with id=%s.\n", pubsubCfg.TopicId, pubsubCfg.SubscriptionId, bucketName, notificationID))
 	return &pubsubCfg, nil
 }
 
@@ -382,15 +398,19 @@ func createPubsubTopicAndSubscription(ctx context.Context, pubsubClient *pubsub.
 	pubsubCfg.TopicId = topicId
 
 	// Create Topic and Subscription
-	topicObj, err := pubsubClient.CreateTopic(ctx, pubsubCfg.TopicId)
+	topicObj, err := gcp.DoWithResult(ctx, gcp.Call{Service: gcp.PUBSUB, Method: "CreateTopic", Resource: pubsubCfg.TopicId}, func(ctx context.Context) (*pubsub.Topic, error) {
+		return pubsubClient.CreateTopic(ctx, pubsubCfg.TopicId)
+	})
 	if err != nil {
 		return pubsubCfg, fmt.Errorf("pubsub topic could not be created: %v", err)
 	}
 
-	_, err = pubsubClient.CreateSubscription(ctx, pubsubCfg.SubscriptionId, pubsub.SubscriptionConfig{
-		Topic:             topicObj,
-		AckDeadline:       time.Minute * 10,
-		RetentionDuration: time.Hour * 24 * 7,
+	_, err = gcp.DoWithResult(ctx, gcp.Call{Service: gcp.PUBSUB, Method: "CreateSubscription", Resource: pubsubCfg.SubscriptionId}, func(ctx context.Context) (*pubsub.Subscription, error) {
+		return pubsubClient.CreateSubscription(ctx, pubsubCfg.SubscriptionId, pubsub.SubscriptionConfig{
+			Topic:             topicObj,
+			AckDeadline:       time.Minute * 10,
+			RetentionDuration: time.Hour * 24 * 7,
+		})
 	})
 	if err != nil {
 		return pubsubCfg, fmt.Errorf("pubsub subscription could not be created: %v", err)
@@ -398,12 +418,20 @@ func createPubsubTopicAndSubscription(ctx context.Context, pubsubClient *pubsub.
 	return pubsubCfg, nil
 }
 
+// getConnectionProfile returns the Datastream connection profile with the
+// given name.
+func getConnectionProfile(ctx context.Context, dsClient *datastream.Client, name string) (*datastreampb.ConnectionProfile, error) {
+	return gcp.DoWithResult(ctx, gcp.Call{Service: gcp.DATASTREAM, Method: "GetConnectionProfile", Resource: name, Idempotent: true}, func(ctx context.Context) (*datastreampb.ConnectionProfile, error) {
+		return dsClient.GetConnectionProfile(ctx, &datastreampb.GetConnectionProfileRequest{Name: name})
+	})
+}
+
 func FetchTargetBucketAndPath(ctx context.Context, datastreamClient *datastream.Client, projectID string, datastreamDestinationConnCfg DstConnCfg) (string, string, error) {
 	if datastreamClient == nil {
 		return "", "", fmt.Errorf("datastream client could not be created")
 	}
 	dstProf := fmt.Sprintf("projects/%s/locations/%s/connectionProfiles/%s", projectID, datastreamDestinationConnCfg.Location, datastreamDestinationConnCfg.Name)
-	res, err := datastreamClient.GetConnectionProfile(ctx, &datastreampb.GetConnectionProfileRequest{Name: dstProf})
+	res, err := getConnectionProfile(ctx, datastreamClient, dstProf)
 	if err != nil {
 		return "", "", fmt.Errorf("could not get connection profiles: %v", err)
 	}
@@ -459,6 +487,9 @@ func concatDirectoryPath(basePath, subPath string) string {
 
 // LaunchStream populates the parameters from the streaming config and triggers a stream on Cloud Datastream.
 func LaunchStream(ctx context.Context, sourceProfile profiles.SourceProfile, dbList []profiles.LogicalShard, projectID string, datastreamCfg DatastreamCfg) error {
+	ctx, span := tracing.StartSpan(ctx, "LaunchStream")
+	defer span.End()
+	defer metrics.ObserveStep("LaunchStream", time.Now())
 	fmt.Println("Launching stream ", fmt.Sprintf("projects/%s/locations/%s", projectID, datastreamCfg.StreamLocation))
 	dsClient, err := datastream.NewClient(ctx)
 	if err != nil {
@@ -500,7 +531,9 @@ func LaunchStream(ctx context.Context, sourceProfile profiles.SourceProfile, dbL
 
 	fmt.Println("Created stream request..")
 
-	dsOp, err := dsClient.CreateStream(ctx, createStreamRequest)
+	dsOp, err := gcp.DoWithResult(ctx, gcp.Call{Service: gcp.DATASTREAM, Method: "CreateStream", Resource: datastreamCfg.StreamId}, func(ctx context.Context) (*datastream.CreateStreamOperation, error) {
+		return dsClient.CreateStream(ctx, createStreamRequest)
+	})
 	if err != nil {
 		fmt.Printf("cannot create stream: createStreamRequest: %+v\n", createStreamRequest)
 		return fmt.Errorf("cannot create stream: %v ", err)
@@ -519,7 +552,9 @@ func LaunchStream(ctx context.Context, sourceProfile profiles.SourceProfile, dbL
 		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"state"}},
 		Stream:     streamInfo,
 	}
-	upOp, err := dsClient.UpdateStream(ctx, updateStreamRequest)
+	upOp, err := gcp.DoWithResult(ctx, gcp.Call{Service: gcp.DATASTREAM, Method: "UpdateStream", Resource: streamInfo.Name, Idempotent: true}, func(ctx context.Context) (*datastream.UpdateStreamOperation, error) {
+		return dsClient.UpdateStream(ctx, updateStreamRequest)
+	})
 	if err != nil {
 		return fmt.Errorf("could not create update request: %v", err)
 	}
@@ -531,70 +566,10 @@ func LaunchStream(ctx context.Context, sourceProfile profiles.SourceProfile, dbL
 	return nil
 }
 
+// CleanUpStreamingJobs deletes the resources of all the registered types
+// generated by the streaming migration recorded in conv.
 func CleanUpStreamingJobs(ctx context.Context, conv *internal.Conv, projectID, region string) error {
-	//create clients
-	c, err := dataflow.NewJobsV1Beta3Client(ctx)
-	if err != nil {
-		return fmt.Errorf("could not create job client: %v", err)
-	}
-	defer c.Close()
-	fmt.Println("Created dataflow job client...")
-	dsClient, err := datastream.NewClient(ctx)
-	fmt.Println("Created datastream client...")
-	if err != nil {
-		return fmt.Errorf("datastream client can not be created: %v", err)
-	}
-	defer dsClient.Close()
-	pubsubClient, err := pubsub.NewClient(ctx, projectID)
-	if err != nil {
-		return fmt.Errorf("pubsub client cannot be created: %v", err)
-	}
-	defer pubsubClient.Close()
-
-	storageClient, err := storage.NewClient(ctx)
-	if err != nil {
-		return fmt.Errorf("storage client cannot be created: %v", err)
-	}
-	defer storageClient.Close()
-
-	//clean up for single instance migrations
-	if conv.Audit.StreamingStats.DataflowJobId != "" {
-		CleanupDataflowJob(ctx, c, conv.Audit.StreamingStats.DataflowJobId, projectID, region)
-	}
-	if conv.Audit.StreamingStats.DataStreamName != "" {
-		CleanupDatastream(ctx, dsClient, conv.Audit.StreamingStats.DataStreamName, projectID, region)
-	}
-	if conv.Audit.StreamingStats.PubsubCfg.TopicId != "" && !conv.IsSharded {
-		CleanupPubsubResources(ctx, pubsubClient, storageClient, conv.Audit.StreamingStats.PubsubCfg, projectID)
-	}
-	if conv.Audit.StreamingStats.MonitoringResources.DashboardName != "" && !conv.IsSharded {
-		CleanupMonitoringDashboard(ctx, conv.Audit.StreamingStats.MonitoringResources.DashboardName, projectID)
-	}
-	if conv.Audit.StreamingStats.AggMonitoringResources.DashboardName != "" && conv.IsSharded {
-		CleanupMonitoringDashboard(ctx, conv.Audit.StreamingStats.AggMonitoringResources.DashboardName, projectID)
-	}
-	// clean up jobs for sharded migrations (with error handling)
-	for _, resourceDetails := range conv.Audit.StreamingStats.ShardToDataflowInfoMap {
-		dfId := resourceDetails.JobId
-		err := CleanupDataflowJob(ctx, c, dfId, projectID, region)
-		if err != nil {
-			fmt.Printf("Cleanup of the dataflow job: %s was unsuccessful, please clean up the job manually", dfId)
-		}
-	}
-	for _, dsName := range conv.Audit.StreamingStats.ShardToDataStreamNameMap {
-		err := CleanupDatastream(ctx, dsClient, dsName, projectID, region)
-		if err != nil {
-			fmt.Printf("Cleanup of the datastream: %s was unsuccessful, please clean up the stream manually", dsName)
-		}
-	}
-	for _, pubsubCfg := range conv.Audit.StreamingStats.ShardToPubsubIdMap {
-		CleanupPubsubResources(ctx, pubsubClient, storageClient, pubsubCfg, projectID)
-	}
-	for _, monitoringResource := range conv.Audit.StreamingStats.ShardToMonitoringResourcesMap {
-		if monitoringResource.DashboardName != "" {
-			CleanupMonitoringDashboard(ctx, monitoringResource.DashboardName, projectID)
-		}
-	}
+	deleteResources(ctx, ListResources(conv, projectID, region))
 	fmt.Println("Clean up complete")
 	return nil
 }
@@ -602,35 +577,35 @@ func CleanUpStreamingJobs(ctx context.Context, conv *internal.Conv, projectID, r
 func CleanupPubsubResources(ctx context.Context, pubsubClient *pubsub.Client, storageClient *storage.Client, pubsubCfg internal.PubsubCfg, projectID string) {
 	subscription := pubsubClient.Subscription(pubsubCfg.SubscriptionId)
 
-	err := subscription.Delete(ctx)
+	err := gcp.Do(ctx, gcp.Call{Service: gcp.PUBSUB, Method: "DeleteSubscription", Resource: pubsubCfg.SubscriptionId}, subscription.Delete)
 	if err != nil {
-		logger.Log.Error(fmt.Sprintf("Cleanup of the pubsub subscription: %s Failed, please clean up the pubsub subscription manually\n error=%v\n", pubsubCfg.SubscriptionId, err))
+		logger.FromContext(ctx).Error(fmt.Sprintf("Cleanup of the pubsub subscription: %s Failed, please clean up the pubsub subscription manually\n error=%v\n", pubsubCfg.SubscriptionId, err))
 	} else {
-		logger.Log.Info(fmt.Sprintf("Successfully deleted subscription: %s\n\n", pubsubCfg.SubscriptionId))
+		logger.FromContext(ctx).Info(fmt.Sprintf("Successfully deleted subscription: %s\n\n", pubsubCfg.SubscriptionId))
 	}
 
 	topic := pubsubClient.Topic(pubsubCfg.TopicId)
 
-	err = topic.Delete(ctx)
+	err = gcp.Do(ctx, gcp.Call{Service: gcp.PUBSUB, Method: "DeleteTopic", Resource: pubsubCfg.TopicId}, topic.Delete)
 	if err != nil {
-		logger.Log.Error(fmt.Sprintf("Cleanup of the pubsub topic: %s Failed, please clean up the pubsub topic manually\n error=%v\n", pubsubCfg.TopicId, err))
+		logger.FromContext(ctx).Error(fmt.Sprintf("Cleanup of the pubsub topic: %s Failed, please clean up the pubsub topic manually\n error=%v\n", pubsubCfg.TopicId, err))
 	} else {
-		logger.Log.Info(fmt.Sprintf("Successfully deleted topic: %s\n\n", pubsubCfg.TopicId))
+		logger.FromContext(ctx).Info(fmt.Sprintf("Successfully deleted topic: %s\n\n", pubsubCfg.TopicId))
 	}
 
 	bucket := storageClient.Bucket(pubsubCfg.BucketName)
 
 	if err := bucket.DeleteNotification(ctx, pubsubCfg.NotificationId); err != nil {
-		logger.Log.Error(fmt.Sprintf("Cleanup of GCS pubsub notification: %s failed.\n error=%v\n", pubsubCfg.NotificationId, err))
+		logger.FromContext(ctx).Error(fmt.Sprintf("Cleanup of GCS pubsub notification: %s failed.\n error=%v\n", pubsubCfg.NotificationId, err))
 	} else {
-		logger.Log.Info(fmt.Sprintf("Successfully deleted GCS pubsub notification: %s\n\n", pubsubCfg.NotificationId))
+		logger.FromContext(ctx).Info(fmt.Sprintf("Successfully deleted GCS pubsub notification: %s\n\n", pubsubCfg.NotificationId))
 	}
 }
 
 func CleanupMonitoringDashboard(ctx context.Context, dashboardName string, projectID string) {
 	client, err := dashboard.NewDashboardsClient(ctx)
 	if err != nil {
-		logger.Log.Error(fmt.Sprintf("Cleanup of the monitoring dashboard: %s Failed, please clean up the dashboard manually\n error=%v\n", dashboardName, err))
+		logger.FromContext(ctx).Error(fmt.Sprintf("Cleanup of the monitoring dashboard: %s Failed, please clean up the dashboard manually\n error=%v\n", dashboardName, err))
 	}
 	defer client.Close()
 	req := &dashboardpb.DeleteDashboardRequest{
@@ -638,9 +613,9 @@ func CleanupMonitoringDashboard(ctx context.Context, dashboardName string, proje
 	}
 	err = client.DeleteDashboard(ctx, req)
 	if err != nil {
-		logger.Log.Error(fmt.Sprintf("Cleanup of the monitoring dashboard: %s Failed, please clean up the dashboard manually\n error=%v\n", dashboardName, err))
+		logger.FromContext(ctx).Error(fmt.Sprintf("Cleanup of the monitoring dashboard: %s Failed, please clean up the dashboard manually\n error=%v\n", dashboardName, err))
 	} else {
-		logger.Log.Info(fmt.Sprintf("Successfully deleted Monitoring Dashboard: %s\n\n", dashboardName))
+		logger.FromContext(ctx).Info(fmt.Sprintf("Successfully deleted Monitoring Dashboard: %s\n\n", dashboardName))
 	}
 }
 
@@ -668,7 +643,10 @@ func CleanupDataflowJob(ctx context.Context, client *dataflow.JobsV1Beta3Client,
 		Location:  region,
 		Job:       job,
 	}
-	_, err := client.UpdateJob(ctx, dfReq)
+	// Requesting the cancellation again is harmless.
+	_, err := gcp.DoWithResult(ctx, gcp.Call{Service: gcp.DATAFLOW, Method: "UpdateJob", Resource: dataflowJobId, Idempotent: true}, func(ctx context.Context) (*dataflowpb.Job, error) {
+		return client.UpdateJob(ctx, dfReq)
+	})
 	if err != nil {
 		fmt.Println(err)
 		return fmt.Errorf("error while cancelling dataflow job: %v", err)
@@ -678,6 +656,9 @@ func CleanupDataflowJob(ctx context.Context, client *dataflow.JobsV1Beta3Client,
 
 // LaunchDataflowJob populates the parameters from the streaming config and triggers a Dataflow job.
 func LaunchDataflowJob(ctx context.Context, targetProfile profiles.TargetProfile, streamingCfg StreamingCfg, conv *internal.Conv) (internal.DataflowOutput, error) {
+	ctx, span := tracing.StartSpan(ctx, "LaunchDataflowJob")
+	defer span.End()
+	defer metrics.ObserveStep("LaunchDataflowJob", time.Now())
 	project, instance, dbName, _ := targetProfile.GetResourceIds(ctx, time.Now(), "", nil)
 	dataflowCfg := streamingCfg.DataflowCfg
 	datastreamCfg := streamingCfg.DatastreamCfg
@@ -699,7 +680,7 @@ func LaunchDataflowJob(ctx context.Context, targetProfile profiles.TargetProfile
 
 	// Fetch the GCS path from the destination connection profile.
 	dstProf := fmt.Sprintf("projects/%s/locations/%s/connectionProfiles/%s", project, datastreamCfg.DestinationConnectionConfig.Location, datastreamCfg.DestinationConnectionConfig.Name)
-	res, err := dsClient.GetConnectionProfile(ctx, &datastreampb.GetConnectionProfileRequest{Name: dstProf})
+	res, err := getConnectionProfile(ctx, dsClient, dstProf)
 	if err != nil {
 		return internal.DataflowOutput{}, fmt.Errorf("could not get connection profiles: %v", err)
 	}
@@ -714,10 +695,11 @@ func LaunchDataflowJob(ctx context.Context, targetProfile profiles.TargetProfile
 	var (
 		dataflowProjectId        = project
 		dataflowVpcHostProjectId = project
-		gcsTemplatePath          = DEFAULT_TEMPLATE_PATH
+		gcsTemplatePath          = settings.Get(settings.DATASTREAM_TO_SPANNER_TEMPLATE)
 		dataflowSubnetwork       = ""
 		workerIpAddressConfig    = dataflowpb.WorkerIPAddressConfiguration_WORKER_IP_PUBLIC
 		dataflowUserLabels       = make(map[string]string)
+		streamingEngine          = true
 	)
 	// If project override present, use that otherwise default to Spanner project. Useful when customers want to run Dataflow in separate project.
 	if dataflowCfg.ProjectId != "" {
@@ -746,6 +728,16 @@ func LaunchDataflowJob(ctx context.Context, targetProfile profiles.TargetProfile
 		}
 	}
 
+	if dataflowCfg.StreamingEngine != "" {
+		streamingEngine, err = strconv.ParseBool(dataflowCfg.StreamingEngine)
+		if err != nil {
+			return internal.DataflowOutput{}, fmt.Errorf("could not parse StreamingEngine parameter %s, please provide true or false as input", dataflowCfg.StreamingEngine)
+		}
+		if !streamingEngine {
+			logger.FromContext(ctx).Warn("Streaming Engine is disabled, the state of the Dataflow job is kept on the disks of the workers, which need more disk and memory. Only disable it when the region or an organization policy doesn't allow it")
+		}
+	}
+
 	if dataflowCfg.MaxWorkers != "" {
 		intVal, err := strconv.ParseInt(dataflowCfg.MaxWorkers, 10, 64)
 		if err != nil {
@@ -767,10 +759,9 @@ func LaunchDataflowJob(ctx context.Context, targetProfile profiles.TargetProfile
 		}
 	}
 
-	launchParameters := &dataflowpb.LaunchFlexTemplateParameter{
-		JobName:  dataflowCfg.JobName,
-		Template: &dataflowpb.LaunchFlexTemplateParameter_ContainerSpecGcsPath{ContainerSpecGcsPath: gcsTemplatePath},
-		Parameters: map[string]string{
+	req, err := dataflowlaunch.NewLaunchRequestBuilder(dataflowProjectId, dataflowCfg.Location, dataflowCfg.JobName).
+		WithTemplate(gcsTemplatePath).
+		WithParams(map[string]string{
 			"inputFilePattern":              concatDirectoryPath(inputFilePattern, "data"),
 			"streamName":                    fmt.Sprintf("projects/%s/locations/%s/streams/%s", project, datastreamCfg.StreamLocation, datastreamCfg.StreamId),
 			"instanceId":                    instance,
@@ -779,39 +770,43 @@ func LaunchDataflowJob(ctx context.Context, targetProfile profiles.TargetProfile
 			"deadLetterQueueDirectory":      inputFilePattern + "dlq",
 			"transformationContextFilePath": streamingCfg.TmpDir + "transformationContext.json",
 			"gcsPubSubSubscription":         fmt.Sprintf("projects/%s/subscriptions/%s", project, streamingCfg.PubsubCfg.SubscriptionId),
-		},
-		Environment: &dataflowpb.FlexTemplateRuntimeEnvironment{
+		}).
+		WithEnvironment(&dataflowpb.FlexTemplateRuntimeEnvironment{
 			MaxWorkers:            maxWorkers,
 			NumWorkers:            numWorkers,
 			ServiceAccountEmail:   dataflowCfg.ServiceAccountEmail,
 			AutoscalingAlgorithm:  2, // 2 corresponds to AUTOSCALING_ALGORITHM_BASIC
-			EnableStreamingEngine: true,
+			EnableStreamingEngine: streamingEngine,
 			Network:               dataflowCfg.Network,
 			Subnetwork:            dataflowSubnetwork,
 			IpConfiguration:       workerIpAddressConfig,
 			MachineType:           dataflowCfg.MachineType,
 			AdditionalUserLabels:  dataflowUserLabels,
 			KmsKeyName:            dataflowCfg.KmsKeyName,
-		},
-	}
-	req := &dataflowpb.LaunchFlexTemplateRequest{
-		ProjectId:       dataflowProjectId,
-		LaunchParameter: launchParameters,
-		Location:        dataflowCfg.Location,
+		}).
+		Build()
+	if err != nil {
+		return internal.DataflowOutput{}, err
 	}
 	fmt.Println("Created flex template request body...")
 
-	respDf, err := c.LaunchFlexTemplate(ctx, req)
+	if err := utils.ValidateFlexTemplateRequest(ctx, req); err != nil {
+		return internal.DataflowOutput{}, err
+	}
+	respDf, err := gcp.DoWithResult(ctx, gcp.Call{Service: gcp.DATAFLOW, Method: "LaunchFlexTemplate", Resource: req.LaunchParameter.JobName}, func(ctx context.Context) (*dataflowpb.LaunchFlexTemplateResponse, error) {
+		return c.LaunchFlexTemplate(ctx, req)
+	})
 	if err != nil {
 		fmt.Printf("flexTemplateRequest: %+v\n", req)
 		return internal.DataflowOutput{}, fmt.Errorf("unable to launch template: %v", err)
 	}
+	recordDlqDirectory(conv, streamingCfg.DataShardId, req.LaunchParameter.Parameters["deadLetterQueueDirectory"])
 	gcloudDfCmd := utils.GetGcloudDataflowCommand(req)
-	logger.Log.Debug(fmt.Sprintf("\nEquivalent gCloud command for job %s:\n%s\n\n", req.LaunchParameter.JobName, gcloudDfCmd))
+	logger.FromContext(ctx).Debug(fmt.Sprintf("\nEquivalent gCloud command for job %s:\n%s\n\n", req.LaunchParameter.JobName, gcloudDfCmd))
 	return internal.DataflowOutput{JobID: respDf.Job.Id, GCloudCmd: gcloudDfCmd}, nil
 }
 
-func StoreGeneratedResources(conv *internal.Conv, streamingCfg StreamingCfg, dfJobId, gcloudDataflowCmd, project, dataShardId string, gcsBucket internal.GcsResources, dashboardName string) {
+func StoreGeneratedResources(conv *internal.Conv, streamingCfg StreamingCfg, dfJobId, gcloudDataflowCmd, project, dataShardId string, gcsBucket internal.GcsResources, monitoringResources internal.MonitoringResources) {
 	datastreamCfg := streamingCfg.DatastreamCfg
 	dataflowCfg := streamingCfg.DataflowCfg
 	conv.Audit.StreamingStats.DataStreamName = datastreamCfg.StreamId
@@ -820,7 +815,7 @@ func StoreGeneratedResources(conv *internal.Conv, streamingCfg StreamingCfg, dfJ
 	conv.Audit.StreamingStats.DataflowGcloudCmd = gcloudDataflowCmd
 	conv.Audit.StreamingStats.PubsubCfg = streamingCfg.PubsubCfg
 	conv.Audit.StreamingStats.GcsResources = gcsBucket
-	conv.Audit.StreamingStats.MonitoringResources = internal.MonitoringResources{DashboardName: dashboardName}
+	conv.Audit.StreamingStats.MonitoringResources = monitoringResources
 	if dataShardId != "" {
 		var resourceMutex sync.Mutex
 		resourceMutex.Lock()
@@ -828,9 +823,9 @@ func StoreGeneratedResources(conv *internal.Conv, streamingCfg StreamingCfg, dfJ
 		conv.Audit.StreamingStats.ShardToDataflowInfoMap[dataShardId] = internal.ShardedDataflowJobResources{JobId: dfJobId, GcloudCmd: gcloudDataflowCmd}
 		conv.Audit.StreamingStats.ShardToPubsubIdMap[dataShardId] = streamingCfg.PubsubCfg
 		conv.Audit.StreamingStats.ShardToGcsResources[dataShardId] = gcsBucket
-		if dashboardName != "" {
+		if monitoringResources.DashboardName != "" || len(monitoringResources.AlertPolicies) > 0 {
 			{
-				conv.Audit.StreamingStats.ShardToMonitoringResourcesMap[dataShardId] = internal.MonitoringResources{DashboardName: dashboardName}
+				conv.Audit.StreamingStats.ShardToMonitoringResourcesMap[dataShardId] = monitoringResources
 			}
 		}
 		resourceMutex.Unlock()
@@ -860,6 +855,7 @@ func CreateStreamingConfig(pl profiles.DataShard) StreamingCfg {
 		AdditionalUserLabels: inputDataflowConfig.AdditionalUserLabels,
 		KmsKeyName:           inputDataflowConfig.KmsKeyName,
 		GcsTemplatePath:      inputDataflowConfig.GcsTemplatePath,
+		StreamingEngine:      inputDataflowConfig.StreamingEngine,
 	}
 	//create src and dst datastream from pl receiver object
 	datastreamCfg := DatastreamCfg{
@@ -919,6 +915,12 @@ func StartDataflow(ctx context.Context, targetProfile profiles.TargetProfile, st
 	if err != nil {
 		return internal.DataflowOutput{}, fmt.Errorf("error while writing to GCS: %v", err)
 	}
+	// Record the files read by the job, so that changes made to them after
+	// launch can be detected.
+	err = recordArtifacts(ctx, conv, streamingCfg.TmpDir+"session.json", streamingCfg.TmpDir+"transformationContext.json")
+	if err != nil {
+		logger.Log.Warn(fmt.Sprintf("could not record the files read by the dataflow job, changes made to them will not be detected: %v", err))
+	}
 	dfOutput, err := LaunchDataflowJob(ctx, targetProfile, streamingCfg, conv)
 	if err != nil {
 		return internal.DataflowOutput{}, fmt.Errorf("error launching dataflow: %v", err)
@@ -125,6 +125,7 @@ export const Dataflow = {
   ProjectId: 'dataflowProjectId',
   Location: 'dataflowLocation',
   GcsTemplatePath: 'gcsTemplatePath',
+  StreamingEngine: 'streamingEngine',
   IsDataflowConfigSet: 'isDataflowConfigSet',
 }
 
@@ -38,6 +38,7 @@ export class DataflowFormComponent implements OnInit {
       dataflowProjectId: new FormControl(data.GCPProjectID),
       dataflowLocation: new FormControl(''),
       gcsTemplatePath: new FormControl('', [Validators.pattern('^gs:\\/\\/[^\\n\\r]+$')]),
+      streamingEngine: new FormControl('true'),
     })
     this.presetFlagsForm.disable()
   }
@@ -59,6 +60,7 @@ export class DataflowFormComponent implements OnInit {
     localStorage.setItem(Dataflow.ProjectId, this.presetFlagsForm.value.dataflowProjectId)
     localStorage.setItem(Dataflow.Location, this.presetFlagsForm.value.dataflowLocation)
     localStorage.setItem(Dataflow.GcsTemplatePath, this.presetFlagsForm.value.gcsTemplatePath)
+    localStorage.setItem(Dataflow.StreamingEngine, this.presetFlagsForm.value.streamingEngine)
     localStorage.setItem(Dataflow.IsDataflowConfigSet, "true")
     this.dialofRef.close()
   }
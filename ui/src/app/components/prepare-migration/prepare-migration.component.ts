@@ -35,6 +35,7 @@ export class PrepareMigrationComponent implements OnInit {
   migrationModes: any = []
   migrationTypes: any = []
   subscription!: Subscription
+  displayStreamingMsg: boolean = false
   constructor(
     private dialog: MatDialog,
     private fetch: FetchService,
@@ -130,7 +131,8 @@ export class PrepareMigrationComponent implements OnInit {
     kmsKeyName: localStorage.getItem(Dataflow.KmsKeyName) as string,
     projectId: localStorage.getItem(Dataflow.ProjectId) as string,
     location: localStorage.getItem(Dataflow.Location) as string,
-    gcsTemplatePath: localStorage.getItem(Dataflow.GcsTemplatePath) as string
+    gcsTemplatePath: localStorage.getItem(Dataflow.GcsTemplatePath) as string,
+    streamingEngine: localStorage.getItem(Dataflow.StreamingEngine) as string
   }
   spannerConfig: ISpannerConfig = {
     GCPProjectID: '',
@@ -377,6 +379,7 @@ export class PrepareMigrationComponent implements OnInit {
     localStorage.removeItem(Dataflow.ProjectId)
     localStorage.removeItem(Dataflow.Location)
     localStorage.removeItem(Dataflow.GcsTemplatePath)
+    localStorage.removeItem(Dataflow.StreamingEngine)
     localStorage.removeItem(MigrationDetails.IsMigrationInProgress)
     localStorage.removeItem(MigrationDetails.HasSchemaMigrationStarted)
     localStorage.removeItem(MigrationDetails.HasDataMigrationStarted)
@@ -523,7 +526,8 @@ export class PrepareMigrationComponent implements OnInit {
         kmsKeyName: localStorage.getItem(Dataflow.KmsKeyName) as string,
         projectId: localStorage.getItem(Dataflow.ProjectId) as string,
         location: localStorage.getItem(Dataflow.Location) as string,
-        gcsTemplatePath: localStorage.getItem(Dataflow.GcsTemplatePath) as string
+        gcsTemplatePath: localStorage.getItem(Dataflow.GcsTemplatePath) as string,
+        streamingEngine: localStorage.getItem(Dataflow.StreamingEngine) as string
       }
       this.isDataflowConfigurationSet = localStorage.getItem(Dataflow.IsDataflowConfigSet) as string === 'true'
       // We only call setDataflowDetailsForShardedMigrations for sharded flows. Non-sharded flows write a streaming config file
@@ -689,111 +693,19 @@ export class PrepareMigrationComponent implements OnInit {
   }
 
   subscribeMigrationProgress() {
-    var displayStreamingMsg = false
+    this.displayStreamingMsg = false
+    // Progress is streamed by the backend as it changes. Fall back to polling
+    // if the stream can't be opened or breaks.
+    this.subscription = this.fetch.streamProgress().subscribe({
+      next: (res: IProgress) => this.handleProgress(res),
+      error: () => this.pollMigrationProgress(),
+    })
+  }
+
+  pollMigrationProgress() {
     this.subscription = interval(5000).subscribe((x) => {
       this.fetch.getProgress().subscribe({
-        next: (res: IProgress) => {
-          if (res.ErrorMessage == '') {
-            // Checking for completion of schema migration
-            if (res.ProgressStatus == ProgressStatus.SchemaMigrationComplete) {
-              localStorage.setItem(MigrationDetails.SchemaMigrationProgress, '100')
-              this.schemaMigrationProgress = parseInt(
-                localStorage.getItem(MigrationDetails.SchemaMigrationProgress) as string
-              )
-              if (this.selectedMigrationMode == MigrationModes.schemaOnly) {
-                this.markMigrationComplete()
-              } else if (this.selectedMigrationType == MigrationTypes.lowDowntimeMigration) {
-                this.markSchemaMigrationComplete()
-                this.generatingResources = true
-                localStorage.setItem(
-                  MigrationDetails.GeneratingResources,
-                  this.generatingResources.toString()
-                )
-                if (!displayStreamingMsg) {
-                  this.snack.openSnackBar('Setting up dataflow and datastream jobs', 'Close')
-                  displayStreamingMsg = true
-                }
-              } else {
-                this.markSchemaMigrationComplete()
-                this.hasDataMigrationStarted = true
-                localStorage.setItem(
-                  MigrationDetails.HasDataMigrationStarted,
-                  this.hasDataMigrationStarted.toString()
-                )
-              }
-            } else if (res.ProgressStatus == ProgressStatus.DataMigrationComplete) {
-              if (this.selectedMigrationType != MigrationTypes.lowDowntimeMigration) {
-                this.hasDataMigrationStarted = true
-                localStorage.setItem(
-                  MigrationDetails.HasDataMigrationStarted,
-                  this.hasDataMigrationStarted.toString()
-                )
-              }
-              this.generatingResources = false
-              localStorage.setItem(
-                MigrationDetails.GeneratingResources,
-                this.generatingResources.toString()
-              )
-              this.markMigrationComplete()
-            }
-            // Checking for data migration in progress
-            else if (res.ProgressStatus == ProgressStatus.DataWriteInProgress) {
-              this.markSchemaMigrationComplete()
-              this.hasDataMigrationStarted = true
-              localStorage.setItem(
-                MigrationDetails.HasDataMigrationStarted,
-                this.hasDataMigrationStarted.toString()
-              )
-              localStorage.setItem(MigrationDetails.DataMigrationProgress, res.Progress.toString())
-              this.dataMigrationProgress = parseInt(
-                localStorage.getItem(MigrationDetails.DataMigrationProgress) as string
-              )
-            } else if (res.ProgressStatus == ProgressStatus.ForeignKeyUpdateComplete) {
-              this.markMigrationComplete()
-            }
-            // Checking for foreign key update in progress
-            else if (res.ProgressStatus == ProgressStatus.ForeignKeyUpdateInProgress) {
-              this.markSchemaMigrationComplete()
-              if (this.selectedMigrationType == MigrationTypes.bulkMigration) {
-                this.hasDataMigrationStarted = true
-                localStorage.setItem(
-                  MigrationDetails.HasDataMigrationStarted,
-                  this.hasDataMigrationStarted.toString()
-                )
-              }
-              this.markForeignKeyUpdateInitiation()
-              this.dataMigrationProgress = 100
-              localStorage.setItem(
-                MigrationDetails.DataMigrationProgress,
-                this.dataMigrationProgress.toString()
-              )
-              localStorage.setItem(
-                MigrationDetails.ForeignKeyUpdateProgress,
-                res.Progress.toString()
-              )
-              this.foreignKeyUpdateProgress = parseInt(
-                localStorage.getItem(MigrationDetails.ForeignKeyUpdateProgress) as string
-              )
-              this.generatingResources = false
-              localStorage.setItem(
-                MigrationDetails.GeneratingResources,
-                this.generatingResources.toString()
-              )
-              this.fetchGeneratedResources()
-            }
-          } else {
-            this.errorMessage = res.ErrorMessage
-            this.subscription.unsubscribe()
-            this.isMigrationInProgress = !this.isMigrationInProgress
-            this.snack.openSnackBarWithoutTimeout(this.errorMessage, 'Close')
-            this.schemaProgressMessage = 'Schema migration cancelled!'
-            this.dataProgressMessage = 'Data migration cancelled!'
-            this.foreignKeyProgressMessage = 'Foreign key update cancelled!'
-            this.generatingResources = false
-            this.isLowDtMigrationRunning = false
-            this.clearLocalStorage()
-          }
-        },
+        next: (res: IProgress) => this.handleProgress(res),
         error: (err: any) => {
           this.snack.openSnackBar(err.error, 'Close')
           this.isMigrationInProgress = !this.isMigrationInProgress
@@ -803,6 +715,109 @@ export class PrepareMigrationComponent implements OnInit {
     })
   }
 
+  handleProgress(res: IProgress) {
+    if (res.ErrorMessage == '') {
+      // Checking for completion of schema migration
+      if (res.ProgressStatus == ProgressStatus.SchemaMigrationComplete) {
+        localStorage.setItem(MigrationDetails.SchemaMigrationProgress, '100')
+        this.schemaMigrationProgress = parseInt(
+          localStorage.getItem(MigrationDetails.SchemaMigrationProgress) as string
+        )
+        if (this.selectedMigrationMode == MigrationModes.schemaOnly) {
+          this.markMigrationComplete()
+        } else if (this.selectedMigrationType == MigrationTypes.lowDowntimeMigration) {
+          this.markSchemaMigrationComplete()
+          this.generatingResources = true
+          localStorage.setItem(
+            MigrationDetails.GeneratingResources,
+            this.generatingResources.toString()
+          )
+          if (!this.displayStreamingMsg) {
+            this.snack.openSnackBar('Setting up dataflow and datastream jobs', 'Close')
+            this.displayStreamingMsg = true
+          }
+        } else {
+          this.markSchemaMigrationComplete()
+          this.hasDataMigrationStarted = true
+          localStorage.setItem(
+            MigrationDetails.HasDataMigrationStarted,
+            this.hasDataMigrationStarted.toString()
+          )
+        }
+      } else if (res.ProgressStatus == ProgressStatus.DataMigrationComplete) {
+        if (this.selectedMigrationType != MigrationTypes.lowDowntimeMigration) {
+          this.hasDataMigrationStarted = true
+          localStorage.setItem(
+            MigrationDetails.HasDataMigrationStarted,
+            this.hasDataMigrationStarted.toString()
+          )
+        }
+        this.generatingResources = false
+        localStorage.setItem(
+          MigrationDetails.GeneratingResources,
+          this.generatingResources.toString()
+        )
+        this.markMigrationComplete()
+      }
+      // Checking for data migration in progress
+      else if (res.ProgressStatus == ProgressStatus.DataWriteInProgress) {
+        this.markSchemaMigrationComplete()
+        this.hasDataMigrationStarted = true
+        localStorage.setItem(
+          MigrationDetails.HasDataMigrationStarted,
+          this.hasDataMigrationStarted.toString()
+        )
+        localStorage.setItem(MigrationDetails.DataMigrationProgress, res.Progress.toString())
+        this.dataMigrationProgress = parseInt(
+          localStorage.getItem(MigrationDetails.DataMigrationProgress) as string
+        )
+      } else if (res.ProgressStatus == ProgressStatus.ForeignKeyUpdateComplete) {
+        this.markMigrationComplete()
+      }
+      // Checking for foreign key update in progress
+      else if (res.ProgressStatus == ProgressStatus.ForeignKeyUpdateInProgress) {
+        this.markSchemaMigrationComplete()
+        if (this.selectedMigrationType == MigrationTypes.bulkMigration) {
+          this.hasDataMigrationStarted = true
+          localStorage.setItem(
+            MigrationDetails.HasDataMigrationStarted,
+            this.hasDataMigrationStarted.toString()
+          )
+        }
+        this.markForeignKeyUpdateInitiation()
+        this.dataMigrationProgress = 100
+        localStorage.setItem(
+          MigrationDetails.DataMigrationProgress,
+          this.dataMigrationProgress.toString()
+        )
+        localStorage.setItem(
+          MigrationDetails.ForeignKeyUpdateProgress,
+          res.Progress.toString()
+        )
+        this.foreignKeyUpdateProgress = parseInt(
+          localStorage.getItem(MigrationDetails.ForeignKeyUpdateProgress) as string
+        )
+        this.generatingResources = false
+        localStorage.setItem(
+          MigrationDetails.GeneratingResources,
+          this.generatingResources.toString()
+        )
+        this.fetchGeneratedResources()
+      }
+    } else {
+      this.errorMessage = res.ErrorMessage
+      this.subscription.unsubscribe()
+      this.isMigrationInProgress = !this.isMigrationInProgress
+      this.snack.openSnackBarWithoutTimeout(this.errorMessage, 'Close')
+      this.schemaProgressMessage = 'Schema migration cancelled!'
+      this.dataProgressMessage = 'Data migration cancelled!'
+      this.foreignKeyProgressMessage = 'Foreign key update cancelled!'
+      this.generatingResources = false
+      this.isLowDtMigrationRunning = false
+      this.clearLocalStorage()
+    }
+  }
+
   markForeignKeyUpdateInitiation() {
     this.dataMigrationProgress = 100
     this.dataProgressMessage = 'Data migration completed successfully!'
@@ -48,6 +48,7 @@ export interface IDataflowConfig {
     projectId: string
     location: string
     gcsTemplatePath: string
+    streamingEngine: string
 }
 
 export interface IDirectConnectionConfig {
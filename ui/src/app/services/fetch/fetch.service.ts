@@ -1,5 +1,6 @@
 import { HttpClient, HttpResponse } from '@angular/common/http'
 import { Injectable } from '@angular/core'
+import { Observable } from 'rxjs'
 import IDbConfig, { IDbConfigs } from 'src/app/model/db-config'
 import ISession, { ISaveSessionPayload } from '../../model/session'
 import IUpdateTable, { IAddColumn, IReviewUpdateTable } from '../../model/update-table'
@@ -288,6 +289,17 @@ export class FetchService {
   getProgress() {
     return this.http.get<IProgress>(`${this.url}/GetProgress`)
   }
+  streamProgress(): Observable<IProgress> {
+    return new Observable<IProgress>((observer) => {
+      const source = new EventSource(`${this.url}/StreamProgress`)
+      source.onmessage = (event: MessageEvent) => observer.next(JSON.parse(event.data))
+      source.onerror = (err: Event) => {
+        source.close()
+        observer.error(err)
+      }
+      return () => source.close()
+    })
+  }
   uploadFile(payload: FormData) {
     return this.http.post(`${this.url}/uploadFile`, payload)
   }
@@ -21,6 +21,7 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/session"
 )
 
@@ -28,6 +29,10 @@ import (
 type Config struct {
 	GCPProjectID      string `json:"GCPProjectID"`
 	SpannerInstanceID string `json:"SpannerInstanceID"`
+	// UserManagedBucket is the gs:// path of an existing bucket and prefix the
+	// session files, connection configs and data of the migrations are written
+	// under. A bucket is created per migration if it is not set.
+	UserManagedBucket string `json:"UserManagedBucket,omitempty"`
 }
 
 // Config wiith metadata
@@ -63,11 +68,18 @@ func SetSpannerConfig(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Request Body parse error : %v", err), http.StatusBadRequest)
 		return
 	}
+	if c.UserManagedBucket != "" {
+		if _, _, err := utils.ParseUserManagedBucket(c.UserManagedBucket); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid user managed bucket : %v", err), http.StatusBadRequest)
+			return
+		}
+	}
 	SaveSpannerConfig(c)
+	session.GetSessionState().UserManagedBucket = c.UserManagedBucket
 	isDbCreated, isConfigValid := session.SetSessionStorageConnectionState(c.GCPProjectID, c.SpannerInstanceID)
 
 	configWithMetadata := ConfigWithMetadata{
-		Config:              Config{c.GCPProjectID, c.SpannerInstanceID},
+		Config:              c,
 		IsMetadataDbCreated: isDbCreated,
 		IsConfigValid:       isConfigValid,
 	}
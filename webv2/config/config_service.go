@@ -66,7 +66,7 @@ func TryInitializeSpannerConfig() Config {
 		spInstanceId := os.Getenv("SpannerInstanceID")
 
 		if projectId == "" || spInstanceId == "" {
-			fmt.Println("Note: To store the sessions please set the environment variables 'GCPProjectID' and 'SpannerInstanceID'. You would set these as part of the migration workflow if you are using the Spanner migration tool Web UI.")
+			fmt.Fprintln(os.Stderr, "Note: To store the sessions please set the environment variables 'GCPProjectID' and 'SpannerInstanceID'. You would set these as part of the migration workflow if you are using the Spanner migration tool Web UI.")
 		} else {
 			c.GCPProjectID = projectId
 			c.SpannerInstanceID = spInstanceId
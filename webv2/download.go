@@ -0,0 +1,72 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webv2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/artifacts"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/session"
+)
+
+// Validity of the download URLs given to the frontend, long enough to start
+// the download of a large report.
+const downloadURLTTL = 15 * time.Minute
+
+// getDownloadObject returns the bucket and name of the object of file, a path
+// relative to the directory of the last migration of the session, e.g.
+// session.json or a report. Files outside the directory of the migration
+// can't be downloaded.
+func getDownloadObject(sessionState *session.SessionState, file string) (string, string, error) {
+	if sessionState.Bucket == "" {
+		return "", "", fmt.Errorf("no migration has written files to Cloud Storage yet")
+	}
+	if file == "" || strings.HasPrefix(file, "/") || path.Clean(file) != file || strings.HasPrefix(file, "..") {
+		return "", "", fmt.Errorf("invalid file %q, it must be a path relative to the migration directory", file)
+	}
+	name := strings.TrimPrefix(sessionState.RootPath, "/") + file
+	return sessionState.Bucket, name, nil
+}
+
+// getDownloadUrl returns a signed URL downloading the file given by the file
+// query parameter directly from Cloud Storage, so that large files such as
+// the skipped rows and reports are not proxied through the server.
+func getDownloadUrl(w http.ResponseWriter, r *http.Request) {
+	sessionState := session.GetSessionState()
+	bucket, name, err := getDownloadObject(sessionState, r.FormValue("file"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	client, err := storage.NewClient(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Can't create the storage client: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer client.Close()
+	url, err := artifacts.GcsStorageAccessor{Client: client}.GenerateSignedURL(bucket, name, downloadURLTTL)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Can't generate the download URL: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"Url": url})
+}
@@ -22,7 +22,9 @@ import (
 
 	database "cloud.google.com/go/spanner/admin/database/apiv1"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/conversion"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
 	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
 )
 
@@ -39,6 +41,18 @@ const (
 
 const metadataDbName string = "spannermigrationtool_metadata"
 
+// smtTaskTableDdl creates the table in which the web UI records the status of
+// its background tasks.
+const smtTaskTableDdl string = `CREATE TABLE IF NOT EXISTS SmtTask (
+				TaskId STRING(36) NOT NULL,
+				TaskType STRING(50) NOT NULL,
+				Status STRING(20) NOT NULL,
+				Payload STRING(MAX),
+				Error STRING(MAX),
+				CreateTimestamp TIMESTAMP NOT NULL,
+				UpdateTimestamp TIMESTAMP NOT NULL,
+			  ) PRIMARY KEY(TaskId)`
+
 func GetMetadataDbName() string {
 	return metadataDbName
 }
@@ -61,7 +75,7 @@ func createDatabase(ctx context.Context, uri string) error {
 	defer adminClient.Close()
 	fmt.Println("Creating database to store session metadata...")
 
-	op, err := adminClient.CreateDatabase(ctx, &adminpb.CreateDatabaseRequest{
+	req := &adminpb.CreateDatabaseRequest{
 		Parent:          spInstance,
 		CreateStatement: "CREATE DATABASE `" + dbName + "`",
 		ExtraStatements: []string{
@@ -79,7 +93,11 @@ func createDatabase(ctx context.Context, uri string) error {
 				SchemaConversionObject JSON NOT NULL,
 				CreateTimestamp TIMESTAMP NOT NULL,
 			  ) PRIMARY KEY(VersionId)`,
+			smtTaskTableDdl,
 		},
+	}
+	op, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "CreateDatabase", Resource: uri}, func(ctx context.Context) (*database.CreateDatabaseOperation, error) {
+		return adminClient.CreateDatabase(ctx, req)
 	})
 	if err != nil {
 		return err
@@ -92,6 +110,21 @@ func createDatabase(ctx context.Context, uri string) error {
 	return nil
 }
 
+// createTaskTable adds the SmtTask table to metadata databases created before
+// the table was introduced.
+func createTaskTable(ctx context.Context, adminClient *database.DatabaseAdminClient, uri string) error {
+	op, err := spanneradmin.DoWithResult(ctx, gcp.Call{Method: "UpdateDatabaseDdl", Resource: uri}, func(ctx context.Context) (*database.UpdateDatabaseDdlOperation, error) {
+		return adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+			Database:   uri,
+			Statements: []string{smtTaskTableDdl},
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return op.Wait(ctx)
+}
+
 func CheckOrCreateMetadataDb(projectId string, instanceId string) (isExist bool, isDbCreated bool) {
 	uri := GetSpannerUri(projectId, instanceId)
 	if uri == "" {
@@ -113,6 +146,9 @@ func CheckOrCreateMetadataDb(projectId string, instanceId string) (isExist bool,
 		return
 	}
 	if dbExists {
+		if err := createTaskTable(ctx, adminClient, uri); err != nil {
+			fmt.Printf("could not create the task table in the metadata database: %v\n", err)
+		}
 		isExist = true
 		return
 	}
@@ -12,6 +12,7 @@ import (
 
 	datastream "cloud.google.com/go/datastream/apiv1"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/gcp"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/streaming"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/helpers"
@@ -30,7 +31,9 @@ func GetBucket(project, location, profileName string) (string, string, error) {
 	defer dsClient.Close()
 	// Fetch the GCS path from the destination connection profile.
 	dstProf := fmt.Sprintf("projects/%s/locations/%s/connectionProfiles/%s", project, location, profileName)
-	res, err := dsClient.GetConnectionProfile(ctx, &datastreampb.GetConnectionProfileRequest{Name: dstProf})
+	res, err := gcp.DoWithResult(ctx, gcp.Call{Service: gcp.DATASTREAM, Method: "GetConnectionProfile", Resource: dstProf, Idempotent: true}, func(ctx context.Context) (*datastreampb.ConnectionProfile, error) {
+		return dsClient.GetConnectionProfile(ctx, &datastreampb.GetConnectionProfileRequest{Name: dstProf})
+	})
 	if err != nil {
 		return "", "", fmt.Errorf("could not get connection profile: %v", err)
 	}
@@ -152,24 +155,27 @@ func CreateConnectionProfile(w http.ResponseWriter, r *http.Request) {
 		},
 		ValidateOnly: details.ValidateOnly,
 	}
-	var bucketName string
+	var bucketName, rootPath string
 	if !details.IsSource {
-
+		name := sessionState.Conv.Audit.MigrationRequestId
 		if sessionState.IsSharded {
-			bucketName = strings.ToLower(sessionState.Conv.Audit.MigrationRequestId + "-" + details.Id)
-		} else {
-			bucketName = strings.ToLower(sessionState.Conv.Audit.MigrationRequestId)
+			name = sessionState.Conv.Audit.MigrationRequestId + "-" + details.Id
+		}
+		bucketName, rootPath, err = session.GetMigrationBucket(sessionState, name)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error while getting bucket: %v", err), http.StatusBadRequest)
+			return
 		}
-		err = utils.CreateGCSBucket(bucketName, sessionState.GCPProjectID, sessionState.Region)
+		err = utils.PrepareGCSBucket(bucketName, rootPath, sessionState.GCPProjectID, sessionState.Region, sessionState.UserManagedBucket != "")
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error while creating bucket: %v", err), http.StatusBadRequest)
+			http.Error(w, fmt.Sprintf("Error while preparing bucket: %v", err), http.StatusBadRequest)
 			return
 		}
 	}
 	if sessionState.IsSharded {
-		setConnectionProfileFromRequest(details, bucketName, req, databaseType)
+		setConnectionProfileFromRequest(details, bucketName, rootPath, req, databaseType)
 	} else {
-		setConnectionProfileFromSessionState(details.IsSource, *sessionState, req, databaseType)
+		setConnectionProfileFromSessionState(details.IsSource, *sessionState, bucketName, rootPath, req, databaseType)
 	}
 
 	op, err := dsClient.CreateConnectionProfile(ctx, req)
@@ -184,7 +190,7 @@ func CreateConnectionProfile(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func setConnectionProfileFromRequest(details connectionProfileReqV2, bucketName string, req *datastreampb.CreateConnectionProfileRequest, databaseType string) error {
+func setConnectionProfileFromRequest(details connectionProfileReqV2, bucketName, rootPath string, req *datastreampb.CreateConnectionProfileRequest, databaseType string) error {
 	if details.IsSource {
 		port, _ := strconv.ParseInt((details.Port), 10, 32)
 		if databaseType == constants.MYSQL {
@@ -204,13 +210,13 @@ func setConnectionProfileFromRequest(details connectionProfileReqV2, bucketName
 		req.ConnectionProfile.Profile = &datastreampb.ConnectionProfile_GcsProfile{
 			GcsProfile: &datastreampb.GcsProfile{
 				Bucket:   bucketName,
-				RootPath: "/",
+				RootPath: rootPath,
 			},
 		}
 		return nil
 	}
 }
-func setConnectionProfileFromSessionState(isSource bool, sessionState session.SessionState, req *datastreampb.CreateConnectionProfileRequest, databaseType string) {
+func setConnectionProfileFromSessionState(isSource bool, sessionState session.SessionState, bucketName, rootPath string, req *datastreampb.CreateConnectionProfileRequest, databaseType string) {
 	if isSource {
 		port, _ := strconv.ParseInt((sessionState.SourceDBConnDetails.Port), 10, 32)
 		if databaseType == constants.MYSQL {
@@ -246,8 +252,8 @@ func setConnectionProfileFromSessionState(isSource bool, sessionState session.Se
 	} else {
 		req.ConnectionProfile.Profile = &datastreampb.ConnectionProfile_GcsProfile{
 			GcsProfile: &datastreampb.GcsProfile{
-				Bucket:   strings.ToLower(sessionState.Conv.Audit.MigrationRequestId),
-				RootPath: "/",
+				Bucket:   bucketName,
+				RootPath: rootPath,
 			},
 		}
 	}
@@ -265,6 +271,52 @@ func CleanUpStreamingJobs(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetStreamingResourceStatuses returns whether each of the resources generated
+// by the streaming migration still exists, and whether the GCS files read by
+// the dataflow jobs were changed since launch, in which case the migration is
+// flagged as potentially inconsistent.
+func GetStreamingResourceStatuses(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	sessionState := session.GetSessionState()
+	sessionState.Conv.ConvLock.RLock()
+	defer sessionState.Conv.ConvLock.RUnlock()
+	statuses := streaming.GetResourceStatuses(ctx, sessionState.Conv, sessionState.GCPProjectID, sessionState.Region)
+	artifacts, err := streaming.VerifyArtifacts(ctx, sessionState.Conv)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error while verifying the files read by the dataflow jobs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(streamingResourceStatuses{
+		Resources:    statuses,
+		Artifacts:    artifacts,
+		Inconsistent: streaming.IsInconsistent(artifacts),
+	})
+}
+
+// GetFailedRecords returns the number of records the dataflow jobs of the
+// streaming migration failed to apply to Spanner, per table and shard, with
+// sample errors, read from the dead letter queues of the jobs.
+func GetFailedRecords(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	sessionState := session.GetSessionState()
+	sessionState.Conv.ConvLock.RLock()
+	defer sessionState.Conv.ConvLock.RUnlock()
+	failedRecords, err := streaming.GetFailedRecords(ctx, sessionState.Conv)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error while reading the failed records: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(failedRecords)
+}
+
+type streamingResourceStatuses struct {
+	Resources    []streaming.ResourceStatus
+	Artifacts    []streaming.ArtifactStatus
+	Inconsistent bool
+}
+
 type connectionProfileReq struct {
 	Id           string
 	ValidateOnly bool
@@ -0,0 +1,175 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webv2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/cmd"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/profiles"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/reverse_replication/reverserepl"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/session"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/task"
+)
+
+// reverseReplicationJobData is the configuration of the reverse replication
+// pipeline of a completed migration, prefilled for the frontend to review
+// before creating the pipeline.
+type reverseReplicationJobData struct {
+	JobData reverserepl.JobData `json:"JobData"`
+	// Notes on the source shards to review, e.g. missing passwords.
+	Notes []string `json:"Notes"`
+}
+
+// getReverseReplicationJobDataForSession prefills the job data of the reverse
+// replication pipeline of the last migration of the session. The source
+// shards file is written to a local temporary file, uploaded with the
+// session file of the migration to its bucket when the pipeline is created.
+func getReverseReplicationJobDataForSession(ctx context.Context, sessionState *session.SessionState) (reverseReplicationJobData, error) {
+	targetProfile := profiles.TargetProfile{
+		Ty: profiles.TargetProfileTypeConnection,
+		Conn: profiles.TargetProfileConnection{
+			Ty: profiles.TargetProfileConnectionTypeSpanner,
+			Sp: profiles.TargetProfileConnectionSpanner{
+				Project:  sessionState.GCPProjectID,
+				Instance: sessionState.SpannerInstanceID,
+				Dbname:   sessionState.SpannerDatabaseName,
+			},
+		},
+	}
+	migrationPath := "gs://" + sessionState.Bucket + sessionState.RootPath
+	filePrefix := filepath.Join(os.TempDir(), sessionState.Conv.Audit.MigrationRequestId+"-")
+	j, notes, err := cmd.ReverseReplicationJobData(ctx, sessionState.MigrationSourceProfile, targetProfile, sessionState.SpannerDatabaseName, migrationPath+"session.json", filePrefix)
+	if err != nil {
+		return reverseReplicationJobData{}, err
+	}
+	j.DataflowRegion = sessionState.Region
+	if j.Flags == nil {
+		j.Flags = make(map[string]string)
+	}
+	j.Flags["artifactsPath"] = migrationPath + "reverse-replication"
+	return reverseReplicationJobData{JobData: j, Notes: notes}, nil
+}
+
+// getReverseReplicationJobData returns the prefilled configuration of the
+// reverse replication pipeline of the migration task with the id given by the
+// taskId query parameter, once the migration succeeded.
+func getReverseReplicationJobData(w http.ResponseWriter, r *http.Request) {
+	taskId := r.FormValue("taskId")
+	if taskId == "" {
+		http.Error(w, "taskId is required", http.StatusBadRequest)
+		return
+	}
+	t, err := taskQueue.Get(r.Context(), taskId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Can't get task: %v", err), http.StatusNotFound)
+		return
+	}
+	if t.TaskType != MIGRATE_TASK || t.Status != task.STATUS_SUCCEEDED {
+		http.Error(w, fmt.Sprintf("Reverse replication can only be set up once migration task %s succeeded", taskId), http.StatusConflict)
+		return
+	}
+	sessionState := session.GetSessionState()
+	sessionState.Conv.ConvLock.RLock()
+	defer sessionState.Conv.ConvLock.RUnlock()
+	jobData, err := getReverseReplicationJobDataForSession(r.Context(), sessionState)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Can't prefill the reverse replication configuration: %v", err), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(jobData)
+}
+
+// createReverseReplication creates the reverse replication pipeline of the
+// job data in the request body, e.g. as returned by
+// getReverseReplicationJobData, in a background task.
+func createReverseReplication(w http.ResponseWriter, r *http.Request) {
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Body Read Error : %v", err), http.StatusInternalServerError)
+		return
+	}
+	var j reverserepl.JobData
+	if err := json.Unmarshal(reqBody, &j); err != nil {
+		http.Error(w, fmt.Sprintf("Request Body parse error : %v", err), http.StatusBadRequest)
+		return
+	}
+	// The payload of the task is persisted, it must not hold the secrets of
+	// the job data.
+	payload := map[string]string{"ProjectId": j.ProjectId, "InstanceId": j.InstanceId, "DbName": j.DbName, "JobNamePrefix": j.JobNamePrefix}
+	t, err := taskQueue.Submit(r.Context(), REVERSE_REPLICATION_TASK, payload, func(ctx context.Context) error {
+		return reverserepl.CreateWorkflow(ctx, j)
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Can't create the reverse replication pipeline: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"TaskId": t.TaskId})
+	log.Println("reverse replication task submitted", "taskid", t.TaskId)
+}
+
+// reverseReplicationSummary is a reverse replication pipeline listed by
+// listReverseReplications.
+type reverseReplicationSummary struct {
+	JobNamePrefix string
+	Active        bool
+	Tags          []string
+	Jobs          []reverseReplicationJobStatus
+}
+
+type reverseReplicationJobStatus struct {
+	Name  string
+	JobId string
+	State string
+}
+
+// listReverseReplications lists the reverse replication pipelines of the
+// project and region query parameters, defaulting to the ones of the session.
+// The repeatable tag query parameter only keeps the pipelines with all the
+// given tags, e.g. tag=env=prod&tag=app.
+func listReverseReplications(w http.ResponseWriter, r *http.Request) {
+	sessionState := session.GetSessionState()
+	project, region := r.FormValue("project"), r.FormValue("region")
+	if project == "" {
+		project = sessionState.GCPProjectID
+	}
+	if region == "" {
+		region = sessionState.Region
+	}
+	summaries, err := reverserepl.ListWorkflows(r.Context(), project, region, r.Form["tag"]...)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Can't list the reverse replication pipelines: %v", err), http.StatusInternalServerError)
+		return
+	}
+	res := []reverseReplicationSummary{}
+	for _, s := range summaries {
+		summary := reverseReplicationSummary{JobNamePrefix: s.JobNamePrefix, Active: s.Active, Tags: s.Tags}
+		for _, job := range s.Jobs {
+			summary.Jobs = append(summary.Jobs, reverseReplicationJobStatus{Name: job.Name, JobId: job.JobId, State: job.State.String()})
+		}
+		res = append(res, summary)
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
@@ -18,6 +18,7 @@ import (
 	"io/fs"
 	"net/http"
 
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/metrics"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/config"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/primarykey"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/profile"
@@ -91,11 +92,17 @@ func getRoutes() *mux.Router {
 
 	// Run migration
 	router.HandleFunc("/Migrate", migrate).Methods("POST")
+	router.HandleFunc("/GetTaskStatus", getTaskStatus).Methods("GET")
 
 	router.HandleFunc("/GetSourceDestinationSummary", getSourceDestinationSummary).Methods("GET")
 	router.HandleFunc("/GetProgress", updateProgress).Methods("GET")
+	router.HandleFunc("/StreamProgress", streamProgress).Methods("GET")
 	router.HandleFunc("/GetLatestSessionDetails", fetchLastLoadedSessionDetails).Methods("GET")
 	router.HandleFunc("/GetGeneratedResources", getGeneratedResources).Methods("GET")
+	router.HandleFunc("/GetReverseReplicationJobData", getReverseReplicationJobData).Methods("GET")
+	router.HandleFunc("/CreateReverseReplication", createReverseReplication).Methods("POST")
+	router.HandleFunc("/ListReverseReplications", listReverseReplications).Methods("GET")
+	router.HandleFunc("/GetDownloadUrl", getDownloadUrl).Methods("GET")
 
 	// Connection profiles
 	router.HandleFunc("/GetConnectionProfiles", profile.ListConnectionProfiles).Methods("GET")
@@ -104,6 +111,8 @@ func getRoutes() *mux.Router {
 
 	// Clean up datastream and data flow jobs
 	router.HandleFunc("/CleanUpStreamingJobs", profile.CleanUpStreamingJobs).Methods("POST")
+	router.HandleFunc("/GetStreamingResourceStatuses", profile.GetStreamingResourceStatuses).Methods("GET")
+	router.HandleFunc("/GetFailedRecords", profile.GetFailedRecords).Methods("GET")
 
 	router.HandleFunc("/SetSourceDBDetailsForDump", setSourceDBDetailsForDump).Methods("POST")
 	router.HandleFunc("/SetSourceDBDetailsForDirectConnect", setSourceDBDetailsForDirectConnect).Methods("POST")
@@ -116,6 +125,7 @@ func getRoutes() *mux.Router {
 
 	router.HandleFunc("/GetTableWithErrors", getTableWithErrors).Methods("GET")
 	router.HandleFunc("/ping", getBackendHealth).Methods("GET")
+	router.Handle("/metrics", metrics.PrometheusHandler()).Methods("GET")
 
 	router.PathPrefix("/").Handler(frontendStatic)
 	return router
@@ -18,8 +18,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"cloud.google.com/go/spanner"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/metrics"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/proto/migration"
 	"google.golang.org/api/iterator"
@@ -36,6 +38,7 @@ func NewRemoteSessionStore(spannerClient *spanner.Client) SessionStore {
 }
 
 func (st *spannerStore) GetSessionsMetadata(ctx context.Context) ([]SchemaConversionSession, error) {
+	defer metrics.ObserveMetadataDbOperation("GetSessionsMetadata", time.Now())
 	txn := st.spannerClient.ReadOnlyTransaction()
 	defer txn.Close()
 
@@ -74,6 +77,7 @@ func (st *spannerStore) GetSessionsMetadata(ctx context.Context) ([]SchemaConver
 }
 
 func (st *spannerStore) GetConvWithMetadata(ctx context.Context, versionId string) (ConvWithMetadata, error) {
+	defer metrics.ObserveMetadataDbOperation("GetConvWithMetadata", time.Now())
 	txn := st.spannerClient.ReadOnlyTransaction()
 	defer txn.Close()
 
@@ -130,6 +134,7 @@ func (st *spannerStore) GetConvWithMetadata(ctx context.Context, versionId strin
 }
 
 func (st *spannerStore) SaveSession(ctx context.Context, scs SchemaConversionSession) error {
+	defer metrics.ObserveMetadataDbOperation("SaveSession", time.Now())
 	_, err := st.spannerClient.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
 		mutation, err := spanner.InsertStruct("SchemaConversionSession", scs)
 		if err != nil {
@@ -145,6 +150,7 @@ func (st *spannerStore) SaveSession(ctx context.Context, scs SchemaConversionSes
 }
 
 func (st *spannerStore) IsSessionNameUnique(ctx context.Context, scs SchemaConversionSession) (bool, error) {
+	defer metrics.ObserveMetadataDbOperation("IsSessionNameUnique", time.Now())
 	txn := st.spannerClient.ReadOnlyTransaction()
 	defer txn.Close()
 
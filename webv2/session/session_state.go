@@ -1,7 +1,11 @@
 package session
 
 import (
+	"strings"
 	"sync"
+
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/settings"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
 )
 
 var once sync.Once
@@ -20,3 +24,20 @@ func GetSessionState() *SessionState {
 	}
 	return sessionState
 }
+
+// GetMigrationBucket returns the bucket and root path the files of the
+// migration identified by name are written to. Without a user managed bucket,
+// every migration gets a bucket of its own, named after the migration with the
+// bucketNamePrefix setting prepended. Otherwise the files are written to
+// a directory named after the migration under the user managed bucket.
+func GetMigrationBucket(ss *SessionState, name string) (string, string, error) {
+	name = strings.ToLower(name)
+	if ss.UserManagedBucket == "" {
+		return settings.Get(settings.BUCKET_NAME_PREFIX) + name, "/", nil
+	}
+	bucket, rootPath, err := utils.ParseUserManagedBucket(ss.UserManagedBucket)
+	if err != nil {
+		return "", "", err
+	}
+	return bucket, rootPath + name + "/", nil
+}
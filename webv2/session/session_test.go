@@ -289,6 +289,29 @@ func TestGetSessionState(t *testing.T) {
 		t.Errorf("Expected GetSessionState to return the same SessionState instance, but got different instances")
 	}
 }
+
+func TestGetMigrationBucket(t *testing.T) {
+	testCases := []struct {
+		name              string
+		userManagedBucket string
+		expectedBucket    string
+		expectedRootPath  string
+		expectError       bool
+	}{
+		{name: "per migration bucket", expectedBucket: "smt-abc", expectedRootPath: "/"},
+		{name: "user managed bucket", userManagedBucket: "gs://my-bucket", expectedBucket: "my-bucket", expectedRootPath: "/smt-abc/"},
+		{name: "user managed bucket with prefix", userManagedBucket: "gs://my-bucket/migrations", expectedBucket: "my-bucket", expectedRootPath: "/migrations/smt-abc/"},
+		{name: "invalid user managed bucket", userManagedBucket: "my-bucket/migrations", expectError: true},
+	}
+	for _, tc := range testCases {
+		ss := &session.SessionState{UserManagedBucket: tc.userManagedBucket}
+		bucket, rootPath, err := session.GetMigrationBucket(ss, "SMT-abc")
+		assert.Equal(t, tc.expectError, err != nil, tc.name)
+		assert.Equal(t, tc.expectedBucket, bucket, tc.name)
+		assert.Equal(t, tc.expectedRootPath, rootPath, tc.name)
+	}
+}
+
 func TestReadSessionFileForSessionMetadata(t *testing.T) {
 	expectedMetadata := &session.SessionMetadata{
 		DatabaseName: "mydb",
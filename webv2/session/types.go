@@ -57,10 +57,12 @@ type SessionState struct {
 	TmpDir string
 	ShardedDbConnDetails []profiles.DirectConnectionConfig
 	SourceProfileConfig profiles.SourceProfileConfig
+	MigrationSourceProfile profiles.SourceProfile // Source profile of the last migration started
 	Region              string
 	SpannerDatabaseName string
 	Bucket              string
 	RootPath            string
+	UserManagedBucket   string // gs:// path of an existing bucket to write the migration files under, instead of creating a bucket per migration
 	SessionMetadata     SessionMetadata
 	Error               error
 	Counter
@@ -0,0 +1,224 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Task ids supplied by the caller of SubmitWithId.
+var taskIdRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// RunFunc executes a task. A non nil error marks the task as failed.
+type RunFunc func(ctx context.Context) error
+
+type queuedTask struct {
+	task Task
+	run  RunFunc
+}
+
+// Queue executes submitted tasks on a fixed number of worker goroutines and
+// records their status in a TaskStore.
+type Queue struct {
+	store   TaskStore
+	workers int
+	tasks   chan queuedTask
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	closed  bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewQueue returns a queue running up to workers tasks at a time, which
+// accepts up to size tasks waiting for a worker.
+func NewQueue(store TaskStore, workers int, size int) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		store:   store,
+		workers: workers,
+		tasks:   make(chan queuedTask, size),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start marks the tasks which were left queued or running by a previous
+// process as failed, since the state they were submitted with is gone, and
+// starts the workers.
+func (q *Queue) Start(ctx context.Context) error {
+	stale, err := q.store.GetTasksByStatus(ctx, STATUS_QUEUED, STATUS_RUNNING)
+	if err != nil {
+		return fmt.Errorf("can't read unfinished tasks: %v", err)
+	}
+	for _, t := range stale {
+		t.Status = STATUS_FAILED
+		t.Error = "interrupted by a restart of the server"
+		t.UpdateTimestamp = time.Now()
+		if err := q.store.SaveTask(ctx, t); err != nil {
+			return fmt.Errorf("can't update unfinished task %s: %v", t.TaskId, err)
+		}
+	}
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return nil
+}
+
+// Submit records a task of type taskType with the given payload and queues
+// run for execution. The returned task has status QUEUED.
+func (q *Queue) Submit(ctx context.Context, taskType string, payload interface{}, run RunFunc) (Task, error) {
+	t, _, err := q.SubmitWithId(ctx, "", taskType, payload, run)
+	return t, err
+}
+
+// SubmitWithId is like Submit, with the task id chosen by the caller so that
+// submitting is safe to retry. If a task of type taskType with id taskId was
+// already submitted, run is not queued and the existing task is returned with
+// created set to false. An empty taskId submits the task under a new id.
+func (q *Queue) SubmitWithId(ctx context.Context, taskId, taskType string, payload interface{}, run RunFunc) (t Task, created bool, err error) {
+	if taskId == "" {
+		taskId = uuid.New().String()
+	} else if !taskIdRegex.MatchString(taskId) {
+		return Task{}, false, fmt.Errorf("invalid task id %q, only up to 64 letters, digits, '-' and '_' are allowed", taskId)
+	}
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		return Task{}, false, fmt.Errorf("can't serialize task payload: %v", err)
+	}
+	now := time.Now()
+	t = Task{
+		TaskId:          taskId,
+		TaskType:        taskType,
+		Status:          STATUS_QUEUED,
+		Payload:         string(bytes),
+		CreateTimestamp: now,
+		UpdateTimestamp: now,
+	}
+
+	// The lock also keeps two submissions of the same task id from both
+	// creating the task.
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	existing, err := q.store.GetTask(ctx, taskId)
+	if err == nil {
+		if existing.TaskType != taskType {
+			return Task{}, false, fmt.Errorf("task id %s is already used by a task of type %s", taskId, existing.TaskType)
+		}
+		return existing, false, nil
+	}
+	if !errors.Is(err, ErrTaskNotFound) {
+		return Task{}, false, fmt.Errorf("can't look up task %s: %v", taskId, err)
+	}
+	if q.closed {
+		return Task{}, false, fmt.Errorf("the server is shutting down")
+	}
+	if len(q.tasks) == cap(q.tasks) {
+		return Task{}, false, fmt.Errorf("too many queued tasks, try again later")
+	}
+	if err := q.store.SaveTask(ctx, t); err != nil {
+		return Task{}, false, fmt.Errorf("can't save task: %v", err)
+	}
+	q.tasks <- queuedTask{task: t, run: run}
+	return t, true, nil
+}
+
+// Get returns the task with id taskId.
+func (q *Queue) Get(ctx context.Context, taskId string) (Task, error) {
+	return q.store.GetTask(ctx, taskId)
+}
+
+// Shutdown stops accepting tasks and waits for the queued and running tasks
+// to finish. If ctx is done first, the context of the running tasks is
+// cancelled and ctx.Err() is returned once they return.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.tasks)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for qt := range q.tasks {
+		q.execute(qt)
+	}
+}
+
+func (q *Queue) execute(qt queuedTask) {
+	t := qt.task
+	if q.ctx.Err() != nil {
+		q.finish(t, q.ctx.Err())
+		return
+	}
+	t.Status = STATUS_RUNNING
+	t.UpdateTimestamp = time.Now()
+	q.save(t)
+
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("task panicked: %v", r)
+			}
+		}()
+		err = qt.run(q.ctx)
+	}()
+	q.finish(t, err)
+}
+
+func (q *Queue) finish(t Task, err error) {
+	t.Status = STATUS_SUCCEEDED
+	if err != nil {
+		t.Status = STATUS_FAILED
+		t.Error = err.Error()
+	}
+	t.UpdateTimestamp = time.Now()
+	q.save(t)
+}
+
+// save records the status of a task. The tasks keep running if the store is
+// unavailable, the status is then only logged.
+func (q *Queue) save(t Task) {
+	if err := q.store.SaveTask(context.Background(), t); err != nil {
+		fmt.Printf("can't save status %s of task %s: %v\n", t.Status, t.TaskId, err)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func waitForTask(t *testing.T, q *Queue, taskId string) Task {
+	for i := 0; i < 100; i++ {
+		task, err := q.Get(context.Background(), taskId)
+		assert.Nil(t, err)
+		if task.IsDone() {
+			return task
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("task %s didn't finish", taskId)
+	return Task{}
+}
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+	q := NewQueue(NewLocalTaskStore(), 1, 4)
+	assert.Nil(t, q.Start(ctx))
+
+	ok, err := q.Submit(ctx, "test", map[string]string{"key": "value"}, func(ctx context.Context) error { return nil })
+	assert.Nil(t, err)
+	assert.Equal(t, STATUS_QUEUED, ok.Status)
+	assert.Equal(t, `{"key":"value"}`, ok.Payload)
+	failed, err := q.Submit(ctx, "test", nil, func(ctx context.Context) error { return fmt.Errorf("failure") })
+	assert.Nil(t, err)
+	panicked, err := q.Submit(ctx, "test", nil, func(ctx context.Context) error { panic("oops") })
+	assert.Nil(t, err)
+
+	assert.Equal(t, STATUS_SUCCEEDED, waitForTask(t, q, ok.TaskId).Status)
+	failedTask := waitForTask(t, q, failed.TaskId)
+	assert.Equal(t, STATUS_FAILED, failedTask.Status)
+	assert.Equal(t, "failure", failedTask.Error)
+	panickedTask := waitForTask(t, q, panicked.TaskId)
+	assert.Equal(t, STATUS_FAILED, panickedTask.Status)
+	assert.Equal(t, "task panicked: oops", panickedTask.Error)
+
+	_, err = q.Get(ctx, "unknown")
+	assert.NotNil(t, err)
+	assert.Nil(t, q.Shutdown(ctx))
+}
+
+func TestQueueSubmitWithId(t *testing.T) {
+	ctx := context.Background()
+	q := NewQueue(NewLocalTaskStore(), 1, 4)
+	assert.Nil(t, q.Start(ctx))
+
+	runs := 0
+	run := func(ctx context.Context) error {
+		runs++
+		return nil
+	}
+	first, created, err := q.SubmitWithId(ctx, "job-1", "test", nil, run)
+	assert.Nil(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "job-1", first.TaskId)
+	assert.Equal(t, STATUS_SUCCEEDED, waitForTask(t, q, "job-1").Status)
+
+	// Resubmitting returns the existing task without running it again.
+	retried, created, err := q.SubmitWithId(ctx, "job-1", "test", nil, run)
+	assert.Nil(t, err)
+	assert.False(t, created)
+	assert.Equal(t, STATUS_SUCCEEDED, retried.Status)
+
+	_, _, err = q.SubmitWithId(ctx, "job-1", "other", nil, run)
+	assert.NotNil(t, err)
+	_, _, err = q.SubmitWithId(ctx, "job/1", "test", nil, run)
+	assert.NotNil(t, err)
+
+	_, err = q.Get(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+	assert.Nil(t, q.Shutdown(ctx))
+	assert.Equal(t, 1, runs)
+}
+
+func TestQueueShutdownDrainsTasks(t *testing.T) {
+	ctx := context.Background()
+	q := NewQueue(NewLocalTaskStore(), 1, 4)
+	assert.Nil(t, q.Start(ctx))
+	release := make(chan struct{})
+	first, err := q.Submit(ctx, "test", nil, func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	assert.Nil(t, err)
+	second, err := q.Submit(ctx, "test", nil, func(ctx context.Context) error { return nil })
+	assert.Nil(t, err)
+
+	shutdown := make(chan error)
+	go func() { shutdown <- q.Shutdown(ctx) }()
+	time.Sleep(20 * time.Millisecond)
+	_, err = q.Submit(ctx, "test", nil, func(ctx context.Context) error { return nil })
+	assert.NotNil(t, err)
+
+	close(release)
+	assert.Nil(t, <-shutdown)
+	for _, id := range []string{first.TaskId, second.TaskId} {
+		task, err := q.Get(ctx, id)
+		assert.Nil(t, err)
+		assert.Equal(t, STATUS_SUCCEEDED, task.Status)
+	}
+}
+
+func TestQueueShutdownTimeout(t *testing.T) {
+	q := NewQueue(NewLocalTaskStore(), 1, 4)
+	assert.Nil(t, q.Start(context.Background()))
+	running, err := q.Submit(context.Background(), "test", nil, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.Nil(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, q.Shutdown(ctx))
+	task, err := q.Get(context.Background(), running.TaskId)
+	assert.Nil(t, err)
+	assert.Equal(t, STATUS_FAILED, task.Status)
+}
+
+func TestQueueStartFailsUnfinishedTasks(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocalTaskStore()
+	now := time.Now()
+	for _, task := range []Task{
+		{TaskId: "queued", Status: STATUS_QUEUED, CreateTimestamp: now},
+		{TaskId: "running", Status: STATUS_RUNNING, CreateTimestamp: now},
+		{TaskId: "succeeded", Status: STATUS_SUCCEEDED, CreateTimestamp: now},
+	} {
+		assert.Nil(t, store.SaveTask(ctx, task))
+	}
+	q := NewQueue(store, 1, 4)
+	assert.Nil(t, q.Start(ctx))
+	defer q.Shutdown(ctx)
+
+	expected := map[string]string{"queued": STATUS_FAILED, "running": STATUS_FAILED, "succeeded": STATUS_SUCCEEDED}
+	for id, status := range expected {
+		task, err := q.Get(ctx, id)
+		assert.Nil(t, err)
+		assert.Equal(t, status, task.Status, id)
+	}
+}
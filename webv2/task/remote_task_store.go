@@ -0,0 +1,98 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/metrics"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+)
+
+type spannerTaskStore struct {
+	spannerClient *spanner.Client
+}
+
+var _ TaskStore = (*spannerTaskStore)(nil)
+
+// NewRemoteTaskStore returns a store keeping the tasks in the SmtTask table
+// of the metadata database.
+func NewRemoteTaskStore(spannerClient *spanner.Client) TaskStore {
+	return &spannerTaskStore{spannerClient: spannerClient}
+}
+
+func (st *spannerTaskStore) SaveTask(ctx context.Context, t Task) error {
+	defer metrics.ObserveMetadataDbOperation("SaveTask", time.Now())
+	mutation, err := spanner.InsertOrUpdateStruct("SmtTask", t)
+	if err != nil {
+		return err
+	}
+	_, err = st.spannerClient.Apply(ctx, []*spanner.Mutation{mutation})
+	return err
+}
+
+func (st *spannerTaskStore) GetTask(ctx context.Context, taskId string) (Task, error) {
+	defer metrics.ObserveMetadataDbOperation("GetTask", time.Now())
+	var t Task
+	row, err := st.spannerClient.Single().ReadRow(ctx, "SmtTask", spanner.Key{taskId}, []string{"TaskId", "TaskType", "Status", "Payload", "Error", "CreateTimestamp", "UpdateTimestamp"})
+	if spanner.ErrCode(err) == codes.NotFound {
+		return t, fmt.Errorf("no task found with id %s: %w", taskId, ErrTaskNotFound)
+	}
+	if err != nil {
+		return t, err
+	}
+	err = row.ToStruct(&t)
+	return t, err
+}
+
+func (st *spannerTaskStore) GetTasksByStatus(ctx context.Context, statuses ...string) ([]Task, error) {
+	defer metrics.ObserveMetadataDbOperation("GetTasksByStatus", time.Now())
+	query := spanner.Statement{
+		SQL: `SELECT
+				TaskId,
+				TaskType,
+				Status,
+				Payload,
+				Error,
+				CreateTimestamp,
+				UpdateTimestamp
+			FROM SmtTask
+			WHERE Status IN UNNEST(@statuses)
+			ORDER BY CreateTimestamp`,
+		Params: map[string]interface{}{"statuses": statuses},
+	}
+	iter := st.spannerClient.Single().Query(ctx, query)
+	defer iter.Stop()
+	result := []Task{}
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var t Task
+		if err := row.ToStruct(&t); err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package task runs long running web requests, such as migrations, in the
+// background. Tasks are recorded in a TaskStore so that their status can be
+// looked up while and after they run.
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	STATUS_QUEUED    = "QUEUED"
+	STATUS_RUNNING   = "RUNNING"
+	STATUS_SUCCEEDED = "SUCCEEDED"
+	STATUS_FAILED    = "FAILED"
+)
+
+// ErrTaskNotFound is returned by the TaskStore when no task has the requested
+// id.
+var ErrTaskNotFound = errors.New("task not found")
+
+// Task is a unit of work submitted to the queue.
+type Task struct {
+	TaskId          string
+	TaskType        string
+	Status          string
+	Payload         string
+	Error           string
+	CreateTimestamp time.Time
+	UpdateTimestamp time.Time
+}
+
+// IsDone returns true if the task finished, successfully or not.
+func (t Task) IsDone() bool {
+	return t.Status == STATUS_SUCCEEDED || t.Status == STATUS_FAILED
+}
+
+// TaskStore persists tasks and their status.
+type TaskStore interface {
+	SaveTask(ctx context.Context, t Task) error
+	GetTask(ctx context.Context, taskId string) (Task, error)
+	GetTasksByStatus(ctx context.Context, statuses ...string) ([]Task, error)
+}
+
+type localTaskStore struct {
+	mu    sync.Mutex
+	tasks map[string]Task
+}
+
+var _ TaskStore = (*localTaskStore)(nil)
+
+// NewLocalTaskStore returns a store keeping the tasks in memory, for when the
+// metadata database is not available.
+func NewLocalTaskStore() TaskStore {
+	return &localTaskStore{tasks: make(map[string]Task)}
+}
+
+func (st *localTaskStore) SaveTask(ctx context.Context, t Task) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.tasks[t.TaskId] = t
+	return nil
+}
+
+func (st *localTaskStore) GetTask(ctx context.Context, taskId string) (Task, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	t, ok := st.tasks[taskId]
+	if !ok {
+		return t, fmt.Errorf("no task found with id %s: %w", taskId, ErrTaskNotFound)
+	}
+	return t, nil
+}
+
+func (st *localTaskStore) GetTasksByStatus(ctx context.Context, statuses ...string) ([]Task, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var result []Task
+	for _, t := range st.tasks {
+		for _, s := range statuses {
+			if t.Status == s {
+				result = append(result, t)
+				break
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreateTimestamp.Before(result[j].CreateTimestamp) })
+	return result, nil
+}
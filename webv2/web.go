@@ -23,21 +23,27 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"cloud.google.com/go/spanner"
 	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/cmd"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/tracing"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/utils"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/conversion"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
@@ -51,16 +57,17 @@ import (
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/oracle"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/postgres"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/sources/sqlserver"
+	spanneradmin "github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/admin"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/ddl"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/streaming"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/config"
 	helpers "github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/helpers"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/profile"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/table"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/task"
 	utilities "github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/utilities"
 	"github.com/google/uuid"
 	"github.com/pkg/browser"
-	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
 
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/webv2/session"
 
@@ -143,6 +150,28 @@ type sessionSummary struct {
 	IsSharded          bool
 }
 
+// progressStreamInterval is the interval at which the progress streamed to
+// the frontend is checked for changes.
+var progressStreamInterval = 500 * time.Millisecond
+
+const (
+	// MIGRATE_TASK is the type of the background tasks running migrations.
+	MIGRATE_TASK = "migrate"
+	// REVERSE_REPLICATION_TASK is the type of the background tasks creating
+	// reverse replication pipelines.
+	REVERSE_REPLICATION_TASK = "reverse-replication"
+	// Number of background tasks which run at the same time, and which can
+	// wait for a worker.
+	taskWorkers   = 2
+	taskQueueSize = 16
+	// shutdownTimeout bounds how long a shutdown waits for running tasks.
+	shutdownTimeout = 5 * time.Minute
+)
+
+// taskQueue runs the long running requests in the background, it is started
+// by App.
+var taskQueue *task.Queue
+
 type progressDetails struct {
 	Progress       int
 	ErrorMessage   string
@@ -157,6 +186,10 @@ type migrationDetails struct {
 	MigrationType    string                    `json:"MigrationType"`
 	IsSharded        bool                      `json:"IsSharded"`
 	SkipForeignKeys  bool                      `json:"skipForeignKeys"`
+	// Optional id of the migration task, which makes retrying the request
+	// safe: a migration already submitted with the same id is not started
+	// again.
+	TaskId string `json:"TaskId"`
 }
 
 type targetDetails struct {
@@ -431,6 +464,7 @@ func setDataflowDetailsForShardedMigrations(w http.ResponseWriter, r *http.Reque
 		AdditionalUserLabels: dataflowConfigUnmarshaler.DataflowConfig.AdditionalUserLabels,
 		KmsKeyName:           dataflowConfigUnmarshaler.DataflowConfig.KmsKeyName,
 		GcsTemplatePath:      dataflowConfigUnmarshaler.DataflowConfig.GcsTemplatePath,
+		StreamingEngine:      dataflowConfigUnmarshaler.DataflowConfig.StreamingEngine,
 	}
 	w.WriteHeader(http.StatusOK)
 }
@@ -2188,29 +2222,19 @@ func getSourceDestinationSummary(w http.ResponseWriter, r *http.Request) {
 	sessionSummary.SourceIndexCount = sourceIndexCount
 	sessionSummary.SpannerIndexCount = spannerIndexCount
 	ctx := context.Background()
-	instanceClient, err := instance.NewInstanceAdminClient(ctx)
+	instanceInfoCache, err := getInstanceInfoCache(ctx)
 	if err != nil {
 		log.Println("instance admin client creation error")
 		http.Error(w, fmt.Sprintf("Error while creating instance admin client : %v", err), http.StatusBadRequest)
 		return
 	}
-	instanceInfo, err := instanceClient.GetInstance(ctx, &instancepb.GetInstanceRequest{Name: fmt.Sprintf("projects/%s/instances/%s", sessionState.GCPProjectID, sessionState.SpannerInstanceID)})
+	instanceInfo, err := instanceInfoCache.GetInstanceInfo(ctx, fmt.Sprintf("projects/%s/instances/%s", sessionState.GCPProjectID, sessionState.SpannerInstanceID))
 	if err != nil {
 		log.Println("get instance error")
 		http.Error(w, fmt.Sprintf("Error while getting instance information : %v", err), http.StatusBadRequest)
 		return
 	}
-	instanceConfig, err := instanceClient.GetInstanceConfig(ctx, &instancepb.GetInstanceConfigRequest{Name: instanceInfo.Config})
-	if err != nil {
-		log.Println("get instance config error")
-		http.Error(w, fmt.Sprintf("Error while getting instance config : %v", err), http.StatusBadRequest)
-		return
-	}
-	for _, replica := range instanceConfig.Replicas {
-		if replica.DefaultLeaderLocation {
-			sessionSummary.Region = replica.Location
-		}
-	}
+	sessionSummary.Region = instanceInfo.LeaderLocation
 	sessionState.Region = sessionSummary.Region
 	sessionSummary.NodeCount = int(instanceInfo.NodeCount)
 	sessionSummary.ProcessingUnits = int(instanceInfo.ProcessingUnits)
@@ -2221,8 +2245,28 @@ func getSourceDestinationSummary(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(sessionSummary)
 }
 
-func updateProgress(w http.ResponseWriter, r *http.Request) {
+var (
+	instanceInfoCacheMu sync.Mutex
+	instanceInfoCache   *spanneradmin.InstanceInfoCache
+)
 
+// getInstanceInfoCache returns the cache of the instance lookups made by the
+// UI, creating its instance admin client on first use.
+func getInstanceInfoCache(ctx context.Context) (*spanneradmin.InstanceInfoCache, error) {
+	instanceInfoCacheMu.Lock()
+	defer instanceInfoCacheMu.Unlock()
+	if instanceInfoCache == nil {
+		instanceClient, err := instance.NewInstanceAdminClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		instanceInfoCache = spanneradmin.NewInstanceInfoCache(instanceClient, spanneradmin.DEFAULT_INSTANCE_INFO_TTL)
+	}
+	return instanceInfoCache, nil
+}
+
+// getProgressDetails returns the progress of the running migration.
+func getProgressDetails() progressDetails {
 	var detail progressDetails
 	sessionState := session.GetSessionState()
 	sessionState.Conv.ConvLock.RLock()
@@ -2233,10 +2277,52 @@ func updateProgress(w http.ResponseWriter, r *http.Request) {
 		detail.ErrorMessage = ""
 		detail.Progress, detail.ProgressStatus = sessionState.Conv.Audit.Progress.ReportProgress()
 	}
+	return detail
+}
+
+func updateProgress(w http.ResponseWriter, r *http.Request) {
+	detail := getProgressDetails()
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(detail)
 }
 
+// streamProgress streams the progress of the running migration as server-sent
+// events, so that the frontend gets live updates without polling. An event is
+// sent when the client connects and whenever the progress changes, until the
+// client disconnects.
+func streamProgress(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	ticker := time.NewTicker(progressStreamInterval)
+	defer ticker.Stop()
+	var last progressDetails
+	sent := false
+	for {
+		detail := getProgressDetails()
+		if !sent || detail != last {
+			data, err := json.Marshal(detail)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+			last, sent = detail, true
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func migrate(w http.ResponseWriter, r *http.Request) {
 
 	log.Println("request started", "method", r.Method, "path", r.URL.Path)
@@ -2253,9 +2339,18 @@ func migrate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Request Body parse error : %v", err), http.StatusBadRequest)
 		return
 	}
+	ctx := context.Background()
+	if details.TaskId != "" {
+		if t, err := taskQueue.Get(ctx, details.TaskId); err == nil {
+			writeExistingMigrationTask(w, t)
+			return
+		} else if !errors.Is(err, task.ErrTaskNotFound) {
+			http.Error(w, fmt.Sprintf("Can't look up task %s: %v", details.TaskId, err), http.StatusInternalServerError)
+			return
+		}
+	}
 	sessionState := session.GetSessionState()
 	sessionState.Error = nil
-	ctx := context.Background()
 	sessionState.Conv.Audit.Progress = internal.Progress{}
 	sourceProfile, targetProfile, ioHelper, dbName, err := getSourceAndTargetProfiles(sessionState, details)
 	if err != nil {
@@ -2263,6 +2358,7 @@ func migrate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Can't get source and target profiles: %v", err), http.StatusBadRequest)
 		return
 	}
+	sessionState.MigrationSourceProfile = sourceProfile
 	err = writeSessionFile(sessionState)
 	if err != nil {
 		log.Println("can't write session file")
@@ -2273,29 +2369,72 @@ func migrate(w http.ResponseWriter, r *http.Request) {
 	sessionState.Conv.Audit.Progress = internal.Progress{}
 	// Set env variable SKIP_METRICS_POPULATION to true in case of dev testing
 	sessionState.Conv.Audit.SkipMetricsPopulation = os.Getenv("SKIP_METRICS_POPULATION") == "true"
+	var migrationCmd interface{}
 	if details.MigrationMode == helpers.SCHEMA_ONLY {
 		log.Println("Starting schema only migration")
 		sessionState.Conv.Audit.MigrationType = migration.MigrationData_SCHEMA_ONLY.Enum()
-		go cmd.MigrateDatabase(ctx, targetProfile, sourceProfile, dbName, &ioHelper, &cmd.SchemaCmd{}, sessionState.Conv, &sessionState.Error)
+		migrationCmd = &cmd.SchemaCmd{}
 	} else if details.MigrationMode == helpers.DATA_ONLY {
-		dataCmd := &cmd.DataCmd{
-			SkipForeignKeys: details.SkipForeignKeys,
-			WriteLimit:      cmd.DefaultWritersLimit,
-		}
 		log.Println("Starting data only migration")
 		sessionState.Conv.Audit.MigrationType = migration.MigrationData_DATA_ONLY.Enum()
-		go cmd.MigrateDatabase(ctx, targetProfile, sourceProfile, dbName, &ioHelper, dataCmd, sessionState.Conv, &sessionState.Error)
-	} else {
-		schemaAndDataCmd := &cmd.SchemaAndDataCmd{
+		migrationCmd = &cmd.DataCmd{
 			SkipForeignKeys: details.SkipForeignKeys,
 			WriteLimit:      cmd.DefaultWritersLimit,
 		}
+	} else {
 		log.Println("Starting schema and data migration")
 		sessionState.Conv.Audit.MigrationType = migration.MigrationData_SCHEMA_AND_DATA.Enum()
-		go cmd.MigrateDatabase(ctx, targetProfile, sourceProfile, dbName, &ioHelper, schemaAndDataCmd, sessionState.Conv, &sessionState.Error)
+		migrationCmd = &cmd.SchemaAndDataCmd{
+			SkipForeignKeys: details.SkipForeignKeys,
+			WriteLimit:      cmd.DefaultWritersLimit,
+		}
+	}
+	t, created, err := taskQueue.SubmitWithId(ctx, details.TaskId, MIGRATE_TASK, details, func(ctx context.Context) error {
+		ctx = logger.WithMigration(ctx, sessionState.Conv.Audit.MigrationRequestId, MIGRATE_TASK)
+		_, err := cmd.MigrateDatabase(ctx, targetProfile, sourceProfile, dbName, &ioHelper, migrationCmd, sessionState.Conv, &sessionState.Error)
+		return err
+	})
+	if err != nil {
+		log.Println("can't submit migration task")
+		http.Error(w, fmt.Sprintf("Can't start migration: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+	if !created {
+		writeExistingMigrationTask(w, t)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"TaskId": t.TaskId})
+	log.Println("migration task submitted", "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr, "taskid", t.TaskId)
+}
+
+// writeExistingMigrationTask responds to a retried migration request with the
+// task submitted by the first request.
+func writeExistingMigrationTask(w http.ResponseWriter, t task.Task) {
+	if t.TaskType != MIGRATE_TASK {
+		http.Error(w, fmt.Sprintf("Task id %s is already used by a task of type %s", t.TaskId, t.TaskType), http.StatusConflict)
+		return
+	}
+	log.Println("migration task already submitted", "taskid", t.TaskId, "status", t.Status)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(t)
+}
+
+// getTaskStatus returns the status of the background task with the id given
+// by the taskId query parameter.
+func getTaskStatus(w http.ResponseWriter, r *http.Request) {
+	taskId := r.FormValue("taskId")
+	if taskId == "" {
+		http.Error(w, "taskId is required", http.StatusBadRequest)
+		return
+	}
+	t, err := taskQueue.Get(r.Context(), taskId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Can't get task: %v", err), http.StatusNotFound)
+		return
 	}
 	w.WriteHeader(http.StatusOK)
-	log.Println("migration completed", "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
+	json.NewEncoder(w).Encode(t)
 }
 
 func getGeneratedResources(w http.ResponseWriter, r *http.Request) {
@@ -2399,8 +2538,10 @@ func getSourceAndTargetProfiles(sessionState *session.SessionState, details migr
 		sourceProfileString = sourceProfileString + fmt.Sprintf(",streamingCfg=%v", fileName)
 	} else {
 		sessionState.Conv.Audit.MigrationRequestId = "SMT-" + uuid.New().String()
-		sessionState.Bucket = strings.ToLower(sessionState.Conv.Audit.MigrationRequestId)
-		sessionState.RootPath = "/"
+		sessionState.Bucket, sessionState.RootPath, err = session.GetMigrationBucket(sessionState, sessionState.Conv.Audit.MigrationRequestId)
+		if err != nil {
+			return profiles.SourceProfile{}, profiles.TargetProfile{}, utils.IOStreams{}, "", fmt.Errorf("error while getting migration bucket: %v", err)
+		}
 	}
 	source, err := helpers.GetSourceDatabaseFromDriver(sessionState.Driver)
 	if err != nil {
@@ -2486,9 +2627,9 @@ func createConfigFileForShardedBulkMigration(sessionState *session.SessionState,
 
 func writeSessionFile(sessionState *session.SessionState) error {
 
-	err := utils.CreateGCSBucket(sessionState.Bucket, sessionState.GCPProjectID, sessionState.Region)
+	err := utils.PrepareGCSBucket(sessionState.Bucket, sessionState.RootPath, sessionState.GCPProjectID, sessionState.Region, sessionState.UserManagedBucket != "")
 	if err != nil {
-		return fmt.Errorf("error while creating bucket: %v", err)
+		return fmt.Errorf("error while preparing bucket: %v", err)
 	}
 
 	convJSON, err := json.MarshalIndent(sessionState.Conv, "", " ")
@@ -2537,6 +2678,7 @@ func createStreamingCfgFile(sessionState *session.SessionState, targetDetails ta
 			AdditionalUserLabels: dataflowConfig.AdditionalUserLabels,
 			KmsKeyName:           dataflowConfig.KmsKeyName,
 			GcsTemplatePath:      dataflowConfig.GcsTemplatePath,
+			StreamingEngine:      dataflowConfig.StreamingEngine,
 		},
 		TmpDir: "gs://" + sessionState.Bucket + sessionState.RootPath,
 	}
@@ -3091,7 +3233,7 @@ func initializeTypeMap() {
 	}
 	// Initialize postgresTypeMap.
 	toddl = postgres.InfoSchemaImpl{}.GetToDdl()
-	for _, srcTypeName := range []string{"bool", "boolean", "bigserial", "bpchar", "character", "bytea", "date", "float8", "double precision", "float4", "real", "int8", "bigint", "int4", "integer", "int2", "smallint", "numeric", "serial", "text", "timestamptz", "timestamp with time zone", "timestamp", "timestamp without time zone", "varchar", "character varying"} {
+	for _, srcTypeName := range []string{"bool", "boolean", "bigserial", "bpchar", "character", "bytea", "date", "float8", "double precision", "float4", "real", "int8", "bigint", "int4", "integer", "int2", "smallint", "numeric", "serial", "text", "timestamptz", "timestamp with time zone", "timestamp", "timestamp without time zone", "varchar", "character varying", "record"} {
 		var l []typeIssue
 		srcType := schema.MakeType()
 		srcType.Name = srcTypeName
@@ -3140,15 +3282,21 @@ func init() {
 	utilities.InitObjectId()
 	sessionState.Conv = internal.MakeConv()
 	config := config.TryInitializeSpannerConfig()
+	sessionState.UserManagedBucket = config.UserManagedBucket
 	session.SetSessionStorageConnectionState(config.GCPProjectID, config.SpannerInstanceID)
 }
 
 // App connects to the web app v2.
-func App(logLevel string, open bool, port int) error {
-	err := logger.InitializeLogger(logLevel)
+func App(logConfig logger.Config, open bool, port int) error {
+	err := logger.InitializeLoggerWithConfig(logConfig)
+	if err != nil {
+		return fmt.Errorf("error initialising webapp, did you specify a valid log-level? [DEBUG, INFO]: %v", err)
+	}
+	flushTraces, err := tracing.Init(context.Background())
 	if err != nil {
-		return fmt.Errorf("error initialising webapp, did you specify a valid log-level? [DEBUG, INFO]")
+		return fmt.Errorf("error initialising webapp: %v", err)
 	}
+	defer flushTraces()
 	addr := fmt.Sprintf(":%s", strconv.Itoa(port))
 	router := getRoutes()
 	fmt.Println("Starting Spanner migration tool UI at:", fmt.Sprintf("http://localhost%s", addr))
@@ -3156,5 +3304,56 @@ func App(logLevel string, open bool, port int) error {
 	if open {
 		browser.OpenURL(fmt.Sprintf("http://localhost%s", addr))
 	}
-	return http.ListenAndServe(addr, handlers.CORS(handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"}), handlers.AllowedMethods([]string{"GET", "POST", "PUT", "HEAD", "OPTIONS"}), handlers.AllowedOrigins([]string{"*"}))(router))
+	taskQueue, err = newTaskQueue()
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Addr:    addr,
+		Handler: handlers.CORS(handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"}), handlers.AllowedMethods([]string{"GET", "POST", "PUT", "HEAD", "OPTIONS"}), handlers.AllowedOrigins([]string{"*"}))(router),
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	select {
+	case err := <-serverErr:
+		return err
+	case <-stop:
+	}
+
+	fmt.Println("Shutting down, waiting for running tasks to finish...")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("error shutting down webapp: %v", err)
+	}
+	if err := taskQueue.Shutdown(ctx); err != nil {
+		return fmt.Errorf("running tasks didn't finish before shutdown: %v", err)
+	}
+	return nil
+}
+
+// newTaskQueue returns a started task queue, which records the tasks in the
+// metadata database if it is available and in memory otherwise.
+func newTaskQueue() (*task.Queue, error) {
+	ctx := context.Background()
+	store := task.NewLocalTaskStore()
+	sessionState := session.GetSessionState()
+	if !sessionState.IsOffline {
+		spannerClient, err := spanner.NewClient(ctx, helpers.GetSpannerUri(sessionState.GCPProjectID, sessionState.SpannerInstanceID))
+		if err != nil {
+			fmt.Printf("can't connect to the metadata database, background tasks are only kept in memory: %v\n", err)
+		} else {
+			store = task.NewRemoteTaskStore(spannerClient)
+		}
+	}
+	q := task.NewQueue(store, taskWorkers, taskQueueSize)
+	if err := q.Start(ctx); err != nil {
+		return nil, fmt.Errorf("error starting background task queue: %v", err)
+	}
+	return q, nil
 }
@@ -24,17 +24,20 @@ import (
 	"path/filepath"
 
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/common/constants"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
 	"github.com/google/subcommands"
 )
 
 var FrontendDir embed.FS
 
 type WebCmd struct {
-	DistDir  embed.FS
-	logLevel string
-	open     bool
-	port     int
-	validate bool
+	DistDir   embed.FS
+	logLevel  string
+	logFormat string
+	logFile   string
+	open      bool
+	port      int
+	validate  bool
 }
 
 // Name returns the name of operation.
@@ -53,6 +56,8 @@ func (cmd *WebCmd) Usage() string {
 
 func (cmd *WebCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&cmd.logLevel, "log-level", "DEBUG", "Configure the logging level for the command (INFO, DEBUG), defaults to DEBUG")
+	f.StringVar(&cmd.logFormat, "log-format", logger.CONSOLE_ENCODING, "Encoding of the logs written to the console (console, json), defaults to console")
+	f.StringVar(&cmd.logFile, "log-file", logger.LOG_FILE_NAME, "File the logs are appended to, defaults to spanner-migration-tool.log")
 	f.BoolVar(&cmd.open, "open", false, "Opens the Spanner migration tool web interface in the default browser, defaults to false")
 	f.IntVar(&cmd.port, "port", 8080, "The port in which Spanner migration tool will run, defaults to 8080")
 	f.BoolVar(&cmd.validate, "validate", false, "Flag for validating if all the required input parameters are present")
@@ -70,6 +75,6 @@ func (cmd *WebCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{
 			fmt.Printf("FATAL error, unable to start webapp: %s", err)
 		}
 	}()
-	err = App(cmd.logLevel, cmd.open, cmd.port)
+	err = App(logger.Config{Level: cmd.logLevel, Encoding: cmd.logFormat, OutputPath: cmd.logFile}, cmd.open, cmd.port)
 	return subcommands.ExitSuccess
 }
@@ -15,11 +15,15 @@
 package webv2
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 
@@ -27,6 +31,7 @@ import (
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/internal/reports"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/logger"
+	"github.com/GoogleCloudPlatform/spanner-migration-tool/profiles"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/proto/migration"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/schema"
 	"github.com/GoogleCloudPlatform/spanner-migration-tool/spanner/ddl"
@@ -3088,3 +3093,94 @@ func buildConvPostgres(conv *internal.Conv) {
 	conv.SyntheticPKeys["t2"] = internal.SyntheticPKey{"c20", 0}
 	conv.Audit.MigrationType = migration.MigrationData_SCHEMA_AND_DATA.Enum()
 }
+
+func TestStreamProgress(t *testing.T) {
+	sessionState := session.GetSessionState()
+	sessionState.Conv = internal.MakeConv()
+	sessionState.Error = nil
+	server := httptest.NewServer(http.HandlerFunc(streamProgress))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	assert.Nil(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := bufio.NewScanner(resp.Body)
+	readEvent := func() progressDetails {
+		var detail progressDetails
+		for events.Scan() {
+			if line := events.Text(); strings.HasPrefix(line, "data: ") {
+				assert.Nil(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &detail))
+				return detail
+			}
+		}
+		t.Fatal("progress stream ended unexpectedly")
+		return detail
+	}
+	assert.Equal(t, progressDetails{}, readEvent())
+
+	sessionState.Conv.ConvLock.Lock()
+	sessionState.Conv.Audit.Progress.UpdateProgress("", 40, internal.DataWriteInProgress)
+	sessionState.Conv.ConvLock.Unlock()
+	assert.Equal(t, progressDetails{Progress: 40, ProgressStatus: int(internal.DataWriteInProgress)}, readEvent())
+}
+
+func TestGetReverseReplicationJobDataForSession(t *testing.T) {
+	sessionState := session.GetSessionState()
+	sessionState.Conv = internal.MakeConv()
+	sessionState.Conv.Audit.MigrationRequestId = "SMT-test"
+	sessionState.GCPProjectID = "test-project"
+	sessionState.SpannerInstanceID = "test-instance"
+	sessionState.SpannerDatabaseName = "test-db"
+	sessionState.Region = "us-central1"
+	sessionState.Bucket = "test-bucket"
+	sessionState.RootPath = "/SMT-test/"
+	sessionState.MigrationSourceProfile = profiles.SourceProfile{
+		Ty: profiles.SourceProfileTypeConnection,
+		Conn: profiles.SourceProfileConnection{
+			Ty:    profiles.SourceProfileConnectionTypeMySQL,
+			Mysql: profiles.SourceProfileConnectionMySQL{Host: "localhost", Port: "3306", User: "root", Pwd: "pwd", Db: "shard1"},
+		},
+	}
+	jobData, err := getReverseReplicationJobDataForSession(context.Background(), sessionState)
+	assert.Nil(t, err)
+	defer os.Remove(jobData.JobData.SourceShardsFilePath)
+	assert.Equal(t, "test-project", jobData.JobData.ProjectId)
+	assert.Equal(t, "test-instance", jobData.JobData.InstanceId)
+	assert.Equal(t, "test-db", jobData.JobData.DbName)
+	assert.Equal(t, "us-central1", jobData.JobData.DataflowRegion)
+	assert.Equal(t, "gs://test-bucket/SMT-test/session.json", jobData.JobData.SessionFilePath)
+	assert.Equal(t, "gs://test-bucket/SMT-test/reverse-replication", jobData.JobData.Flags["artifactsPath"])
+	shards, err := ioutil.ReadFile(jobData.JobData.SourceShardsFilePath)
+	assert.Nil(t, err)
+	assert.Contains(t, string(shards), `"logicalShardId": "shard1"`)
+
+	sessionState.MigrationSourceProfile.Conn.Streaming = true
+	_, err = getReverseReplicationJobDataForSession(context.Background(), sessionState)
+	assert.NotNil(t, err)
+}
+
+func TestGetDownloadObject(t *testing.T) {
+	sessionState := &session.SessionState{}
+	_, _, err := getDownloadObject(sessionState, "session.json")
+	assert.NotNil(t, err)
+
+	sessionState.Bucket = "test-bucket"
+	sessionState.RootPath = "/SMT-test/"
+	bucket, name, err := getDownloadObject(sessionState, "session.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "test-bucket", bucket)
+	assert.Equal(t, "SMT-test/session.json", name)
+	_, name, err = getDownloadObject(sessionState, "reports/skipped.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "SMT-test/reports/skipped.txt", name)
+
+	for _, file := range []string{"", "/other/session.json", "../other/session.json", "reports/../../session.json", "reports//skipped.txt"} {
+		_, _, err := getDownloadObject(sessionState, file)
+		assert.NotNil(t, err, file)
+	}
+}